@@ -0,0 +1,237 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package abi
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"golang.org/x/crypto/sha3"
+)
+
+const WordSize = 32
+
+// selectorSize is the number of bytes of the signature hash that identify the method at the start of calldata
+const selectorSize = 4
+
+// revertDataRegex finds ABI encoded revert data for the standard Error(string) and Panic(uint256) errors in an error message
+var revertDataRegex = regexp.MustCompile(`0x(08c379a0|4e487b71)[0-9a-fA-F]*`)
+
+// Type is a parsed elementary ABI type. Arrays and tuples are not supported
+type Type struct {
+	base string // address, bool, uint, int, bytes or string
+	size int    // bits for uint/int, length for fixed bytes, 0 for dynamic bytes and string
+}
+
+// Function is the JSON ABI definition of a contract method, as supplied in the method of a transaction
+type Function struct {
+	Type   string                   `json:"type,omitempty"` // must be "function" if set
+	Name   string                   `json:"name"`
+	Inputs []*apitypes.ABIParameter `json:"inputs"`
+}
+
+func (t *Type) String() string {
+	switch t.base {
+	case "uint", "int":
+		return fmt.Sprintf("%s%d", t.base, t.size)
+	case "bytes":
+		if t.size > 0 {
+			return fmt.Sprintf("bytes%d", t.size)
+		}
+	}
+	return t.base
+}
+
+func (t *Type) Dynamic() bool {
+	return t.base == "string" || (t.base == "bytes" && t.size == 0)
+}
+
+func ParseType(t string) (*Type, bool) {
+	switch t {
+	case "address", "bool", "string", "bytes":
+		return &Type{base: t}, true
+	case "uint", "int":
+		return &Type{base: t, size: 256}, true
+	}
+	for _, base := range []string{"uint", "int", "bytes"} {
+		if !strings.HasPrefix(t, base) {
+			continue
+		}
+		size, err := strconv.Atoi(strings.TrimPrefix(t, base))
+		if err != nil {
+			return nil, false
+		}
+		if base == "bytes" && size >= 1 && size <= 32 {
+			return &Type{base: base, size: size}, true
+		}
+		if base != "bytes" && size >= 8 && size <= 256 && size%8 == 0 {
+			return &Type{base: base, size: size}, true
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// SignatureHash returns the keccak256 hash of the canonical signature of an event or method
+func SignatureHash(name string, types []*Type) []byte {
+	typeNames := make([]string, len(types))
+	for i, t := range types {
+		typeNames[i] = t.String()
+	}
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(fmt.Sprintf("%s(%s)", name, strings.Join(typeNames, ","))))
+	return hash.Sum(nil)
+}
+
+// DecodeData decodes the parameter at the specified position in ABI encoded data
+func DecodeData(data []byte, idx int, t *Type) (interface{}, error) {
+	word, err := abiWord(data, idx*WordSize)
+	if err != nil {
+		return nil, err
+	}
+	if !t.Dynamic() {
+		return DecodeWord(word, t), nil
+	}
+	offset := new(big.Int).SetBytes(word)
+	if !offset.IsInt64() || offset.Int64() > int64(len(data)) {
+		return nil, fmt.Errorf("offset %s out of range", offset)
+	}
+	lengthWord, err := abiWord(data, int(offset.Int64()))
+	if err != nil {
+		return nil, err
+	}
+	length := new(big.Int).SetBytes(lengthWord)
+	start := offset.Int64() + WordSize
+	if !length.IsInt64() || start+length.Int64() > int64(len(data)) {
+		return nil, fmt.Errorf("length %s out of range", length)
+	}
+	b := data[start : start+length.Int64()]
+	if t.base == "string" {
+		return string(b), nil
+	}
+	return "0x" + hex.EncodeToString(b), nil
+}
+
+// DecodeWord decodes a 32 byte word containing a static type. Integers are returned as
+// decimal strings, so that large values are not truncated by JSON consumers
+func DecodeWord(word []byte, t *Type) interface{} {
+	switch t.base {
+	case "address":
+		return "0x" + hex.EncodeToString(word[WordSize-20:])
+	case "bool":
+		return word[WordSize-1] != 0
+	case "int":
+		i := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			i.Sub(i, new(big.Int).Lsh(big.NewInt(1), WordSize*8))
+		}
+		return i.String()
+	case "uint":
+		return new(big.Int).SetBytes(word).String()
+	default: // fixed bytes
+		return "0x" + hex.EncodeToString(word[:t.size])
+	}
+}
+
+func abiWord(data []byte, offset int) ([]byte, error) {
+	if offset+WordSize > len(data) {
+		return nil, fmt.Errorf("insufficient data")
+	}
+	return data[offset : offset+WordSize], nil
+}
+
+func DecodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+}
+
+// DecodeFunctionInput decodes the named arguments of a method call from hex encoded calldata, which
+// must start with the selector of the method
+func DecodeFunctionInput(ctx context.Context, method *fftypes.JSONAny, calldata string) (*apitypes.DecodedInput, error) {
+	var f Function
+	if method == nil || method.Unmarshal(ctx, &f) != nil || f.Name == "" || (f.Type != "" && f.Type != "function") {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidMethodABI, f.Name, "name is required, and type must be 'function'")
+	}
+	types := make([]*Type, len(f.Inputs))
+	for i, p := range f.Inputs {
+		t, ok := ParseType(p.Type)
+		if !ok {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidMethodABI, f.Name, fmt.Sprintf("unsupported type '%s'", p.Type))
+		}
+		types[i] = t
+	}
+	data, err := DecodeHex(calldata)
+	if err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgMethodInputDecodeFailed, f.Name, "calldata is not hex encoded")
+	}
+	selector := SignatureHash(f.Name, types)[:selectorSize]
+	if len(data) < selectorSize || hex.EncodeToString(data[:selectorSize]) != hex.EncodeToString(selector) {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgMethodInputDecodeFailed, f.Name, "calldata does not start with the method selector")
+	}
+	args := fftypes.JSONObject{}
+	for i, p := range f.Inputs {
+		name := p.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		value, err := DecodeData(data[selectorSize:], i, types[i])
+		if err != nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgMethodInputDecodeFailed, f.Name, fmt.Sprintf("%s: %s", name, err))
+		}
+		args[name] = value
+	}
+	return &apitypes.DecodedInput{
+		Method:    f.Name,
+		Signature: fmt.Sprintf("0x%s", hex.EncodeToString(selector)),
+		Args:      fftypes.JSONAnyPtr(args.String()),
+	}, nil
+}
+
+// DecodeRevertReason returns the reason a transaction reverted, from the error the connector returned for it.
+// If the error contains the ABI encoded revert data, the message of an Error(string) or the code of a Panic(uint256)
+// is decoded from it. Otherwise the connector is assumed to have decoded the reason, and the error message is returned.
+func DecodeRevertReason(err error) string {
+	match := revertDataRegex.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err.Error()
+	}
+	data, decodeErr := DecodeHex(match[0])
+	if decodeErr != nil {
+		// Odd number of hex digits
+		return err.Error()
+	}
+	if match[1] == "08c379a0" {
+		t, _ := ParseType("string")
+		if reason, decodeErr := DecodeData(data[selectorSize:], 0, t); decodeErr == nil {
+			return reason.(string)
+		}
+		return err.Error()
+	}
+	word, decodeErr := abiWord(data[selectorSize:], 0)
+	if decodeErr != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("Panic(0x%x)", new(big.Int).SetBytes(word))
+}
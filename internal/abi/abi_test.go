@@ -0,0 +1,157 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package abi
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/stretchr/testify/assert"
+)
+
+const transferMethodABI = `{
+	"type": "function",
+	"name": "transfer",
+	"inputs": [
+		{"name": "to", "type": "address"},
+		{"name": "value", "type": "uint256"}
+	]
+}`
+
+const sampleTransferCalldata = "0xa9059cbb" +
+	"0000000000000000000000000102030405060708090a0b0c0d0e0f1011121314" +
+	"00000000000000000000000000000000000000000000000000000000000003e8"
+
+func TestDecodeFunctionInputTransfer(t *testing.T) {
+	decoded, err := DecodeFunctionInput(context.Background(), fftypes.JSONAnyPtr(transferMethodABI), sampleTransferCalldata)
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer", decoded.Method)
+	assert.Equal(t, "0xa9059cbb", decoded.Signature)
+	args := decoded.Args.JSONObject()
+	assert.Equal(t, "0x0102030405060708090a0b0c0d0e0f1011121314", args.GetString("to"))
+	assert.Equal(t, "1000", args.GetString("value"))
+}
+
+func TestDecodeFunctionInputDynamicUnnamed(t *testing.T) {
+	pString, _ := ParseType("string")
+	pBool, _ := ParseType("bool")
+	selector := hex.EncodeToString(SignatureHash("setName", []*Type{pString, pBool})[:4])
+	decoded, err := DecodeFunctionInput(context.Background(), fftypes.JSONAnyPtr(`{
+		"name": "setName",
+		"inputs": [{"name": "name", "type": "string"}, {"type": "bool"}]
+	}`), "0x"+selector+
+		"0000000000000000000000000000000000000000000000000000000000000040"+
+		"0000000000000000000000000000000000000000000000000000000000000001"+
+		"0000000000000000000000000000000000000000000000000000000000000005"+
+		"68656c6c6f000000000000000000000000000000000000000000000000000000")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "hello", "1": true}`, decoded.Args.String())
+}
+
+func TestDecodeFunctionInputBadABI(t *testing.T) {
+	_, err := DecodeFunctionInput(context.Background(), nil, sampleTransferCalldata)
+	assert.Regexp(t, "FF21146", err)
+
+	_, err = DecodeFunctionInput(context.Background(), fftypes.JSONAnyPtr(`{"type": "event", "name": "transfer"}`), sampleTransferCalldata)
+	assert.Regexp(t, "FF21146", err)
+
+	_, err = DecodeFunctionInput(context.Background(), fftypes.JSONAnyPtr(`{"name": "transfer", "inputs": [{"type": "uint256[]"}]}`), sampleTransferCalldata)
+	assert.Regexp(t, "FF21146.*uint256\\[\\]", err)
+}
+
+func TestDecodeFunctionInputBadCalldata(t *testing.T) {
+	method := fftypes.JSONAnyPtr(transferMethodABI)
+
+	_, err := DecodeFunctionInput(context.Background(), method, "not hex")
+	assert.Regexp(t, "FF21147", err)
+
+	_, err = DecodeFunctionInput(context.Background(), method, "0x12345678"+sampleTransferCalldata[10:])
+	assert.Regexp(t, "FF21147.*selector", err)
+
+	_, err = DecodeFunctionInput(context.Background(), method, sampleTransferCalldata[:74])
+	assert.Regexp(t, "FF21147.*value: insufficient data", err)
+}
+
+func TestParseType(t *testing.T) {
+	for in, out := range map[string]string{
+		"uint":    "uint256",
+		"int64":   "int64",
+		"bytes32": "bytes32",
+		"bytes":   "bytes",
+		"address": "address",
+	} {
+		pt, ok := ParseType(in)
+		assert.True(t, ok)
+		assert.Equal(t, out, pt.String())
+	}
+	for _, in := range []string{"uint7", "bytes33", "intx", "tuple"} {
+		_, ok := ParseType(in)
+		assert.False(t, ok)
+	}
+}
+
+func TestDecodeDataAndWords(t *testing.T) {
+	word := func(s string) []byte {
+		b, _ := DecodeHex(s)
+		return b
+	}
+	pInt, _ := ParseType("int8")
+	assert.Equal(t, "-1", DecodeWord(word("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"), pInt))
+	pBytes4, _ := ParseType("bytes4")
+	assert.Equal(t, "0x01020304", DecodeWord(word("0102030400000000000000000000000000000000000000000000000000000000"), pBytes4))
+
+	pBytes, _ := ParseType("bytes")
+	_, err := DecodeData(word("00000000000000000000000000000000000000000000000000000000000000ff"), 0, pBytes)
+	assert.Regexp(t, "offset 255 out of range", err)
+	_, err = DecodeData(word("0000000000000000000000000000000000000000000000000000000000000020"), 0, pBytes)
+	assert.Regexp(t, "insufficient data", err)
+	_, err = DecodeData(word("0000000000000000000000000000000000000000000000000000000000000020"+
+		"0000000000000000000000000000000000000000000000000000000000000021"), 0, pBytes)
+	assert.Regexp(t, "length 33 out of range", err)
+	v, err := DecodeData(word("0000000000000000000000000000000000000000000000000000000000000020"+
+		"0000000000000000000000000000000000000000000000000000000000000002"+
+		"abcd000000000000000000000000000000000000000000000000000000000000"), 0, pBytes)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xabcd", v)
+}
+
+func TestDecodeRevertReason(t *testing.T) {
+	// Error("not enough tokens")
+	errorData := "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000011" +
+		"6e6f7420656e6f75676820746f6b656e73000000000000000000000000000000"
+	assert.Equal(t, "not enough tokens", DecodeRevertReason(fmt.Errorf("execution reverted: %s", errorData)))
+
+	// Panic(0x11) - arithmetic overflow
+	panicData := "0x4e487b71" +
+		"0000000000000000000000000000000000000000000000000000000000000011"
+	assert.Equal(t, "Panic(0x11)", DecodeRevertReason(fmt.Errorf("execution reverted: %s", panicData)))
+
+	// Already decoded by the connector, or data that cannot be decoded
+	for _, msg := range []string{
+		"execution reverted: not enough tokens",
+		"execution reverted: 0x08c379a",
+		"execution reverted: 0x08c379a00000",
+		"execution reverted: 0x4e487b7100",
+	} {
+		assert.Equal(t, msg, DecodeRevertReason(fmt.Errorf(msg)))
+	}
+}
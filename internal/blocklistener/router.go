@@ -0,0 +1,124 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocklistener
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Router fans out the new block events from a single connector block listener, to every
+// event stream that has been configured to use it in place of the blocks delivered
+// to the stream by the connector.
+//
+// Each consumer must be a channel returned by BufferChannel, which always consumes from the
+// channel until its context is cancelled. So consumers must be removed before their context
+// is cancelled.
+type Router struct {
+	Name      string
+	Filters   []fftypes.JSONAny
+	mux       sync.Mutex
+	consumers map[fftypes.UUID]chan<- *ffcapi.BlockHashEvent
+}
+
+// Routers are the configured block listeners, by name
+type Routers map[string]*Router
+
+func NewRouter(name string, filters []fftypes.JSONAny) *Router {
+	return &Router{
+		Name:      name,
+		Filters:   filters,
+		consumers: make(map[fftypes.UUID]chan<- *ffcapi.BlockHashEvent),
+	}
+}
+
+// NewRoutersFromConfig reads the configured block listeners, each of which is scoped with connector
+// specific filters to the blocks of interest to the event streams that select it
+func NewRoutersFromConfig(ctx context.Context, conf config.ArraySection) (Routers, error) {
+	routers := make(Routers)
+	for i := 0; i < conf.ArraySize(); i++ {
+		entry := conf.ArrayEntry(i)
+		name := entry.GetString(tmconfig.BlockListenerConfigName)
+		if name == "" {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgMissingName)
+		}
+		if _, exists := routers[name]; exists {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgDuplicateBlockListenerName, name)
+		}
+		var filters []fftypes.JSONAny
+		for _, f := range entry.GetObjectArray(tmconfig.BlockListenerConfigFilters) {
+			filters = append(filters, *fftypes.JSONAnyPtr(f.String()))
+		}
+		routers[name] = NewRouter(name, filters)
+	}
+	return routers, nil
+}
+
+func (r *Router) AddConsumer(id *fftypes.UUID, blocks chan<- *ffcapi.BlockHashEvent) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.consumers[*id] = blocks
+}
+
+func (r *Router) RemoveConsumer(id *fftypes.UUID) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	delete(r.consumers, *id)
+}
+
+// Start returns the channel to pass to the connector as the block listener, and a channel
+// that is closed once the router has stopped after the context is cancelled
+func (r *Router) Start(ctx context.Context) (blocks chan *ffcapi.BlockHashEvent, done chan struct{}) {
+	blocks = make(chan *ffcapi.BlockHashEvent)
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case update := <-blocks:
+				r.route(ctx, update)
+			case <-ctx.Done():
+				log.L(ctx).Debugf("Block listener '%s' exiting", r.Name)
+				return
+			}
+		}
+	}()
+	return blocks, done
+}
+
+func (r *Router) route(ctx context.Context, update *ffcapi.BlockHashEvent) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	log.L(ctx).Debugf("Block listener '%s' routing block event to %d streams: %v", r.Name, len(r.consumers), update.BlockHashes)
+	for _, c := range r.consumers {
+		// Each consumer takes its own copy, as a blocked consumer marks the gap potential on the event
+		var bu = *update
+		select {
+		case c <- &bu:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
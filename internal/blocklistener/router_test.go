@@ -0,0 +1,121 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocklistener
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterDeliversToEachConsumer(t *testing.T) {
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	r := NewRouter("ut", []fftypes.JSONAny{`{"address":"0x12345"}`})
+	blocks, done := r.Start(ctx)
+
+	c1, c2 := make(chan *ffcapi.BlockHashEvent, 1), make(chan *ffcapi.BlockHashEvent, 1)
+	id1, id2 := fftypes.NewUUID(), fftypes.NewUUID()
+	r.AddConsumer(id1, c1)
+	r.AddConsumer(id2, c2)
+
+	blocks <- &ffcapi.BlockHashEvent{BlockHashes: []string{"0x1111"}}
+	bhe1, bhe2 := <-c1, <-c2
+	assert.Equal(t, []string{"0x1111"}, bhe1.BlockHashes)
+	assert.Equal(t, []string{"0x1111"}, bhe2.BlockHashes)
+	assert.NotSame(t, bhe1, bhe2)
+
+	r.RemoveConsumer(id1)
+	blocks <- &ffcapi.BlockHashEvent{BlockHashes: []string{"0x2222"}}
+	assert.Equal(t, []string{"0x2222"}, (<-c2).BlockHashes)
+	assert.Empty(t, c1)
+
+	cancelCtx()
+	<-done
+
+}
+
+func TestRouterExitsWhileBlocked(t *testing.T) {
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	r := NewRouter("ut", nil)
+	blocks, done := r.Start(ctx)
+	r.AddConsumer(fftypes.NewUUID(), make(chan *ffcapi.BlockHashEvent))
+
+	blocks <- &ffcapi.BlockHashEvent{}
+	cancelCtx()
+	<-done
+
+}
+
+func setTestBlockListenersConfig(t *testing.T, yaml string) {
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(yaml))
+	assert.NoError(t, err)
+}
+
+func TestNewRoutersFromConfig(t *testing.T) {
+
+	tmconfig.Reset()
+	setTestBlockListenersConfig(t, `
+blocklisteners:
+- name: tokens
+  filters:
+  - address: "0x1111"
+- name: all
+`)
+	routers, err := NewRoutersFromConfig(context.Background(), tmconfig.BlockListenersConfig)
+	assert.NoError(t, err)
+
+	assert.Len(t, routers, 2)
+	assert.Equal(t, "tokens", routers["tokens"].Name)
+	assert.Len(t, routers["tokens"].Filters, 1)
+	assert.JSONEq(t, `{"address":"0x1111"}`, routers["tokens"].Filters[0].String())
+	assert.Empty(t, routers["all"].Filters)
+
+}
+
+func TestNewRoutersFromConfigMissingName(t *testing.T) {
+
+	tmconfig.Reset()
+	setTestBlockListenersConfig(t, `
+blocklisteners:
+- filters: []
+`)
+	_, err := NewRoutersFromConfig(context.Background(), tmconfig.BlockListenersConfig)
+	assert.Regexp(t, "FF21028", err)
+
+}
+
+func TestNewRoutersFromConfigDuplicateName(t *testing.T) {
+
+	tmconfig.Reset()
+	setTestBlockListenersConfig(t, `
+blocklisteners:
+- name: tokens
+- name: tokens
+`)
+	_, err := NewRoutersFromConfig(context.Background(), tmconfig.BlockListenersConfig)
+	assert.Regexp(t, "FF21128.*tokens", err)
+
+}
@@ -17,9 +17,11 @@ package confirmations
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
@@ -40,6 +42,8 @@ type Manager interface {
 	Stop()
 	NewBlockHashes() chan<- *ffcapi.BlockHashEvent
 	CheckInFlight(listenerID *fftypes.UUID) bool
+	HighestBlockSeen() uint64
+	TransactionProgress(txHash string) *TransactionProgress
 }
 
 type NotificationType int
@@ -60,14 +64,26 @@ type Notification struct {
 }
 
 type EventInfo struct {
-	ID        *ffcapi.EventID
-	Confirmed func(ctx context.Context, confirmations []BlockInfo)
+	ID         *ffcapi.EventID
+	Confirmed  func(ctx context.Context, confirmations []BlockInfo)
+	RolledBack func(ctx context.Context, removedBlock BlockInfo) // optional - called if the block of an event is re-orged out after it was confirmed
 }
 
 type TransactionInfo struct {
 	TransactionHash string
 	Receipt         func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse)
 	Confirmed       func(ctx context.Context, confirmations []BlockInfo)
+	Required        *Requirement // optional - overrides the default requirement of the confirmation manager
+	Milestones      []int        // optional - ascending confirmation depths to call Milestone at, tracking past the requirement until the last is reached
+	Milestone       func(ctx context.Context, confirmations []BlockInfo)
+}
+
+// TransactionProgress is a point-in-time view of the confirmations accumulated
+// for a transaction that is being tracked by the confirmation manager
+type TransactionProgress struct {
+	BlockNumber   fftypes.FFuint64 // zero until the receipt has been downloaded
+	BlockHash     string           // empty until the receipt has been downloaded
+	Confirmations []BlockInfo
 }
 
 type RemovedListenerInfo struct {
@@ -90,25 +106,44 @@ type blockConfirmationManager struct {
 	connector             ffcapi.API
 	blockListenerStale    bool
 	requiredConfirmations int
+	finalized             bool   // wait for finality by default, rather than counting confirmations
+	finalizedBlock        uint64 // the latest finalized block reported by the connector, or zero if unknown
 	staleReceiptTimeout   time.Duration
+	confirmationTimeout   time.Duration
+	settleDelay           time.Duration
+	receiptPollInterval   time.Duration
+	maxReceiptChecks      int
+	receiptBatchSize      int
 	bcmNotifications      chan *Notification
 	highestBlockSeen      uint64
 	pending               map[string]*pendingItem
 	pendingMux            sync.Mutex
 	staleReceipts         map[string]bool
+	reorgDetectionDepth   uint64
+	canonicalBlocks       map[uint64]*BlockInfo // the recent chain, as notified to us, for detecting re-orgs
+	dispatched            []*pendingItem        // recently confirmed events, that would be rolled back by a re-org
 	done                  chan struct{}
 }
 
-func NewBlockConfirmationManager(baseContext context.Context, connector ffcapi.API, desc string) Manager {
+// NewBlockConfirmationManager creates a confirmation manager that waits for the supplied number of
+// confirmations, which can be the global default or an override (such as for an individual event stream)
+func NewBlockConfirmationManager(baseContext context.Context, connector ffcapi.API, desc string, requiredConfirmations int) Manager {
 	bcm := &blockConfirmationManager{
 		baseContext:           baseContext,
 		connector:             connector,
 		blockListenerStale:    true,
-		requiredConfirmations: config.GetInt(tmconfig.ConfirmationsRequired),
+		requiredConfirmations: requiredConfirmations,
 		staleReceiptTimeout:   config.GetDuration(tmconfig.ConfirmationsStaleReceiptTimeout),
+		confirmationTimeout:   config.GetDuration(tmconfig.ConfirmationsConfirmationTimeout),
+		settleDelay:           config.GetDuration(tmconfig.ConfirmationsSettleDelay),
+		receiptPollInterval:   config.GetDuration(tmconfig.ConfirmationsReceiptPollInterval),
+		maxReceiptChecks:      config.GetInt(tmconfig.ConfirmationsMaxReceiptChecks),
+		receiptBatchSize:      config.GetInt(tmconfig.ConfirmationsReceiptBatchSize),
 		bcmNotifications:      make(chan *Notification, config.GetInt(tmconfig.ConfirmationsNotificationQueueLength)),
 		pending:               make(map[string]*pendingItem),
 		staleReceipts:         make(map[string]bool),
+		reorgDetectionDepth:   uint64(config.GetInt(tmconfig.ConfirmationsReorgDetectionDepth)),
+		canonicalBlocks:       make(map[uint64]*BlockInfo),
 		newBlockHashes:        make(chan *ffcapi.BlockHashEvent, config.GetInt(tmconfig.ConfirmationsBlockQueueLength)),
 	}
 	bcm.ctx, bcm.cancelFunc = context.WithCancel(baseContext)
@@ -117,6 +152,14 @@ func NewBlockConfirmationManager(baseContext context.Context, connector ffcapi.A
 	return bcm
 }
 
+// NewBlockConfirmationManagerForRequirement creates a confirmation manager that applies the supplied
+// requirement by default, such as one resolved from a named confirmation strategy
+func NewBlockConfirmationManagerForRequirement(baseContext context.Context, connector ffcapi.API, desc string, required *Requirement) Manager {
+	bcm := NewBlockConfirmationManager(baseContext, connector, desc, required.Confirmations).(*blockConfirmationManager)
+	bcm.finalized = required.Finalized
+	return bcm
+}
+
 type pendingType int
 
 const (
@@ -127,18 +170,26 @@ const (
 // pendingItem could be a specific event that has been detected, but not confirmed yet.
 // Or it could be a transaction
 type pendingItem struct {
-	pType             pendingType
-	added             time.Time
-	confirmations     []*BlockInfo
-	lastReceiptCheck  time.Time
-	receiptCallback   func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse)
-	confirmedCallback func(ctx context.Context, confirmations []BlockInfo)
-	transactionHash   string
-	blockHash         string        // can be notified of changes to this for receipts
-	blockNumber       uint64        // known at creation time for event logs
-	transactionIndex  uint64        // known at creation time for event logs
-	logIndex          uint64        // events only
-	listenerID        *fftypes.UUID // events only
+	pType              pendingType
+	added              time.Time
+	confirmations      []*BlockInfo
+	lastReceiptCheck   time.Time
+	lastProgress       time.Time    // transactions only - when the receipt was downloaded, or a confirmation was last added
+	required           *Requirement // transactions only - overrides the default requirement of the manager
+	milestones         []int        // transactions only - confirmation depths to call the milestone callback at
+	nextMilestone      int          // transactions only - index of the next milestone to be reached
+	dispatched         bool         // transactions only - confirmed, but still tracked for later milestones
+	settleStart        time.Time    // when the requirement was met, if waiting for the settle delay
+	receiptCallback    func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse)
+	confirmedCallback  func(ctx context.Context, confirmations []BlockInfo)
+	milestoneCallback  func(ctx context.Context, confirmations []BlockInfo)
+	rolledBackCallback func(ctx context.Context, removedBlock BlockInfo)
+	transactionHash    string
+	blockHash          string        // can be notified of changes to this for receipts
+	blockNumber        uint64        // known at creation time for event logs
+	transactionIndex   uint64        // known at creation time for event logs
+	logIndex           uint64        // events only
+	listenerID         *fftypes.UUID // events only
 }
 
 func pendingKeyForTX(txHash string) string {
@@ -174,14 +225,15 @@ func (pi *pendingItem) copyConfirmations() []BlockInfo {
 
 func (n *Notification) eventPendingItem() *pendingItem {
 	return &pendingItem{
-		pType:             pendingTypeEvent,
-		listenerID:        n.Event.ID.ListenerID,
-		blockNumber:       n.Event.ID.BlockNumber.Uint64(),
-		blockHash:         n.Event.ID.BlockHash,
-		transactionHash:   n.Event.ID.TransactionHash,
-		transactionIndex:  n.Event.ID.TransactionIndex.Uint64(),
-		logIndex:          n.Event.ID.LogIndex.Uint64(),
-		confirmedCallback: n.Event.Confirmed,
+		pType:              pendingTypeEvent,
+		listenerID:         n.Event.ID.ListenerID,
+		blockNumber:        n.Event.ID.BlockNumber.Uint64(),
+		blockHash:          n.Event.ID.BlockHash,
+		transactionHash:    n.Event.ID.TransactionHash,
+		transactionIndex:   n.Event.ID.TransactionIndex.Uint64(),
+		logIndex:           n.Event.ID.LogIndex.Uint64(),
+		confirmedCallback:  n.Event.Confirmed,
+		rolledBackCallback: n.Event.RolledBack,
 	}
 }
 
@@ -189,9 +241,13 @@ func (n *Notification) transactionPendingItem() *pendingItem {
 	return &pendingItem{
 		pType:             pendingTypeTransaction,
 		lastReceiptCheck:  time.Now(),
+		lastProgress:      time.Now(),
 		transactionHash:   n.Transaction.TransactionHash,
 		receiptCallback:   n.Transaction.Receipt,
 		confirmedCallback: n.Transaction.Confirmed,
+		required:          n.Transaction.Required,
+		milestones:        n.Transaction.Milestones,
+		milestoneCallback: n.Transaction.Milestone,
 	}
 }
 
@@ -270,6 +326,26 @@ func (bcm *blockConfirmationManager) CheckInFlight(listenerID *fftypes.UUID) boo
 	return false
 }
 
+// TransactionProgress returns the confirmations so far for a transaction, or nil if it is not being tracked
+func (bcm *blockConfirmationManager) TransactionProgress(txHash string) *TransactionProgress {
+	bcm.pendingMux.Lock()
+	defer bcm.pendingMux.Unlock()
+	pending, ok := bcm.pending[pendingKeyForTX(txHash)]
+	if !ok {
+		return nil
+	}
+	return &TransactionProgress{
+		BlockNumber:   fftypes.FFuint64(pending.blockNumber),
+		BlockHash:     pending.blockHash,
+		Confirmations: pending.copyConfirmations(),
+	}
+}
+
+// HighestBlockSeen returns the highest block number received from the block listener, or zero if no blocks have been received
+func (bcm *blockConfirmationManager) HighestBlockSeen() uint64 {
+	return atomic.LoadUint64(&bcm.highestBlockSeen)
+}
+
 func (bcm *blockConfirmationManager) getBlockByHash(blockHash string) (*BlockInfo, error) {
 	res, reason, err := bcm.connector.BlockInfoByHash(bcm.ctx, &ffcapi.BlockInfoByHashRequest{
 		BlockHash: blockHash,
@@ -316,7 +392,25 @@ func (bcm *blockConfirmationManager) confirmationsListener() {
 	notifications := make([]*Notification, 0)
 	blockHashes := make([]string, 0)
 	for {
+		// Optionally wake up periodically to check receipts, even if there are no new blocks
+		var pollTimer <-chan time.Time
+		if bcm.receiptPollInterval > 0 {
+			pollTimer = time.After(bcm.receiptPollInterval)
+		}
+		// Also wake up to re-check receipts independently of the block stream, in case we missed a block
+		var confirmationTimer <-chan time.Time
+		if bcm.confirmationTimeout > 0 {
+			confirmationTimer = time.After(bcm.confirmationTimeout)
+		}
+		// And to dispatch any items that have finished settling
+		var settleTimer <-chan time.Time
+		if nextSettle, settling := bcm.nextSettle(); settling {
+			settleTimer = time.After(nextSettle)
+		}
 		select {
+		case <-pollTimer:
+		case <-confirmationTimer:
+		case <-settleTimer:
 		case bhe := <-bcm.newBlockHashes:
 			if bhe.GapPotential {
 				bcm.blockListenerStale = true
@@ -364,19 +458,76 @@ func (bcm *blockConfirmationManager) confirmationsListener() {
 
 		// Mark receipts stale after duration
 		bcm.staleReceiptCheck()
+		bcm.confirmationTimeoutCheck()
 
 		// Perform any receipt checks required, due to new notifications, previously failed
 		// receipt checks, or processing block headers
-		for pendingKey := range bcm.staleReceipts {
-			if pending, ok := bcm.pending[pendingKey]; ok {
-				bcm.checkReceipt(pending, blocks)
-			}
-		}
+		bcm.checkStaleReceipts(blocks)
+
+		// Dispatch any items whose settle delay has passed, if they survive a final re-check
+		bcm.settleCheck(blocks)
 
 	}
 
 }
 
+// checkStaleReceipts queries the receipts for stale transactions, up to the configured maximum
+// per cycle. Any left over remain stale, and are checked on subsequent cycles.
+// Receipts are queried in batches, for connectors that support batched receipt queries.
+func (bcm *blockConfirmationManager) checkStaleReceipts(blocks *blockState) {
+	toCheck := make([]*pendingItem, 0, len(bcm.staleReceipts))
+	for pendingKey := range bcm.staleReceipts {
+		if bcm.maxReceiptChecks > 0 && len(toCheck) >= bcm.maxReceiptChecks {
+			log.L(bcm.ctx).Debugf("Deferring %d receipt checks to next cycle", len(bcm.staleReceipts)-len(toCheck))
+			break
+		}
+		if pending, ok := bcm.pending[pendingKey]; ok {
+			toCheck = append(toCheck, pending)
+		}
+	}
+	batchSize := bcm.receiptBatchSize
+	if batchSize <= 0 {
+		batchSize = len(toCheck)
+	}
+	for len(toCheck) > 0 {
+		if batchSize > len(toCheck) {
+			batchSize = len(toCheck)
+		}
+		bcm.checkReceiptBatch(toCheck[0:batchSize], blocks)
+		toCheck = toCheck[batchSize:]
+	}
+}
+
+func (bcm *blockConfirmationManager) checkReceiptBatch(batch []*pendingItem, blocks *blockState) {
+	req := &ffcapi.TransactionReceiptsRequest{
+		TransactionHashes: make([]string, len(batch)),
+	}
+	for i, pending := range batch {
+		req.TransactionHashes[i] = pending.transactionHash
+	}
+	res, _, err := ffcapi.TransactionReceipts(bcm.ctx, bcm.connector, req)
+	if err == nil && len(res.Results) != len(batch) {
+		err = i18n.NewError(bcm.ctx, tmmsgs.MsgReceiptBatchMismatch, len(res.Results), len(batch))
+	}
+	if err != nil {
+		// All items in the batch remain stale, to be checked again on the next cycle
+		log.L(bcm.ctx).Debugf("Failed to query batch of %d receipts: %s", len(batch), err)
+		return
+	}
+	for i, pending := range batch {
+		result := res.Results[i]
+		if result == nil || (result.Error == "" && result.Receipt == nil) {
+			// Treat a missing result as not found, and continue to check for it
+			result = &ffcapi.TransactionReceiptResult{ErrorReason: ffcapi.ErrorReasonNotFound, Error: "not found"}
+		}
+		var resultErr error
+		if result.Error != "" {
+			resultErr = errors.New(result.Error)
+		}
+		bcm.processReceipt(pending, result.Receipt, result.ErrorReason, resultErr, blocks)
+	}
+}
+
 func (bcm *blockConfirmationManager) staleReceiptCheck() {
 	now := time.Now()
 	for _, pending := range bcm.pending {
@@ -388,6 +539,25 @@ func (bcm *blockConfirmationManager) staleReceiptCheck() {
 	}
 }
 
+// confirmationTimeoutCheck marks receipts stale for any transaction that has made no progress towards
+// confirmation within the confirmation timeout. If the block stream missed the block containing the
+// transaction, or one of its confirmations, querying the receipt directly and walking the chain recovers it.
+func (bcm *blockConfirmationManager) confirmationTimeoutCheck() {
+	if bcm.confirmationTimeout <= 0 {
+		return
+	}
+	now := time.Now()
+	bcm.pendingMux.Lock()
+	defer bcm.pendingMux.Unlock()
+	for pendingKey, pending := range bcm.pending {
+		if pending.pType == pendingTypeTransaction && now.Sub(pending.lastProgress) > bcm.confirmationTimeout {
+			log.L(bcm.ctx).Infof("No confirmation progress for %s within %s - re-checking receipt", pendingKey, bcm.confirmationTimeout)
+			bcm.staleReceipts[pendingKey] = true
+			pending.lastProgress = now
+		}
+	}
+}
+
 func (bcm *blockConfirmationManager) processNotifications(notifications []*Notification, blocks *blockState) error {
 
 	for _, n := range notifications {
@@ -419,7 +589,10 @@ func (bcm *blockConfirmationManager) checkReceipt(pending *pendingItem, blocks *
 	res, reason, err := bcm.connector.TransactionReceipt(bcm.ctx, &ffcapi.TransactionReceiptRequest{
 		TransactionHash: pending.transactionHash,
 	})
+	bcm.processReceipt(pending, res, reason, err, blocks)
+}
 
+func (bcm *blockConfirmationManager) processReceipt(pending *pendingItem, res *ffcapi.TransactionReceiptResponse, reason ffcapi.ErrorReason, err error, blocks *blockState) {
 	if err != nil {
 		if reason == ffcapi.ErrorReasonNotFound {
 			log.L(bcm.ctx).Debugf("Receipt for transaction %s not yet available", pending.transactionHash)
@@ -429,16 +602,19 @@ func (bcm *blockConfirmationManager) checkReceipt(pending *pendingItem, blocks *
 			return
 		}
 	} else {
+		bcm.pendingMux.Lock()
 		pending.blockNumber = res.BlockNumber.Uint64()
 		pending.blockHash = res.BlockHash
+		pending.lastProgress = time.Now()
+		bcm.pendingMux.Unlock()
 		log.L(bcm.ctx).Infof("Receipt for transaction %s downloaded. BlockNumber=%d BlockHash=%s", pending.transactionHash, pending.blockNumber, pending.blockHash)
 		// Notify of the receipt
 		if pending.receiptCallback != nil {
 			pending.receiptCallback(bcm.ctx, res)
 		}
 
-		if bcm.requiredConfirmations == 0 {
-			bcm.dispatchConfirmed(pending)
+		if bcm.isConfirmed(pending) {
+			bcm.settleOrDispatch(pending)
 		} else {
 			// Need to walk the chain for this new receipt
 			if err = bcm.walkChainForItem(pending, blocks); err != nil {
@@ -486,6 +662,7 @@ func (bcm *blockConfirmationManager) removeItem(pendingKey string, stale bool) {
 func (bcm *blockConfirmationManager) processBlockHashes(blockHashes []string) {
 	if len(blockHashes) > 0 {
 		log.L(bcm.ctx).Debugf("New block notifications %v", blockHashes)
+		bcm.updateFinalizedBlock()
 	}
 
 	for _, blockHash := range blockHashes {
@@ -496,12 +673,13 @@ func (bcm *blockConfirmationManager) processBlockHashes(blockHashes []string) {
 			continue
 		}
 
-		// Process the block for confirmations
+		// Check whether this block replaces one we have already seen, before processing it for confirmations
+		bcm.detectReorg(block)
 		bcm.processBlock(block)
 
 		// Update the highest block (used for efficiency in chain walks)
 		if block.BlockNumber.Uint64() > bcm.highestBlockSeen {
-			bcm.highestBlockSeen = block.BlockNumber.Uint64()
+			atomic.StoreUint64(&bcm.highestBlockSeen, block.BlockNumber.Uint64())
 		}
 	}
 }
@@ -522,12 +700,11 @@ func (bcm *blockConfirmationManager) processBlock(block *BlockInfo) {
 			}
 		}
 	}
-	bcm.pendingMux.Unlock()
 
 	// Go through all the events, adding in the confirmations, and popping any out
 	// that have reached their threshold. Then drop the log before logging/processing them.
 	blockNumber := block.BlockNumber.Uint64()
-	var confirmed pendingItems
+	var confirmed, milestones pendingItems
 	for pendingKey, pending := range bcm.pending {
 		if pending.blockHash != "" {
 
@@ -538,8 +715,12 @@ func (bcm *blockConfirmationManager) processBlock(block *BlockInfo) {
 				l.Tracef("Comparing block number=%d parent=%s to %d / %s for %s", blockNumber, block.ParentHash, expectedBlockNumber, expectedParentHash, pendingKey)
 				if block.ParentHash == expectedParentHash && blockNumber == expectedBlockNumber {
 					pending.confirmations = append(pending.confirmations[0:i], block)
+					pending.lastProgress = time.Now()
 					l.Infof("Confirmation %d at block %d / %s item=%s",
 						len(pending.confirmations), block.BlockNumber, block.BlockHash, pending.getKey())
+					if pending.nextMilestone < len(pending.milestones) {
+						milestones = append(milestones, pending)
+					}
 					break
 				}
 				if i < len(pending.confirmations) {
@@ -547,28 +728,304 @@ func (bcm *blockConfirmationManager) processBlock(block *BlockInfo) {
 				}
 				expectedBlockNumber++
 			}
-			if len(pending.confirmations) >= bcm.requiredConfirmations {
+			if !pending.dispatched && bcm.isConfirmed(pending) {
 				confirmed = append(confirmed, pending)
 			}
 
 		}
 	}
+	bcm.pendingMux.Unlock()
+
+	// Milestones are notified before the confirmation of the same block
+	sort.Sort(milestones)
+	for _, p := range milestones {
+		bcm.notifyMilestones(p)
+	}
 
 	// Sort the events to dispatch them in the correct order
 	sort.Sort(confirmed)
 	for _, c := range confirmed {
-		bcm.dispatchConfirmed(c)
+		bcm.settleOrDispatch(c)
+	}
+
+}
+
+// isConfirmed checks whether an item has met its requirement - either the number of confirmations, or the
+// block containing it being at or below the latest finalized block reported by the connector
+func (bcm *blockConfirmationManager) isConfirmed(pending *pendingItem) bool {
+	finalized, requiredConfirmations := bcm.finalized, bcm.requiredConfirmations
+	if pending.required != nil {
+		finalized, requiredConfirmations = pending.required.Finalized, pending.required.Confirmations
+	}
+	if finalized {
+		return pending.blockHash != "" && bcm.finalizedBlock > 0 && pending.blockNumber <= bcm.finalizedBlock
+	}
+	return len(pending.confirmations) >= requiredConfirmations
+}
+
+// updateFinalizedBlock queries the connector for the latest finalized block, when there are items
+// waiting for finality. Failures are logged, and the query is retried on the next block.
+func (bcm *blockConfirmationManager) updateFinalizedBlock() {
+	bcm.pendingMux.Lock()
+	awaitingFinality := false
+	for _, pending := range bcm.pending {
+		if (pending.required == nil && bcm.finalized) || (pending.required != nil && pending.required.Finalized) {
+			awaitingFinality = true
+			break
+		}
+	}
+	bcm.pendingMux.Unlock()
+	if !awaitingFinality {
+		return
+	}
+	finalityAPI, ok := bcm.connector.(ffcapi.FinalityAPI)
+	if !ok {
+		log.L(bcm.ctx).Errorf("Items are waiting for finality, but the connector does not implement FinalityAPI")
+		return
+	}
+	res, _, err := finalityAPI.FinalizedBlock(bcm.ctx, &ffcapi.FinalizedBlockRequest{})
+	if err != nil {
+		log.L(bcm.ctx).Errorf("Failed to query finalized block: %s", err)
+		return
+	}
+	if res.BlockNumber.Uint64() > bcm.finalizedBlock {
+		log.L(bcm.ctx).Debugf("Finalized block %d / %s", res.BlockNumber.Uint64(), res.BlockHash)
+		bcm.finalizedBlock = res.BlockNumber.Uint64()
 	}
+}
 
+// settleOrDispatch is called when an item meets its requirement. Without a settle delay it is dispatched
+// immediately, otherwise its settle window starts (if not already running) and settleCheck dispatches it
+// once the delay has passed
+func (bcm *blockConfirmationManager) settleOrDispatch(pending *pendingItem) {
+	if pending.dispatched {
+		return
+	}
+	if bcm.settleDelay <= 0 {
+		bcm.dispatchConfirmed(pending)
+		return
+	}
+	if pending.settleStart.IsZero() {
+		log.L(bcm.ctx).Infof("Settling for %s with %d confirmations event=%s", bcm.settleDelay, len(pending.confirmations), pending.getKey())
+		pending.settleStart = time.Now()
+	}
+}
+
+// nextSettle returns how long until the next item finishes settling, if any items are settling
+func (bcm *blockConfirmationManager) nextSettle() (next time.Duration, settling bool) {
+	bcm.pendingMux.Lock()
+	defer bcm.pendingMux.Unlock()
+	for _, pending := range bcm.pending {
+		if pending.settleStart.IsZero() {
+			continue
+		}
+		remaining := bcm.settleDelay - time.Since(pending.settleStart)
+		if remaining < 0 {
+			remaining = 0
+		}
+		if !settling || remaining < next {
+			next, settling = remaining, true
+		}
+	}
+	return next, settling
+}
+
+// settleCheck performs a final re-check of each item whose settle delay has passed, dispatching those
+// that are still confirmed on the current chain. Any that are not restart confirmation.
+func (bcm *blockConfirmationManager) settleCheck(blocks *blockState) {
+	now := time.Now()
+	bcm.pendingMux.Lock()
+	var settled pendingItems
+	for _, pending := range bcm.pending {
+		if !pending.settleStart.IsZero() && now.Sub(pending.settleStart) >= bcm.settleDelay {
+			settled = append(settled, pending)
+		}
+	}
+	bcm.pendingMux.Unlock()
+
+	// Sort the items to dispatch them in the correct order
+	sort.Sort(settled)
+	for _, pending := range settled {
+		stillConfirmed, err := bcm.recheckSettled(pending, blocks)
+		if err != nil {
+			// We remain settled, and re-check on the next cycle
+			log.L(bcm.ctx).Debugf("Failed final re-check event=%s: %s", pending.getKey(), err)
+			continue
+		}
+		if stillConfirmed {
+			bcm.dispatchConfirmed(pending)
+			continue
+		}
+		log.L(bcm.ctx).Warnf("Final re-check failed after settling - restarting confirmation event=%s", pending.getKey())
+		pending.settleStart = time.Time{}
+		if err := bcm.walkChainForItem(pending, blocks); err != nil {
+			log.L(bcm.ctx).Debugf("Failed to walk chain event=%s: %s", pending.getKey(), err)
+		}
+	}
+}
+
+// recheckSettled confirms the receipt of a transaction still refers to the same block, and that the
+// block and each of its confirmations are still on the chain
+func (bcm *blockConfirmationManager) recheckSettled(pending *pendingItem, blocks *blockState) (bool, error) {
+	if pending.pType == pendingTypeTransaction {
+		res, reason, err := bcm.connector.TransactionReceipt(bcm.ctx, &ffcapi.TransactionReceiptRequest{
+			TransactionHash: pending.transactionHash,
+		})
+		if err != nil && reason != ffcapi.ErrorReasonNotFound {
+			return false, err
+		}
+		if err != nil || res.BlockHash != pending.blockHash {
+			// The transaction has moved, so the receipt needs to be downloaded again
+			bcm.pendingMux.Lock()
+			pending.blockHash = ""
+			pending.confirmations = pending.confirmations[:0]
+			bcm.staleReceipts[pending.getKey()] = true
+			bcm.pendingMux.Unlock()
+			return false, nil
+		}
+	}
+	if !bcm.isConfirmed(pending) {
+		return false, nil
+	}
+	expectedParentHash := pending.blockHash
+	for _, confirmation := range pending.copyConfirmations() {
+		block, err := blocks.getByNumber(confirmation.BlockNumber.Uint64(), expectedParentHash)
+		if err != nil {
+			return false, err
+		}
+		if block == nil || block.BlockHash != confirmation.BlockHash || block.ParentHash != expectedParentHash {
+			return false, nil
+		}
+		expectedParentHash = block.BlockHash
+	}
+	return true, nil
+}
+
+// restartSettling restarts confirmation of any settling items affected by a re-org from the fork point,
+// dropping the confirmations that are no longer on the chain
+func (bcm *blockConfirmationManager) restartSettling(forkPoint uint64) {
+	bcm.pendingMux.Lock()
+	defer bcm.pendingMux.Unlock()
+	for pendingKey, pending := range bcm.pending {
+		if pending.settleStart.IsZero() || pending.blockNumber+uint64(len(pending.confirmations)) < forkPoint {
+			continue
+		}
+		log.L(bcm.ctx).Warnf("Re-org at block %d while settling - restarting confirmation event=%s", forkPoint, pendingKey)
+		pending.settleStart = time.Time{}
+		if pending.blockNumber >= forkPoint {
+			pending.confirmations = pending.confirmations[:0]
+			if pending.pType == pendingTypeTransaction {
+				bcm.staleReceipts[pendingKey] = true
+			}
+			continue
+		}
+		pending.confirmations = pending.confirmations[0 : forkPoint-pending.blockNumber-1]
+	}
 }
 
 // dispatchConfirmed drive the event stream for any events that are confirmed, and prunes the state
 func (bcm *blockConfirmationManager) dispatchConfirmed(item *pendingItem) {
 	pendingKey := item.getKey()
-	bcm.removeItem(pendingKey, false)
+	// A transaction with milestones beyond its requirement stays tracked until the last of them is reached
+	bcm.pendingMux.Lock()
+	item.dispatched = item.nextMilestone < len(item.milestones)
+	item.settleStart = time.Time{}
+	bcm.pendingMux.Unlock()
+	if !item.dispatched {
+		bcm.removeItem(pendingKey, false)
+	}
 
 	log.L(bcm.ctx).Infof("Confirmed with %d confirmations event=%s", len(item.confirmations), pendingKey)
 	item.confirmedCallback(bcm.ctx, item.copyConfirmations() /* a safe copy outside of our cache */)
+
+	// Keep track of the item, in case the block it was confirmed in is re-orged out later
+	if item.rolledBackCallback != nil && bcm.reorgDetectionDepth > 0 {
+		bcm.dispatched = append(bcm.dispatched, item)
+	}
+}
+
+// notifyMilestones calls the milestone callback of a transaction for each milestone its confirmations have newly
+// reached. A milestone is only notified once, even if a re-org later removes confirmations below its depth.
+func (bcm *blockConfirmationManager) notifyMilestones(pending *pendingItem) {
+	bcm.pendingMux.Lock()
+	var reached [][]BlockInfo
+	for pending.nextMilestone < len(pending.milestones) && len(pending.confirmations) >= pending.milestones[pending.nextMilestone] {
+		reached = append(reached, pending.copyConfirmations()[0:pending.milestones[pending.nextMilestone]])
+		pending.nextMilestone++
+	}
+	complete := pending.dispatched && pending.nextMilestone >= len(pending.milestones)
+	bcm.pendingMux.Unlock()
+
+	for _, confirmations := range reached {
+		log.L(bcm.ctx).Infof("Milestone of %d confirmations reached event=%s", len(confirmations), pending.getKey())
+		pending.milestoneCallback(bcm.ctx, confirmations)
+	}
+	if complete {
+		bcm.removeItem(pending.getKey(), false)
+	}
+}
+
+// detectReorg compares each new block with the chain previously notified to us, using the
+// parent hash. If the block replaces one we have seen (or its parent does), everything from
+// that block number onwards on the old fork has been re-orged out, and any events we already
+// dispatched from those blocks are rolled back.
+func (bcm *blockConfirmationManager) detectReorg(block *BlockInfo) {
+	blockNumber := block.BlockNumber.Uint64()
+	forkPoint, forked := blockNumber, false
+	if existing, ok := bcm.canonicalBlocks[blockNumber]; ok && existing.BlockHash != block.BlockHash {
+		forked = true
+	}
+	if parent, ok := bcm.canonicalBlocks[blockNumber-1]; blockNumber > 0 && ok && parent.BlockHash != block.ParentHash {
+		forkPoint, forked = blockNumber-1, true
+	}
+	if forked {
+		log.L(bcm.ctx).Warnf("Re-org detected at block %d by block %d / %s (parent=%s)", forkPoint, blockNumber, block.BlockHash, block.ParentHash)
+		for n := range bcm.canonicalBlocks {
+			if n >= forkPoint {
+				delete(bcm.canonicalBlocks, n)
+			}
+		}
+		bcm.rollbackDispatched(forkPoint, block)
+		bcm.restartSettling(forkPoint)
+	}
+	bcm.canonicalBlocks[blockNumber] = block
+
+	// Only retain the configured depth of history
+	for n := range bcm.canonicalBlocks {
+		if n+bcm.reorgDetectionDepth < blockNumber {
+			delete(bcm.canonicalBlocks, n)
+		}
+	}
+	retained := bcm.dispatched[:0]
+	for _, item := range bcm.dispatched {
+		if item.blockNumber+bcm.reorgDetectionDepth >= blockNumber {
+			retained = append(retained, item)
+		}
+	}
+	bcm.dispatched = retained
+}
+
+// rollbackDispatched notifies the rollback of all dispatched events in blocks that are no longer
+// on the chain, newest first
+func (bcm *blockConfirmationManager) rollbackDispatched(forkPoint uint64, newBlock *BlockInfo) {
+	var retained, rolledBack []*pendingItem
+	for _, item := range bcm.dispatched {
+		if item.blockNumber < forkPoint ||
+			(item.blockNumber == newBlock.BlockNumber.Uint64() && item.blockHash == newBlock.BlockHash) {
+			retained = append(retained, item)
+		} else {
+			rolledBack = append(rolledBack, item)
+		}
+	}
+	bcm.dispatched = retained
+	for i := len(rolledBack) - 1; i >= 0; i-- {
+		item := rolledBack[i]
+		log.L(bcm.ctx).Warnf("Rolling back confirmed event=%s after re-org", item.getKey())
+		item.rolledBackCallback(bcm.ctx, BlockInfo{
+			BlockNumber: fftypes.FFuint64(item.blockNumber),
+			BlockHash:   item.blockHash,
+		})
+	}
 }
 
 // walkChain goes through each event and sees whether it's valid,
@@ -650,7 +1107,9 @@ func (bcm *blockConfirmationManager) walkChainForItem(pending *pendingItem, bloc
 
 	blockNumber := pending.blockNumber + 1
 	expectedParentHash := pending.blockHash
+	bcm.pendingMux.Lock()
 	pending.confirmations = pending.confirmations[:0]
+	bcm.pendingMux.Unlock()
 	for {
 		// No point in walking past the highest block we've seen via the notifier
 		if bcm.highestBlockSeen > 0 && blockNumber > bcm.highestBlockSeen {
@@ -670,10 +1129,13 @@ func (bcm *blockConfirmationManager) walkChainForItem(pending *pendingItem, bloc
 			log.L(bcm.ctx).Infof("Block mismatch in confirmations: block=%d expected=%s actual=%s confirmations=%d event=%s", blockNumber, expectedParentHash, candidateParentHash, len(pending.confirmations), pendingKey)
 			return nil
 		}
+		bcm.pendingMux.Lock()
 		pending.confirmations = append(pending.confirmations, block)
-		if len(pending.confirmations) >= bcm.requiredConfirmations {
+		bcm.pendingMux.Unlock()
+		bcm.notifyMilestones(pending)
+		if !pending.dispatched && bcm.isConfirmed(pending) {
 			// Ready for dispatch
-			bcm.dispatchConfirmed(pending)
+			bcm.settleOrDispatch(pending)
 			return nil
 		}
 		blockNumber++
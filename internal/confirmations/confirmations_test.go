@@ -41,7 +41,7 @@ func newTestBlockConfirmationManager(t *testing.T, enabled bool) (*blockConfirma
 func newTestBlockConfirmationManagerCustomConfig(t *testing.T) (*blockConfirmationManager, *ffcapimocks.API) {
 	logrus.SetLevel(logrus.DebugLevel)
 	mca := &ffcapimocks.API{}
-	bcm := NewBlockConfirmationManager(context.Background(), mca, "ut")
+	bcm := NewBlockConfirmationManager(context.Background(), mca, "ut", config.GetInt(tmconfig.ConfirmationsRequired))
 	return bcm.(*blockConfirmationManager), mca
 }
 
@@ -1045,6 +1045,159 @@ func TestStaleReceiptCheck(t *testing.T) {
 
 }
 
+func TestCheckStaleReceiptsMaxPerCycle(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	bcm.maxReceiptChecks = 2
+
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	for i := 0; i < 3; i++ {
+		pending := &pendingItem{
+			pType:           pendingTypeTransaction,
+			transactionHash: fftypes.NewRandB32().String(),
+		}
+		bcm.pending[pending.getKey()] = pending
+		bcm.staleReceipts[pending.getKey()] = true
+	}
+	bcm.checkStaleReceipts(bcm.newBlockState())
+
+	mca.AssertNumberOfCalls(t, "TransactionReceipt", 2)
+	assert.Len(t, bcm.staleReceipts, 3) // failures remain stale for the next cycle
+
+}
+
+func TestReceiptPollIntervalWithoutBlocks(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsReceiptPollInterval, "1ms")
+	config.Set(tmconfig.ConfirmationsMaxReceiptChecks, 10)
+	config.Set(tmconfig.ConfirmationsStaleReceiptTimeout, "0")
+	bcm, mca := newTestBlockConfirmationManagerCustomConfig(t)
+	assert.Equal(t, 1*time.Millisecond, bcm.receiptPollInterval)
+	assert.Equal(t, 10, bcm.maxReceiptChecks)
+	bcm.blockListenerStale = false
+
+	polled := make(chan struct{})
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).
+		Run(func(args mock.Arguments) {
+			close(polled)
+		}).Once()
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Maybe()
+
+	pending := &pendingItem{
+		pType:           pendingTypeTransaction,
+		transactionHash: fftypes.NewRandB32().String(),
+	}
+	bcm.pending[pending.getKey()] = pending
+
+	bcm.Start()
+	<-polled
+	bcm.Stop()
+
+}
+
+func TestConfirmationTimeoutRecoversMissedBlock(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsRequired, 1)
+	config.Set(tmconfig.ConfirmationsConfirmationTimeout, "1ms")
+	bcm, mca := newTestBlockConfirmationManagerCustomConfig(t)
+	assert.Equal(t, 1*time.Millisecond, bcm.confirmationTimeout)
+
+	txHash := "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+	receiptBlock := &BlockInfo{
+		BlockNumber: 1001,
+		BlockHash:   "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542",
+	}
+	block1002 := &BlockInfo{
+		BlockNumber: 1002,
+		BlockHash:   "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
+		ParentHash:  receiptBlock.BlockHash,
+	}
+
+	// The receipt is not available when the transaction is first notified, and the block that
+	// mines the transaction is never delivered by the block stream
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Once()
+	// The direct receipt check after the confirmation timeout finds it
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptResponse{
+		BlockHash:        receiptBlock.BlockHash,
+		BlockNumber:      fftypes.NewFFBigInt(int64(receiptBlock.BlockNumber)),
+		TransactionIndex: fftypes.NewFFBigInt(0),
+		Success:          true,
+	}, ffcapi.ErrorReason(""), nil)
+	// Walking the chain from the receipt finds the confirmation
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == block1002.BlockNumber.Uint64()
+	})).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{
+			BlockNumber: fftypes.NewFFBigInt(int64(block1002.BlockNumber)),
+			BlockHash:   block1002.BlockHash,
+			ParentHash:  block1002.ParentHash,
+		},
+	}, ffcapi.ErrorReason(""), nil)
+
+	receipt := make(chan *ffcapi.TransactionReceiptResponse, 1)
+	confirmed := make(chan []BlockInfo, 1)
+	bcm.Start()
+	err := bcm.Notify(&Notification{
+		NotificationType: NewTransaction,
+		Transaction: &TransactionInfo{
+			TransactionHash: txHash,
+			Receipt: func(ctx context.Context, r *ffcapi.TransactionReceiptResponse) {
+				receipt <- r
+			},
+			Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+				confirmed <- confirmations
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	r := <-receipt
+	assert.Equal(t, receiptBlock.BlockHash, r.BlockHash)
+	confirmations := <-confirmed
+	assert.Len(t, confirmations, 1)
+	assert.Equal(t, block1002.BlockHash, confirmations[0].BlockHash)
+
+	bcm.Stop()
+	mca.AssertExpectations(t)
+}
+
+func TestConfirmationTimeoutCheck(t *testing.T) {
+
+	bcm, _ := newTestBlockConfirmationManager(t, false)
+
+	stalled := &pendingItem{
+		pType:           pendingTypeTransaction,
+		lastProgress:    time.Now().Add(-1 * time.Hour),
+		transactionHash: fftypes.NewRandB32().String(),
+	}
+	bcm.pending[stalled.getKey()] = stalled
+	progressing := &pendingItem{
+		pType:           pendingTypeTransaction,
+		lastProgress:    time.Now(),
+		transactionHash: fftypes.NewRandB32().String(),
+	}
+	bcm.pending[progressing.getKey()] = progressing
+
+	// Disabled
+	bcm.confirmationTimeout = 0
+	bcm.confirmationTimeoutCheck()
+	assert.Empty(t, bcm.staleReceipts)
+
+	bcm.confirmationTimeout = 1 * time.Minute
+	bcm.confirmationTimeoutCheck()
+	assert.True(t, bcm.staleReceipts[stalled.getKey()])
+	assert.False(t, bcm.staleReceipts[progressing.getKey()])
+
+	// The timeout restarts, so the receipt is not re-checked again until it next expires
+	delete(bcm.staleReceipts, stalled.getKey())
+	bcm.confirmationTimeoutCheck()
+	assert.Empty(t, bcm.staleReceipts)
+
+}
+
 func TestBlockState(t *testing.T) {
 
 	bcm, mca := newTestBlockConfirmationManager(t, false)
@@ -1082,3 +1235,803 @@ func TestBlockState(t *testing.T) {
 
 	mca.AssertExpectations(t)
 }
+
+func TestBlockConfirmationManagerDifferentDepthsSameChain(t *testing.T) {
+	tmconfig.Reset()
+	mca := &ffcapimocks.API{}
+	bcm1 := NewBlockConfirmationManager(context.Background(), mca, "ut1", 1)
+	bcm3 := NewBlockConfirmationManager(context.Background(), mca, "ut3", 3)
+
+	blockHash := func(n uint64) string { return fmt.Sprintf("0x%.64d", n) }
+	chain := []*BlockInfo{}
+	for n := uint64(1002); n <= 1005; n++ {
+		block := &BlockInfo{
+			BlockNumber: fftypes.FFuint64(n),
+			BlockHash:   blockHash(n),
+			ParentHash:  blockHash(n - 1),
+		}
+		chain = append(chain, block)
+		mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+			return r.BlockNumber.Uint64() == uint64(block.BlockNumber)
+		})).Return(&ffcapi.BlockInfoByNumberResponse{
+			BlockInfo: ffcapi.BlockInfo{
+				BlockNumber: fftypes.NewFFBigInt(int64(block.BlockNumber)),
+				BlockHash:   block.BlockHash,
+				ParentHash:  block.ParentHash,
+			},
+		}, ffcapi.ErrorReason(""), nil).Maybe()
+	}
+
+	notifyEvent := func(bcm Manager, confirmed chan []BlockInfo) {
+		err := bcm.Notify(&Notification{
+			NotificationType: NewEventLog,
+			Event: &EventInfo{
+				ID: &ffcapi.EventID{
+					ListenerID:       fftypes.NewUUID(),
+					TransactionHash:  "0x531e219d98d81dc9f9a14811ac537479f5d77a74bdba47629bfbebe2d7663ce7",
+					BlockHash:        blockHash(1001),
+					BlockNumber:      1001,
+					TransactionIndex: 5,
+					LogIndex:         10,
+				},
+				Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+					confirmed <- confirmations
+				},
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	confirmed1 := make(chan []BlockInfo, 1)
+	confirmed3 := make(chan []BlockInfo, 1)
+	notifyEvent(bcm1, confirmed1)
+	notifyEvent(bcm3, confirmed3)
+	bcm1.Start()
+	bcm3.Start()
+
+	assert.Equal(t, []BlockInfo{*chain[0]}, <-confirmed1)
+	assert.Equal(t, []BlockInfo{*chain[0], *chain[1], *chain[2]}, <-confirmed3)
+
+	bcm1.Stop()
+	bcm3.Stop()
+}
+
+func TestBlockConfirmationManagerReorgRollsBackConfirmedEvent(t *testing.T) {
+	tmconfig.Reset()
+	mca := &ffcapimocks.API{}
+	bcm := NewBlockConfirmationManager(context.Background(), mca, "ut", 1).(*blockConfirmationManager)
+
+	blockHash := func(n uint64, fork string) string { return fmt.Sprintf("0x%s%.63d", fork, n) }
+	newBlock := func(n uint64, fork, parentFork string) *BlockInfo {
+		block := &BlockInfo{
+			BlockNumber: fftypes.FFuint64(n),
+			BlockHash:   blockHash(n, fork),
+			ParentHash:  blockHash(n-1, parentFork),
+		}
+		mca.On("BlockInfoByHash", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByHashRequest) bool {
+			return r.BlockHash == block.BlockHash
+		})).Return(&ffcapi.BlockInfoByHashResponse{
+			BlockInfo: ffcapi.BlockInfo{
+				BlockNumber: fftypes.NewFFBigInt(int64(block.BlockNumber)),
+				BlockHash:   block.BlockHash,
+				ParentHash:  block.ParentHash,
+			},
+		}, ffcapi.ErrorReason(""), nil)
+		return block
+	}
+
+	confirmed := make(chan string, 2)
+	rolledBack := make(chan BlockInfo, 2)
+	addEvent := func(block *BlockInfo) {
+		n := &Notification{
+			NotificationType: NewEventLog,
+			Event: &EventInfo{
+				ID: &ffcapi.EventID{
+					ListenerID:      fftypes.NewUUID(),
+					TransactionHash: "0x531e219d98d81dc9f9a14811ac537479f5d77a74bdba47629bfbebe2d7663ce7",
+					BlockHash:       block.BlockHash,
+					BlockNumber:     block.BlockNumber,
+				},
+				Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+					confirmed <- block.BlockHash
+				},
+				RolledBack: func(ctx context.Context, removedBlock BlockInfo) {
+					rolledBack <- removedBlock
+				},
+			},
+		}
+		bcm.addOrReplaceItem(n.eventPendingItem())
+	}
+
+	// Two events are confirmed on the "a" fork
+	block1000a := newBlock(1000, "a", "a")
+	block1001a := newBlock(1001, "a", "a")
+	block1002a := newBlock(1002, "a", "a")
+	addEvent(block1000a)
+	addEvent(block1001a)
+	bcm.processBlockHashes([]string{block1000a.BlockHash, block1001a.BlockHash, block1002a.BlockHash})
+	assert.Equal(t, block1000a.BlockHash, <-confirmed)
+	assert.Equal(t, block1001a.BlockHash, <-confirmed)
+	assert.Len(t, bcm.dispatched, 2)
+	assert.Equal(t, uint64(1002), bcm.HighestBlockSeen())
+
+	// Then a fork replaces 1001 onwards, which we detect from the parent hash of the new 1002
+	block1002b := newBlock(1002, "b", "b")
+	bcm.processBlockHashes([]string{block1002b.BlockHash})
+	removed := <-rolledBack
+	assert.Equal(t, block1001a.BlockNumber, removed.BlockNumber)
+	assert.Equal(t, block1001a.BlockHash, removed.BlockHash)
+	assert.Len(t, bcm.dispatched, 1)
+	assert.Empty(t, rolledBack)
+
+	// The replaced block itself arriving later does not roll anything else back
+	block1001b := newBlock(1001, "b", "a")
+	bcm.processBlockHashes([]string{block1001b.BlockHash})
+	assert.Empty(t, rolledBack)
+	assert.Len(t, bcm.dispatched, 1)
+
+	// A replacement of the 1000 block is detected from the block hash differing
+	block1000c := newBlock(1000, "c", "a")
+	bcm.processBlockHashes([]string{block1000c.BlockHash})
+	removed = <-rolledBack
+	assert.Equal(t, block1000a.BlockHash, removed.BlockHash)
+	assert.Empty(t, bcm.dispatched)
+
+	mca.AssertExpectations(t)
+}
+
+func TestBlockConfirmationManagerReorgOutsideDetectionDepth(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsReorgDetectionDepth, 2)
+	bcm, _ := newTestBlockConfirmationManagerCustomConfig(t)
+
+	blockHash := func(n uint64, fork string) string { return fmt.Sprintf("0x%s%.63d", fork, n) }
+	rolledBack := false
+	bcm.dispatched = []*pendingItem{{
+		pType:       pendingTypeEvent,
+		blockNumber: 1000,
+		blockHash:   blockHash(1000, "a"),
+		rolledBackCallback: func(ctx context.Context, removedBlock BlockInfo) {
+			rolledBack = true
+		},
+	}}
+	for n := uint64(1000); n <= 1003; n++ {
+		bcm.detectReorg(&BlockInfo{BlockNumber: fftypes.FFuint64(n), BlockHash: blockHash(n, "a"), ParentHash: blockHash(n-1, "a")})
+	}
+	assert.Len(t, bcm.canonicalBlocks, 3)
+	assert.Empty(t, bcm.dispatched)
+
+	// The re-org is deeper than we track, so cannot be detected
+	bcm.detectReorg(&BlockInfo{BlockNumber: 1000, BlockHash: blockHash(1000, "b"), ParentHash: blockHash(999, "a")})
+	assert.False(t, rolledBack)
+}
+
+type batchingConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.BatchReceiptAPI
+}
+
+func TestCheckStaleReceiptsBatched(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	bcm.requiredConfirmations = 0
+	bcm.receiptBatchSize = 2
+	mbr := &ffcapimocks.BatchReceiptAPI{}
+	bcm.connector = &batchingConnector{API: mca, BatchReceiptAPI: mbr}
+
+	receipts := 0
+	for i := 0; i < 5; i++ {
+		pending := &pendingItem{
+			pType:           pendingTypeTransaction,
+			transactionHash: fftypes.NewRandB32().String(),
+			receiptCallback: func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse) {
+				receipts++
+			},
+			confirmedCallback: func(ctx context.Context, confirmations []BlockInfo) {},
+		}
+		bcm.pending[pending.getKey()] = pending
+		bcm.staleReceipts[pending.getKey()] = true
+	}
+
+	mbr.On("TransactionReceipts", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionReceiptsRequest) bool {
+		return len(req.TransactionHashes) == 2
+	})).Return(&ffcapi.TransactionReceiptsResponse{
+		Results: []*ffcapi.TransactionReceiptResult{
+			{Receipt: &ffcapi.TransactionReceiptResponse{BlockNumber: fftypes.NewFFBigInt(1001), BlockHash: "0x1"}},
+			{ErrorReason: ffcapi.ErrorReasonNotFound, Error: "not found"},
+		},
+	}, ffcapi.ErrorReason(""), nil).Twice()
+	mbr.On("TransactionReceipts", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionReceiptsRequest) bool {
+		return len(req.TransactionHashes) == 1
+	})).Return(&ffcapi.TransactionReceiptsResponse{
+		Results: []*ffcapi.TransactionReceiptResult{
+			{Error: "pop"},
+		},
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	bcm.checkStaleReceipts(bcm.newBlockState())
+
+	// Three batch calls replace five individual calls
+	mbr.AssertNumberOfCalls(t, "TransactionReceipts", 3)
+	mca.AssertNotCalled(t, "TransactionReceipt", mock.Anything, mock.Anything)
+	assert.Equal(t, 2, receipts)
+	assert.Len(t, bcm.staleReceipts, 1) // only the failed query remains stale
+
+	mbr.AssertExpectations(t)
+}
+
+func TestCheckStaleReceiptsBatchFailed(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	mbr := &ffcapimocks.BatchReceiptAPI{}
+	bcm.connector = &batchingConnector{API: mca, BatchReceiptAPI: mbr}
+
+	for i := 0; i < 3; i++ {
+		pending := &pendingItem{
+			pType:           pendingTypeTransaction,
+			transactionHash: fftypes.NewRandB32().String(),
+		}
+		bcm.pending[pending.getKey()] = pending
+		bcm.staleReceipts[pending.getKey()] = true
+	}
+
+	mbr.On("TransactionReceipts", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mbr.On("TransactionReceipts", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptsResponse{
+		Results: []*ffcapi.TransactionReceiptResult{nil},
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	bcm.checkStaleReceipts(bcm.newBlockState())
+	assert.Len(t, bcm.staleReceipts, 3)
+
+	bcm.checkStaleReceipts(bcm.newBlockState())
+	assert.Len(t, bcm.staleReceipts, 3) // mismatched result count
+
+	mbr.AssertExpectations(t)
+}
+
+func TestTransactionProgressIncrementsAsBlocksArrive(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+
+	txHash := "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+	receiptBlock := &BlockInfo{
+		BlockNumber: 1001,
+		BlockHash:   "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542",
+	}
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptResponse{
+		BlockHash:        receiptBlock.BlockHash,
+		BlockNumber:      fftypes.NewFFBigInt(int64(receiptBlock.BlockNumber)),
+		TransactionIndex: fftypes.NewFFBigInt(0),
+		Success:          true,
+	}, ffcapi.ErrorReason(""), nil)
+
+	confirmed := false
+	pending := &pendingItem{
+		pType:           pendingTypeTransaction,
+		transactionHash: txHash,
+		confirmedCallback: func(ctx context.Context, confirmations []BlockInfo) {
+			confirmed = true
+		},
+	}
+
+	// Not tracked at all
+	assert.Nil(t, bcm.TransactionProgress(txHash))
+
+	// Tracked, but not yet mined
+	bcm.addOrReplaceItem(pending)
+	progress := bcm.TransactionProgress(txHash)
+	assert.Empty(t, progress.BlockHash)
+	assert.Zero(t, progress.BlockNumber)
+	assert.Empty(t, progress.Confirmations)
+
+	// Receipt arrives - no chain walk needed as we have not seen any later blocks
+	bcm.highestBlockSeen = receiptBlock.BlockNumber.Uint64()
+	bcm.checkReceipt(pending, bcm.newBlockState())
+	progress = bcm.TransactionProgress(txHash)
+	assert.Equal(t, receiptBlock.BlockHash, progress.BlockHash)
+	assert.Equal(t, receiptBlock.BlockNumber, progress.BlockNumber)
+	assert.Empty(t, progress.Confirmations)
+
+	// Each block that arrives adds a confirmation
+	parent := receiptBlock
+	for i := 1; i < bcm.requiredConfirmations; i++ {
+		block := &BlockInfo{
+			BlockNumber: parent.BlockNumber + 1,
+			BlockHash:   fftypes.NewRandB32().String(),
+			ParentHash:  parent.BlockHash,
+		}
+		bcm.processBlock(block)
+		progress = bcm.TransactionProgress(txHash)
+		assert.Len(t, progress.Confirmations, i)
+		assert.Equal(t, block.BlockHash, progress.Confirmations[i-1].BlockHash)
+		parent = block
+	}
+
+	// The final block confirms the transaction, and it is no longer tracked
+	bcm.processBlock(&BlockInfo{
+		BlockNumber: parent.BlockNumber + 1,
+		BlockHash:   fftypes.NewRandB32().String(),
+		ParentHash:  parent.BlockHash,
+	})
+	assert.True(t, confirmed)
+	assert.Nil(t, bcm.TransactionProgress(txHash))
+
+	mca.AssertExpectations(t)
+}
+
+type finalityConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.FinalityAPI
+}
+
+func newTestFinalityConfirmationManager(t *testing.T) (*blockConfirmationManager, *ffcapimocks.API, *ffcapimocks.FinalityAPI) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsNotificationQueueLength, 1)
+	fc := &finalityConnector{API: &ffcapimocks.API{}, FinalityAPI: &ffcapimocks.FinalityAPI{}}
+	bcm := NewBlockConfirmationManagerForRequirement(context.Background(), fc, "ut", &Requirement{Finalized: true})
+	return bcm.(*blockConfirmationManager), fc.API, fc.FinalityAPI
+}
+
+func mockNextBlock(mca *ffcapimocks.API, parent *BlockInfo) *BlockInfo {
+	block := &BlockInfo{
+		BlockNumber: parent.BlockNumber + 1,
+		BlockHash:   fftypes.NewRandB32().String(),
+		ParentHash:  parent.BlockHash,
+	}
+	mca.On("BlockInfoByHash", mock.Anything, &ffcapi.BlockInfoByHashRequest{BlockHash: block.BlockHash}).Return(&ffcapi.BlockInfoByHashResponse{
+		BlockInfo: ffcapi.BlockInfo{
+			BlockNumber: fftypes.NewFFBigInt(int64(block.BlockNumber)),
+			BlockHash:   block.BlockHash,
+			ParentHash:  block.ParentHash,
+		},
+	}, ffcapi.ErrorReason(""), nil).Once()
+	return block
+}
+
+func TestFinalizedRequirementWaitsForFinalizedBlock(t *testing.T) {
+
+	bcm, mca, mfa := newTestFinalityConfirmationManager(t)
+
+	receiptBlock := &BlockInfo{BlockNumber: 1001, BlockHash: fftypes.NewRandB32().String()}
+	var confirmations []BlockInfo
+	pending := &pendingItem{
+		pType:           pendingTypeTransaction,
+		transactionHash: "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
+		blockNumber:     receiptBlock.BlockNumber.Uint64(),
+		blockHash:       receiptBlock.BlockHash,
+		confirmedCallback: func(ctx context.Context, c []BlockInfo) {
+			confirmations = c
+		},
+	}
+	bcm.addOrReplaceItem(pending)
+
+	// Blocks are built on the block, but it is not confirmed until it has been finalized
+	block1 := mockNextBlock(mca, receiptBlock)
+	mfa.On("FinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.FinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1000),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	bcm.processBlockHashes([]string{block1.BlockHash})
+	assert.Nil(t, confirmations)
+	assert.Len(t, bcm.TransactionProgress(pending.transactionHash).Confirmations, 1)
+
+	block2 := mockNextBlock(mca, block1)
+	mfa.On("FinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.FinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1001),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	bcm.processBlockHashes([]string{block2.BlockHash})
+	assert.Len(t, confirmations, 2)
+	assert.Equal(t, uint64(1001), bcm.finalizedBlock)
+	assert.Nil(t, bcm.TransactionProgress(pending.transactionHash))
+
+	// Nothing is waiting for finality, so the connector is not queried
+	bcm.processBlockHashes([]string{mockNextBlock(mca, block2).BlockHash})
+
+	mca.AssertExpectations(t)
+	mfa.AssertExpectations(t)
+}
+
+func TestFinalizedRequirementReceiptAlreadyFinalized(t *testing.T) {
+
+	bcm, mca, _ := newTestFinalityConfirmationManager(t)
+	bcm.finalizedBlock = 2000
+
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptResponse{
+		BlockHash:        fftypes.NewRandB32().String(),
+		BlockNumber:      fftypes.NewFFBigInt(1001),
+		TransactionIndex: fftypes.NewFFBigInt(0),
+		Success:          true,
+	}, ffcapi.ErrorReason(""), nil)
+
+	confirmed := false
+	pending := &pendingItem{
+		pType:           pendingTypeTransaction,
+		transactionHash: "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
+		confirmedCallback: func(ctx context.Context, confirmations []BlockInfo) {
+			confirmed = true
+		},
+	}
+	bcm.addOrReplaceItem(pending)
+	bcm.checkReceipt(pending, bcm.newBlockState())
+	assert.True(t, confirmed)
+
+	mca.AssertExpectations(t)
+}
+
+func TestFinalizedBlockQueryFails(t *testing.T) {
+
+	bcm, _, mfa := newTestFinalityConfirmationManager(t)
+	bcm.addOrReplaceItem(&pendingItem{pType: pendingTypeTransaction, transactionHash: "0x12345"})
+
+	mfa.On("FinalizedBlock", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	bcm.updateFinalizedBlock()
+	assert.Zero(t, bcm.finalizedBlock)
+
+	mfa.AssertExpectations(t)
+}
+
+func TestFinalizedRequirementConnectorNotSupported(t *testing.T) {
+
+	bcm, _ := newTestBlockConfirmationManager(t, false)
+	bcm.addOrReplaceItem(&pendingItem{pType: pendingTypeTransaction, transactionHash: "0x12345", required: &Requirement{Finalized: true}})
+
+	bcm.updateFinalizedBlock()
+	assert.Zero(t, bcm.finalizedBlock)
+}
+
+func TestTransactionRequirementOverridesDefault(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	assert.Equal(t, 3, bcm.requiredConfirmations)
+
+	receiptBlock := &BlockInfo{BlockNumber: 1001, BlockHash: fftypes.NewRandB32().String()}
+	newPending := func(txHash string, required *Requirement, confirmed *bool) *pendingItem {
+		n := &Notification{
+			NotificationType: NewTransaction,
+			Transaction: &TransactionInfo{
+				TransactionHash: txHash,
+				Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+					*confirmed = true
+				},
+				Required: required,
+			},
+		}
+		pending := n.transactionPendingItem()
+		pending.blockNumber = receiptBlock.BlockNumber.Uint64()
+		pending.blockHash = receiptBlock.BlockHash
+		bcm.addOrReplaceItem(pending)
+		return pending
+	}
+	var fastConfirmed, defaultConfirmed bool
+	newPending("0x1111", &Requirement{Confirmations: 1}, &fastConfirmed)
+	newPending("0x2222", nil, &defaultConfirmed)
+
+	bcm.processBlockHashes([]string{mockNextBlock(mca, receiptBlock).BlockHash})
+	assert.True(t, fastConfirmed)
+	assert.False(t, defaultConfirmed)
+	assert.Nil(t, bcm.TransactionProgress("0x1111"))
+	assert.Len(t, bcm.TransactionProgress("0x2222").Confirmations, 1)
+
+	mca.AssertExpectations(t)
+}
+
+func TestSettleDelayReorgDuringSettleRestartsConfirmation(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsRequired, 1)
+	config.Set(tmconfig.ConfirmationsSettleDelay, "1h")
+	bcm, mca := newTestBlockConfirmationManagerCustomConfig(t)
+	assert.Equal(t, 1*time.Hour, bcm.settleDelay)
+
+	blockHash := func(n uint64, fork string) string { return fmt.Sprintf("0x%s%.63d", fork, n) }
+	newBlock := func(n uint64, fork, parentFork string) *BlockInfo {
+		block := &BlockInfo{
+			BlockNumber: fftypes.FFuint64(n),
+			BlockHash:   blockHash(n, fork),
+			ParentHash:  blockHash(n-1, parentFork),
+		}
+		res := ffcapi.BlockInfo{
+			BlockNumber: fftypes.NewFFBigInt(int64(block.BlockNumber)),
+			BlockHash:   block.BlockHash,
+			ParentHash:  block.ParentHash,
+		}
+		mca.On("BlockInfoByHash", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByHashRequest) bool {
+			return r.BlockHash == block.BlockHash
+		})).Return(&ffcapi.BlockInfoByHashResponse{BlockInfo: res}, ffcapi.ErrorReason(""), nil)
+		return block
+	}
+
+	confirmed := make(chan []BlockInfo, 1)
+	n := &Notification{
+		NotificationType: NewEventLog,
+		Event: &EventInfo{
+			ID: &ffcapi.EventID{
+				ListenerID:      fftypes.NewUUID(),
+				TransactionHash: "0x531e219d98d81dc9f9a14811ac537479f5d77a74bdba47629bfbebe2d7663ce7",
+				BlockHash:       blockHash(1000, "a"),
+				BlockNumber:     1000,
+			},
+			Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+				confirmed <- confirmations
+			},
+		},
+	}
+	pending := n.eventPendingItem()
+	bcm.addOrReplaceItem(pending)
+
+	// The confirmation depth is met, but the event settles rather than being confirmed
+	block1000a := newBlock(1000, "a", "a")
+	block1001a := newBlock(1001, "a", "a")
+	bcm.processBlockHashes([]string{block1000a.BlockHash, block1001a.BlockHash})
+	assert.Empty(t, confirmed)
+	assert.False(t, pending.settleStart.IsZero())
+	firstSettleStart := pending.settleStart
+	next, settling := bcm.nextSettle()
+	assert.True(t, settling)
+	assert.Greater(t, next, 59*time.Minute)
+
+	// A re-org replaces the confirmation during the settle window, which restarts it
+	block1001b := newBlock(1001, "b", "a")
+	bcm.processBlockHashes([]string{block1001b.BlockHash})
+	assert.Empty(t, confirmed)
+	assert.Len(t, pending.confirmations, 1)
+	assert.Equal(t, block1001b.BlockHash, pending.confirmations[0].BlockHash)
+	assert.True(t, pending.settleStart.After(firstSettleStart))
+
+	// Nothing is dispatched until the restarted settle window has passed
+	bcm.settleCheck(bcm.newBlockState())
+	assert.Empty(t, confirmed)
+
+	// Once it has, the final re-check finds the new fork and confirms against it
+	pending.settleStart = time.Now().Add(-2 * time.Hour)
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == 1001
+	})).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{
+			BlockNumber: fftypes.NewFFBigInt(1001),
+			BlockHash:   block1001b.BlockHash,
+			ParentHash:  block1001b.ParentHash,
+		},
+	}, ffcapi.ErrorReason(""), nil)
+	next, settling = bcm.nextSettle()
+	assert.True(t, settling)
+	assert.Equal(t, time.Duration(0), next)
+	bcm.settleCheck(bcm.newBlockState())
+	confirmations := <-confirmed
+	assert.Len(t, confirmations, 1)
+	assert.Equal(t, block1001b.BlockHash, confirmations[0].BlockHash)
+	assert.Empty(t, bcm.pending)
+	_, settling = bcm.nextSettle()
+	assert.False(t, settling)
+
+	mca.AssertExpectations(t)
+}
+
+func newTestSettledTransaction(t *testing.T, bcm *blockConfirmationManager, confirmed chan []BlockInfo) *pendingItem {
+	pending := &pendingItem{
+		pType:           pendingTypeTransaction,
+		transactionHash: "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
+		blockNumber:     1000,
+		blockHash:       "0xa000",
+		confirmedCallback: func(ctx context.Context, confirmations []BlockInfo) {
+			confirmed <- confirmations
+		},
+	}
+	bcm.addOrReplaceItem(pending)
+	pending.confirmations = []*BlockInfo{{BlockNumber: 1001, BlockHash: "0xa001", ParentHash: "0xa000"}}
+	pending.settleStart = time.Now().Add(-2 * time.Hour)
+	return pending
+}
+
+func TestSettleDelayReorgFoundByFinalRecheck(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsRequired, 1)
+	config.Set(tmconfig.ConfirmationsSettleDelay, "1h")
+	bcm, mca := newTestBlockConfirmationManagerCustomConfig(t)
+
+	confirmed := make(chan []BlockInfo, 1)
+	pending := newTestSettledTransaction(t, bcm, confirmed)
+
+	// The receipt is unchanged, but the confirmation block was re-orged without us being notified
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptResponse{
+		BlockHash:   "0xa000",
+		BlockNumber: fftypes.NewFFBigInt(1000),
+	}, ffcapi.ErrorReason(""), nil)
+	mca.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{
+			BlockNumber: fftypes.NewFFBigInt(1001),
+			BlockHash:   "0xb001",
+			ParentHash:  "0xa000",
+		},
+	}, ffcapi.ErrorReason(""), nil)
+
+	bcm.settleCheck(bcm.newBlockState())
+	assert.Empty(t, confirmed)
+
+	// Confirmation restarted against the new block, with a new settle window
+	assert.Len(t, pending.confirmations, 1)
+	assert.Equal(t, "0xb001", pending.confirmations[0].BlockHash)
+	assert.WithinDuration(t, time.Now(), pending.settleStart, 1*time.Minute)
+
+	mca.AssertExpectations(t)
+}
+
+func TestSettleDelayReceiptMovedDuringSettle(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsRequired, 1)
+	config.Set(tmconfig.ConfirmationsSettleDelay, "1h")
+	bcm, mca := newTestBlockConfirmationManagerCustomConfig(t)
+
+	confirmed := make(chan []BlockInfo, 1)
+	pending := newTestSettledTransaction(t, bcm, confirmed)
+
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptResponse{
+		BlockHash:   "0xb001",
+		BlockNumber: fftypes.NewFFBigInt(1001),
+	}, ffcapi.ErrorReason(""), nil)
+
+	bcm.settleCheck(bcm.newBlockState())
+	assert.Empty(t, confirmed)
+	assert.Empty(t, pending.blockHash)
+	assert.Empty(t, pending.confirmations)
+	assert.True(t, pending.settleStart.IsZero())
+	assert.True(t, bcm.staleReceipts[pending.getKey()])
+
+	mca.AssertExpectations(t)
+}
+
+func TestSettleDelayFinalRecheckFails(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsRequired, 1)
+	config.Set(tmconfig.ConfirmationsSettleDelay, "1h")
+	bcm, mca := newTestBlockConfirmationManagerCustomConfig(t)
+
+	confirmed := make(chan []BlockInfo, 1)
+	pending := newTestSettledTransaction(t, bcm, confirmed)
+	settleStart := pending.settleStart
+
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	bcm.settleCheck(bcm.newBlockState())
+
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptResponse{
+		BlockHash:   "0xa000",
+		BlockNumber: fftypes.NewFFBigInt(1000),
+	}, ffcapi.ErrorReason(""), nil)
+	mca.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	bcm.settleCheck(bcm.newBlockState())
+
+	// Both failures leave the transaction settled, to be re-checked on the next cycle
+	assert.Empty(t, confirmed)
+	assert.Equal(t, settleStart, pending.settleStart)
+	assert.Len(t, pending.confirmations, 1)
+
+	mca.AssertExpectations(t)
+}
+
+func TestRestartSettlingReorgOfTransactionBlock(t *testing.T) {
+	bcm, _ := newTestBlockConfirmationManager(t, false)
+
+	confirmed := make(chan []BlockInfo, 1)
+	pending := newTestSettledTransaction(t, bcm, confirmed)
+	unaffected := &pendingItem{
+		pType:           pendingTypeTransaction,
+		transactionHash: fftypes.NewRandB32().String(),
+		blockNumber:     900,
+		blockHash:       "0xa900",
+		settleStart:     time.Now(),
+	}
+	bcm.pending[unaffected.getKey()] = unaffected
+
+	bcm.restartSettling(1000)
+	assert.True(t, pending.settleStart.IsZero())
+	assert.Empty(t, pending.confirmations)
+	assert.True(t, bcm.staleReceipts[pending.getKey()])
+	assert.False(t, unaffected.settleStart.IsZero())
+}
+
+func TestTransactionMilestonesPastRequirement(t *testing.T) {
+
+	bcm, _ := newTestBlockConfirmationManager(t, false)
+	assert.Equal(t, 3, bcm.requiredConfirmations)
+
+	receiptBlock := &BlockInfo{BlockNumber: 1001, BlockHash: fftypes.NewRandB32().String()}
+	var milestones []int
+	confirmedAt := 0
+	n := &Notification{
+		NotificationType: NewTransaction,
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x1111",
+			Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+				confirmedAt = len(confirmations)
+			},
+			Milestones: []int{1, 2, 5},
+			Milestone: func(ctx context.Context, confirmations []BlockInfo) {
+				milestones = append(milestones, len(confirmations))
+			},
+		},
+	}
+	pending := n.transactionPendingItem()
+	pending.blockNumber = receiptBlock.BlockNumber.Uint64()
+	pending.blockHash = receiptBlock.BlockHash
+	bcm.addOrReplaceItem(pending)
+
+	parent := receiptBlock
+	nextBlock := func() {
+		block := &BlockInfo{
+			BlockNumber: parent.BlockNumber + 1,
+			BlockHash:   fftypes.NewRandB32().String(),
+			ParentHash:  parent.BlockHash,
+		}
+		bcm.processBlock(block)
+		parent = block
+	}
+
+	// Milestones within the requirement are notified as each is reached
+	nextBlock()
+	nextBlock()
+	assert.Equal(t, []int{1, 2}, milestones)
+	assert.Zero(t, confirmedAt)
+
+	// The transaction is confirmed once, and is still tracked for the remaining milestone
+	nextBlock()
+	assert.Equal(t, 3, confirmedAt)
+	nextBlock()
+	assert.Equal(t, []int{1, 2}, milestones)
+	assert.Len(t, bcm.TransactionProgress("0x1111").Confirmations, 4)
+
+	// The last milestone ends the tracking
+	confirmedAt = 0
+	nextBlock()
+	assert.Equal(t, []int{1, 2, 5}, milestones)
+	assert.Zero(t, confirmedAt)
+	assert.Nil(t, bcm.TransactionProgress("0x1111"))
+
+}
+
+func TestTransactionMilestonesWalkingChain(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	bcm.highestBlockSeen = 1003
+
+	receiptBlock := &BlockInfo{BlockNumber: 1001, BlockHash: fftypes.NewRandB32().String()}
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptResponse{
+		BlockHash:        receiptBlock.BlockHash,
+		BlockNumber:      fftypes.NewFFBigInt(int64(receiptBlock.BlockNumber)),
+		TransactionIndex: fftypes.NewFFBigInt(0),
+		Success:          true,
+	}, ffcapi.ErrorReason(""), nil)
+	parent := receiptBlock
+	for i := 0; i < 2; i++ {
+		block := &BlockInfo{
+			BlockNumber: parent.BlockNumber + 1,
+			BlockHash:   fftypes.NewRandB32().String(),
+			ParentHash:  parent.BlockHash,
+		}
+		mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+			return r.BlockNumber.Uint64() == block.BlockNumber.Uint64()
+		})).Return(&ffcapi.BlockInfoByNumberResponse{
+			BlockInfo: ffcapi.BlockInfo{
+				BlockNumber: fftypes.NewFFBigInt(int64(block.BlockNumber)),
+				BlockHash:   block.BlockHash,
+				ParentHash:  block.ParentHash,
+			},
+		}, ffcapi.ErrorReason(""), nil)
+		parent = block
+	}
+
+	var milestones []int
+	pending := &pendingItem{
+		pType:           pendingTypeTransaction,
+		transactionHash: "0x1111",
+		milestones:      []int{1, 2},
+		milestoneCallback: func(ctx context.Context, confirmations []BlockInfo) {
+			milestones = append(milestones, len(confirmations))
+		},
+	}
+	bcm.addOrReplaceItem(pending)
+
+	// The blocks already seen when the receipt arrives reach both milestones
+	bcm.checkReceipt(pending, bcm.newBlockState())
+	assert.Equal(t, []int{1, 2}, milestones)
+	assert.Len(t, bcm.TransactionProgress("0x1111").Confirmations, 2)
+
+	mca.AssertExpectations(t)
+}
@@ -0,0 +1,56 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirmations
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+)
+
+const (
+	// StrategyFast requires the configured (small) number of confirmations, for chains with instant finality
+	StrategyFast = "fast"
+	// StrategySafe requires the configured (larger) number of confirmations, to protect against re-orgs
+	StrategySafe = "safe"
+	// StrategyFinalized waits for the block to be finalized, as reported by the connector
+	StrategyFinalized = "finalized"
+)
+
+// Requirement is the concrete behavior that a named confirmation strategy resolves to
+type Requirement struct {
+	Confirmations int  // the number of blocks that must be built on the block, when not waiting for finality
+	Finalized     bool // wait for the connector to report the block as finalized, rather than counting blocks
+}
+
+// ResolveStrategy maps a named confirmation strategy to a requirement, using the configured number of
+// confirmations for each of the counting strategies
+func ResolveStrategy(ctx context.Context, strategy string) (*Requirement, error) {
+	switch strategy {
+	case StrategyFast:
+		return &Requirement{Confirmations: config.GetInt(tmconfig.ConfirmationsStrategiesFast)}, nil
+	case StrategySafe:
+		return &Requirement{Confirmations: config.GetInt(tmconfig.ConfirmationsStrategiesSafe)}, nil
+	case StrategyFinalized:
+		return &Requirement{Finalized: true}, nil
+	default:
+		return nil, i18n.NewError(ctx, tmmsgs.MsgUnknownConfirmationStrategy, strategy)
+	}
+}
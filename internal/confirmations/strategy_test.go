@@ -0,0 +1,64 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirmations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveStrategyDefaults(t *testing.T) {
+	tmconfig.Reset()
+	ctx := context.Background()
+
+	r, err := ResolveStrategy(ctx, StrategyFast)
+	assert.NoError(t, err)
+	assert.Equal(t, &Requirement{Confirmations: 1}, r)
+
+	r, err = ResolveStrategy(ctx, StrategySafe)
+	assert.NoError(t, err)
+	assert.Equal(t, &Requirement{Confirmations: 20}, r)
+
+	r, err = ResolveStrategy(ctx, StrategyFinalized)
+	assert.NoError(t, err)
+	assert.Equal(t, &Requirement{Finalized: true}, r)
+}
+
+func TestResolveStrategyConfigured(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsStrategiesFast, 0)
+	config.Set(tmconfig.ConfirmationsStrategiesSafe, 64)
+	ctx := context.Background()
+
+	r, err := ResolveStrategy(ctx, StrategyFast)
+	assert.NoError(t, err)
+	assert.Equal(t, &Requirement{Confirmations: 0}, r)
+
+	r, err = ResolveStrategy(ctx, StrategySafe)
+	assert.NoError(t, err)
+	assert.Equal(t, &Requirement{Confirmations: 64}, r)
+}
+
+func TestResolveStrategyUnknown(t *testing.T) {
+	tmconfig.Reset()
+	_, err := ResolveStrategy(context.Background(), "slow")
+	assert.Regexp(t, "FF21153.*slow", err)
+}
@@ -0,0 +1,183 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/abi"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type abiEventDecoder struct {
+	name   string
+	params []*apitypes.ABIParameter
+	types  []*abi.Type
+	topics int // number of indexed parameters
+}
+
+// eventDecoder decodes the raw logs delivered by a stream, using the event ABI configured on the stream.
+// Non-anonymous events are matched using the signature hash in the first topic, and anonymous
+// events are tried in turn if none match.
+type eventDecoder struct {
+	bySignature map[string]*abiEventDecoder
+	anonymous   []*abiEventDecoder
+}
+
+// rawLog is the log structure expected in the data of an event, for it to be decoded
+type rawLog struct {
+	Topics []string `json:"topics"`
+	Data   string   `json:"data"`
+}
+
+// newEventDecoder validates the event ABI of a stream, returning nil if the stream has none
+func newEventDecoder(ctx context.Context, eventABI []*apitypes.ABIEvent) (*eventDecoder, error) {
+	if len(eventABI) == 0 {
+		return nil, nil
+	}
+	d := &eventDecoder{
+		bySignature: make(map[string]*abiEventDecoder),
+	}
+	for _, e := range eventABI {
+		if e == nil || e.Name == "" || (e.Type != "" && e.Type != "event") {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidEventABI, eventName(e), "name is required, and type must be 'event'")
+		}
+		ed := &abiEventDecoder{name: e.Name, params: e.Inputs}
+		for _, p := range e.Inputs {
+			t, ok := abi.ParseType(p.Type)
+			if !ok {
+				return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidEventABI, e.Name, fmt.Sprintf("unsupported type '%s'", p.Type))
+			}
+			if p.Indexed {
+				ed.topics++
+			}
+			ed.types = append(ed.types, t)
+		}
+		if e.Anonymous {
+			d.anonymous = append(d.anonymous, ed)
+			continue
+		}
+		d.bySignature["0x"+hex.EncodeToString(abi.SignatureHash(e.Name, ed.types))] = ed
+	}
+	return d, nil
+}
+
+// decode returns the named parameters of the event, decoded from the raw log in its data
+func (d *eventDecoder) decode(ctx context.Context, event *ffcapi.Event) (*fftypes.JSONAny, error) {
+	var l rawLog
+	if event.Data != nil {
+		_ = event.Data.Unmarshal(ctx, &l)
+	}
+	topics := make([][]byte, len(l.Topics))
+	for i, t := range l.Topics {
+		b, err := abi.DecodeHex(t)
+		if err != nil || len(b) != abi.WordSize {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgEventABINoMatch, l.Topics)
+		}
+		topics[i] = b
+	}
+	data, err := abi.DecodeHex(l.Data)
+	if err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgEventABINoMatch, l.Topics)
+	}
+
+	if len(l.Topics) > 0 {
+		if ed, ok := d.bySignature[strings.ToLower(l.Topics[0])]; ok {
+			return ed.decode(ctx, topics[1:], data)
+		}
+	}
+	var lastErr error
+	for _, ed := range d.anonymous {
+		if ed.topics != len(topics) {
+			continue
+		}
+		var decoded *fftypes.JSONAny
+		if decoded, lastErr = ed.decode(ctx, topics, data); lastErr == nil {
+			return decoded, nil
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, i18n.NewError(ctx, tmmsgs.MsgEventABINoMatch, l.Topics)
+}
+
+func (ed *abiEventDecoder) decode(ctx context.Context, topics [][]byte, data []byte) (*fftypes.JSONAny, error) {
+	if len(topics) != ed.topics {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgEventABIDecodeFailed, ed.name, fmt.Sprintf("expected %d indexed topics, found %d", ed.topics, len(topics)))
+	}
+	decoded := fftypes.JSONObject{}
+	topicIdx, dataIdx := 0, 0
+	for i, p := range ed.params {
+		name := p.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		t := ed.types[i]
+		var value interface{}
+		var err error
+		switch {
+		case p.Indexed && t.Dynamic():
+			// Only the hash of indexed dynamic values is recorded in the topic
+			value = "0x" + hex.EncodeToString(topics[topicIdx])
+			topicIdx++
+		case p.Indexed:
+			value = abi.DecodeWord(topics[topicIdx], t)
+			topicIdx++
+		default:
+			value, err = abi.DecodeData(data, dataIdx, t)
+			dataIdx++
+		}
+		if err != nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgEventABIDecodeFailed, ed.name, fmt.Sprintf("%s: %s", name, err))
+		}
+		decoded[name] = value
+	}
+	return fftypes.JSONAnyPtr(decoded.String()), nil
+}
+
+// checkUpdateEventABI merges an event ABI update, noting that an empty list in the updates clears the event ABI
+func checkUpdateEventABI(changed bool, merged *[]*apitypes.ABIEvent, old []*apitypes.ABIEvent, new []*apitypes.ABIEvent) bool {
+	if new == nil {
+		*merged = old
+		return changed
+	}
+	if len(new) == 0 {
+		*merged = nil
+	} else {
+		*merged = new
+	}
+	jsonOld, _ := json.Marshal(old)
+	jsonNew, _ := json.Marshal(*merged)
+	return changed || string(jsonOld) != string(jsonNew)
+}
+
+func eventName(e *apitypes.ABIEvent) string {
+	if e == nil {
+		return ""
+	}
+	return e.Name
+}
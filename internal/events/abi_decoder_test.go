@@ -0,0 +1,210 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const transferEventABI = `[{
+	"type": "event",
+	"name": "Transfer",
+	"inputs": [
+		{"name": "from", "type": "address", "indexed": true},
+		{"name": "to", "type": "address", "indexed": true},
+		{"name": "value", "type": "uint256"}
+	]
+}]`
+
+const sampleTransferLog = `{
+	"topics": [
+		"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		"0x000000000000000000000000aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"0x000000000000000000000000bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	],
+	"data": "0x00000000000000000000000000000000000000000000000000000000000003e8"
+}`
+
+func newTestDecoder(t *testing.T, abiJSON string) *eventDecoder {
+	var abi []*apitypes.ABIEvent
+	err := json.Unmarshal([]byte(abiJSON), &abi)
+	assert.NoError(t, err)
+	d, err := newEventDecoder(context.Background(), abi)
+	assert.NoError(t, err)
+	return d
+}
+
+func decodeTestLog(t *testing.T, d *eventDecoder, log string) (map[string]interface{}, error) {
+	decoded, err := d.decode(context.Background(), &ffcapi.Event{Data: fftypes.JSONAnyPtr(log)})
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	err = json.Unmarshal(decoded.Bytes(), &m)
+	assert.NoError(t, err)
+	return m, nil
+}
+
+func TestDecodeTransferEvent(t *testing.T) {
+
+	d := newTestDecoder(t, transferEventABI)
+
+	m, err := decodeTestLog(t, d, sampleTransferLog)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"from":  "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"to":    "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		"value": "1000",
+	}, m)
+
+}
+
+func TestDecodeDynamicAndSignedParams(t *testing.T) {
+
+	d := newTestDecoder(t, `[{
+		"name": "Changed",
+		"inputs": [
+			{"name": "key", "type": "string", "indexed": true},
+			{"name": "delta", "type": "int"},
+			{"name": "label", "type": "string"},
+			{"name": "flag", "type": "bool"},
+			{"name": "tag", "type": "bytes4"},
+			{"name": "", "type": "bytes"}
+		]
+	}]`)
+
+	// keccak256("Changed(string,int256,string,bool,bytes4,bytes)")
+	m, err := decodeTestLog(t, d, `{
+		"topics": [
+			"0x9f017ab3c58472f67be5df8e816b4002422b766bf3bb90e2d3a5b3602098c3e5",
+			"0x1111111111111111111111111111111111111111111111111111111111111111"
+		],
+		"data": "0x`+
+		`fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe`+ // delta = -2
+		`00000000000000000000000000000000000000000000000000000000000000a0`+ // label offset
+		`0000000000000000000000000000000000000000000000000000000000000001`+ // flag
+		`cafe000100000000000000000000000000000000000000000000000000000000`+ // tag
+		`00000000000000000000000000000000000000000000000000000000000000e0`+ // bytes offset
+		`0000000000000000000000000000000000000000000000000000000000000005`+ // label length
+		`68656c6c6f000000000000000000000000000000000000000000000000000000`+ // "hello"
+		`0000000000000000000000000000000000000000000000000000000000000002`+ // bytes length
+		`abcd000000000000000000000000000000000000000000000000000000000000"
+	}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"key":   "0x1111111111111111111111111111111111111111111111111111111111111111", // hash of the indexed string
+		"delta": "-2",
+		"label": "hello",
+		"flag":  true,
+		"tag":   "0xcafe0001",
+		"5":     "0xabcd",
+	}, m)
+
+}
+
+func TestDecodeAnonymousEvent(t *testing.T) {
+
+	d := newTestDecoder(t, `[{
+		"name": "Anon",
+		"anonymous": true,
+		"inputs": [
+			{"name": "id", "type": "uint8", "indexed": true},
+			{"name": "value", "type": "uint256"}
+		]
+	}]`)
+
+	m, err := decodeTestLog(t, d, `{
+		"topics": ["0x0000000000000000000000000000000000000000000000000000000000000007"],
+		"data": "0x0000000000000000000000000000000000000000000000000000000000000009"
+	}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "7", "value": "9"}, m)
+
+	// Insufficient data
+	_, err = decodeTestLog(t, d, `{
+		"topics": ["0x0000000000000000000000000000000000000000000000000000000000000007"],
+		"data": "0x"
+	}`)
+	assert.Regexp(t, "FF21110.*value", err)
+
+	// Wrong number of topics
+	_, err = decodeTestLog(t, d, `{"topics": [], "data": "0x"}`)
+	assert.Regexp(t, "FF21109", err)
+
+}
+
+func TestDecodeFailures(t *testing.T) {
+
+	d := newTestDecoder(t, transferEventABI)
+
+	_, err := decodeTestLog(t, d, `{"k1":"v1"}`)
+	assert.Regexp(t, "FF21109", err)
+
+	_, err = decodeTestLog(t, d, `{"topics": ["not hex"]}`)
+	assert.Regexp(t, "FF21109", err)
+
+	_, err = decodeTestLog(t, d, `{"topics": ["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"], "data": "not hex"}`)
+	assert.Regexp(t, "FF21109", err)
+
+	// Signature matches, but the indexed parameters are missing
+	_, err = decodeTestLog(t, d, `{"topics": ["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"], "data": "0x"}`)
+	assert.Regexp(t, "FF21110.*Transfer", err)
+
+	d = newTestDecoder(t, `[{"name": "Dyn", "inputs": [{"name": "s", "type": "string"}]}]`)
+	dynTopic := `"0xaedd95f9ac2b45d0b98ec0d757310ae35c362bdce39d86149ed33939e28f59c9"`
+	_, err = decodeTestLog(t, d, `{"topics": [`+dynTopic+`], "data": "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"}`)
+	assert.Regexp(t, "FF21110.*offset", err)
+	_, err = decodeTestLog(t, d, `{"topics": [`+dynTopic+`], "data": "0x0000000000000000000000000000000000000000000000000000000000000020"}`)
+	assert.Regexp(t, "FF21110.*insufficient", err)
+	_, err = decodeTestLog(t, d, `{"topics": [`+dynTopic+`], "data": "0x`+
+		`0000000000000000000000000000000000000000000000000000000000000020`+
+		`0000000000000000000000000000000000000000000000000000000000000040"}`)
+	assert.Regexp(t, "FF21110.*length", err)
+
+}
+
+func TestInvalidEventABI(t *testing.T) {
+
+	for _, abiJSON := range []string{
+		`[null]`,
+		`[{"name": ""}]`,
+		`[{"type": "function", "name": "set"}]`,
+		`[{"name": "E", "inputs": [{"type": "uint7"}]}]`,
+		`[{"name": "E", "inputs": [{"type": "uintX"}]}]`,
+		`[{"name": "E", "inputs": [{"type": "bytes33"}]}]`,
+		`[{"name": "E", "inputs": [{"type": "uint256[]"}]}]`,
+		`[{"name": "E", "inputs": [{"type": "tuple"}]}]`,
+	} {
+		var abi []*apitypes.ABIEvent
+		err := json.Unmarshal([]byte(abiJSON), &abi)
+		assert.NoError(t, err)
+		_, err = newEventDecoder(context.Background(), abi)
+		assert.Regexp(t, "FF21108", err, abiJSON)
+	}
+
+	d, err := newEventDecoder(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+
+}
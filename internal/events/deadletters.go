@@ -0,0 +1,146 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+type deadLetterReplay struct {
+	deadLetter *apitypes.DeadLetter
+	result     chan error
+}
+
+// deadLetter stores a batch of events that could not be delivered, so it can be inspected and replayed later.
+// The stream moves on past the batch regardless, so failures to store it are only logged.
+func (es *eventStream) deadLetter(ctx context.Context, events []*apitypes.EventWithContext, reason error) {
+	if esDefaults.deadLetterMaxEntries <= 0 {
+		return
+	}
+	deadLetter := &apitypes.DeadLetter{
+		ID:       fftypes.NewUUID(),
+		StreamID: es.spec.ID,
+		Created:  fftypes.Now(),
+		Reason:   reason.Error(),
+		Events:   events,
+	}
+	if err := es.persistence.WriteDeadLetter(ctx, deadLetter); err != nil {
+		log.L(ctx).Errorf("Failed to store dead letter for %d events: %s", len(events), err)
+		return
+	}
+	log.L(ctx).Warnf("Stored dead letter %s for %d events", deadLetter.ID, len(events))
+	if err := es.pruneDeadLetters(ctx); err != nil {
+		log.L(ctx).Errorf("Failed to prune dead letters: %s", err)
+	}
+}
+
+// pruneDeadLetters removes the dead letters that are older than the retention period, and the oldest
+// beyond the maximum number of entries
+func (es *eventStream) pruneDeadLetters(ctx context.Context) error {
+	deadLetters, err := es.persistence.ListStreamDeadLetters(ctx, es.spec.ID, 0)
+	if err != nil {
+		return err
+	}
+	excess := len(deadLetters) - esDefaults.deadLetterMaxEntries
+	for i, deadLetter := range deadLetters {
+		expired := esDefaults.deadLetterRetention > 0 && time.Since(*deadLetter.Created.Time()) > esDefaults.deadLetterRetention
+		if i < excess || expired {
+			log.L(ctx).Infof("Pruning dead letter %s created %s", deadLetter.ID, deadLetter.Created)
+			if err := es.persistence.DeleteDeadLetter(ctx, deadLetter); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteDeadLetters removes all the dead letters of the stream, when it is deleted
+func (es *eventStream) deleteDeadLetters(ctx context.Context) error {
+	deadLetters, err := es.persistence.ListStreamDeadLetters(ctx, es.spec.ID, 0)
+	if err != nil {
+		return err
+	}
+	for _, deadLetter := range deadLetters {
+		if err := es.persistence.DeleteDeadLetter(ctx, deadLetter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (es *eventStream) DeadLetters(ctx context.Context, limit int) ([]*apitypes.DeadLetter, error) {
+	if err := es.pruneDeadLetters(ctx); err != nil {
+		return nil, err
+	}
+	return es.persistence.ListStreamDeadLetters(ctx, es.spec.ID, limit)
+}
+
+// ReplayDeadLetters passes each dead letter in turn to the batch loop for a single delivery attempt, oldest first.
+// Each that is delivered is removed. The first that fails is left in place along with all those after it,
+// so events are not delivered out of order.
+func (es *eventStream) ReplayDeadLetters(ctx context.Context) (*apitypes.DeadLetterReplayResult, error) {
+	es.mux.Lock()
+	status := es.status
+	startedState := es.currentState
+	es.mux.Unlock()
+	if status != apitypes.EventStreamStatusStarted {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgStreamStateError, status)
+	}
+
+	deadLetters, err := es.DeadLetters(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := &apitypes.DeadLetterReplayResult{StreamID: es.spec.ID}
+	for i, deadLetter := range deadLetters {
+		dlr := &deadLetterReplay{
+			deadLetter: deadLetter,
+			result:     make(chan error, 1),
+		}
+		var deliveryErr error
+		select {
+		case startedState.deadLetterReplays <- dlr:
+			select {
+			case deliveryErr = <-dlr.result:
+			case <-startedState.ctx.Done():
+				return nil, i18n.NewError(ctx, tmmsgs.MsgStreamStateError, apitypes.EventStreamStatusStopping)
+			}
+		case <-startedState.ctx.Done():
+			return nil, i18n.NewError(ctx, tmmsgs.MsgStreamStateError, apitypes.EventStreamStatusStopping)
+		case <-ctx.Done():
+			return nil, i18n.NewError(ctx, i18n.MsgContextCanceled)
+		}
+		if deliveryErr != nil {
+			log.L(ctx).Warnf("Redelivery of dead letter %s failed: %s", deadLetter.ID, deliveryErr)
+			result.Remaining = len(deadLetters) - i
+			break
+		}
+		if err := es.persistence.DeleteDeadLetter(ctx, deadLetter); err != nil {
+			return nil, err
+		}
+		result.Delivered++
+	}
+	log.L(ctx).Infof("Redelivered %d dead letters on stream %s (remaining=%d)", result.Delivered, es, result.Remaining)
+	return result, nil
+}
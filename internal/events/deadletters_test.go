@@ -0,0 +1,313 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newTestDeadLetterEventStream returns a started stream with skip error handling, backed by an in-memory
+// persistence, and with the batch loop running
+func newTestDeadLetterEventStream(t *testing.T) (*eventStream, *startedStreamState, func()) {
+	es, err := newTestEventStreamWithListener(t, &ffcapimocks.API{}, `{
+		"name": "ut_stream",
+		"errorHandling": "skip",
+		"retryTimeout": "0s"
+	}`)
+	assert.NoError(t, err)
+	config.Set(tmconfig.PersistenceSQLitePath, persistence.SQLiteInMemory)
+	es.persistence, err = persistence.NewSQLitePersistence(context.Background())
+	assert.NoError(t, err)
+
+	ss := &startedStreamState{
+		batchLoopDone:     make(chan struct{}),
+		replays:           make(chan ffcapi.ListenerEvents),
+		deadLetterReplays: make(chan *deadLetterReplay),
+	}
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+	es.status = apitypes.EventStreamStatusStarted
+	es.currentState = ss
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		es.batchLoop(ss)
+		wg.Done()
+	}()
+	return es, ss, func() {
+		ss.cancelCtx()
+		wg.Wait()
+		es.persistence.Close(context.Background())
+	}
+}
+
+func testDeadLetterEvents(blocks ...uint64) []*apitypes.EventWithContext {
+	events := make([]*apitypes.EventWithContext, len(blocks))
+	for i, block := range blocks {
+		events[i] = &apitypes.EventWithContext{
+			Event: ffcapi.Event{ID: ffcapi.EventID{BlockNumber: fftypes.FFuint64(block)}},
+		}
+	}
+	return events
+}
+
+func TestDeadLetterDeliveryFailureThenReplay(t *testing.T) {
+	es, ss, done := newTestDeadLetterEventStream(t)
+	defer done()
+	ctx := context.Background()
+
+	var mux sync.Mutex
+	failing := true
+	delivered := make(chan []*apitypes.EventWithContext, 1)
+	ss.action = func(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+		mux.Lock()
+		defer mux.Unlock()
+		if failing {
+			return fmt.Errorf("pop")
+		}
+		delivered <- events
+		return nil
+	}
+
+	// The delivery fails, and the batch is skipped into the dead-letter store
+	err := es.performActionsWithRetry(ss, &eventStreamBatch{number: 1, events: testDeadLetterEvents(1001, 1002)})
+	assert.NoError(t, err)
+	deadLetters, err := es.DeadLetters(ctx, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, es.spec.ID, deadLetters[0].StreamID)
+	assert.Regexp(t, "pop", deadLetters[0].Reason)
+	assert.Len(t, deadLetters[0].Events, 2)
+
+	// Replay fails while the receiver is still failing, and leaves it in place
+	res, err := es.ReplayDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &apitypes.DeadLetterReplayResult{StreamID: es.spec.ID, Delivered: 0, Remaining: 1}, res)
+
+	// Once the receiver recovers, the replay delivers it and removes it
+	mux.Lock()
+	failing = false
+	mux.Unlock()
+	res, err = es.ReplayDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &apitypes.DeadLetterReplayResult{StreamID: es.spec.ID, Delivered: 1, Remaining: 0}, res)
+	events := <-delivered
+	assert.Len(t, events, 2)
+	assert.Equal(t, uint64(1001), events[0].ID.BlockNumber.Uint64())
+
+	deadLetters, err = es.DeadLetters(ctx, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, deadLetters)
+}
+
+func TestDeadLetterReplayStopsAtFirstFailure(t *testing.T) {
+	es, ss, done := newTestDeadLetterEventStream(t)
+	defer done()
+	ctx := context.Background()
+
+	ss.action = func(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+		if events[0].ID.BlockNumber == 1002 {
+			return fmt.Errorf("pop")
+		}
+		return nil
+	}
+	for _, block := range []uint64{1001, 1002, 1003} {
+		es.deadLetter(ctx, testDeadLetterEvents(block), fmt.Errorf("pop"))
+		time.Sleep(1 * time.Millisecond) // ensure distinct creation times
+	}
+
+	res, err := es.ReplayDeadLetters(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.Delivered)
+	assert.Equal(t, 2, res.Remaining)
+
+	deadLetters, err := es.DeadLetters(ctx, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, uint64(1002), deadLetters[0].Events[0].ID.BlockNumber.Uint64())
+	assert.Equal(t, uint64(1003), deadLetters[1].Events[0].ID.BlockNumber.Uint64())
+}
+
+func TestDeadLetterBoundedAndRetention(t *testing.T) {
+	es, _, done := newTestDeadLetterEventStream(t)
+	defer done()
+	ctx := context.Background()
+	esDefaults.deadLetterMaxEntries = 2
+	esDefaults.deadLetterRetention = 1 * time.Hour
+
+	// One that has passed the retention period
+	expired := fftypes.FFTime(time.Now().Add(-2 * time.Hour))
+	err := es.persistence.WriteDeadLetter(ctx, &apitypes.DeadLetter{
+		ID:       fftypes.NewUUID(),
+		StreamID: es.spec.ID,
+		Created:  &expired,
+		Events:   testDeadLetterEvents(1000),
+	})
+	assert.NoError(t, err)
+
+	for _, block := range []uint64{1001, 1002, 1003} {
+		es.deadLetter(ctx, testDeadLetterEvents(block), fmt.Errorf("pop"))
+		time.Sleep(1 * time.Millisecond) // ensure distinct creation times
+	}
+
+	// Only the newest entries, up to the maximum, are kept
+	deadLetters, err := es.DeadLetters(ctx, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, uint64(1002), deadLetters[0].Events[0].ID.BlockNumber.Uint64())
+	assert.Equal(t, uint64(1003), deadLetters[1].Events[0].ID.BlockNumber.Uint64())
+
+	deadLetters, err = es.DeadLetters(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 1)
+}
+
+func TestDeadLetterDisabled(t *testing.T) {
+	es := newTestEventStream(t, `{"name": "ut_stream"}`)
+	esDefaults.deadLetterMaxEntries = 0
+
+	// No persistence calls are made
+	es.deadLetter(context.Background(), testDeadLetterEvents(1001), fmt.Errorf("pop"))
+	es.persistence.(*persistencemocks.Persistence).AssertExpectations(t)
+}
+
+func TestDeadLetterStoreFailures(t *testing.T) {
+	es := newTestEventStream(t, `{"name": "ut_stream"}`)
+	ctx := context.Background()
+
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("WriteDeadLetter", mock.Anything, mock.Anything).Return(fmt.Errorf("pop")).Once()
+	msp.On("WriteDeadLetter", mock.Anything, mock.Anything).Return(nil)
+	msp.On("ListStreamDeadLetters", mock.Anything, es.spec.ID, 0).Return(nil, fmt.Errorf("pop"))
+
+	// Failures are only logged, as the stream moves on regardless
+	es.deadLetter(ctx, testDeadLetterEvents(1001), fmt.Errorf("pop"))
+	es.deadLetter(ctx, testDeadLetterEvents(1001), fmt.Errorf("pop"))
+
+	_, err := es.DeadLetters(ctx, 0)
+	assert.Regexp(t, "pop", err)
+
+	es.status = apitypes.EventStreamStatusStarted
+	_, err = es.ReplayDeadLetters(ctx)
+	assert.Regexp(t, "pop", err)
+
+	err = es.deleteDeadLetters(ctx)
+	assert.Regexp(t, "pop", err)
+
+	msp.AssertExpectations(t)
+}
+
+func TestDeadLetterDeleteFailures(t *testing.T) {
+	es := newTestEventStream(t, `{"name": "ut_stream"}`)
+	ctx := context.Background()
+	esDefaults.deadLetterMaxEntries = 0
+
+	deadLetter := &apitypes.DeadLetter{ID: fftypes.NewUUID(), StreamID: es.spec.ID, Created: fftypes.Now()}
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("ListStreamDeadLetters", mock.Anything, es.spec.ID, 0).Return([]*apitypes.DeadLetter{deadLetter}, nil)
+	msp.On("DeleteDeadLetter", mock.Anything, deadLetter).Return(fmt.Errorf("pop"))
+
+	err := es.pruneDeadLetters(ctx)
+	assert.Regexp(t, "pop", err)
+
+	err = es.deleteDeadLetters(ctx)
+	assert.Regexp(t, "pop", err)
+
+	msp.AssertExpectations(t)
+}
+
+func TestDeadLetterReplayDeleteFail(t *testing.T) {
+	es := newTestEventStream(t, `{"name": "ut_stream"}`)
+	ctx := context.Background()
+
+	deadLetter := &apitypes.DeadLetter{ID: fftypes.NewUUID(), StreamID: es.spec.ID, Created: fftypes.Now()}
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("ListStreamDeadLetters", mock.Anything, es.spec.ID, 0).Return([]*apitypes.DeadLetter{deadLetter}, nil)
+	msp.On("DeleteDeadLetter", mock.Anything, deadLetter).Return(fmt.Errorf("pop"))
+
+	ss := &startedStreamState{deadLetterReplays: make(chan *deadLetterReplay)}
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+	defer ss.cancelCtx()
+	es.status = apitypes.EventStreamStatusStarted
+	es.currentState = ss
+	go func() {
+		dlr := <-ss.deadLetterReplays
+		dlr.result <- nil
+	}()
+
+	_, err := es.ReplayDeadLetters(ctx)
+	assert.Regexp(t, "pop", err)
+
+	msp.AssertExpectations(t)
+}
+
+func TestDeadLetterReplayNotStarted(t *testing.T) {
+	es := newTestEventStream(t, `{"name": "ut_stream"}`)
+
+	_, err := es.ReplayDeadLetters(context.Background())
+	assert.Regexp(t, "FF21027", err)
+}
+
+func TestDeadLetterReplayStopping(t *testing.T) {
+	es := newTestEventStream(t, `{"name": "ut_stream"}`)
+
+	deadLetter := &apitypes.DeadLetter{ID: fftypes.NewUUID(), StreamID: es.spec.ID, Created: fftypes.Now()}
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("ListStreamDeadLetters", mock.Anything, es.spec.ID, 0).Return([]*apitypes.DeadLetter{deadLetter}, nil)
+
+	ss := &startedStreamState{deadLetterReplays: make(chan *deadLetterReplay)}
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+	es.status = apitypes.EventStreamStatusStarted
+	es.currentState = ss
+
+	// Stopped before the batch loop picks it up
+	ss.cancelCtx()
+	_, err := es.ReplayDeadLetters(context.Background())
+	assert.Regexp(t, "FF21027", err)
+
+	// Stopped while the batch loop is delivering it
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+	go func() {
+		<-ss.deadLetterReplays
+		ss.cancelCtx()
+	}()
+	_, err = es.ReplayDeadLetters(context.Background())
+	assert.Regexp(t, "FF21027", err)
+
+	// Request context cancelled
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+	defer ss.cancelCtx()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = es.ReplayDeadLetters(ctx)
+	assert.Regexp(t, "FF00154", err)
+}
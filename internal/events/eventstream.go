@@ -19,6 +19,7 @@ package events
 import (
 	"context"
 	"encoding/json"
+	"net/http"
 	"sync"
 	"time"
 
@@ -40,13 +41,22 @@ import (
 type Stream interface {
 	AddOrUpdateListener(ctx context.Context, id *fftypes.UUID,
 		updates *apitypes.Listener, reset bool) (*apitypes.Listener, error) // Add or update a listener
-	RemoveListener(ctx context.Context, id *fftypes.UUID) error          // Stop and remove a listener
-	UpdateSpec(ctx context.Context, updates *apitypes.EventStream) error // Apply definition updates (if there are changes)
-	Spec() *apitypes.EventStream                                         // Retrieve the merged definition to persist
-	Status() apitypes.EventStreamStatus                                  // Get the current status
-	Start(ctx context.Context) error                                     // Start delivery
-	Stop(ctx context.Context) error                                      // Stop delivery (does not remove checkpoints)
-	Delete(ctx context.Context) error                                    // Stop delivery, and clean up any checkpoint
+	RemoveListener(ctx context.Context, id *fftypes.UUID) error                 // Stop and remove a listener
+	UpdateSpec(ctx context.Context, updates *apitypes.EventStream) error        // Apply definition updates (if there are changes)
+	Spec() *apitypes.EventStream                                                // Retrieve the merged definition to persist
+	Status() apitypes.EventStreamStatus                                         // Get the current status
+	Start(ctx context.Context) error                                            // Start delivery
+	Stop(ctx context.Context) error                                             // Stop delivery (does not remove checkpoints)
+	Delete(ctx context.Context) error                                           // Stop delivery, and clean up any checkpoint
+	GetCheckpoint(ctx context.Context) (*apitypes.EventStreamCheckpoint, error) // Get the persisted checkpoint
+	SetCheckpoint(ctx context.Context, updates *apitypes.EventStreamCheckpoint,
+		confirmRewind bool) (*apitypes.EventStreamCheckpoint, error) // Replace the checkpoint of listeners, restarting delivery from there
+	ServeSSE(ctx context.Context, res http.ResponseWriter, manualAck bool) error     // Deliver batches to a Server-Sent Events client until it disconnects
+	AckSSE(ctx context.Context, batchNumber int) error                               // Acknowledge a batch delivered to a manual ack Server-Sent Events client
+	DeadLetters(ctx context.Context, limit int) ([]*apitypes.DeadLetter, error)      // List the batches that could not be delivered, oldest first
+	ReplayDeadLetters(ctx context.Context) (*apitypes.DeadLetterReplayResult, error) // Redeliver the batches that could not be delivered, removing those delivered
+	Replay(ctx context.Context,
+		req *apitypes.EventStreamReplay) (*apitypes.EventStreamReplayResult, error) // Re-deliver the events in a historical block range, without moving the checkpoint
 }
 
 // esDefaults are the defaults for new event streams, read from the config once in InitDefaults()
@@ -59,6 +69,13 @@ var esDefaults struct {
 	blockedRetryDelay         fftypes.FFDuration
 	webhookRequestTimeout     fftypes.FFDuration
 	websocketDistributionMode apitypes.DistributionMode
+	websocketNackDelay        fftypes.FFDuration
+	websocketMaxRedeliveries  int64
+	sseHeartbeatInterval      time.Duration
+	deadLetterMaxEntries      int
+	deadLetterRetention       time.Duration
+	confirmations             int64
+	maxConfirmations          int64
 	retry                     *retry.Retry
 }
 
@@ -70,6 +87,13 @@ func InitDefaults() {
 	esDefaults.blockedRetryDelay = fftypes.FFDuration(config.GetDuration(tmconfig.EventStreamsDefaultsBlockedRetryDelay))
 	esDefaults.webhookRequestTimeout = fftypes.FFDuration(config.GetDuration(tmconfig.EventStreamsDefaultsWebhookRequestTimeout))
 	esDefaults.websocketDistributionMode = fftypes.FFEnum(config.GetString(tmconfig.EventStreamsDefaultsWebsocketDistributionMode))
+	esDefaults.websocketNackDelay = fftypes.FFDuration(config.GetDuration(tmconfig.EventStreamsDefaultsWebsocketNackDelay))
+	esDefaults.websocketMaxRedeliveries = config.GetInt64(tmconfig.EventStreamsDefaultsWebsocketMaxRedeliveries)
+	esDefaults.sseHeartbeatInterval = config.GetDuration(tmconfig.EventStreamsSSEHeartbeatInterval)
+	esDefaults.deadLetterMaxEntries = config.GetInt(tmconfig.EventStreamsDeadLetterMaxEntries)
+	esDefaults.deadLetterRetention = config.GetDuration(tmconfig.EventStreamsDeadLetterRetention)
+	esDefaults.confirmations = config.GetInt64(tmconfig.ConfirmationsRequired)
+	esDefaults.maxConfirmations = config.GetInt64(tmconfig.ConfirmationsMaxRequired)
 	esDefaults.retry = &retry.Retry{
 		InitialDelay: config.GetDuration(tmconfig.EventStreamsRetryInitDelay),
 		MaximumDelay: config.GetDuration(tmconfig.EventStreamsRetryMaxDelay),
@@ -96,6 +120,9 @@ type startedStreamState struct {
 	blockListenerDone chan struct{}
 	updates           chan *ffcapi.ListenerEvent
 	blocks            chan *ffcapi.BlockHashEvent
+	blockRouter       *blocklistener.Router
+	replays           chan ffcapi.ListenerEvents
+	deadLetterReplays chan *deadLetterReplay
 }
 
 type eventStream struct {
@@ -108,10 +135,12 @@ type eventStream struct {
 	confirmations      confirmations.Manager
 	listeners          map[fftypes.UUID]*listener
 	wsChannels         ws.WebSocketChannels
+	sse                *sseAction
 	retry              *retry.Retry
 	currentState       *startedStreamState
 	checkpointInterval time.Duration
 	batchChannel       chan *ffcapi.ListenerEvent
+	blockListeners     blocklistener.Routers
 }
 
 func NewEventStream(
@@ -121,6 +150,7 @@ func NewEventStream(
 	persistence persistence.Persistence,
 	wsChannels ws.WebSocketChannels,
 	initialListeners []*apitypes.Listener,
+	blockListeners blocklistener.Routers,
 ) (ees Stream, err error) {
 	esCtx := log.WithLogField(bgCtx, "eventstream", persistedSpec.ID.String())
 	es := &eventStream{
@@ -131,17 +161,20 @@ func NewEventStream(
 		persistence:        persistence,
 		listeners:          make(map[fftypes.UUID]*listener),
 		wsChannels:         wsChannels,
+		sse:                newSSEAction(),
 		retry:              esDefaults.retry,
 		checkpointInterval: config.GetDuration(tmconfig.EventStreamsCheckpointInterval),
-	}
-	if config.GetInt(tmconfig.ConfirmationsRequired) > 0 {
-		es.confirmations = confirmations.NewBlockConfirmationManager(esCtx, connector, "_es_"+persistedSpec.ID.String())
+		blockListeners:     blockListeners,
 	}
 	// The configuration we have in memory, applies all the defaults to what is passed in
 	// to ensure there are no nil fields on the configuration object.
 	if es.spec, _, err = mergeValidateEsConfig(esCtx, nil, persistedSpec); err != nil {
 		return nil, err
 	}
+	if _, err = es.blockListenerRouter(esCtx, es.spec); err != nil {
+		return nil, err
+	}
+	es.confirmations = es.newConfirmationsManager()
 	es.batchChannel = make(chan *ffcapi.ListenerEvent, *es.spec.BatchSize)
 	for _, existing := range initialListeners {
 		spec, err := es.verifyListenerOptions(esCtx, existing.ID, existing)
@@ -157,13 +190,34 @@ func NewEventStream(
 	return es, nil
 }
 
+// newConfirmationsManager creates a confirmation manager using the confirmation strategy or number of
+// confirmations in the spec of this stream, or returns nil if the stream does not require any confirmations
+func (es *eventStream) newConfirmationsManager() confirmations.Manager {
+	required := &confirmations.Requirement{Confirmations: int(*es.spec.Confirmations)}
+	if es.spec.ConfirmationStrategy != nil {
+		// The strategy is validated when the spec is merged
+		if r, err := confirmations.ResolveStrategy(es.bgCtx, *es.spec.ConfirmationStrategy); err == nil {
+			required = r
+		}
+	}
+	if !required.Finalized && required.Confirmations == 0 {
+		return nil
+	}
+	return confirmations.NewBlockConfirmationManagerForRequirement(es.bgCtx, es.connector, "_es_"+es.spec.ID.String(), required)
+}
+
 func (es *eventStream) initAction(startedState *startedStreamState) {
 	ctx := startedState.ctx
 	switch *es.spec.Type {
 	case apitypes.EventStreamTypeWebhook:
-		startedState.action = newWebhookAction(ctx, es.spec.Webhook).attemptBatch
+		startedState.action = newWebhookAction(ctx, es.spec.Webhook, *es.spec.PayloadEncoding).attemptBatch
 	case apitypes.EventStreamTypeWebSocket:
-		startedState.action = newWebSocketAction(es.wsChannels, es.spec.WebSocket, *es.spec.Name).attemptBatch
+		wsa := newWebSocketAction(es.wsChannels, es.spec.WebSocket, *es.spec.PayloadEncoding, *es.spec.Name)
+		wsa.deadLetter = es.deadLetter
+		startedState.action = wsa.attemptBatch
+	case apitypes.EventStreamTypeSSE:
+		// Batches are delivered to whichever client is connected to the stream at the time
+		startedState.action = es.sse.attemptBatch
 	default:
 		// mergeValidateEsConfig always be called previous to this
 		panic(i18n.NewError(ctx, tmmsgs.MsgInvalidStreamType, *es.spec.Type))
@@ -204,6 +258,9 @@ func mergeValidateEsConfig(ctx context.Context, base *apitypes.EventStream, upda
 
 	// Batch size
 	changed = apitypes.CheckUpdateUint64(changed, &merged.BatchSize, base.BatchSize, updates.BatchSize, esDefaults.batchSize)
+	if *merged.BatchSize < 1 {
+		return nil, false, i18n.NewError(ctx, tmmsgs.MsgInvalidBatchSize, *merged.BatchSize)
+	}
 
 	// Error handling mode
 	changed = apitypes.CheckUpdateEnum(changed, &merged.ErrorHandling, base.ErrorHandling, updates.ErrorHandling, esDefaults.errorHandling)
@@ -232,6 +289,40 @@ func mergeValidateEsConfig(ctx context.Context, base *apitypes.EventStream, upda
 		changed = apitypes.CheckUpdateDuration(changed, &merged.BlockedRetryDelay, base.BlockedRetryDelay, updates.BlockedRetryDelay, esDefaults.blockedRetryDelay)
 	}
 
+	// Confirmations - an override of the global default for this stream, up to a configured maximum
+	if updates.Confirmations != nil && int64(*updates.Confirmations) > esDefaults.maxConfirmations {
+		return nil, false, i18n.NewError(ctx, tmmsgs.MsgConfirmationsExceedMax, *updates.Confirmations, esDefaults.maxConfirmations)
+	}
+	changed = apitypes.CheckUpdateUint64(changed, &merged.Confirmations, base.Confirmations, updates.Confirmations, esDefaults.confirmations)
+
+	// Confirmation strategy (no default) - a named strategy, mapped through config, that takes precedence over confirmations
+	changed = checkUpdateOptionalString(changed, &merged.ConfirmationStrategy, base.ConfirmationStrategy, updates.ConfirmationStrategy)
+	if merged.ConfirmationStrategy != nil {
+		if _, err := confirmations.ResolveStrategy(ctx, *merged.ConfirmationStrategy); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Filter (no default - a nil filter matches all events)
+	changed = checkUpdateFilter(changed, &merged.Filter, base.Filter, updates.Filter)
+
+	// Block listener (no default - blocks are delivered to the stream by the connector)
+	changed = checkUpdateOptionalString(changed, &merged.BlockListener, base.BlockListener, updates.BlockListener)
+
+	// Event ABI (no default - raw logs are delivered without decoding)
+	changed = checkUpdateEventABI(changed, &merged.EventABI, base.EventABI, updates.EventABI)
+	if _, err := newEventDecoder(ctx, merged.EventABI); err != nil {
+		return nil, false, err
+	}
+
+	// Payload encoding
+	changed = apitypes.CheckUpdateEnum(changed, &merged.PayloadEncoding, base.PayloadEncoding, updates.PayloadEncoding, apitypes.PayloadEncodingJSON)
+	switch *merged.PayloadEncoding {
+	case apitypes.PayloadEncodingJSON, apitypes.PayloadEncodingMsgpack:
+	default:
+		return nil, false, i18n.NewError(ctx, tmmsgs.MsgInvalidPayloadEncoding, *merged.PayloadEncoding)
+	}
+
 	// Type
 	changed = apitypes.CheckUpdateEnum(changed, &merged.Type, base.Type, updates.Type, apitypes.EventStreamTypeWebSocket)
 	switch *merged.Type {
@@ -243,6 +334,11 @@ func mergeValidateEsConfig(ctx context.Context, base *apitypes.EventStream, upda
 		if merged.Webhook, changed, err = mergeValidateWhConfig(ctx, changed, base.Webhook, updates.Webhook); err != nil {
 			return nil, false, err
 		}
+	case apitypes.EventStreamTypeSSE:
+		// No type specific configuration, and batches are delivered as text
+		if *merged.PayloadEncoding != apitypes.PayloadEncodingJSON {
+			return nil, false, i18n.NewError(ctx, tmmsgs.MsgPayloadEncodingNotSupported, *merged.PayloadEncoding, *merged.Type)
+		}
 	default:
 		return nil, false, i18n.NewError(ctx, tmmsgs.MsgInvalidStreamType, *merged.Type)
 	}
@@ -250,25 +346,78 @@ func mergeValidateEsConfig(ctx context.Context, base *apitypes.EventStream, upda
 	return merged, changed, nil
 }
 
+// checkUpdateOptionalString merges an optional string with no default, where an empty string clears it
+func checkUpdateOptionalString(changed bool, merged **string, old *string, new *string) bool {
+	if new == nil {
+		*merged = old
+		return changed
+	}
+	if *new == "" {
+		*merged = nil
+	} else {
+		*merged = new
+	}
+	return changed || optionalStringChanged(old, *merged)
+}
+
+func optionalStringChanged(old, new *string) bool {
+	return (old == nil) != (new == nil) || (old != nil && *old != *new)
+}
+
+// blockListenerRouter returns the configured block listener selected by the spec, if any
+func (es *eventStream) blockListenerRouter(ctx context.Context, spec *apitypes.EventStream) (*blocklistener.Router, error) {
+	if spec.BlockListener == nil {
+		return nil, nil
+	}
+	r := es.blockListeners[*spec.BlockListener]
+	if r == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgBlockListenerNotConfigured, *spec.BlockListener)
+	}
+	return r, nil
+}
+
 func (es *eventStream) Spec() *apitypes.EventStream {
+	es.mux.Lock()
+	defer es.mux.Unlock()
 	return es.spec
 }
 
+// UpdateSpec merges the updates into the spec of the stream. The loops of a started stream read the spec
+// without locking, so a started stream is stopped before the spec is replaced, and then restarted unless
+// the update suspends it.
 func (es *eventStream) UpdateSpec(ctx context.Context, updates *apitypes.EventStream) error {
-	merged, changed, err := mergeValidateEsConfig(ctx, es.spec, updates)
+	spec := es.Spec()
+	merged, changed, err := mergeValidateEsConfig(ctx, spec, updates)
 	if err != nil {
 		return err
 	}
+	if _, err = es.blockListenerRouter(ctx, merged); err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
 
 	es.mux.Lock()
-	es.spec = merged
 	isStarted := es.status == apitypes.EventStreamStatusStarted
 	es.mux.Unlock()
 
-	if changed && isStarted {
+	if isStarted {
 		if err := es.Stop(ctx); err != nil {
 			return i18n.NewError(ctx, tmmsgs.MsgStopFailedUpdatingESConfig, err)
 		}
+	}
+
+	es.mux.Lock()
+	confirmationsChanged := *merged.Confirmations != *es.spec.Confirmations || optionalStringChanged(es.spec.ConfirmationStrategy, merged.ConfirmationStrategy)
+	es.spec = merged
+	es.mux.Unlock()
+
+	if confirmationsChanged {
+		// The stream is stopped at this point, so we can swap in a new confirmation manager
+		es.confirmations = es.newConfirmationsManager()
+	}
+	if isStarted && !*merged.Suspended {
 		if err := es.Start(ctx); err != nil {
 			return i18n.NewError(ctx, tmmsgs.MsgStartFailedUpdatingESConfig, err)
 		}
@@ -478,6 +627,9 @@ func (es *eventStream) Start(ctx context.Context) error {
 		eventLoopDone: make(chan struct{}),
 		batchLoopDone: make(chan struct{}),
 		updates:       make(chan *ffcapi.ListenerEvent, int(*es.spec.BatchSize)),
+		replays:       make(chan ffcapi.ListenerEvents),
+		// Dead letters are redelivered one at a time by the batch loop, in between live batches
+		deadLetterReplays: make(chan *deadLetterReplay),
 	}
 	startedState.ctx, startedState.cancelCtx = context.WithCancel(es.bgCtx)
 	es.currentState = startedState
@@ -490,20 +642,32 @@ func (es *eventStream) Start(ctx context.Context) error {
 
 	initialListeners := make([]*ffcapi.EventListenerAddRequest, 0)
 	for _, l := range es.listeners {
-		initialListeners = append(initialListeners, l.buildAddRequest(ctx, cp))
+		req := l.buildAddRequest(ctx, cp)
+		// We resume from the persisted checkpoint, which might have been set manually while we were stopped
+		l.checkpoint = req.Checkpoint
+		initialListeners = append(initialListeners, req)
 	}
 	startedState.blocks, startedState.blockListenerDone = blocklistener.BufferChannel(startedState.ctx, es.confirmations)
-	_, _, err = es.connector.EventStreamStart(startedState.ctx, &ffcapi.EventStreamStartRequest{
+	startReq := &ffcapi.EventStreamStartRequest{
 		ID:               es.spec.ID,
 		EventStream:      startedState.updates,
 		StreamContext:    startedState.ctx,
-		BlockListener:    startedState.blocks,
 		InitialListeners: initialListeners,
-	})
+	}
+	// A stream using a configured block listener receives blocks from that listener, rather than
+	// requiring the connector to deliver every block on the chain to the stream
+	startedState.blockRouter, _ = es.blockListenerRouter(ctx, es.spec)
+	if startedState.blockRouter == nil {
+		startReq.BlockListener = startedState.blocks
+	}
+	_, _, err = es.connector.EventStreamStart(startedState.ctx, startReq)
 	if err != nil {
 		_ = es.checkSetStatus(ctx, apitypes.EventStreamStatusStarted, apitypes.EventStreamStatusStopped)
 		return err
 	}
+	if startedState.blockRouter != nil {
+		startedState.blockRouter.AddConsumer(es.spec.ID, startedState.blocks)
+	}
 
 	// Kick off the loops
 	go es.eventLoop(startedState)
@@ -530,6 +694,11 @@ func (es *eventStream) requestStop(ctx context.Context) (*startedStreamState, er
 	}
 	log.L(ctx).Infof("Stopping event stream %s", es)
 
+	// Stop receiving blocks from any configured block listener, before the block buffer stops consuming
+	if startedState.blockRouter != nil {
+		startedState.blockRouter.RemoveConsumer(es.spec.ID)
+	}
+
 	// Cancel the context, stop stop the event loop, and shut down the action (WebSockets in particular)
 	startedState.cancelCtx()
 
@@ -560,7 +729,9 @@ func (es *eventStream) Stop(ctx context.Context) error {
 	}
 
 	// Stop the confirmations manager
-	es.confirmations.Stop()
+	if es.confirmations != nil {
+		es.confirmations.Stop()
+	}
 
 	// Wait for our event loop to stop
 	<-startedState.eventLoopDone
@@ -594,6 +765,9 @@ func (es *eventStream) Delete(ctx context.Context) error {
 	if err := es.persistence.DeleteCheckpoint(ctx, es.spec.ID); err != nil {
 		return err
 	}
+	if err := es.deleteDeadLetters(ctx); err != nil {
+		return err
+	}
 	return es.checkSetStatus(ctx, apitypes.EventStreamStatusStopped, apitypes.EventStreamStatusDeleted)
 }
 
@@ -625,6 +799,10 @@ func (es *eventStream) processNewEvent(ctx context.Context, fev *ffcapi.Listener
 						// - Note this will block the confirmation manager when the event stream is blocked
 						es.batchChannel <- fev
 					},
+					RolledBack: func(ctx context.Context, removedBlock confirmations.BlockInfo) {
+						// Push a rollback of the event to the batch, so the application can reconcile
+						es.batchChannel <- &ffcapi.ListenerEvent{Event: fev.Event, Removed: true}
+					},
 				},
 			})
 			if err != nil {
@@ -673,6 +851,8 @@ func (es *eventStream) batchLoop(startedState *startedStreamState) {
 	defer close(startedState.batchLoopDone)
 	ctx := startedState.ctx
 	maxSize := int(*es.spec.BatchSize)
+	filter := newEventFilter(es.spec.Filter)
+	decoder, _ := newEventDecoder(ctx, es.spec.EventABI) // validated when the spec was merged
 	batchNumber := 0
 
 	var batch *eventStreamBatch
@@ -695,7 +875,7 @@ func (es *eventStream) batchLoop(startedState *startedStreamState) {
 				es.mux.Unlock()
 				if l != nil {
 					currentCheckpoint := l.checkpoint
-					if currentCheckpoint != nil && !currentCheckpoint.LessThan(fev.Checkpoint) {
+					if !fev.Removed && currentCheckpoint != nil && !currentCheckpoint.LessThan(fev.Checkpoint) {
 						// This event is behind the current checkpoint - this is a re-detection.
 						// We're perfectly happy to accept re-detections from the connector, as it can be
 						// very efficient to batch operations between listeners that cause re-detections.
@@ -718,17 +898,32 @@ func (es *eventStream) batchLoop(startedState *startedStreamState) {
 						batch.checkpoints[*fev.Event.ID.ListenerID] = fev.Checkpoint
 					}
 
-					log.L(es.bgCtx).Debugf("%s '%s' event confirmed: %s", l.spec.ID, l.spec.Signature, fev.Event)
-					batch.events = append(batch.events, &apitypes.EventWithContext{
-						StandardContext: apitypes.EventContext{
-							StreamID:       es.spec.ID,
-							EthCompatSubID: l.spec.ID,
-							ListenerName:   *l.spec.Name,
-						},
-						Event: *fev.Event,
-					})
+					if !filter.matches(fev.Event) {
+						// The checkpoint still moves forwards past events excluded by the filter
+						log.L(es.bgCtx).Debugf("%s '%s' event excluded by stream filter: %s", l.spec.ID, l.spec.Signature, fev.Event)
+						continue
+					}
+
+					if fev.Removed {
+						log.L(es.bgCtx).Warnf("%s '%s' event rolled back: %s", l.spec.ID, l.spec.Signature, fev.Event)
+					} else {
+						log.L(es.bgCtx).Debugf("%s '%s' event confirmed: %s", l.spec.ID, l.spec.Signature, fev.Event)
+					}
+					batch.events = append(batch.events, es.eventWithContext(ctx, l, fev, decoder))
 				}
 			}
+		case replay := <-startedState.replays:
+			// Replayed events are delivered in their own batches, and never affect the checkpoint
+			if err := es.deliverReplay(startedState, &batchNumber, replay, filter, decoder); err != nil {
+				log.L(ctx).Debugf("Batch loop exiting: %s", err)
+				return
+			}
+			continue
+		case dlr := <-startedState.deadLetterReplays:
+			// A single attempt is made to redeliver each dead letter, with the result passed back to the caller
+			batchNumber++
+			dlr.result <- startedState.action(ctx, batchNumber, 1, dlr.deadLetter.Events)
+			continue
 		case <-timeoutChannel:
 			timedOut = true
 			if batch == nil {
@@ -762,6 +957,27 @@ func (es *eventStream) batchLoop(startedState *startedStreamState) {
 	}
 }
 
+func (es *eventStream) eventWithContext(ctx context.Context, l *listener, fev *ffcapi.ListenerEvent, decoder *eventDecoder) *apitypes.EventWithContext {
+	ewc := &apitypes.EventWithContext{
+		StandardContext: apitypes.EventContext{
+			StreamID:       es.spec.ID,
+			EthCompatSubID: l.spec.ID,
+			ListenerName:   *l.spec.Name,
+			RolledBack:     fev.Removed,
+		},
+		Event: *fev.Event,
+	}
+	if decoder != nil {
+		// Events that cannot be decoded are still delivered, with the raw log only
+		var err error
+		if ewc.Decoded, err = decoder.decode(ctx, fev.Event); err != nil {
+			log.L(es.bgCtx).Warnf("%s '%s' event could not be decoded: %s", l.spec.ID, l.spec.Signature, err)
+			ewc.StandardContext.DecodeFailed = true
+		}
+	}
+	return ewc
+}
+
 // performActionWithRetry performs an action, with exponential back-off retry up
 // to a given threshold. Only returns error in the case that the context is closed.
 func (es *eventStream) performActionsWithRetry(startedState *startedStreamState, batch *eventStreamBatch) (err error) {
@@ -790,7 +1006,8 @@ func (es *eventStream) performActionsWithRetry(startedState *startedStreamState,
 		log.L(ctx).Errorf("Batch failed short retry after %.2fs secs. ErrorHandling=%s BlockedRetryDelay=%.2fs ",
 			time.Since(startTime).Seconds(), *es.spec.ErrorHandling, time.Duration(*es.spec.BlockedRetryDelay).Seconds())
 		if *es.spec.ErrorHandling == apitypes.ErrorHandlingTypeSkip {
-			// Swallow the error now we have logged it
+			// Swallow the error now we have logged it, keeping the batch so it can be replayed later
+			es.deadLetter(ctx, batch.events, err)
 			return nil
 		}
 		select {
@@ -802,6 +1019,101 @@ func (es *eventStream) performActionsWithRetry(startedState *startedStreamState,
 	}
 }
 
+func (es *eventStream) GetCheckpoint(ctx context.Context) (*apitypes.EventStreamCheckpoint, error) {
+	cp, err := es.persistence.GetCheckpoint(ctx, es.spec.ID)
+	if err != nil {
+		return nil, err
+	}
+	if cp == nil {
+		cp = &apitypes.EventStreamCheckpoint{
+			StreamID:  es.spec.ID,
+			Listeners: make(map[fftypes.UUID]json.RawMessage),
+		}
+	}
+	return cp, nil
+}
+
+// SetCheckpoint replaces the persisted checkpoint of each listener in the update, for example to resume
+// from a known position when rebuilding an environment. A checkpoint cannot be set ahead of the high water
+// mark the connector reports for the listener, and moving a listener backwards must be confirmed as it
+// will redeliver events.
+func (es *eventStream) SetCheckpoint(ctx context.Context, updates *apitypes.EventStreamCheckpoint, confirmRewind bool) (*apitypes.EventStreamCheckpoint, error) {
+	cp, err := es.GetCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate all the updates before we change anything
+	for lID, jsonCP := range updates.Listeners {
+		listenerID := lID
+		es.mux.Lock()
+		l := es.listeners[listenerID]
+		es.mux.Unlock()
+		if l == nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgResetStreamNotFound, &listenerID, es.spec.ID)
+		}
+		newCP := es.connector.EventStreamNewCheckpointStruct()
+		if err := json.Unmarshal(jsonCP, &newCP); err != nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidCheckpoint, &listenerID, err)
+		}
+		res, _, err := es.connector.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{
+			StreamID:   es.spec.ID,
+			ListenerID: &listenerID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if res.Checkpoint != nil && res.Checkpoint.LessThan(newCP) {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgCheckpointAheadOfHead, &listenerID)
+		}
+		if existingJSON := cp.Listeners[listenerID]; existingJSON != nil && !confirmRewind {
+			existingCP := es.connector.EventStreamNewCheckpointStruct()
+			if err := json.Unmarshal(existingJSON, &existingCP); err == nil && newCP.LessThan(existingCP) {
+				return nil, i18n.NewError(ctx, tmmsgs.MsgCheckpointRewindNotConfirmed, &listenerID)
+			}
+		}
+	}
+
+	// Only safe to replace the checkpoint with the event stream stopped
+	es.mux.Lock()
+	startedState := es.currentState
+	es.mux.Unlock()
+	if startedState != nil {
+		if err := es.Stop(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for lID, jsonCP := range updates.Listeners {
+		listenerID := lID
+		log.L(ctx).Warnf("Setting checkpoint for listener %s: %s", &listenerID, jsonCP)
+		cp.Listeners[listenerID] = jsonCP
+		if es.confirmations != nil {
+			// Discard any events in-flight in the confirmation manager, as they relate to the old checkpoint.
+			// This is processed when the confirmation manager is restarted, before any new events arrive.
+			_ = es.confirmations.Notify(&confirmations.Notification{
+				NotificationType: confirmations.ListenerRemoved,
+				RemovedListener: &confirmations.RemovedListenerInfo{
+					ListenerID: &listenerID,
+					Completed:  make(chan struct{}),
+				},
+			})
+		}
+	}
+	cp.Time = fftypes.Now()
+	if err := es.persistence.WriteCheckpoint(ctx, cp); err != nil {
+		return nil, err
+	}
+
+	// Restart if we were started
+	if startedState != nil {
+		if err := es.Start(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return cp, nil
+}
+
 func (es *eventStream) checkUpdateHWMCheckpoint(ctx context.Context, l *listener) ffcapi.EventListenerCheckpoint {
 
 	checkpoint := l.checkpoint
@@ -29,8 +29,10 @@ import (
 
 	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/blocklistener"
 	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/ws"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
@@ -79,6 +81,7 @@ func newTestEventStreamWithListener(t *testing.T, mfc *ffcapimocks.API, conf str
 		&persistencemocks.Persistence{},
 		&wsmocks.WebSocketChannels{},
 		listeners,
+		nil,
 	)
 	mfc.On("EventStreamNewCheckpointStruct").Return(&utCheckpointType{}).Maybe()
 	if err != nil {
@@ -114,6 +117,7 @@ func TestNewTestEventStreamMissingID(t *testing.T) {
 		&persistencemocks.Persistence{},
 		&wsmocks.WebSocketChannels{},
 		[]*apitypes.Listener{},
+		nil,
 	)
 	assert.Regexp(t, "FF21048", err)
 }
@@ -126,6 +130,7 @@ func TestNewTestEventStreamBadConfig(t *testing.T) {
 		&persistencemocks.Persistence{},
 		&wsmocks.WebSocketChannels{},
 		[]*apitypes.Listener{},
+		nil,
 	)
 	assert.Regexp(t, "FF21028", err)
 }
@@ -150,13 +155,17 @@ func TestConfigNewDefaultsUpdate(t *testing.T) {
 		"batchSize": 50,
 		"batchTimeout": "5s",
 		"blockedRetryDelay": "30s",
+		"confirmations": 20,
 		"errorHandling":"block",
 		"name":"test1",
+		"payloadEncoding":"json",
 		"retryTimeout":"30s",
 		"suspended":false,
 		"type":"websocket",
 		"websocket": {
-			"distributionMode":"load_balance"
+			"distributionMode":"load_balance",
+			"nackRedeliveryDelay":"5s",
+			"maxRedeliveries":0
 		}
 	}`, string(b))
 
@@ -191,8 +200,10 @@ func TestConfigNewDefaultsUpdate(t *testing.T) {
 		"batchSize": 111,
 		"batchTimeout": "222ms",
 		"blockedRetryDelay": "5m33s",
+		"confirmations": 20,
 		"errorHandling":"skip",
 		"name":"test2",
+		"payloadEncoding":"json",
 		"retryTimeout":"7m24s",
 		"suspended":true,
 		"type":"webhook",
@@ -261,6 +272,32 @@ func TestConfigNewBadType(t *testing.T) {
 
 }
 
+func TestConfigNewPayloadEncoding(t *testing.T) {
+	tmconfig.Reset()
+	InitDefaults()
+
+	es, _, err := mergeValidateEsConfig(context.Background(), nil, testESConf(t, `{
+		"name": "test",
+		"payloadEncoding": "msgpack"
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.PayloadEncodingMsgpack, *es.PayloadEncoding)
+
+	_, _, err = mergeValidateEsConfig(context.Background(), nil, testESConf(t, `{
+		"name": "test",
+		"payloadEncoding": "cbor"
+	}`))
+	assert.Regexp(t, "FF21173.*cbor", err)
+
+	_, _, err = mergeValidateEsConfig(context.Background(), nil, testESConf(t, `{
+		"name": "test",
+		"type": "sse",
+		"payloadEncoding": "msgpack"
+	}`))
+	assert.Regexp(t, "FF21174.*msgpack.*sse", err)
+
+}
+
 func TestConfigNewWebhookRetryMigration(t *testing.T) {
 	tmconfig.Reset()
 	InitDefaults()
@@ -789,6 +826,7 @@ func TestUpdateListenerAndDeleteStarted(t *testing.T) {
 	msp.On("GetCheckpoint", mock.Anything, es.spec.ID).Return(nil, nil)
 	msp.On("DeleteCheckpoint", mock.Anything, es.spec.ID).Return(fmt.Errorf("pop")).Once()
 	msp.On("DeleteCheckpoint", mock.Anything, es.spec.ID).Return(nil)
+	msp.On("ListStreamDeadLetters", mock.Anything, es.spec.ID, 0).Return([]*apitypes.DeadLetter{}, nil)
 
 	err := es.Start(es.bgCtx)
 	assert.NoError(t, err)
@@ -1137,6 +1175,7 @@ func TestResetListenerRestartFail(t *testing.T) {
 	}, nil)
 	msp.On("WriteCheckpoint", mock.Anything, mock.Anything).Return(nil)
 	msp.On("DeleteCheckpoint", mock.Anything, es.spec.ID).Return(nil)
+	msp.On("ListStreamDeadLetters", mock.Anything, es.spec.ID, 0).Return([]*apitypes.DeadLetter{}, nil)
 
 	err := es.Start(es.bgCtx)
 	assert.NoError(t, err)
@@ -1280,6 +1319,93 @@ func TestWebSocketBroadcastActionCloseDuringCheckpoint(t *testing.T) {
 	mfc.AssertExpectations(t)
 }
 
+func TestWebSocketNackRedeliversBeforeCheckpoint(t *testing.T) {
+
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"websocket": {
+			"nackRedeliveryDelay": "1ms"
+		}
+	}`)
+
+	l := &apitypes.Listener{
+		ID:        fftypes.NewUUID(),
+		Name:      strPtr("ut_listener"),
+		Filters:   []fftypes.JSONAny{`{"event":"definition1"}`},
+		Options:   fftypes.JSONAnyPtr(`{"option1":"value1"}`),
+		FromBlock: strPtr("12345"),
+	}
+
+	mfc := es.connector.(*ffcapimocks.API)
+
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil)
+
+	started := make(chan *ffcapi.EventStreamStartRequest, 1)
+	mfc.On("EventStreamStart", mock.Anything, mock.MatchedBy(func(r *ffcapi.EventStreamStartRequest) bool {
+		return r.ID.Equals(es.spec.ID)
+	})).Run(func(args mock.Arguments) {
+		started <- args[1].(*ffcapi.EventStreamStartRequest)
+	}).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("EventStreamStopped", mock.Anything, mock.MatchedBy(func(r *ffcapi.EventStreamStoppedRequest) bool {
+		return r.ID.Equals(es.spec.ID)
+	})).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil)
+
+	acked := false
+	checkpointed := make(chan struct{})
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("WriteCheckpoint", mock.Anything, mock.MatchedBy(func(cp *apitypes.EventStreamCheckpoint) bool {
+		return string(cp.Listeners[*l.ID]) == `{"someSequenceNumber":12345}`
+	})).Run(func(args mock.Arguments) {
+		// The checkpoint must only advance once the batch is acked
+		assert.True(t, acked)
+		close(checkpointed)
+	}).Return(nil).Once()
+	msp.On("GetCheckpoint", mock.Anything, mock.Anything).Return(nil, nil) // no existing checkpoint
+
+	senderChannel, _, receiverChannel := mockWSChannels(es.wsChannels.(*wsmocks.WebSocketChannels))
+
+	_, err := es.AddOrUpdateListener(es.bgCtx, l.ID, l, false)
+	assert.NoError(t, err)
+
+	err = es.Start(es.bgCtx)
+	assert.NoError(t, err)
+
+	r := <-started
+
+	r.EventStream <- &ffcapi.ListenerEvent{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 12345},
+		Event: &ffcapi.Event{
+			ID: ffcapi.EventID{
+				ListenerID:       l.ID,
+				BlockNumber:      42,
+				TransactionIndex: 13,
+				LogIndex:         1,
+			},
+			Data: fftypes.JSONAnyPtr(`{"k1":"v1"}`),
+			Info: &testInfo{
+				BlockNumber:      "42",
+				TransactionIndex: "13",
+				LogIndex:         "1",
+			},
+		},
+	}
+	batch1 := (<-senderChannel).([]*apitypes.EventWithContext)
+	assert.Len(t, batch1, 1)
+	receiverChannel <- &ws.UnackedError{Err: fmt.Errorf("pop"), Nack: true}
+
+	batch2 := (<-senderChannel).([]*apitypes.EventWithContext)
+	assert.Equal(t, batch1, batch2)
+	acked = true
+	receiverChannel <- nil
+
+	<-checkpointed
+	err = es.Stop(es.bgCtx)
+	assert.NoError(t, err)
+
+	mfc.AssertExpectations(t)
+	msp.AssertExpectations(t)
+}
+
 func TestActionRetryOk(t *testing.T) {
 
 	es := newTestEventStream(t, `{
@@ -1349,6 +1475,10 @@ func TestActionRetrySkip(t *testing.T) {
 
 	msp := es.persistence.(*persistencemocks.Persistence)
 	msp.On("GetCheckpoint", mock.Anything, mock.Anything).Return(nil, nil) // no existing checkpoint
+	msp.On("WriteDeadLetter", mock.Anything, mock.MatchedBy(func(dl *apitypes.DeadLetter) bool {
+		return dl.StreamID.Equals(es.spec.ID) && dl.Reason == "pop" && len(dl.Events) == 1
+	})).Return(nil)
+	msp.On("ListStreamDeadLetters", mock.Anything, es.spec.ID, 0).Return([]*apitypes.DeadLetter{}, nil)
 
 	err := es.Start(es.bgCtx)
 	assert.NoError(t, err)
@@ -1359,13 +1489,14 @@ func TestActionRetrySkip(t *testing.T) {
 	}
 	es.mux.Unlock()
 
-	// Skip behavior
+	// Skip behavior, with the batch dead-lettered
 	err = es.performActionsWithRetry(es.currentState, &eventStreamBatch{
 		events: []*apitypes.EventWithContext{
 			{StandardContext: apitypes.EventContext{StreamID: es.spec.ID}},
 		},
 	})
 	assert.NoError(t, err)
+	msp.AssertExpectations(t)
 
 	err = es.Stop(es.bgCtx)
 	assert.NoError(t, err)
@@ -1778,3 +1909,865 @@ func TestHWMCheckpointFail(t *testing.T) {
 	msp.AssertExpectations(t)
 	mcm.AssertExpectations(t)
 }
+
+func TestConfigConfirmationsExceedMax(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsMaxRequired, 10)
+	InitDefaults()
+	_, err := NewEventStream(context.Background(), testESConf(t, `{
+		"name": "ut_stream",
+		"confirmations": 11
+	}`),
+		&ffcapimocks.API{},
+		&persistencemocks.Persistence{},
+		&wsmocks.WebSocketChannels{},
+		[]*apitypes.Listener{},
+		nil,
+	)
+	assert.Regexp(t, "FF21075", err)
+}
+
+func TestConfigConfirmationsOverride(t *testing.T) {
+	tmconfig.Reset()
+	InitDefaults()
+
+	es, err := NewEventStream(context.Background(), testESConf(t, `{
+		"name": "ut_stream",
+		"confirmations": 0
+	}`),
+		&ffcapimocks.API{},
+		&persistencemocks.Persistence{},
+		&wsmocks.WebSocketChannels{},
+		[]*apitypes.Listener{},
+		nil,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), *es.Spec().Confirmations)
+	assert.Nil(t, es.(*eventStream).confirmations)
+
+	// Not started, so the update simply swaps in a new confirmation manager
+	err = es.UpdateSpec(context.Background(), testESConf(t, `{
+		"name": "ut_stream",
+		"confirmations": 5
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), *es.Spec().Confirmations)
+	assert.NotNil(t, es.(*eventStream).confirmations)
+
+	err = es.UpdateSpec(context.Background(), testESConf(t, `{
+		"name": "ut_stream",
+		"confirmations": 101
+	}`))
+	assert.Regexp(t, "FF21075", err)
+	assert.Equal(t, uint64(5), *es.Spec().Confirmations)
+}
+
+func TestConfigConfirmationStrategy(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsStrategiesFast, 0)
+	InitDefaults()
+
+	// The strategy takes precedence over the number of confirmations, so the stream needs no confirmation manager
+	es, err := NewEventStream(context.Background(), testESConf(t, `{
+		"name": "ut_stream",
+		"confirmations": 5,
+		"confirmationStrategy": "fast"
+	}`),
+		&ffcapimocks.API{},
+		&persistencemocks.Persistence{},
+		&wsmocks.WebSocketChannels{},
+		[]*apitypes.Listener{},
+		nil,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "fast", *es.Spec().ConfirmationStrategy)
+	assert.Nil(t, es.(*eventStream).confirmations)
+
+	// Waiting for finality always requires a confirmation manager
+	err = es.UpdateSpec(context.Background(), testESConf(t, `{
+		"confirmationStrategy": "finalized"
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "finalized", *es.Spec().ConfirmationStrategy)
+	assert.NotNil(t, es.(*eventStream).confirmations)
+
+	err = es.UpdateSpec(context.Background(), testESConf(t, `{
+		"confirmationStrategy": "slow"
+	}`))
+	assert.Regexp(t, "FF21153", err)
+	assert.Equal(t, "finalized", *es.Spec().ConfirmationStrategy)
+
+	// Clearing the strategy reverts to the number of confirmations
+	err = es.UpdateSpec(context.Background(), testESConf(t, `{
+		"confirmationStrategy": ""
+	}`))
+	assert.NoError(t, err)
+	assert.Nil(t, es.Spec().ConfirmationStrategy)
+	assert.Equal(t, uint64(5), *es.Spec().Confirmations)
+	assert.NotNil(t, es.(*eventStream).confirmations)
+}
+
+func TestRolledBackEventDeliveredBehindCheckpoint(t *testing.T) {
+
+	es := newTestEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+
+	listenerID := fftypes.NewUUID()
+	li := &listener{
+		spec:       &apitypes.Listener{ID: listenerID, Name: strPtr("listener1")},
+		checkpoint: &utCheckpointType{SomeSequenceNumber: 2000},
+	}
+	es.listeners[*li.spec.ID] = li
+
+	var rolledBack func(ctx context.Context, removedBlock confirmations.BlockInfo)
+	mcm := &confirmationsmocks.Manager{}
+	mcm.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewEventLog && n.Event.RolledBack != nil
+	})).Return(nil).Run(func(args mock.Arguments) {
+		rolledBack = args[0].(*confirmations.Notification).Event.RolledBack
+	})
+	mcm.On("CheckInFlight", listenerID).Return(true)
+	es.confirmations = mcm
+
+	es.processNewEvent(context.Background(), &ffcapi.ListenerEvent{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 1999},
+		Event:      &ffcapi.Event{ID: ffcapi.EventID{ListenerID: listenerID, BlockNumber: 1999}},
+	})
+	assert.NotNil(t, rolledBack)
+
+	ss := &startedStreamState{
+		batchLoopDone: make(chan struct{}),
+		action: func(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+			assert.Len(t, events, 1)
+			assert.True(t, events[0].StandardContext.RolledBack)
+			assert.Equal(t, uint64(1999), events[0].ID.BlockNumber.Uint64())
+			return nil
+		},
+	}
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("WriteCheckpoint", mock.Anything, mock.MatchedBy(func(cp *apitypes.EventStreamCheckpoint) bool {
+		// The rollback does not move the checkpoint backwards
+		return cp.StreamID.Equals(es.spec.ID) && bytes.Equal(cp.Listeners[*li.spec.ID], json.RawMessage(`{"someSequenceNumber":2000}`))
+	})).Return(nil).Run(func(args mock.Arguments) {
+		ss.cancelCtx()
+	})
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		es.batchLoop(ss)
+		wg.Done()
+	}()
+	rolledBack(context.Background(), confirmations.BlockInfo{BlockNumber: 1999, BlockHash: "0x12345"})
+	wg.Wait()
+
+	mcm.AssertExpectations(t)
+	msp.AssertExpectations(t)
+}
+
+func TestGetCheckpoint(t *testing.T) {
+
+	es := newTestEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+
+	listenerID := fftypes.NewUUID()
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("GetCheckpoint", mock.Anything, es.spec.ID).Return(nil, nil).Once()
+	msp.On("GetCheckpoint", mock.Anything, es.spec.ID).Return(&apitypes.EventStreamCheckpoint{
+		StreamID: es.spec.ID,
+		Listeners: map[fftypes.UUID]json.RawMessage{
+			*listenerID: json.RawMessage(`{"someSequenceNumber":2000}`),
+		},
+	}, nil).Once()
+	msp.On("GetCheckpoint", mock.Anything, es.spec.ID).Return(nil, fmt.Errorf("pop")).Once()
+
+	cp, err := es.GetCheckpoint(es.bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, es.spec.ID, cp.StreamID)
+	assert.Empty(t, cp.Listeners)
+
+	cp, err = es.GetCheckpoint(es.bgCtx)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"someSequenceNumber":2000}`, string(cp.Listeners[*listenerID]))
+
+	_, err = es.GetCheckpoint(es.bgCtx)
+	assert.Regexp(t, "pop", err)
+
+	msp.AssertExpectations(t)
+}
+
+func TestSetCheckpoint(t *testing.T) {
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("EventStreamNewCheckpointStruct").Return(func() ffcapi.EventListenerCheckpoint { return &utCheckpointType{} })
+	es, err := newTestEventStreamWithListener(t, mfc, `{
+		"name": "ut_stream"
+	}`)
+	assert.NoError(t, err)
+
+	l := &apitypes.Listener{
+		ID:      fftypes.NewUUID(),
+		Name:    strPtr("ut_listener"),
+		Filters: []fftypes.JSONAny{`{"event":"definition1"}`},
+	}
+	setTo := func(seq int64) *apitypes.EventStreamCheckpoint {
+		return &apitypes.EventStreamCheckpoint{
+			Listeners: map[fftypes.UUID]json.RawMessage{
+				*l.ID: json.RawMessage(fmt.Sprintf(`{"someSequenceNumber":%d}`, seq)),
+			},
+		}
+	}
+
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventListenerHWM", mock.Anything, mock.MatchedBy(func(r *ffcapi.EventListenerHWMRequest) bool {
+		return r.ListenerID.Equals(l.ID)
+	})).Return(&ffcapi.EventListenerHWMResponse{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 3000},
+	}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	restarted := make(chan *ffcapi.EventStreamStartRequest, 1)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		restarted <- args[1].(*ffcapi.EventStreamStartRequest)
+	}).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil)
+
+	mcm := &confirmationsmocks.Manager{}
+	es.confirmations = mcm
+	mcm.On("Start").Return(nil)
+	mcm.On("Stop").Return(nil)
+	mcm.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.ListenerRemoved && n.RemovedListener.ListenerID.Equals(l.ID)
+	})).Return(nil).Once()
+
+	persisted := setTo(2000)
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("GetCheckpoint", mock.Anything, es.spec.ID).Return(func(ctx context.Context, streamID *fftypes.UUID) *apitypes.EventStreamCheckpoint {
+		return persisted
+	}, nil)
+	msp.On("WriteCheckpoint", mock.Anything, mock.MatchedBy(func(cp *apitypes.EventStreamCheckpoint) bool {
+		return bytes.Equal(cp.Listeners[*l.ID], json.RawMessage(`{"someSequenceNumber":1500}`))
+	})).Run(func(args mock.Arguments) {
+		persisted = args[1].(*apitypes.EventStreamCheckpoint)
+	}).Return(nil).Once()
+
+	_, err = es.AddOrUpdateListener(es.bgCtx, l.ID, l, false)
+	assert.NoError(t, err)
+	err = es.Start(es.bgCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2000), es.listeners[*l.ID].checkpoint.(*utCheckpointType).SomeSequenceNumber)
+
+	// Cannot set ahead of the chain head
+	_, err = es.SetCheckpoint(es.bgCtx, setTo(3001), true)
+	assert.Regexp(t, "FF21087", err)
+
+	// Cannot rewind without confirmation
+	_, err = es.SetCheckpoint(es.bgCtx, setTo(1500), false)
+	assert.Regexp(t, "FF21088", err)
+
+	// Rewind with confirmation
+	cp, err := es.SetCheckpoint(es.bgCtx, setTo(1500), true)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"someSequenceNumber":1500}`, string(cp.Listeners[*l.ID]))
+
+	// The restart resumes from the new checkpoint
+	req := <-restarted
+	assert.Equal(t, int64(1500), req.InitialListeners[0].Checkpoint.(*utCheckpointType).SomeSequenceNumber)
+	assert.Equal(t, int64(1500), es.listeners[*l.ID].checkpoint.(*utCheckpointType).SomeSequenceNumber)
+	assert.Equal(t, apitypes.EventStreamStatusStarted, es.Status())
+
+	mfc.AssertExpectations(t)
+	mcm.AssertExpectations(t)
+	msp.AssertExpectations(t)
+}
+
+func TestSetCheckpointStoppedForward(t *testing.T) {
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("EventStreamNewCheckpointStruct").Return(func() ffcapi.EventListenerCheckpoint { return &utCheckpointType{} })
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil)
+	l := &apitypes.Listener{
+		ID:      fftypes.NewUUID(),
+		Name:    strPtr("ut_listener"),
+		Filters: []fftypes.JSONAny{`{"event":"definition1"}`},
+	}
+	es, err := newTestEventStreamWithListener(t, mfc, `{
+		"name": "ut_stream"
+	}`, l)
+	assert.NoError(t, err)
+	es.confirmations = nil
+
+	mfc.On("EventListenerHWM", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerHWMResponse{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 3000},
+	}, ffcapi.ErrorReason(""), nil)
+
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("GetCheckpoint", mock.Anything, es.spec.ID).Return(nil, nil)
+	msp.On("WriteCheckpoint", mock.Anything, mock.MatchedBy(func(cp *apitypes.EventStreamCheckpoint) bool {
+		return cp.StreamID.Equals(es.spec.ID) && bytes.Equal(cp.Listeners[*l.ID], json.RawMessage(`{"someSequenceNumber":3000}`))
+	})).Return(nil)
+
+	// Moving forwards to the head does not need confirmation, and does not start the stream
+	_, err = es.SetCheckpoint(es.bgCtx, &apitypes.EventStreamCheckpoint{
+		Listeners: map[fftypes.UUID]json.RawMessage{
+			*l.ID: json.RawMessage(`{"someSequenceNumber":3000}`),
+		},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.EventStreamStatusStopped, es.Status())
+
+	mfc.AssertExpectations(t)
+	msp.AssertExpectations(t)
+}
+
+func TestSetCheckpointFailures(t *testing.T) {
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("EventStreamNewCheckpointStruct").Return(func() ffcapi.EventListenerCheckpoint { return &utCheckpointType{} })
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil)
+	l := &apitypes.Listener{
+		ID:      fftypes.NewUUID(),
+		Name:    strPtr("ut_listener"),
+		Filters: []fftypes.JSONAny{`{"event":"definition1"}`},
+	}
+	es, err := newTestEventStreamWithListener(t, mfc, `{
+		"name": "ut_stream"
+	}`, l)
+	assert.NoError(t, err)
+	setTo := func(lID *fftypes.UUID, cp string) *apitypes.EventStreamCheckpoint {
+		return &apitypes.EventStreamCheckpoint{
+			Listeners: map[fftypes.UUID]json.RawMessage{*lID: json.RawMessage(cp)},
+		}
+	}
+
+	mfc.On("EventListenerHWM", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("hwm-pop")).Once()
+	mfc.On("EventListenerHWM", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerHWMResponse{}, ffcapi.ErrorReason(""), nil)
+
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("GetCheckpoint", mock.Anything, es.spec.ID).Return(nil, fmt.Errorf("get-pop")).Once()
+	msp.On("GetCheckpoint", mock.Anything, es.spec.ID).Return(nil, nil)
+	msp.On("WriteCheckpoint", mock.Anything, mock.Anything).Return(fmt.Errorf("write-pop"))
+
+	_, err = es.SetCheckpoint(es.bgCtx, setTo(l.ID, `{}`), false)
+	assert.Regexp(t, "get-pop", err)
+
+	_, err = es.SetCheckpoint(es.bgCtx, setTo(fftypes.NewUUID(), `{}`), false)
+	assert.Regexp(t, "FF21052", err)
+
+	_, err = es.SetCheckpoint(es.bgCtx, setTo(l.ID, `"not a checkpoint"`), false)
+	assert.Regexp(t, "FF21086", err)
+
+	_, err = es.SetCheckpoint(es.bgCtx, setTo(l.ID, `{}`), false)
+	assert.Regexp(t, "hwm-pop", err)
+
+	_, err = es.SetCheckpoint(es.bgCtx, setTo(l.ID, `{}`), false)
+	assert.Regexp(t, "write-pop", err)
+
+	mfc.AssertExpectations(t)
+	msp.AssertExpectations(t)
+}
+
+func TestBatchLoopFilterEvents(t *testing.T) {
+
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"batchTimeout": "50ms",
+		"filter": {
+			"addresses": ["0xAAAA"],
+			"signatures": ["Changed(uint256)"]
+		}
+	}`)
+
+	ss := &startedStreamState{
+		updates:       make(chan *ffcapi.ListenerEvent, 1),
+		batchLoopDone: make(chan struct{}),
+		action: func(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+			assert.Len(t, events, 1)
+			assert.Equal(t, uint64(2002), events[0].ID.BlockNumber.Uint64())
+			return nil
+		},
+	}
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+
+	listenerID := fftypes.NewUUID()
+	li := &listener{
+		spec: &apitypes.Listener{ID: listenerID, Name: strPtr("listener1")},
+	}
+	es.listeners[*li.spec.ID] = li
+
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("WriteCheckpoint", mock.Anything, mock.MatchedBy(func(cp *apitypes.EventStreamCheckpoint) bool {
+		// The checkpoint includes the filtered event after the delivered one
+		return cp.StreamID.Equals(es.spec.ID) && bytes.Equal(cp.Listeners[*li.spec.ID], json.RawMessage(`{"someSequenceNumber":2003}`))
+	})).Return(nil).Run(func(args mock.Arguments) {
+		ss.cancelCtx()
+	})
+
+	es.batchChannel <- &ffcapi.ListenerEvent{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 2001}, // wrong signature
+		Event: &ffcapi.Event{
+			ID:   ffcapi.EventID{ListenerID: listenerID, BlockNumber: 2001, Signature: "Other()"},
+			Info: map[string]interface{}{"address": "0xaaaa"},
+		},
+	}
+	es.batchChannel <- &ffcapi.ListenerEvent{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 2002}, // matches
+		Event: &ffcapi.Event{
+			ID:   ffcapi.EventID{ListenerID: listenerID, BlockNumber: 2002, Signature: "Changed(uint256)"},
+			Info: map[string]interface{}{"address": "0xaaaa"},
+		},
+	}
+	es.batchChannel <- &ffcapi.ListenerEvent{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 2003}, // wrong address
+		Event: &ffcapi.Event{
+			ID:   ffcapi.EventID{ListenerID: listenerID, BlockNumber: 2003, Signature: "Changed(uint256)"},
+			Info: map[string]interface{}{"address": "0xbbbb"},
+		},
+	}
+
+	// Queue all the events before starting, so they arrive in a single batch
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		es.batchLoop(ss)
+		wg.Done()
+	}()
+	wg.Wait()
+
+	msp.AssertExpectations(t)
+}
+
+func TestBatchLoopDecodeEvents(t *testing.T) {
+
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"batchTimeout": "50ms",
+		"eventABI": `+transferEventABI+`
+	}`)
+
+	ss := &startedStreamState{
+		updates:       make(chan *ffcapi.ListenerEvent, 1),
+		batchLoopDone: make(chan struct{}),
+		action: func(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+			assert.Len(t, events, 2)
+
+			// Decoded alongside the raw log
+			b, err := json.Marshal(events[0])
+			assert.NoError(t, err)
+			var delivered map[string]interface{}
+			err = json.Unmarshal(b, &delivered)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{
+				"from":  "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+				"to":    "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+				"value": "1000",
+			}, delivered["decoded"])
+			assert.NotNil(t, delivered["data"])
+			assert.Nil(t, delivered["decodeFailed"])
+
+			// Delivered raw with a flag
+			assert.Nil(t, events[1].Decoded)
+			assert.True(t, events[1].StandardContext.DecodeFailed)
+			assert.JSONEq(t, `{"k1":"v1"}`, events[1].Data.String())
+			return nil
+		},
+	}
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+
+	listenerID := fftypes.NewUUID()
+	li := &listener{
+		spec: &apitypes.Listener{ID: listenerID, Name: strPtr("listener1")},
+	}
+	es.listeners[*li.spec.ID] = li
+
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("WriteCheckpoint", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		ss.cancelCtx()
+	})
+
+	es.batchChannel <- &ffcapi.ListenerEvent{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 2001},
+		Event: &ffcapi.Event{
+			ID:   ffcapi.EventID{ListenerID: listenerID, BlockNumber: 2001},
+			Data: fftypes.JSONAnyPtr(sampleTransferLog),
+		},
+	}
+	es.batchChannel <- &ffcapi.ListenerEvent{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 2002},
+		Event: &ffcapi.Event{
+			ID:   ffcapi.EventID{ListenerID: listenerID, BlockNumber: 2002},
+			Data: fftypes.JSONAnyPtr(`{"k1":"v1"}`),
+		},
+	}
+
+	// Queue all the events before starting, so they arrive in a single batch
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		es.batchLoop(ss)
+		wg.Done()
+	}()
+	wg.Wait()
+
+	msp.AssertExpectations(t)
+}
+
+func TestEventABIUpdates(t *testing.T) {
+
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"eventABI": `+transferEventABI+`
+	}`)
+	assert.Len(t, es.spec.EventABI, 1)
+
+	_, _, err := mergeValidateEsConfig(context.Background(), es.spec, &apitypes.EventStream{
+		EventABI: []*apitypes.ABIEvent{{Name: "Bad", Inputs: []*apitypes.ABIParameter{{Type: "uint256[]"}}}},
+	})
+	assert.Regexp(t, "FF21108", err)
+
+	merged, changed, err := mergeValidateEsConfig(context.Background(), es.spec, &apitypes.EventStream{})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Len(t, merged.EventABI, 1)
+
+	merged, changed, err = mergeValidateEsConfig(context.Background(), es.spec, &apitypes.EventStream{
+		EventABI: []*apitypes.ABIEvent{},
+	})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Nil(t, merged.EventABI)
+
+}
+
+func TestSuspendResumeContinuesFromCheckpoint(t *testing.T) {
+
+	receivedWebhook := make(chan []*apitypes.EventWithContext, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var events []*apitypes.EventWithContext
+		err := json.NewDecoder(r.Body).Decode(&events)
+		assert.NoError(t, err)
+		receivedWebhook <- events
+	}))
+	defer s.Close()
+
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"type": "webhook",
+		"webhook": {
+			"url": "`+fmt.Sprintf("http://%s/test/path", s.Listener.Addr())+`"
+		}
+	}`)
+
+	l := &apitypes.Listener{
+		ID:      fftypes.NewUUID(),
+		Filters: []fftypes.JSONAny{`{"event":"definition1"}`},
+	}
+
+	mfc := es.connector.(*ffcapimocks.API)
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{
+		ResolvedSignature: "EventSig(uint256)",
+		ResolvedOptions:   *fftypes.JSONAnyPtr(`{}`),
+	}, ffcapi.ErrorReason(""), nil)
+	started := make(chan *ffcapi.EventStreamStartRequest, 2)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- args[1].(*ffcapi.EventStreamStartRequest)
+	}).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil)
+	mcm := es.confirmations.(*confirmationsmocks.Manager)
+	mcm.On("CheckInFlight", l.ID).Return(true).Maybe()
+
+	// Persistence holds the latest checkpoint
+	var cpMux sync.Mutex
+	var persistedCP *apitypes.EventStreamCheckpoint
+	checkpointed := make(chan struct{}, 10)
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("GetCheckpoint", mock.Anything, mock.Anything).Return(func(context.Context, *fftypes.UUID) *apitypes.EventStreamCheckpoint {
+		cpMux.Lock()
+		defer cpMux.Unlock()
+		return persistedCP
+	}, nil)
+	msp.On("WriteCheckpoint", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		cpMux.Lock()
+		defer cpMux.Unlock()
+		persistedCP = args[1].(*apitypes.EventStreamCheckpoint)
+		checkpointed <- struct{}{}
+	}).Return(nil)
+
+	_, err := es.AddOrUpdateListener(es.bgCtx, l.ID, l, false)
+	assert.NoError(t, err)
+
+	newEvent := func(seq int64) *ffcapi.ListenerEvent {
+		return &ffcapi.ListenerEvent{
+			Checkpoint: &utCheckpointType{SomeSequenceNumber: seq},
+			Event: &ffcapi.Event{
+				ID:   ffcapi.EventID{ListenerID: l.ID, BlockNumber: fftypes.FFuint64(seq)},
+				Data: fftypes.JSONAnyPtr(fmt.Sprintf(`{"seq":%d}`, seq)),
+			},
+		}
+	}
+
+	err = es.Start(es.bgCtx)
+	assert.NoError(t, err)
+	r1 := <-started
+	assert.Nil(t, r1.InitialListeners[0].Checkpoint)
+
+	r1.EventStream <- newEvent(100)
+	batch := <-receivedWebhook
+	assert.Len(t, batch, 1)
+	assert.Equal(t, uint64(100), batch[0].Event.ID.BlockNumber.Uint64())
+	<-checkpointed
+
+	// Suspend the stream - it is stopped and not restarted, so nothing more is delivered.
+	// No blocks are tracked while suspended, and a gap-free resume relies on the connector
+	// replaying events from the checkpoint alone.
+	truthy, falsy := true, false
+	err = es.UpdateSpec(es.bgCtx, &apitypes.EventStream{Suspended: &truthy})
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.EventStreamStatusStopped, es.Status())
+	<-r1.StreamContext.Done()
+	select {
+	case r1.EventStream <- newEvent(101):
+	default:
+	}
+	select {
+	case batch = <-receivedWebhook:
+		assert.Fail(t, "delivered while suspended", batch)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Resume - the connector is asked to start from the last delivered checkpoint
+	err = es.UpdateSpec(es.bgCtx, &apitypes.EventStream{Suspended: &falsy})
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.EventStreamStatusStopped, es.Status())
+	err = es.Start(es.bgCtx)
+	assert.NoError(t, err)
+	r2 := <-started
+	assert.Equal(t, int64(100), r2.InitialListeners[0].Checkpoint.(*utCheckpointType).SomeSequenceNumber)
+
+	// Redelivery of the checkpoint event is skipped, and we continue with the next event
+	r2.EventStream <- newEvent(100)
+	r2.EventStream <- newEvent(101)
+	batch = <-receivedWebhook
+	assert.Len(t, batch, 1)
+	assert.Equal(t, uint64(101), batch[0].Event.ID.BlockNumber.Uint64())
+
+	err = es.Stop(es.bgCtx)
+	assert.NoError(t, err)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestConfigBatchSizeInvalid(t *testing.T) {
+	_, err := newTestEventStreamWithListener(t, &ffcapimocks.API{}, `{
+		"name": "ut_stream",
+		"batchSize": 0
+	}`)
+	assert.Regexp(t, "FF21121", err)
+
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"batchSize": 1
+	}`)
+	assert.Equal(t, uint64(1), *es.spec.BatchSize)
+	err = es.UpdateSpec(context.Background(), testESConf(t, `{
+		"name": "ut_stream",
+		"batchSize": 0
+	}`))
+	assert.Regexp(t, "FF21121", err)
+	assert.Equal(t, uint64(1), *es.spec.BatchSize)
+}
+
+func runTestBatchLoop(t *testing.T, es *eventStream, eventCount int) [][]*apitypes.EventWithContext {
+	var batches [][]*apitypes.EventWithContext
+	ss := &startedStreamState{
+		updates:       make(chan *ffcapi.ListenerEvent, 1),
+		batchLoopDone: make(chan struct{}),
+		action: func(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+			batches = append(batches, events)
+			return nil
+		},
+	}
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+
+	listenerID := fftypes.NewUUID()
+	es.listeners[*listenerID] = &listener{
+		spec: &apitypes.Listener{ID: listenerID, Name: strPtr("listener1")},
+	}
+
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("WriteCheckpoint", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		// Stop after the first batch is delivered
+		ss.cancelCtx()
+	}).Once()
+
+	for i := 0; i < eventCount; i++ {
+		es.batchChannel <- &ffcapi.ListenerEvent{
+			Checkpoint: &utCheckpointType{SomeSequenceNumber: int64(i)},
+			Event: &ffcapi.Event{
+				ID: ffcapi.EventID{ListenerID: listenerID, BlockNumber: fftypes.FFuint64(i)},
+			},
+		}
+	}
+
+	es.batchLoop(ss)
+	msp.AssertExpectations(t)
+	return batches
+}
+
+func TestBatchLoopDispatchesOnStreamBatchSize(t *testing.T) {
+
+	// The timeout is long enough that only reaching the size can dispatch the batch
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"batchSize": 2,
+		"batchTimeout": "1h"
+	}`)
+
+	batches := runTestBatchLoop(t, es, 2)
+	assert.Len(t, batches, 1)
+	assert.Len(t, batches[0], 2)
+}
+
+func TestBatchLoopDispatchesOnStreamBatchTimeout(t *testing.T) {
+
+	// The size is never reached, so only the timeout can dispatch the batch
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"batchSize": 100,
+		"batchTimeout": "10ms"
+	}`)
+
+	batches := runTestBatchLoop(t, es, 3)
+	assert.Len(t, batches, 1)
+	assert.Len(t, batches[0], 3)
+}
+
+func newTestBlockListenerStream(t *testing.T, routers blocklistener.Routers, name string) (*eventStream, chan *ffcapi.BlockHashEvent) {
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"type": "sse"
+	}`)
+	es.blockListeners = routers
+	es.spec.BlockListener = strPtr(name)
+
+	blocks := make(chan *ffcapi.BlockHashEvent, 1)
+	mcm := es.confirmations.(*confirmationsmocks.Manager)
+	mcm.On("NewBlockHashes").Return((chan<- *ffcapi.BlockHashEvent)(blocks))
+
+	mfc := es.connector.(*ffcapimocks.API)
+	mfc.On("EventStreamStart", mock.Anything, mock.MatchedBy(func(r *ffcapi.EventStreamStartRequest) bool {
+		// The connector does not need to deliver blocks to the stream
+		return r.ID.Equals(es.spec.ID) && r.BlockListener == nil
+	})).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil)
+
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("GetCheckpoint", mock.Anything, mock.Anything).Return(nil, nil)
+	return es, blocks
+}
+
+func TestBlockListenersRouteToSelectedStreams(t *testing.T) {
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	routers := blocklistener.Routers{
+		"a": blocklistener.NewRouter("a", nil),
+		"b": blocklistener.NewRouter("b", nil),
+	}
+	blocksA, doneA := routers["a"].Start(ctx)
+	blocksB, doneB := routers["b"].Start(ctx)
+
+	es1, es1Blocks := newTestBlockListenerStream(t, routers, "a")
+	es2, es2Blocks := newTestBlockListenerStream(t, routers, "b")
+	es3, es3Blocks := newTestBlockListenerStream(t, routers, "a")
+	for _, es := range []*eventStream{es1, es2, es3} {
+		err := es.Start(context.Background())
+		assert.NoError(t, err)
+	}
+
+	blocksA <- &ffcapi.BlockHashEvent{BlockHashes: []string{"0x1111"}}
+	assert.Equal(t, []string{"0x1111"}, (<-es1Blocks).BlockHashes)
+	assert.Equal(t, []string{"0x1111"}, (<-es3Blocks).BlockHashes)
+
+	blocksB <- &ffcapi.BlockHashEvent{BlockHashes: []string{"0x2222"}}
+	assert.Equal(t, []string{"0x2222"}, (<-es2Blocks).BlockHashes)
+
+	// Stopped streams no longer receive blocks
+	err := es3.Stop(context.Background())
+	assert.NoError(t, err)
+	blocksA <- &ffcapi.BlockHashEvent{BlockHashes: []string{"0x3333"}}
+	assert.Equal(t, []string{"0x3333"}, (<-es1Blocks).BlockHashes)
+	assert.Empty(t, es3Blocks)
+
+	// Only the first listener delivered blocks to these streams
+	assert.Empty(t, es1Blocks)
+	assert.Empty(t, es2Blocks)
+
+	for _, es := range []*eventStream{es1, es2} {
+		err := es.Stop(context.Background())
+		assert.NoError(t, err)
+	}
+	cancelCtx()
+	<-doneA
+	<-doneB
+}
+
+func TestBlockListenerNotConfigured(t *testing.T) {
+	tmconfig.Reset()
+	InitDefaults()
+	_, err := NewEventStream(context.Background(), testESConf(t, `{
+		"name": "ut_stream",
+		"blockListener": "missing"
+	}`),
+		&ffcapimocks.API{},
+		&persistencemocks.Persistence{},
+		&wsmocks.WebSocketChannels{},
+		[]*apitypes.Listener{},
+		blocklistener.Routers{},
+	)
+	assert.Regexp(t, "FF21127.*missing", err)
+
+	es := newTestEventStream(t, `{"name": "ut_stream"}`)
+	err = es.UpdateSpec(context.Background(), &apitypes.EventStream{BlockListener: strPtr("missing")})
+	assert.Regexp(t, "FF21127.*missing", err)
+}
+
+func TestUpdateSpecBlockListener(t *testing.T) {
+	es := newTestEventStream(t, `{"name": "ut_stream"}`)
+	es.blockListeners = blocklistener.Routers{
+		"a": blocklistener.NewRouter("a", nil),
+		"b": blocklistener.NewRouter("b", nil),
+	}
+
+	merged, changed, err := mergeValidateEsConfig(context.Background(), es.spec, &apitypes.EventStream{BlockListener: strPtr("a")})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "a", *merged.BlockListener)
+
+	merged, changed, err = mergeValidateEsConfig(context.Background(), merged, &apitypes.EventStream{BlockListener: strPtr("a")})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "a", *merged.BlockListener)
+
+	merged, changed, err = mergeValidateEsConfig(context.Background(), merged, &apitypes.EventStream{BlockListener: strPtr("b")})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "b", *merged.BlockListener)
+
+	merged, changed, err = mergeValidateEsConfig(context.Background(), merged, &apitypes.EventStream{})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "b", *merged.BlockListener)
+
+	// An empty name returns the stream to receiving blocks from the connector
+	merged, changed, err = mergeValidateEsConfig(context.Background(), merged, &apitypes.EventStream{BlockListener: strPtr("")})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Nil(t, merged.BlockListener)
+
+	err = es.UpdateSpec(context.Background(), &apitypes.EventStream{BlockListener: strPtr("b")})
+	assert.NoError(t, err)
+	assert.Equal(t, "b", *es.Spec().BlockListener)
+}
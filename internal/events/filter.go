@@ -0,0 +1,89 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// eventFilter is the runtime form of the filter on an event stream, applied to confirmed
+// events in the batch loop before they are delivered to the WebSocket/Webhook action
+type eventFilter struct {
+	addresses  map[string]bool
+	signatures map[string]bool
+}
+
+func newEventFilter(spec *apitypes.EventStreamFilter) *eventFilter {
+	f := &eventFilter{
+		addresses:  make(map[string]bool),
+		signatures: make(map[string]bool),
+	}
+	if spec != nil {
+		for _, a := range spec.Addresses {
+			f.addresses[strings.ToLower(a)] = true
+		}
+		for _, s := range spec.Signatures {
+			f.signatures[s] = true
+		}
+	}
+	return f
+}
+
+func (f *eventFilter) matches(event *ffcapi.Event) bool {
+	if len(f.signatures) > 0 && !f.signatures[event.ID.Signature] {
+		return false
+	}
+	if len(f.addresses) > 0 && !f.addresses[strings.ToLower(eventAddress(event))] {
+		return false
+	}
+	return true
+}
+
+// eventAddress extracts the address of the emitting contract from the connector specific info
+func eventAddress(event *ffcapi.Event) string {
+	if event.Info == nil {
+		return ""
+	}
+	var info struct {
+		Address string `json:"address"`
+	}
+	b, err := json.Marshal(event.Info)
+	if err == nil {
+		_ = json.Unmarshal(b, &info)
+	}
+	return info.Address
+}
+
+// checkUpdateFilter merges a filter update, noting that an empty filter in the updates clears the filter
+func checkUpdateFilter(changed bool, merged **apitypes.EventStreamFilter, old *apitypes.EventStreamFilter, new *apitypes.EventStreamFilter) bool {
+	if new == nil {
+		*merged = old
+		return changed
+	}
+	if len(new.Addresses) == 0 && len(new.Signatures) == 0 {
+		*merged = nil
+	} else {
+		*merged = new
+	}
+	jsonOld, _ := json.Marshal(old)
+	jsonNew, _ := json.Marshal(*merged)
+	return changed || string(jsonOld) != string(jsonNew)
+}
@@ -0,0 +1,74 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"testing"
+
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFilterMatchAll(t *testing.T) {
+	f := newEventFilter(nil)
+	assert.True(t, f.matches(&ffcapi.Event{ID: ffcapi.EventID{Signature: "Changed()"}}))
+	f = newEventFilter(&apitypes.EventStreamFilter{})
+	assert.True(t, f.matches(&ffcapi.Event{}))
+}
+
+func TestEventFilterAddressesAndSignatures(t *testing.T) {
+	f := newEventFilter(&apitypes.EventStreamFilter{
+		Addresses:  []string{"0xAAAA"},
+		Signatures: []string{"Changed(uint256)"},
+	})
+	assert.True(t, f.matches(&ffcapi.Event{
+		ID:   ffcapi.EventID{Signature: "Changed(uint256)"},
+		Info: map[string]interface{}{"address": "0xaaaa"},
+	}))
+	assert.False(t, f.matches(&ffcapi.Event{
+		ID:   ffcapi.EventID{Signature: "Other()"},
+		Info: map[string]interface{}{"address": "0xaaaa"},
+	}))
+	assert.False(t, f.matches(&ffcapi.Event{
+		ID:   ffcapi.EventID{Signature: "Changed(uint256)"},
+		Info: map[string]interface{}{"address": "0xbbbb"},
+	}))
+	assert.False(t, f.matches(&ffcapi.Event{
+		ID: ffcapi.EventID{Signature: "Changed(uint256)"},
+	}))
+	assert.False(t, f.matches(&ffcapi.Event{
+		ID:   ffcapi.EventID{Signature: "Changed(uint256)"},
+		Info: map[bool]string{true: "unserializable"},
+	}))
+}
+
+func TestCheckUpdateFilter(t *testing.T) {
+	var merged *apitypes.EventStreamFilter
+	existing := &apitypes.EventStreamFilter{Signatures: []string{"Changed()"}}
+
+	assert.False(t, checkUpdateFilter(false, &merged, existing, nil))
+	assert.Equal(t, existing, merged)
+
+	assert.False(t, checkUpdateFilter(false, &merged, existing, &apitypes.EventStreamFilter{Signatures: []string{"Changed()"}}))
+
+	assert.True(t, checkUpdateFilter(false, &merged, existing, &apitypes.EventStreamFilter{Addresses: []string{"0xaaaa"}}))
+	assert.Equal(t, []string{"0xaaaa"}, merged.Addresses)
+
+	assert.True(t, checkUpdateFilter(false, &merged, existing, &apitypes.EventStreamFilter{}))
+	assert.Nil(t, merged)
+}
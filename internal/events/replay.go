@@ -0,0 +1,123 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Replay queries the connector for the events of the listeners in a historical block range, and queues them for
+// delivery to the stream out-of-band of the live events. The caller is responsible for validating the range, and
+// checking it is confirmed. Replayed events are marked as such, and the checkpoint of the stream is not affected.
+func (es *eventStream) Replay(ctx context.Context, req *apitypes.EventStreamReplay) (*apitypes.EventStreamReplayResult, error) {
+	replayAPI, ok := es.connector.(ffcapi.EventReplayAPI)
+	if !ok {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgEventReplayNotSupported)
+	}
+
+	es.mux.Lock()
+	status := es.status
+	startedState := es.currentState
+	listeners := make([]*listener, 0, len(es.listeners))
+	if len(req.Listeners) == 0 {
+		for _, l := range es.listeners {
+			listeners = append(listeners, l)
+		}
+	} else {
+		for _, listenerID := range req.Listeners {
+			l := es.listeners[*listenerID]
+			if l == nil {
+				es.mux.Unlock()
+				return nil, i18n.NewError(ctx, tmmsgs.MsgListenerNotFound, listenerID)
+			}
+			listeners = append(listeners, l)
+		}
+	}
+	es.mux.Unlock()
+	if status != apitypes.EventStreamStatusStarted {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgStreamStateError, status)
+	}
+
+	events := make(ffcapi.ListenerEvents, 0)
+	for _, l := range listeners {
+		res, _, err := replayAPI.EventListenerReplay(ctx, &ffcapi.EventListenerReplayRequest{
+			EventListenerOptions: listenerSpecToOptions(l.spec),
+			ListenerID:           l.spec.ID,
+			StreamID:             es.spec.ID,
+			FromBlock:            req.FromBlock.Uint64(),
+			ToBlock:              req.ToBlock.Uint64(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, res.Events...)
+	}
+	sort.Stable(events)
+	log.L(ctx).Infof("Replaying %d events from blocks %d-%d on stream %s", len(events), *req.FromBlock, *req.ToBlock, es)
+
+	select {
+	case startedState.replays <- events:
+	case <-startedState.ctx.Done():
+		return nil, i18n.NewError(ctx, tmmsgs.MsgStreamStateError, apitypes.EventStreamStatusStopping)
+	case <-ctx.Done():
+		return nil, i18n.NewError(ctx, i18n.MsgContextCanceled)
+	}
+	return &apitypes.EventStreamReplayResult{
+		StreamID:  es.spec.ID,
+		FromBlock: *req.FromBlock,
+		ToBlock:   *req.ToBlock,
+		Events:    len(events),
+	}, nil
+}
+
+// deliverReplay delivers replayed events in batches up to the batch size of the stream, from the batch loop.
+// Only returns an error if the context is closed.
+func (es *eventStream) deliverReplay(startedState *startedStreamState, batchNumber *int, replay ffcapi.ListenerEvents, filter *eventFilter, decoder *eventDecoder) error {
+	ctx := startedState.ctx
+	maxSize := int(*es.spec.BatchSize)
+	var batch *eventStreamBatch
+	for i, fev := range replay {
+		if fev.Event != nil && fev.Event.ID.ListenerID != nil && filter.matches(fev.Event) {
+			es.mux.Lock()
+			l := es.listeners[*fev.Event.ID.ListenerID]
+			es.mux.Unlock()
+			if l != nil {
+				if batch == nil {
+					*batchNumber++
+					batch = &eventStreamBatch{number: *batchNumber}
+				}
+				ewc := es.eventWithContext(ctx, l, fev, decoder)
+				ewc.StandardContext.Replayed = true
+				batch.events = append(batch.events, ewc)
+			}
+		}
+		if batch != nil && (len(batch.events) >= maxSize || i == len(replay)-1) {
+			if err := es.performActionsWithRetry(startedState, batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type replayConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.EventReplayAPI
+}
+
+func newTestReplayEventStream(t *testing.T, conf string) (*eventStream, *ffcapimocks.EventReplayAPI, *listener, *startedStreamState) {
+	mfc := &ffcapimocks.API{}
+	es, err := newTestEventStreamWithListener(t, mfc, conf)
+	assert.NoError(t, err)
+	mra := &ffcapimocks.EventReplayAPI{}
+	es.connector = &replayConnector{API: mfc, EventReplayAPI: mra}
+
+	listenerID := fftypes.NewUUID()
+	l := &listener{
+		es:         es,
+		spec:       &apitypes.Listener{ID: listenerID, Name: strPtr("listener1"), FromBlock: strPtr("0")},
+		checkpoint: &utCheckpointType{SomeSequenceNumber: 2000},
+	}
+	es.listeners[*listenerID] = l
+
+	ss := &startedStreamState{
+		batchLoopDone: make(chan struct{}),
+		replays:       make(chan ffcapi.ListenerEvents),
+	}
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+	es.status = apitypes.EventStreamStatusStarted
+	es.currentState = ss
+	return es, mra, l, ss
+}
+
+func replayBlocks(from, to uint64) *apitypes.EventStreamReplay {
+	fromBlock, toBlock := fftypes.FFuint64(from), fftypes.FFuint64(to)
+	return &apitypes.EventStreamReplay{FromBlock: &fromBlock, ToBlock: &toBlock}
+}
+
+func TestReplayDeliversEventsWithoutMovingCheckpoint(t *testing.T) {
+
+	es, mra, l, ss := newTestReplayEventStream(t, `{
+		"name": "ut_stream",
+		"batchSize": 2
+	}`)
+
+	batches := make(chan []*apitypes.EventWithContext, 2)
+	ss.action = func(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+		batches <- events
+		return nil
+	}
+
+	// The events are all behind the checkpoint, so would be skipped as re-detections if delivered live.
+	// They are returned out of order, and there is one for a listener no longer on the stream.
+	replayEvent := func(listenerID *fftypes.UUID, block uint64) *ffcapi.ListenerEvent {
+		return &ffcapi.ListenerEvent{
+			Checkpoint: &utCheckpointType{SomeSequenceNumber: int64(block)},
+			Event:      &ffcapi.Event{ID: ffcapi.EventID{ListenerID: listenerID, BlockNumber: fftypes.FFuint64(block)}},
+		}
+	}
+	mra.On("EventListenerReplay", mock.Anything, mock.MatchedBy(func(req *ffcapi.EventListenerReplayRequest) bool {
+		return req.ListenerID.Equals(l.spec.ID) && req.StreamID.Equals(es.spec.ID) &&
+			req.FromBlock == 1000 && req.ToBlock == 1010
+	})).Return(&ffcapi.EventListenerReplayResponse{
+		Events: ffcapi.ListenerEvents{
+			replayEvent(l.spec.ID, 1005),
+			replayEvent(l.spec.ID, 1001),
+			replayEvent(fftypes.NewUUID(), 1003),
+			replayEvent(l.spec.ID, 1010),
+		},
+	}, ffcapi.ErrorReason(""), nil)
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		es.batchLoop(ss)
+		wg.Done()
+	}()
+
+	res, err := es.Replay(context.Background(), replayBlocks(1000, 1010))
+	assert.NoError(t, err)
+	assert.Equal(t, es.spec.ID, res.StreamID)
+	assert.Equal(t, 4, res.Events)
+
+	batch1 := <-batches
+	assert.Len(t, batch1, 2)
+	assert.Equal(t, uint64(1001), batch1[0].ID.BlockNumber.Uint64())
+	assert.Equal(t, uint64(1005), batch1[1].ID.BlockNumber.Uint64())
+	batch2 := <-batches
+	assert.Len(t, batch2, 1)
+	assert.Equal(t, uint64(1010), batch2[0].ID.BlockNumber.Uint64())
+	for _, ewc := range append(batch1, batch2...) {
+		assert.True(t, ewc.StandardContext.Replayed)
+		assert.Equal(t, l.spec.ID, ewc.StandardContext.EthCompatSubID)
+	}
+
+	ss.cancelCtx()
+	wg.Wait()
+
+	// The live checkpoint is unchanged, and was not written (there is no persistence mock for it)
+	assert.Equal(t, int64(2000), l.checkpoint.(*utCheckpointType).SomeSequenceNumber)
+	mra.AssertExpectations(t)
+}
+
+func TestReplayFilteredListeners(t *testing.T) {
+
+	es, mra, l, ss := newTestReplayEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+	es.listeners[*fftypes.NewUUID()] = &listener{
+		spec: &apitypes.Listener{ID: fftypes.NewUUID(), Name: strPtr("listener2"), FromBlock: strPtr("0")},
+	}
+
+	mra.On("EventListenerReplay", mock.Anything, mock.MatchedBy(func(req *ffcapi.EventListenerReplayRequest) bool {
+		return req.ListenerID.Equals(l.spec.ID)
+	})).Return(&ffcapi.EventListenerReplayResponse{}, ffcapi.ErrorReason(""), nil).Once()
+
+	go func() {
+		<-ss.replays
+	}()
+	req := replayBlocks(1000, 1000)
+	req.Listeners = []*fftypes.UUID{l.spec.ID}
+	res, err := es.Replay(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Zero(t, res.Events)
+
+	mra.AssertExpectations(t)
+}
+
+func TestReplayNotSupported(t *testing.T) {
+	es := newTestEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+	_, err := es.Replay(context.Background(), replayBlocks(1000, 1010))
+	assert.Regexp(t, "FF21122", err)
+}
+
+func TestReplayListenerNotFound(t *testing.T) {
+	es, _, _, _ := newTestReplayEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+	req := replayBlocks(1000, 1010)
+	req.Listeners = []*fftypes.UUID{fftypes.NewUUID()}
+	_, err := es.Replay(context.Background(), req)
+	assert.Regexp(t, "FF21046", err)
+}
+
+func TestReplayStreamNotStarted(t *testing.T) {
+	es, _, _, _ := newTestReplayEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+	es.status = apitypes.EventStreamStatusStopped
+	es.currentState = nil
+	_, err := es.Replay(context.Background(), replayBlocks(1000, 1010))
+	assert.Regexp(t, "FF21027", err)
+}
+
+func TestReplayConnectorFail(t *testing.T) {
+	es, mra, _, _ := newTestReplayEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+	mra.On("EventListenerReplay", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	_, err := es.Replay(context.Background(), replayBlocks(1000, 1010))
+	assert.Regexp(t, "pop", err)
+}
+
+func TestReplayStreamStopping(t *testing.T) {
+	es, mra, _, ss := newTestReplayEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+	mra.On("EventListenerReplay", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerReplayResponse{}, ffcapi.ErrorReason(""), nil)
+	ss.cancelCtx()
+	_, err := es.Replay(context.Background(), replayBlocks(1000, 1010))
+	assert.Regexp(t, "FF21027", err)
+}
+
+func TestReplayContextCancelled(t *testing.T) {
+	es, mra, _, _ := newTestReplayEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+	mra.On("EventListenerReplay", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerReplayResponse{}, ffcapi.ErrorReason(""), nil)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	cancelCtx()
+	_, err := es.Replay(ctx, replayBlocks(1000, 1010))
+	assert.Regexp(t, "FF00154", err)
+}
+
+func TestReplayBatchLoopExitsOnStopDuringDelivery(t *testing.T) {
+	es, _, l, ss := newTestReplayEventStream(t, `{
+		"name": "ut_stream",
+		"errorHandling": "block"
+	}`)
+	ss.action = func(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+		ss.cancelCtx()
+		return fmt.Errorf("pop")
+	}
+
+	go func() {
+		ss.replays <- ffcapi.ListenerEvents{
+			{
+				Checkpoint: &utCheckpointType{SomeSequenceNumber: 1000},
+				Event:      &ffcapi.Event{ID: ffcapi.EventID{ListenerID: l.spec.ID, BlockNumber: 1000}},
+			},
+		}
+	}()
+	es.batchLoop(ss)
+}
@@ -0,0 +1,179 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// sseBatch is a batch handed from the batch loop of the stream, to a connected Server-Sent Events client
+type sseBatch struct {
+	number int
+	events []*apitypes.EventWithContext
+	result chan error // receives nil once the batch is acknowledged, or an error if it was not delivered
+}
+
+// sseAction delivers batches to Server-Sent Events clients. It lives for the lifetime of the stream,
+// rather than each time it is started, as clients connect independently of the stream starting.
+type sseAction struct {
+	batches     chan *sseBatch
+	mux         sync.Mutex
+	awaitingAck *sseBatch // batch delivered to a manual ack client, that has not been acknowledged yet
+}
+
+func newSSEAction() *sseAction {
+	return &sseAction{
+		batches: make(chan *sseBatch),
+	}
+}
+
+// attemptBatch waits for a connected client to take the batch, then for it to be acknowledged. The
+// checkpoint of the stream only advances once this returns without error, so a batch that is not
+// acknowledged is redelivered to the next client.
+func (s *sseAction) attemptBatch(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+	batch := &sseBatch{
+		number: batchNumber,
+		events: events,
+		result: make(chan error, 1),
+	}
+	defer s.clearAwaitingAck(batch)
+
+	select {
+	case s.batches <- batch:
+	case <-ctx.Done():
+		return i18n.NewError(ctx, tmmsgs.MsgSSEInterrupted)
+	}
+	select {
+	case err := <-batch.result:
+		log.L(ctx).Infof("SSE event batch %d complete (len=%d). err=%v", batchNumber, len(events), err)
+		return err
+	case <-ctx.Done():
+		return i18n.NewError(ctx, tmmsgs.MsgSSEInterrupted)
+	}
+}
+
+func (s *sseAction) clearAwaitingAck(batch *sseBatch) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.awaitingAck != batch {
+		return false
+	}
+	s.awaitingAck = nil
+	return true
+}
+
+// serve writes batches to the client as they become available, with a heartbeat comment at the configured
+// interval while there is nothing to deliver. A batch is acknowledged as soon as it has been written to the
+// client, unless manualAck is set, in which case the client must acknowledge each batch by number.
+func (s *sseAction) serve(ctx context.Context, res http.ResponseWriter, manualAck bool, heartbeatInterval time.Duration) error {
+	flusher, _ := res.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	flush()
+
+	var unacked *sseBatch
+	defer func() {
+		// A batch that was delivered, but not acknowledged, must be redelivered
+		if unacked != nil && s.clearAwaitingAck(unacked) {
+			unacked.result <- i18n.NewError(ctx, tmmsgs.MsgSSEClientDisconnected, unacked.number)
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case batch := <-s.batches:
+			if err := writeSSEBatch(res, batch); err != nil {
+				batch.result <- err
+				return err
+			}
+			flush()
+			if manualAck {
+				s.mux.Lock()
+				s.awaitingAck = batch
+				s.mux.Unlock()
+				unacked = batch
+			} else {
+				batch.result <- nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+			flush()
+		case <-ctx.Done():
+			log.L(ctx).Debugf("SSE client disconnected")
+			return nil
+		}
+	}
+}
+
+func writeSSEBatch(res http.ResponseWriter, batch *sseBatch) error {
+	data, err := json.Marshal(batch.events)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(res, "id: %d\nevent: batch\ndata: %s\n\n", batch.number, data)
+	return err
+}
+
+// ack acknowledges the batch awaiting acknowledgement from a manual ack client, if it has the given number
+func (s *sseAction) ack(batchNumber int) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.awaitingAck == nil || s.awaitingAck.number != batchNumber {
+		return false
+	}
+	s.awaitingAck.result <- nil
+	s.awaitingAck = nil
+	return true
+}
+
+func (es *eventStream) ServeSSE(ctx context.Context, res http.ResponseWriter, manualAck bool) error {
+	if *es.spec.Type != apitypes.EventStreamTypeSSE {
+		return i18n.NewError(ctx, tmmsgs.MsgStreamNotSSE, es.spec.ID)
+	}
+	return es.sse.serve(ctx, res, manualAck, esDefaults.sseHeartbeatInterval)
+}
+
+func (es *eventStream) AckSSE(ctx context.Context, batchNumber int) error {
+	if *es.spec.Type != apitypes.EventStreamTypeSSE {
+		return i18n.NewError(ctx, tmmsgs.MsgStreamNotSSE, es.spec.ID)
+	}
+	if !es.sse.ack(batchNumber) {
+		return i18n.NewError(ctx, tmmsgs.MsgSSEBatchNotAwaitingAck, batchNumber, es.spec.ID)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSSEMessage struct {
+	id    string
+	event string
+	data  string
+}
+
+func newTestSSEServer(t *testing.T, es *eventStream) (url string, done func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		err := es.ServeSSE(req.Context(), res, req.URL.Query().Get("ack") == "manual")
+		assert.NoError(t, err)
+	}))
+	return server.URL, server.Close
+}
+
+// connectSSE returns a channel of the messages received, excluding comments, and a function to disconnect
+func connectSSE(t *testing.T, url string) (<-chan *testSSEMessage, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	assert.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	messages := make(chan *testSSEMessage, 10)
+	go func() {
+		defer close(messages)
+		reader := bufio.NewReader(res.Body)
+		msg := &testSSEMessage{}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				if msg.event != "" {
+					messages <- msg
+				}
+				msg = &testSSEMessage{}
+			case strings.HasPrefix(line, "id: "):
+				msg.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				msg.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				msg.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return messages, func() {
+		cancel()
+		res.Body.Close()
+	}
+}
+
+func testSSEEvents(batchNumber int) []*apitypes.EventWithContext {
+	events := make([]*apitypes.EventWithContext, 2)
+	for i := range events {
+		events[i] = &apitypes.EventWithContext{
+			Event: ffcapi.Event{
+				ID: ffcapi.EventID{
+					BlockNumber: fftypes.FFuint64(batchNumber),
+					LogIndex:    fftypes.FFuint64(i),
+				},
+				Data: fftypes.JSONAnyPtr(fmt.Sprintf(`{"batch":%d,"index":%d}`, batchNumber, i)),
+			},
+		}
+	}
+	return events
+}
+
+func newTestSSEEventStream(t *testing.T) *eventStream {
+	return newTestEventStream(t, `{
+		"name": "ut_stream",
+		"type": "sse"
+	}`)
+}
+
+func TestSSEDeliversBatchesInOrder(t *testing.T) {
+	es := newTestSSEEventStream(t)
+	url, done := newTestSSEServer(t, es)
+	defer done()
+
+	messages, disconnect := connectSSE(t, url)
+	defer disconnect()
+
+	// Each batch is acknowledged once written, so the batch loop moves on to the next
+	for batchNumber := 1; batchNumber <= 3; batchNumber++ {
+		err := es.sse.attemptBatch(context.Background(), batchNumber, 0, testSSEEvents(batchNumber))
+		assert.NoError(t, err)
+	}
+
+	for batchNumber := 1; batchNumber <= 3; batchNumber++ {
+		msg := <-messages
+		assert.Equal(t, fmt.Sprintf("%d", batchNumber), msg.id)
+		assert.Equal(t, "batch", msg.event)
+		var events []map[string]interface{}
+		err := json.Unmarshal([]byte(msg.data), &events)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		for i, e := range events {
+			assert.Equal(t, map[string]interface{}{"batch": float64(batchNumber), "index": float64(i)}, e["data"])
+		}
+	}
+}
+
+func TestSSEManualAck(t *testing.T) {
+	es := newTestSSEEventStream(t)
+	url, done := newTestSSEServer(t, es)
+	defer done()
+
+	messages, disconnect := connectSSE(t, url+"?ack=manual")
+	defer disconnect()
+
+	result := make(chan error)
+	go func() {
+		result <- es.sse.attemptBatch(context.Background(), 1, 0, testSSEEvents(1))
+	}()
+	msg := <-messages
+	assert.Equal(t, "1", msg.id)
+
+	// Batch is not complete until it is acknowledged, with the right batch number
+	err := es.AckSSE(context.Background(), 2)
+	assert.Regexp(t, "FF21103", err)
+	select {
+	case <-result:
+		assert.Fail(t, "batch completed without an ack")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	err = es.AckSSE(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.NoError(t, <-result)
+
+	// Cannot ack twice
+	err = es.AckSSE(context.Background(), 1)
+	assert.Regexp(t, "FF21103", err)
+}
+
+func TestSSEDisconnectBeforeAckRedelivers(t *testing.T) {
+	es := newTestSSEEventStream(t)
+	url, done := newTestSSEServer(t, es)
+	defer done()
+
+	messages, disconnect := connectSSE(t, url+"?ack=manual")
+
+	result := make(chan error)
+	go func() {
+		result <- es.sse.attemptBatch(context.Background(), 1, 0, testSSEEvents(1))
+	}()
+	<-messages
+	disconnect()
+	assert.Regexp(t, "FF21104", <-result)
+
+	// The retry of the batch is delivered to the next client
+	messages, disconnect = connectSSE(t, url)
+	defer disconnect()
+	err := es.sse.attemptBatch(context.Background(), 1, 1, testSSEEvents(1))
+	assert.NoError(t, err)
+	msg := <-messages
+	assert.Equal(t, "1", msg.id)
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	es := newTestSSEEventStream(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res := httptest.NewRecorder()
+	served := make(chan error)
+	go func() {
+		served <- es.sse.serve(ctx, res, false, 1*time.Millisecond)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.NoError(t, <-served)
+	assert.Contains(t, res.Body.String(), ": heartbeat\n\n")
+}
+
+func TestSSEAttemptBatchInterrupted(t *testing.T) {
+	es := newTestSSEEventStream(t)
+
+	// No client connected
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := es.sse.attemptBatch(ctx, 1, 0, testSSEEvents(1))
+	assert.Regexp(t, "FF21101", err)
+
+	// Client connected, but never acknowledges
+	res := httptest.NewRecorder()
+	go func() {
+		_ = es.sse.serve(context.Background(), res, true, 1*time.Hour)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = es.sse.attemptBatch(ctx, 1, 0, testSSEEvents(1))
+	assert.Regexp(t, "FF21101", err)
+}
+
+func TestSSENotSSEStream(t *testing.T) {
+	es := newTestEventStream(t, `{
+		"name": "ut_stream",
+		"websocket": {
+			"topic": "ut_stream"
+		}
+	}`)
+	err := es.ServeSSE(context.Background(), httptest.NewRecorder(), false)
+	assert.Regexp(t, "FF21102", err)
+	err = es.AckSSE(context.Background(), 1)
+	assert.Regexp(t, "FF21102", err)
+}
+
+func TestSSEInitAction(t *testing.T) {
+	es := newTestSSEEventStream(t)
+	assert.Equal(t, apitypes.EventStreamTypeSSE, *es.spec.Type)
+	ss := &startedStreamState{ctx: context.Background()}
+	es.initAction(ss)
+	assert.NotNil(t, ss.action)
+}
@@ -70,10 +70,11 @@ func mergeValidateWhConfig(ctx context.Context, changed bool, base *apitypes.Web
 type webhookAction struct {
 	allowPrivateIPs bool
 	spec            *apitypes.WebhookConfig
+	encoding        apitypes.PayloadEncoding
 	client          *resty.Client
 }
 
-func newWebhookAction(bgCtx context.Context, spec *apitypes.WebhookConfig) *webhookAction {
+func newWebhookAction(bgCtx context.Context, spec *apitypes.WebhookConfig, encoding apitypes.PayloadEncoding) *webhookAction {
 	client := ffresty.New(bgCtx, tmconfig.WebhookPrefix)   // majority of settings come from config
 	client.SetTimeout(time.Duration(*spec.RequestTimeout)) // request timeout set per stream
 	if *spec.TLSkipHostVerify {
@@ -84,6 +85,7 @@ func newWebhookAction(bgCtx context.Context, spec *apitypes.WebhookConfig) *webh
 
 	return &webhookAction{
 		spec:            spec,
+		encoding:        encoding,
 		allowPrivateIPs: config.GetBool(tmconfig.WebhooksAllowPrivateIPs),
 		client:          client,
 	}
@@ -93,20 +95,20 @@ func newWebhookAction(bgCtx context.Context, spec *apitypes.WebhookConfig) *webh
 func (w *webhookAction) attemptBatch(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
 	// We perform DNS resolution before each attempt, to exclude private IP address ranges from the target
 	u, _ := url.Parse(*w.spec.URL)
-	addr, err := net.ResolveIPAddr("ip4", u.Hostname())
-	if err != nil {
-		return i18n.NewError(ctx, tmmsgs.MsgInvalidHost, u.Hostname())
+	if err := CheckWebhookHost(ctx, w.allowPrivateIPs, u); err != nil {
+		return err
 	}
-	if w.isAddressBlocked(addr) {
-		return i18n.NewError(ctx, tmmsgs.MsgBlockWebhookAddress, addr, u.Hostname())
+	body, err := apitypes.MarshalPayload(w.encoding, events)
+	if err != nil {
+		return err
 	}
 	var resBody []byte
 	req := w.client.R().
 		SetContext(ctx).
-		SetBody(events).
+		SetBody(body).
 		SetResult(&resBody).
 		SetError(&resBody)
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", apitypes.PayloadContentType(w.encoding))
 	for h, v := range w.spec.Headers {
 		req.Header.Set(h, v)
 	}
@@ -122,10 +124,24 @@ func (w *webhookAction) attemptBatch(ctx context.Context, batchNumber, attempt i
 	return err
 }
 
+// CheckWebhookHost resolves the host of a webhook URL, and checks it is not in one of the
+// private address blocks (unless private IPs are allowed). Used by other components that
+// POST to user supplied URLs, as well as event stream webhooks.
+func CheckWebhookHost(ctx context.Context, allowPrivateIPs bool, u *url.URL) error {
+	addr, err := net.ResolveIPAddr("ip4", u.Hostname())
+	if err != nil {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidHost, u.Hostname())
+	}
+	if isAddressBlocked(allowPrivateIPs, addr) {
+		return i18n.NewError(ctx, tmmsgs.MsgBlockWebhookAddress, addr, u.Hostname())
+	}
+	return nil
+}
+
 // isAddressBlocked allows blocking of all of the "private" address blocks defined by IPv4
-func (w *webhookAction) isAddressBlocked(ip *net.IPAddr) bool {
+func isAddressBlocked(allowPrivateIPs bool, ip *net.IPAddr) bool {
 	ip4 := ip.IP.To4()
-	return !w.allowPrivateIPs &&
+	return !allowPrivateIPs &&
 		(ip4[0] == 0 ||
 			ip4[0] >= 224 ||
 			ip4[0] == 127 ||
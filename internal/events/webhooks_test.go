@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -28,6 +29,7 @@ import (
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -39,7 +41,7 @@ func newTestWebhooks(url string) *webhookAction {
 		TLSkipHostVerify: &truthy,
 		URL:              &url,
 		RequestTimeout:   (*fftypes.FFDuration)(&oneSec),
-	})
+	}, apitypes.PayloadEncodingJSON)
 }
 
 func TestWebhooksBadHost(t *testing.T) {
@@ -88,6 +90,40 @@ func TestWebhooksCustomHeaders403(t *testing.T) {
 	<-done
 }
 
+func TestWebhooksMsgpack(t *testing.T) {
+
+	events := []*apitypes.EventWithContext{
+		{
+			StandardContext: apitypes.EventContext{ListenerName: "listener1"},
+			Event: ffcapi.Event{
+				ID:   ffcapi.EventID{BlockNumber: 12345, TransactionHash: "0x12345"},
+				Data: fftypes.JSONAnyPtr(`{"value":"1000"}`),
+			},
+		},
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/msgpack", r.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var received []map[string]interface{}
+		err = apitypes.UnmarshalPayload(apitypes.PayloadEncodingMsgpack, body, &received)
+		assert.NoError(t, err)
+		assert.Len(t, received, 1)
+		assert.Equal(t, "listener1", received[0]["listenerName"])
+		assert.Equal(t, "12345", received[0]["blockNumber"])
+		assert.Equal(t, map[string]interface{}{"value": "1000"}, received[0]["data"])
+		w.WriteHeader(204)
+	}))
+	defer s.Close()
+
+	tmconfig.Reset()
+	ws := newTestWebhooks(fmt.Sprintf("http://%s/test/path", s.Listener.Addr()))
+	ws.encoding = apitypes.PayloadEncodingMsgpack
+
+	err := ws.attemptBatch(context.Background(), 0, 0, events)
+	assert.NoError(t, err)
+}
+
 func TestWebhooksCustomHeadersConnectFail(t *testing.T) {
 
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
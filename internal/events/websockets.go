@@ -18,6 +18,8 @@ package events
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
@@ -47,25 +49,77 @@ func mergeValidateWsConfig(ctx context.Context, changed bool, base *apitypes.Web
 		return nil, false, i18n.NewError(ctx, tmmsgs.MsgInvalidDistributionMode, *merged.DistributionMode)
 	}
 
+	// Redelivery of batches that are nacked, or in-flight on a connection that closes
+	changed = apitypes.CheckUpdateDuration(changed, &merged.NackRedeliveryDelay, base.NackRedeliveryDelay, updates.NackRedeliveryDelay, esDefaults.websocketNackDelay)
+	changed = apitypes.CheckUpdateUint64(changed, &merged.MaxRedeliveries, base.MaxRedeliveries, updates.MaxRedeliveries, esDefaults.websocketMaxRedeliveries)
+
 	return merged, changed, nil
 }
 
 type webSocketAction struct {
-	topic      string
-	spec       *apitypes.WebSocketConfig
-	wsChannels ws.WebSocketChannels
+	topic        string
+	spec         *apitypes.WebSocketConfig
+	encoding     apitypes.PayloadEncoding
+	wsChannels   ws.WebSocketChannels
+	batchNumber  int // the batch the redeliveries count applies to
+	redeliveries int
+	deadLetter   func(ctx context.Context, events []*apitypes.EventWithContext, reason error) // stores batches that exceed the redelivery limit
 }
 
-func newWebSocketAction(wsChannels ws.WebSocketChannels, spec *apitypes.WebSocketConfig, topic string) *webSocketAction {
+func newWebSocketAction(wsChannels ws.WebSocketChannels, spec *apitypes.WebSocketConfig, encoding apitypes.PayloadEncoding, topic string) *webSocketAction {
 	return &webSocketAction{
 		spec:       spec,
+		encoding:   encoding,
 		wsChannels: wsChannels,
 		topic:      topic,
 	}
 }
 
-// attemptBatch attempts to deliver a batch over socket IO
+// attemptBatch attempts to deliver a batch over socket IO, redelivering it if the client nacks it, or
+// disconnects before acknowledging it. Once the redelivery limit is reached the batch is dead-lettered.
 func (w *webSocketAction) attemptBatch(ctx context.Context, batchNumber, attempt int, events []*apitypes.EventWithContext) error {
+	if batchNumber != w.batchNumber {
+		w.batchNumber = batchNumber
+		w.redeliveries = 0
+	}
+	for {
+		err := w.deliverBatch(ctx, batchNumber, events)
+		var unacked *ws.UnackedError
+		if !errors.As(err, &unacked) {
+			return err
+		}
+
+		w.redeliveries++
+		if *w.spec.MaxRedeliveries > 0 && uint64(w.redeliveries) > *w.spec.MaxRedeliveries {
+			w.skipBatch(ctx, batchNumber, events, err)
+			return nil
+		}
+		if unacked.Nack {
+			log.L(ctx).Warnf("WebSocket event batch %d rejected. Redelivery %d in %s: %s", batchNumber, w.redeliveries, w.spec.NackRedeliveryDelay, err)
+			select {
+			case <-time.After(time.Duration(*w.spec.NackRedeliveryDelay)):
+			case <-ctx.Done():
+				return i18n.NewError(ctx, tmmsgs.MsgWebSocketInterruptedSend)
+			}
+		} else {
+			log.L(ctx).Warnf("WebSocket event batch %d unacknowledged. Redelivery %d: %s", batchNumber, w.redeliveries, err)
+		}
+	}
+}
+
+// skipBatch logs a batch that could not be delivered, and dead-letters it so the stream can move on past it
+func (w *webSocketAction) skipBatch(ctx context.Context, batchNumber int, events []*apitypes.EventWithContext, err error) {
+	log.L(ctx).Errorf("WebSocket event batch %d dead-lettered after %d redeliveries: %s", batchNumber, w.redeliveries-1, err)
+	for _, e := range events {
+		log.L(ctx).Errorf("Dead-lettered event: %s", e.Event.String())
+	}
+	if w.deadLetter != nil {
+		w.deadLetter(ctx, events, err)
+	}
+}
+
+// deliverBatch sends the batch once, and waits for the ack if required
+func (w *webSocketAction) deliverBatch(ctx context.Context, batchNumber int, events []*apitypes.EventWithContext) error {
 	var err error
 
 	// Get a blocking channel to send and receive on our chosen namespace
@@ -92,9 +146,13 @@ func (w *webSocketAction) attemptBatch(ctx context.Context, batchNumber, attempt
 		}
 	}
 
-	// Send the batch of events
+	// Send the batch of events, in the encoding of the stream unless the connection negotiated its own
+	var msg interface{} = events
+	if w.encoding != apitypes.PayloadEncodingJSON {
+		msg = &ws.EncodedMessage{Encoding: w.encoding, Payload: events}
+	}
 	select {
-	case channel <- events:
+	case channel <- msg:
 		break
 	case <-ctx.Done():
 		err = i18n.NewError(ctx, tmmsgs.MsgWebSocketInterruptedSend)
@@ -18,10 +18,15 @@ package events
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/ws"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/wsmocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,7 +38,7 @@ func TestWSAttemptBatchBadDistMode(t *testing.T) {
 	dmw := apitypes.DistributionMode("wrong")
 	wsa := newWebSocketAction(mws, &apitypes.WebSocketConfig{
 		DistributionMode: &dmw,
-	}, "ut_stream")
+	}, apitypes.PayloadEncodingJSON, "ut_stream")
 
 	err := wsa.attemptBatch(context.Background(), 0, 0, []*apitypes.EventWithContext{})
 	assert.Regexp(t, "FF21034", err)
@@ -49,7 +54,7 @@ func TestWSAttemptBatchPurge(t *testing.T) {
 	dmw := apitypes.DistributionModeBroadcast
 	wsa := newWebSocketAction(mws, &apitypes.WebSocketConfig{
 		DistributionMode: &dmw,
-	}, "ut_stream")
+	}, apitypes.PayloadEncodingJSON, "ut_stream")
 
 	err := wsa.attemptBatch(context.Background(), 0, 0, []*apitypes.EventWithContext{})
 	assert.NoError(t, err)
@@ -70,7 +75,7 @@ func TestWSAttemptBatchExitPushingEvent(t *testing.T) {
 	dmw := apitypes.DistributionModeBroadcast
 	wsa := newWebSocketAction(mws, &apitypes.WebSocketConfig{
 		DistributionMode: &dmw,
-	}, "ut_stream")
+	}, apitypes.PayloadEncodingJSON, "ut_stream")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
@@ -87,7 +92,7 @@ func TestWSAttemptBatchExitReceivingReply(t *testing.T) {
 	dmw := apitypes.DistributionModeBroadcast
 	wsa := newWebSocketAction(mws, &apitypes.WebSocketConfig{
 		DistributionMode: &dmw,
-	}, "ut_stream")
+	}, apitypes.PayloadEncodingJSON, "ut_stream")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
@@ -95,3 +100,148 @@ func TestWSAttemptBatchExitReceivingReply(t *testing.T) {
 	assert.Regexp(t, "FF21039", err)
 
 }
+
+func TestWSAttemptBatchMsgpack(t *testing.T) {
+
+	mws := &wsmocks.WebSocketChannels{}
+	sc, _, rc := mockWSChannels(mws)
+
+	dmw := apitypes.DistributionModeLoadBalance
+	wsa := newWebSocketAction(mws, &apitypes.WebSocketConfig{
+		DistributionMode: &dmw,
+	}, apitypes.PayloadEncodingMsgpack, "ut_stream")
+
+	events := []*apitypes.EventWithContext{}
+	go func() {
+		msg := <-sc
+		assert.Equal(t, &ws.EncodedMessage{Encoding: apitypes.PayloadEncodingMsgpack, Payload: events}, msg)
+		rc <- nil
+	}()
+
+	err := wsa.attemptBatch(context.Background(), 1, 0, events)
+	assert.NoError(t, err)
+
+}
+
+func TestWSAttemptBatchNackRedelivers(t *testing.T) {
+
+	mws := &wsmocks.WebSocketChannels{}
+	sc, _, rc := mockWSChannels(mws)
+
+	dmw := apitypes.DistributionModeLoadBalance
+	delay := fftypes.FFDuration(1 * time.Millisecond)
+	maxRedeliveries := uint64(0)
+	wsa := newWebSocketAction(mws, &apitypes.WebSocketConfig{
+		DistributionMode:    &dmw,
+		NackRedeliveryDelay: &delay,
+		MaxRedeliveries:     &maxRedeliveries,
+	}, apitypes.PayloadEncodingJSON, "ut_stream")
+
+	go func() {
+		<-sc
+		rc <- &ws.UnackedError{Err: fmt.Errorf("pop"), Nack: true}
+		<-sc
+		rc <- nil
+	}()
+
+	err := wsa.attemptBatch(context.Background(), 1, 0, []*apitypes.EventWithContext{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, wsa.redeliveries)
+
+}
+
+func TestWSAttemptBatchDisconnectRedelivers(t *testing.T) {
+
+	mws := &wsmocks.WebSocketChannels{}
+	sc, _, rc := mockWSChannels(mws)
+
+	dmw := apitypes.DistributionModeLoadBalance
+	delay := fftypes.FFDuration(1 * time.Hour) // does not apply to a disconnect
+	maxRedeliveries := uint64(0)
+	wsa := newWebSocketAction(mws, &apitypes.WebSocketConfig{
+		DistributionMode:    &dmw,
+		NackRedeliveryDelay: &delay,
+		MaxRedeliveries:     &maxRedeliveries,
+	}, apitypes.PayloadEncodingJSON, "ut_stream")
+
+	go func() {
+		<-sc
+		rc <- &ws.UnackedError{Err: fmt.Errorf("closed")}
+		<-sc
+		rc <- nil
+	}()
+
+	err := wsa.attemptBatch(context.Background(), 1, 0, []*apitypes.EventWithContext{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, wsa.redeliveries)
+
+}
+
+func TestWSAttemptBatchDeadLetter(t *testing.T) {
+
+	mws := &wsmocks.WebSocketChannels{}
+	sc, _, rc := mockWSChannels(mws)
+
+	dmw := apitypes.DistributionModeLoadBalance
+	delay := fftypes.FFDuration(1 * time.Millisecond)
+	maxRedeliveries := uint64(1)
+	wsa := newWebSocketAction(mws, &apitypes.WebSocketConfig{
+		DistributionMode:    &dmw,
+		NackRedeliveryDelay: &delay,
+		MaxRedeliveries:     &maxRedeliveries,
+	}, apitypes.PayloadEncodingJSON, "ut_stream")
+	var deadLettered []*apitypes.EventWithContext
+	wsa.deadLetter = func(ctx context.Context, events []*apitypes.EventWithContext, reason error) {
+		deadLettered = events
+		assert.Regexp(t, "pop", reason)
+	}
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			<-sc
+			rc <- &ws.UnackedError{Err: fmt.Errorf("pop"), Nack: true}
+		}
+	}()
+
+	// After one redelivery the batch is dead-lettered, so the stream can move on
+	err := wsa.attemptBatch(context.Background(), 1, 0, []*apitypes.EventWithContext{
+		{Event: ffcapi.Event{ID: ffcapi.EventID{BlockNumber: 42}}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, deadLettered, 1)
+
+	// The count resets for the next batch
+	go func() {
+		<-sc
+		rc <- nil
+	}()
+	err = wsa.attemptBatch(context.Background(), 2, 0, []*apitypes.EventWithContext{})
+	assert.NoError(t, err)
+	assert.Zero(t, wsa.redeliveries)
+
+}
+
+func TestWSAttemptBatchExitWaitingToRedeliver(t *testing.T) {
+
+	mws := &wsmocks.WebSocketChannels{}
+	sc, _, rc := mockWSChannels(mws)
+
+	dmw := apitypes.DistributionModeLoadBalance
+	delay := fftypes.FFDuration(1 * time.Hour)
+	maxRedeliveries := uint64(0)
+	wsa := newWebSocketAction(mws, &apitypes.WebSocketConfig{
+		DistributionMode:    &dmw,
+		NackRedeliveryDelay: &delay,
+		MaxRedeliveries:     &maxRedeliveries,
+	}, apitypes.PayloadEncodingJSON, "ut_stream")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-sc
+		rc <- &ws.UnackedError{Err: fmt.Errorf("pop"), Nack: true}
+		cancel()
+	}()
+	err := wsa.attemptBatch(ctx, 1, 0, []*apitypes.EventWithContext{})
+	assert.Regexp(t, "FF21038", err)
+
+}
@@ -0,0 +1,243 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistence
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// encryptedValuePrefix marks a field value as encrypted, followed by the base64 encoded GCM nonce and ciphertext.
+// Values without the prefix were written before encryption was enabled, and are returned unchanged.
+const encryptedValuePrefix = "enc:v1:"
+
+// encryptedTXFields are the sensitive fields of a transaction that are encrypted at rest - the signed
+// transaction, the transaction data, and the completion callback and confirmation webhook URLs which can include credentials
+var encryptedTXFields = []struct {
+	name  string
+	field func(tx *apitypes.ManagedTX) *string
+}{
+	{"rawTransaction", func(tx *apitypes.ManagedTX) *string { return &tx.RawTransaction }},
+	{"transactionData", func(tx *apitypes.ManagedTX) *string { return &tx.TransactionData }},
+	{"completionCallback", func(tx *apitypes.ManagedTX) *string { return &tx.CompletionCallback }},
+	{"confirmationWebhook", func(tx *apitypes.ManagedTX) *string { return &tx.ConfirmationWebhook }},
+}
+
+// encryptedPersistence encrypts the sensitive fields of each transaction written to the persistence
+// beneath it, and decrypts them on every read - so encryption is transparent to callers.
+// Everything other than transactions is passed straight through.
+type encryptedPersistence struct {
+	Persistence
+	aead cipher.AEAD
+}
+
+// WithFieldEncryption returns the persistence wrapped with encryption of sensitive transaction fields,
+// if an encryption key is configured. Otherwise it returns the persistence unchanged.
+func WithFieldEncryption(ctx context.Context, p Persistence) (Persistence, error) {
+	key, err := loadEncryptionKey(ctx)
+	if err != nil || key == nil {
+		return p, err
+	}
+	block, _ := aes.NewCipher(key) // the key length is already checked
+	aead, _ := cipher.NewGCM(block)
+	return &encryptedPersistence{
+		Persistence: p,
+		aead:        aead,
+	}, nil
+}
+
+func loadEncryptionKey(ctx context.Context) ([]byte, error) {
+	keyFile := config.GetString(tmconfig.PersistenceEncryptionKeyFile)
+	keyEnv := config.GetString(tmconfig.PersistenceEncryptionKeyEnv)
+	var keyHex, source string
+	switch {
+	case keyFile != "" && keyEnv != "":
+		return nil, i18n.NewError(ctx, tmmsgs.MsgEncryptionKeySourceConflict)
+	case keyFile != "":
+		b, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, i18n.WrapError(ctx, err, tmmsgs.MsgEncryptionKeyFileRead, keyFile)
+		}
+		keyHex, source = string(b), keyFile
+	case keyEnv != "":
+		keyHex, source = os.Getenv(keyEnv), "$"+keyEnv
+	default:
+		return nil, nil
+	}
+	key, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(keyHex), "0x"))
+	if err != nil || len(key) != 32 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgEncryptionKeyInvalid, source)
+	}
+	return key, nil
+}
+
+// The transaction ID and field name are authenticated with each value, so an encrypted value
+// cannot be moved to a different field or transaction
+func fieldAdditionalData(tx *apitypes.ManagedTX, name string) []byte {
+	return []byte(tx.ID + "/" + name)
+}
+
+func (p *encryptedPersistence) encryptTX(tx *apitypes.ManagedTX) (*apitypes.ManagedTX, error) {
+	enc := *tx // the caller's copy is left unencrypted
+	for _, f := range encryptedTXFields {
+		v := f.field(&enc)
+		if *v == "" || strings.HasPrefix(*v, encryptedValuePrefix) {
+			continue
+		}
+		nonce := make([]byte, p.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		sealed := p.aead.Seal(nonce, nonce, []byte(*v), fieldAdditionalData(tx, f.name))
+		*v = encryptedValuePrefix + base64.StdEncoding.EncodeToString(sealed)
+	}
+	return &enc, nil
+}
+
+func (p *encryptedPersistence) decryptTX(ctx context.Context, tx *apitypes.ManagedTX) error {
+	if tx == nil {
+		return nil
+	}
+	for _, f := range encryptedTXFields {
+		v := f.field(tx)
+		if !strings.HasPrefix(*v, encryptedValuePrefix) {
+			continue
+		}
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(*v, encryptedValuePrefix))
+		if err != nil || len(sealed) < p.aead.NonceSize() {
+			return i18n.NewError(ctx, tmmsgs.MsgFieldDecryptFailed, f.name, tx.ID)
+		}
+		nonceSize := p.aead.NonceSize()
+		plain, err := p.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], fieldAdditionalData(tx, f.name))
+		if err != nil {
+			return i18n.WrapError(ctx, err, tmmsgs.MsgFieldDecryptFailed, f.name, tx.ID)
+		}
+		*v = string(plain)
+	}
+	return nil
+}
+
+func (p *encryptedPersistence) decryptTXs(ctx context.Context, txs []*apitypes.ManagedTX, err error) ([]*apitypes.ManagedTX, error) {
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range txs {
+		if err := p.decryptTX(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+	return txs, nil
+}
+
+func (p *encryptedPersistence) decryptOne(ctx context.Context, tx *apitypes.ManagedTX, err error) (*apitypes.ManagedTX, error) {
+	if err == nil {
+		err = p.decryptTX(ctx, tx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (p *encryptedPersistence) ListTransactionsByCreateTime(ctx context.Context, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	txs, err := p.Persistence.ListTransactionsByCreateTime(ctx, after, limit, dir)
+	return p.decryptTXs(ctx, txs, err)
+}
+
+func (p *encryptedPersistence) ListTransactionsByCreateTimeRange(ctx context.Context, from, to *fftypes.FFTime, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	txs, err := p.Persistence.ListTransactionsByCreateTimeRange(ctx, from, to, after, limit, dir)
+	return p.decryptTXs(ctx, txs, err)
+}
+
+func (p *encryptedPersistence) ListTransactionsByNonce(ctx context.Context, signer string, after *fftypes.FFBigInt, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	txs, err := p.Persistence.ListTransactionsByNonce(ctx, signer, after, limit, dir)
+	return p.decryptTXs(ctx, txs, err)
+}
+
+func (p *encryptedPersistence) ListTransactionsPending(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	txs, err := p.Persistence.ListTransactionsPending(ctx, after, limit, dir)
+	return p.decryptTXs(ctx, txs, err)
+}
+
+func (p *encryptedPersistence) ListTransactionsByStatus(ctx context.Context, status apitypes.TxStatus, signer string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	txs, err := p.Persistence.ListTransactionsByStatus(ctx, status, signer, after, limit, dir)
+	return p.decryptTXs(ctx, txs, err)
+}
+
+func (p *encryptedPersistence) ListTransactionsByRequestID(ctx context.Context, requestID string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	txs, err := p.Persistence.ListTransactionsByRequestID(ctx, requestID, after, limit, dir)
+	return p.decryptTXs(ctx, txs, err)
+}
+
+func (p *encryptedPersistence) ListTransactionsByDependsOn(ctx context.Context, parentID string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	txs, err := p.Persistence.ListTransactionsByDependsOn(ctx, parentID, after, limit, dir)
+	return p.decryptTXs(ctx, txs, err)
+}
+
+func (p *encryptedPersistence) ListTransactionsByLabels(ctx context.Context, labels map[string]string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	txs, err := p.Persistence.ListTransactionsByLabels(ctx, labels, after, limit, dir)
+	return p.decryptTXs(ctx, txs, err)
+}
+
+func (p *encryptedPersistence) GetTransactionByID(ctx context.Context, txID string) (*apitypes.ManagedTX, error) {
+	tx, err := p.Persistence.GetTransactionByID(ctx, txID)
+	return p.decryptOne(ctx, tx, err)
+}
+
+func (p *encryptedPersistence) GetTransactionsByIDs(ctx context.Context, txIDs []string) ([]*apitypes.ManagedTX, error) {
+	txs, err := p.Persistence.GetTransactionsByIDs(ctx, txIDs)
+	return p.decryptTXs(ctx, txs, err)
+}
+
+func (p *encryptedPersistence) GetTransactionByNonce(ctx context.Context, signer string, nonce *fftypes.FFBigInt) (*apitypes.ManagedTX, error) {
+	tx, err := p.Persistence.GetTransactionByNonce(ctx, signer, nonce)
+	return p.decryptOne(ctx, tx, err)
+}
+
+func (p *encryptedPersistence) GetTransactionByHash(ctx context.Context, hash string) (*apitypes.ManagedTX, error) {
+	tx, err := p.Persistence.GetTransactionByHash(ctx, hash)
+	return p.decryptOne(ctx, tx, err)
+}
+
+func (p *encryptedPersistence) GetTransactionByIdempotencyKey(ctx context.Context, signer, idempotencyKey string) (*apitypes.ManagedTX, error) {
+	tx, err := p.Persistence.GetTransactionByIdempotencyKey(ctx, signer, idempotencyKey)
+	return p.decryptOne(ctx, tx, err)
+}
+
+// WriteTransaction encrypts every sensitive field on each write, which includes re-encrypting a record
+// that was read from before encryption was enabled
+func (p *encryptedPersistence) WriteTransaction(ctx context.Context, tx *apitypes.ManagedTX, new bool) error {
+	enc, err := p.encryptTX(tx)
+	if err != nil {
+		return err
+	}
+	return p.Persistence.WriteTransaction(ctx, enc, new)
+}
@@ -18,10 +18,12 @@ package persistence
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
@@ -40,6 +42,10 @@ type leveldbPersistence struct {
 	db         *leveldb.DB
 	syncWrites bool
 	txMux      sync.RWMutex // allows us to draw conclusions on the cleanup of indexes
+	leaseMux   sync.Mutex   // makes the check and update of a lease atomic
+	writeBatch *txWriteBatch
+	// statusCounts is nil until transactions are first counted, and then maintained on each write under txMux
+	statusCounts map[apitypes.TxStatus]int
 }
 
 func NewLevelDBPersistence(ctx context.Context) (Persistence, error) {
@@ -53,10 +59,42 @@ func NewLevelDBPersistence(ctx context.Context) (Persistence, error) {
 	if err != nil {
 		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceInitFailed, dbPath)
 	}
-	return &leveldbPersistence{
+	p := &leveldbPersistence{
 		db:         db,
 		syncWrites: config.GetBool(tmconfig.PersistenceLevelDBSyncWrites),
-	}, nil
+	}
+	err = p.indexPendingBySigner(ctx)
+	if err == nil {
+		err = p.indexIdempotencyKeys(ctx)
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if batchSize := config.GetInt(tmconfig.PersistenceLevelDBWriteBatchSize); batchSize > 0 {
+		p.startWriteBatch(ctx, batchSize, config.GetDuration(tmconfig.PersistenceLevelDBWriteBatchFlushInterval))
+	}
+	return p, nil
+}
+
+// indexPendingBySigner adds the pending transactions to the index by signer on startup, as databases written by
+// earlier versions do not have it. Only the pending transactions are read, so this is cheap once it is populated.
+func (p *leveldbPersistence) indexPendingBySigner(ctx context.Context) error {
+	pending := make([]*apitypes.ManagedTX, 0)
+	_, err := p.listJSON(ctx, txPendingIndexPrefix, txPendingIndexEnd, "", 0, SortDirectionAscending,
+		func() interface{} { var v *apitypes.ManagedTX; return &v },
+		func(v interface{}) { pending = append(pending, *(v.(**apitypes.ManagedTX))) },
+		p.indexLookupCallback,
+	)
+	if err != nil {
+		return err
+	}
+	for _, tx := range pending {
+		if err := p.writeKeyValue(ctx, txSignerPendingIndexKey(tx), txDataKey(tx.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type SortDirection int
@@ -67,6 +105,7 @@ const (
 )
 
 const checkpointsPrefix = "checkpoints_0/"
+const deadLettersPrefix = "deadletters_0/"
 const eventstreamsPrefix = "eventstreams_0/"
 const eventstreamsEnd = "eventstreams_1"
 const listenersPrefix = "listeners_0/"
@@ -75,8 +114,28 @@ const transactionsPrefix = "tx_0/"
 const nonceAllocationPrefix = "nonce_0/"
 const txPendingIndexPrefix = "tx_inflight_0/"
 const txPendingIndexEnd = "tx_inflight_1"
+const txSignerPendingIndexPrefix = "tx_signer_inflight_0/"
 const txCreatedIndexPrefix = "tx_created_0/"
 const txCreatedIndexEnd = "tx_created_1"
+const txHashIndexPrefix = "tx_hash_0/"
+const txDependsOnIndexPrefix = "tx_depends_0/"
+const txLabelIndexPrefix = "tx_label_0/"
+const txIdempotencyIndexPrefix = "tx_idempotency_0/"
+const txIdempotencyIndexedKey = "tx_idempotency_indexed"
+const leasesPrefix = "leases_0/"
+const submissionControlKey = "control_0/submissions"
+
+func streamDeadLettersPrefix(streamID *fftypes.UUID) string {
+	return fmt.Sprintf("%s%s_0/", deadLettersPrefix, streamID)
+}
+
+func streamDeadLettersEnd(streamID *fftypes.UUID) string {
+	return fmt.Sprintf("%s%s_1", deadLettersPrefix, streamID)
+}
+
+func deadLetterKey(deadLetter *apitypes.DeadLetter) []byte {
+	return []byte(fmt.Sprintf("%s%.19d/%s", streamDeadLettersPrefix(deadLetter.StreamID), deadLetter.Created.UnixNano(), deadLetter.ID))
+}
 
 func signerNoncePrefix(signer string) string {
 	return fmt.Sprintf("%s%s_0/", nonceAllocationPrefix, signer)
@@ -94,10 +153,60 @@ func txPendingIndexKey(sequenceID *fftypes.UUID) []byte {
 	return []byte(fmt.Sprintf("%s%s", txPendingIndexPrefix, sequenceID))
 }
 
+func signerPendingPrefix(signer string) string {
+	return fmt.Sprintf("%s%s_0/", txSignerPendingIndexPrefix, signer)
+}
+
+func signerPendingEnd(signer string) string {
+	return fmt.Sprintf("%s%s_1", txSignerPendingIndexPrefix, signer)
+}
+
+func txSignerPendingIndexKey(tx *apitypes.ManagedTX) []byte {
+	return []byte(fmt.Sprintf("%s%.24d", signerPendingPrefix(tx.NonceKey()), tx.Nonce.Int()))
+}
+
 func txCreatedIndexKey(tx *apitypes.ManagedTX) []byte {
 	return []byte(fmt.Sprintf("%s%.19d/%s", txCreatedIndexPrefix, tx.Created.UnixNano(), tx.SequenceID))
 }
 
+func txDependsOnPrefix(parentID string) string {
+	return fmt.Sprintf("%s%s_0/", txDependsOnIndexPrefix, parentID)
+}
+
+func txDependsOnEnd(parentID string) string {
+	return fmt.Sprintf("%s%s_1", txDependsOnIndexPrefix, parentID)
+}
+
+func txDependsOnIndexKey(tx *apitypes.ManagedTX) []byte {
+	return []byte(fmt.Sprintf("%s%.19d/%s", txDependsOnPrefix(tx.DependsOn), tx.Created.UnixNano(), tx.SequenceID))
+}
+
+// Labels are hex encoded in the index, as they can contain any character including our key separators
+func txLabelPrefix(key, value string) string {
+	return fmt.Sprintf("%s%s_0/", txLabelIndexPrefix, hex.EncodeToString([]byte(labelIndexValue(key, value))))
+}
+
+func txLabelEnd(key, value string) string {
+	return fmt.Sprintf("%s%s_1", txLabelIndexPrefix, hex.EncodeToString([]byte(labelIndexValue(key, value))))
+}
+
+func txLabelIndexKeys(tx *apitypes.ManagedTX) [][]byte {
+	keys := make([][]byte, 0, len(tx.Labels))
+	for k, v := range tx.Labels {
+		keys = append(keys, []byte(fmt.Sprintf("%s%.19d/%s", txLabelPrefix(k, v), tx.Created.UnixNano(), tx.SequenceID)))
+	}
+	return keys
+}
+
+// The signer and idempotency key are hex encoded in the index, as the key can contain any character including our key separators
+func txIdempotencyIndexKey(signer, idempotencyKey string) []byte {
+	return []byte(fmt.Sprintf("%s%s/%s", txIdempotencyIndexPrefix, hex.EncodeToString([]byte(signer)), hex.EncodeToString([]byte(idempotencyKey))))
+}
+
+func txHashIndexKey(hash string) []byte {
+	return []byte(fmt.Sprintf("%s%s", txHashIndexPrefix, hash))
+}
+
 func txDataKey(k string) []byte {
 	return []byte(fmt.Sprintf("%s%s", transactionsPrefix, k))
 }
@@ -107,7 +216,11 @@ func prefixedKey(prefix string, id fmt.Stringer) []byte {
 }
 
 func (p *leveldbPersistence) writeKeyValue(ctx context.Context, key, value []byte) error {
-	err := p.db.Put(key, value, &opt.WriteOptions{Sync: p.syncWrites})
+	return p.writeKeyValueSync(ctx, key, value, p.syncWrites)
+}
+
+func (p *leveldbPersistence) writeKeyValueSync(ctx context.Context, key, value []byte, sync bool) error {
+	err := p.db.Put(key, value, &opt.WriteOptions{Sync: sync})
 	if err != nil {
 		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceWriteFailed)
 	}
@@ -115,12 +228,16 @@ func (p *leveldbPersistence) writeKeyValue(ctx context.Context, key, value []byt
 }
 
 func (p *leveldbPersistence) writeJSON(ctx context.Context, key []byte, value interface{}) error {
+	return p.writeJSONSync(ctx, key, value, p.syncWrites)
+}
+
+func (p *leveldbPersistence) writeJSONSync(ctx context.Context, key []byte, value interface{}, sync bool) error {
 	b, err := json.Marshal(value)
 	if err != nil {
 		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceMarshalFailed)
 	}
 	log.L(ctx).Debugf("Wrote %s", key)
-	return p.writeKeyValue(ctx, key, b)
+	return p.writeKeyValueSync(ctx, key, b, sync)
 }
 
 func (p *leveldbPersistence) getKeyValue(ctx context.Context, key []byte) ([]byte, error) {
@@ -139,7 +256,17 @@ func (p *leveldbPersistence) readJSONByIndex(ctx context.Context, idxKey []byte,
 	if err != nil || valKey == nil {
 		return err
 	}
-	return p.readJSON(ctx, valKey, target)
+	return p.readTransactionJSON(ctx, valKey, target)
+}
+
+// readTransactionJSON reads a transaction record, including any update that is
+// buffered in the write batch and not yet written to the DB
+func (p *leveldbPersistence) readTransactionJSON(ctx context.Context, key []byte, target interface{}) error {
+	b, err := p.getTransactionValue(ctx, key)
+	if err != nil || b == nil {
+		return err
+	}
+	return p.unmarshalJSON(ctx, key, b, target)
 }
 
 func (p *leveldbPersistence) readJSON(ctx context.Context, key []byte, target interface{}) error {
@@ -147,7 +274,11 @@ func (p *leveldbPersistence) readJSON(ctx context.Context, key []byte, target in
 	if err != nil || b == nil {
 		return err
 	}
-	err = json.Unmarshal(b, target)
+	return p.unmarshalJSON(ctx, key, b, target)
+}
+
+func (p *leveldbPersistence) unmarshalJSON(ctx context.Context, key, b []byte, target interface{}) error {
+	err := json.Unmarshal(b, target)
 	if err != nil {
 		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceUnmarshalFailed)
 	}
@@ -261,6 +392,26 @@ func (p *leveldbPersistence) DeleteCheckpoint(ctx context.Context, streamID *fft
 	return p.deleteKeys(ctx, prefixedKey(checkpointsPrefix, streamID))
 }
 
+func (p *leveldbPersistence) ListStreamDeadLetters(ctx context.Context, streamID *fftypes.UUID, limit int) ([]*apitypes.DeadLetter, error) {
+	deadLetters := make([]*apitypes.DeadLetter, 0)
+	if _, err := p.listJSON(ctx, streamDeadLettersPrefix(streamID), streamDeadLettersEnd(streamID), "", limit, SortDirectionAscending,
+		func() interface{} { var v *apitypes.DeadLetter; return &v },
+		func(v interface{}) { deadLetters = append(deadLetters, *(v.(**apitypes.DeadLetter))) },
+		nil,
+	); err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+func (p *leveldbPersistence) WriteDeadLetter(ctx context.Context, deadLetter *apitypes.DeadLetter) error {
+	return p.writeJSON(ctx, deadLetterKey(deadLetter), deadLetter)
+}
+
+func (p *leveldbPersistence) DeleteDeadLetter(ctx context.Context, deadLetter *apitypes.DeadLetter) error {
+	return p.deleteKeys(ctx, deadLetterKey(deadLetter))
+}
+
 func (p *leveldbPersistence) ListStreams(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection) ([]*apitypes.EventStream, error) {
 	streams := make([]*apitypes.EventStream, 0)
 	if _, err := p.listJSON(ctx, eventstreamsPrefix, eventstreamsEnd, after.String(), limit, dir,
@@ -325,7 +476,7 @@ func (p *leveldbPersistence) DeleteListener(ctx context.Context, listenerID *fft
 }
 
 func (p *leveldbPersistence) indexLookupCallback(ctx context.Context, key []byte) ([]byte, error) {
-	b, err := p.getKeyValue(ctx, key)
+	b, err := p.getTransactionValue(ctx, key)
 	switch {
 	case err != nil:
 		return nil, err
@@ -344,7 +495,7 @@ func (p *leveldbPersistence) cleanupOrphanedTXIdxKeys(ctx context.Context, orpha
 	}
 }
 
-func (p *leveldbPersistence) listTransactionsByIndex(ctx context.Context, collectionPrefix, collectionEnd, afterStr string, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+func (p *leveldbPersistence) listTransactionsByIndex(ctx context.Context, collectionPrefix, collectionEnd, afterStr string, limit int, dir SortDirection, filters ...func(interface{}) bool) ([]*apitypes.ManagedTX, error) {
 
 	p.txMux.RLock()
 	transactions := make([]*apitypes.ManagedTX, 0)
@@ -352,6 +503,7 @@ func (p *leveldbPersistence) listTransactionsByIndex(ctx context.Context, collec
 		func() interface{} { var v *apitypes.ManagedTX; return &v },
 		func(v interface{}) { transactions = append(transactions, *(v.(**apitypes.ManagedTX))) },
 		p.indexLookupCallback,
+		filters...,
 	)
 	p.txMux.RUnlock()
 	if err != nil {
@@ -372,6 +524,18 @@ func (p *leveldbPersistence) ListTransactionsByCreateTime(ctx context.Context, a
 	return p.listTransactionsByIndex(ctx, txCreatedIndexPrefix, txCreatedIndexEnd, afterStr, limit, dir)
 }
 
+func (p *leveldbPersistence) ListTransactionsByCreateTimeRange(ctx context.Context, from, to *fftypes.FFTime, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	afterStr := ""
+	if after != nil {
+		afterStr = fmt.Sprintf("%.19d/%s", after.Created.UnixNano(), after.SequenceID)
+	}
+	rangeFilter := func(v interface{}) bool {
+		created := (*(v.(**apitypes.ManagedTX))).Created.UnixNano()
+		return (from == nil || created >= from.UnixNano()) && (to == nil || created <= to.UnixNano())
+	}
+	return p.listTransactionsByIndex(ctx, txCreatedIndexPrefix, txCreatedIndexEnd, afterStr, limit, dir, rangeFilter)
+}
+
 func (p *leveldbPersistence) ListTransactionsByNonce(ctx context.Context, signer string, after *fftypes.FFBigInt, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
 	afterStr := ""
 	if after != nil {
@@ -384,13 +548,84 @@ func (p *leveldbPersistence) ListTransactionsPending(ctx context.Context, after
 	return p.listTransactionsByIndex(ctx, txPendingIndexPrefix, txPendingIndexEnd, after.String(), limit, dir)
 }
 
+func (p *leveldbPersistence) ListTransactionsByStatus(ctx context.Context, status apitypes.TxStatus, signer string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	// There is no index by status, so we filter the create time (or signer nonce) index - other than for the
+	// pending transactions of a signer, which are indexed so the completed history of the signer is not read
+	statusFilter := func(v interface{}) bool {
+		return (*(v.(**apitypes.ManagedTX))).Status == status
+	}
+	afterStr := ""
+	if signer != "" {
+		if after != nil {
+			afterStr = fmt.Sprintf("%.24d", after.Nonce.Int())
+		}
+		if status == apitypes.TxStatusPending {
+			return p.listTransactionsByIndex(ctx, signerPendingPrefix(signer), signerPendingEnd(signer), afterStr, limit, dir, statusFilter)
+		}
+		return p.listTransactionsByIndex(ctx, signerNoncePrefix(signer), signerNonceEnd(signer), afterStr, limit, dir, statusFilter)
+	}
+	if after != nil {
+		afterStr = fmt.Sprintf("%.19d/%s", after.Created.UnixNano(), after.SequenceID)
+	}
+	return p.listTransactionsByIndex(ctx, txCreatedIndexPrefix, txCreatedIndexEnd, afterStr, limit, dir, statusFilter)
+}
+
+func (p *leveldbPersistence) ListTransactionsByRequestID(ctx context.Context, requestID string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	// There is no index by request ID, so we filter the create time index
+	requestIDFilter := func(v interface{}) bool {
+		return (*(v.(**apitypes.ManagedTX))).RequestID == requestID
+	}
+	afterStr := ""
+	if after != nil {
+		afterStr = fmt.Sprintf("%.19d/%s", after.Created.UnixNano(), after.SequenceID)
+	}
+	return p.listTransactionsByIndex(ctx, txCreatedIndexPrefix, txCreatedIndexEnd, afterStr, limit, dir, requestIDFilter)
+}
+
+func (p *leveldbPersistence) ListTransactionsByDependsOn(ctx context.Context, parentID string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	afterStr := ""
+	if after != nil {
+		afterStr = fmt.Sprintf("%.19d/%s", after.Created.UnixNano(), after.SequenceID)
+	}
+	return p.listTransactionsByIndex(ctx, txDependsOnPrefix(parentID), txDependsOnEnd(parentID), afterStr, limit, dir)
+}
+
+func (p *leveldbPersistence) ListTransactionsByLabels(ctx context.Context, labels map[string]string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	// We read the index of one of the labels, and filter on the others
+	key, value := firstLabel(labels)
+	labelsFilter := func(v interface{}) bool {
+		return hasLabels(*(v.(**apitypes.ManagedTX)), labels)
+	}
+	afterStr := ""
+	if after != nil {
+		afterStr = fmt.Sprintf("%.19d/%s", after.Created.UnixNano(), after.SequenceID)
+	}
+	return p.listTransactionsByIndex(ctx, txLabelPrefix(key, value), txLabelEnd(key, value), afterStr, limit, dir, labelsFilter)
+}
+
 func (p *leveldbPersistence) GetTransactionByID(ctx context.Context, txID string) (tx *apitypes.ManagedTX, err error) {
 	p.txMux.RLock()
 	defer p.txMux.RUnlock()
-	err = p.readJSON(ctx, txDataKey(txID), &tx)
+	err = p.readTransactionJSON(ctx, txDataKey(txID), &tx)
 	return tx, err
 }
 
+func (p *leveldbPersistence) GetTransactionsByIDs(ctx context.Context, txIDs []string) ([]*apitypes.ManagedTX, error) {
+	p.txMux.RLock()
+	defer p.txMux.RUnlock()
+	transactions := make([]*apitypes.ManagedTX, 0, len(txIDs))
+	for _, txID := range txIDs {
+		var tx *apitypes.ManagedTX
+		if err := p.readTransactionJSON(ctx, txDataKey(txID), &tx); err != nil {
+			return nil, err
+		}
+		if tx != nil {
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions, nil
+}
+
 func (p *leveldbPersistence) GetTransactionByNonce(ctx context.Context, signer string, nonce *fftypes.FFBigInt) (tx *apitypes.ManagedTX, err error) {
 	p.txMux.RLock()
 	defer p.txMux.RUnlock()
@@ -398,6 +633,34 @@ func (p *leveldbPersistence) GetTransactionByNonce(ctx context.Context, signer s
 	return tx, err
 }
 
+func (p *leveldbPersistence) GetTransactionByHash(ctx context.Context, hash string) (tx *apitypes.ManagedTX, err error) {
+	idxKey := txHashIndexKey(hash)
+	p.txMux.RLock()
+	valKey, err := p.getKeyValue(ctx, idxKey)
+	if err == nil && valKey != nil {
+		err = p.readTransactionJSON(ctx, valKey, &tx)
+	}
+	p.txMux.RUnlock()
+	if err == nil && valKey != nil && tx == nil {
+		// The transaction has been deleted since it was submitted with this hash
+		p.cleanupOrphanedTXIdxKeys(ctx, [][]byte{idxKey})
+	}
+	return tx, err
+}
+
+func (p *leveldbPersistence) writeTxHashIndexes(ctx context.Context, tx *apitypes.ManagedTX, idKey []byte) error {
+	for _, hash := range transactionHashes(tx) {
+		existing, err := p.getKeyValue(ctx, txHashIndexKey(hash))
+		if err == nil && existing == nil {
+			err = p.writeKeyValue(ctx, txHashIndexKey(hash), idKey)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *leveldbPersistence) WriteTransaction(ctx context.Context, tx *apitypes.ManagedTX, new bool) (err error) {
 	// We take a write-lock here, because we are writing multiple values (the indexes), and anybody
 	// attempting to read the critical nonce allocation index must know the difference between a partial write
@@ -406,6 +669,25 @@ func (p *leveldbPersistence) WriteTransaction(ctx context.Context, tx *apitypes.
 	p.txMux.Lock()
 	defer p.txMux.Unlock()
 
+	// Once we are maintaining counts by status, we need the status the transaction had before this write
+	var previous *apitypes.ManagedTX
+	if p.statusCounts != nil && !new {
+		if err := p.readTransactionJSON(ctx, txDataKey(tx.ID), &previous); err != nil {
+			return err
+		}
+	}
+	err = p.writeTransaction(ctx, tx, new)
+	if err == nil && p.statusCounts != nil {
+		if previous != nil {
+			p.statusCounts[previous.Status]--
+		}
+		p.statusCounts[tx.Status]++
+	}
+	return err
+}
+
+// writeTransaction must be called holding the txMux write lock
+func (p *leveldbPersistence) writeTransaction(ctx context.Context, tx *apitypes.ManagedTX, new bool) (err error) {
 	if tx.TransactionHeaders.From == "" ||
 		tx.Nonce == nil ||
 		tx.SequenceID == nil ||
@@ -431,37 +713,215 @@ func (p *leveldbPersistence) WriteTransaction(ctx context.Context, tx *apitypes.
 		// See listTransactionsByIndex() for the other half of this logic.
 		err = p.writeKeyValue(ctx, txCreatedIndexKey(tx), idKey)
 		if err == nil && tx.Status == apitypes.TxStatusPending {
-			err = p.writeKeyValue(ctx, txPendingIndexKey(tx.SequenceID), idKey)
+			err = p.writePendingIndexes(ctx, tx, idKey)
 		}
 		if err == nil {
-			err = p.writeKeyValue(ctx, txNonceAllocationKey(tx.TransactionHeaders.From, tx.Nonce), idKey)
+			err = p.writeKeyValue(ctx, txNonceAllocationKey(tx.NonceKey(), tx.Nonce), idKey)
+		}
+		if err == nil && tx.DependsOn != "" {
+			err = p.writeKeyValue(ctx, txDependsOnIndexKey(tx), idKey)
+		}
+		for _, labelKey := range txLabelIndexKeys(tx) {
+			if err == nil {
+				err = p.writeKeyValue(ctx, labelKey, idKey)
+			}
+		}
+		if err == nil && tx.IdempotencyKey != "" {
+			// A key reused after the retention window replaces the earlier transaction in the index
+			err = p.writeKeyValue(ctx, txIdempotencyIndexKey(tx.NonceKey(), tx.IdempotencyKey), idKey)
 		}
 	}
+	if err == nil {
+		// Each hash is indexed as soon as we see it, and remains indexed after the transaction is resubmitted
+		// with a new hash - so that the transaction can be found from any of its historical hashes
+		err = p.writeTxHashIndexes(ctx, tx, idKey)
+	}
+	if err == nil && !new && tx.Status == apitypes.TxStatusPending {
+		// Updates to a record that is already pending can be buffered, when write batching is enabled
+		if buffered, err := p.bufferTransactionUpdate(ctx, tx); err != nil || buffered {
+			return err
+		}
+		// An existing record can become pending, such as when a scheduled transaction is released
+		err = p.writePendingIndexes(ctx, tx, idKey)
+	}
 	// If we are creating/updating a record that is not pending, we need to ensure there is no pending index associated with it
 	if err == nil && tx.Status != apitypes.TxStatusPending {
-		err = p.deleteKeys(ctx, txPendingIndexKey(tx.SequenceID))
+		err = p.deleteKeys(ctx, txPendingIndexKey(tx.SequenceID), txSignerPendingIndexKey(tx))
 	}
 	if err == nil {
-		err = p.writeJSON(ctx, idKey, tx)
+		// Any buffered update is superseded by this write. When write batching is enabled, we
+		// always sync a transaction reaching a final state to storage before returning.
+		p.discardBufferedTransaction(idKey)
+		err = p.writeJSONSync(ctx, idKey, tx, p.syncWrites || (p.writeBatch != nil && tx.Status != apitypes.TxStatusPending))
 	}
 	return err
 }
 
+// writePendingIndexes writes the index of all pending transactions, and of the pending transactions of the signer
+func (p *leveldbPersistence) writePendingIndexes(ctx context.Context, tx *apitypes.ManagedTX, idKey []byte) error {
+	err := p.writeKeyValue(ctx, txPendingIndexKey(tx.SequenceID), idKey)
+	if err == nil {
+		err = p.writeKeyValue(ctx, txSignerPendingIndexKey(tx), idKey)
+	}
+	return err
+}
+
+// indexIdempotencyKeys indexes the idempotency keys of the stored transactions, the first time a database written by
+// an earlier version is opened. This reads every transaction, so a marker is written once it completes.
+func (p *leveldbPersistence) indexIdempotencyKeys(ctx context.Context) error {
+	if indexed, err := p.getKeyValue(ctx, []byte(txIdempotencyIndexedKey)); err != nil || indexed != nil {
+		return err
+	}
+	latest := make(map[string]*apitypes.ManagedTX)
+	_, err := p.listJSON(ctx, txCreatedIndexPrefix, txCreatedIndexEnd, "", 0, SortDirectionAscending,
+		func() interface{} { var v *apitypes.ManagedTX; return &v },
+		func(v interface{}) {
+			tx := *(v.(**apitypes.ManagedTX))
+			latest[string(txIdempotencyIndexKey(tx.NonceKey(), tx.IdempotencyKey))] = tx
+		},
+		p.indexLookupCallback,
+		func(v interface{}) bool { return (*(v.(**apitypes.ManagedTX))).IdempotencyKey != "" },
+	)
+	if err != nil {
+		return err
+	}
+	for idxKey, tx := range latest {
+		if err := p.writeKeyValue(ctx, []byte(idxKey), txDataKey(tx.ID)); err != nil {
+			return err
+		}
+	}
+	return p.writeKeyValue(ctx, []byte(txIdempotencyIndexedKey), []byte{})
+}
+
+func (p *leveldbPersistence) GetTransactionByIdempotencyKey(ctx context.Context, signer, idempotencyKey string) (tx *apitypes.ManagedTX, err error) {
+	idxKey := txIdempotencyIndexKey(signer, idempotencyKey)
+	p.txMux.RLock()
+	valKey, err := p.getKeyValue(ctx, idxKey)
+	if err == nil && valKey != nil {
+		err = p.readTransactionJSON(ctx, valKey, &tx)
+	}
+	p.txMux.RUnlock()
+	if err == nil && valKey != nil && tx == nil {
+		// The transaction has been deleted since it was submitted with this key
+		p.cleanupOrphanedTXIdxKeys(ctx, [][]byte{idxKey})
+	}
+	return tx, err
+}
+
 func (p *leveldbPersistence) DeleteTransaction(ctx context.Context, txID string) error {
+	p.txMux.Lock()
+	defer p.txMux.Unlock()
 	var tx *apitypes.ManagedTX
-	err := p.readJSON(ctx, txDataKey(txID), &tx)
+	err := p.readTransactionJSON(ctx, txDataKey(txID), &tx)
 	if err != nil || tx == nil {
 		return err
 	}
-	return p.deleteKeys(ctx,
+	p.discardBufferedTransaction(txDataKey(txID))
+	if p.statusCounts != nil {
+		p.statusCounts[tx.Status]--
+	}
+	// Index entries for hashes replaced by a resubmission are cleaned up if they are looked up
+	keys := [][]byte{
 		txDataKey(txID),
 		txCreatedIndexKey(tx),
 		txPendingIndexKey(tx.SequenceID),
-		txNonceAllocationKey(tx.TransactionHeaders.From, tx.Nonce),
-	)
+		txSignerPendingIndexKey(tx),
+		txNonceAllocationKey(tx.NonceKey(), tx.Nonce),
+	}
+	if tx.DependsOn != "" {
+		keys = append(keys, txDependsOnIndexKey(tx))
+	}
+	keys = append(keys, txLabelIndexKeys(tx)...)
+	for _, hash := range transactionHashes(tx) {
+		keys = append(keys, txHashIndexKey(hash))
+	}
+	if tx.IdempotencyKey != "" {
+		// The index only refers to this transaction if the key has not been reused since
+		idempotencyKey := txIdempotencyIndexKey(tx.NonceKey(), tx.IdempotencyKey)
+		if current, err := p.getKeyValue(ctx, idempotencyKey); err != nil {
+			return err
+		} else if string(current) == string(txDataKey(txID)) {
+			keys = append(keys, idempotencyKey)
+		}
+	}
+	return p.deleteKeys(ctx, keys...)
+}
+
+// CountTransactionsByStatus scans the stored transactions the first time it is called, and from then on
+// maintains the counts as transactions are written and deleted - so only the first call is expensive
+func (p *leveldbPersistence) CountTransactionsByStatus(ctx context.Context) (map[apitypes.TxStatus]int, error) {
+	p.txMux.Lock()
+	defer p.txMux.Unlock()
+	if p.statusCounts == nil {
+		counts := make(map[apitypes.TxStatus]int)
+		// Only updates to transactions that are already pending are buffered, so the stored status is current
+		it := p.db.NewIterator(util.BytesPrefix([]byte(transactionsPrefix)), &opt.ReadOptions{DontFillCache: true})
+		defer it.Release()
+		for it.Next() {
+			var tx struct {
+				Status apitypes.TxStatus `json:"status"`
+			}
+			if err := json.Unmarshal(it.Value(), &tx); err != nil {
+				return nil, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceUnmarshalFailed)
+			}
+			counts[tx.Status]++
+		}
+		if err := it.Error(); err != nil {
+			return nil, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, transactionsPrefix)
+		}
+		p.statusCounts = counts
+	}
+	counts := make(map[apitypes.TxStatus]int, len(p.statusCounts))
+	for status, count := range p.statusCounts {
+		if count > 0 {
+			counts[status] = count
+		}
+	}
+	return counts, nil
+}
+
+func (p *leveldbPersistence) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	p.leaseMux.Lock()
+	defer p.leaseMux.Unlock()
+	key := []byte(leasesPrefix + name)
+	var existing *leaseRecord
+	if err := p.readJSON(ctx, key, &existing); err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if existing != nil && existing.Holder != holder && now.Before(*existing.Expires.Time()) {
+		return false, nil
+	}
+	expires := fftypes.FFTime(now.Add(ttl))
+	if err := p.writeJSON(ctx, key, &leaseRecord{Holder: holder, Expires: &expires}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *leveldbPersistence) ReleaseLease(ctx context.Context, name, holder string) error {
+	p.leaseMux.Lock()
+	defer p.leaseMux.Unlock()
+	key := []byte(leasesPrefix + name)
+	var existing *leaseRecord
+	if err := p.readJSON(ctx, key, &existing); err != nil || existing == nil || existing.Holder != holder {
+		return err
+	}
+	return p.deleteKeys(ctx, key)
+}
+
+func (p *leveldbPersistence) GetSubmissionControl(ctx context.Context) (control *apitypes.SubmissionControl, err error) {
+	err = p.readJSON(ctx, []byte(submissionControlKey), &control)
+	return control, err
+}
+
+func (p *leveldbPersistence) WriteSubmissionControl(ctx context.Context, control *apitypes.SubmissionControl) error {
+	// Always synced to disk, so a crash straight after pausing cannot resume submissions on restart
+	return p.writeJSONSync(ctx, []byte(submissionControlKey), control, true)
 }
 
 func (p *leveldbPersistence) Close(ctx context.Context) {
+	p.stopWriteBatch(ctx)
 	err := p.db.Close()
 	if err != nil {
 		log.L(ctx).Warnf("Error closing leveldb: %s", err)
@@ -95,261 +95,111 @@ func TestLevelDBInitFail(t *testing.T) {
 }
 
 func TestReadWriteStreams(t *testing.T) {
-
 	p, done := newTestLevelDBPersistence(t)
 	defer done()
-
-	ctx := context.Background()
-	s1 := &apitypes.EventStream{
-		ID:   apitypes.NewULID(), // ensure we get sequentially ascending IDs
-		Name: strPtr("stream1"),
-	}
-	p.WriteStream(ctx, s1)
-	s2 := &apitypes.EventStream{
-		ID:   apitypes.NewULID(),
-		Name: strPtr("stream2"),
-	}
-	p.WriteStream(ctx, s2)
-	s3 := &apitypes.EventStream{
-		ID:   apitypes.NewULID(),
-		Name: strPtr("stream3"),
-	}
-	p.WriteStream(ctx, s3)
-
-	streams, err := p.ListStreams(ctx, nil, 0, SortDirectionDescending)
-	assert.NoError(t, err)
-	assert.Len(t, streams, 3)
-
-	assert.Equal(t, s3.ID, streams[0].ID)
-	assert.Equal(t, s2.ID, streams[1].ID)
-	assert.Equal(t, s1.ID, streams[2].ID)
-
-	// Test pagination
-
-	streams, err = p.ListStreams(ctx, nil, 2, SortDirectionDescending)
-	assert.NoError(t, err)
-	assert.Len(t, streams, 2)
-	assert.Equal(t, s3.ID, streams[0].ID)
-	assert.Equal(t, s2.ID, streams[1].ID)
-
-	streams, err = p.ListStreams(ctx, streams[1].ID, 2, SortDirectionDescending)
-	assert.NoError(t, err)
-	assert.Len(t, streams, 1)
-	assert.Equal(t, s1.ID, streams[0].ID)
-
-	// Test delete
-
-	err = p.DeleteStream(ctx, s2.ID)
-	assert.NoError(t, err)
-	streams, err = p.ListStreams(ctx, nil, 2, SortDirectionDescending)
-	assert.NoError(t, err)
-	assert.Len(t, streams, 2)
-	assert.Equal(t, s3.ID, streams[0].ID)
-	assert.Equal(t, s1.ID, streams[1].ID)
-
-	// Test get direct
-
-	s, err := p.GetStream(ctx, s3.ID)
-	assert.NoError(t, err)
-	assert.Equal(t, s3.ID, s.ID)
-	assert.Equal(t, s3.Name, s.Name)
-
-	s, err = p.GetStream(ctx, s2.ID)
-	assert.NoError(t, err)
-	assert.Nil(t, s)
+	testReadWriteStreams(t, p)
 }
 
 func TestReadWriteListeners(t *testing.T) {
-
 	p, done := newTestLevelDBPersistence(t)
 	defer done()
-
-	ctx := context.Background()
-
-	sID1 := apitypes.NewULID()
-	sID2 := apitypes.NewULID()
-
-	s1l1 := &apitypes.Listener{
-		ID:       apitypes.NewULID(),
-		StreamID: sID1,
-	}
-	err := p.WriteListener(ctx, s1l1)
-	assert.NoError(t, err)
-
-	s2l1 := &apitypes.Listener{
-		ID:       apitypes.NewULID(),
-		StreamID: sID2,
-	}
-	err = p.WriteListener(ctx, s2l1)
-	assert.NoError(t, err)
-
-	s1l2 := &apitypes.Listener{
-		ID:       apitypes.NewULID(),
-		StreamID: sID1,
-	}
-	err = p.WriteListener(ctx, s1l2)
-	assert.NoError(t, err)
-
-	listeners, err := p.ListListeners(ctx, nil, 0, SortDirectionDescending)
-	assert.NoError(t, err)
-	assert.Len(t, listeners, 3)
-
-	assert.Equal(t, s1l2.ID, listeners[0].ID)
-	assert.Equal(t, s2l1.ID, listeners[1].ID)
-	assert.Equal(t, s1l1.ID, listeners[2].ID)
-
-	// Test stream filter
-
-	listeners, err = p.ListStreamListeners(ctx, nil, 0, SortDirectionDescending, sID1)
-	assert.NoError(t, err)
-	assert.Len(t, listeners, 2)
-	assert.Equal(t, s1l2.ID, listeners[0].ID)
-	assert.Equal(t, s1l1.ID, listeners[1].ID)
-
-	// Test delete
-
-	err = p.DeleteListener(ctx, s2l1.ID)
-	assert.NoError(t, err)
-	listeners, err = p.ListStreamListeners(ctx, nil, 0, SortDirectionDescending, sID2)
-	assert.NoError(t, err)
-	assert.Len(t, listeners, 0)
-
-	// Test get direct
-
-	l, err := p.GetListener(ctx, s1l2.ID)
-	assert.NoError(t, err)
-	assert.Equal(t, s1l2.ID, l.ID)
-
-	l, err = p.GetListener(ctx, s2l1.ID)
-	assert.NoError(t, err)
-	assert.Nil(t, l)
+	testReadWriteListeners(t, p)
 }
 
 func TestReadWriteCheckpoints(t *testing.T) {
-
 	p, done := newTestLevelDBPersistence(t)
 	defer done()
-
-	ctx := context.Background()
-	cp1 := &apitypes.EventStreamCheckpoint{
-		StreamID: apitypes.NewULID(),
-	}
-	cp2 := &apitypes.EventStreamCheckpoint{
-		StreamID: apitypes.NewULID(),
-	}
-
-	err := p.WriteCheckpoint(ctx, cp1)
-	assert.NoError(t, err)
-
-	err = p.WriteCheckpoint(ctx, cp2)
-	assert.NoError(t, err)
-
-	err = p.DeleteCheckpoint(ctx, cp1.StreamID)
-	assert.NoError(t, err)
-
-	err = p.DeleteCheckpoint(ctx, cp1.StreamID)
-	assert.NoError(t, err) // No-op
-
-	cp, err := p.GetCheckpoint(ctx, cp1.StreamID)
-	assert.NoError(t, err)
-	assert.Nil(t, cp)
-
-	cp, err = p.GetCheckpoint(ctx, cp2.StreamID)
-	assert.NoError(t, err)
-	assert.Equal(t, cp2.StreamID, cp.StreamID)
-}
-
-func newTestTX(signer string, nonce int64, status apitypes.TxStatus) *apitypes.ManagedTX {
-	return &apitypes.ManagedTX{
-		ID:      fmt.Sprintf("ns1/%s", fftypes.NewUUID()),
-		Created: fftypes.Now(),
-		TransactionHeaders: ffcapi.TransactionHeaders{
-			From: signer,
-		},
-		SequenceID: apitypes.NewULID(),
-		Nonce:      fftypes.NewFFBigInt(nonce),
-		Status:     status,
-	}
+	testReadWriteCheckpoints(t, p)
 }
 
 func TestReadWriteManagedTransactions(t *testing.T) {
-
 	p, done := newTestLevelDBPersistence(t)
 	defer done()
+	testReadWriteManagedTransactions(t, p)
+}
 
-	ctx := context.Background()
-	submitNewTX := func(signer string, nonce int64, status apitypes.TxStatus) *apitypes.ManagedTX {
-		tx := newTestTX(signer, nonce, status)
-		err := p.WriteTransaction(ctx, tx, true)
-		assert.NoError(t, err)
-		return tx
-	}
-
-	s1t1 := submitNewTX("0xaaaaa", 10001, apitypes.TxStatusSucceeded)
-	s2t1 := submitNewTX("0xbbbbb", 10001, apitypes.TxStatusFailed)
-	s1t2 := submitNewTX("0xaaaaa", 10002, apitypes.TxStatusPending)
-	s1t3 := submitNewTX("0xaaaaa", 10003, apitypes.TxStatusPending)
-
-	// Check dup
-	err := p.WriteTransaction(ctx, s1t1, true)
-	assert.Regexp(t, "FF21065", err)
-
-	txns, err := p.ListTransactionsByCreateTime(ctx, nil, 0, SortDirectionDescending)
-	assert.NoError(t, err)
-	assert.Len(t, txns, 4)
+func TestTransactionBecomesPending(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testTransactionBecomesPending(t, p)
+}
 
-	assert.Equal(t, s1t3.ID, txns[0].ID)
-	assert.Equal(t, s1t2.ID, txns[1].ID)
-	assert.Equal(t, s2t1.ID, txns[2].ID)
-	assert.Equal(t, s1t1.ID, txns[3].ID)
+func TestGetTransactionByHash(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testGetTransactionByHash(t, p)
+}
 
-	// Only list pending
+func TestGetTransactionByIdempotencyKey(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testGetTransactionByIdempotencyKey(t, p)
+}
 
-	txns, err = p.ListTransactionsPending(ctx, nil, 0, SortDirectionDescending)
-	assert.NoError(t, err)
-	assert.Len(t, txns, 2)
+func TestListTransactionsByStatus(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testListTransactionsByStatus(t, p)
+}
 
-	assert.Equal(t, s1t3.ID, txns[0].ID)
-	assert.Equal(t, s1t2.ID, txns[1].ID)
+func TestListTransactionsByNonceKey(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testListTransactionsByNonceKey(t, p)
+}
 
-	// List with time range
+func TestCountTransactionsByStatus(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testCountTransactionsByStatus(t, p)
+}
 
-	txns, err = p.ListTransactionsByCreateTime(ctx, s1t2, 0, SortDirectionDescending)
-	assert.NoError(t, err)
-	assert.Len(t, txns, 2)
-	assert.Equal(t, s2t1.ID, txns[0].ID)
-	assert.Equal(t, s1t1.ID, txns[1].ID)
+func TestListTransactionsByRequestID(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testListTransactionsByRequestID(t, p)
+}
 
-	// Test delete, and querying by nonce to limit TX returned
+func TestListTransactionsByDependsOn(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testListTransactionsByDependsOn(t, p)
+}
 
-	err = p.DeleteTransaction(ctx, s1t2.ID)
-	assert.NoError(t, err)
-	txns, err = p.ListTransactionsByNonce(ctx, "0xaaaaa", s1t1.Nonce, 0, SortDirectionAscending)
-	assert.NoError(t, err)
-	assert.Len(t, txns, 1)
-	assert.Equal(t, s1t3.ID, txns[0].ID)
+func TestGetTransactionsByIDs(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testGetTransactionsByIDs(t, p)
+}
 
-	// Check we can use after with the deleted nonce, and not skip the one after
-	txns, err = p.ListTransactionsByNonce(ctx, "0xaaaaa", s1t2.Nonce, 0, SortDirectionAscending)
-	assert.NoError(t, err)
-	assert.Len(t, txns, 1)
-	assert.Equal(t, s1t3.ID, txns[0].ID)
+func TestListTransactionsByLabels(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testListTransactionsByLabels(t, p)
+}
 
-	// Test get direct
+func TestLeases(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testLeases(t, p)
+}
 
-	v, err := p.GetTransactionByID(ctx, s1t3.ID)
-	assert.NoError(t, err)
-	assert.Equal(t, s1t3.ID, v.ID)
-	assert.Equal(t, s1t3.Nonce, v.Nonce)
+func TestSubmissionControl(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testSubmissionControl(t, p)
+}
 
-	v, err = p.GetTransactionByNonce(ctx, "0xbbbbb", s2t1.Nonce)
-	assert.NoError(t, err)
-	assert.Equal(t, s2t1.ID, v.ID)
-	assert.Equal(t, s2t1.Nonce, v.Nonce)
+func TestListTransactionsByCreateTimeRange(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testListTransactionsByCreateTimeRange(t, p)
+}
 
-	v, err = p.GetTransactionByID(ctx, s1t2.ID)
-	assert.NoError(t, err)
-	assert.Nil(t, v)
+func TestDeadLetters(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	testDeadLetters(t, p)
 }
 
 func TestListStreamsBadJSON(t *testing.T) {
@@ -405,6 +255,42 @@ func TestWriteTXFail(t *testing.T) {
 
 }
 
+func TestCountTransactionsByStatusFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	p.db.Close()
+
+	_, err := p.CountTransactionsByStatus(context.Background())
+	assert.Regexp(t, "FF21055", err)
+
+}
+
+func TestCountTransactionsByStatusFailUnmarshal(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	err := p.db.Put(txDataKey("tx1"), []byte("{! not json"), &opt.WriteOptions{})
+	assert.NoError(t, err)
+
+	_, err = p.CountTransactionsByStatus(context.Background())
+	assert.Regexp(t, "FF21054", err)
+
+}
+
+func TestWriteTXCountedFailRead(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	_, err := p.CountTransactionsByStatus(context.Background())
+	assert.NoError(t, err)
+	p.db.Close()
+
+	err = p.WriteTransaction(context.Background(), newTestTX("0x1234", 1000, apitypes.TxStatusPending), false)
+	assert.Error(t, err)
+
+}
+
 func TestWriteCheckpointFailMarshal(t *testing.T) {
 	p, done := newTestLevelDBPersistence(t)
 	defer done()
@@ -480,6 +366,129 @@ func TestListManagedTransactionFail(t *testing.T) {
 
 }
 
+func TestLevelDBIndexesPendingBySignerOnStartup(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	ctx := context.Background()
+
+	// A database written before pending transactions were indexed by signer
+	tx := newTestTX("0xaaaaa", 12345, apitypes.TxStatusPending)
+	err := p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+	err = p.deleteKeys(ctx, txSignerPendingIndexKey(tx))
+	assert.NoError(t, err)
+	txns, err := p.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, "0xaaaaa", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Empty(t, txns)
+
+	p.Close(ctx)
+	pp, err := NewLevelDBPersistence(ctx)
+	assert.NoError(t, err)
+	p = pp.(*leveldbPersistence)
+	defer p.Close(ctx)
+
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, "0xaaaaa", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, tx.ID, txns[0].ID)
+
+}
+
+func TestLevelDBIndexPendingBySignerFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	ctx := context.Background()
+
+	txID := fmt.Sprintf("ns1:%s", apitypes.NewULID())
+	err := p.writeKeyValue(ctx, txPendingIndexKey(apitypes.NewULID()), txDataKey(txID))
+	assert.NoError(t, err)
+	err = p.db.Put(txDataKey(txID), []byte("{! not json"), &opt.WriteOptions{})
+	assert.NoError(t, err)
+
+	p.Close(ctx)
+	_, err = NewLevelDBPersistence(ctx)
+	assert.Regexp(t, "FF21054", err)
+
+}
+
+func TestLevelDBIndexesIdempotencyKeysOnStartup(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	ctx := context.Background()
+
+	// A database written before idempotency keys were indexed
+	t1 := newTestTX("0xaaaaa", 12345, apitypes.TxStatusSucceeded)
+	t1.IdempotencyKey = "key1"
+	t2 := newTestTX("0xaaaaa", 12346, apitypes.TxStatusPending)
+	t2.IdempotencyKey = "key1"
+	t3 := newTestTX("0xaaaaa", 12347, apitypes.TxStatusPending)
+	for _, tx := range []*apitypes.ManagedTX{t1, t2, t3} {
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+	}
+	err := p.deleteKeys(ctx, txIdempotencyIndexKey("0xaaaaa", "key1"), []byte(txIdempotencyIndexedKey))
+	assert.NoError(t, err)
+	v, err := p.GetTransactionByIdempotencyKey(ctx, "0xaaaaa", "key1")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	p.Close(ctx)
+	pp, err := NewLevelDBPersistence(ctx)
+	assert.NoError(t, err)
+	p = pp.(*leveldbPersistence)
+	defer p.Close(ctx)
+
+	v, err = p.GetTransactionByIdempotencyKey(ctx, "0xaaaaa", "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, t2.ID, v.ID)
+	indexed, err := p.getKeyValue(ctx, []byte(txIdempotencyIndexedKey))
+	assert.NoError(t, err)
+	assert.NotNil(t, indexed)
+
+}
+
+func TestLevelDBIndexIdempotencyKeysFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	ctx := context.Background()
+
+	tx := &apitypes.ManagedTX{
+		ID:         fmt.Sprintf("ns1:%s", apitypes.NewULID()),
+		Created:    fftypes.Now(),
+		SequenceID: apitypes.NewULID(),
+	}
+	err := p.writeKeyValue(ctx, txCreatedIndexKey(tx), txDataKey(tx.ID))
+	assert.NoError(t, err)
+	err = p.db.Put(txDataKey(tx.ID), []byte("{! not json"), &opt.WriteOptions{})
+	assert.NoError(t, err)
+	err = p.deleteKeys(ctx, []byte(txIdempotencyIndexedKey))
+	assert.NoError(t, err)
+
+	p.Close(ctx)
+	_, err = NewLevelDBPersistence(ctx)
+	assert.Regexp(t, "FF21054", err)
+
+}
+
+func TestGetTransactionByIdempotencyKeyCleanupOrphans(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	ctx := context.Background()
+
+	idxKey := txIdempotencyIndexKey("0xaaaaa", "key1")
+	err := p.writeKeyValue(ctx, idxKey, txDataKey("ns1:missing"))
+	assert.NoError(t, err)
+
+	v, err := p.GetTransactionByIdempotencyKey(ctx, "0xaaaaa", "key1")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	cleanedUpIndex, err := p.getKeyValue(ctx, idxKey)
+	assert.NoError(t, err)
+	assert.Nil(t, cleanedUpIndex)
+
+}
+
 func TestListManagedTransactionCleanupOrphans(t *testing.T) {
 	p, done := newTestLevelDBPersistence(t)
 	defer done()
@@ -562,6 +571,64 @@ func TestGetTransactionByNonceFail(t *testing.T) {
 
 }
 
+func TestListStreamDeadLettersBadJSON(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	sID := apitypes.NewULID()
+	err := p.db.Put([]byte(streamDeadLettersPrefix(sID)+"bad"), []byte("{! not json"), &opt.WriteOptions{})
+	assert.NoError(t, err)
+
+	_, err = p.ListStreamDeadLetters(context.Background(), sID, 0)
+	assert.Regexp(t, "FF21054", err)
+
+}
+
+func TestGetTransactionsByIDsFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	p.Close(context.Background())
+
+	_, err := p.GetTransactionsByIDs(context.Background(), []string{"tx1"})
+	assert.Regexp(t, "FF21055", err)
+
+}
+
+func TestGetTransactionByHashFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	p.Close(context.Background())
+
+	_, err := p.GetTransactionByHash(context.Background(), "0x12345")
+	assert.Regexp(t, "FF21055", err)
+
+}
+
+func TestGetTransactionByIdempotencyKeyFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+	p.Close(context.Background())
+
+	_, err := p.GetTransactionByIdempotencyKey(context.Background(), "0xaaaaa", "key1")
+	assert.Regexp(t, "FF21055", err)
+
+}
+
+func TestWriteTransactionHashIndexFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	tx := newTestTX("0xaaa", 10001, apitypes.TxStatusPending)
+	err := p.WriteTransaction(context.Background(), tx, true)
+	assert.NoError(t, err)
+	p.Close(context.Background())
+
+	tx.TransactionHash = "0x12345"
+	err = p.WriteTransaction(context.Background(), tx, false)
+	assert.Regexp(t, "FF21055", err)
+
+}
+
 func TestIterateReverseJSONFailIdxResolve(t *testing.T) {
 	p, done := newTestLevelDBPersistence(t)
 	defer done()
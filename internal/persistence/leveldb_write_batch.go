@@ -0,0 +1,160 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// txWriteBatch buffers updates to transactions that are already pending, so that
+// rapid updates to the same transaction are coalesced into a single write, and
+// updates to many transactions are written to LevelDB together.
+//
+// Only the data record of a pending transaction is ever buffered - new transactions,
+// and any change to the indexes (including a transaction reaching a final state)
+// are written through. So a crash loses at most the latest updates to transactions
+// that are still pending, which the policy engine will re-process.
+//
+// All fields are protected by the txMux of the persistence.
+type txWriteBatch struct {
+	size          int
+	flushInterval time.Duration
+	buffered      map[string][]byte
+	cancelCtx     context.CancelFunc
+	loopDone      chan struct{}
+}
+
+func (p *leveldbPersistence) startWriteBatch(ctx context.Context, size int, flushInterval time.Duration) {
+	p.writeBatch = &txWriteBatch{
+		size:          size,
+		flushInterval: flushInterval,
+		buffered:      make(map[string][]byte),
+		loopDone:      make(chan struct{}),
+	}
+	var loopCtx context.Context
+	loopCtx, p.writeBatch.cancelCtx = context.WithCancel(log.WithLogField(ctx, "role", "persistence-write-batch"))
+	go p.writeBatchLoop(loopCtx)
+}
+
+// stopWriteBatch stops the flush loop, and writes anything that is still buffered
+func (p *leveldbPersistence) stopWriteBatch(ctx context.Context) {
+	if p.writeBatch == nil {
+		return
+	}
+	p.writeBatch.cancelCtx()
+	<-p.writeBatch.loopDone
+	p.txMux.Lock()
+	defer p.txMux.Unlock()
+	if err := p.flushWriteBatch(ctx); err != nil {
+		log.L(ctx).Errorf("Failed to write %d buffered transaction updates on close: %s", len(p.writeBatch.buffered), err)
+	}
+}
+
+func (p *leveldbPersistence) writeBatchLoop(ctx context.Context) {
+	defer close(p.writeBatch.loopDone)
+	ticker := time.NewTicker(p.writeBatch.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.L(ctx).Debugf("Write batch loop exiting")
+			return
+		case <-ticker.C:
+			p.txMux.Lock()
+			err := p.flushWriteBatch(ctx)
+			p.txMux.Unlock()
+			if err != nil {
+				log.L(ctx).Warnf("Failed to write buffered transaction updates (will retry): %s", err)
+			}
+		}
+	}
+}
+
+// bufferTransactionUpdate buffers an update to a transaction that is already pending.
+// Returns false if the update is not eligible, and must be written through.
+// Must be called holding the txMux write lock.
+func (p *leveldbPersistence) bufferTransactionUpdate(ctx context.Context, tx *apitypes.ManagedTX) (bool, error) {
+	if p.writeBatch == nil {
+		return false, nil
+	}
+	// If the pending index is not yet written, then this is a transition to pending - which
+	// must be written through along with the index
+	isPending, err := p.db.Has(txPendingIndexKey(tx.SequenceID), &opt.ReadOptions{})
+	if err != nil {
+		return false, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, txPendingIndexKey(tx.SequenceID))
+	}
+	if !isPending {
+		return false, nil
+	}
+	b, err := json.Marshal(tx)
+	if err != nil {
+		return false, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceMarshalFailed)
+	}
+	p.writeBatch.buffered[string(txDataKey(tx.ID))] = b
+	log.L(ctx).Debugf("Buffered %s", txDataKey(tx.ID))
+	if len(p.writeBatch.buffered) >= p.writeBatch.size {
+		return true, p.flushWriteBatch(ctx)
+	}
+	return true, nil
+}
+
+// flushWriteBatch writes all buffered updates in a single LevelDB batch. On failure
+// the updates remain buffered, for the next flush to retry.
+// Must be called holding the txMux write lock.
+func (p *leveldbPersistence) flushWriteBatch(ctx context.Context) error {
+	if len(p.writeBatch.buffered) == 0 {
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	for k, v := range p.writeBatch.buffered {
+		batch.Put([]byte(k), v)
+	}
+	if err := p.db.Write(batch, &opt.WriteOptions{Sync: p.syncWrites}); err != nil {
+		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceWriteFailed)
+	}
+	log.L(ctx).Debugf("Wrote batch of %d buffered transaction updates", len(p.writeBatch.buffered))
+	p.writeBatch.buffered = make(map[string][]byte)
+	return nil
+}
+
+// discardBufferedTransaction drops any buffered update for a transaction that is being written through.
+// Must be called holding the txMux write lock.
+func (p *leveldbPersistence) discardBufferedTransaction(key []byte) {
+	if p.writeBatch != nil {
+		delete(p.writeBatch.buffered, string(key))
+	}
+}
+
+// getTransactionValue returns a buffered transaction update if there is one, or otherwise reads the DB.
+// Must be called holding the txMux (read or write).
+func (p *leveldbPersistence) getTransactionValue(ctx context.Context, key []byte) ([]byte, error) {
+	if p.writeBatch != nil {
+		if b, ok := p.writeBatch.buffered[string(key)]; ok {
+			return b, nil
+		}
+	}
+	return p.getKeyValue(ctx, key)
+}
@@ -0,0 +1,313 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLevelDBWriteBatchPersistence(t *testing.T, size int, flushInterval string) (*leveldbPersistence, string, func()) {
+
+	dir, err := ioutil.TempDir("", "ldb_*")
+	assert.NoError(t, err)
+
+	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceLevelDBPath, dir)
+	config.Set(tmconfig.PersistenceLevelDBWriteBatchSize, size)
+	config.Set(tmconfig.PersistenceLevelDBWriteBatchFlushInterval, flushInterval)
+
+	pp, err := NewLevelDBPersistence(context.Background())
+	assert.NoError(t, err)
+
+	p := pp.(*leveldbPersistence)
+	return p, dir, func() {
+		p.Close(context.Background())
+		os.RemoveAll(dir)
+	}
+
+}
+
+func readStoredTX(t *testing.T, p *leveldbPersistence, txID string) (tx *apitypes.ManagedTX) {
+	b, err := p.getKeyValue(context.Background(), txDataKey(txID))
+	assert.NoError(t, err)
+	if b != nil {
+		err = json.Unmarshal(b, &tx)
+		assert.NoError(t, err)
+	}
+	return tx
+}
+
+func bufferedCount(p *leveldbPersistence) int {
+	p.txMux.RLock()
+	defer p.txMux.RUnlock()
+	return len(p.writeBatch.buffered)
+}
+
+func TestWriteBatchReadWriteManagedTransactions(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	testReadWriteManagedTransactions(t, p)
+}
+
+func TestWriteBatchTransactionBecomesPending(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	testTransactionBecomesPending(t, p)
+	// Only the final re-write, once the transaction was already pending, is buffered
+	assert.Equal(t, 1, bufferedCount(p))
+}
+
+func TestWriteBatchGetTransactionByHash(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	testGetTransactionByHash(t, p)
+}
+
+func TestWriteBatchGetTransactionByIdempotencyKey(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	testGetTransactionByIdempotencyKey(t, p)
+}
+
+func TestWriteBatchListTransactionsByStatus(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	testListTransactionsByStatus(t, p)
+}
+
+func TestWriteBatchCoalescesUpdates(t *testing.T) {
+	p, dir, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	ctx := context.Background()
+
+	tx := newTestTX("0x1234", 1000, apitypes.TxStatusPending)
+	err := p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+
+	// Make a number of rapid updates to the same transaction
+	for i := int64(1); i <= 5; i++ {
+		tx.GasPrice = fftypes.JSONAnyPtr(fftypes.NewFFBigInt(i).String())
+		err = p.WriteTransaction(ctx, tx, false)
+		assert.NoError(t, err)
+	}
+
+	// Only the create has been written to the DB, with one coalesced update buffered
+	assert.Nil(t, readStoredTX(t, p, tx.ID).GasPrice)
+	assert.Equal(t, 1, bufferedCount(p))
+
+	// But all reads reflect the latest update
+	tx1, err := p.GetTransactionByID(ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, `5`, tx1.GasPrice.String())
+	tx1, err = p.GetTransactionByNonce(ctx, "0x1234", fftypes.NewFFBigInt(1000))
+	assert.NoError(t, err)
+	assert.Equal(t, `5`, tx1.GasPrice.String())
+	txs, err := p.ListTransactionsPending(ctx, nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txs, 1)
+	assert.Equal(t, `5`, txs[0].GasPrice.String())
+
+	// Closing writes the buffered update
+	p.Close(ctx)
+	pp, err := NewLevelDBPersistence(ctx)
+	assert.NoError(t, err)
+	p = pp.(*leveldbPersistence)
+	assert.Equal(t, dir, config.GetString(tmconfig.PersistenceLevelDBPath))
+	assert.Equal(t, `5`, readStoredTX(t, p, tx.ID).GasPrice.String())
+}
+
+func TestWriteBatchFlushOnSize(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 2, "1h")
+	defer done()
+	ctx := context.Background()
+
+	tx1 := newTestTX("0x1234", 1000, apitypes.TxStatusPending)
+	tx2 := newTestTX("0x1234", 1001, apitypes.TxStatusPending)
+	for _, tx := range []*apitypes.ManagedTX{tx1, tx2} {
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+	}
+
+	tx1.TransactionHash = "0xaaaa"
+	err := p.WriteTransaction(ctx, tx1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bufferedCount(p))
+	assert.Empty(t, readStoredTX(t, p, tx1.ID).TransactionHash)
+
+	tx2.TransactionHash = "0xbbbb"
+	err = p.WriteTransaction(ctx, tx2, false)
+	assert.NoError(t, err)
+	assert.Zero(t, bufferedCount(p))
+	assert.Equal(t, "0xaaaa", readStoredTX(t, p, tx1.ID).TransactionHash)
+	assert.Equal(t, "0xbbbb", readStoredTX(t, p, tx2.ID).TransactionHash)
+}
+
+func TestWriteBatchFlushOnInterval(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 100, "1ms")
+	defer done()
+	ctx := context.Background()
+
+	tx := newTestTX("0x1234", 1000, apitypes.TxStatusPending)
+	err := p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+
+	tx.TransactionHash = "0xaaaa"
+	err = p.WriteTransaction(ctx, tx, false)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return bufferedCount(p) == 0 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, "0xaaaa", readStoredTX(t, p, tx.ID).TransactionHash)
+}
+
+func TestWriteBatchCrashKeepsFinalStates(t *testing.T) {
+	p, dir, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	ctx := context.Background()
+
+	tx1 := newTestTX("0x1234", 1000, apitypes.TxStatusPending)
+	tx2 := newTestTX("0x1234", 1001, apitypes.TxStatusPending)
+	for _, tx := range []*apitypes.ManagedTX{tx1, tx2} {
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+		tx.TransactionHash = "0xaaaa"
+		err = p.WriteTransaction(ctx, tx, false)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, bufferedCount(p))
+
+	// The first transaction succeeds, which is written through (superseding the buffered update)
+	tx1.Status = apitypes.TxStatusSucceeded
+	err := p.WriteTransaction(ctx, tx1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bufferedCount(p))
+
+	// Simulate a crash, by closing the DB underneath the persistence without a flush
+	err = p.db.Close()
+	assert.NoError(t, err)
+	pp, err := NewLevelDBPersistence(ctx)
+	assert.NoError(t, err)
+	p2 := pp.(*leveldbPersistence)
+	defer p2.Close(ctx)
+	assert.Equal(t, dir, config.GetString(tmconfig.PersistenceLevelDBPath))
+
+	// The final state survived
+	tx, err := p2.GetTransactionByID(ctx, tx1.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusSucceeded, tx.Status)
+	assert.Equal(t, "0xaaaa", tx.TransactionHash)
+
+	// The buffered update to the pending transaction was lost, but the transaction is still pending
+	txs, err := p2.ListTransactionsPending(ctx, nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txs, 1)
+	assert.Equal(t, tx2.ID, txs[0].ID)
+	assert.Empty(t, txs[0].TransactionHash)
+}
+
+func TestWriteBatchDeleteDiscardsBuffered(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	ctx := context.Background()
+
+	tx := newTestTX("0x1234", 1000, apitypes.TxStatusPending)
+	err := p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+	tx.TransactionHash = "0xaaaa"
+	err = p.WriteTransaction(ctx, tx, false)
+	assert.NoError(t, err)
+
+	err = p.DeleteTransaction(ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.Zero(t, bufferedCount(p))
+
+	tx1, err := p.GetTransactionByID(ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, tx1)
+}
+
+func TestWriteBatchFlushFailKeepsBuffered(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	ctx := context.Background()
+
+	tx := newTestTX("0x1234", 1000, apitypes.TxStatusPending)
+	err := p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+	tx.TransactionHash = "0xaaaa"
+	err = p.WriteTransaction(ctx, tx, false)
+	assert.NoError(t, err)
+
+	p.db.Close()
+
+	p.txMux.Lock()
+	err = p.flushWriteBatch(ctx)
+	p.txMux.Unlock()
+	assert.Regexp(t, "FF21056", err)
+	assert.Equal(t, 1, bufferedCount(p))
+}
+
+func TestWriteBatchFlushLoopFailKeepsBuffered(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	ctx := context.Background()
+
+	tx := newTestTX("0x1234", 1000, apitypes.TxStatusPending)
+	err := p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+	tx.TransactionHash = "0xaaaa"
+	err = p.WriteTransaction(ctx, tx, false)
+	assert.NoError(t, err)
+
+	p.db.Close()
+
+	// Restart the loop with a short interval, and check it retains the buffer across failures
+	p.writeBatch.cancelCtx()
+	<-p.writeBatch.loopDone
+	buffered := p.writeBatch.buffered
+	p.startWriteBatch(ctx, 10, time.Millisecond)
+	p.txMux.Lock()
+	p.writeBatch.buffered = buffered
+	p.txMux.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, bufferedCount(p))
+}
+
+func TestWriteBatchBufferCheckPendingFail(t *testing.T) {
+	p, _, done := newTestLevelDBWriteBatchPersistence(t, 10, "1h")
+	defer done()
+	ctx := context.Background()
+
+	tx := newTestTX("0x1234", 1000, apitypes.TxStatusPending)
+	err := p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+
+	p.db.Close()
+
+	err = p.WriteTransaction(ctx, tx, false)
+	assert.Regexp(t, "FF21055", err)
+}
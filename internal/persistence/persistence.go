@@ -18,6 +18,7 @@ package persistence
 
 import (
 	"context"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
@@ -28,6 +29,10 @@ type Persistence interface {
 	GetCheckpoint(ctx context.Context, streamID *fftypes.UUID) (*apitypes.EventStreamCheckpoint, error)
 	DeleteCheckpoint(ctx context.Context, streamID *fftypes.UUID) error
 
+	ListStreamDeadLetters(ctx context.Context, streamID *fftypes.UUID, limit int) ([]*apitypes.DeadLetter, error) // create time order, oldest first
+	WriteDeadLetter(ctx context.Context, deadLetter *apitypes.DeadLetter) error
+	DeleteDeadLetter(ctx context.Context, deadLetter *apitypes.DeadLetter) error
+
 	ListStreams(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection) ([]*apitypes.EventStream, error) // reverse UUIDv1 order
 	GetStream(ctx context.Context, streamID *fftypes.UUID) (*apitypes.EventStream, error)
 	WriteStream(ctx context.Context, spec *apitypes.EventStream) error
@@ -39,13 +44,74 @@ type Persistence interface {
 	WriteListener(ctx context.Context, spec *apitypes.Listener) error
 	DeleteListener(ctx context.Context, listenerID *fftypes.UUID) error
 
-	ListTransactionsByCreateTime(ctx context.Context, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)         // reverse create time order
-	ListTransactionsByNonce(ctx context.Context, signer string, after *fftypes.FFBigInt, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) // reverse nonce order within signer
-	ListTransactionsPending(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)                    // reverse UUIDv1 order, only those in pending state
+	// Transactions are indexed by signer using the nonce key (see apitypes.NonceKey), which is only the signer for the default connector
+	ListTransactionsByCreateTime(ctx context.Context, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)                                      // reverse create time order
+	ListTransactionsByCreateTimeRange(ctx context.Context, from, to *fftypes.FFTime, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)       // reverse create time order, with optional inclusive bounds on the create time
+	ListTransactionsByNonce(ctx context.Context, signer string, after *fftypes.FFBigInt, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)                              // reverse nonce order within signer
+	ListTransactionsPending(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)                                                 // reverse UUIDv1 order, only those in pending state
+	ListTransactionsByStatus(ctx context.Context, status apitypes.TxStatus, signer string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) // reverse create time order, or reverse nonce order if a signer is supplied
+	ListTransactionsByRequestID(ctx context.Context, requestID string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)                     // reverse create time order, only those with the caller supplied request ID
+	ListTransactionsByDependsOn(ctx context.Context, parentID string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)                      // reverse create time order, only those that declared a dependency on the parent transaction ID
+	ListTransactionsByLabels(ctx context.Context, labels map[string]string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)                // reverse create time order, only those with all of the labels
 	GetTransactionByID(ctx context.Context, txID string) (*apitypes.ManagedTX, error)
+	GetTransactionsByIDs(ctx context.Context, txIDs []string) ([]*apitypes.ManagedTX, error) // those that exist, in no particular order
 	GetTransactionByNonce(ctx context.Context, signer string, nonce *fftypes.FFBigInt) (*apitypes.ManagedTX, error)
-	WriteTransaction(ctx context.Context, tx *apitypes.ManagedTX, new bool) error // must reject if new is true, and the request ID is no
+	GetTransactionByHash(ctx context.Context, hash string) (*apitypes.ManagedTX, error)                             // any hash the transaction has been submitted with, including those replaced by a resubmission
+	GetTransactionByIdempotencyKey(ctx context.Context, signer, idempotencyKey string) (*apitypes.ManagedTX, error) // the most recently created transaction of the signer with the idempotency key
+	WriteTransaction(ctx context.Context, tx *apitypes.ManagedTX, new bool) error                                   // must reject if new is true, and the request ID is no
 	DeleteTransaction(ctx context.Context, txID string) error
+	CountTransactionsByStatus(ctx context.Context) (map[apitypes.TxStatus]int, error) // statuses with no transactions are omitted
+
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) // obtains or renews the lease, unless it is held by a different holder and has not expired
+	ReleaseLease(ctx context.Context, name, holder string) error                            // releases the lease, if it is still held by the holder
+
+	GetSubmissionControl(ctx context.Context) (*apitypes.SubmissionControl, error) // nil if it has never been written
+	WriteSubmissionControl(ctx context.Context, control *apitypes.SubmissionControl) error
 
 	Close(ctx context.Context)
 }
+
+// transactionHashes returns the on-chain hashes to index for a transaction - the most recent submission,
+// and any no-op submitted to cancel it
+func transactionHashes(tx *apitypes.ManagedTX) []string {
+	hashes := make([]string, 0, 2)
+	if tx.TransactionHash != "" {
+		hashes = append(hashes, tx.TransactionHash)
+	}
+	if tx.Cancel != nil && tx.Cancel.TransactionHash != "" {
+		hashes = append(hashes, tx.Cancel.TransactionHash)
+	}
+	return hashes
+}
+
+// labelIndexValue is the indexed form of a label. Label keys cannot contain '=', so it is unambiguous
+func labelIndexValue(key, value string) string {
+	return key + "=" + value
+}
+
+// firstLabel returns the label with the lowest key, so the same index is used for every page of a query
+func firstLabel(labels map[string]string) (key, value string) {
+	first := true
+	for k, v := range labels {
+		if first || k < key {
+			key, value, first = k, v, false
+		}
+	}
+	return key, value
+}
+
+// hasLabels returns true if the transaction has every one of the labels
+func hasLabels(tx *apitypes.ManagedTX, labels map[string]string) bool {
+	for k, v := range labels {
+		if txv, ok := tx.Labels[k]; !ok || txv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// leaseRecord is the persisted state of a lease, used for leader election between replicas sharing a store
+type leaseRecord struct {
+	Holder  string          `json:"holder"`
+	Expires *fftypes.FFTime `json:"expires"`
+}
@@ -0,0 +1,943 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// The tests in this file are run against every persistence implementation, to ensure they
+// all provide the same ordering and pagination semantics.
+
+func testReadWriteStreams(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	s1 := &apitypes.EventStream{
+		ID:   apitypes.NewULID(), // ensure we get sequentially ascending IDs
+		Name: strPtr("stream1"),
+	}
+	p.WriteStream(ctx, s1)
+	s2 := &apitypes.EventStream{
+		ID:   apitypes.NewULID(),
+		Name: strPtr("stream2"),
+	}
+	p.WriteStream(ctx, s2)
+	s3 := &apitypes.EventStream{
+		ID:   apitypes.NewULID(),
+		Name: strPtr("stream3"),
+	}
+	p.WriteStream(ctx, s3)
+
+	streams, err := p.ListStreams(ctx, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, streams, 3)
+
+	assert.Equal(t, s3.ID, streams[0].ID)
+	assert.Equal(t, s2.ID, streams[1].ID)
+	assert.Equal(t, s1.ID, streams[2].ID)
+
+	// Test pagination
+
+	streams, err = p.ListStreams(ctx, nil, 2, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, streams, 2)
+	assert.Equal(t, s3.ID, streams[0].ID)
+	assert.Equal(t, s2.ID, streams[1].ID)
+
+	streams, err = p.ListStreams(ctx, streams[1].ID, 2, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, streams, 1)
+	assert.Equal(t, s1.ID, streams[0].ID)
+
+	streams, err = p.ListStreams(ctx, s1.ID, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, streams, 2)
+	assert.Equal(t, s2.ID, streams[0].ID)
+	assert.Equal(t, s3.ID, streams[1].ID)
+
+	// Test delete
+
+	err = p.DeleteStream(ctx, s2.ID)
+	assert.NoError(t, err)
+	streams, err = p.ListStreams(ctx, nil, 2, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, streams, 2)
+	assert.Equal(t, s3.ID, streams[0].ID)
+	assert.Equal(t, s1.ID, streams[1].ID)
+
+	// Test get direct
+
+	s, err := p.GetStream(ctx, s3.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, s3.ID, s.ID)
+	assert.Equal(t, s3.Name, s.Name)
+
+	s, err = p.GetStream(ctx, s2.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+}
+
+func testReadWriteListeners(t *testing.T, p Persistence) {
+	ctx := context.Background()
+
+	sID1 := apitypes.NewULID()
+	sID2 := apitypes.NewULID()
+
+	s1l1 := &apitypes.Listener{
+		ID:       apitypes.NewULID(),
+		StreamID: sID1,
+	}
+	err := p.WriteListener(ctx, s1l1)
+	assert.NoError(t, err)
+
+	s2l1 := &apitypes.Listener{
+		ID:       apitypes.NewULID(),
+		StreamID: sID2,
+	}
+	err = p.WriteListener(ctx, s2l1)
+	assert.NoError(t, err)
+
+	s1l2 := &apitypes.Listener{
+		ID:       apitypes.NewULID(),
+		StreamID: sID1,
+	}
+	err = p.WriteListener(ctx, s1l2)
+	assert.NoError(t, err)
+
+	listeners, err := p.ListListeners(ctx, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, listeners, 3)
+
+	assert.Equal(t, s1l2.ID, listeners[0].ID)
+	assert.Equal(t, s2l1.ID, listeners[1].ID)
+	assert.Equal(t, s1l1.ID, listeners[2].ID)
+
+	// Test stream filter
+
+	listeners, err = p.ListStreamListeners(ctx, nil, 0, SortDirectionDescending, sID1)
+	assert.NoError(t, err)
+	assert.Len(t, listeners, 2)
+	assert.Equal(t, s1l2.ID, listeners[0].ID)
+	assert.Equal(t, s1l1.ID, listeners[1].ID)
+
+	// Test delete
+
+	err = p.DeleteListener(ctx, s2l1.ID)
+	assert.NoError(t, err)
+	listeners, err = p.ListStreamListeners(ctx, nil, 0, SortDirectionDescending, sID2)
+	assert.NoError(t, err)
+	assert.Len(t, listeners, 0)
+
+	// Test get direct
+
+	l, err := p.GetListener(ctx, s1l2.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, s1l2.ID, l.ID)
+
+	l, err = p.GetListener(ctx, s2l1.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, l)
+}
+
+func testReadWriteCheckpoints(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	cp1 := &apitypes.EventStreamCheckpoint{
+		StreamID: apitypes.NewULID(),
+	}
+	cp2 := &apitypes.EventStreamCheckpoint{
+		StreamID: apitypes.NewULID(),
+	}
+
+	err := p.WriteCheckpoint(ctx, cp1)
+	assert.NoError(t, err)
+
+	err = p.WriteCheckpoint(ctx, cp2)
+	assert.NoError(t, err)
+
+	err = p.DeleteCheckpoint(ctx, cp1.StreamID)
+	assert.NoError(t, err)
+
+	err = p.DeleteCheckpoint(ctx, cp1.StreamID)
+	assert.NoError(t, err) // No-op
+
+	cp, err := p.GetCheckpoint(ctx, cp1.StreamID)
+	assert.NoError(t, err)
+	assert.Nil(t, cp)
+
+	cp, err = p.GetCheckpoint(ctx, cp2.StreamID)
+	assert.NoError(t, err)
+	assert.Equal(t, cp2.StreamID, cp.StreamID)
+}
+
+func newTestTX(signer string, nonce int64, status apitypes.TxStatus) *apitypes.ManagedTX {
+	return &apitypes.ManagedTX{
+		ID:      fmt.Sprintf("ns1/%s", fftypes.NewUUID()),
+		Created: fftypes.Now(),
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: signer,
+		},
+		SequenceID: apitypes.NewULID(),
+		Nonce:      fftypes.NewFFBigInt(nonce),
+		Status:     status,
+	}
+}
+
+func testReadWriteManagedTransactions(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	submitNewTX := func(signer string, nonce int64, status apitypes.TxStatus) *apitypes.ManagedTX {
+		tx := newTestTX(signer, nonce, status)
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+
+	s1t1 := submitNewTX("0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	s2t1 := submitNewTX("0xbbbbb", 10001, apitypes.TxStatusFailed)
+	s1t2 := submitNewTX("0xaaaaa", 10002, apitypes.TxStatusPending)
+	s1t3 := submitNewTX("0xaaaaa", 10003, apitypes.TxStatusPending)
+
+	// Check dup
+	err := p.WriteTransaction(ctx, s1t1, true)
+	assert.Regexp(t, "FF21065", err)
+
+	txns, err := p.ListTransactionsByCreateTime(ctx, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 4)
+
+	assert.Equal(t, s1t3.ID, txns[0].ID)
+	assert.Equal(t, s1t2.ID, txns[1].ID)
+	assert.Equal(t, s2t1.ID, txns[2].ID)
+	assert.Equal(t, s1t1.ID, txns[3].ID)
+
+	// Only list pending
+
+	txns, err = p.ListTransactionsPending(ctx, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+
+	assert.Equal(t, s1t3.ID, txns[0].ID)
+	assert.Equal(t, s1t2.ID, txns[1].ID)
+
+	// List with time range
+
+	txns, err = p.ListTransactionsByCreateTime(ctx, s1t2, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, s2t1.ID, txns[0].ID)
+	assert.Equal(t, s1t1.ID, txns[1].ID)
+
+	txns, err = p.ListTransactionsByCreateTime(ctx, s2t1, 1, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t2.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsPending(ctx, s1t2.SequenceID, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t3.ID, txns[0].ID)
+
+	// Updating a transaction out of pending state removes it from the pending list
+	s1t3.Status = apitypes.TxStatusSucceeded
+	err = p.WriteTransaction(ctx, s1t3, false)
+	assert.NoError(t, err)
+	txns, err = p.ListTransactionsPending(ctx, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t2.ID, txns[0].ID)
+
+	// Test delete, and querying by nonce to limit TX returned
+
+	err = p.DeleteTransaction(ctx, s1t2.ID)
+	assert.NoError(t, err)
+	txns, err = p.ListTransactionsByNonce(ctx, "0xaaaaa", s1t1.Nonce, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t3.ID, txns[0].ID)
+
+	// Check we can use after with the deleted nonce, and not skip the one after
+	txns, err = p.ListTransactionsByNonce(ctx, "0xaaaaa", s1t2.Nonce, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t3.ID, txns[0].ID)
+
+	// Test get direct
+
+	v, err := p.GetTransactionByID(ctx, s1t3.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, s1t3.ID, v.ID)
+	assert.Equal(t, s1t3.Nonce, v.Nonce)
+
+	v, err = p.GetTransactionByNonce(ctx, "0xbbbbb", s2t1.Nonce)
+	assert.NoError(t, err)
+	assert.Equal(t, s2t1.ID, v.ID)
+	assert.Equal(t, s2t1.Nonce, v.Nonce)
+
+	v, err = p.GetTransactionByID(ctx, s1t2.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func testTransactionBecomesPending(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	tx := newTestTX("0xaaaaa", 10001, apitypes.TxStatusScheduled)
+	err := p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+
+	txns, err := p.ListTransactionsPending(ctx, nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Empty(t, txns)
+
+	// Releasing a scheduled transaction updates it to pending, which must add it to the pending list
+	tx.Status = apitypes.TxStatusPending
+	err = p.WriteTransaction(ctx, tx, false)
+	assert.NoError(t, err)
+	txns, err = p.ListTransactionsPending(ctx, nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, tx.ID, txns[0].ID)
+
+	// Writing it again while still pending does not duplicate it
+	err = p.WriteTransaction(ctx, tx, false)
+	assert.NoError(t, err)
+	txns, err = p.ListTransactionsPending(ctx, nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+}
+
+func testGetTransactionByHash(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	tx := newTestTX("0xaaaaa", 10001, apitypes.TxStatusPending)
+	err := p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+
+	// Each resubmission replaces the hash on the transaction
+	for _, hash := range []string{"0x111111", "0x222222", "0x333333"} {
+		tx.TransactionHash = hash
+		err = p.WriteTransaction(ctx, tx, false)
+		assert.NoError(t, err)
+	}
+	tx.Cancel = &apitypes.ManagedTXCancel{TransactionHash: "0x444444"}
+	tx.Status = apitypes.TxStatusFailed
+	err = p.WriteTransaction(ctx, tx, false)
+	assert.NoError(t, err)
+
+	other := newTestTX("0xbbbbb", 10001, apitypes.TxStatusPending)
+	other.TransactionHash = "0x555555"
+	err = p.WriteTransaction(ctx, other, true)
+	assert.NoError(t, err)
+
+	for _, hash := range []string{"0x111111", "0x222222", "0x333333", "0x444444"} {
+		v, err := p.GetTransactionByHash(ctx, hash)
+		assert.NoError(t, err)
+		assert.Equal(t, tx.ID, v.ID)
+		assert.Equal(t, "0x333333", v.TransactionHash)
+	}
+	v, err := p.GetTransactionByHash(ctx, "0x555555")
+	assert.NoError(t, err)
+	assert.Equal(t, other.ID, v.ID)
+
+	v, err = p.GetTransactionByHash(ctx, "0x999999")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	// Once deleted, none of the hashes resolve
+	err = p.DeleteTransaction(ctx, tx.ID)
+	assert.NoError(t, err)
+	for _, hash := range []string{"0x111111", "0x333333", "0x444444"} {
+		v, err := p.GetTransactionByHash(ctx, hash)
+		assert.NoError(t, err)
+		assert.Nil(t, v)
+	}
+}
+
+func testGetTransactionByIdempotencyKey(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	t1 := newTestTX("0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	t1.IdempotencyKey = "key1"
+	t2 := newTestTX("0xbbbbb", 10001, apitypes.TxStatusPending)
+	t2.IdempotencyKey = "key1"
+	t3 := newTestTX("0xaaaaa", 10002, apitypes.TxStatusPending)
+	t4 := newTestTX("0xaaaaa", 10003, apitypes.TxStatusPending)
+	t4.IdempotencyKey = "key1" // reused once outside the retention window
+	for _, tx := range []*apitypes.ManagedTX{t1, t2, t3, t4} {
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+	}
+
+	// Keys are scoped to the signer, and the latest use of the key is returned
+	v, err := p.GetTransactionByIdempotencyKey(ctx, "0xaaaaa", "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, t4.ID, v.ID)
+	v, err = p.GetTransactionByIdempotencyKey(ctx, "0xbbbbb", "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, t2.ID, v.ID)
+	v, err = p.GetTransactionByIdempotencyKey(ctx, "0xaaaaa", "key2")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	// Updates do not affect the lookup
+	t4.Status = apitypes.TxStatusSucceeded
+	err = p.WriteTransaction(ctx, t4, false)
+	assert.NoError(t, err)
+	v, err = p.GetTransactionByIdempotencyKey(ctx, "0xaaaaa", "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, t4.ID, v.ID)
+	assert.Equal(t, apitypes.TxStatusSucceeded, v.Status)
+
+	// Deleting the earlier use of the key leaves the latest in place
+	err = p.DeleteTransaction(ctx, t1.ID)
+	assert.NoError(t, err)
+	v, err = p.GetTransactionByIdempotencyKey(ctx, "0xaaaaa", "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, t4.ID, v.ID)
+
+	err = p.DeleteTransaction(ctx, t2.ID)
+	assert.NoError(t, err)
+	v, err = p.GetTransactionByIdempotencyKey(ctx, "0xbbbbb", "key1")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func testGetTransactionsByIDs(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	t1 := newTestTX("0xaaaaa", 10001, apitypes.TxStatusPending)
+	t2 := newTestTX("0xaaaaa", 10002, apitypes.TxStatusSucceeded)
+	t3 := newTestTX("0xaaaaa", 10003, apitypes.TxStatusFailed)
+	for _, tx := range []*apitypes.ManagedTX{t1, t2, t3} {
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+	}
+
+	txns, err := p.GetTransactionsByIDs(ctx, []string{t3.ID, "unknown", t1.ID})
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	byID := map[string]*apitypes.ManagedTX{}
+	for _, tx := range txns {
+		byID[tx.ID] = tx
+	}
+	assert.Equal(t, apitypes.TxStatusPending, byID[t1.ID].Status)
+	assert.Equal(t, apitypes.TxStatusFailed, byID[t3.ID].Status)
+
+	txns, err = p.GetTransactionsByIDs(ctx, []string{})
+	assert.NoError(t, err)
+	assert.Empty(t, txns)
+}
+
+func testListTransactionsByStatus(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	submitNewTX := func(signer string, nonce int64, status apitypes.TxStatus) *apitypes.ManagedTX {
+		tx := newTestTX(signer, nonce, status)
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+
+	s1t1 := submitNewTX("0xaaaaa", 10001, apitypes.TxStatusFailed)
+	s2t1 := submitNewTX("0xbbbbb", 10001, apitypes.TxStatusFailed)
+	s1t2 := submitNewTX("0xaaaaa", 10002, apitypes.TxStatusSucceeded)
+	s1t3 := submitNewTX("0xaaaaa", 10003, apitypes.TxStatusFailed)
+	s2t2 := submitNewTX("0xbbbbb", 10002, apitypes.TxStatusPending)
+
+	// By create time
+	txns, err := p.ListTransactionsByStatus(ctx, apitypes.TxStatusFailed, "", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 3)
+	assert.Equal(t, s1t3.ID, txns[0].ID)
+	assert.Equal(t, s2t1.ID, txns[1].ID)
+	assert.Equal(t, s1t1.ID, txns[2].ID)
+
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusFailed, "", s2t1, 1, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t3.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusSucceeded, "", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t2.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, "", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s2t2.ID, txns[0].ID)
+
+	// By nonce within a signer
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusFailed, "0xaaaaa", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, s1t3.ID, txns[0].ID)
+	assert.Equal(t, s1t1.ID, txns[1].ID)
+
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusFailed, "0xaaaaa", s1t2, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t1.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusSucceeded, "0xbbbbb", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Empty(t, txns)
+
+	// Pending within a signer, as transactions become pending and complete
+	s1t4 := submitNewTX("0xaaaaa", 10004, apitypes.TxStatusPending)
+	s1t5 := submitNewTX("0xaaaaa", 10005, apitypes.TxStatusScheduled)
+	s1t5.Status = apitypes.TxStatusPending
+	err = p.WriteTransaction(ctx, s1t5, false)
+	assert.NoError(t, err)
+
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, "0xaaaaa", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, s1t5.ID, txns[0].ID)
+	assert.Equal(t, s1t4.ID, txns[1].ID)
+
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, "0xaaaaa", s1t5, 1, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t4.ID, txns[0].ID)
+
+	s1t4.Status = apitypes.TxStatusSucceeded
+	err = p.WriteTransaction(ctx, s1t4, false)
+	assert.NoError(t, err)
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, "0xaaaaa", nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, s1t5.ID, txns[0].ID)
+
+	err = p.DeleteTransaction(ctx, s1t5.ID)
+	assert.NoError(t, err)
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, "0xaaaaa", nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Empty(t, txns)
+}
+
+func testListTransactionsByNonceKey(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	submitNewTX := func(connector, signer string, nonce int64) *apitypes.ManagedTX {
+		tx := newTestTX(signer, nonce, apitypes.TxStatusPending)
+		tx.Connector = connector
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+
+	// The same signer and nonce on different connectors are different transactions
+	t1 := submitNewTX("", "0xaaaaa", 10001)
+	t2 := submitNewTX("chain2", "0xaaaaa", 10001)
+	t3 := submitNewTX("chain2", "0xaaaaa", 10002)
+
+	txns, err := p.ListTransactionsByNonce(ctx, "0xaaaaa", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, t1.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsByNonce(ctx, "chain2/0xaaaaa", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, t3.ID, txns[0].ID)
+	assert.Equal(t, t2.ID, txns[1].ID)
+
+	tx, err := p.GetTransactionByNonce(ctx, "chain2/0xaaaaa", fftypes.NewFFBigInt(10001))
+	assert.NoError(t, err)
+	assert.Equal(t, t2.ID, tx.ID)
+
+	txns, err = p.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, "chain2/0xaaaaa", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+
+	// Deleting removes the transaction from the index of its connector
+	err = p.DeleteTransaction(ctx, t3.ID)
+	assert.NoError(t, err)
+	txns, err = p.ListTransactionsByNonce(ctx, "chain2/0xaaaaa", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, t2.ID, txns[0].ID)
+}
+
+func testCountTransactionsByStatus(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	submitNewTX := func(nonce int64, status apitypes.TxStatus) *apitypes.ManagedTX {
+		tx := newTestTX("0xaaaaa", nonce, status)
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+
+	counts, err := p.CountTransactionsByStatus(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+
+	t1 := submitNewTX(10001, apitypes.TxStatusPending)
+	t2 := submitNewTX(10002, apitypes.TxStatusPending)
+	submitNewTX(10003, apitypes.TxStatusSucceeded)
+	submitNewTX(10004, apitypes.TxStatusFailed)
+
+	counts, err = p.CountTransactionsByStatus(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[apitypes.TxStatus]int{
+		apitypes.TxStatusPending:   2,
+		apitypes.TxStatusSucceeded: 1,
+		apitypes.TxStatusFailed:    1,
+	}, counts)
+
+	// Counts follow updates and deletes
+	t1.Status = apitypes.TxStatusSucceeded
+	err = p.WriteTransaction(ctx, t1, false)
+	assert.NoError(t, err)
+	err = p.WriteTransaction(ctx, t1, false)
+	assert.NoError(t, err)
+	err = p.DeleteTransaction(ctx, t2.ID)
+	assert.NoError(t, err)
+	submitNewTX(10005, apitypes.TxStatusScheduled)
+
+	counts, err = p.CountTransactionsByStatus(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[apitypes.TxStatus]int{
+		apitypes.TxStatusSucceeded: 2,
+		apitypes.TxStatusFailed:    1,
+		apitypes.TxStatusScheduled: 1,
+	}, counts)
+}
+
+func testListTransactionsByRequestID(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	submitNewTX := func(nonce int64, requestID string) *apitypes.ManagedTX {
+		tx := newTestTX("0xaaaaa", nonce, apitypes.TxStatusPending)
+		tx.RequestID = requestID
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+
+	t1 := submitNewTX(10001, "req1")
+	submitNewTX(10002, "req2")
+	t3 := submitNewTX(10003, "req1")
+	submitNewTX(10004, "")
+
+	txns, err := p.ListTransactionsByRequestID(ctx, "req1", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, t3.ID, txns[0].ID)
+	assert.Equal(t, t1.ID, txns[1].ID)
+
+	txns, err = p.ListTransactionsByRequestID(ctx, "req1", t1, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, t3.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsByRequestID(ctx, "unknown", nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Empty(t, txns)
+}
+
+func testListTransactionsByDependsOn(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	submitNewTX := func(nonce int64, dependsOn string) *apitypes.ManagedTX {
+		tx := newTestTX("0xaaaaa", nonce, apitypes.TxStatusPending)
+		tx.DependsOn = dependsOn
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+
+	// parent <- c1 <- g1, and parent <- c2
+	parent := submitNewTX(10001, "")
+	c1 := submitNewTX(10002, parent.ID)
+	c2 := submitNewTX(10003, parent.ID)
+	g1 := submitNewTX(10004, c1.ID)
+
+	txns, err := p.ListTransactionsByDependsOn(ctx, parent.ID, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, c2.ID, txns[0].ID)
+	assert.Equal(t, c1.ID, txns[1].ID)
+
+	txns, err = p.ListTransactionsByDependsOn(ctx, parent.ID, c1, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, c2.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsByDependsOn(ctx, c1.ID, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, g1.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsByDependsOn(ctx, g1.ID, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Empty(t, txns)
+
+	// Dependents are still found once the parent is deleted, and are removed from the index when deleted themselves
+	err = p.DeleteTransaction(ctx, parent.ID)
+	assert.NoError(t, err)
+	err = p.DeleteTransaction(ctx, c2.ID)
+	assert.NoError(t, err)
+	txns, err = p.ListTransactionsByDependsOn(ctx, parent.ID, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, c1.ID, txns[0].ID)
+}
+
+func testListTransactionsByLabels(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	submitNewTX := func(nonce int64, labels map[string]string) *apitypes.ManagedTX {
+		tx := newTestTX("0xaaaaa", nonce, apitypes.TxStatusPending)
+		tx.Labels = labels
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+
+	t1 := submitNewTX(10001, map[string]string{"app": "payments", "env": "prod"})
+	t2 := submitNewTX(10002, map[string]string{"app": "payments", "env": "test"})
+	t3 := submitNewTX(10003, map[string]string{"app": "payments", "env": "prod", "purpose": "refund"})
+	submitNewTX(10004, map[string]string{"app": "payroll", "env": "prod"})
+	submitNewTX(10005, nil)
+	// A label value that contains the separators used in index keys
+	t6 := submitNewTX(10006, map[string]string{"app": "payments_0/x=y"})
+
+	// Single label
+	txns, err := p.ListTransactionsByLabels(ctx, map[string]string{"app": "payments"}, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 3)
+	assert.Equal(t, t3.ID, txns[0].ID)
+	assert.Equal(t, t2.ID, txns[1].ID)
+	assert.Equal(t, t1.ID, txns[2].ID)
+	assert.Equal(t, "prod", txns[0].Labels["env"])
+
+	// Multiple labels must all match
+	txns, err = p.ListTransactionsByLabels(ctx, map[string]string{"app": "payments", "env": "prod"}, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, t3.ID, txns[0].ID)
+	assert.Equal(t, t1.ID, txns[1].ID)
+
+	txns, err = p.ListTransactionsByLabels(ctx, map[string]string{"app": "payments", "env": "prod", "purpose": "refund"}, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, t3.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsByLabels(ctx, map[string]string{"app": "payments", "env": "staging"}, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Empty(t, txns)
+
+	txns, err = p.ListTransactionsByLabels(ctx, map[string]string{"app": "payments_0/x=y"}, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, t6.ID, txns[0].ID)
+
+	// Pagination
+	txns, err = p.ListTransactionsByLabels(ctx, map[string]string{"env": "prod", "app": "payments"}, t1, 1, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, t3.ID, txns[0].ID)
+
+	// Deleted transactions are removed from the index
+	err = p.DeleteTransaction(ctx, t3.ID)
+	assert.NoError(t, err)
+	txns, err = p.ListTransactionsByLabels(ctx, map[string]string{"app": "payments"}, nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, t2.ID, txns[0].ID)
+	assert.Equal(t, t1.ID, txns[1].ID)
+}
+
+func testListTransactionsByCreateTimeRange(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	base := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	at := func(secs int) *fftypes.FFTime {
+		ft := fftypes.FFTime(base.Add(time.Duration(secs) * time.Second))
+		return &ft
+	}
+	submitNewTX := func(nonce int64, created *fftypes.FFTime) *apitypes.ManagedTX {
+		tx := newTestTX("0xaaaaa", nonce, apitypes.TxStatusPending)
+		tx.Created = created
+		err := p.WriteTransaction(ctx, tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+
+	t1 := submitNewTX(10001, at(10))
+	t2 := submitNewTX(10002, at(20))
+	t3 := submitNewTX(10003, at(30))
+	t4 := submitNewTX(10004, at(40))
+
+	// Bounds are inclusive
+	txns, err := p.ListTransactionsByCreateTimeRange(ctx, at(20), at(30), nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, t3.ID, txns[0].ID)
+	assert.Equal(t, t2.ID, txns[1].ID)
+
+	txns, err = p.ListTransactionsByCreateTimeRange(ctx, at(21), at(29), nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Empty(t, txns)
+
+	// Either bound can be omitted
+	txns, err = p.ListTransactionsByCreateTimeRange(ctx, at(30), nil, nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, t3.ID, txns[0].ID)
+	assert.Equal(t, t4.ID, txns[1].ID)
+
+	txns, err = p.ListTransactionsByCreateTimeRange(ctx, nil, at(10), nil, 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, t1.ID, txns[0].ID)
+
+	// Composes with limit and the after cursor
+	txns, err = p.ListTransactionsByCreateTimeRange(ctx, at(10), at(30), nil, 2, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, t3.ID, txns[0].ID)
+	assert.Equal(t, t2.ID, txns[1].ID)
+
+	txns, err = p.ListTransactionsByCreateTimeRange(ctx, at(10), at(30), t2, 2, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, t1.ID, txns[0].ID)
+
+	txns, err = p.ListTransactionsByCreateTimeRange(ctx, at(10), at(40), t2, 1, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, t3.ID, txns[0].ID)
+}
+
+func testLeases(t *testing.T, p Persistence) {
+	ctx := context.Background()
+
+	// First holder obtains the lease, and can renew it
+	acquired, err := p.AcquireLease(ctx, "leader", "replica1", 1*time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = p.AcquireLease(ctx, "leader", "replica1", 1*time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Another holder cannot take it while it is valid
+	acquired, err = p.AcquireLease(ctx, "leader", "replica2", 1*time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	// Leases are independent by name
+	acquired, err = p.AcquireLease(ctx, "other", "replica2", 1*time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Release by a non-holder is ignored
+	err = p.ReleaseLease(ctx, "leader", "replica2")
+	assert.NoError(t, err)
+	acquired, err = p.AcquireLease(ctx, "leader", "replica2", 1*time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	// Once released, another holder can take it
+	err = p.ReleaseLease(ctx, "leader", "replica1")
+	assert.NoError(t, err)
+	acquired, err = p.AcquireLease(ctx, "leader", "replica2", 1*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Once expired, another holder can take it
+	time.Sleep(5 * time.Millisecond)
+	acquired, err = p.AcquireLease(ctx, "leader", "replica1", 1*time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func testSubmissionControl(t *testing.T, p Persistence) {
+	ctx := context.Background()
+
+	control, err := p.GetSubmissionControl(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, control)
+
+	err = p.WriteSubmissionControl(ctx, &apitypes.SubmissionControl{Paused: true, Updated: fftypes.Now()})
+	assert.NoError(t, err)
+	control, err = p.GetSubmissionControl(ctx)
+	assert.NoError(t, err)
+	assert.True(t, control.Paused)
+	assert.NotNil(t, control.Updated)
+
+	err = p.WriteSubmissionControl(ctx, &apitypes.SubmissionControl{Paused: false, Updated: fftypes.Now()})
+	assert.NoError(t, err)
+	control, err = p.GetSubmissionControl(ctx)
+	assert.NoError(t, err)
+	assert.False(t, control.Paused)
+}
+
+func testDeadLetters(t *testing.T, p Persistence) {
+	ctx := context.Background()
+	streamID := apitypes.NewULID()
+	otherStreamID := apitypes.NewULID()
+
+	deadLetters, err := p.ListStreamDeadLetters(ctx, streamID, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, deadLetters)
+
+	now := time.Now()
+	newDeadLetter := func(streamID *fftypes.UUID, age time.Duration) *apitypes.DeadLetter {
+		created := fftypes.FFTime(now.Add(-age))
+		return &apitypes.DeadLetter{
+			ID:       fftypes.NewUUID(),
+			StreamID: streamID,
+			Created:  &created,
+			Reason:   "pop",
+			Events: []*apitypes.EventWithContext{
+				{StandardContext: apitypes.EventContext{StreamID: streamID}},
+			},
+		}
+	}
+	dl2 := newDeadLetter(streamID, 1*time.Minute)
+	dl1 := newDeadLetter(streamID, 2*time.Minute)
+	dl3 := newDeadLetter(otherStreamID, 3*time.Minute)
+	for _, dl := range []*apitypes.DeadLetter{dl2, dl1, dl3} {
+		err = p.WriteDeadLetter(ctx, dl)
+		assert.NoError(t, err)
+	}
+
+	// Oldest first, for the stream only
+	deadLetters, err = p.ListStreamDeadLetters(ctx, streamID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, dl1.ID, deadLetters[0].ID)
+	assert.Equal(t, dl2.ID, deadLetters[1].ID)
+	assert.Equal(t, "pop", deadLetters[0].Reason)
+	assert.Len(t, deadLetters[0].Events, 1)
+
+	deadLetters, err = p.ListStreamDeadLetters(ctx, streamID, 1)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, dl1.ID, deadLetters[0].ID)
+
+	err = p.DeleteDeadLetter(ctx, dl1)
+	assert.NoError(t, err)
+	deadLetters, err = p.ListStreamDeadLetters(ctx, streamID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, dl2.ID, deadLetters[0].ID)
+
+	deadLetters, err = p.ListStreamDeadLetters(ctx, otherStreamID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, dl3.ID, deadLetters[0].ID)
+}
@@ -0,0 +1,629 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+
+	// Registers the pure-Go "sqlite" database/sql driver, so no CGO is required
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteInMemory can be configured as the path, to use a non-persistent in-memory database
+const SQLiteInMemory = ":memory:"
+
+// The schema stores each object as a JSON document in the "data" column, alongside the
+// columns used for lookup and ordering. This means operators can inspect the database with
+// standard sqlite tooling, including using json_extract() against the documents.
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS checkpoints (
+		stream_id   TEXT PRIMARY KEY,
+		data        TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS deadletters (
+		id          TEXT PRIMARY KEY,
+		stream_id   TEXT NOT NULL,
+		created     INTEGER NOT NULL,
+		data        TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS deadletters_stream ON deadletters(stream_id, created, id)`,
+	`CREATE TABLE IF NOT EXISTS eventstreams (
+		id          TEXT PRIMARY KEY,
+		data        TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS listeners (
+		id          TEXT PRIMARY KEY,
+		stream_id   TEXT,
+		data        TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS listeners_stream ON listeners(stream_id, id)`,
+	`CREATE TABLE IF NOT EXISTS transactions (
+		id          TEXT PRIMARY KEY,
+		created     INTEGER NOT NULL,
+		sequence_id TEXT NOT NULL,
+		signer      TEXT NOT NULL,
+		nonce       TEXT NOT NULL,
+		status      TEXT NOT NULL,
+		data        TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS transactions_created ON transactions(created, sequence_id)`,
+	`CREATE INDEX IF NOT EXISTS transactions_nonce ON transactions(signer, nonce)`,
+	`CREATE INDEX IF NOT EXISTS transactions_pending ON transactions(status, sequence_id)`,
+	`CREATE INDEX IF NOT EXISTS transactions_signer_status ON transactions(signer, status, nonce)`,
+	`CREATE INDEX IF NOT EXISTS transactions_depends_on ON transactions(json_extract(data, '$.dependsOn'), created, sequence_id)`,
+	`CREATE INDEX IF NOT EXISTS transactions_idempotency ON transactions(signer, json_extract(data, '$.idempotencyKey'), created, sequence_id)`,
+	`CREATE TABLE IF NOT EXISTS transaction_hashes (
+		hash        TEXT PRIMARY KEY,
+		tx_id       TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS transaction_hashes_tx ON transaction_hashes(tx_id)`,
+	`CREATE TABLE IF NOT EXISTS transaction_labels (
+		label       TEXT NOT NULL,
+		tx_id       TEXT NOT NULL,
+		PRIMARY KEY (label, tx_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS transaction_labels_tx ON transaction_labels(tx_id)`,
+	`CREATE TABLE IF NOT EXISTS leases (
+		name        TEXT PRIMARY KEY,
+		holder      TEXT NOT NULL,
+		expires     INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS control (
+		name        TEXT PRIMARY KEY,
+		data        TEXT NOT NULL
+	)`,
+}
+
+const submissionControlName = "submissions"
+
+type sqlitePersistence struct {
+	db    *sql.DB
+	txMux sync.Mutex // ensures the duplicate check on new transactions is atomic with the insert
+}
+
+func NewSQLitePersistence(ctx context.Context) (Persistence, error) {
+	dbPath := config.GetString(tmconfig.PersistenceSQLitePath)
+	if dbPath == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgSQLitePathMissing)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceInitFailed, dbPath)
+	}
+	// SQLite only supports a single writer, and each connection to an in-memory database
+	// is a separate database - so we use a single connection.
+	db.SetMaxOpenConns(1)
+	for _, stmt := range sqliteSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceInitFailed, dbPath)
+		}
+	}
+	return &sqlitePersistence{
+		db: db,
+	}, nil
+}
+
+func (p *sqlitePersistence) exec(ctx context.Context, errMsg i18n.ErrorMessageKey, key string, query string, args ...interface{}) error {
+	if _, err := p.db.ExecContext(ctx, query, args...); err != nil {
+		return i18n.WrapError(ctx, err, errMsg, key)
+	}
+	log.L(ctx).Debugf("Updated %s", key)
+	return nil
+}
+
+func (p *sqlitePersistence) marshal(ctx context.Context, value interface{}) (string, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceMarshalFailed)
+	}
+	return string(b), nil
+}
+
+func (p *sqlitePersistence) readJSON(ctx context.Context, key string, target interface{}, query string, args ...interface{}) error {
+	var data string
+	err := p.db.QueryRowContext(ctx, query, args...).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, key)
+	}
+	if err = json.Unmarshal([]byte(data), target); err != nil {
+		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceUnmarshalFailed)
+	}
+	log.L(ctx).Debugf("Read %s", key)
+	return nil
+}
+
+func (p *sqlitePersistence) listJSON(ctx context.Context, collection string,
+	val func() interface{}, // return a pointer to a pointer variable, of the type to unmarshal
+	add func(interface{}), // passes back the val() for adding to the list
+	query string, args ...interface{},
+) error {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, collection)
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, collection)
+		}
+		v := val()
+		if err := json.Unmarshal([]byte(data), v); err != nil {
+			return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceUnmarshalFailed)
+		}
+		add(v)
+		count++
+	}
+	log.L(ctx).Debugf("Listed %d items", count)
+	return nil
+}
+
+// pageQuery builds the WHERE/ORDER BY/LIMIT clauses for a paginated query, where "after" is exclusive
+// in the direction of the sort, and the sort columns are compared as a tuple.
+func pageQuery(baseQuery string, conditions []string, args []interface{}, sortCols []string, after []interface{}, limit int, dir SortDirection) (string, []interface{}) {
+	op, order := "<", "DESC"
+	if dir == SortDirectionAscending {
+		op, order = ">", "ASC"
+	}
+	if len(after) > 0 {
+		// Expand (a,b) > (x,y) as: a > x OR (a = x AND b > y)
+		var ors []string
+		for i := range sortCols {
+			var ands []string
+			for j := 0; j < i; j++ {
+				ands = append(ands, fmt.Sprintf("%s = ?", sortCols[j]))
+				args = append(args, after[j])
+			}
+			ands = append(ands, fmt.Sprintf("%s %s ?", sortCols[i], op))
+			args = append(args, after[i])
+			ors = append(ors, fmt.Sprintf("(%s)", strings.Join(ands, " AND ")))
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s)", strings.Join(ors, " OR ")))
+	}
+	query := baseQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	orderBy := make([]string, len(sortCols))
+	for i, c := range sortCols {
+		orderBy[i] = fmt.Sprintf("%s %s", c, order)
+	}
+	query += " ORDER BY " + strings.Join(orderBy, ", ")
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	return query, args
+}
+
+func sqliteNonce(nonce *fftypes.FFBigInt) string {
+	// Zero-padded so that the text ordering matches the numeric ordering
+	return fmt.Sprintf("%.24d", nonce.Int())
+}
+
+func (p *sqlitePersistence) WriteCheckpoint(ctx context.Context, checkpoint *apitypes.EventStreamCheckpoint) error {
+	data, err := p.marshal(ctx, checkpoint)
+	if err != nil {
+		return err
+	}
+	return p.exec(ctx, tmmsgs.MsgPersistenceWriteFailed, checkpoint.StreamID.String(),
+		`INSERT OR REPLACE INTO checkpoints (stream_id, data) VALUES (?, ?)`, checkpoint.StreamID.String(), data)
+}
+
+func (p *sqlitePersistence) GetCheckpoint(ctx context.Context, streamID *fftypes.UUID) (cp *apitypes.EventStreamCheckpoint, err error) {
+	err = p.readJSON(ctx, streamID.String(), &cp, `SELECT data FROM checkpoints WHERE stream_id = ?`, streamID.String())
+	return cp, err
+}
+
+func (p *sqlitePersistence) DeleteCheckpoint(ctx context.Context, streamID *fftypes.UUID) error {
+	return p.exec(ctx, tmmsgs.MsgPersistenceDeleteFailed, streamID.String(),
+		`DELETE FROM checkpoints WHERE stream_id = ?`, streamID.String())
+}
+
+func (p *sqlitePersistence) ListStreamDeadLetters(ctx context.Context, streamID *fftypes.UUID, limit int) ([]*apitypes.DeadLetter, error) {
+	query, args := pageQuery(`SELECT data FROM deadletters`, []string{"stream_id = ?"}, []interface{}{streamID.String()},
+		[]string{"created", "id"}, nil, limit, SortDirectionAscending)
+	deadLetters := make([]*apitypes.DeadLetter, 0)
+	if err := p.listJSON(ctx, "deadletters",
+		func() interface{} { var v *apitypes.DeadLetter; return &v },
+		func(v interface{}) { deadLetters = append(deadLetters, *(v.(**apitypes.DeadLetter))) },
+		query, args...,
+	); err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+func (p *sqlitePersistence) WriteDeadLetter(ctx context.Context, deadLetter *apitypes.DeadLetter) error {
+	data, err := p.marshal(ctx, deadLetter)
+	if err != nil {
+		return err
+	}
+	return p.exec(ctx, tmmsgs.MsgPersistenceWriteFailed, deadLetter.ID.String(),
+		`INSERT OR REPLACE INTO deadletters (id, stream_id, created, data) VALUES (?, ?, ?, ?)`,
+		deadLetter.ID.String(), deadLetter.StreamID.String(), deadLetter.Created.UnixNano(), data)
+}
+
+func (p *sqlitePersistence) DeleteDeadLetter(ctx context.Context, deadLetter *apitypes.DeadLetter) error {
+	return p.exec(ctx, tmmsgs.MsgPersistenceDeleteFailed, deadLetter.ID.String(),
+		`DELETE FROM deadletters WHERE id = ?`, deadLetter.ID.String())
+}
+
+func (p *sqlitePersistence) ListStreams(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection) ([]*apitypes.EventStream, error) {
+	var afterVals []interface{}
+	if after != nil {
+		afterVals = []interface{}{after.String()}
+	}
+	query, args := pageQuery(`SELECT data FROM eventstreams`, nil, nil, []string{"id"}, afterVals, limit, dir)
+	streams := make([]*apitypes.EventStream, 0)
+	if err := p.listJSON(ctx, "eventstreams",
+		func() interface{} { var v *apitypes.EventStream; return &v },
+		func(v interface{}) { streams = append(streams, *(v.(**apitypes.EventStream))) },
+		query, args...,
+	); err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+func (p *sqlitePersistence) GetStream(ctx context.Context, streamID *fftypes.UUID) (es *apitypes.EventStream, err error) {
+	err = p.readJSON(ctx, streamID.String(), &es, `SELECT data FROM eventstreams WHERE id = ?`, streamID.String())
+	return es, err
+}
+
+func (p *sqlitePersistence) WriteStream(ctx context.Context, spec *apitypes.EventStream) error {
+	data, err := p.marshal(ctx, spec)
+	if err != nil {
+		return err
+	}
+	return p.exec(ctx, tmmsgs.MsgPersistenceWriteFailed, spec.ID.String(),
+		`INSERT OR REPLACE INTO eventstreams (id, data) VALUES (?, ?)`, spec.ID.String(), data)
+}
+
+func (p *sqlitePersistence) DeleteStream(ctx context.Context, streamID *fftypes.UUID) error {
+	return p.exec(ctx, tmmsgs.MsgPersistenceDeleteFailed, streamID.String(),
+		`DELETE FROM eventstreams WHERE id = ?`, streamID.String())
+}
+
+func (p *sqlitePersistence) listListeners(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection, conditions []string, args []interface{}) ([]*apitypes.Listener, error) {
+	var afterVals []interface{}
+	if after != nil {
+		afterVals = []interface{}{after.String()}
+	}
+	query, args := pageQuery(`SELECT data FROM listeners`, conditions, args, []string{"id"}, afterVals, limit, dir)
+	listeners := make([]*apitypes.Listener, 0)
+	if err := p.listJSON(ctx, "listeners",
+		func() interface{} { var v *apitypes.Listener; return &v },
+		func(v interface{}) { listeners = append(listeners, *(v.(**apitypes.Listener))) },
+		query, args...,
+	); err != nil {
+		return nil, err
+	}
+	return listeners, nil
+}
+
+func (p *sqlitePersistence) ListListeners(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection) ([]*apitypes.Listener, error) {
+	return p.listListeners(ctx, after, limit, dir, nil, nil)
+}
+
+func (p *sqlitePersistence) ListStreamListeners(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection, streamID *fftypes.UUID) ([]*apitypes.Listener, error) {
+	return p.listListeners(ctx, after, limit, dir, []string{"stream_id = ?"}, []interface{}{streamID.String()})
+}
+
+func (p *sqlitePersistence) GetListener(ctx context.Context, listenerID *fftypes.UUID) (l *apitypes.Listener, err error) {
+	err = p.readJSON(ctx, listenerID.String(), &l, `SELECT data FROM listeners WHERE id = ?`, listenerID.String())
+	return l, err
+}
+
+func (p *sqlitePersistence) WriteListener(ctx context.Context, spec *apitypes.Listener) error {
+	data, err := p.marshal(ctx, spec)
+	if err != nil {
+		return err
+	}
+	return p.exec(ctx, tmmsgs.MsgPersistenceWriteFailed, spec.ID.String(),
+		`INSERT OR REPLACE INTO listeners (id, stream_id, data) VALUES (?, ?, ?)`, spec.ID.String(), spec.StreamID.String(), data)
+}
+
+func (p *sqlitePersistence) DeleteListener(ctx context.Context, listenerID *fftypes.UUID) error {
+	return p.exec(ctx, tmmsgs.MsgPersistenceDeleteFailed, listenerID.String(),
+		`DELETE FROM listeners WHERE id = ?`, listenerID.String())
+}
+
+func (p *sqlitePersistence) listTransactions(ctx context.Context, conditions []string, args []interface{}, sortCols []string, after []interface{}, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	query, args := pageQuery(`SELECT data FROM transactions`, conditions, args, sortCols, after, limit, dir)
+	transactions := make([]*apitypes.ManagedTX, 0)
+	if err := p.listJSON(ctx, "transactions",
+		func() interface{} { var v *apitypes.ManagedTX; return &v },
+		func(v interface{}) { transactions = append(transactions, *(v.(**apitypes.ManagedTX))) },
+		query, args...,
+	); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (p *sqlitePersistence) ListTransactionsByCreateTime(ctx context.Context, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	var afterVals []interface{}
+	if after != nil {
+		afterVals = []interface{}{after.Created.UnixNano(), after.SequenceID.String()}
+	}
+	return p.listTransactions(ctx, nil, nil, []string{"created", "sequence_id"}, afterVals, limit, dir)
+}
+
+func (p *sqlitePersistence) ListTransactionsByCreateTimeRange(ctx context.Context, from, to *fftypes.FFTime, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if from != nil {
+		conditions = append(conditions, "created >= ?")
+		args = append(args, from.UnixNano())
+	}
+	if to != nil {
+		conditions = append(conditions, "created <= ?")
+		args = append(args, to.UnixNano())
+	}
+	var afterVals []interface{}
+	if after != nil {
+		afterVals = []interface{}{after.Created.UnixNano(), after.SequenceID.String()}
+	}
+	return p.listTransactions(ctx, conditions, args, []string{"created", "sequence_id"}, afterVals, limit, dir)
+}
+
+func (p *sqlitePersistence) ListTransactionsByNonce(ctx context.Context, signer string, after *fftypes.FFBigInt, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	var afterVals []interface{}
+	if after != nil {
+		afterVals = []interface{}{sqliteNonce(after)}
+	}
+	return p.listTransactions(ctx, []string{"signer = ?"}, []interface{}{signer}, []string{"nonce"}, afterVals, limit, dir)
+}
+
+func (p *sqlitePersistence) ListTransactionsPending(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	var afterVals []interface{}
+	if after != nil {
+		afterVals = []interface{}{after.String()}
+	}
+	return p.listTransactions(ctx, []string{"status = ?"}, []interface{}{string(apitypes.TxStatusPending)}, []string{"sequence_id"}, afterVals, limit, dir)
+}
+
+func (p *sqlitePersistence) ListTransactionsByStatus(ctx context.Context, status apitypes.TxStatus, signer string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	conditions := []string{"status = ?"}
+	args := []interface{}{string(status)}
+	var afterVals []interface{}
+	if signer != "" {
+		conditions = append(conditions, "signer = ?")
+		args = append(args, signer)
+		if after != nil {
+			afterVals = []interface{}{sqliteNonce(after.Nonce)}
+		}
+		return p.listTransactions(ctx, conditions, args, []string{"nonce"}, afterVals, limit, dir)
+	}
+	if after != nil {
+		afterVals = []interface{}{after.Created.UnixNano(), after.SequenceID.String()}
+	}
+	return p.listTransactions(ctx, conditions, args, []string{"created", "sequence_id"}, afterVals, limit, dir)
+}
+
+func (p *sqlitePersistence) ListTransactionsByRequestID(ctx context.Context, requestID string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	// The request ID is not a column, so we match it within the JSON document
+	conditions := []string{"json_extract(data, '$.requestId') = ?"}
+	args := []interface{}{requestID}
+	var afterVals []interface{}
+	if after != nil {
+		afterVals = []interface{}{after.Created.UnixNano(), after.SequenceID.String()}
+	}
+	return p.listTransactions(ctx, conditions, args, []string{"created", "sequence_id"}, afterVals, limit, dir)
+}
+
+func (p *sqlitePersistence) ListTransactionsByDependsOn(ctx context.Context, parentID string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	// The condition must match the expression of the transactions_depends_on index for it to be used
+	conditions := []string{"json_extract(data, '$.dependsOn') = ?"}
+	args := []interface{}{parentID}
+	var afterVals []interface{}
+	if after != nil {
+		afterVals = []interface{}{after.Created.UnixNano(), after.SequenceID.String()}
+	}
+	return p.listTransactions(ctx, conditions, args, []string{"created", "sequence_id"}, afterVals, limit, dir)
+}
+
+func (p *sqlitePersistence) ListTransactionsByLabels(ctx context.Context, labels map[string]string, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) {
+	conditions := make([]string, 0, len(labels))
+	args := make([]interface{}, 0, len(labels))
+	for k, v := range labels {
+		conditions = append(conditions, "id IN (SELECT tx_id FROM transaction_labels WHERE label = ?)")
+		args = append(args, labelIndexValue(k, v))
+	}
+	var afterVals []interface{}
+	if after != nil {
+		afterVals = []interface{}{after.Created.UnixNano(), after.SequenceID.String()}
+	}
+	return p.listTransactions(ctx, conditions, args, []string{"created", "sequence_id"}, afterVals, limit, dir)
+}
+
+func (p *sqlitePersistence) GetTransactionByID(ctx context.Context, txID string) (tx *apitypes.ManagedTX, err error) {
+	err = p.readJSON(ctx, txID, &tx, `SELECT data FROM transactions WHERE id = ?`, txID)
+	return tx, err
+}
+
+func (p *sqlitePersistence) GetTransactionsByIDs(ctx context.Context, txIDs []string) ([]*apitypes.ManagedTX, error) {
+	if len(txIDs) == 0 {
+		return []*apitypes.ManagedTX{}, nil
+	}
+	args := make([]interface{}, len(txIDs))
+	for i, txID := range txIDs {
+		args[i] = txID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(txIDs)), ",")
+	return p.listTransactions(ctx, []string{fmt.Sprintf("id IN (%s)", placeholders)}, args, []string{"created", "sequence_id"}, nil, 0, SortDirectionAscending)
+}
+
+func (p *sqlitePersistence) GetTransactionByNonce(ctx context.Context, signer string, nonce *fftypes.FFBigInt) (tx *apitypes.ManagedTX, err error) {
+	// If a nonce has been re-used, the most recent transaction is returned
+	err = p.readJSON(ctx, fmt.Sprintf("%s/%s", signer, nonce), &tx,
+		`SELECT data FROM transactions WHERE signer = ? AND nonce = ? ORDER BY created DESC, sequence_id DESC LIMIT 1`, signer, sqliteNonce(nonce))
+	return tx, err
+}
+
+func (p *sqlitePersistence) WriteTransaction(ctx context.Context, tx *apitypes.ManagedTX, new bool) (err error) {
+	p.txMux.Lock()
+	defer p.txMux.Unlock()
+
+	if tx.TransactionHeaders.From == "" ||
+		tx.Nonce == nil ||
+		tx.SequenceID == nil ||
+		tx.Created == nil ||
+		tx.ID == "" ||
+		tx.Status == "" {
+		return i18n.NewError(ctx, tmmsgs.MsgPersistenceTXIncomplete)
+	}
+	if new {
+		// This must be a unique ID, otherwise we return a conflict
+		var count int
+		if err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM transactions WHERE id = ?`, tx.ID).Scan(&count); err != nil {
+			return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, tx.ID)
+		}
+		if count > 0 {
+			return i18n.NewError(ctx, tmmsgs.MsgDuplicateID, tx.ID)
+		}
+	}
+	data, err := p.marshal(ctx, tx)
+	if err != nil {
+		return err
+	}
+	// Labels are set on submission, so are only indexed when the transaction is created
+	if new {
+		for k, v := range tx.Labels {
+			if err := p.exec(ctx, tmmsgs.MsgPersistenceWriteFailed, tx.ID,
+				`INSERT OR IGNORE INTO transaction_labels (label, tx_id) VALUES (?, ?)`, labelIndexValue(k, v), tx.ID); err != nil {
+				return err
+			}
+		}
+	}
+	// Each hash remains indexed after the transaction is resubmitted with a new hash
+	for _, hash := range transactionHashes(tx) {
+		if err := p.exec(ctx, tmmsgs.MsgPersistenceWriteFailed, hash,
+			`INSERT OR IGNORE INTO transaction_hashes (hash, tx_id) VALUES (?, ?)`, hash, tx.ID); err != nil {
+			return err
+		}
+	}
+	return p.exec(ctx, tmmsgs.MsgPersistenceWriteFailed, tx.ID,
+		`INSERT OR REPLACE INTO transactions (id, created, sequence_id, signer, nonce, status, data) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		tx.ID, tx.Created.UnixNano(), tx.SequenceID.String(), tx.NonceKey(), sqliteNonce(tx.Nonce), string(tx.Status), data)
+}
+
+func (p *sqlitePersistence) GetTransactionByHash(ctx context.Context, hash string) (tx *apitypes.ManagedTX, err error) {
+	err = p.readJSON(ctx, hash, &tx,
+		`SELECT t.data FROM transaction_hashes h JOIN transactions t ON t.id = h.tx_id WHERE h.hash = ?`, hash)
+	return tx, err
+}
+
+func (p *sqlitePersistence) GetTransactionByIdempotencyKey(ctx context.Context, signer, idempotencyKey string) (tx *apitypes.ManagedTX, err error) {
+	// The condition must match the expression of the transactions_idempotency index for it to be used
+	err = p.readJSON(ctx, fmt.Sprintf("%s/%s", signer, idempotencyKey), &tx,
+		`SELECT data FROM transactions WHERE signer = ? AND json_extract(data, '$.idempotencyKey') = ? ORDER BY created DESC, sequence_id DESC LIMIT 1`, signer, idempotencyKey)
+	return tx, err
+}
+
+func (p *sqlitePersistence) DeleteTransaction(ctx context.Context, txID string) error {
+	if err := p.exec(ctx, tmmsgs.MsgPersistenceDeleteFailed, txID,
+		`DELETE FROM transaction_hashes WHERE tx_id = ?`, txID); err != nil {
+		return err
+	}
+	if err := p.exec(ctx, tmmsgs.MsgPersistenceDeleteFailed, txID,
+		`DELETE FROM transaction_labels WHERE tx_id = ?`, txID); err != nil {
+		return err
+	}
+	return p.exec(ctx, tmmsgs.MsgPersistenceDeleteFailed, txID,
+		`DELETE FROM transactions WHERE id = ?`, txID)
+}
+
+// CountTransactionsByStatus is answered from the status index, so it does not read the transactions themselves
+func (p *sqlitePersistence) CountTransactionsByStatus(ctx context.Context) (map[apitypes.TxStatus]int, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT status, COUNT(*) FROM transactions GROUP BY status`)
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, "transactions")
+	}
+	defer rows.Close()
+	counts := make(map[apitypes.TxStatus]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, "transactions")
+		}
+		counts[apitypes.TxStatus(status)] = count
+	}
+	return counts, nil
+}
+
+func (p *sqlitePersistence) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	// The upsert only updates an existing lease that we hold, or that has expired, so a single
+	// statement atomically decides the outcome even when the database is shared between replicas
+	now := time.Now()
+	res, err := p.db.ExecContext(ctx,
+		`INSERT INTO leases (name, holder, expires) VALUES (?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires = excluded.expires
+		 WHERE leases.holder = excluded.holder OR leases.expires <= ?`,
+		name, holder, now.Add(ttl).UnixNano(), now.UnixNano())
+	if err != nil {
+		return false, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceWriteFailed, name)
+	}
+	updated, _ := res.RowsAffected()
+	return updated > 0, nil
+}
+
+func (p *sqlitePersistence) ReleaseLease(ctx context.Context, name, holder string) error {
+	return p.exec(ctx, tmmsgs.MsgPersistenceDeleteFailed, name,
+		`DELETE FROM leases WHERE name = ? AND holder = ?`, name, holder)
+}
+
+func (p *sqlitePersistence) GetSubmissionControl(ctx context.Context) (control *apitypes.SubmissionControl, err error) {
+	err = p.readJSON(ctx, submissionControlName, &control, `SELECT data FROM control WHERE name = ?`, submissionControlName)
+	return control, err
+}
+
+func (p *sqlitePersistence) WriteSubmissionControl(ctx context.Context, control *apitypes.SubmissionControl) error {
+	data, err := p.marshal(ctx, control)
+	if err != nil {
+		return err
+	}
+	return p.exec(ctx, tmmsgs.MsgPersistenceWriteFailed, submissionControlName,
+		`INSERT OR REPLACE INTO control (name, data) VALUES (?, ?)`, submissionControlName, data)
+}
+
+func (p *sqlitePersistence) Close(ctx context.Context) {
+	err := p.db.Close()
+	if err != nil {
+		log.L(ctx).Warnf("Error closing sqlite: %s", err)
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSQLitePersistence(t *testing.T) (*sqlitePersistence, func()) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceSQLitePath, SQLiteInMemory)
+
+	pp, err := NewSQLitePersistence(context.Background())
+	assert.NoError(t, err)
+
+	p := pp.(*sqlitePersistence)
+	return p, func() {
+		p.Close(context.Background())
+	}
+
+}
+
+func TestSQLiteInitMissingPath(t *testing.T) {
+
+	tmconfig.Reset()
+
+	_, err := NewSQLitePersistence(context.Background())
+	assert.Regexp(t, "FF21074", err)
+
+}
+
+func TestSQLiteInitFail(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceSQLitePath, "/path/does/not/exist/fftm.db")
+
+	_, err := NewSQLitePersistence(context.Background())
+	assert.Regexp(t, "FF21058", err)
+
+}
+
+func TestSQLiteFileReopen(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sqlite_*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceSQLitePath, path.Join(dir, "fftm.db"))
+
+	ctx := context.Background()
+	p, err := NewSQLitePersistence(ctx)
+	assert.NoError(t, err)
+	tx := newTestTX("0xaaaaa", 10001, apitypes.TxStatusPending)
+	err = p.WriteTransaction(ctx, tx, true)
+	assert.NoError(t, err)
+	p.Close(ctx)
+
+	// Schema creation must be idempotent, and the data must survive
+	p, err = NewSQLitePersistence(ctx)
+	assert.NoError(t, err)
+	defer p.Close(ctx)
+	v, err := p.GetTransactionByID(ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tx.ID, v.ID)
+
+}
+
+func TestSQLiteReadWriteStreams(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testReadWriteStreams(t, p)
+}
+
+func TestSQLiteReadWriteListeners(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testReadWriteListeners(t, p)
+}
+
+func TestSQLiteReadWriteCheckpoints(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testReadWriteCheckpoints(t, p)
+}
+
+func TestSQLiteReadWriteManagedTransactions(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testReadWriteManagedTransactions(t, p)
+}
+
+func TestSQLiteTransactionBecomesPending(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testTransactionBecomesPending(t, p)
+}
+
+func TestSQLiteGetTransactionByHash(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testGetTransactionByHash(t, p)
+}
+
+func TestSQLiteGetTransactionByIdempotencyKey(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testGetTransactionByIdempotencyKey(t, p)
+}
+
+func TestSQLiteListTransactionsByStatus(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testListTransactionsByStatus(t, p)
+}
+
+func TestSQLiteListTransactionsByNonceKey(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testListTransactionsByNonceKey(t, p)
+}
+
+func TestSQLiteCountTransactionsByStatus(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testCountTransactionsByStatus(t, p)
+}
+
+func TestSQLiteListTransactionsByRequestID(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testListTransactionsByRequestID(t, p)
+}
+
+func TestSQLiteListTransactionsByDependsOn(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testListTransactionsByDependsOn(t, p)
+}
+
+func TestSQLiteGetTransactionsByIDs(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testGetTransactionsByIDs(t, p)
+}
+
+func TestSQLiteListTransactionsByLabels(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testListTransactionsByLabels(t, p)
+}
+
+func TestSQLiteLeases(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testLeases(t, p)
+}
+
+func TestSQLiteSubmissionControl(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testSubmissionControl(t, p)
+}
+
+func TestSQLiteDeadLetters(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testDeadLetters(t, p)
+}
+
+func TestSQLiteListTransactionsByCreateTimeRange(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+	testListTransactionsByCreateTimeRange(t, p)
+}
+
+func TestSQLiteListStreamsBadJSON(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+
+	sID := apitypes.NewULID()
+	_, err := p.db.Exec(`INSERT INTO eventstreams (id, data) VALUES (?, ?)`, sID.String(), "{! not json")
+	assert.NoError(t, err)
+
+	_, err = p.ListStreams(context.Background(), nil, 0, SortDirectionDescending)
+	assert.Regexp(t, "FF21054", err)
+
+	_, err = p.GetStream(context.Background(), sID)
+	assert.Regexp(t, "FF21054", err)
+
+}
+
+func TestSQLiteListScanFail(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+
+	err := p.listJSON(context.Background(), "test",
+		func() interface{} { var v *apitypes.EventStream; return &v },
+		func(v interface{}) {},
+		`SELECT NULL`,
+	)
+	assert.Regexp(t, "FF21055", err)
+
+}
+
+func TestSQLiteWriteCheckpointFailMarshal(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+
+	id1 := apitypes.NewULID()
+	err := p.WriteCheckpoint(context.Background(), &apitypes.EventStreamCheckpoint{
+		Listeners: map[fftypes.UUID]json.RawMessage{
+			*id1: json.RawMessage([]byte(`{"bad": "json"!`)),
+		},
+	})
+	assert.Regexp(t, "FF21053", err)
+
+}
+
+func TestSQLiteClosedFailures(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	done()
+
+	ctx := context.Background()
+	id1 := apitypes.NewULID()
+
+	err := p.WriteCheckpoint(ctx, &apitypes.EventStreamCheckpoint{StreamID: id1})
+	assert.Regexp(t, "FF21056", err)
+	_, err = p.GetCheckpoint(ctx, id1)
+	assert.Regexp(t, "FF21055", err)
+	err = p.DeleteCheckpoint(ctx, id1)
+	assert.Regexp(t, "FF21057", err)
+
+	deadLetter := &apitypes.DeadLetter{ID: id1, StreamID: id1, Created: fftypes.Now()}
+	_, err = p.ListStreamDeadLetters(ctx, id1, 0)
+	assert.Regexp(t, "FF21055", err)
+	err = p.WriteDeadLetter(ctx, deadLetter)
+	assert.Regexp(t, "FF21056", err)
+	err = p.DeleteDeadLetter(ctx, deadLetter)
+	assert.Regexp(t, "FF21057", err)
+
+	_, err = p.ListStreams(ctx, nil, 0, SortDirectionDescending)
+	assert.Regexp(t, "FF21055", err)
+	err = p.WriteStream(ctx, &apitypes.EventStream{ID: id1})
+	assert.Regexp(t, "FF21056", err)
+	err = p.DeleteStream(ctx, id1)
+	assert.Regexp(t, "FF21057", err)
+
+	_, err = p.ListListeners(ctx, nil, 0, SortDirectionDescending)
+	assert.Regexp(t, "FF21055", err)
+	_, err = p.GetListener(ctx, id1)
+	assert.Regexp(t, "FF21055", err)
+	err = p.WriteListener(ctx, &apitypes.Listener{ID: id1, StreamID: id1})
+	assert.Regexp(t, "FF21056", err)
+	err = p.DeleteListener(ctx, id1)
+	assert.Regexp(t, "FF21057", err)
+
+	_, err = p.ListTransactionsPending(ctx, nil, 0, SortDirectionDescending)
+	assert.Regexp(t, "FF21055", err)
+	_, err = p.GetTransactionByNonce(ctx, "0xaaaaa", fftypes.NewFFBigInt(12345))
+	assert.Regexp(t, "FF21055", err)
+	err = p.WriteTransaction(ctx, newTestTX("0xaaaaa", 10001, apitypes.TxStatusPending), true)
+	assert.Regexp(t, "FF21055", err)
+	err = p.WriteTransaction(ctx, newTestTX("0xaaaaa", 10001, apitypes.TxStatusPending), false)
+	assert.Regexp(t, "FF21056", err)
+	err = p.DeleteTransaction(ctx, "tx1")
+	assert.Regexp(t, "FF21057", err)
+	_, err = p.GetTransactionByHash(ctx, "0x12345")
+	assert.Regexp(t, "FF21055", err)
+	_, err = p.GetTransactionByIdempotencyKey(ctx, "0xaaaaa", "key1")
+	assert.Regexp(t, "FF21055", err)
+	_, err = p.GetTransactionsByIDs(ctx, []string{"tx1"})
+	assert.Regexp(t, "FF21055", err)
+	tx := newTestTX("0xaaaaa", 10001, apitypes.TxStatusPending)
+	tx.TransactionHash = "0x12345"
+	err = p.WriteTransaction(ctx, tx, false)
+	assert.Regexp(t, "FF21056", err)
+	_, err = p.CountTransactionsByStatus(ctx)
+	assert.Regexp(t, "FF21055", err)
+
+	// Close twice is just a warning
+	p.Close(ctx)
+
+}
+
+func TestSQLiteInitSchemaFail(t *testing.T) {
+	defer func() { sqliteSchema = sqliteSchema[1:] }()
+	sqliteSchema = append([]string{"NOT VALID SQL"}, sqliteSchema...)
+
+	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceSQLitePath, SQLiteInMemory)
+	_, err := NewSQLitePersistence(context.Background())
+	assert.Regexp(t, "FF21058", err)
+}
+
+func TestSQLiteWriteTransactionIncomplete(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+
+	err := p.WriteTransaction(context.Background(), &apitypes.ManagedTX{}, true)
+	assert.Regexp(t, "FF21059", err)
+}
+
+func TestSQLiteDeleteTransactionMissing(t *testing.T) {
+	p, done := newTestSQLitePersistence(t)
+	defer done()
+
+	err := p.DeleteTransaction(context.Background(), "missing")
+	assert.NoError(t, err)
+}
@@ -27,17 +27,94 @@ var ffc = config.AddRootKey
 
 var (
 	ConfirmationsRequired                         = ffc("confirmations.required")
+	ConfirmationsMaxRequired                      = ffc("confirmations.maxRequired")
 	ConfirmationsBlockQueueLength                 = ffc("confirmations.blockQueueLength")
 	ConfirmationsStaleReceiptTimeout              = ffc("confirmations.staleReceiptTimeout")
+	ConfirmationsConfirmationTimeout              = ffc("confirmations.confirmationTimeout")
 	ConfirmationsNotificationQueueLength          = ffc("confirmations.notificationQueueLength")
+	ConfirmationsReorgDetectionDepth              = ffc("confirmations.reorgDetectionDepth")
+	ConfirmationsReceiptPollInterval              = ffc("confirmations.receiptPollInterval")
+	ConfirmationsMaxReceiptChecks                 = ffc("confirmations.maxReceiptChecks")
+	ConfirmationsReceiptBatchSize                 = ffc("confirmations.receiptBatchSize")
+	ConfirmationsSettleDelay                      = ffc("confirmations.settleDelay")
+	ConfirmationsStrategiesFast                   = ffc("confirmations.strategies.fast")
+	ConfirmationsStrategiesSafe                   = ffc("confirmations.strategies.safe")
 	TransactionsErrorHistoryCount                 = ffc("transactions.errorHistoryCount")
+	TransactionsIdempotencyKeyRetention           = ffc("transactions.idempotencyKeyRetention")
+	TransactionsCallbackMaxAttempts               = ffc("transactions.completionCallback.maxAttempts")
+	TransactionsCallbackRetryInitDelay            = ffc("transactions.completionCallback.retry.initialDelay")
+	TransactionsCallbackRetryMaxDelay             = ffc("transactions.completionCallback.retry.maxDelay")
+	TransactionsCallbackRetryFactor               = ffc("transactions.completionCallback.retry.factor")
+	TransactionsConfirmationWebhookMilestones     = ffc("transactions.confirmationWebhook.milestones")
+	TransactionsConfirmationWebhookSecret         = ffc("transactions.confirmationWebhook.secret")
+	TransactionsConfirmationWebhookMaxAttempts    = ffc("transactions.confirmationWebhook.maxAttempts")
+	TransactionsConfirmationWebhookRetryInitDelay = ffc("transactions.confirmationWebhook.retry.initialDelay")
+	TransactionsConfirmationWebhookRetryMaxDelay  = ffc("transactions.confirmationWebhook.retry.maxDelay")
+	TransactionsConfirmationWebhookRetryFactor    = ffc("transactions.confirmationWebhook.retry.factor")
+	TransactionsLabelsMaxCount                    = ffc("transactions.labels.maxCount")
+	TransactionsLabelsMaxLength                   = ffc("transactions.labels.maxLength")
+	TransactionsMaxHistoryCount                   = ffc("transactions.maxHistoryCount")
 	TransactionsMaxInFlight                       = ffc("transactions.maxInFlight")
+	TransactionsMaxPendingPerSigner               = ffc("transactions.maxPendingPerSigner")
+	TransactionsBackpressureMode                  = ffc("transactions.backpressure.mode")
+	TransactionsBackpressureThreshold             = ffc("transactions.backpressure.threshold")
 	TransactionsNonceStateTimeout                 = ffc("transactions.nonceStateTimeout")
+	TransactionsNonceGapCheckInterval             = ffc("transactions.nonceGapCheckInterval")
+	TransactionsNonceReservationTTL               = ffc("transactions.nonceReservation.ttl")
+	TransactionsPriorityWindow                    = ffc("transactions.priorityWindow")
+	TransactionsReaperRetention                   = ffc("transactions.reaper.retention")
+	TransactionsReaperInterval                    = ffc("transactions.reaper.interval")
+	TransactionsReaperBatchSize                   = ffc("transactions.reaper.batchSize")
+	TransactionsReaperDeletedRetention            = ffc("transactions.reaper.deletedRetention")
+	TransactionsRateLimitRate                     = ffc("transactions.rateLimit.rate")
+	TransactionsRateLimitBurst                    = ffc("transactions.rateLimit.burst")
+	TransactionsBlockGasLimit                     = ffc("transactions.blockGasLimit")
+	TransactionsMaxDataSize                       = ffc("transactions.maxDataSize")
+	TransactionsSubmissionTimeout                 = ffc("transactions.submissionTimeout")
+	TransactionsSignersAllow                      = ffc("transactions.signers.allow")
+	TransactionsSignersDeny                       = ffc("transactions.signers.deny")
+	TransactionsStrictNonceOrdering               = ffc("transactions.strictNonceOrdering")
+	ConnectorInfoRefreshInterval                  = ffc("connectorinfo.refreshInterval")
+	CircuitBreakerFailureThreshold                = ffc("circuitbreaker.failureThreshold")
+	CircuitBreakerCooldown                        = ffc("circuitbreaker.cooldown")
+	ConnectorAuditEnabled                         = ffc("connectoraudit.enabled")
+	ConnectorAuditLevel                           = ffc("connectoraudit.level")
+	ConnectorAuditRedactFields                    = ffc("connectoraudit.redactFields")
+	ConnectorFailoverEndpoints                    = ffc("connectorfailover.endpoints")
+	ConnectorFailoverStrategy                     = ffc("connectorfailover.strategy")
+	ConnectorFailoverFailureThreshold             = ffc("connectorfailover.failureThreshold")
+	ConnectorFailoverRecoveryInterval             = ffc("connectorfailover.recoveryInterval")
+	TracingServiceName                            = ffc("tracing.serviceName")
+	TracingOTLPEndpoint                           = ffc("tracing.otlp.endpoint")
+	TracingOTLPInsecure                           = ffc("tracing.otlp.insecure")
 	PolicyLoopInterval                            = ffc("policyloop.interval")
+	PolicyLoopAdaptiveEnabled                     = ffc("policyloop.adaptive.enabled")
+	PolicyLoopAdaptiveMinInterval                 = ffc("policyloop.adaptive.minInterval")
+	PolicyLoopAdaptiveMaxInterval                 = ffc("policyloop.adaptive.maxInterval")
+	PolicyLoopDryRun                              = ffc("policyloop.dryRun")
+	PolicyLoopDrainTimeout                        = ffc("policyloop.drainTimeout")
+	PolicyLoopWorkers                             = ffc("policyloop.workers")
+	PolicyLoopHealthCheckInterval                 = ffc("policyloop.healthCheckInterval")
 	PolicyLoopRetryInitDelay                      = ffc("policyloop.retry.initialDelay")
 	PolicyLoopRetryMaxDelay                       = ffc("policyloop.retry.maxDelay")
 	PolicyLoopRetryFactor                         = ffc("policyloop.retry.factor")
+	PolicyLoopBackoffInitDelay                    = ffc("policyloop.backoff.initialDelay")
+	PolicyLoopBackoffMaxDelay                     = ffc("policyloop.backoff.maxDelay")
+	PolicyLoopBackoffFactor                       = ffc("policyloop.backoff.factor")
+	PolicyLoopBackoffMaxAttempts                  = ffc("policyloop.backoff.maxAttempts")
+	PolicyLoopBackoffMaxElapsed                   = ffc("policyloop.backoff.maxElapsed")
+	PolicyLoopBackoffSubmitMaxAttempts            = ffc("policyloop.backoff.submit.maxAttempts")
+	PolicyLoopBackoffSubmitMaxElapsed             = ffc("policyloop.backoff.submit.maxElapsed")
+	PolicyLoopBackoffResubmitMaxAttempts          = ffc("policyloop.backoff.resubmit.maxAttempts")
+	PolicyLoopBackoffResubmitMaxElapsed           = ffc("policyloop.backoff.resubmit.maxElapsed")
+	PolicyLoopRevertRetryMaxAttempts              = ffc("policyloop.revertRetry.maxAttempts")
+	PolicyLoopRevertRetryDelay                    = ffc("policyloop.revertRetry.delay")
+	LeaderElectionEnabled                         = ffc("leaderelection.enabled")
+	LeaderElectionLeaseTTL                        = ffc("leaderelection.leaseTTL")
 	PolicyEngineName                              = ffc("policyengine.name")
+	PolicyEngineAdditional                        = ffc("policyengine.additional")
+	NonceAllocatorName                            = ffc("nonceallocator.name")
+	KeyManagerName                                = ffc("keymanager.name")
 	EventStreamsDefaultsBatchSize                 = ffc("eventstreams.defaults.batchSize")
 	EventStreamsDefaultsBatchTimeout              = ffc("eventstreams.defaults.batchTimeout")
 	EventStreamsDefaultsErrorHandling             = ffc("eventstreams.defaults.errorHandling")
@@ -45,7 +122,15 @@ var (
 	EventStreamsDefaultsBlockedRetryDelay         = ffc("eventstreams.defaults.blockedRetryDelay")
 	EventStreamsDefaultsWebhookRequestTimeout     = ffc("eventstreams.defaults.webhookRequestTimeout")
 	EventStreamsDefaultsWebsocketDistributionMode = ffc("eventstreams.defaults.websocketDistributionMode")
+	EventStreamsDefaultsWebsocketNackDelay        = ffc("eventstreams.defaults.websocketNackRedeliveryDelay")
+	EventStreamsDefaultsWebsocketMaxRedeliveries  = ffc("eventstreams.defaults.websocketMaxRedeliveries")
 	EventStreamsCheckpointInterval                = ffc("eventstreams.checkpointInterval")
+	EventStreamsDeadLetterMaxEntries              = ffc("eventstreams.deadLetter.maxEntries")
+	EventStreamsDeadLetterRetention               = ffc("eventstreams.deadLetter.retention")
+	EventStreamsSSEHeartbeatInterval              = ffc("eventstreams.sse.heartbeatInterval")
+	EventStreamsWebSocketSendBufferSize           = ffc("eventstreams.websocket.sendBufferSize")
+	EventStreamsWebSocketBackpressurePolicy       = ffc("eventstreams.websocket.backpressurePolicy")
+	EventStreamsWebSocketBlockTimeout             = ffc("eventstreams.websocket.blockTimeout")
 	EventStreamsRetryInitDelay                    = ffc("eventstreams.retry.initialDelay")
 	EventStreamsRetryMaxDelay                     = ffc("eventstreams.retry.maxDelay")
 	EventStreamsRetryFactor                       = ffc("eventstreams.retry.factor")
@@ -54,8 +139,18 @@ var (
 	PersistenceLevelDBPath                        = ffc("persistence.leveldb.path")
 	PersistenceLevelDBMaxHandles                  = ffc("persistence.leveldb.maxHandles")
 	PersistenceLevelDBSyncWrites                  = ffc("persistence.leveldb.syncWrites")
+	PersistenceLevelDBWriteBatchSize              = ffc("persistence.leveldb.writeBatch.size")
+	PersistenceLevelDBWriteBatchFlushInterval     = ffc("persistence.leveldb.writeBatch.flushInterval")
+	PersistenceSQLitePath                         = ffc("persistence.sqlite.path")
+	PersistenceEncryptionKeyFile                  = ffc("persistence.encryption.keyFile")
+	PersistenceEncryptionKeyEnv                   = ffc("persistence.encryption.keyEnv")
 	APIDefaultRequestTimeout                      = ffc("api.defaultRequestTimeout")
 	APIMaxRequestTimeout                          = ffc("api.maxRequestTimeout")
+	APIMaxBatchLength                             = ffc("api.maxBatchLength")
+	APIDefaultLimit                               = ffc("api.defaultLimit")
+	APIMaxLimit                                   = ffc("api.maxLimit")
+	APIMaxLimitAction                             = ffc("api.maxLimitAction")
+	APIAuthName                                   = ffc("apiauth.name")
 )
 
 var APIConfig config.Section
@@ -64,18 +159,101 @@ var CorsConfig config.Section
 
 var PolicyEngineBaseConfig config.Section
 
+var NonceAllocatorBaseConfig config.Section
+
+var KeyManagerBaseConfig config.Section
+
+var APIAuthBaseConfig config.Section
+
 var WebhookPrefix config.Section
 
+var BlockListenersConfig config.ArraySection
+
+var ErrorReasonsConfig config.ArraySection
+
+var SignerBackpressureConfig config.ArraySection
+
+const (
+	BlockListenerConfigName    = "name"
+	BlockListenerConfigFilters = "filters"
+
+	ErrorReasonConfigPattern = "pattern"
+	ErrorReasonConfigReason  = "reason"
+
+	SignerBackpressureConfigSigner    = "signer"
+	SignerBackpressureConfigMode      = "mode"
+	SignerBackpressureConfigThreshold = "threshold"
+)
+
 func setDefaults() {
 	viper.SetDefault(string(TransactionsMaxInFlight), 100)
+	viper.SetDefault(string(TransactionsMaxPendingPerSigner), 0)
+	viper.SetDefault(string(TransactionsBackpressureMode), "queue")
+	viper.SetDefault(string(TransactionsBackpressureThreshold), 0)
+	viper.SetDefault(string(TransactionsPriorityWindow), 1000)
+	viper.SetDefault(string(TransactionsReaperRetention), "0")
+	viper.SetDefault(string(TransactionsReaperInterval), "1h")
+	viper.SetDefault(string(TransactionsReaperBatchSize), 100)
+	viper.SetDefault(string(TransactionsReaperDeletedRetention), "0")
+	viper.SetDefault(string(TransactionsRateLimitRate), 0)
+	viper.SetDefault(string(TransactionsRateLimitBurst), 10)
+	viper.SetDefault(string(TransactionsBlockGasLimit), 0)
+	viper.SetDefault(string(TransactionsMaxDataSize), "256Kb")
+	viper.SetDefault(string(TransactionsLabelsMaxCount), 10)
+	viper.SetDefault(string(TransactionsLabelsMaxLength), 128)
 	viper.SetDefault(string(TransactionsErrorHistoryCount), 25)
+	viper.SetDefault(string(TransactionsMaxHistoryCount), 50)
 	viper.SetDefault(string(TransactionsNonceStateTimeout), "1h")
+	viper.SetDefault(string(TransactionsNonceGapCheckInterval), "1m")
+	viper.SetDefault(string(TransactionsNonceReservationTTL), "5m")
+	viper.SetDefault(string(TransactionsSubmissionTimeout), "24h")
+	viper.SetDefault(string(TransactionsStrictNonceOrdering), true)
+	viper.SetDefault(string(TransactionsIdempotencyKeyRetention), "24h")
+	viper.SetDefault(string(TransactionsCallbackMaxAttempts), 5)
+	viper.SetDefault(string(TransactionsCallbackRetryInitDelay), "1s")
+	viper.SetDefault(string(TransactionsCallbackRetryMaxDelay), "30s")
+	viper.SetDefault(string(TransactionsCallbackRetryFactor), 2.0)
+	viper.SetDefault(string(TransactionsConfirmationWebhookMilestones), []int{1, 6, 12})
+	viper.SetDefault(string(TransactionsConfirmationWebhookMaxAttempts), 5)
+	viper.SetDefault(string(TransactionsConfirmationWebhookRetryInitDelay), "1s")
+	viper.SetDefault(string(TransactionsConfirmationWebhookRetryMaxDelay), "30s")
+	viper.SetDefault(string(TransactionsConfirmationWebhookRetryFactor), 2.0)
 	viper.SetDefault(string(ConfirmationsRequired), 20)
+	viper.SetDefault(string(ConfirmationsMaxRequired), 100)
 	viper.SetDefault(string(ConfirmationsBlockQueueLength), 50)
 	viper.SetDefault(string(ConfirmationsNotificationQueueLength), 50)
 	viper.SetDefault(string(ConfirmationsStaleReceiptTimeout), "1m")
+	viper.SetDefault(string(ConfirmationsConfirmationTimeout), "5m")
+	viper.SetDefault(string(ConfirmationsReorgDetectionDepth), 100)
+	viper.SetDefault(string(ConfirmationsReceiptPollInterval), "0")
+	viper.SetDefault(string(ConfirmationsMaxReceiptChecks), 0)
+	viper.SetDefault(string(ConfirmationsReceiptBatchSize), 50)
+	viper.SetDefault(string(ConfirmationsSettleDelay), "0")
+	viper.SetDefault(string(ConfirmationsStrategiesFast), 1)
+	viper.SetDefault(string(ConfirmationsStrategiesSafe), 20)
 	viper.SetDefault(string(PolicyLoopInterval), "10s")
+	viper.SetDefault(string(PolicyLoopAdaptiveEnabled), false)
+	viper.SetDefault(string(PolicyLoopAdaptiveMinInterval), "1s")
+	viper.SetDefault(string(PolicyLoopAdaptiveMaxInterval), "1m")
+	viper.SetDefault(string(PolicyLoopDryRun), false)
+	viper.SetDefault(string(PolicyLoopDrainTimeout), "10s")
+	viper.SetDefault(string(PolicyLoopWorkers), 1)
+	viper.SetDefault(string(LeaderElectionEnabled), false)
+	viper.SetDefault(string(LeaderElectionLeaseTTL), "30s")
+	viper.SetDefault(string(PolicyLoopHealthCheckInterval), "30s")
+	viper.SetDefault(string(ConnectorInfoRefreshInterval), "5m")
+	viper.SetDefault(string(CircuitBreakerFailureThreshold), 0)
+	viper.SetDefault(string(CircuitBreakerCooldown), "30s")
+	viper.SetDefault(string(ConnectorAuditEnabled), false)
+	viper.SetDefault(string(ConnectorAuditLevel), "info")
+	viper.SetDefault(string(ConnectorAuditRedactFields), []string{"rawTransaction", "signature"})
+	viper.SetDefault(string(ConnectorFailoverStrategy), "priority")
+	viper.SetDefault(string(ConnectorFailoverFailureThreshold), 3)
+	viper.SetDefault(string(ConnectorFailoverRecoveryInterval), "30s")
+	viper.SetDefault(string(TracingServiceName), "fftm")
+	viper.SetDefault(string(TracingOTLPInsecure), false)
 	viper.SetDefault(string(PolicyEngineName), "simple")
+	viper.SetDefault(string(NonceAllocatorName), "local")
 
 	viper.SetDefault(string(EventStreamsDefaultsBatchSize), 50)
 	viper.SetDefault(string(EventStreamsDefaultsBatchTimeout), "5s")
@@ -84,19 +262,40 @@ func setDefaults() {
 	viper.SetDefault(string(EventStreamsDefaultsBlockedRetryDelay), "30s")
 	viper.SetDefault(string(EventStreamsDefaultsWebhookRequestTimeout), "30s")
 	viper.SetDefault(string(EventStreamsDefaultsWebsocketDistributionMode), "load_balance")
+	viper.SetDefault(string(EventStreamsDefaultsWebsocketNackDelay), "5s")
+	viper.SetDefault(string(EventStreamsDefaultsWebsocketMaxRedeliveries), 0)
 	viper.SetDefault(string(EventStreamsCheckpointInterval), "1m")
+	viper.SetDefault(string(EventStreamsDeadLetterMaxEntries), 100)
+	viper.SetDefault(string(EventStreamsDeadLetterRetention), "168h")
+	viper.SetDefault(string(EventStreamsSSEHeartbeatInterval), "15s")
+	viper.SetDefault(string(EventStreamsWebSocketSendBufferSize), 100)
+	viper.SetDefault(string(EventStreamsWebSocketBackpressurePolicy), "block")
+	viper.SetDefault(string(EventStreamsWebSocketBlockTimeout), "30s")
 	viper.SetDefault(string(WebhooksAllowPrivateIPs), true)
 
 	viper.SetDefault(string(PersistenceType), "leveldb")
 	viper.SetDefault(string(PersistenceLevelDBMaxHandles), 100)
 	viper.SetDefault(string(PersistenceLevelDBSyncWrites), false)
+	viper.SetDefault(string(PersistenceLevelDBWriteBatchSize), 0)
+	viper.SetDefault(string(PersistenceLevelDBWriteBatchFlushInterval), "50ms")
 
 	viper.SetDefault(string(APIDefaultRequestTimeout), "30s")
 	viper.SetDefault(string(APIMaxRequestTimeout), "10m")
+	viper.SetDefault(string(APIMaxBatchLength), 1000)
+	viper.SetDefault(string(APIDefaultLimit), 100)
+	viper.SetDefault(string(APIMaxLimit), 1000)
+	viper.SetDefault(string(APIMaxLimitAction), "clamp")
 
 	viper.SetDefault(string(PolicyLoopRetryInitDelay), "250ms")
 	viper.SetDefault(string(PolicyLoopRetryMaxDelay), "30s")
 	viper.SetDefault(string(PolicyLoopRetryFactor), 2.0)
+	viper.SetDefault(string(PolicyLoopBackoffInitDelay), "1s")
+	viper.SetDefault(string(PolicyLoopBackoffMaxDelay), "5m")
+	viper.SetDefault(string(PolicyLoopBackoffFactor), 2.0)
+	viper.SetDefault(string(PolicyLoopBackoffMaxAttempts), 0)
+	viper.SetDefault(string(PolicyLoopBackoffMaxElapsed), "0")
+	viper.SetDefault(string(PolicyLoopRevertRetryMaxAttempts), 0)
+	viper.SetDefault(string(PolicyLoopRevertRetryDelay), "5s")
 	viper.SetDefault(string(EventStreamsRetryInitDelay), "250ms")
 	viper.SetDefault(string(EventStreamsRetryMaxDelay), "30s")
 	viper.SetDefault(string(EventStreamsRetryFactor), 2.0)
@@ -117,4 +316,25 @@ func Reset() {
 	PolicyEngineBaseConfig = config.RootSection("policyengine")
 	// policy engines must be registered outside of this package
 
+	NonceAllocatorBaseConfig = config.RootSection("nonceallocator")
+	// nonce allocators other than the built-in "local" allocator must be registered outside of this package
+
+	KeyManagerBaseConfig = config.RootSection("keymanager")
+	// key managers must be registered outside of this package
+
+	APIAuthBaseConfig = config.RootSection("apiauth")
+	// API auth plugins must be registered outside of this package
+
+	BlockListenersConfig = config.RootArray("blocklisteners")
+	BlockListenersConfig.AddKnownKey(BlockListenerConfigName)
+	BlockListenersConfig.AddKnownKey(BlockListenerConfigFilters)
+
+	ErrorReasonsConfig = config.RootArray("errorreasons")
+	ErrorReasonsConfig.AddKnownKey(ErrorReasonConfigPattern)
+	ErrorReasonsConfig.AddKnownKey(ErrorReasonConfigReason)
+
+	SignerBackpressureConfig = config.RootArray("signerbackpressure")
+	SignerBackpressureConfig.AddKnownKey(SignerBackpressureConfigSigner)
+	SignerBackpressureConfig.AddKnownKey(SignerBackpressureConfigMode)
+	SignerBackpressureConfig.AddKnownKey(SignerBackpressureConfigThreshold)
 }
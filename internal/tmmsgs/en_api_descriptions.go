@@ -27,35 +27,78 @@ var ffm = func(key, translation string) i18n.MessageKey {
 
 //revive:disable
 var (
-	APIEndpointPostRoot                     = ffm("api.endpoints.post.root", "RPC/webhook style interface initiate a submit transactions, and execute queries")
-	APIEndpointPostRootQueryOutput          = ffm("api.endpoints.post.root.query.output", "The data result of a query against a smart contract")
-	APIEndpointPostEventStream              = ffm("api.endpoints.post.eventstreams", "Create a new event stream")
-	APIEndpointPatchEventStream             = ffm("api.endpoints.patch.eventstreams", "Update an existing event stream")
-	APIEndpointPostEventStreamSuspend       = ffm("api.endpoints.post.eventstream.suspend", "Suspend an event stream")
-	APIEndpointPostEventStreamResume        = ffm("api.endpoints.post.eventstream.resume", "Resume an event stream")
-	APIEndpointGetEventStreams              = ffm("api.endpoints.get.eventstreams", "List event streams")
-	APIEndpointGetEventStream               = ffm("api.endpoints.get.eventstream", "Get an event stream with status")
-	APIEndpointDeleteEventStream            = ffm("api.endpoints.delete.eventstream", "Delete an event stream")
-	APIEndpointDeleteTransaction            = ffm("api.endpoints.delete.transaction", "Request transaction deletion by the policy engine. Result could be immediate (200), asynchronous (202), or rejected with an error")
-	APIEndpointGetSubscriptions             = ffm("api.endpoints.get.subscriptions", "Get listeners - route deprecated in favor of /eventstreams/{streamId}/listeners")
-	APIEndpointGetSubscription              = ffm("api.endpoints.get.subscription", "Get listener - route deprecated in favor of /eventstreams/{streamId}/listeners/{listenerId}")
-	APIEndpointPostSubscriptions            = ffm("api.endpoints.post.subscriptions", "Create new listener - route deprecated in favor of /eventstreams/{streamId}/listeners")
-	APIEndpointPostSubscriptionReset        = ffm("api.endpoints.post.subscription.reset", "Reset listener - route deprecated in favor of /eventstreams/{streamId}/listeners/{listenerId}/reset")
-	APIEndpointPatchSubscription            = ffm("api.endpoints.patch.subscription", "Update listener - route deprecated in favor of /eventstreams/{streamId}/listeners/{listenerId}")
-	APIEndpointDeleteSubscription           = ffm("api.endpoints.delete.subscription", "Delete listener - route deprecated in favor of /eventstreams/{streamId}/listeners/{listenerId}")
-	APIEndpointGetEventStreamListeners      = ffm("api.endpoints.get.eventstream.listeners", "List event stream listeners")
-	APIEndpointGetEventStreamListener       = ffm("api.endpoints.get.eventstream.listener", "Get event stream listener")
-	APIEndpointPostEventStreamListener      = ffm("api.endpoints.post.eventstream.listener", "Create event stream listener")
-	APIEndpointPostEventStreamListenerReset = ffm("api.endpoints.post.eventstream.listener.reset", "Reset an event stream listener, to redeliver all events since the specified block")
-	APIEndpointPatchEventStreamListener     = ffm("api.endpoints.patch.eventstream.listener", "Update event stream listener")
-	APIEndpointDeleteEventStreamListener    = ffm("api.endpoints.delete.eventstream.listener", "Delete event stream listener")
+	APIEndpointPostRoot                        = ffm("api.endpoints.post.root", "RPC/webhook style interface initiate a submit transactions, and execute queries")
+	APIEndpointPostRootQueryOutput             = ffm("api.endpoints.post.root.query.output", "The data result of a query against a smart contract")
+	APIEndpointPostEventStream                 = ffm("api.endpoints.post.eventstreams", "Create a new event stream")
+	APIEndpointPatchEventStream                = ffm("api.endpoints.patch.eventstreams", "Update an existing event stream")
+	APIEndpointPostEventStreamSuspend          = ffm("api.endpoints.post.eventstream.suspend", "Suspend an event stream")
+	APIEndpointPostEventStreamResume           = ffm("api.endpoints.post.eventstream.resume", "Resume an event stream")
+	APIEndpointPostEventStreamDeadLetterReplay = ffm("api.endpoints.post.eventstream.deadletter.replay", "Redeliver the batches of events that could not be delivered to an event stream, oldest first, removing each that is delivered successfully. Stops at the first batch that fails redelivery")
+	APIEndpointPostEventStreamReplay           = ffm("api.endpoints.post.eventstream.replay", "Re-deliver the confirmed events in a historical block range to an event stream, marked as replayed. The checkpoint of the stream is not affected")
+	APIEndpointPostEventStreamPause            = ffm("api.endpoints.post.eventstream.pause", "Pause an event stream. An alias of POST /eventstreams/{streamId}/suspend. The stream is not restarted on startup until it is resumed. A paused stream does not track blocks, so on resume the connector replays events from the last checkpoint, and delivery continues without gaps")
+	APIEndpointGetEventStreams                 = ffm("api.endpoints.get.eventstreams", "List event streams")
+	APIEndpointGetEventStreamsExport           = ffm("api.endpoints.get.eventstreams.export", "Export the definitions of all event streams and their listeners as a portable document, without IDs or checkpoints")
+	APIEndpointPostEventStreamsImport          = ffm("api.endpoints.post.eventstreams.import", "Recreate the event streams and listeners in an export document, skipping or overwriting existing streams with the same name. Returns the result for each stream")
+	APIEndpointGetEventStream                  = ffm("api.endpoints.get.eventstream", "Get an event stream with status")
+	APIEndpointDeleteEventStream               = ffm("api.endpoints.delete.eventstream", "Delete an event stream")
+	APIEndpointGetEventStreamCheckpoint        = ffm("api.endpoints.get.eventstream.checkpoint", "Get the persisted checkpoint of an event stream")
+	APIEndpointPutEventStreamCheckpoint        = ffm("api.endpoints.put.eventstream.checkpoint", "Set the checkpoint of listeners on an event stream, to resume delivery from a known position. The stream is restarted if it is running")
+	APIEndpointPostTransactionsRaw             = ffm("api.endpoints.post.transactions.raw", "Submit a transaction signed outside of FFTM, for FFTM to submit and track through to confirmation without assigning a nonce")
+	APIEndpointPostTransactionsEstimate        = ffm("api.endpoints.post.transactions.estimate", "Estimate the gas and gas price for a transaction using the connector and policy engine, without submitting it")
+	APIEndpointPostTransactionsStatus          = ffm("api.endpoints.post.transactions.status", "Get the current status of many transactions at once, by an array of transaction IDs. Returns a map of ID to status, where IDs that are not found are marked as such")
+	APIEndpointPostControlPause                = ffm("api.endpoints.post.control.pause", "Pause all submissions to the blockchain, as an emergency stop. Pending transactions are not sent, cancelled or resubmitted until submissions are resumed, but new transactions are accepted and confirmations are still processed. The pause is persisted, so it remains in force over a restart")
+	APIEndpointPostControlResume               = ffm("api.endpoints.post.control.resume", "Resume submissions to the blockchain, after they have been paused")
+	APIEndpointGetTransactionByHash            = ffm("api.endpoints.get.transaction.byhash", "Get the transaction submitted with a given on-chain hash. Matches the hash of any submission of the transaction, including those replaced by a resubmission, and of any no-op submitted to cancel it")
+	APIEndpointGetTransactionHistory           = ffm("api.endpoints.get.transaction.history", "Get the history of actions taken for a transaction")
+	APIEndpointGetTransactionDependents        = ffm("api.endpoints.get.transaction.dependents", "Get the transactions that declared a dependency on a transaction, in reverse creation order. Dependents are still returned after the transaction they depend on has been deleted")
+	APIEndpointGetTransactionConfirmations     = ffm("api.endpoints.get.transaction.confirmations", "Get the confirmation progress of a transaction, including the blocks confirming it so far")
+	APIEndpointGetTransactionTrace             = ffm("api.endpoints.get.transaction.trace", "Get the revert reason and execution trace of a transaction, if supported by the connector")
+	APIEndpointPostTransactionCancel           = ffm("api.endpoints.post.transaction.cancel", "Request cancellation of a submitted transaction, by replacing it with a no-op transaction at the same nonce. The outcome is reported on the transaction once either is mined")
+	APIEndpointDeleteTransaction               = ffm("api.endpoints.delete.transaction", "Request transaction deletion by the policy engine. Result could be immediate (200), asynchronous (202), or rejected with an error. When transactions.reaper.deletedRetention is set, a deleted transaction is retained and can be restored until the retention period passes - deleting it again removes it immediately")
+	APIEndpointPostTransactionUndelete         = ffm("api.endpoints.post.transaction.undelete", "Restore a deleted transaction to the status it had when it was deleted, before it is removed by the reaper")
+	APIEndpointGetSubscriptions                = ffm("api.endpoints.get.subscriptions", "Get listeners - route deprecated in favor of /eventstreams/{streamId}/listeners")
+	APIEndpointGetSubscription                 = ffm("api.endpoints.get.subscription", "Get listener - route deprecated in favor of /eventstreams/{streamId}/listeners/{listenerId}")
+	APIEndpointPostSubscriptions               = ffm("api.endpoints.post.subscriptions", "Create new listener - route deprecated in favor of /eventstreams/{streamId}/listeners")
+	APIEndpointPostSubscriptionReset           = ffm("api.endpoints.post.subscription.reset", "Reset listener - route deprecated in favor of /eventstreams/{streamId}/listeners/{listenerId}/reset")
+	APIEndpointPatchSubscription               = ffm("api.endpoints.patch.subscription", "Update listener - route deprecated in favor of /eventstreams/{streamId}/listeners/{listenerId}")
+	APIEndpointDeleteSubscription              = ffm("api.endpoints.delete.subscription", "Delete listener - route deprecated in favor of /eventstreams/{streamId}/listeners/{listenerId}")
+	APIEndpointGetEventStreamDeadLetters       = ffm("api.endpoints.get.eventstream.deadletters", "List the batches of events that could not be delivered to an event stream, oldest first")
+	APIEndpointGetEventStreamListeners         = ffm("api.endpoints.get.eventstream.listeners", "List event stream listeners")
+	APIEndpointGetEventStreamListener          = ffm("api.endpoints.get.eventstream.listener", "Get event stream listener")
+	APIEndpointPostEventStreamListener         = ffm("api.endpoints.post.eventstream.listener", "Create event stream listener")
+	APIEndpointPostEventStreamListenerReset    = ffm("api.endpoints.post.eventstream.listener.reset", "Reset an event stream listener, to redeliver all events since the specified block")
+	APIEndpointPatchEventStreamListener        = ffm("api.endpoints.patch.eventstream.listener", "Update event stream listener")
+	APIEndpointGetConnectorInfo                = ffm("api.endpoints.get.connector.info", "Get the name, version and capabilities reported by the blockchain connector")
+	APIEndpointGetNonceGaps                    = ffm("api.endpoints.get.nonce.gaps", "List the signing addresses with a nonce that is neither in-flight nor mined, which prevents any of their later transactions being mined. Updated by the policy loop at the nonce gap check interval")
+	APIEndpointGetTransactionStats             = ffm("api.endpoints.get.transactions.stats", "Get the number of transactions in each status, with the in-flight and pending counts, and the number submitted and the average time to confirmation over a recent window")
+	APIEndpointGetBackpressure                 = ffm("api.endpoints.get.backpressure", "Get the backlog of in-flight and pending transactions, globally and for each signer with its own backpressure policy, relative to the threshold at which submissions are rejected")
+	APIEndpointGetNonces                       = ffm("api.endpoints.get.nonces", "List the signing addresses currently holding a nonce lock, with the locked nonce and the next nonce reported by the blockchain")
+	APIEndpointPostNonceRefresh                = ffm("api.endpoints.post.nonce.refresh", "Re-read the next nonce for a signing address from the blockchain, and move the next nonce allocated for it forwards if transactions sent outside of FFTM have used nonces it would otherwise assign. Waits for any nonce assignment in progress for the signer to complete, and reports the previous and new next nonce")
+	APIEndpointPostNonceReserve                = ffm("api.endpoints.post.nonce.reserve", "Allocate the next nonce for a signing address, for a transaction that will be signed outside of FFTM. The nonce is not assigned to any other transaction until the signed transaction is submitted to /transactions/raw with the reservation ID, or the reservation expires")
+	APIEndpointPostNonceReset                  = ffm("api.endpoints.post.nonce.reset", "Clear any nonce lock held for a signing address, and allocate the next nonce for it from the next nonce reported by the blockchain")
+	APIEndpointDeleteEventStreamListener       = ffm("api.endpoints.delete.eventstream.listener", "Delete event stream listener")
+	APIEndpointPostEventStreamSSEAck           = ffm("api.endpoints.post.eventstream.sse.ack", "Acknowledge a batch delivered to a Server-Sent Events client connected with ack=manual, so the stream checkpoint advances and the next batch is delivered")
 
-	APIParamStreamID      = ffm("api.params.streamId", "Event Stream ID")
-	APIParamListenerID    = ffm("api.params.listenerId", "Listener ID")
-	APIParamTransactionID = ffm("api.params.transactionId", "Transaction ID")
-	APIParamLimit         = ffm("api.params.limit", "Maximum number of entries to return")
-	APIParamAfter         = ffm("api.params.after", "Return entries after this ID - for pagination (non-inclusive)")
-	APIParamTXSigner      = ffm("api.params.txSigner", "Return only transactions for a specific signing address, in reverse nonce order")
-	APIParamTXPending     = ffm("api.params.txPending", "Return only pending transactions, in reverse submission sequence (a 'sequenceId' is assigned to each transaction to determine its sequence")
-	APIParamSortDirection = ffm("api.params.sortDirection", "Sort direction: 'asc'/'ascending' or 'desc'/'descending'")
+	APIParamStreamID         = ffm("api.params.streamId", "Event Stream ID")
+	APIParamListenerID       = ffm("api.params.listenerId", "Listener ID")
+	APIParamTransactionID    = ffm("api.params.transactionId", "Transaction ID")
+	APIParamTransactionHash  = ffm("api.params.transactionHash", "Transaction hash")
+	APIParamSigner           = ffm("api.params.signer", "Signing address")
+	APIParamConnector        = ffm("api.params.connector", "Named connector of the chain, with the default connector used if not set")
+	APIParamStatsWindow      = ffm("api.params.statsWindow", "The recent window to report submissions and the average confirmation time over, such as 30m, with a number of minutes if no unit is supplied. Defaults to 10m")
+	APIParamLimit            = ffm("api.params.limit", "Maximum number of entries to return")
+	APIParamAfter            = ffm("api.params.after", "Return entries after this ID - for pagination (non-inclusive)")
+	APIParamTXSigner         = ffm("api.params.txSigner", "Return only transactions for a specific signing address, in reverse nonce order")
+	APIParamTXPending        = ffm("api.params.txPending", "Return only pending transactions, in reverse submission sequence (a 'sequenceId' is assigned to each transaction to determine its sequence")
+	APIParamTXFromTime       = ffm("api.params.txFromTime", "Return only transactions created at or after this time")
+	APIParamTXToTime         = ffm("api.params.txToTime", "Return only transactions created at or before this time")
+	APIParamTXLabel          = ffm("api.params.txLabel", "Return only transactions with the specified label, in the format 'key=value'. Can be repeated to return only transactions with all of the labels")
+	APIParamTXRequestID      = ffm("api.params.txRequestId", "Return only transactions submitted with the specified caller supplied request ID")
+	APIParamTXIncludeDeleted = ffm("api.params.txIncludeDeleted", "Include deleted transactions that are retained until the deleted retention period passes, which are otherwise only returned when requested with status=Deleted")
+	APIParamTXStatus         = ffm("api.params.txStatus", "Return only transactions in the specified status: 'pending', 'succeeded', 'failed' or 'wouldsubmit' (dry-run mode). Can be combined with 'signer' to return transactions in reverse nonce order")
+	APIParamSortDirection    = ffm("api.params.sortDirection", "Sort direction: 'asc'/'ascending' or 'desc'/'descending'")
+	APIParamWaitConfirmed    = ffm("api.params.waitConfirmed", "Block until the transaction is complete, or the timeout is reached. Returns 200 with the final state, or 202 if the transaction is still pending")
+	APIParamWaitTimeout      = ffm("api.params.waitTimeout", "Maximum time to wait when waitConfirmed is set - defaults to 30s")
+	APIParamConfirmRewind    = ffm("api.params.confirmRewind", "Must be set to move a listener checkpoint backwards, which will cause events to be redelivered")
+	APIParamSSEBatch         = ffm("api.params.sseBatch", "The number of the batch to acknowledge, from the id of the Server-Sent Event it was delivered in")
 )
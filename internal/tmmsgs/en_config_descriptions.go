@@ -28,6 +28,10 @@ var ffc = func(key, translation, fieldType string) i18n.ConfigMessageKey {
 //revive:disable
 var (
 	ConfigAPIDefaultRequestTimeout = ffc("config.api.defaultRequestTimeout", "Default server-side request timeout for API calls", i18n.TimeDurationType)
+	ConfigAPIMaxBatchLength        = ffc("config.api.maxBatchLength", "The maximum number of items in the array of a request that acts on many items at once, such as an event stream import or a bulk transaction status lookup. Set to 0 for no maximum", i18n.IntType)
+	ConfigAPIDefaultLimit          = ffc("config.api.defaultLimit", "The number of entries returned by a list API, such as the transactions or event streams, when no limit is supplied. Set to 0 to return all entries", i18n.IntType)
+	ConfigAPIMaxLimit              = ffc("config.api.maxLimit", "The maximum limit a caller can supply on a list API, to protect the persistence layer from expensive queries. Set to 0 for no maximum", i18n.IntType)
+	ConfigAPIMaxLimitAction        = ffc("config.api.maxLimitAction", "What happens to a request with a limit above the maximum. 'clamp' returns the maximum number of entries, and 'reject' rejects the request with a 400", "clamp | reject")
 	ConfigAPIMaxRequestTimeout     = ffc("config.api.maxRequestTimeout", "Maximum server-side request timeout a caller can request with a Request-Timeout header", i18n.TimeDurationType)
 	ConfigAPIAddress               = ffc("config.api.address", "Listener address for API", i18n.StringType)
 	ConfigAPIPort                  = ffc("config.api.port", "Listener port for API", i18n.IntType)
@@ -36,28 +40,142 @@ var (
 	ConfigAPIWriteTimeout          = ffc("config.api.writeTimeout", "The maximum time to wait when writing to a HTTP connection", i18n.TimeDurationType)
 	ConfigAPIShutdownTimeout       = ffc("config.api.shutdownTimeout", "The maximum amount of time to wait for any open HTTP requests to finish before shutting down the HTTP server", i18n.TimeDurationType)
 
+	ConfigAPIAuthName = ffc("config.apiauth.name", "The name of a registered API auth plugin, to authorize each request to the API server. Leave empty to allow all requests", i18n.StringType)
+
 	ConfigConfirmationsBlockCacheSize           = ffc("config.confirmations.blockCacheSize", "The maximum number of block headers to keep in the cache", i18n.IntType)
 	ConfigConfirmationsBlockQueueLength         = ffc("config.confirmations.blockQueueLength", "Internal queue length for notifying the confirmations manager of new blocks", i18n.IntType)
+	ConfigConfirmationsMaxRequired              = ffc("config.confirmations.maxRequired", "The maximum number of confirmations an individual event stream can be configured to require, as an override of the default", i18n.IntType)
 	ConfigConfirmationsNotificationsQueueLength = ffc("config.confirmations.notificationQueueLength", "Internal queue length for notifying the confirmations manager of new transactions/events", i18n.IntType)
+	ConfigConfirmationsReorgDetectionDepth      = ffc("config.confirmations.reorgDetectionDepth", "The number of blocks behind the head of the chain, for which a re-org of the block containing an already confirmed event will cause a rollback notification for that event", i18n.IntType)
+	ConfigConfirmationsMaxReceiptChecks         = ffc("config.confirmations.maxReceiptChecks", "The maximum number of transaction receipts to query in each cycle of the confirmation manager. Remaining receipt checks are deferred to later cycles. 0 for no limit", i18n.IntType)
+	ConfigConfirmationsReceiptBatchSize         = ffc("config.confirmations.receiptBatchSize", "The maximum number of transaction receipts to query in a single call to the connector, for connectors that support batched receipt queries", i18n.IntType)
+	ConfigConfirmationsReceiptPollInterval      = ffc("config.confirmations.receiptPollInterval", "Interval at which the confirmation manager wakes up to check stale receipts, even when no new blocks or notifications arrive. 0 to only check on new blocks/notifications", i18n.TimeDurationType)
+	ConfigConfirmationsSettleDelay              = ffc("config.confirmations.settleDelay", "Minimum duration to wait after a transaction/event meets its confirmation requirement, before a final re-check of its block and confirmations. A re-org detected during this time restarts confirmation. Set to 0 to disable", i18n.TimeDurationType)
 	ConfigConfirmationsRequired                 = ffc("config.confirmations.required", "Number of confirmations required to consider a transaction/event final", i18n.IntType)
 	ConfigConfirmationsStaleReceiptTimeout      = ffc("config.confirmations.staleReceiptTimeout", "Duration after which to force a receipt check for a pending transaction", i18n.TimeDurationType)
+	ConfigConfirmationsConfirmationTimeout      = ffc("config.confirmations.confirmationTimeout", "Duration a pending transaction can make no progress towards confirmation via the block stream, before its receipt is queried directly to recover from missed blocks. Set to 0 to disable", i18n.TimeDurationType)
+	ConfigConfirmationsStrategiesFast           = ffc("config.confirmations.strategies.fast", "Number of confirmations required by the 'fast' confirmation strategy, which an event stream or transaction can reference by name", i18n.IntType)
+	ConfigConfirmationsStrategiesSafe           = ffc("config.confirmations.strategies.safe", "Number of confirmations required by the 'safe' confirmation strategy, which an event stream or transaction can reference by name", i18n.IntType)
+
+	ConfigTransactionsCallbackMaxAttempts     = ffc("config.transactions.completionCallback.maxAttempts", "The maximum number of attempts to deliver a completed transaction to its completion callback URL, before delivery is abandoned", i18n.IntType)
+	ConfigTransactionsCallbackRetryInitDelay  = ffc("config.transactions.completionCallback.retry.initialDelay", "Initial delay between attempts to deliver a completion callback", i18n.TimeDurationType)
+	ConfigTransactionsCallbackRetryMaxDelay   = ffc("config.transactions.completionCallback.retry.maxDelay", "Maximum delay between attempts to deliver a completion callback", i18n.TimeDurationType)
+	ConfigTransactionsCallbackRetryFactor     = ffc("config.transactions.completionCallback.retry.factor", "Factor to increase the delay by, between attempts to deliver a completion callback", i18n.FloatType)
+	ConfigTransactionsErrorHistoryCount       = ffc("config.transactions.errorHistoryCount", "The number of historical errors to retain in the operation", i18n.IntType)
+	ConfigTransactionsIdempotencyKeyRetention = ffc("config.transactions.idempotencyKeyRetention", "How long an idempotency key supplied on submission is remembered for a signing address. A submission with the same key and signer within this window returns the existing transaction", i18n.TimeDurationType)
+	ConfigTransactionsLabelsMaxCount          = ffc("config.transactions.labels.maxCount", "The maximum number of labels that can be set on a transaction", i18n.IntType)
+	ConfigTransactionsLabelsMaxLength         = ffc("config.transactions.labels.maxLength", "The maximum length of each label key and value set on a transaction", i18n.IntType)
+	ConfigTransactionsMaxHistoryCount         = ffc("config.transactions.maxHistoryCount", "The maximum number of history entries to retain in the operation. Older entries are collapsed into a single summary entry", i18n.IntType)
+	ConfigTransactionsMaxInflight             = ffc("config.transactions.maxInFlight", "The maximum number of transactions to have in-flight with the policy engine / blockchain transaction pool", i18n.IntType)
+	ConfigTransactionsBackpressureMode        = ffc("config.transactions.backpressure.mode", "What happens to submissions once the backlog of in-flight and pending transactions reaches the threshold. 'queue' accepts them to wait for space in the in-flight set, and 'reject' rejects them with a 429 so the client can retry later. Signers with an entry in signerbackpressure use their own mode and threshold instead", "queue | reject")
+	ConfigTransactionsBackpressureThreshold   = ffc("config.transactions.backpressure.threshold", "The backlog of in-flight and pending transactions across all signers at which submissions are rejected, in reject mode. Set to 0 to use transactions.maxInFlight, so submissions are rejected rather than waiting for space in the in-flight set", i18n.IntType)
+	ConfigTransactionsMaxPendingPerSigner     = ffc("config.transactions.maxPendingPerSigner", "The maximum number of pending transactions each signer can have waiting outside of the in-flight set, before further submissions for the signer are rejected with a 429. Set to 0 for no maximum", i18n.IntType)
+	ConfigTransactionsNonceReservationTTL     = ffc("config.transactions.nonceReservation.ttl", "How long a nonce reserved for a transaction signed outside of FFTM is held, if no ttl is supplied on the reservation. Once expired and unused, the nonce is assigned to the next transaction for the signer", i18n.TimeDurationType)
+	ConfigTransactionsNonceGapCheckInterval   = ffc("config.transactions.nonceGapCheckInterval", "Interval at which the policy loop checks each signer with in-flight transactions for nonces that are neither in-flight nor mined, which halt all later transactions from the signer. Set to 0 to disable", i18n.TimeDurationType)
+	ConfigTransactionsNonceStateTimeout       = ffc("config.transactions.nonceStateTimeout", "How old the most recently submitted transaction record in our local state needs to be, before we make a request to the node to query the next nonce for a signing address", i18n.TimeDurationType)
+	ConfigTransactionsPriorityWindow          = ffc("config.transactions.priorityWindow", "The number of pending transactions outside of the in-flight set that are considered, in priority order, when there is space in the in-flight set. Transactions beyond this window are considered once earlier ones complete", i18n.IntType)
+	ConfigTransactionsReaperRetention         = ffc("config.transactions.reaper.retention", "How long transactions are kept after they have succeeded or failed, before they are deleted along with their history and receipt. Pending and scheduled transactions are never deleted. Set to 0 to disable deletion", i18n.TimeDurationType)
+	ConfigTransactionsReaperInterval          = ffc("config.transactions.reaper.interval", "Interval at which to delete transactions that have passed the retention period", i18n.TimeDurationType)
+	ConfigTransactionsReaperBatchSize         = ffc("config.transactions.reaper.batchSize", "The number of transactions to read from persistence at a time, when checking for transactions to delete", i18n.IntType)
+	ConfigTransactionsReaperDeletedRetention  = ffc("config.transactions.reaper.deletedRetention", "How long a transaction is retained after it is deleted, during which time it is hidden from listings but can be restored with undelete. Set to 0 to remove transactions from persistence as soon as they are deleted", i18n.TimeDurationType)
+	ConfigTransactionsRateLimitRate           = ffc("config.transactions.rateLimit.rate", "The sustained number of transactions per second each signer can submit, before further submissions are rejected with a 429. Set to 0 to disable rate limiting", i18n.FloatType)
+	ConfigTransactionsMaxDataSize             = ffc("config.transactions.maxDataSize", "The maximum size of the encoded data of a transaction, or of a raw signed transaction. Larger submissions are rejected before a nonce is assigned. Set to 0 for no maximum", i18n.ByteSizeType)
+	ConfigTransactionsBlockGasLimit           = ffc("config.transactions.blockGasLimit", "The maximum gas limit of a block on the chain. A gas limit increased by the gasLimitMultiplier of a submission is reduced to this value. Set to 0 for no maximum", i18n.IntType)
+	ConfigTransactionsRateLimitBurst          = ffc("config.transactions.rateLimit.burst", "The number of transactions a signer can submit in a burst above the sustained rate", i18n.IntType)
+	ConfigTransactionsSignersAllow            = ffc("config.transactions.signers.allow", "If set, only these signing addresses can submit transactions. Hex addresses are matched case-insensitively", "[]string")
+	ConfigTransactionsSignersDeny             = ffc("config.transactions.signers.deny", "Signing addresses that cannot submit transactions. Takes precedence over the allow list", "[]string")
+	ConfigTransactionsStrictNonceOrdering     = ffc("config.transactions.strictNonceOrdering", "Whether transactions from a signer are only submitted for the first time once all lower nonces in the in-flight set have been submitted, so a higher nonce never reaches the node ahead of a lower one", i18n.BooleanType)
+	ConfigTransactionsSubmissionTimeout       = ffc("config.transactions.submissionTimeout", "How long after first submission, or the most recent gas price change, a transaction can remain without a receipt before it is marked as failed. Can be overridden for individual transactions. Set to 0 to disable", i18n.TimeDurationType)
+
+	ConfigTransactionsConfirmationWebhookMilestones     = ffc("config.transactions.confirmationWebhook.milestones", "The confirmation depths a transaction with a confirmation webhook is notified at, unless the transaction supplies its own. A transaction is tracked past its confirmation requirement until its deepest milestone is reached", "[]int")
+	ConfigTransactionsConfirmationWebhookSecret         = ffc("config.transactions.confirmationWebhook.secret", "The secret to sign the payload of each confirmation webhook with, using HMAC-SHA256. Confirmation webhooks cannot be registered unless this is set", i18n.StringType)
+	ConfigTransactionsConfirmationWebhookMaxAttempts    = ffc("config.transactions.confirmationWebhook.maxAttempts", "The maximum number of attempts to deliver each confirmation milestone to its webhook URL, before delivery is abandoned", i18n.IntType)
+	ConfigTransactionsConfirmationWebhookRetryInitDelay = ffc("config.transactions.confirmationWebhook.retry.initialDelay", "Initial delay between attempts to deliver a confirmation milestone", i18n.TimeDurationType)
+	ConfigTransactionsConfirmationWebhookRetryMaxDelay  = ffc("config.transactions.confirmationWebhook.retry.maxDelay", "Maximum delay between attempts to deliver a confirmation milestone", i18n.TimeDurationType)
+	ConfigTransactionsConfirmationWebhookRetryFactor    = ffc("config.transactions.confirmationWebhook.retry.factor", "Factor to increase the delay by, between attempts to deliver a confirmation milestone", i18n.FloatType)
+
+	ConfigPolicyEngineName       = ffc("config.policyengine.name", "The name of the policy engine to use", i18n.StringType)
+	ConfigPolicyEngineAdditional = ffc("config.policyengine.additional", "The names of additional policy engines to initialize, which can be selected for individual transactions with the policyEngine request header", "[]string")
+
+	ConfigKeyManagerName     = ffc("config.keymanager.name", "The name of a registered key manager, to resolve the key name of a submission to the signing address. Leave empty to use the key name as the signing address", i18n.StringType)
+	ConfigNonceAllocatorName = ffc("config.nonceallocator.name", "The name of the nonce allocator to use. The built-in 'local' allocator assigns nonces from the local transaction state", i18n.StringType)
+
+	ConfigBlockListenersName    = ffc("config.blocklisteners[].name", "The name of a block listener scoped to specific contracts, which event streams can select with blockListener to receive new block notifications from it instead of from every block on the chain", i18n.StringType)
+	ConfigBlockListenersFilters = ffc("config.blocklisteners[].filters", "Connector specific filters (such as contract addresses and topics) passed to the connector when creating the block listener", "[]object")
+
+	ConfigSignerBackpressureSigner    = ffc("config.signerbackpressure[].signer", "The signing address the backpressure mode and threshold apply to, instead of those configured in transactions.backpressure", i18n.StringType)
+	ConfigSignerBackpressureMode      = ffc("config.signerbackpressure[].mode", "What happens to submissions for the signer once its backlog of in-flight and pending transactions reaches the threshold. Defaults to queue", "queue | reject")
+	ConfigSignerBackpressureThreshold = ffc("config.signerbackpressure[].threshold", "The backlog of in-flight and pending transactions for the signer at which its submissions are rejected, in reject mode. Set to 0 to use transactions.maxInFlight", i18n.IntType)
+	ConfigErrorReasonsPattern         = ffc("config.errorreasons[].pattern", "A regular expression matched against the error message from the blockchain connector, when the connector returns an error without a reason. Patterns are checked in the order they are configured, and the first match is used", i18n.StringType)
+	ConfigErrorReasonsReason          = ffc("config.errorreasons[].reason", "The reason given to errors matching the pattern, which determines how FFTM handles the error. One of invalid_inputs, transaction_reverted, nonce_too_low, transaction_underpriced, insufficient_funds, not_found or known_transaction", i18n.StringType)
+
+	ConfigCircuitBreakerFailureThreshold    = ffc("config.circuitbreaker.failureThreshold", "The number of consecutive failed calls to the blockchain connector, after which calls fail immediately without waiting on the connector until the cooldown has passed. Errors with a reason returned by the connector, such as a reverted transaction, are not failures. Set to 0 to disable", i18n.IntType)
+	ConfigConnectorAuditEnabled             = ffc("config.connectoraudit.enabled", "Log every call to the blockchain connector, with its request, response, duration and any error, for audit", i18n.BooleanType)
+	ConfigConnectorAuditLevel               = ffc("config.connectoraudit.level", "The log level of the connector audit log entries", "trace | debug | info | warn | error")
+	ConfigConnectorAuditRedactFields        = ffc("config.connectoraudit.redactFields", "The names of JSON fields in connector requests and responses, at any depth, to redact in the connector audit log", "[]string")
+	ConfigConnectorFailoverEndpoints        = ffc("config.connectorfailover.endpoints", "The endpoints of the blockchain connector backends to fail over between, in priority order, when the connector is built with NewFailoverConnector", "[]string")
+	ConfigConnectorFailoverStrategy         = ffc("config.connectorfailover.strategy", "The order in which healthy connector backends are tried - priority always prefers the earliest endpoint, roundRobin spreads calls across them", "priority | roundRobin")
+	ConfigConnectorFailoverFailureThreshold = ffc("config.connectorfailover.failureThreshold", "The number of consecutive failed calls after which a connector backend is marked unhealthy", i18n.IntType)
+	ConfigConnectorFailoverRecoveryInterval = ffc("config.connectorfailover.recoveryInterval", "How long an unhealthy connector backend is skipped, before it is tried again and re-included on success", i18n.TimeDurationType)
+	ConfigTracingServiceName                = ffc("config.tracing.serviceName", "The service name reported on the OpenTelemetry spans for transaction submission and processing", i18n.StringType)
+	ConfigTracingOTLPEndpoint               = ffc("config.tracing.otlp.endpoint", "The host and port of an OTLP/HTTP collector to export OpenTelemetry spans to. Tracing is disabled if not set", i18n.StringType)
+	ConfigTracingOTLPInsecure               = ffc("config.tracing.otlp.insecure", "Export spans to the OTLP collector over HTTP, rather than HTTPS", i18n.BooleanType)
+	ConfigCircuitBreakerCooldown            = ffc("config.circuitbreaker.cooldown", "How long calls to the blockchain connector fail immediately once the failure threshold is reached, before a single call is allowed through to check whether it has recovered", i18n.TimeDurationType)
 
-	ConfigTransactionsErrorHistoryCount = ffc("config.transactions.errorHistoryCount", "The number of historical errors to retain in the operation", i18n.IntType)
-	ConfigTransactionsMaxInflight       = ffc("config.transactions.maxInFlight", "The maximum number of transactions to have in-flight with the policy engine / blockchain transaction pool", i18n.IntType)
-	ConfigTransactionsNonceStateTimeout = ffc("config.transactions.nonceStateTimeout", "How old the most recently submitted transaction record in our local state needs to be, before we make a request to the node to query the next nonce for a signing address", i18n.TimeDurationType)
+	ConfigConnectorInfoRefreshInterval = ffc("config.connectorinfo.refreshInterval", "Interval at which to refresh the version and capabilities reported by the blockchain connector, which are first queried on startup. Set to 0 to only query them when first needed", i18n.TimeDurationType)
 
-	ConfigPolicyEngineName = ffc("config.policyengine.name", "The name of the policy engine to use", i18n.StringType)
+	ConfigLoopInterval                   = ffc("config.policyloop.interval", "Interval at which to invoke the policy engine to evaluate outstanding transactions", i18n.TimeDurationType)
+	ConfigLoopAdaptiveEnabled            = ffc("config.policyloop.adaptive.enabled", "Adapt the interval of the policy loop to the load. The interval drops to policyloop.adaptive.minInterval while there are in-flight transactions, and doubles towards policyloop.adaptive.maxInterval on each cycle with nothing to do. Replaces policyloop.interval, other than as the starting interval", i18n.BooleanType)
+	ConfigLoopAdaptiveMinInterval        = ffc("config.policyloop.adaptive.minInterval", "The interval of the policy loop while there are in-flight transactions, when adaptive", i18n.TimeDurationType)
+	ConfigLoopAdaptiveMaxInterval        = ffc("config.policyloop.adaptive.maxInterval", "The interval the policy loop backs off to while idle, when adaptive", i18n.TimeDurationType)
+	ConfigLoopDryRun                     = ffc("config.policyloop.dryRun", "Run the policy engine without submitting transactions to the blockchain. Transactions the policy engine attempts to submit are moved to the WouldSubmit status, with the intended submission recorded in their history", i18n.BooleanType)
+	ConfigLoopDrainTimeout               = ffc("config.policyloop.drainTimeout", "Maximum time to wait on shutdown for the policy engine to finish the action it is performing on in-flight transactions, before it is cancelled", i18n.TimeDurationType)
+	ConfigLoopWorkers                    = ffc("config.policyloop.workers", "Number of workers executing the policy engine against in-flight transactions in each cycle of the policy loop. Transactions for different signers are processed concurrently, while the transactions for each signer are always processed in order by a single worker", i18n.IntType)
+	ConfigLoopHealthCheck                = ffc("config.policyloop.healthCheckInterval", "Interval at which to check the blockchain connector is reachable. Submissions are paused while it is not, and resume when it recovers. Set to 0 to disable", i18n.TimeDurationType)
+	ConfigLoopBackoffInitDelay           = ffc("config.policyloop.backoff.initialDelay", "Initial delay before the policy engine is invoked again for a transaction, after it returns an error", i18n.TimeDurationType)
+	ConfigLoopBackoffMaxDelay            = ffc("config.policyloop.backoff.maxDelay", "Maximum delay before the policy engine is invoked again for a transaction that continues to return errors", i18n.TimeDurationType)
+	ConfigLoopBackoffFactor              = ffc("config.policyloop.backoff.factor", "Factor to increase the delay by, for each consecutive error returned by the policy engine for a transaction. Random jitter is applied to each delay", i18n.FloatType)
+	ConfigLoopBackoffMaxAttempts         = ffc("config.policyloop.backoff.maxAttempts", "Maximum number of consecutive errors returned by the policy engine for a transaction, before the transaction is marked as failed. Errors while the connector is unhealthy are not counted. Set to 0 for no limit", i18n.IntType)
+	ConfigLoopBackoffMaxElapsed          = ffc("config.policyloop.backoff.maxElapsed", "Maximum time the policy engine can continue to return errors for a transaction, before the transaction is marked as failed. Set to 0 for no limit", i18n.TimeDurationType)
+	ConfigLoopBackoffSubmitMaxAttempts   = ffc("config.policyloop.backoff.submit.maxAttempts", "Overrides policyloop.backoff.maxAttempts for transactions that have not yet been submitted to the blockchain", i18n.IntType)
+	ConfigLoopBackoffSubmitMaxElapsed    = ffc("config.policyloop.backoff.submit.maxElapsed", "Overrides policyloop.backoff.maxElapsed for transactions that have not yet been submitted to the blockchain", i18n.TimeDurationType)
+	ConfigLoopBackoffResubmitMaxAttempts = ffc("config.policyloop.backoff.resubmit.maxAttempts", "Overrides policyloop.backoff.maxAttempts for transactions that have already been submitted to the blockchain", i18n.IntType)
+	ConfigLoopBackoffResubmitMaxElapsed  = ffc("config.policyloop.backoff.resubmit.maxElapsed", "Overrides policyloop.backoff.maxElapsed for transactions that have already been submitted to the blockchain", i18n.TimeDurationType)
+	ConfigLoopRevertRetryMaxAttempts     = ffc("config.policyloop.revertRetry.maxAttempts", "Number of times to retry the submission of a transaction the connector reports as reverted, before marking it as failed. Allows for reverts caused by transient state, such as a transaction it relies on not yet being mined on the node that was queried. When set, a transaction with invalid inputs is failed immediately, as retrying cannot succeed. Set to 0 to disable, and apply policyloop.backoff to reverts like any other error", i18n.IntType)
+	ConfigLoopRevertRetryDelay           = ffc("config.policyloop.revertRetry.delay", "Delay before retrying the submission of a reverted transaction", i18n.TimeDurationType)
 
-	ConfigLoopInterval = ffc("config.policyloop.interval", "Interval at which to invoke the policy engine to evaluate outstanding transactions", i18n.TimeDurationType)
+	ConfigLeaderElectionEnabled  = ffc("config.leaderelection.enabled", "Elect a single leader between replicas sharing the same persistence, using a lease in the store. Only the leader runs the policy loop, block listener and confirmation manager, and the other replicas serve read-only API requests", i18n.BooleanType)
+	ConfigLeaderElectionLeaseTTL = ffc("config.leaderelection.leaseTTL", "How long the leader lease is valid for without being renewed. The leader renews it at a third of this interval, and another replica takes over once it expires", i18n.TimeDurationType)
 
-	ConfigPolicyEngineSimpleFixedGasPrice          = ffc("config.policyengine.simple.fixedGasPrice", "A fixed gasPrice value/structure to pass to the connector", "Raw JSON")
-	ConfigPolicyEngineSimpleResubmitInterval       = ffc("config.policyengine.simple.resubmitInterval", "The time between warning and re-sending a transaction (same nonce) when a blockchain transaction has not been allocated a receipt", i18n.TimeDurationType)
-	ConfigPolicyEngineSimpleGasOracleEnabled       = ffc("config.policyengine.simple.gasOracle.mode", "The gas oracle mode", "connector | restapi | disabled")
-	ConfigPolicyEngineSimpleGasOracleGoTemplate    = ffc("config.policyengine.simple.gasOracle.template", "REST API Gas Oracle: A go template to execute against the result from the Gas Oracle, to create a JSON block that will be passed as the gas price to the connector", i18n.GoTemplateType)
-	ConfigPolicyEngineSimpleGasOracleURL           = ffc("config.policyengine.simple.gasOracle.url", "REST API Gas Oracle: The URL of a Gas Oracle REST API to call", i18n.StringType)
-	ConfigPolicyEngineSimpleGasOracleProxyURL      = ffc("config.policyengine.simple.gasOracle.proxy.url", "Optional HTTP proxy URL to use for the Gas Oracle REST API", i18n.StringType)
-	ConfigPolicyEngineSimpleGasOracleMethod        = ffc("config.policyengine.simple.gasOracle.method", "The HTTP Method to use when invoking the Gas Oracle REST API", i18n.StringType)
-	ConfigPolicyEngineSimpleGasOracleQueryInterval = ffc("config.policyengine.simple.gasOracle.queryInterval", "The minimum interval between queries to the Gas Oracle", i18n.TimeDurationType)
+	ConfigPolicyEngineSimpleFixedGasPrice           = ffc("config.policyengine.simple.fixedGasPrice", "A fixed gasPrice value/structure to pass to the connector", "Raw JSON")
+	ConfigPolicyEngineSimpleResubmitInterval        = ffc("config.policyengine.simple.resubmitInterval", "The time between warning and re-sending a transaction (same nonce) when a blockchain transaction has not been allocated a receipt", i18n.TimeDurationType)
+	ConfigPolicyEngineSimpleGasOracleEnabled        = ffc("config.policyengine.simple.gasOracle.mode", "The name of the registered gas oracle to use, or disabled to use the fixedGasPrice of the policy engine", "connector | restapi | fixed | disabled")
+	ConfigPolicyEngineSimpleGasOracleFixedGasPrice  = ffc("config.policyengine.simple.gasOracle.fixedGasPrice", "Fixed Gas Oracle: The gasPrice value/structure to pass to the connector", "Raw JSON")
+	ConfigPolicyEngineSimpleGasOracleGoTemplate     = ffc("config.policyengine.simple.gasOracle.template", "REST API Gas Oracle: A go template to execute against the result from the Gas Oracle, to create a JSON block that will be passed as the gas price to the connector", i18n.GoTemplateType)
+	ConfigPolicyEngineSimpleGasOracleURL            = ffc("config.policyengine.simple.gasOracle.url", "REST API Gas Oracle: The URL of a Gas Oracle REST API to call", i18n.StringType)
+	ConfigPolicyEngineSimpleGasOracleProxyURL       = ffc("config.policyengine.simple.gasOracle.proxy.url", "Optional HTTP proxy URL to use for the Gas Oracle REST API", i18n.StringType)
+	ConfigPolicyEngineSimpleGasOracleMethod         = ffc("config.policyengine.simple.gasOracle.method", "The HTTP Method to use when invoking the Gas Oracle REST API", i18n.StringType)
+	ConfigPolicyEngineSimpleGasOracleQueryInterval  = ffc("config.policyengine.simple.gasOracle.queryInterval", "The minimum interval between queries to the Gas Oracle", i18n.TimeDurationType)
+	ConfigPolicyEngineSimpleMaxGasPrice             = ffc("config.policyengine.simple.maxGasPrice", "The maximum gas price that will be submitted, when the gas price is a single value or contains a gasPrice field. Gas prices from the oracle above this value are reduced to the cap", i18n.StringType)
+	ConfigPolicyEngineSimpleMaxFeePerGas            = ffc("config.policyengine.simple.maxFeePerGas", "The maximum value that will be submitted for the maxFeePerGas field of an EIP-1559 gas price", i18n.StringType)
+	ConfigPolicyEngineSimpleMinGasPrice             = ffc("config.policyengine.simple.minGasPrice", "The minimum gas price that will be submitted, when the gas price is a single value or contains a gasPrice field. Gas prices from the oracle below this value are raised to the floor", i18n.StringType)
+	ConfigPolicyEngineSimpleFeeType                 = ffc("config.policyengine.simple.feeType", "Whether to send the legacy gasPrice field, or the EIP-1559 maxFeePerGas and maxPriorityFeePerGas fields, when the gas price from the oracle has EIP-1559 fields. 'auto' uses EIP-1559 if the connector reports the capability, falling back to legacy. A gas price with only legacy fields is always sent as-is. Not set by default, in which case the fields from the oracle are passed to the connector unchanged", "auto | legacy | eip1559")
+	ConfigPolicyEngineSimpleSimulate                = ffc("config.policyengine.simple.simulate", "Whether to simulate each transaction against the current state of the chain before its first submission, such as with eth_call. A transaction that reverts in simulation is failed without being submitted, with the revert reason. Can be overridden for individual transactions with the simulate request header. Skipped if the connector does not report the simulation capability", i18n.BooleanType)
+	ConfigPolicyEngineSimpleMinReplacementBump      = ffc("config.policyengine.simple.minReplacementBump", "The minimum percentage each field of the gas price must increase by over the previous submission, when a transaction is resubmitted with a different gas price. Nodes reject underpriced replacements, so smaller increases are raised to this minimum, and lower prices are ignored. Set to 0 to disable", i18n.IntType)
+	ConfigPolicyEngineSimpleEscalationInterval      = ffc("config.policyengine.simple.escalation.interval", "Bump the gas price of a transaction each time it has been pending this long without a receipt, regardless of the gas oracle. The higher of the bumped and oracle prices is used, subject to the caps. Set to 0 to disable", i18n.TimeDurationType)
+	ConfigPolicyEngineSimpleEscalationMaxBumps      = ffc("config.policyengine.simple.escalation.maxBumps", "The maximum number of times the gas price of a transaction is bumped on resubmission by escalation. Once reached, the terminal action is taken. Set to 0 for no limit", i18n.IntType)
+	ConfigPolicyEngineSimpleTerminalAction          = ffc("config.policyengine.simple.escalation.terminalAction", "What to do with a transaction that has reached the maximum number of gas price bumps without being mined. 'hold' continues to resubmit it at the last gas price, and 'cancel' replaces it with a no-op transaction at the same nonce", "hold | cancel")
+	ConfigPolicyEngineSimpleEscalationPercentage    = ffc("config.policyengine.simple.escalation.percentage", "The percentage to increase each field of the gas price by, on each escalation", i18n.IntType)
+	ConfigPolicyEngineSimpleFiatGasCapMaxCost       = ffc("config.policyengine.simple.fiatGasCap.maxCost", "The maximum fiat cost of the gas for a transaction, such as 5.00 for a budget in USD. Each time the gas price is calculated, it is converted into a gas price ceiling using the exchange rate of the native currency and the gas of the transaction. Gas prices above the ceiling are reduced to it, in the same way as the other caps. Not set by default, which disables the fiat gas cap", i18n.StringType)
+	ConfigPolicyEngineSimpleFiatGasCapRateProvider  = ffc("config.policyengine.simple.fiatGasCap.rateProvider", "The name of the registered exchange-rate provider that supplies the fiat price of the native currency", "fixed | restapi")
+	ConfigPolicyEngineSimpleFiatGasCapDecimals      = ffc("config.policyengine.simple.fiatGasCap.decimals", "The number of decimals of the native currency, as gas prices are denominated in its smallest unit", i18n.IntType)
+	ConfigPolicyEngineSimpleFiatGasCapQueryInterval = ffc("config.policyengine.simple.fiatGasCap.queryInterval", "The minimum interval between queries to the exchange-rate provider", i18n.TimeDurationType)
+	ConfigPolicyEngineSimpleFiatGasCapRate          = ffc("config.policyengine.simple.fiatGasCap.rate", "Fixed exchange-rate provider: The fiat price of one whole unit of the native currency", i18n.StringType)
+	ConfigPolicyEngineSimpleFiatGasCapMethod        = ffc("config.policyengine.simple.fiatGasCap.method", "The HTTP Method to use when invoking the exchange-rate REST API", i18n.StringType)
+	ConfigPolicyEngineSimpleFiatGasCapTemplate      = ffc("config.policyengine.simple.fiatGasCap.template", "REST API exchange-rate provider: A go template to execute against the result from the REST API, to extract the fiat price of one whole unit of the native currency", i18n.GoTemplateType)
+	ConfigPolicyEngineSimpleFiatGasCapURL           = ffc("config.policyengine.simple.fiatGasCap.url", "REST API exchange-rate provider: The URL of a price REST API to call", i18n.StringType)
+	ConfigPolicyEngineSimpleFiatGasCapProxyURL      = ffc("config.policyengine.simple.fiatGasCap.proxy.url", "Optional HTTP proxy URL to use for the exchange-rate REST API", i18n.StringType)
+	ConfigPolicyEngineSimpleMaxPriorityFeePerGas    = ffc("config.policyengine.simple.maxPriorityFeePerGas", "The maximum value that will be submitted for the maxPriorityFeePerGas field of an EIP-1559 gas price", i18n.StringType)
 
 	ConfigEventStreamsDefaultsBatchSize                 = ffc("config.eventstreams.defaults.batchSize", "Default batch size for newly created event streams", i18n.IntType)
 	ConfigEventStreamsDefaultsBatchTimeout              = ffc("config.eventstreams.defaults.batchTimeout", "Default batch timeout for newly created event streams", i18n.TimeDurationType)
@@ -66,15 +184,28 @@ var (
 	ConfigEventStreamsDefaultsBlockedRetryDelay         = ffc("config.eventstreams.defaults.blockedRetryDelay", "Default blocked retry delay for newly created event streams", i18n.TimeDurationType)
 	ConfigEventStreamsDefaultsWebhookRequestTimeout     = ffc("config.eventstreams.defaults.webhookRequestTimeout", "Default WebHook request timeout for newly created event streams", i18n.TimeDurationType)
 	ConfigEventStreamsDefaultsWebsocketDistributionMode = ffc("config.eventstreams.defaults.websocketDistributionMode", "Default WebSocket distribution mode for newly created event streams", "'load_balance' or 'broadcast'")
+	ConfigEventStreamsDefaultsWebsocketMaxRedeliveries  = ffc("config.eventstreams.defaults.websocketMaxRedeliveries", "Default maximum number of times a batch is redelivered over a WebSocket, after a nack or a disconnect, before it is moved to the dead-letter store and skipped. 0 for unlimited", i18n.IntType)
+	ConfigEventStreamsDefaultsWebsocketNackDelay        = ffc("config.eventstreams.defaults.websocketNackRedeliveryDelay", "Default delay before redelivering a batch that a WebSocket client has rejected with a nack", i18n.TimeDurationType)
 	ConfigEventStreamsCheckpointInterval                = ffc("config.eventstreams.checkpointInterval", "Regular interval to write checkpoints for an event stream listener that is not actively detecting/delivering events", i18n.TimeDurationType)
+	ConfigEventStreamsDeadLetterMaxEntries              = ffc("config.eventstreams.deadLetter.maxEntries", "Maximum number of undeliverable event batches kept for each event stream, for inspection and replay. The oldest are removed first. 0 to disable the dead-letter store", i18n.IntType)
+	ConfigEventStreamsDeadLetterRetention               = ffc("config.eventstreams.deadLetter.retention", "Duration after which undeliverable event batches are removed from the dead-letter store of an event stream. 0 to keep them until the maximum number of entries is reached", i18n.TimeDurationType)
+	ConfigEventStreamsSSEHeartbeatInterval              = ffc("config.eventstreams.sse.heartbeatInterval", "Interval at which a heartbeat comment is sent to Server-Sent Events clients, to keep the connection open through proxies while no events are being delivered", i18n.TimeDurationType)
+	ConfigEventStreamsWebSocketSendBufferSize           = ffc("config.eventstreams.websocket.sendBufferSize", "Maximum number of broadcast messages and replies buffered for each WebSocket connection, before the backpressure policy is applied to it", i18n.IntType)
+	ConfigEventStreamsWebSocketBackpressurePolicy       = ffc("config.eventstreams.websocket.backpressurePolicy", "What happens to a broadcast message or reply for a WebSocket connection whose send buffer is full. 'disconnect' closes the connection, 'dropOldest' discards the oldest buffered message, and 'block' waits up to the block timeout for space then closes the connection, holding up to the size of the send buffer of further messages for the connection while it waits. None of the policies delay delivery to other connections", "disconnect | dropOldest | block")
+	ConfigEventStreamsWebSocketBlockTimeout             = ffc("config.eventstreams.websocket.blockTimeout", "How long to wait for space in the full send buffer of a WebSocket connection with the 'block' backpressure policy, before closing the connection", i18n.TimeDurationType)
 	ConfigEventStreamsRetryInitDelay                    = ffc("config.eventstreams.retry.initialDelay", "Initial retry delay", i18n.TimeDurationType)
 	ConfigEventStreamsRetryMaxDelay                     = ffc("config.eventstreams.retry.maxDelay", "Maximum delay between retries", i18n.TimeDurationType)
 	ConfigEventStreamsRetryFactor                       = ffc("config.eventstreams.retry.factor", "Factor to increase the delay by, between each retry", i18n.FloatType)
 
-	ConfigPersistenceType              = ffc("config.persistence.type", "The type of persistence to use", "Only 'leveldb' currently supported")
-	ConfigPersistenceLevelDBPath       = ffc("config.persistence.leveldb.path", "The path for the LevelDB persistence directory", i18n.StringType)
-	ConfigPersistenceLevelDBMaxHandles = ffc("config.persistence.leveldb.maxHandles", "The maximum number of cached file handles LevelDB should keep open", i18n.IntType)
-	ConfigPersistenceLevelDBSyncWrites = ffc("config.persistence.leveldb.syncWrites", "Whether to synchronously perform writes to the storage", i18n.BooleanType)
+	ConfigPersistenceType                           = ffc("config.persistence.type", "The type of persistence to use", "leveldb | sqlite")
+	ConfigPersistenceLevelDBPath                    = ffc("config.persistence.leveldb.path", "The path for the LevelDB persistence directory", i18n.StringType)
+	ConfigPersistenceLevelDBMaxHandles              = ffc("config.persistence.leveldb.maxHandles", "The maximum number of cached file handles LevelDB should keep open", i18n.IntType)
+	ConfigPersistenceLevelDBSyncWrites              = ffc("config.persistence.leveldb.syncWrites", "Whether to synchronously perform writes to the storage", i18n.BooleanType)
+	ConfigPersistenceLevelDBWriteBatchSize          = ffc("config.persistence.leveldb.writeBatch.size", "The number of updates to pending transactions to buffer, before they are written to LevelDB in a single batch. Repeated updates to the same transaction are coalesced. Updates to the buffered transactions that were not written could be lost on a crash, but transactions that have succeeded or failed are always written before the update is acknowledged. Set to 0 to disable", i18n.IntType)
+	ConfigPersistenceLevelDBWriteBatchFlushInterval = ffc("config.persistence.leveldb.writeBatch.flushInterval", "The maximum time an update to a pending transaction is buffered, before it is written to LevelDB", i18n.TimeDurationType)
+	ConfigPersistenceEncryptionKeyFile              = ffc("config.persistence.encryption.keyFile", "Path to a file containing a 32 byte AES-256 key, hex encoded, to encrypt the sensitive fields of transactions in persistence with. Records written without encryption remain readable, and are encrypted when next updated", i18n.StringType)
+	ConfigPersistenceEncryptionKeyEnv               = ffc("config.persistence.encryption.keyEnv", "Name of an environment variable containing a 32 byte AES-256 key, hex encoded, as an alternative to persistence.encryption.keyFile", i18n.StringType)
+	ConfigPersistenceSQLitePath                     = ffc("config.persistence.sqlite.path", "The path for the SQLite database file, or ':memory:' for a non-persistent in-memory database", i18n.StringType)
 
 	ConfigWebhooksAllowPrivateIPs = ffc("config.webhooks.allowPrivateIPs", "Whether to allow WebHook URLs that resolve to Private IP address ranges (vs. internet addresses)", i18n.BooleanType)
 	ConfigWebhooksURL             = ffc("config.webhooks.url", "Unused (overridden by the WebHook configuration of an individual event stream)", i18n.IgnoredType)
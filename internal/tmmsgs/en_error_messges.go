@@ -85,4 +85,136 @@ var (
 	MsgTransactionNotFound           = ffe("FF21067", "Transaction '%s' not found", http.StatusNotFound)
 	MsgPolicyEngineRequestTimeout    = ffe("FF21068", "The policy engine did not acknowledge the request after %.2fs", 408)
 	MsgPolicyEngineRequestInvalid    = ffe("FF21069", "Invalid policy engine request type '%d'")
+	MsgNonceAllocatorNotRegistered   = ffe("FF21070", "No nonce allocator registered with name '%s'")
+	MsgInvalidGasPriceCap            = ffe("FF21071", "Invalid value '%s' for gas price cap '%s'")
+	MsgGasPriceCapReached            = ffe("FF21072", "Gas price field '%s' value %s exceeded the configured cap, and was limited to %s")
+	MsgInvalidWaitTimeout            = ffe("FF21073", "Invalid timeout '%s'", http.StatusBadRequest)
+	MsgSQLitePathMissing             = ffe("FF21074", "Path must be supplied for SQLite persistence")
+	MsgConfirmationsExceedMax        = ffe("FF21075", "Confirmations %d exceeds the configured maximum of %d", http.StatusBadRequest)
+	MsgTXConflictStatusPending       = ffe("FF21076", "Only one of 'status' and 'pending' can be supplied when querying transactions", http.StatusBadRequest)
+	MsgInvalidTXStatus               = ffe("FF21077", "Invalid transaction status '%s'", http.StatusBadRequest)
+	MsgPolicyEngineNotConfigured     = ffe("FF21078", "Policy engine '%s' is not configured", http.StatusBadRequest)
+	MsgShuttingDown                  = ffe("FF21079", "The transaction manager is shutting down, and is not accepting new transactions", http.StatusServiceUnavailable)
+	MsgInvalidCompletionCallback     = ffe("FF21080", "Invalid completion callback URL '%s'", http.StatusBadRequest)
+	MsgInvalidTimeParam              = ffe("FF21081", "Invalid time '%s' for '%s'", http.StatusBadRequest)
+	MsgInvalidTimeRange              = ffe("FF21082", "'fromTime' must not be after 'toTime'", http.StatusBadRequest)
+	MsgTXConflictTimeRange           = ffe("FF21083", "'fromTime' and 'toTime' cannot be combined with 'signer', 'pending' or 'status' when querying transactions", http.StatusBadRequest)
+	MsgInvalidSubmissionTimeout      = ffe("FF21084", "Invalid submission timeout '%s'", http.StatusBadRequest)
+	MsgSubmissionTimeout             = ffe("FF21085", "Transaction was not mined within the submission timeout of %s")
+	MsgInvalidCheckpoint             = ffe("FF21086", "Invalid checkpoint for listener '%s': %s", http.StatusBadRequest)
+	MsgCheckpointAheadOfHead         = ffe("FF21087", "Checkpoint for listener '%s' is ahead of the current chain head", http.StatusBadRequest)
+	MsgCheckpointRewindNotConfirmed  = ffe("FF21088", "Checkpoint for listener '%s' is behind the current checkpoint, and would redeliver events. Set 'confirmRewind' to proceed", http.StatusConflict)
+	MsgSignerNotAllowed              = ffe("FF21089", "Signing address '%s' is not permitted to submit transactions", http.StatusForbidden)
+	MsgIdempotencyKeyInFlight        = ffe("FF21090", "A transaction with idempotency key '%s' is already being submitted for signing address '%s'", http.StatusConflict)
+	MsgWSNackFromClient              = ffe("FF21091", "Batch rejected by WebSocket client: %s")
+	MsgDependencyNotFound            = ffe("FF21092", "Transaction '%s' that this transaction depends on was not found", http.StatusBadRequest)
+	MsgDependencyCycle               = ffe("FF21093", "Transaction '%s' cannot depend on '%s', as this would create a dependency cycle", http.StatusBadRequest)
+	MsgDependencyFailed              = ffe("FF21094", "Transaction '%s' that this transaction depends on did not succeed (status=%s)")
+	MsgInvalidEscalationPercentage   = ffe("FF21095", "Gas price escalation percentage must be greater than zero: %d")
+	MsgReceiptBatchMismatch          = ffe("FF21096", "Batch receipt query returned %d results for %d transactions")
+	MsgTXConflictRequestID           = ffe("FF21097", "'requestId' cannot be combined with 'signer', 'pending', 'status', 'fromTime' or 'toTime' when querying transactions", http.StatusBadRequest)
+	MsgNotLeader                     = ffe("FF21098", "This replica is not the leader, and only serves read-only requests", http.StatusServiceUnavailable)
+	MsgInvalidGasPriceFloor          = ffe("FF21099", "Invalid value '%s' for minimum gas price '%s'")
+	MsgInvalidReplacementBump        = ffe("FF21100", "Minimum replacement gas price bump percentage cannot be negative: %d")
+	MsgSSEInterrupted                = ffe("FF21101", "Interrupted waiting for a Server-Sent Events client to receive and acknowledge the batch")
+	MsgStreamNotSSE                  = ffe("FF21102", "Event stream '%s' does not deliver events using Server-Sent Events", http.StatusBadRequest)
+	MsgSSEBatchNotAwaitingAck        = ffe("FF21103", "Batch %d is not awaiting acknowledgement on event stream '%s'", http.StatusConflict)
+	MsgSSEClientDisconnected         = ffe("FF21104", "Server-Sent Events client disconnected before acknowledging batch %d")
+	MsgInvalidSSEBatchNumber         = ffe("FF21105", "Invalid batch number '%s'", http.StatusBadRequest)
+	MsgPolicyRetriesExhausted        = ffe("FF21106", "Transaction failed after %d consecutive policy engine errors over %s: %s")
+	MsgAPIAuthNotRegistered          = ffe("FF21107", "No API auth plugin registered with name '%s'")
+	MsgInvalidEventABI               = ffe("FF21108", "Invalid event ABI entry '%s': %s", http.StatusBadRequest)
+	MsgEventABINoMatch               = ffe("FF21109", "No event in the event ABI of the stream matches the log with topics %v")
+	MsgEventABIDecodeFailed          = ffe("FF21110", "Failed to decode log as event '%s': %s")
+	MsgInvalidAccessList             = ffe("FF21111", "Invalid access list entry %d: %s", http.StatusBadRequest)
+	MsgSubmissionRateLimited         = ffe("FF21112", "Transaction submission rate limit exceeded for signer '%s'", http.StatusTooManyRequests)
+	MsgInvalidGasLimitMultiplier     = ffe("FF21113", "Invalid gas limit multiplier %f - must be greater than zero", http.StatusBadRequest)
+	MsgTransactionNotSubmitted       = ffe("FF21114", "Transaction '%s' has not been submitted to the blockchain", http.StatusConflict)
+	MsgPolicyEngineConfigInvalid     = ffe("FF21115", "Invalid configuration for policy engine '%s' - unknown keys: %v, missing required keys: %v, invalid values: %v")
+	MsgRawTransactionsNotSupported   = ffe("FF21116", "The blockchain connector does not support submitting raw transactions", http.StatusNotImplemented)
+	MsgMissingRawTransactionField    = ffe("FF21117", "Missing '%s' for raw transaction", http.StatusBadRequest)
+	MsgRawTransactionNonceConflict   = ffe("FF21118", "Nonce %s for signer '%s' is already used by transaction '%s'", http.StatusConflict)
+	MsgTransactionComplete           = ffe("FF21119", "Transaction '%s' is already complete with status %s", http.StatusConflict)
+	MsgTransactionCancelled          = ffe("FF21120", "Transaction was cancelled by no-op transaction %s being mined at its nonce")
+	MsgInvalidBatchSize              = ffe("FF21121", "Invalid batch size %d - must be at least 1", http.StatusBadRequest)
+	MsgEventReplayNotSupported       = ffe("FF21122", "The blockchain connector does not support replaying events", http.StatusNotImplemented)
+	MsgInvalidReplayRange            = ffe("FF21123", "Invalid replay range - fromBlock and toBlock are required, and fromBlock cannot be after toBlock", http.StatusBadRequest)
+	MsgReplayAheadOfHead             = ffe("FF21124", "Replay toBlock %d is ahead of the highest confirmed block %d on the chain", http.StatusBadRequest)
+	MsgConnectorUnavailable          = ffe("FF21125", "The blockchain connector is unavailable after %d consecutive failed calls", http.StatusServiceUnavailable)
+	MsgConnectorAPINotImplemented    = ffe("FF21126", "The blockchain connector does not implement %s", http.StatusNotImplemented)
+	MsgBlockListenerNotConfigured    = ffe("FF21127", "Block listener '%s' is not configured", http.StatusBadRequest)
+	MsgDuplicateBlockListenerName    = ffe("FF21128", "Block listener name '%s' is configured more than once")
+	MsgRevertRetriesExhausted        = ffe("FF21129", "Transaction reverted on %d consecutive submission attempts: %s")
+	MsgDeterministicRevert           = ffe("FF21130", "Transaction failed with invalid inputs, which cannot succeed on retry: %s")
+	MsgInvalidExpiry                 = ffe("FF21131", "Invalid expiry '%s' - must be after notBefore '%s'", http.StatusBadRequest)
+	MsgTransactionExpired            = ffe("FF21132", "Transaction was not mined before its expiry at %s")
+	MsgClientRequestFailed           = ffe("FF21133", "FFTM request %s %s failed: %s")
+	MsgClientRequestError            = ffe("FF21134", "FFTM request %s %s failed with status %d: %s")
+	MsgTypedDataNotSupported         = ffe("FF21135", "The blockchain connector does not support signing EIP-712 typed data", http.StatusNotImplemented)
+	MsgInvalidTypedData              = ffe("FF21136", "Invalid EIP-712 typed data: %s", http.StatusBadRequest)
+	MsgReservedStreamName            = ffe("FF21137", "Event stream name '%s' is reserved", http.StatusBadRequest)
+	MsgInvalidFeeType                = ffe("FF21138", "Invalid fee type '%s' - must be 'auto', 'legacy' or 'eip1559'")
+	MsgTooManyPendingTransactions    = ffe("FF21139", "Signer '%s' has reached the maximum of %d pending transactions", http.StatusTooManyRequests)
+	MsgInvalidErrorReasonPattern     = ffe("FF21140", "Invalid pattern '%s' in error reason mapping %d: %s")
+	MsgInvalidErrorReason            = ffe("FF21141", "Invalid reason '%s' in error reason mapping %d")
+	MsgUnsupportedExportVersion      = ffe("FF21142", "Unsupported event stream export version %d", http.StatusBadRequest)
+	MsgImportStreamMissingName       = ffe("FF21143", "Stream %d in the import document is missing a name", http.StatusBadRequest)
+	MsgImportStreamDuplicateName     = ffe("FF21144", "Stream name '%s' appears more than once in the import document", http.StatusBadRequest)
+	MsgInvalidImportConflict         = ffe("FF21145", "Invalid onConflict '%s' - must be 'skip' or 'overwrite'", http.StatusBadRequest)
+	MsgInvalidMethodABI              = ffe("FF21146", "Invalid method ABI '%s': %s", http.StatusBadRequest)
+	MsgMethodInputDecodeFailed       = ffe("FF21147", "Failed to decode the input of method '%s': %s")
+	MsgTransactionNotDeleted         = ffe("FF21148", "Transaction '%s' has not been deleted", http.StatusConflict)
+	MsgGasOracleNotRegistered        = ffe("FF21149", "No gas oracle registered with name '%s'")
+	MsgInvalidConnectorAuditLevel    = ffe("FF21150", "Invalid connector audit log level '%s'")
+	MsgTransactionDataTooLarge       = ffe("FF21151", "Transaction data of %d bytes exceeds the maximum of %d bytes", http.StatusRequestEntityTooLarge)
+	MsgBatchTooLarge                 = ffe("FF21152", "Request contains %d items, which exceeds the maximum of %d", http.StatusRequestEntityTooLarge)
+	MsgUnknownConfirmationStrategy   = ffe("FF21153", "Unknown confirmation strategy '%s'. Must be one of: fast, safe, finalized", http.StatusBadRequest)
+	MsgTracingInitFailed             = ffe("FF21154", "Failed to initialize the OpenTelemetry span exporter: %s")
+	MsgInvalidAdaptiveLoopInterval   = ffe("FF21155", "Invalid adaptive policy loop interval bounds. The minimum interval '%s' must be greater than zero, and no greater than the maximum interval '%s'")
+	MsgDeployWithToAddress           = ffe("FF21156", "A contract deployment cannot have a 'to' address", http.StatusBadRequest)
+	MsgEncryptionKeySourceConflict   = ffe("FF21157", "Only one of persistence.encryption.keyFile and persistence.encryption.keyEnv can be configured")
+	MsgEncryptionKeyInvalid          = ffe("FF21158", "The persistence encryption key from %s must be 32 bytes, hex encoded")
+	MsgEncryptionKeyFileRead         = ffe("FF21159", "Failed to read the persistence encryption key file '%s'")
+	MsgFieldDecryptFailed            = ffe("FF21160", "Failed to decrypt field '%s' of transaction '%s'")
+	MsgTooManyLabels                 = ffe("FF21161", "Transaction has %d labels, exceeding the maximum of %d", http.StatusBadRequest)
+	MsgInvalidLabelKey               = ffe("FF21162", "Invalid label key '%s' - label keys must not be empty, or contain '='", http.StatusBadRequest)
+	MsgLabelTooLong                  = ffe("FF21163", "Label '%s' exceeds the maximum length of %d characters for a label key or value", http.StatusBadRequest)
+	MsgInvalidLabelFilter            = ffe("FF21164", "Invalid label filter '%s' - must be in the format 'key=value'", http.StatusBadRequest)
+	MsgTXConflictLabel               = ffe("FF21165", "'label' cannot be combined with 'signer', 'pending', 'status', 'fromTime', 'toTime' or 'requestId' when querying transactions", http.StatusBadRequest)
+	MsgNonceReservationNotFound      = ffe("FF21166", "Nonce reservation '%s' not found, or has expired", http.StatusNotFound)
+	MsgNonceReservationMismatch      = ffe("FF21167", "Nonce reservation '%s' is for nonce %s of signer '%s'", http.StatusBadRequest)
+	MsgInvalidNonceReservationTTL    = ffe("FF21168", "Invalid nonce reservation ttl '%s' - must be greater than zero", http.StatusBadRequest)
+	MsgNonceReservationInUse         = ffe("FF21169", "Nonce reservation '%s' is already being used by another submission", http.StatusConflict)
+	MsgUnknownFailoverStrategy       = ffe("FF21170", "Unknown connector failover strategy '%s'. Must be one of: priority, roundRobin")
+	MsgNoFailoverEndpoints           = ffe("FF21171", "No endpoints are configured in connectorfailover.endpoints")
+	MsgFailoverEndpointInitFailed    = ffe("FF21172", "Failed to create the connector for failover endpoint %d")
+	MsgInvalidPayloadEncoding        = ffe("FF21173", "Invalid event stream payload encoding '%s'. Must be one of: json, msgpack", http.StatusBadRequest)
+	MsgPayloadEncodingNotSupported   = ffe("FF21174", "Payload encoding '%s' is not supported for event streams of type '%s'", http.StatusBadRequest)
+	MsgExchangeRateNotRegistered     = ffe("FF21175", "No exchange-rate provider registered with name '%s'")
+	MsgInvalidExchangeRate           = ffe("FF21176", "Invalid exchange rate '%s'. Must be a positive decimal number")
+	MsgErrorQueryingExchangeRateAPI  = ffe("FF21177", "Error from exchange-rate API [%d]: %s")
+	MsgMissingExchangeRateTemplate   = ffe("FF21178", "Missing template for processing response from exchange-rate REST API")
+	MsgExchangeRateResultError       = ffe("FF21179", "Error processing result from exchange-rate API via template")
+	MsgInvalidFiatGasCap             = ffe("FF21180", "Invalid value '%s' for fiat gas cap '%s'")
+	MsgFiatGasCapReached             = ffe("FF21181", "Gas price field '%s' value %s exceeded the cap of %s derived from the fiat limit of %s at an exchange rate of %s, and was limited to the cap")
+	MsgFiatGasCapNoGas               = ffe("FF21182", "Cannot apply the fiat gas cap to transaction '%s' as the gas is not known")
+	MsgInvalidBackpressureMode       = ffe("FF21183", "Invalid backpressure mode '%s'. Must be one of: queue, reject")
+	MsgBackpressureSignerMissing     = ffe("FF21184", "Missing signer for entry %d in signerbackpressure")
+	MsgBacklogThresholdReached       = ffe("FF21185", "The backlog of %d in-flight and pending transactions has reached the threshold of %d", http.StatusTooManyRequests)
+	MsgSignerBacklogThresholdReached = ffe("FF21186", "Signer '%s' has a backlog of %d in-flight and pending transactions, which has reached the threshold of %d", http.StatusTooManyRequests)
+	MsgSimulationReverted            = ffe("FF21187", "Simulation of the transaction reverted, so it was not submitted: %s")
+	MsgInvalidMaxGasBumps            = ffe("FF21188", "Maximum number of gas price bumps cannot be negative: %d")
+	MsgInvalidTerminalAction         = ffe("FF21189", "Invalid terminal action '%s' - must be 'hold' or 'cancel'")
+	MsgConnectorNotConfigured        = ffe("FF21190", "Connector '%s' is not configured", http.StatusBadRequest)
+	MsgDefaultConnectorMissing       = ffe("FF21191", "Default connector '%s' is not one of the supplied connectors")
+	MsgInvalidStatsWindow            = ffe("FF21192", "Invalid stats window '%s' - must be a positive duration", http.StatusBadRequest)
+	MsgKeyManagerNotRegistered       = ffe("FF21193", "No key manager registered with name '%s'")
+	MsgKeyNameAndFrom                = ffe("FF21194", "Only one of the key name and from address can be set on a submission", http.StatusBadRequest)
+	MsgKeyResolveFailed              = ffe("FF21195", "Failed to resolve key name '%s' to a signing address", http.StatusBadRequest)
+	MsgInvalidConfirmationWebhook    = ffe("FF21196", "Invalid confirmation webhook URL '%s'", http.StatusBadRequest)
+	MsgConfirmationWebhookNoSecret   = ffe("FF21197", "Confirmation webhooks cannot be registered, as no signing secret is configured", http.StatusBadRequest)
+	MsgInvalidConfirmationMilestones = ffe("FF21198", "Invalid confirmation milestones %v - each must be between 1 and %d confirmations", http.StatusBadRequest)
+	MsgInvalidMaxLimitAction         = ffe("FF21199", "Invalid maximum limit action '%s'. Must be one of: clamp, reject")
+	MsgLimitExceedsMax               = ffe("FF21200", "Limit %d must be between 1 and the maximum of %d", http.StatusBadRequest)
+	MsgInvalidWebSocketBackpressure  = ffe("FF21201", "Invalid WebSocket backpressure policy '%s'. Must be one of: disconnect, dropOldest, block")
 )
@@ -21,23 +21,28 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	ws "github.com/gorilla/websocket"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 )
 
 type webSocketConnection struct {
 	ctx       context.Context
 	id        string
+	identity  string // as returned by the authorizer when the connection was established
 	server    *webSocketServer
 	conn      *ws.Conn
 	mux       sync.Mutex
 	closed    bool
 	topics    map[string]*webSocketTopic
-	broadcast chan interface{}
+	inflight  map[string]bool  // topics with a batch sent to this connection, that has not been acknowledged
+	broadcast chan interface{} // bounded send buffer for broadcasts and replies, shared with other connections by the dispatcher
+	overflow  []interface{}    // messages held while waiting for space in the send buffer, with the block policy
 	newTopic  chan bool
 	receive   chan error
 	closing   chan struct{}
@@ -50,16 +55,21 @@ type webSocketCommandMessage struct {
 	Message string `json:"message,omitempty"`
 }
 
-func newConnection(bgCtx context.Context, server *webSocketServer, conn *ws.Conn) *webSocketConnection {
+// maxCloseReasonLen is the longest reason that fits in a close frame, after the two byte close code
+const maxCloseReasonLen = 123
+
+func newConnection(bgCtx context.Context, server *webSocketServer, conn *ws.Conn, identity string) *webSocketConnection {
 	id := fftypes.NewUUID().String()
 	wsc := &webSocketConnection{
 		ctx:       log.WithLogField(bgCtx, "wsc", id),
 		id:        id,
+		identity:  identity,
 		server:    server,
 		conn:      conn,
 		newTopic:  make(chan bool),
 		topics:    make(map[string]*webSocketTopic),
-		broadcast: make(chan interface{}),
+		inflight:  make(map[string]bool),
+		broadcast: make(chan interface{}, server.sendBufferSize),
 		receive:   make(chan error),
 		closing:   make(chan struct{}),
 	}
@@ -75,10 +85,14 @@ func (c *webSocketConnection) close() {
 		c.conn.Close()
 		close(c.closing)
 	}
+	topics := make([]*webSocketTopic, 0, len(c.topics))
+	for _, t := range c.topics {
+		topics = append(topics, t)
+	}
 	c.mux.Unlock()
 
-	for _, t := range c.topics {
-		c.server.cycleTopic(c.id, t)
+	for _, t := range topics {
+		c.server.cycleTopic(c.id, t, c.isInflight(t.topic))
 		log.L(c.ctx).Infof("Websocket closed while active on topic '%s'", t.topic)
 	}
 	c.server.connectionClosed(c)
@@ -87,13 +101,16 @@ func (c *webSocketConnection) close() {
 
 func (c *webSocketConnection) sender() {
 	defer c.close()
+	var topics []string
 	buildCases := func() []reflect.SelectCase {
 		c.mux.Lock()
 		defer c.mux.Unlock()
+		topics = make([]string, len(c.topics))
 		cases := make([]reflect.SelectCase, len(c.topics)+3)
 		i := 0
 		for _, t := range c.topics {
 			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.senderChannel)}
+			topics[i] = t.topic
 			i++
 		}
 		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.broadcast)}
@@ -115,10 +132,134 @@ func (c *webSocketConnection) sender() {
 			// Addition of a new topic
 			cases = buildCases()
 		} else {
+			if chosen < len(topics) {
+				// A batch sent to this connection alone, which we need an ack for
+				c.setInflight(topics[chosen], true)
+			}
 			// Message from one of the existing topics
-			_ = c.conn.WriteJSON(value.Interface())
+			_ = c.writeMessage(value.Interface())
+		}
+	}
+}
+
+// queueBroadcast queues a message in the send buffer without blocking, returning false if the buffer is full.
+// Messages for a connection that has closed are discarded.
+func (c *webSocketConnection) queueBroadcast(message interface{}) bool {
+	select {
+	case c.broadcast <- message:
+		return true
+	case <-c.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// queueBroadcastDropOldest discards messages from the front of the send buffer until there is space for the message
+func (c *webSocketConnection) queueBroadcastDropOldest(message interface{}) {
+	for !c.queueBroadcast(message) {
+		select {
+		case <-c.broadcast:
+			log.L(c.ctx).Warnf("Send buffer full - dropped oldest message")
+		default:
+			// The sender took a message from the buffer, so there should be space now
+		}
+	}
+}
+
+// queueBroadcastBlocking queues a message, waiting for space in the send buffer on a goroutine of the connection
+// rather than on the dispatcher. Messages dispatched while it waits are held in order behind it, up to the size of
+// the send buffer, beyond which the connection is closed.
+func (c *webSocketConnection) queueBroadcastBlocking(message interface{}, timeout time.Duration) {
+	c.mux.Lock()
+	if c.overflow == nil {
+		if !c.queueBroadcast(message) {
+			c.overflow = []interface{}{message}
+			go c.drainOverflow(timeout)
+		}
+		c.mux.Unlock()
+		return
+	}
+	full := len(c.overflow) >= cap(c.broadcast)
+	if !full {
+		c.overflow = append(c.overflow, message)
+	}
+	c.mux.Unlock()
+	if full {
+		c.disconnectSlowConsumer()
+	}
+}
+
+// drainOverflow moves the held messages into the send buffer as space becomes available, and closes the
+// connection if no space becomes available within the timeout
+func (c *webSocketConnection) drainOverflow(timeout time.Duration) {
+	for {
+		c.mux.Lock()
+		if len(c.overflow) == 0 {
+			c.overflow = nil
+			c.mux.Unlock()
+			return
+		}
+		message := c.overflow[0]
+		c.mux.Unlock()
+
+		timer := time.NewTimer(timeout)
+		select {
+		case c.broadcast <- message:
+			timer.Stop()
+		case <-c.closing:
+			timer.Stop()
+			return
+		case <-timer.C:
+			c.disconnectSlowConsumer()
+			return
 		}
+
+		c.mux.Lock()
+		c.overflow = c.overflow[1:]
+		c.mux.Unlock()
+	}
+}
+
+// disconnectSlowConsumer closes a connection that has fallen too far behind, so it cannot stall delivery to others
+func (c *webSocketConnection) disconnectSlowConsumer() {
+	log.L(c.ctx).Warnf("Disconnecting slow consumer, as its send buffer of %d messages is full", cap(c.broadcast))
+	c.close()
+}
+
+// writeMessage sends a message in the encoding negotiated by the connection if any, otherwise the encoding the
+// message was sent with. Messages encoded as msgpack are sent as binary frames, and JSON as text frames.
+func (c *webSocketConnection) writeMessage(msg interface{}) error {
+	encoding := apitypes.PayloadEncodingJSON
+	if em, ok := msg.(*EncodedMessage); ok {
+		encoding = em.Encoding
+		msg = em.Payload
+	}
+	if subprotocol := c.conn.Subprotocol(); subprotocol != "" {
+		encoding = apitypes.PayloadEncoding(subprotocol)
+	}
+	if encoding != apitypes.PayloadEncodingMsgpack {
+		return c.conn.WriteJSON(msg)
+	}
+	data, err := apitypes.MarshalPayload(encoding, msg)
+	if err != nil {
+		log.L(c.ctx).Errorf("Failed to encode message: %s", err)
+		return err
 	}
+	return c.conn.WriteMessage(ws.BinaryMessage, data)
+}
+
+// readCommand reads the next command from the client, which can be sent as JSON in a text frame, or msgpack in a binary frame
+func (c *webSocketConnection) readCommand(msg *webSocketCommandMessage) error {
+	msgType, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	encoding := apitypes.PayloadEncodingJSON
+	if msgType == ws.BinaryMessage {
+		encoding = apitypes.PayloadEncodingMsgpack
+	}
+	return apitypes.UnmarshalPayload(encoding, data, msg)
 }
 
 func (c *webSocketConnection) listenTopic(t *webSocketTopic) {
@@ -141,7 +282,7 @@ func (c *webSocketConnection) listen() {
 	log.L(c.ctx).Infof("Connected")
 	for {
 		var msg webSocketCommandMessage
-		err := c.conn.ReadJSON(&msg)
+		err := c.readCommand(&msg)
 		if err != nil {
 			log.L(c.ctx).Errorf("Error: %s", err)
 			return
@@ -155,11 +296,20 @@ func (c *webSocketConnection) listen() {
 		t := c.server.getTopic(topic)
 		switch strings.ToLower(msg.Type) {
 		case "listen":
+			if err := c.server.authorizer.AuthorizeListen(c.ctx, c.identity, topic); err != nil {
+				c.rejectListen(topic, err)
+				return
+			}
 			c.listenTopic(t)
 		case "listenreplies":
 			c.listenReplies()
 		case "ack":
 			c.handleAckOrError(t, nil)
+		case "nack":
+			c.handleAckOrError(t, &UnackedError{
+				Err:  i18n.NewError(c.ctx, tmmsgs.MsgWSNackFromClient, msg.Message),
+				Nack: true,
+			})
 		case "error":
 			c.handleAckOrError(t, i18n.NewError(c.ctx, tmmsgs.MsgWSErrorFromClient, msg.Message))
 		default:
@@ -168,7 +318,34 @@ func (c *webSocketConnection) listen() {
 	}
 }
 
+// rejectListen sends a policy violation close frame, with the reason the authorizer gave, before the connection is closed
+func (c *webSocketConnection) rejectListen(topic string, err error) {
+	log.L(c.ctx).Errorf("Not authorized to listen on topic '%s': %s", topic, err)
+	reason := err.Error()
+	if len(reason) > maxCloseReasonLen {
+		reason = reason[0:maxCloseReasonLen]
+	}
+	_ = c.conn.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.ClosePolicyViolation, reason), time.Now().Add(time.Second))
+}
+
+func (c *webSocketConnection) setInflight(topic string, inflight bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if inflight {
+		c.inflight[topic] = true
+	} else {
+		delete(c.inflight, topic)
+	}
+}
+
+func (c *webSocketConnection) isInflight(topic string) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.inflight[topic]
+}
+
 func (c *webSocketConnection) handleAckOrError(t *webSocketTopic, err error) {
+	c.setInflight(t.topic, false)
 	isError := err != nil
 	select {
 	case t.receiverChannel <- err:
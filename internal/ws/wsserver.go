@@ -20,6 +20,7 @@ import (
 	"context"
 	"net/http"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 )
 
 // WebSocketChannels is provided to allow us to do a blocking send to a namespace that will complete once a client connects on it
@@ -40,22 +42,99 @@ type WebSocketChannels interface {
 type WebSocketServer interface {
 	WebSocketChannels
 	Handler(w http.ResponseWriter, r *http.Request)
+	HasListeners(topic string) bool
 	Close()
 }
 
+// Authorizer authenticates websocket connections during the handshake, and authorizes each stream
+// a connection asks to listen on. Returning an error from either rejects the connection.
+type Authorizer interface {
+	// Authenticate is called with the token from the "Authorization: Bearer" header, or the "token"
+	// query parameter, of the handshake request. The token is empty if neither was supplied.
+	// The returned identity is passed to AuthorizeListen for each stream the connection listens on.
+	Authenticate(ctx context.Context, token string) (identity string, err error)
+	// AuthorizeListen is called each time a connection asks to listen on a stream
+	AuthorizeListen(ctx context.Context, identity, stream string) error
+}
+
+// permissiveAuthorizer is used when no authorizer is supplied, and allows any connection to listen on any stream
+type permissiveAuthorizer struct{}
+
+func (pa *permissiveAuthorizer) Authenticate(ctx context.Context, token string) (string, error) {
+	return "", nil
+}
+
+func (pa *permissiveAuthorizer) AuthorizeListen(ctx context.Context, identity, stream string) error {
+	return nil
+}
+
+// BackpressurePolicy determines what happens to a broadcast message for a connection whose send buffer is full
+type BackpressurePolicy string
+
+const (
+	BackpressureDisconnect BackpressurePolicy = "disconnect" // close the connection of the slow consumer, so it can reconnect once it has caught up
+	BackpressureDropOldest BackpressurePolicy = "dropOldest" // discard the oldest message in the send buffer to make space
+	BackpressureBlock      BackpressurePolicy = "block"      // wait on the connection for space up to the block timeout, then disconnect the slow consumer
+)
+
+// WebSocketServerConfig configures the bounded send buffer of each connection, and the policy applied when one fills
+type WebSocketServerConfig struct {
+	SendBufferSize     int
+	BackpressurePolicy BackpressurePolicy
+	BlockTimeout       time.Duration
+}
+
+// DefaultWebSocketServerConfig is used when no configuration is supplied to NewWebSocketServer
+var DefaultWebSocketServerConfig = WebSocketServerConfig{
+	SendBufferSize:     100,
+	BackpressurePolicy: BackpressureBlock,
+	BlockTimeout:       30 * time.Second,
+}
+
 type webSocketServer struct {
-	ctx               context.Context
-	processingTimeout time.Duration
-	mux               sync.Mutex
-	topics            map[string]*webSocketTopic
-	topicMap          map[string]map[string]*webSocketConnection
-	replyMap          map[string]*webSocketConnection
-	newTopic          chan bool
-	replyChannel      chan interface{}
-	upgrader          *websocket.Upgrader
-	connections       map[string]*webSocketConnection
+	ctx                context.Context
+	authorizer         Authorizer
+	processingTimeout  time.Duration
+	sendBufferSize     int
+	backpressurePolicy BackpressurePolicy
+	blockTimeout       time.Duration
+	mux                sync.Mutex
+	topics             map[string]*webSocketTopic
+	topicMap           map[string]map[string]*webSocketConnection
+	replyMap           map[string]*webSocketConnection
+	newTopic           chan bool
+	replyChannel       chan interface{}
+	upgrader           *websocket.Upgrader
+	connections        map[string]*webSocketConnection
+}
+
+// UnackedError is passed back on the receiver channel of a topic when a batch was not acknowledged by
+// the client, because it sent a nack, or because the connection closed before it sent an ack.
+// The batch should be redelivered.
+type UnackedError struct {
+	Err  error
+	Nack bool
+}
+
+func (e *UnackedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UnackedError) Unwrap() error {
+	return e.Err
+}
+
+// EncodedMessage is sent on a channel in place of a plain message, to deliver the payload in an encoding
+// other than JSON. A connection that negotiated an encoding with a subprotocol uses its own encoding instead.
+type EncodedMessage struct {
+	Encoding apitypes.PayloadEncoding
+	Payload  interface{}
 }
 
+// Subprotocols are the WebSocket subprotocols a client can request, to receive all messages on the
+// connection in that encoding, regardless of the encoding configured on the streams it listens to
+var Subprotocols = []string{string(apitypes.PayloadEncodingJSON), string(apitypes.PayloadEncodingMsgpack)}
+
 type webSocketTopic struct {
 	topic            string
 	senderChannel    chan interface{}
@@ -63,20 +142,38 @@ type webSocketTopic struct {
 	receiverChannel  chan error
 }
 
-// NewWebSocketServer create a new server with a simplified interface
-func NewWebSocketServer(bgCtx context.Context) WebSocketServer {
+// NewWebSocketServer create a new server with a simplified interface.
+// If authorizer is nil, all connections are accepted and can listen on any stream.
+// If conf is nil, DefaultWebSocketServerConfig is used.
+func NewWebSocketServer(bgCtx context.Context, authorizer Authorizer, conf *WebSocketServerConfig) WebSocketServer {
+	if authorizer == nil {
+		authorizer = &permissiveAuthorizer{}
+	}
+	if conf == nil {
+		conf = &DefaultWebSocketServerConfig
+	}
+	sendBufferSize := conf.SendBufferSize
+	if sendBufferSize < 1 {
+		// An unbuffered connection would be full whenever it was in the middle of a write
+		sendBufferSize = 1
+	}
 	s := &webSocketServer{
-		ctx:               bgCtx,
-		connections:       make(map[string]*webSocketConnection),
-		topics:            make(map[string]*webSocketTopic),
-		topicMap:          make(map[string]map[string]*webSocketConnection),
-		replyMap:          make(map[string]*webSocketConnection),
-		newTopic:          make(chan bool),
-		replyChannel:      make(chan interface{}),
-		processingTimeout: 30 * time.Second,
+		ctx:                bgCtx,
+		authorizer:         authorizer,
+		sendBufferSize:     sendBufferSize,
+		backpressurePolicy: conf.BackpressurePolicy,
+		blockTimeout:       conf.BlockTimeout,
+		connections:        make(map[string]*webSocketConnection),
+		topics:             make(map[string]*webSocketTopic),
+		topicMap:           make(map[string]map[string]*webSocketConnection),
+		replyMap:           make(map[string]*webSocketConnection),
+		newTopic:           make(chan bool),
+		replyChannel:       make(chan interface{}),
+		processingTimeout:  30 * time.Second,
 		upgrader: &websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
+			Subprotocols:    Subprotocols,
 		},
 	}
 	go s.processBroadcasts()
@@ -84,7 +181,22 @@ func NewWebSocketServer(bgCtx context.Context) WebSocketServer {
 	return s
 }
 
+// getToken extracts the token from the handshake request, preferring a bearer token in the Authorization header
+func getToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[0:7], "bearer ") {
+		return auth[7:]
+	}
+	return r.URL.Query().Get("token")
+}
+
 func (s *webSocketServer) Handler(w http.ResponseWriter, r *http.Request) {
+	identity, err := s.authorizer.Authenticate(r.Context(), getToken(r))
+	if err != nil {
+		log.L(s.ctx).Errorf("WebSocket authentication failed: %s", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.L(s.ctx).Errorf("WebSocket upgrade failed: %s", err)
@@ -92,18 +204,22 @@ func (s *webSocketServer) Handler(w http.ResponseWriter, r *http.Request) {
 	}
 	s.mux.Lock()
 	defer s.mux.Unlock()
-	c := newConnection(s.ctx, s, conn)
+	c := newConnection(s.ctx, s, conn, identity)
 	s.connections[c.id] = c
 }
 
-func (s *webSocketServer) cycleTopic(connInfo string, t *webSocketTopic) {
+func (s *webSocketServer) cycleTopic(connInfo string, t *webSocketTopic, inflight bool) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
 	// When a connection that was listening on a topic closes, we need to wake anyone
-	// that was listening for a response
+	// that was listening for a response. If it had a batch in-flight, that batch needs redelivering.
+	var err error = i18n.NewError(s.ctx, tmmsgs.MsgWebSocketClosed, connInfo)
+	if inflight {
+		err = &UnackedError{Err: err}
+	}
 	select {
-	case t.receiverChannel <- i18n.NewError(s.ctx, tmmsgs.MsgWebSocketClosed, connInfo):
+	case t.receiverChannel <- err:
 	default:
 	}
 }
@@ -119,7 +235,10 @@ func (s *webSocketServer) connectionClosed(c *webSocketConnection) {
 }
 
 func (s *webSocketServer) Close() {
-	for _, c := range s.connections {
+	s.mux.Lock()
+	wsconns := getConnListFromMap(s.connections)
+	s.mux.Unlock()
+	for _, c := range wsconns {
 		c.close()
 	}
 }
@@ -152,7 +271,17 @@ func (s *webSocketServer) GetChannels(topic string) (chan<- interface{}, chan<-
 
 func (s *webSocketServer) ListenOnTopic(c *webSocketConnection, topic string) {
 	// Track that this connection is interested in this topic
+	s.mux.Lock()
 	s.topicMap[topic][c.id] = c
+	s.mux.Unlock()
+}
+
+// HasListeners returns true if any connection is listening on the topic, so producers of best-effort
+// broadcasts can skip building messages nobody will receive
+func (s *webSocketServer) HasListeners(topic string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.topicMap[topic]) > 0
 }
 
 func (s *webSocketServer) ListenForReplies(c *webSocketConnection) {
@@ -222,8 +351,19 @@ func (s *webSocketServer) processReplies() {
 	}
 }
 
+// broadcastToConnections queues the message to each connection, applying the backpressure policy to those with a
+// full send buffer. None of the policies wait on the dispatcher, so a slow consumer does not delay the others.
 func (s *webSocketServer) broadcastToConnections(connections []*webSocketConnection, message interface{}) {
 	for _, c := range connections {
-		c.broadcast <- message
+		switch s.backpressurePolicy {
+		case BackpressureBlock:
+			c.queueBroadcastBlocking(message, s.blockTimeout)
+		case BackpressureDropOldest:
+			c.queueBroadcastDropOldest(message)
+		default:
+			if !c.queueBroadcast(message) {
+				c.disconnectSlowConsumer()
+			}
+		}
 	}
 }
@@ -18,20 +18,55 @@ package ws
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	ws "github.com/gorilla/websocket"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func newTestWebSocketServer() (*webSocketServer, *httptest.Server) {
-	s := NewWebSocketServer(context.Background()).(*webSocketServer)
+	s := NewWebSocketServer(context.Background(), nil, nil).(*webSocketServer)
+	ts := httptest.NewServer(http.HandlerFunc(s.Handler))
+	return s, ts
+}
+
+type testAuthorizer struct {
+	tokens  map[string]string   // token to identity
+	streams map[string][]string // identity to allowed streams
+}
+
+func (ta *testAuthorizer) Authenticate(ctx context.Context, token string) (string, error) {
+	identity, ok := ta.tokens[token]
+	if !ok {
+		return "", fmt.Errorf("pop")
+	}
+	return identity, nil
+}
+
+func (ta *testAuthorizer) AuthorizeListen(ctx context.Context, identity, stream string) error {
+	for _, s := range ta.streams[identity] {
+		if s == stream {
+			return nil
+		}
+	}
+	return fmt.Errorf("identity '%s' cannot listen on '%s'", identity, stream)
+}
+
+func newTestWebSocketServerWithAuth() (*webSocketServer, *httptest.Server) {
+	s := NewWebSocketServer(context.Background(), &testAuthorizer{
+		tokens:  map[string]string{"token1": "user1"},
+		streams: map[string][]string{"user1": {"stream1"}},
+	}, nil).(*webSocketServer)
 	ts := httptest.NewServer(http.HandlerFunc(s.Handler))
 	return s, ts
 }
@@ -369,3 +404,595 @@ func TestListenTopicClosing(t *testing.T) {
 		topic: "test",
 	})
 }
+
+func TestNackAndDisconnectRedelivery(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "listen",
+		Topic: "topic1",
+	})
+
+	s, _, r := w.GetChannels("topic1")
+
+	s <- "Batch 1"
+	var val string
+	c.ReadJSON(&val)
+	assert.Equal("Batch 1", val)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:    "nack",
+		Topic:   "topic1",
+		Message: "not now",
+	})
+	err = <-r
+	unacked, ok := err.(*UnackedError)
+	assert.True(ok)
+	assert.True(unacked.Nack)
+	assert.Regexp("FF21091.*not now", err)
+	assert.Regexp("FF21091", unacked.Unwrap())
+
+	// Redelivered batch is in-flight when the connection closes
+	s <- "Batch 1"
+	c.ReadJSON(&val)
+	assert.Equal("Batch 1", val)
+	c.Close()
+
+	err = <-r
+	unacked, ok = err.(*UnackedError)
+	assert.True(ok)
+	assert.False(unacked.Nack)
+	assert.Regexp("FF21037", err)
+
+	w.Close()
+}
+
+func TestHasListeners(t *testing.T) {
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	assert.False(t, w.HasListeners("test"))
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "listen",
+		Topic: "test",
+	})
+	for !w.HasListeners("test") {
+		time.Sleep(1 * time.Millisecond)
+	}
+	assert.False(t, w.HasListeners("other"))
+
+	c.Close()
+	for w.HasListeners("test") {
+		time.Sleep(1 * time.Millisecond)
+	}
+}
+
+func TestAuthorizedListen(t *testing.T) {
+
+	w, ts := newTestWebSocketServerWithAuth()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), http.Header{
+		"Authorization": []string{"Bearer token1"},
+	})
+	assert.NoError(t, err)
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:   "listen",
+		Stream: "stream1",
+	})
+
+	s, _, _ := w.GetChannels("stream1")
+	s <- "Hello World"
+
+	var val string
+	err = c.ReadJSON(&val)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello World", val)
+
+	w.Close()
+}
+
+func TestUnauthorizedListenRejected(t *testing.T) {
+
+	w, ts := newTestWebSocketServerWithAuth()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	u.RawQuery = "token=token1"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:   "listen",
+		Stream: "stream2",
+	})
+
+	_, _, err = c.ReadMessage()
+	assert.True(t, ws.IsCloseError(err, ws.ClosePolicyViolation))
+	assert.Regexp(t, "identity 'user1' cannot listen on 'stream2'", err)
+	assert.False(t, w.HasListeners("stream2"))
+
+	for len(w.connections) > 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	w.Close()
+}
+
+func TestUnauthorizedListenLongReason(t *testing.T) {
+
+	w, ts := newTestWebSocketServerWithAuth()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	u.RawQuery = "token=token1"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	longStream := strings.Repeat("a", 200)
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:   "listen",
+		Stream: longStream,
+	})
+
+	_, _, err = c.ReadMessage()
+	closeErr, ok := err.(*ws.CloseError)
+	assert.True(t, ok)
+	assert.Equal(t, ws.ClosePolicyViolation, closeErr.Code)
+	assert.Len(t, closeErr.Text, maxCloseReasonLen)
+
+	w.Close()
+}
+
+func TestUnauthenticatedConnectionRejected(t *testing.T) {
+
+	w, ts := newTestWebSocketServerWithAuth()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	_, res, err := ws.DefaultDialer.Dial(u.String(), http.Header{
+		"Authorization": []string{"Bearer wrong"},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	_, res, err = ws.DefaultDialer.Dial(u.String(), nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	assert.Empty(t, w.connections)
+
+	w.Close()
+}
+
+func TestMsgpackSubprotocol(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	dialer := &ws.Dialer{Subprotocols: []string{"msgpack"}}
+	c, _, err := dialer.Dial(u.String(), nil)
+	assert.NoError(err)
+	assert.Equal("msgpack", c.Subprotocol())
+
+	// Commands can be sent as msgpack in binary frames
+	listen, err := apitypes.MarshalPayload(apitypes.PayloadEncodingMsgpack, &webSocketCommandMessage{Type: "listen", Stream: "stream1"})
+	assert.NoError(err)
+	c.WriteMessage(ws.BinaryMessage, listen)
+
+	// Plain messages are sent as msgpack, as negotiated by the connection
+	s, _, r := w.GetChannels("stream1")
+	s <- map[string]interface{}{"batchNumber": 1}
+	msgType, data, err := c.ReadMessage()
+	assert.NoError(err)
+	assert.Equal(ws.BinaryMessage, msgType)
+	var received map[string]interface{}
+	err = apitypes.UnmarshalPayload(apitypes.PayloadEncodingMsgpack, data, &received)
+	assert.NoError(err)
+	assert.Equal(float64(1), received["batchNumber"])
+
+	// The ack protocol is the same
+	ack, err := apitypes.MarshalPayload(apitypes.PayloadEncodingMsgpack, &webSocketCommandMessage{Type: "ack", Stream: "stream1"})
+	assert.NoError(err)
+	c.WriteMessage(ws.BinaryMessage, ack)
+	err = <-r
+	assert.NoError(err)
+
+	// JSON commands in text frames are accepted too
+	s <- map[string]interface{}{"batchNumber": 2}
+	_, _, err = c.ReadMessage()
+	assert.NoError(err)
+	c.WriteJSON(&webSocketCommandMessage{Type: "nack", Stream: "stream1", Message: "pop"})
+	err = <-r
+	assert.Regexp("pop", err)
+
+	w.Close()
+}
+
+func TestEncodedMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	c1, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+	assert.Empty(c1.Subprotocol())
+	c1.WriteJSON(&webSocketCommandMessage{Type: "listen", Stream: "stream1"})
+
+	// A connection that negotiated JSON gets JSON, regardless of the encoding of the message
+	dialer := &ws.Dialer{Subprotocols: []string{"json"}}
+	c2, _, err := dialer.Dial(u.String(), nil)
+	assert.NoError(err)
+	c2.WriteJSON(&webSocketCommandMessage{Type: "listen", Stream: "stream1"})
+
+	for {
+		w.mux.Lock()
+		listeners := len(w.topicMap["stream1"])
+		w.mux.Unlock()
+		if listeners == 2 {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	_, b, _ := w.GetChannels("stream1")
+	b <- &EncodedMessage{Encoding: apitypes.PayloadEncodingMsgpack, Payload: []string{"event1"}}
+
+	msgType, data, err := c1.ReadMessage()
+	assert.NoError(err)
+	assert.Equal(ws.BinaryMessage, msgType)
+	var received []string
+	err = apitypes.UnmarshalPayload(apitypes.PayloadEncodingMsgpack, data, &received)
+	assert.NoError(err)
+	assert.Equal([]string{"event1"}, received)
+
+	msgType, data, err = c2.ReadMessage()
+	assert.NoError(err)
+	assert.Equal(ws.TextMessage, msgType)
+	assert.JSONEq(`["event1"]`, string(data))
+
+	w.Close()
+}
+
+func TestEncodedMessageFail(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+	c.WriteJSON(&webSocketCommandMessage{Type: "listen", Stream: "stream1"})
+
+	// A message that cannot be encoded is dropped, and the connection carries on
+	s, _, _ := w.GetChannels("stream1")
+	s <- &EncodedMessage{Encoding: apitypes.PayloadEncodingMsgpack, Payload: map[bool]bool{true: true}}
+	s <- &EncodedMessage{Encoding: apitypes.PayloadEncodingMsgpack, Payload: "ok"}
+
+	_, data, err := c.ReadMessage()
+	assert.NoError(err)
+	var received string
+	err = apitypes.UnmarshalPayload(apitypes.PayloadEncodingMsgpack, data, &received)
+	assert.NoError(err)
+	assert.Equal("ok", received)
+
+	w.Close()
+}
+
+func TestBadMsgpackCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+
+	// The connection is closed, as with a bad JSON command
+	c.WriteMessage(ws.BinaryMessage, []byte{0xc1})
+	_, _, err = c.ReadMessage()
+	assert.Error(err)
+
+	w.Close()
+}
+
+// gatedConn wraps the server side of a connection, so a test can stall writes to simulate a slow reader
+type gatedConn struct {
+	net.Conn
+	mux     sync.Mutex
+	gate    chan struct{}
+	blocked chan struct{}
+}
+
+func (gc *gatedConn) Write(b []byte) (int, error) {
+	gc.mux.Lock()
+	gate := gc.gate
+	gc.mux.Unlock()
+	if gate != nil {
+		select {
+		case gc.blocked <- struct{}{}:
+		default:
+		}
+		<-gate
+	}
+	return gc.Conn.Write(b)
+}
+
+func (gc *gatedConn) stall() {
+	gc.mux.Lock()
+	defer gc.mux.Unlock()
+	gc.gate = make(chan struct{})
+}
+
+func (gc *gatedConn) release() {
+	gc.mux.Lock()
+	defer gc.mux.Unlock()
+	if gc.gate != nil {
+		close(gc.gate)
+		gc.gate = nil
+	}
+}
+
+func (gc *gatedConn) Close() error {
+	gc.release()
+	return gc.Conn.Close()
+}
+
+type gatedListener struct {
+	net.Listener
+	accepted chan *gatedConn
+}
+
+func (gl *gatedListener) Accept() (net.Conn, error) {
+	conn, err := gl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	gc := &gatedConn{Conn: conn, blocked: make(chan struct{}, 1)}
+	gl.accepted <- gc
+	return gc, nil
+}
+
+func newTestBackpressureServer(conf *WebSocketServerConfig) (*webSocketServer, *httptest.Server, *gatedListener) {
+	s := NewWebSocketServer(context.Background(), nil, conf).(*webSocketServer)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(s.Handler))
+	gl := &gatedListener{Listener: ts.Listener, accepted: make(chan *gatedConn, 10)}
+	ts.Listener = gl
+	ts.Start()
+	return s, ts, gl
+}
+
+func listenerCount(s *webSocketServer, topic string) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.topicMap[topic])
+}
+
+// dialTestListener connects a client listening on the topic, returning the server side of the connection
+func dialTestListener(t *testing.T, s *webSocketServer, ts *httptest.Server, gl *gatedListener, topic string) (*ws.Conn, *gatedConn) {
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	before := listenerCount(s, topic)
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	gc := <-gl.accepted
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "listen",
+		Topic: topic,
+	})
+	for listenerCount(s, topic) == before {
+		time.Sleep(1 * time.Millisecond)
+	}
+	return c, gc
+}
+
+// stallSlowReader blocks the sender of the slow connection part way through writing the first message
+func stallSlowReader(t *testing.T, gc *gatedConn, b chan<- interface{}, fast *ws.Conn) {
+	gc.stall()
+	b <- "message 1"
+	<-gc.blocked
+	var val string
+	err := fast.ReadJSON(&val)
+	assert.NoError(t, err)
+	assert.Equal(t, "message 1", val)
+}
+
+// broadcastToFastReader checks each message reaches the fast connection, while the slow connection is stalled
+func broadcastToFastReader(t *testing.T, b chan<- interface{}, fast *ws.Conn, from, to int) {
+	for i := from; i <= to; i++ {
+		msg := fmt.Sprintf("message %d", i)
+		b <- msg
+		var val string
+		err := fast.ReadJSON(&val)
+		assert.NoError(t, err)
+		assert.Equal(t, msg, val)
+	}
+}
+
+func TestBackpressureDisconnectSlowConsumer(t *testing.T) {
+	w, ts, gl := newTestBackpressureServer(&WebSocketServerConfig{
+		SendBufferSize:     2,
+		BackpressurePolicy: BackpressureDisconnect,
+	})
+	defer ts.Close()
+
+	slow, slowConn := dialTestListener(t, w, ts, gl, "topic1")
+	fast, _ := dialTestListener(t, w, ts, gl, "topic1")
+	_, b, _ := w.GetChannels("topic1")
+
+	// Message 1 is being written, and 2 and 3 fill the send buffer, so message 4 disconnects the slow consumer
+	stallSlowReader(t, slowConn, b, fast)
+	broadcastToFastReader(t, b, fast, 2, 4)
+	for listenerCount(w, "topic1") > 1 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	broadcastToFastReader(t, b, fast, 5, 5)
+
+	var err error
+	for err == nil {
+		var val string
+		err = slow.ReadJSON(&val)
+		assert.NotEqual(t, "message 2", val)
+	}
+
+	w.Close()
+}
+
+func TestBackpressureDropOldest(t *testing.T) {
+	w, ts, gl := newTestBackpressureServer(&WebSocketServerConfig{
+		SendBufferSize:     2,
+		BackpressurePolicy: BackpressureDropOldest,
+	})
+	defer ts.Close()
+
+	slow, slowConn := dialTestListener(t, w, ts, gl, "topic1")
+	fast, _ := dialTestListener(t, w, ts, gl, "topic1")
+	_, b, _ := w.GetChannels("topic1")
+
+	// Message 1 is being written, and messages 2 to 4 are dropped to make space for the later messages
+	stallSlowReader(t, slowConn, b, fast)
+	broadcastToFastReader(t, b, fast, 2, 6)
+	slowConn.release()
+
+	for _, expected := range []string{"message 1", "message 5", "message 6"} {
+		var val string
+		err := slow.ReadJSON(&val)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, val)
+	}
+	assert.Equal(t, 2, listenerCount(w, "topic1"))
+
+	w.Close()
+}
+
+func TestBackpressureBlockUntilSpace(t *testing.T) {
+	w, ts, gl := newTestBackpressureServer(&WebSocketServerConfig{
+		SendBufferSize:     2,
+		BackpressurePolicy: BackpressureBlock,
+		BlockTimeout:       1 * time.Minute,
+	})
+	defer ts.Close()
+
+	slow, slowConn := dialTestListener(t, w, ts, gl, "topic1")
+	fast, _ := dialTestListener(t, w, ts, gl, "topic1")
+	_, b, _ := w.GetChannels("topic1")
+
+	// Messages 2 and 3 fill the send buffer, so the slow connection waits for space for message 4 and holds
+	// message 5 behind it, while the dispatcher carries on delivering to the fast reader
+	stallSlowReader(t, slowConn, b, fast)
+	broadcastToFastReader(t, b, fast, 2, 5)
+	slowConn.release()
+
+	readSlow := func(from, to int) {
+		for i := from; i <= to; i++ {
+			var val string
+			err := slow.ReadJSON(&val)
+			assert.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("message %d", i), val)
+		}
+	}
+	readSlow(1, 5)
+	broadcastToFastReader(t, b, fast, 6, 6)
+	readSlow(6, 6)
+	assert.Equal(t, 2, listenerCount(w, "topic1"))
+
+	w.Close()
+}
+
+func TestBackpressureBlockOverflow(t *testing.T) {
+	w, ts, gl := newTestBackpressureServer(&WebSocketServerConfig{
+		SendBufferSize:     1,
+		BackpressurePolicy: BackpressureBlock,
+		BlockTimeout:       1 * time.Minute,
+	})
+	defer ts.Close()
+
+	slow, slowConn := dialTestListener(t, w, ts, gl, "topic1")
+	fast, _ := dialTestListener(t, w, ts, gl, "topic1")
+	_, b, _ := w.GetChannels("topic1")
+
+	// Message 2 fills the send buffer and message 3 is held, so message 4 disconnects the slow consumer
+	stallSlowReader(t, slowConn, b, fast)
+	broadcastToFastReader(t, b, fast, 2, 4)
+	for listenerCount(w, "topic1") > 1 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	broadcastToFastReader(t, b, fast, 5, 5)
+
+	var err error
+	for err == nil {
+		var val string
+		err = slow.ReadJSON(&val)
+	}
+
+	w.Close()
+}
+
+func TestBackpressureBlockTimeout(t *testing.T) {
+	w, ts, gl := newTestBackpressureServer(&WebSocketServerConfig{
+		SendBufferSize:     1,
+		BackpressurePolicy: BackpressureBlock,
+		BlockTimeout:       10 * time.Millisecond,
+	})
+	defer ts.Close()
+
+	slow, slowConn := dialTestListener(t, w, ts, gl, "topic1")
+	fast, _ := dialTestListener(t, w, ts, gl, "topic1")
+	_, b, _ := w.GetChannels("topic1")
+
+	stallSlowReader(t, slowConn, b, fast)
+	broadcastToFastReader(t, b, fast, 2, 3)
+	for listenerCount(w, "topic1") > 1 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	broadcastToFastReader(t, b, fast, 4, 4)
+
+	var err error
+	for err == nil {
+		var val string
+		err = slow.ReadJSON(&val)
+	}
+
+	w.Close()
+}
+
+func TestNewWebSocketServerMinimumBuffer(t *testing.T) {
+	w := NewWebSocketServer(context.Background(), nil, &WebSocketServerConfig{
+		BackpressurePolicy: BackpressureDisconnect,
+	}).(*webSocketServer)
+	assert.Equal(t, 1, w.sendBufferSize)
+
+	w = NewWebSocketServer(context.Background(), nil, nil).(*webSocketServer)
+	assert.Equal(t, DefaultWebSocketServerConfig.SendBufferSize, w.sendBufferSize)
+	assert.Equal(t, BackpressureBlock, w.backpressurePolicy)
+}
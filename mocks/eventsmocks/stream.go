@@ -5,6 +5,8 @@ package eventsmocks
 import (
 	context "context"
 
+	http "net/http"
+
 	apitypes "github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 
 	fftypes "github.com/hyperledger/firefly-common/pkg/fftypes"
@@ -17,6 +19,20 @@ type Stream struct {
 	mock.Mock
 }
 
+// AckSSE provides a mock function with given fields: ctx, batchNumber
+func (_m *Stream) AckSSE(ctx context.Context, batchNumber int) error {
+	ret := _m.Called(ctx, batchNumber)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, batchNumber)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // AddOrUpdateListener provides a mock function with given fields: ctx, id, updates, reset
 func (_m *Stream) AddOrUpdateListener(ctx context.Context, id *fftypes.UUID, updates *apitypes.Listener, reset bool) (*apitypes.Listener, error) {
 	ret := _m.Called(ctx, id, updates, reset)
@@ -40,6 +56,29 @@ func (_m *Stream) AddOrUpdateListener(ctx context.Context, id *fftypes.UUID, upd
 	return r0, r1
 }
 
+// DeadLetters provides a mock function with given fields: ctx, limit
+func (_m *Stream) DeadLetters(ctx context.Context, limit int) ([]*apitypes.DeadLetter, error) {
+	ret := _m.Called(ctx, limit)
+
+	var r0 []*apitypes.DeadLetter
+	if rf, ok := ret.Get(0).(func(context.Context, int) []*apitypes.DeadLetter); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*apitypes.DeadLetter)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: ctx
 func (_m *Stream) Delete(ctx context.Context) error {
 	ret := _m.Called(ctx)
@@ -54,6 +93,29 @@ func (_m *Stream) Delete(ctx context.Context) error {
 	return r0
 }
 
+// GetCheckpoint provides a mock function with given fields: ctx
+func (_m *Stream) GetCheckpoint(ctx context.Context) (*apitypes.EventStreamCheckpoint, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *apitypes.EventStreamCheckpoint
+	if rf, ok := ret.Get(0).(func(context.Context) *apitypes.EventStreamCheckpoint); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.EventStreamCheckpoint)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RemoveListener provides a mock function with given fields: ctx, id
 func (_m *Stream) RemoveListener(ctx context.Context, id *fftypes.UUID) error {
 	ret := _m.Called(ctx, id)
@@ -68,6 +130,89 @@ func (_m *Stream) RemoveListener(ctx context.Context, id *fftypes.UUID) error {
 	return r0
 }
 
+// Replay provides a mock function with given fields: ctx, req
+func (_m *Stream) Replay(ctx context.Context, req *apitypes.EventStreamReplay) (*apitypes.EventStreamReplayResult, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *apitypes.EventStreamReplayResult
+	if rf, ok := ret.Get(0).(func(context.Context, *apitypes.EventStreamReplay) *apitypes.EventStreamReplayResult); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.EventStreamReplayResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *apitypes.EventStreamReplay) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplayDeadLetters provides a mock function with given fields: ctx
+func (_m *Stream) ReplayDeadLetters(ctx context.Context) (*apitypes.DeadLetterReplayResult, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *apitypes.DeadLetterReplayResult
+	if rf, ok := ret.Get(0).(func(context.Context) *apitypes.DeadLetterReplayResult); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.DeadLetterReplayResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ServeSSE provides a mock function with given fields: ctx, res, manualAck
+func (_m *Stream) ServeSSE(ctx context.Context, res http.ResponseWriter, manualAck bool) error {
+	ret := _m.Called(ctx, res, manualAck)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, http.ResponseWriter, bool) error); ok {
+		r0 = rf(ctx, res, manualAck)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetCheckpoint provides a mock function with given fields: ctx, updates, confirmRewind
+func (_m *Stream) SetCheckpoint(ctx context.Context, updates *apitypes.EventStreamCheckpoint, confirmRewind bool) (*apitypes.EventStreamCheckpoint, error) {
+	ret := _m.Called(ctx, updates, confirmRewind)
+
+	var r0 *apitypes.EventStreamCheckpoint
+	if rf, ok := ret.Get(0).(func(context.Context, *apitypes.EventStreamCheckpoint, bool) *apitypes.EventStreamCheckpoint); ok {
+		r0 = rf(ctx, updates, confirmRewind)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.EventStreamCheckpoint)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *apitypes.EventStreamCheckpoint, bool) error); ok {
+		r1 = rf(ctx, updates, confirmRewind)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Spec provides a mock function with given fields:
 func (_m *Stream) Spec() *apitypes.EventStream {
 	ret := _m.Called()
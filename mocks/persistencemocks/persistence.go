@@ -12,6 +12,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	persistence "github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+
+	time "time"
 )
 
 // Persistence is an autogenerated mock type for the Persistence type
@@ -19,11 +21,55 @@ type Persistence struct {
 	mock.Mock
 }
 
+// AcquireLease provides a mock function with given fields: ctx, name, holder, ttl
+func (_m *Persistence) AcquireLease(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error) {
+	ret := _m.Called(ctx, name, holder, ttl)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Duration) bool); ok {
+		r0 = rf(ctx, name, holder, ttl)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Duration) error); ok {
+		r1 = rf(ctx, name, holder, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Close provides a mock function with given fields: ctx
 func (_m *Persistence) Close(ctx context.Context) {
 	_m.Called(ctx)
 }
 
+// CountTransactionsByStatus provides a mock function with given fields: ctx
+func (_m *Persistence) CountTransactionsByStatus(ctx context.Context) (map[apitypes.TxStatus]int, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[apitypes.TxStatus]int
+	if rf, ok := ret.Get(0).(func(context.Context) map[apitypes.TxStatus]int); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[apitypes.TxStatus]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteCheckpoint provides a mock function with given fields: ctx, streamID
 func (_m *Persistence) DeleteCheckpoint(ctx context.Context, streamID *fftypes.UUID) error {
 	ret := _m.Called(ctx, streamID)
@@ -38,6 +84,20 @@ func (_m *Persistence) DeleteCheckpoint(ctx context.Context, streamID *fftypes.U
 	return r0
 }
 
+// DeleteDeadLetter provides a mock function with given fields: ctx, deadLetter
+func (_m *Persistence) DeleteDeadLetter(ctx context.Context, deadLetter *apitypes.DeadLetter) error {
+	ret := _m.Called(ctx, deadLetter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *apitypes.DeadLetter) error); ok {
+		r0 = rf(ctx, deadLetter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteListener provides a mock function with given fields: ctx, listenerID
 func (_m *Persistence) DeleteListener(ctx context.Context, listenerID *fftypes.UUID) error {
 	ret := _m.Called(ctx, listenerID)
@@ -149,6 +209,29 @@ func (_m *Persistence) GetStream(ctx context.Context, streamID *fftypes.UUID) (*
 	return r0, r1
 }
 
+// GetSubmissionControl provides a mock function with given fields: ctx
+func (_m *Persistence) GetSubmissionControl(ctx context.Context) (*apitypes.SubmissionControl, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *apitypes.SubmissionControl
+	if rf, ok := ret.Get(0).(func(context.Context) *apitypes.SubmissionControl); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.SubmissionControl)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetTransactionByID provides a mock function with given fields: ctx, txID
 func (_m *Persistence) GetTransactionByID(ctx context.Context, txID string) (*apitypes.ManagedTX, error) {
 	ret := _m.Called(ctx, txID)
@@ -172,6 +255,75 @@ func (_m *Persistence) GetTransactionByID(ctx context.Context, txID string) (*ap
 	return r0, r1
 }
 
+// GetTransactionsByIDs provides a mock function with given fields: ctx, txIDs
+func (_m *Persistence) GetTransactionsByIDs(ctx context.Context, txIDs []string) ([]*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, txIDs)
+
+	var r0 []*apitypes.ManagedTX
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []*apitypes.ManagedTX); ok {
+		r0 = rf(ctx, txIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*apitypes.ManagedTX)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, txIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTransactionByHash provides a mock function with given fields: ctx, hash
+func (_m *Persistence) GetTransactionByHash(ctx context.Context, hash string) (*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, hash)
+
+	var r0 *apitypes.ManagedTX
+	if rf, ok := ret.Get(0).(func(context.Context, string) *apitypes.ManagedTX); ok {
+		r0 = rf(ctx, hash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.ManagedTX)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTransactionByIdempotencyKey provides a mock function with given fields: ctx, signer, idempotencyKey
+func (_m *Persistence) GetTransactionByIdempotencyKey(ctx context.Context, signer string, idempotencyKey string) (*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, signer, idempotencyKey)
+
+	var r0 *apitypes.ManagedTX
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *apitypes.ManagedTX); ok {
+		r0 = rf(ctx, signer, idempotencyKey)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*apitypes.ManagedTX)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, signer, idempotencyKey)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetTransactionByNonce provides a mock function with given fields: ctx, signer, nonce
 func (_m *Persistence) GetTransactionByNonce(ctx context.Context, signer string, nonce *fftypes.FFBigInt) (*apitypes.ManagedTX, error) {
 	ret := _m.Called(ctx, signer, nonce)
@@ -218,6 +370,29 @@ func (_m *Persistence) ListListeners(ctx context.Context, after *fftypes.UUID, l
 	return r0, r1
 }
 
+// ListStreamDeadLetters provides a mock function with given fields: ctx, streamID, limit
+func (_m *Persistence) ListStreamDeadLetters(ctx context.Context, streamID *fftypes.UUID, limit int) ([]*apitypes.DeadLetter, error) {
+	ret := _m.Called(ctx, streamID, limit)
+
+	var r0 []*apitypes.DeadLetter
+	if rf, ok := ret.Get(0).(func(context.Context, *fftypes.UUID, int) []*apitypes.DeadLetter); ok {
+		r0 = rf(ctx, streamID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*apitypes.DeadLetter)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *fftypes.UUID, int) error); ok {
+		r1 = rf(ctx, streamID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListStreamListeners provides a mock function with given fields: ctx, after, limit, dir, streamID
 func (_m *Persistence) ListStreamListeners(ctx context.Context, after *fftypes.UUID, limit int, dir persistence.SortDirection, streamID *fftypes.UUID) ([]*apitypes.Listener, error) {
 	ret := _m.Called(ctx, after, limit, dir, streamID)
@@ -287,6 +462,75 @@ func (_m *Persistence) ListTransactionsByCreateTime(ctx context.Context, after *
 	return r0, r1
 }
 
+// ListTransactionsByCreateTimeRange provides a mock function with given fields: ctx, from, to, after, limit, dir
+func (_m *Persistence) ListTransactionsByCreateTimeRange(ctx context.Context, from *fftypes.FFTime, to *fftypes.FFTime, after *apitypes.ManagedTX, limit int, dir persistence.SortDirection) ([]*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, from, to, after, limit, dir)
+
+	var r0 []*apitypes.ManagedTX
+	if rf, ok := ret.Get(0).(func(context.Context, *fftypes.FFTime, *fftypes.FFTime, *apitypes.ManagedTX, int, persistence.SortDirection) []*apitypes.ManagedTX); ok {
+		r0 = rf(ctx, from, to, after, limit, dir)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*apitypes.ManagedTX)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *fftypes.FFTime, *fftypes.FFTime, *apitypes.ManagedTX, int, persistence.SortDirection) error); ok {
+		r1 = rf(ctx, from, to, after, limit, dir)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTransactionsByDependsOn provides a mock function with given fields: ctx, parentID, after, limit, dir
+func (_m *Persistence) ListTransactionsByDependsOn(ctx context.Context, parentID string, after *apitypes.ManagedTX, limit int, dir persistence.SortDirection) ([]*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, parentID, after, limit, dir)
+
+	var r0 []*apitypes.ManagedTX
+	if rf, ok := ret.Get(0).(func(context.Context, string, *apitypes.ManagedTX, int, persistence.SortDirection) []*apitypes.ManagedTX); ok {
+		r0 = rf(ctx, parentID, after, limit, dir)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*apitypes.ManagedTX)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, *apitypes.ManagedTX, int, persistence.SortDirection) error); ok {
+		r1 = rf(ctx, parentID, after, limit, dir)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTransactionsByLabels provides a mock function with given fields: ctx, labels, after, limit, dir
+func (_m *Persistence) ListTransactionsByLabels(ctx context.Context, labels map[string]string, after *apitypes.ManagedTX, limit int, dir persistence.SortDirection) ([]*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, labels, after, limit, dir)
+
+	var r0 []*apitypes.ManagedTX
+	if rf, ok := ret.Get(0).(func(context.Context, map[string]string, *apitypes.ManagedTX, int, persistence.SortDirection) []*apitypes.ManagedTX); ok {
+		r0 = rf(ctx, labels, after, limit, dir)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*apitypes.ManagedTX)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, map[string]string, *apitypes.ManagedTX, int, persistence.SortDirection) error); ok {
+		r1 = rf(ctx, labels, after, limit, dir)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListTransactionsByNonce provides a mock function with given fields: ctx, signer, after, limit, dir
 func (_m *Persistence) ListTransactionsByNonce(ctx context.Context, signer string, after *fftypes.FFBigInt, limit int, dir persistence.SortDirection) ([]*apitypes.ManagedTX, error) {
 	ret := _m.Called(ctx, signer, after, limit, dir)
@@ -310,6 +554,52 @@ func (_m *Persistence) ListTransactionsByNonce(ctx context.Context, signer strin
 	return r0, r1
 }
 
+// ListTransactionsByRequestID provides a mock function with given fields: ctx, requestID, after, limit, dir
+func (_m *Persistence) ListTransactionsByRequestID(ctx context.Context, requestID string, after *apitypes.ManagedTX, limit int, dir persistence.SortDirection) ([]*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, requestID, after, limit, dir)
+
+	var r0 []*apitypes.ManagedTX
+	if rf, ok := ret.Get(0).(func(context.Context, string, *apitypes.ManagedTX, int, persistence.SortDirection) []*apitypes.ManagedTX); ok {
+		r0 = rf(ctx, requestID, after, limit, dir)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*apitypes.ManagedTX)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, *apitypes.ManagedTX, int, persistence.SortDirection) error); ok {
+		r1 = rf(ctx, requestID, after, limit, dir)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTransactionsByStatus provides a mock function with given fields: ctx, status, signer, after, limit, dir
+func (_m *Persistence) ListTransactionsByStatus(ctx context.Context, status apitypes.TxStatus, signer string, after *apitypes.ManagedTX, limit int, dir persistence.SortDirection) ([]*apitypes.ManagedTX, error) {
+	ret := _m.Called(ctx, status, signer, after, limit, dir)
+
+	var r0 []*apitypes.ManagedTX
+	if rf, ok := ret.Get(0).(func(context.Context, apitypes.TxStatus, string, *apitypes.ManagedTX, int, persistence.SortDirection) []*apitypes.ManagedTX); ok {
+		r0 = rf(ctx, status, signer, after, limit, dir)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*apitypes.ManagedTX)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, apitypes.TxStatus, string, *apitypes.ManagedTX, int, persistence.SortDirection) error); ok {
+		r1 = rf(ctx, status, signer, after, limit, dir)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListTransactionsPending provides a mock function with given fields: ctx, after, limit, dir
 func (_m *Persistence) ListTransactionsPending(ctx context.Context, after *fftypes.UUID, limit int, dir persistence.SortDirection) ([]*apitypes.ManagedTX, error) {
 	ret := _m.Called(ctx, after, limit, dir)
@@ -333,6 +623,20 @@ func (_m *Persistence) ListTransactionsPending(ctx context.Context, after *fftyp
 	return r0, r1
 }
 
+// ReleaseLease provides a mock function with given fields: ctx, name, holder
+func (_m *Persistence) ReleaseLease(ctx context.Context, name string, holder string) error {
+	ret := _m.Called(ctx, name, holder)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, name, holder)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WriteCheckpoint provides a mock function with given fields: ctx, checkpoint
 func (_m *Persistence) WriteCheckpoint(ctx context.Context, checkpoint *apitypes.EventStreamCheckpoint) error {
 	ret := _m.Called(ctx, checkpoint)
@@ -347,6 +651,20 @@ func (_m *Persistence) WriteCheckpoint(ctx context.Context, checkpoint *apitypes
 	return r0
 }
 
+// WriteDeadLetter provides a mock function with given fields: ctx, deadLetter
+func (_m *Persistence) WriteDeadLetter(ctx context.Context, deadLetter *apitypes.DeadLetter) error {
+	ret := _m.Called(ctx, deadLetter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *apitypes.DeadLetter) error); ok {
+		r0 = rf(ctx, deadLetter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WriteListener provides a mock function with given fields: ctx, spec
 func (_m *Persistence) WriteListener(ctx context.Context, spec *apitypes.Listener) error {
 	ret := _m.Called(ctx, spec)
@@ -375,6 +693,20 @@ func (_m *Persistence) WriteStream(ctx context.Context, spec *apitypes.EventStre
 	return r0
 }
 
+// WriteSubmissionControl provides a mock function with given fields: ctx, control
+func (_m *Persistence) WriteSubmissionControl(ctx context.Context, control *apitypes.SubmissionControl) error {
+	ret := _m.Called(ctx, control)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *apitypes.SubmissionControl) error); ok {
+		r0 = rf(ctx, control)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WriteTransaction provides a mock function with given fields: ctx, tx, new
 func (_m *Persistence) WriteTransaction(ctx context.Context, tx *apitypes.ManagedTX, new bool) error {
 	ret := _m.Called(ctx, tx, new)
@@ -0,0 +1,57 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiauth
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+)
+
+// APIAuth authorizes requests to the API server. It is invoked for every request after it
+// has been matched to a route, and before the handler for that route is called, so it can be
+// used for authentication, per-route authorization, and request logging.
+//
+// Returning an error rejects the request. The HTTP status is taken from the status hint of
+// the error if it is an i18n error, and is 401 Unauthorized otherwise.
+type APIAuth interface {
+	Authorize(ctx context.Context, req *AuthRequest) error
+}
+
+// AuthRequest describes a request to the API server that is being authorized
+type AuthRequest struct {
+	Method      string               // the HTTP method of the request
+	Route       string               // the path template of the matched route, such as /transactions/{transactionId}
+	Path        string               // the actual path of the request
+	RouteParams map[string]string    // the values of the parameters in the path template
+	Header      http.Header          // the headers of the request, including any Authorization header
+	TLS         *tls.ConnectionState // the TLS connection state, including any verified client certificates. Nil for plain HTTP
+	RemoteAddr  string               // the network address of the client
+}
+
+// WebSocketAuth can optionally be implemented by an APIAuth plugin, to authenticate websocket
+// connections and authorize each event stream they listen on. Plugins that do not implement it
+// only authorize the handshake request through Authorize, and every connection that passes can
+// listen on any stream.
+type WebSocketAuth interface {
+	// Authenticate is called with the token from the "Authorization: Bearer" header, or the "token"
+	// query parameter, of the handshake request. The token is empty if neither was supplied.
+	// The returned identity is passed to AuthorizeListen for each stream the connection listens on.
+	Authenticate(ctx context.Context, token string) (identity string, err error)
+	// AuthorizeListen is called each time a connection asks to listen on a stream
+	AuthorizeListen(ctx context.Context, identity, stream string) error
+}
@@ -0,0 +1,50 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiauths
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apiauth"
+)
+
+var apiAuths = make(map[string]Factory)
+
+func NewAPIAuth(ctx context.Context, baseConfig config.Section, name string) (apiauth.APIAuth, error) {
+	factory, ok := apiAuths[name]
+	if !ok {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgAPIAuthNotRegistered, name)
+	}
+	return factory.NewAPIAuth(ctx, baseConfig.SubSection(name))
+}
+
+type Factory interface {
+	Name() string
+	InitConfig(conf config.Section)
+	NewAPIAuth(ctx context.Context, conf config.Section) (apiauth.APIAuth, error)
+}
+
+func RegisterAuth(factory Factory) string {
+	name := factory.Name()
+	apiAuths[name] = factory
+	factory.InitConfig(tmconfig.APIAuthBaseConfig.SubSection(name))
+	return name
+}
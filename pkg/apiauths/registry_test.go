@@ -0,0 +1,71 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiauths
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apiauth"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFactory struct{}
+
+type testAuth struct {
+	token string
+}
+
+func (f *testFactory) Name() string {
+	return "test"
+}
+
+func (f *testFactory) InitConfig(conf config.Section) {
+	conf.AddKnownKey("token", "secret")
+}
+
+func (f *testFactory) NewAPIAuth(ctx context.Context, conf config.Section) (apiauth.APIAuth, error) {
+	return &testAuth{token: conf.GetString("token")}, nil
+}
+
+func (a *testAuth) Authorize(ctx context.Context, req *apiauth.AuthRequest) error {
+	if req.Header.Get("Authorization") != "Bearer "+a.token {
+		return fmt.Errorf("pop")
+	}
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+
+	tmconfig.Reset()
+	RegisterAuth(&testFactory{})
+
+	aa, err := NewAPIAuth(context.Background(), tmconfig.APIAuthBaseConfig, "test")
+	assert.NoError(t, err)
+	err = aa.Authorize(context.Background(), &apiauth.AuthRequest{
+		Header: map[string][]string{"Authorization": {"Bearer secret"}},
+	})
+	assert.NoError(t, err)
+
+	aa, err = NewAPIAuth(context.Background(), tmconfig.APIAuthBaseConfig, "bob")
+	assert.Nil(t, aa)
+	assert.Regexp(t, "FF21107", err)
+
+}
@@ -38,6 +38,14 @@ type EventStreamType = fftypes.FFEnum
 var (
 	EventStreamTypeWebhook   = fftypes.FFEnumValue("estype", "webhook")
 	EventStreamTypeWebSocket = fftypes.FFEnumValue("estype", "websocket")
+	EventStreamTypeSSE       = fftypes.FFEnumValue("estype", "sse")
+)
+
+type PayloadEncoding = fftypes.FFEnum
+
+var (
+	PayloadEncodingJSON    = fftypes.FFEnumValue("payloadenc", "json")
+	PayloadEncodingMsgpack = fftypes.FFEnumValue("payloadenc", "msgpack")
 )
 
 type ErrorHandlingType = fftypes.FFEnum
@@ -55,11 +63,17 @@ type EventStream struct {
 	Suspended *bool            `ffstruct:"eventstream" json:"suspended,omitempty"`
 	Type      *EventStreamType `ffstruct:"eventstream" json:"type,omitempty" ffenum:"estype"`
 
-	ErrorHandling     *ErrorHandlingType  `ffstruct:"eventstream" json:"errorHandling"`
-	BatchSize         *uint64             `ffstruct:"eventstream" json:"batchSize"`
-	BatchTimeout      *fftypes.FFDuration `ffstruct:"eventstream" json:"batchTimeout"`
-	RetryTimeout      *fftypes.FFDuration `ffstruct:"eventstream" json:"retryTimeout"`
-	BlockedRetryDelay *fftypes.FFDuration `ffstruct:"eventstream" json:"blockedRetryDelay"`
+	ErrorHandling        *ErrorHandlingType  `ffstruct:"eventstream" json:"errorHandling"`
+	BatchSize            *uint64             `ffstruct:"eventstream" json:"batchSize"`
+	BatchTimeout         *fftypes.FFDuration `ffstruct:"eventstream" json:"batchTimeout"`
+	RetryTimeout         *fftypes.FFDuration `ffstruct:"eventstream" json:"retryTimeout"`
+	BlockedRetryDelay    *fftypes.FFDuration `ffstruct:"eventstream" json:"blockedRetryDelay"`
+	Confirmations        *uint64             `ffstruct:"eventstream" json:"confirmations"`
+	ConfirmationStrategy *string             `ffstruct:"eventstream" json:"confirmationStrategy,omitempty"` // takes precedence over confirmations if set
+	Filter               *EventStreamFilter  `ffstruct:"eventstream" json:"filter,omitempty"`
+	EventABI             []*ABIEvent         `ffstruct:"eventstream" json:"eventABI,omitempty"`
+	BlockListener        *string             `ffstruct:"eventstream" json:"blockListener,omitempty"`
+	PayloadEncoding      *PayloadEncoding    `ffstruct:"eventstream" json:"payloadEncoding,omitempty" ffenum:"payloadenc"` // the encoding of the batches delivered by the stream
 
 	EthCompatBatchTimeoutMS       *uint64 `ffstruct:"eventstream" json:"batchTimeoutMS,omitempty"`       // input only, for backwards compatibility
 	EthCompatRetryTimeoutSec      *uint64 `ffstruct:"eventstream" json:"retryTimeoutSec,omitempty"`      // input only, for backwards compatibility
@@ -69,6 +83,30 @@ type EventStream struct {
 	WebSocket *WebSocketConfig `ffstruct:"eventstream" json:"websocket,omitempty"`
 }
 
+// EventStreamFilter restricts the confirmed events delivered by a stream. Each set that is empty
+// matches all values, so a stream with no filter delivers every event from its listeners.
+type EventStreamFilter struct {
+	Addresses  []string `ffstruct:"esfilter" json:"addresses,omitempty"`  // contract addresses, matched case-insensitively against the "address" in the event info
+	Signatures []string `ffstruct:"esfilter" json:"signatures,omitempty"` // event signatures (topics)
+}
+
+// ABIEvent is the JSON ABI definition of a contract event. Event streams configured with event ABI
+// decode the raw logs they deliver into the named parameters of the matching event.
+type ABIEvent struct {
+	Type      string          `ffstruct:"abievent" json:"type,omitempty"` // must be "event" if set
+	Name      string          `ffstruct:"abievent" json:"name"`
+	Anonymous bool            `ffstruct:"abievent" json:"anonymous,omitempty"`
+	Inputs    []*ABIParameter `ffstruct:"abievent" json:"inputs"`
+}
+
+// ABIParameter is a parameter of an ABIEvent, or an input of a method. Indexed event parameters are decoded
+// from the topics of the log, and all other parameters from the data of the log.
+type ABIParameter struct {
+	Name    string `ffstruct:"abiparameter" json:"name"`
+	Type    string `ffstruct:"abiparameter" json:"type"`
+	Indexed bool   `ffstruct:"abiparameter" json:"indexed,omitempty"`
+}
+
 type EventStreamStatus string
 
 const (
@@ -89,6 +127,84 @@ type EventStreamCheckpoint struct {
 	Listeners map[fftypes.UUID]json.RawMessage `json:"listeners"`
 }
 
+// EventStreamReplay requests re-delivery of the confirmed events in a historical block range, without moving the checkpoint
+type EventStreamReplay struct {
+	FromBlock *fftypes.FFuint64 `json:"fromBlock"`           // the first block to replay (inclusive)
+	ToBlock   *fftypes.FFuint64 `json:"toBlock"`             // the last block to replay (inclusive) - cannot be ahead of the chain head
+	Listeners []*fftypes.UUID   `json:"listeners,omitempty"` // the listeners to replay events for - defaults to all listeners on the stream
+}
+
+type EventStreamReplayResult struct {
+	StreamID  *fftypes.UUID    `json:"streamId"`
+	FromBlock fftypes.FFuint64 `json:"fromBlock"`
+	ToBlock   fftypes.FFuint64 `json:"toBlock"`
+	Events    int              `json:"events"` // the number of events queued for re-delivery (before the filter of the stream is applied)
+}
+
+// DeadLetter is a batch of events that could not be delivered to an event stream, and was skipped
+type DeadLetter struct {
+	ID       *fftypes.UUID       `json:"id"`
+	StreamID *fftypes.UUID       `json:"streamId"`
+	Created  *fftypes.FFTime     `json:"created"`
+	Reason   string              `json:"reason"` // the error from the last delivery attempt
+	Events   []*EventWithContext `json:"events"`
+}
+
+type DeadLetterReplayResult struct {
+	StreamID  *fftypes.UUID `json:"streamId"`
+	Delivered int           `json:"delivered"` // the number of dead letters redelivered, and removed
+	Remaining int           `json:"remaining"` // the number of dead letters left, after the first that failed redelivery
+}
+
+// EventStreamExportVersion is the version of the event stream export document produced by this release
+const EventStreamExportVersion = 1
+
+// EventStreamExport is a portable document of event stream definitions and their listeners, without any IDs or
+// runtime checkpoints, for recreating the streams in another environment
+type EventStreamExport struct {
+	Version  int                      `json:"version"`
+	Exported *fftypes.FFTime          `json:"exported,omitempty"`
+	Streams  []*EventStreamDefinition `json:"streams"`
+}
+
+type EventStreamDefinition struct {
+	EventStream
+	Listeners []*Listener `json:"listeners"`
+}
+
+type EventStreamImportConflict string
+
+const (
+	// EventStreamImportSkip leaves an existing stream with the same name unchanged
+	EventStreamImportSkip EventStreamImportConflict = "skip"
+	// EventStreamImportOverwrite deletes an existing stream with the same name, along with its checkpoint, before creating the imported stream
+	EventStreamImportOverwrite EventStreamImportConflict = "overwrite"
+)
+
+// EventStreamImport recreates the streams in an export document
+type EventStreamImport struct {
+	EventStreamExport
+	OnConflict EventStreamImportConflict `json:"onConflict,omitempty"` // defaults to skip
+	FromBlock  *string                   `json:"fromBlock,omitempty"`  // the block all imported listeners start from, replacing the fromBlock in the document
+}
+
+type EventStreamImportStatus string
+
+const (
+	EventStreamImportCreated     EventStreamImportStatus = "created"
+	EventStreamImportOverwritten EventStreamImportStatus = "overwritten"
+	EventStreamImportSkipped     EventStreamImportStatus = "skipped"
+	EventStreamImportFailed      EventStreamImportStatus = "failed"
+)
+
+type EventStreamImportResult struct {
+	Name      string                  `json:"name"`
+	Status    EventStreamImportStatus `json:"status"`
+	ID        *fftypes.UUID           `json:"id,omitempty"` // the ID of the imported stream, or of the existing stream if it was skipped
+	Listeners int                     `json:"listeners"`    // the number of listeners created on the stream
+	Error     string                  `json:"error,omitempty"`
+}
+
 type WebhookConfig struct {
 	URL                        *string             `ffstruct:"whconfig" json:"url,omitempty"`
 	Headers                    map[string]string   `ffstruct:"whconfig" json:"headers,omitempty"`
@@ -98,7 +214,9 @@ type WebhookConfig struct {
 }
 
 type WebSocketConfig struct {
-	DistributionMode *DistributionMode `ffstruct:"wsconfig" json:"distributionMode,omitempty"`
+	DistributionMode    *DistributionMode   `ffstruct:"wsconfig" json:"distributionMode,omitempty"`
+	NackRedeliveryDelay *fftypes.FFDuration `ffstruct:"wsconfig" json:"nackRedeliveryDelay,omitempty"`
+	MaxRedeliveries     *uint64             `ffstruct:"wsconfig" json:"maxRedeliveries,omitempty"` // 0 for unlimited redeliveries
 }
 
 type Listener struct {
@@ -121,6 +239,132 @@ type ListenerWithStatus struct {
 	ffcapi.EventListenerHWMResponse
 }
 
+// ReadinessStatus is returned by the readiness probe, which only reports ready once startup is complete
+type ReadinessStatus struct {
+	Ready             bool                  `json:"ready"`
+	StreamsRestored   bool                  `json:"streamsRestored"`
+	LastBlock         uint64                `json:"lastBlock"` // highest block received from the block listener, or 0 if none yet
+	LastPolicyLoop    *fftypes.FFTime       `json:"lastPolicyLoop,omitempty"`
+	ConnectorHealthy  bool                  `json:"connectorHealthy"`
+	SubmissionsPaused bool                  `json:"submissionsPaused"` // the emergency stop is set, which does not affect readiness
+	Leader            bool                  `json:"leader"`            // true if this replica runs the policy loop, always true unless leader election is enabled
+	LastHealthCheck   *fftypes.FFTime       `json:"lastHealthCheck,omitempty"`
+	NonceGaps         []*NonceGap           `json:"nonceGaps,omitempty"`        // signers that cannot progress, which does not affect readiness
+	ConnectorBreaker  *CircuitBreakerStatus `json:"connectorBreaker,omitempty"` // only set when the circuit breaker is enabled - not ready while it is open
+}
+
+// SubmissionControl is the persisted emergency stop for submissions. While paused the policy loop does not
+// send anything to the blockchain, but new transactions are accepted and confirmations are still processed.
+type SubmissionControl struct {
+	Paused  bool            `json:"paused"`
+	Updated *fftypes.FFTime `json:"updated,omitempty"`
+}
+
+type CircuitBreakerState string
+
+const (
+	CircuitBreakerClosed   CircuitBreakerState = "closed"
+	CircuitBreakerOpen     CircuitBreakerState = "open"
+	CircuitBreakerHalfOpen CircuitBreakerState = "halfOpen"
+)
+
+// CircuitBreakerStatus is the state of the circuit breaker around the blockchain connector
+type CircuitBreakerStatus struct {
+	State               CircuitBreakerState `json:"state"`
+	ConsecutiveFailures int                 `json:"consecutiveFailures"`
+	OpenUntil           *fftypes.FFTime     `json:"openUntil,omitempty"` // when an open breaker next lets a call through, to check if the connector has recovered
+}
+
+// ConnectorInfo is the name, version and capabilities reported by the blockchain connector, as cached by the manager
+type ConnectorInfo struct {
+	ffcapi.ConnectorInfoResponse
+	LastRefresh *fftypes.FFTime `json:"lastRefresh"`
+}
+
+// NonceStatus is the nonce allocation state for a signing address, for debugging nonce management
+type NonceStatus struct {
+	Signer         string            `json:"signer"`
+	Connector      string            `json:"connector,omitempty"` // not set for the default connector
+	Locked         bool              `json:"locked"`
+	LockedBy       string            `json:"lockedBy,omitempty"`    // ID of the transaction holding the lock
+	LockedAt       *fftypes.FFTime   `json:"lockedAt,omitempty"`    // when the lock was acquired
+	LockedNonce    *fftypes.FFBigInt `json:"lockedNonce,omitempty"` // not set while the nonce is still being allocated
+	ChainNextNonce *fftypes.FFBigInt `json:"chainNextNonce,omitempty"`
+	Pending        int               `json:"pending"` // pending transactions waiting outside of the in-flight set
+}
+
+// BackpressureStatus reports how close the backlog of in-flight and pending transactions is to the threshold at
+// which submissions are rejected, so clients can throttle themselves before they are rejected
+type BackpressureStatus struct {
+	Mode        string                      `json:"mode"` // queue or reject
+	Threshold   int                         `json:"threshold"`
+	Backlog     int                         `json:"backlog"`     // in-flight and pending transactions across all signers
+	Utilization float64                     `json:"utilization"` // the backlog as a fraction of the threshold
+	InFlight    int                         `json:"inFlight"`
+	MaxInFlight int                         `json:"maxInFlight"`
+	Signers     []*SignerBackpressureStatus `json:"signers"` // for each signer with its own backpressure policy
+}
+
+// SignerBackpressureStatus reports the backlog of a signer with its own backpressure policy
+type SignerBackpressureStatus struct {
+	Signer      string  `json:"signer"`
+	Mode        string  `json:"mode"`
+	Threshold   int     `json:"threshold"`
+	Backlog     int     `json:"backlog"`
+	Utilization float64 `json:"utilization"`
+	InFlight    int     `json:"inFlight"`
+}
+
+// TransactionStats reports totals across all transactions, for dashboards that need them without paging through
+// every transaction. The submission and confirmation figures only cover transactions submitted within the window.
+type TransactionStats struct {
+	ByStatus            map[TxStatus]int    `json:"byStatus"`
+	InFlight            int                 `json:"inFlight"`
+	Pending             int                 `json:"pending"` // pending transactions waiting for space in the in-flight set
+	Window              *fftypes.FFDuration `json:"window"`
+	Submitted           int                 `json:"submitted"`                     // transactions submitted within the window
+	Confirmed           int                 `json:"confirmed"`                     // transactions submitted within the window that have since succeeded
+	AvgConfirmationTime *fftypes.FFDuration `json:"avgConfirmationTime,omitempty"` // from first submission to the blockchain until confirmed, not set if none are confirmed
+}
+
+// NonceRefresh is the result of realigning the next nonce allocated for a signer with the blockchain
+type NonceRefresh struct {
+	Signer         string            `json:"signer"`
+	Connector      string            `json:"connector,omitempty"`
+	OldNextNonce   *fftypes.FFBigInt `json:"oldNextNonce"` // the next nonce that would have been allocated before the refresh
+	NewNextNonce   *fftypes.FFBigInt `json:"newNextNonce"` // the next nonce that will be allocated after the refresh
+	ChainNextNonce *fftypes.FFBigInt `json:"chainNextNonce"`
+}
+
+// NonceReservation is a nonce allocated to a signer for a transaction signed outside of FFTM. The nonce is not
+// assigned to any other transaction until the signed transaction is submitted with the reservation ID, or the
+// reservation expires - after which the nonce is assigned to the next transaction for the signer.
+// Reservations are held in memory, so do not survive a restart.
+type NonceReservation struct {
+	ID      *fftypes.UUID     `json:"id"`
+	Signer  string            `json:"signer"`
+	Nonce   *fftypes.FFBigInt `json:"nonce"`
+	Created *fftypes.FFTime   `json:"created"`
+	Expiry  *fftypes.FFTime   `json:"expiry"`
+}
+
+// NonceReservationRequest is the optional payload of a nonce reservation
+type NonceReservationRequest struct {
+	TTL *fftypes.FFDuration `json:"ttl,omitempty"` // optional - overrides the configured reservation ttl
+}
+
+// NonceGap reports a signer with a nonce that is neither in-flight nor mined, so none of the
+// transactions from the signer at higher nonces can be mined until it is filled
+type NonceGap struct {
+	Signer         string            `json:"signer"`
+	Connector      string            `json:"connector,omitempty"`
+	MissingNonce   *fftypes.FFBigInt `json:"missingNonce"`   // the lowest missing nonce
+	MissingCount   int               `json:"missingCount"`   // the number of missing nonces up to the highest nonce
+	ChainNextNonce *fftypes.FFBigInt `json:"chainNextNonce"` // the next nonce the blockchain reports for the signer
+	HighestNonce   *fftypes.FFBigInt `json:"highestNonce"`   // the highest nonce assigned locally to the signer
+	Detected       *fftypes.FFTime   `json:"detected"`
+}
+
 // CheckUpdateString helper merges supplied configuration, with a base, and applies a default if unset
 func CheckUpdateString(changed bool, merged **string, old *string, new *string, defValue string) bool {
 	if new != nil {
@@ -203,7 +447,7 @@ func CheckUpdateStringMap(changed bool, merged *map[string]string, old map[strin
 		changed = changed || (old == nil)
 	} else {
 		*merged = old
-		return false // new was nil, we cannot have changed
+		return changed // new was nil, so the map has not changed
 	}
 	if changed {
 		return true
@@ -215,9 +459,12 @@ func CheckUpdateStringMap(changed bool, merged *map[string]string, old map[strin
 }
 
 type EventContext struct {
-	StreamID       *fftypes.UUID `json:"streamId"`     // the ID of the event stream for this event
-	EthCompatSubID *fftypes.UUID `json:"subId"`        // ID of the listener - EthCompat "subscription" naming
-	ListenerName   string        `json:"listenerName"` // name of the listener
+	StreamID       *fftypes.UUID `json:"streamId"`               // the ID of the event stream for this event
+	EthCompatSubID *fftypes.UUID `json:"subId"`                  // ID of the listener - EthCompat "subscription" naming
+	ListenerName   string        `json:"listenerName"`           // name of the listener
+	RolledBack     bool          `json:"rolledBack,omitempty"`   // set when a previously delivered event has been removed from the chain by a re-org
+	DecodeFailed   bool          `json:"decodeFailed,omitempty"` // set when the stream has event ABI, but the event could not be decoded using it
+	Replayed       bool          `json:"replayed,omitempty"`     // set when the event is a re-delivery requested with a replay, rather than live delivery
 }
 
 // EventWithContext is what is delivered
@@ -227,6 +474,7 @@ type EventContext struct {
 type EventWithContext struct {
 	StandardContext EventContext
 	ffcapi.Event
+	Decoded *fftypes.JSONAny // the named parameters of the event, when decoded using the event ABI of the stream
 }
 
 func (e *EventWithContext) MarshalJSON() ([]byte, error) {
@@ -237,6 +485,9 @@ func (e *EventWithContext) MarshalJSON() ([]byte, error) {
 	jsonmap.AddJSONFieldsToMap(reflect.ValueOf(&e.ID), m)
 	jsonmap.AddJSONFieldsToMap(reflect.ValueOf(&e.StandardContext), m)
 	m["data"] = e.Data
+	if e.Decoded != nil {
+		m["decoded"] = e.Decoded
+	}
 	return json.Marshal(m)
 }
 
@@ -176,6 +176,10 @@ func TestCheckUpdateStringMap(t *testing.T) {
 	changed = CheckUpdateStringMap(false, &pVal3, val1, nil)
 	assert.Equal(t, map[string]string{"key1": "val1"}, pVal3) // val1 won
 	assert.False(t, changed)                                  // which was the current value
+
+	changed = CheckUpdateStringMap(true, &pVal3, val1, nil)
+	assert.Equal(t, map[string]string{"key1": "val1"}, pVal3)
+	assert.True(t, changed) // because it was already changed
 }
 
 func TestMarshalUnmarshalEventOK(t *testing.T) {
@@ -252,3 +256,20 @@ func TestUnmarshalFail(t *testing.T) {
 	assert.Error(t, err)
 
 }
+
+func TestNonceKey(t *testing.T) {
+	assert.Equal(t, "0xaaaaa", NonceKey("", "0xaaaaa"))
+	assert.Equal(t, "chain2/0xaaaaa", NonceKey("chain2", "0xaaaaa"))
+	mtx := &ManagedTX{Connector: "chain2", TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa"}}
+	assert.Equal(t, "chain2/0xaaaaa", mtx.NonceKey())
+}
+
+func TestFailureCodeForReason(t *testing.T) {
+	assert.Equal(t, TxFailureReverted, FailureCodeForReason(ffcapi.ErrorReasonTransactionReverted, TxFailureUnknown))
+	assert.Equal(t, TxFailureNonceTooLow, FailureCodeForReason(ffcapi.ErrorReasonNonceTooLow, TxFailureUnknown))
+	assert.Equal(t, TxFailureInsufficientFunds, FailureCodeForReason(ffcapi.ErrorReasonInsufficientFunds, TxFailureUnknown))
+	assert.Equal(t, TxFailureUnderpriced, FailureCodeForReason(ffcapi.ErrorReasonTransactionUnderpriced, TxFailureUnknown))
+	assert.Equal(t, TxFailureInvalidInputs, FailureCodeForReason(ffcapi.ErrorReasonInvalidInputs, TxFailureUnknown))
+	assert.Equal(t, TxFailureUnknown, FailureCodeForReason(ffcapi.ErrorReasonNotFound, TxFailureUnknown))
+	assert.Equal(t, TxFailureSubmissionTimeout, FailureCodeForReason("", TxFailureSubmissionTimeout))
+}
@@ -16,7 +16,11 @@
 
 package apitypes
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
 
 // BaseRequest is the common headers to all requests, and captures the full input payload for later decoding to a specific type
 type BaseRequest struct {
@@ -38,14 +42,46 @@ func (br *BaseRequest) UnmarshalTo(o interface{}) error {
 }
 
 type RequestHeaders struct {
-	ID   string      `ffstruct:"fftmrequest" json:"id"`
-	Type RequestType `json:"type"`
+	ID                   string              `ffstruct:"fftmrequest" json:"id"`
+	Type                 RequestType         `json:"type"`
+	PolicyEngine         string              `json:"policyEngine,omitempty"`         // optional - the default policy engine is used if not set
+	Connector            string              `json:"connector,omitempty"`            // optional - the named connector for the chain to submit to, with the default connector used if not set
+	KeyName              string              `json:"keyName,omitempty"`              // optional - a key name the key manager resolves to the from address, in place of setting the from address
+	CompletionCallback   string              `json:"completionCallback,omitempty"`   // optional - URL to POST the transaction to, once it is confirmed or has failed
+	ConfirmationWebhook  string              `json:"confirmationWebhook,omitempty"`  // optional - URL to POST a signed notification to, as the transaction reaches each confirmation milestone
+	Milestones           []int               `json:"milestones,omitempty"`           // optional - the confirmation depths to notify the confirmation webhook at, overriding the configured milestones
+	SubmissionTimeout    *fftypes.FFDuration `json:"submissionTimeout,omitempty"`    // optional - overrides the configured submission timeout, with 0 disabling it
+	IdempotencyKey       string              `json:"idempotencyKey,omitempty"`       // optional - a repeat submission with the same key for the same signer returns the existing transaction
+	NotBefore            *fftypes.FFTime     `json:"notBefore,omitempty"`            // optional - the transaction is not submitted before this time. Later nonces for the same signer cannot be mined until it is
+	DependsOn            string              `json:"dependsOn,omitempty"`            // optional - ID of a transaction that must succeed before this one is submitted. Later nonces for the same signer cannot be mined until it is
+	Expiry               *fftypes.FFTime     `json:"expiry,omitempty"`               // optional - if the transaction is not mined by this time, it is cancelled by replacing it with a no-op at the same nonce
+	RequestID            string              `json:"requestId,omitempty"`            // optional - caller supplied ID for correlation with the originating request, generated if not set
+	Priority             int                 `json:"priority,omitempty"`             // optional - higher priority transactions are moved into the in-flight set first. Later nonces for the same signer raise the priority of earlier ones
+	GasLimitMultiplier   float64             `json:"gasLimitMultiplier,omitempty"`   // optional - multiplies the estimated gas limit, up to the configured block gas limit. Not applied if an explicit gas limit is supplied
+	ConfirmationStrategy string              `json:"confirmationStrategy,omitempty"` // optional - named confirmation strategy (fast, safe or finalized), overriding the configured number of confirmations
+	Labels               map[string]string   `json:"labels,omitempty"`               // optional - key/value labels to categorize the transaction, which can be used to filter transaction queries
+	Simulate             *bool               `json:"simulate,omitempty"`             // optional - whether to simulate the transaction before the first submission, failing it without submission if the simulation reverts. Overrides the policy engine configuration
 }
 
+// IdempotencyKeyHeader can be set on a submission, as an alternative to the idempotencyKey request header field
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RequestIDHeader can be set on a submission, as an alternative to the requestId request header field
+const RequestIDHeader = "X-Request-ID"
+
+// CompletionCallbackDeliveryIDHeader is set on every attempt to deliver a completion callback,
+// with the same value for each attempt, so the receiver can discard duplicate deliveries
+const CompletionCallbackDeliveryIDHeader = "X-FFTM-Delivery-ID"
+
+// ConfirmationWebhookSignatureHeader is set on every confirmation webhook delivery, to "sha256=" followed by the
+// hex encoded HMAC-SHA256 of the request body, using the configured secret
+const ConfirmationWebhookSignatureHeader = "X-FFTM-Signature"
+
 type RequestType string
 
 const (
 	RequestTypeSendTransaction RequestType = "SendTransaction"
 	RequestTypeQuery           RequestType = "Query"
 	RequestTypeDeploy          RequestType = "DeployContract"
+	RequestTypeSignTypedData   RequestType = "SignTypedData"
 )
@@ -32,8 +32,181 @@ const (
 	TxStatusSucceeded TxStatus = "Succeeded"
 	// TxStatusFailed happens when an error is reported by the infrastructure runtime
 	TxStatusFailed TxStatus = "Failed"
+	// TxStatusScheduled indicates the operation has a nonce assigned, but will not be submitted until its notBefore time
+	TxStatusScheduled TxStatus = "Scheduled"
+	// TxStatusWouldSubmit is set in dry-run mode, when the policy engine attempted to submit the operation but the submission was intercepted
+	TxStatusWouldSubmit TxStatus = "WouldSubmit"
+	// TxStatusDeleted indicates deletion of the operation completed, and it is retained until the deleted retention period passes
+	TxStatusDeleted TxStatus = "Deleted"
 )
 
+// TxAction is a significant action recorded in the history of a transaction
+type TxAction string
+
+const (
+	// TxActionSubmitted the transaction was submitted to the blockchain for the first time
+	TxActionSubmitted TxAction = "Submitted"
+	// TxActionResubmitted the transaction was submitted to the blockchain again
+	TxActionResubmitted TxAction = "Resubmitted"
+	// TxActionGasPriceChanged the policy engine changed the gas price of the transaction
+	TxActionGasPriceChanged TxAction = "GasPriceChanged"
+	// TxActionPolicyError the policy engine returned an error processing the transaction
+	TxActionPolicyError TxAction = "PolicyError"
+	// TxActionDeleteRequested deletion of the transaction was requested
+	TxActionDeleteRequested TxAction = "DeleteRequested"
+	// TxActionDeleted the transaction was deleted, and is retained so that it can be restored
+	TxActionDeleted TxAction = "Deleted"
+	// TxActionUndeleted the deleted transaction was restored
+	TxActionUndeleted TxAction = "Undeleted"
+	// TxActionConfirmed the transaction was confirmed as successful
+	TxActionConfirmed TxAction = "Confirmed"
+	// TxActionFailed the transaction was confirmed as failed
+	TxActionFailed TxAction = "Failed"
+	// TxActionSubmissionTimeout the transaction was marked as failed, as it was not mined within the submission timeout
+	TxActionSubmissionTimeout TxAction = "SubmissionTimeout"
+	// TxActionDependencyFailed the transaction was marked as failed, as the transaction it depends on did not succeed
+	TxActionDependencyFailed TxAction = "DependencyFailed"
+	// TxActionScheduleReached the notBefore time of a scheduled transaction passed, and it was released for submission
+	TxActionScheduleReached TxAction = "ScheduleReached"
+	// TxActionWouldSubmit the policy engine attempted to submit the transaction in dry-run mode, and the submission was intercepted
+	TxActionWouldSubmit TxAction = "WouldSubmit"
+	// TxActionRetriesExhausted the transaction was marked as failed, as the policy engine continued to return errors beyond the configured limit
+	TxActionRetriesExhausted TxAction = "RetriesExhausted"
+	// TxActionHistorySummary older entries were collapsed into this single entry, to bound the length of the history
+	TxActionHistorySummary TxAction = "HistorySummary"
+	// TxActionCancelRequested cancellation of the transaction was requested
+	TxActionCancelRequested TxAction = "CancelRequested"
+	// TxActionCancelSubmitted a no-op transaction was submitted at the nonce of the transaction, to replace it
+	TxActionCancelSubmitted TxAction = "CancelSubmitted"
+	// TxActionCancelled the no-op transaction was mined at the nonce of the transaction, so it was cancelled
+	TxActionCancelled TxAction = "Cancelled"
+	// TxActionRevertRetry the connector reported the submission as reverted, and it will be retried after a delay
+	TxActionRevertRetry TxAction = "RevertRetry"
+	// TxActionExpired the transaction was not mined before its expiry, so it is being cancelled - or failed, if it was never submitted
+	TxActionExpired TxAction = "Expired"
+)
+
+// GasLimitSource records how the gas limit of a transaction was determined
+type GasLimitSource string
+
+const (
+	// GasLimitSourceExplicit the gas limit was supplied on submission, and estimation was skipped
+	GasLimitSourceExplicit GasLimitSource = "explicit"
+	// GasLimitSourceEstimated the gas limit was estimated by the connector
+	GasLimitSourceEstimated GasLimitSource = "estimated"
+	// GasLimitSourceMultiplied the gas limit was estimated by the connector, then multiplied by the gasLimitMultiplier of the submission
+	GasLimitSourceMultiplied GasLimitSource = "multiplied"
+)
+
+// TxTrace is the revert reason and execution trace of a transaction, as returned by the connector
+type TxTrace struct {
+	Supported    bool             `json:"supported"` // false if the connector does not support tracing transactions
+	RevertReason string           `json:"revertReason,omitempty"`
+	Trace        *fftypes.JSONAny `json:"trace,omitempty"`
+	Fetched      *fftypes.FFTime  `json:"fetched,omitempty"`
+}
+
+// CancelOutcome reports which of a cancelled transaction, and the no-op replacing it, was mined
+type CancelOutcome string
+
+const (
+	// CancelOutcomeCancelled the no-op was mined, so the original transaction can never be mined
+	CancelOutcomeCancelled CancelOutcome = "cancelled"
+	// CancelOutcomeConfirmed the original transaction was mined before the no-op
+	CancelOutcomeConfirmed CancelOutcome = "confirmed"
+)
+
+// ManagedTXCancel records a request to cancel a submitted transaction. The policy engine cancels a transaction
+// by submitting a no-op transaction at the same nonce, with a higher gas price, until one of the two is mined.
+type ManagedTXCancel struct {
+	Requested       *fftypes.FFTime                    `json:"requested"`
+	TransactionHash string                             `json:"transactionHash,omitempty"` // the hash of the most recent submission of the no-op
+	GasPrice        *fftypes.JSONAny                   `json:"gasPrice,omitempty"`
+	FirstSubmit     *fftypes.FFTime                    `json:"firstSubmit,omitempty"`
+	LastSubmit      *fftypes.FFTime                    `json:"lastSubmit,omitempty"`
+	Receipt         *ffcapi.TransactionReceiptResponse `json:"receipt,omitempty"` // the receipt of the no-op, if it was mined
+	Outcome         CancelOutcome                      `json:"outcome,omitempty"` // set once either the original or the no-op is confirmed
+	Expired         bool                               `json:"expired,omitempty"` // true if cancellation was triggered by the expiry of the transaction, rather than requested
+}
+
+// TxHistoryEntry is a timestamped record of an action in the history of a transaction
+type TxHistoryEntry struct {
+	Time   *fftypes.FFTime `json:"time"`
+	Action TxAction        `json:"action"`
+	Info   string          `json:"info,omitempty"`
+	Count  int             `json:"count,omitempty"` // for a summary entry, the number of entries it replaced
+}
+
+// TxFailureCode is a stable, machine-readable code describing why a transaction reached the Failed state
+type TxFailureCode string
+
+const (
+	// TxFailureReverted the transaction was mined, but execution reverted
+	TxFailureReverted TxFailureCode = "Reverted"
+	// TxFailureNonceTooLow the nonce of the transaction was already used by another transaction on the chain
+	TxFailureNonceTooLow TxFailureCode = "NonceTooLow"
+	// TxFailureInsufficientFunds the signing account did not have the funds to pay for the transaction
+	TxFailureInsufficientFunds TxFailureCode = "InsufficientFunds"
+	// TxFailureUnderpriced the gas price of the transaction was too low to be accepted
+	TxFailureUnderpriced TxFailureCode = "Underpriced"
+	// TxFailureInvalidInputs the connector could not process the inputs of the transaction
+	TxFailureInvalidInputs TxFailureCode = "InvalidInputs"
+	// TxFailureSubmissionTimeout the transaction was not mined within the submission timeout, with no more specific error reported
+	TxFailureSubmissionTimeout TxFailureCode = "SubmissionTimeout"
+	// TxFailureDependencyFailed the transaction was not submitted, as the transaction it depends on did not succeed
+	TxFailureDependencyFailed TxFailureCode = "DependencyFailed"
+	// TxFailureRetriesExhausted the policy engine continued to return errors for the transaction beyond the configured limit, with no more specific error reported
+	TxFailureRetriesExhausted TxFailureCode = "RetriesExhausted"
+	// TxFailureCancelled the transaction was cancelled, by a no-op transaction being mined at its nonce
+	TxFailureCancelled TxFailureCode = "Cancelled"
+	// TxFailureExpired the transaction was not mined before its expiry, and was cancelled (or never submitted)
+	TxFailureExpired TxFailureCode = "Expired"
+	// TxFailureUnknown the failure could not be mapped to a more specific code
+	TxFailureUnknown TxFailureCode = "Unknown"
+)
+
+// FailureCodeForReason maps a connector error reason to the failure code reported on a transaction,
+// returning the supplied default when the reason does not map to a more specific code
+func FailureCodeForReason(reason ffcapi.ErrorReason, def TxFailureCode) TxFailureCode {
+	switch reason {
+	case ffcapi.ErrorReasonTransactionReverted:
+		return TxFailureReverted
+	case ffcapi.ErrorReasonNonceTooLow:
+		return TxFailureNonceTooLow
+	case ffcapi.ErrorReasonInsufficientFunds:
+		return TxFailureInsufficientFunds
+	case ffcapi.ErrorReasonTransactionUnderpriced:
+		return TxFailureUnderpriced
+	case ffcapi.ErrorReasonInvalidInputs:
+		return TxFailureInvalidInputs
+	default:
+		return def
+	}
+}
+
+// ManagedTXFailure is the terminal failure record of a transaction, for programmatic handling by clients
+type ManagedTXFailure struct {
+	Time   *fftypes.FFTime    `json:"time"`
+	Code   TxFailureCode      `json:"code"`
+	Reason ffcapi.ErrorReason `json:"reason,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// NonceKey returns the key nonces are allocated under for a signer on the named connector. Each chain has its
+// own nonces, so the key for a signer on any connector other than the default includes the connector name.
+// The key for a signer on the default connector is the signer, so existing state is unaffected.
+func NonceKey(connector, signer string) string {
+	if connector == "" {
+		return signer
+	}
+	return connector + "/" + signer
+}
+
+// NonceKey returns the key the nonce of the transaction is allocated under
+func (mtx *ManagedTX) NonceKey() string {
+	return NonceKey(mtx.Connector, mtx.TransactionHeaders.From)
+}
+
 type ManagedTXError struct {
 	Time   *fftypes.FFTime    `json:"time"`
 	Error  string             `json:"error,omitempty"`
@@ -57,25 +230,97 @@ type ManagedTXError struct {
 //   - When listing back entries, the persistence layer will automatically clean up indexes if the underlying
 //     TX they refer to is not available. For this reason the index records are written first.
 type ManagedTX struct {
-	ID                 string                             `json:"id"`
-	Created            *fftypes.FFTime                    `json:"created"`
-	Updated            *fftypes.FFTime                    `json:"updated"`
-	Status             TxStatus                           `json:"status"`
-	DeleteRequested    *fftypes.FFTime                    `json:"deleteRequested,omitempty"`
-	SequenceID         *fftypes.UUID                      `json:"sequenceId"`
-	Nonce              *fftypes.FFBigInt                  `json:"nonce"`
-	Gas                *fftypes.FFBigInt                  `json:"gas"`
-	TransactionHeaders ffcapi.TransactionHeaders          `json:"transactionHeaders"`
-	TransactionData    string                             `json:"transactionData"`
-	TransactionHash    string                             `json:"transactionHash,omitempty"`
-	GasPrice           *fftypes.JSONAny                   `json:"gasPrice"`
-	PolicyInfo         *fftypes.JSONAny                   `json:"policyInfo"`
-	FirstSubmit        *fftypes.FFTime                    `json:"firstSubmit,omitempty"`
-	LastSubmit         *fftypes.FFTime                    `json:"lastSubmit,omitempty"`
-	Receipt            *ffcapi.TransactionReceiptResponse `json:"receipt,omitempty"`
-	ErrorMessage       string                             `json:"errorMessage,omitempty"`
-	ErrorHistory       []*ManagedTXError                  `json:"errorHistory"`
-	Confirmations      []confirmations.BlockInfo          `json:"confirmations,omitempty"`
+	ID                    string                             `json:"id"`
+	Created               *fftypes.FFTime                    `json:"created"`
+	Updated               *fftypes.FFTime                    `json:"updated"`
+	Status                TxStatus                           `json:"status"`
+	DeleteRequested       *fftypes.FFTime                    `json:"deleteRequested,omitempty"`
+	Deleted               *fftypes.FFTime                    `json:"deleted,omitempty"`       // set while a deleted transaction is retained, and can be restored
+	DeletedStatus         TxStatus                           `json:"deletedStatus,omitempty"` // the status a deleted transaction is restored to
+	SequenceID            *fftypes.UUID                      `json:"sequenceId"`
+	Nonce                 *fftypes.FFBigInt                  `json:"nonce"`
+	Gas                   *fftypes.FFBigInt                  `json:"gas"`
+	GasLimitSource        GasLimitSource                     `json:"gasLimitSource,omitempty"`
+	TransactionHeaders    ffcapi.TransactionHeaders          `json:"transactionHeaders"`
+	TransactionData       string                             `json:"transactionData"`
+	RawTransaction        string                             `json:"rawTransaction,omitempty"` // set for a transaction signed outside of FFTM, which is submitted unchanged
+	DeployContract        bool                               `json:"deployContract,omitempty"` // a contract creation, with no "to" address and the contract as the transaction data
+	TransactionHash       string                             `json:"transactionHash,omitempty"`
+	GasPrice              *fftypes.JSONAny                   `json:"gasPrice"`
+	PolicyEngine          string                             `json:"policyEngine,omitempty"`
+	Connector             string                             `json:"connector,omitempty"` // the named connector the transaction is submitted to, or empty for the default connector
+	KeyName               string                             `json:"keyName,omitempty"`   // the key name the signing address was resolved from, if the submission referenced one
+	CompletionCallback    string                             `json:"completionCallback,omitempty"`
+	ConfirmationWebhook   string                             `json:"confirmationWebhook,omitempty"`
+	Milestones            []int                              `json:"milestones,omitempty"` // the confirmation depths the confirmation webhook is notified at
+	SubmissionTimeout     *fftypes.FFDuration                `json:"submissionTimeout,omitempty"`
+	NotBefore             *fftypes.FFTime                    `json:"notBefore,omitempty"`
+	Expiry                *fftypes.FFTime                    `json:"expiry,omitempty"`
+	DependsOn             string                             `json:"dependsOn,omitempty"`
+	ConfirmationStrategy  string                             `json:"confirmationStrategy,omitempty"`
+	IdempotencyKey        string                             `json:"idempotencyKey,omitempty"`
+	RequestID             string                             `json:"requestId,omitempty"`
+	Labels                map[string]string                  `json:"labels,omitempty"`
+	Simulate              *bool                              `json:"simulate,omitempty"`    // overrides whether the policy engine simulates the transaction before the first submission
+	TraceParent           string                             `json:"traceParent,omitempty"` // W3C trace context of the submission, when tracing is enabled
+	Priority              int                                `json:"priority"`
+	PolicyInfo            *fftypes.JSONAny                   `json:"policyInfo"`
+	FirstSubmit           *fftypes.FFTime                    `json:"firstSubmit,omitempty"`
+	LastSubmit            *fftypes.FFTime                    `json:"lastSubmit,omitempty"`
+	Receipt               *ffcapi.TransactionReceiptResponse `json:"receipt,omitempty"`
+	ContractLocation      *fftypes.JSONAny                   `json:"contractLocation,omitempty"` // the deployed contract, once a contract creation succeeds
+	ErrorMessage          string                             `json:"errorMessage,omitempty"`
+	Failure               *ManagedTXFailure                  `json:"failure,omitempty"`
+	ErrorHistory          []*ManagedTXError                  `json:"errorHistory"`
+	History               []*TxHistoryEntry                  `json:"history,omitempty"`
+	Confirmations         []confirmations.BlockInfo          `json:"confirmations,omitempty"`
+	Trace                 *TxTrace                           `json:"trace,omitempty"`        // cached once fetched for a completed transaction
+	Cancel                *ManagedTXCancel                   `json:"cancel,omitempty"`       // set once cancellation is requested
+	DecodedInput          *DecodedInput                      `json:"decodedInput,omitempty"` // decoded from the calldata, when the method ABI was supplied on submission
+	ConfirmationCount     int                                `json:"confirmationCount"`      // populated when the transaction is queried
+	ConfirmationsRequired int                                `json:"confirmationsRequired"`  // populated when the transaction is queried
+}
+
+// DecodedInput is the method name and named arguments of a transaction, decoded from its calldata using
+// the method ABI supplied when the transaction was submitted.
+type DecodedInput struct {
+	Method    string           `json:"method"`
+	Signature string           `json:"signature"` // the 4 byte method selector
+	Args      *fftypes.JSONAny `json:"args"`
+}
+
+// TxConfirmations reports the progress of a transaction towards the required number of confirmations.
+// A transaction that has not yet been mined has mined=false, and zero confirmations.
+type TxConfirmations struct {
+	Mined         bool                      `json:"mined"`
+	Confirmed     bool                      `json:"confirmed"`
+	BlockNumber   *fftypes.FFBigInt         `json:"blockNumber,omitempty"`
+	BlockHash     string                    `json:"blockHash,omitempty"`
+	Count         int                       `json:"count"`
+	Required      int                       `json:"required"`           // zero when waiting for finality
+	Strategy      string                    `json:"strategy,omitempty"` // the named confirmation strategy of the transaction, if set
+	Confirmations []confirmations.BlockInfo `json:"confirmations"`
+}
+
+// ConfirmationMilestone is the payload delivered to the confirmation webhook of a transaction, each time it reaches
+// one of its confirmation milestones
+type ConfirmationMilestone struct {
+	ID              string                    `json:"id"`
+	RequestID       string                    `json:"requestId,omitempty"`
+	TransactionHash string                    `json:"transactionHash"`
+	BlockNumber     *fftypes.FFBigInt         `json:"blockNumber,omitempty"`
+	BlockHash       string                    `json:"blockHash,omitempty"`
+	Milestone       int                       `json:"milestone"`
+	Confirmations   []confirmations.BlockInfo `json:"confirmations"`
+}
+
+// TxStatusSummary is the slim status of a transaction, returned for each ID in a bulk status lookup
+type TxStatusSummary struct {
+	Found                 bool     `json:"found"` // false if there is no transaction with the ID
+	Status                TxStatus `json:"status,omitempty"`
+	TransactionHash       string   `json:"transactionHash,omitempty"` // the hash the transaction was last submitted with
+	ConfirmationCount     int      `json:"confirmationCount"`
+	ConfirmationsRequired int      `json:"confirmationsRequired"`
 }
 
 type ReplyType string
@@ -84,6 +329,7 @@ const (
 	TransactionUpdate        ReplyType = "TransactionUpdate"
 	TransactionUpdateSuccess ReplyType = "TransactionSuccess"
 	TransactionUpdateFailure ReplyType = "TransactionFailure"
+	PolicyDecision           ReplyType = "PolicyDecision"
 )
 
 type ReplyHeaders struct {
@@ -98,3 +344,23 @@ type TransactionUpdateReply struct {
 	Headers ReplyHeaders `json:"headers"`
 	ManagedTX
 }
+
+// PolicyDecisionsTopic is the WebSocket topic reserved for policy decision events, so it cannot be
+// used as the name of an event stream
+const PolicyDecisionsTopic = "_policy_decisions"
+
+// PolicyDecisionEvent is broadcast on the PolicyDecisionsTopic for each action recorded against a transaction,
+// such as a submission, a change of gas price, or a failure. Delivery is best-effort, and events are only
+// produced while a WebSocket connection is listening on the topic.
+type PolicyDecisionEvent struct {
+	Headers         ReplyHeaders      `json:"headers"`
+	TransactionID   string            `json:"transactionId"`
+	From            string            `json:"from"`
+	Nonce           *fftypes.FFBigInt `json:"nonce,omitempty"`
+	Action          TxAction          `json:"action"`
+	TransactionHash string            `json:"transactionHash,omitempty"`
+	OldGasPrice     *fftypes.JSONAny  `json:"oldGasPrice,omitempty"`
+	NewGasPrice     *fftypes.JSONAny  `json:"newGasPrice,omitempty"`
+	Reason          string            `json:"reason,omitempty"` // the info recorded in the history, such as the error message of a failure
+	Time            *fftypes.FFTime   `json:"time"`
+}
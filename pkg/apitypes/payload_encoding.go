@@ -0,0 +1,94 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apitypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// PayloadContentType returns the MIME type of payloads in the encoding
+func PayloadContentType(encoding PayloadEncoding) string {
+	if encoding == PayloadEncodingMsgpack {
+		return "application/msgpack"
+	}
+	return "application/json"
+}
+
+// MarshalPayload encodes a payload, such as a batch of events. The msgpack encoding is the same document as
+// the JSON encoding, with the same field names and values, so consumers can switch between them without any
+// change to how they process the payload.
+func MarshalPayload(encoding PayloadEncoding, v interface{}) ([]byte, error) {
+	jsonPayload, err := json.Marshal(v)
+	if err != nil || encoding != PayloadEncodingMsgpack {
+		return jsonPayload, err
+	}
+	// Go through the generic form of the JSON document, so any custom JSON serialization is preserved
+	d := json.NewDecoder(bytes.NewReader(jsonPayload))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(msgpackValue(doc))
+}
+
+// UnmarshalPayload decodes a payload encoded by MarshalPayload
+func UnmarshalPayload(encoding PayloadEncoding, data []byte, v interface{}) error {
+	if encoding != PayloadEncodingMsgpack {
+		return json.Unmarshal(data, v)
+	}
+	var doc interface{}
+	if err := msgpack.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	jsonPayload, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonPayload, v)
+}
+
+// msgpackValue converts the numbers in a generic JSON document to msgpack integers where possible,
+// so large integers do not lose precision as floats
+func msgpackValue(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		for k, e := range vt {
+			vt[k] = msgpackValue(e)
+		}
+	case []interface{}:
+		for i, e := range vt {
+			vt[i] = msgpackValue(e)
+		}
+	case json.Number:
+		if i, err := vt.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(vt.String(), 10, 64); err == nil {
+			return u
+		}
+		if f, err := vt.Float64(); err == nil {
+			return f
+		}
+		return vt.String()
+	}
+	return v
+}
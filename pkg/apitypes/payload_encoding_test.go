@@ -0,0 +1,146 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apitypes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testEventInfo struct {
+	Address  string  `json:"address"`
+	Ratio    float64 `json:"ratio"`
+	Negative int     `json:"negative"`
+}
+
+func testEventBatch() []*EventWithContext {
+	return []*EventWithContext{
+		{
+			StandardContext: EventContext{
+				StreamID:       fftypes.NewUUID(),
+				EthCompatSubID: fftypes.NewUUID(),
+				ListenerName:   "listener1",
+				Replayed:       true,
+			},
+			Event: ffcapi.Event{
+				ID: ffcapi.EventID{
+					ListenerID:       fftypes.NewUUID(),
+					Signature:        "Transfer(address,address,uint256)",
+					BlockHash:        "0x12345",
+					BlockNumber:      fftypes.FFuint64(18446744073709551615),
+					TransactionHash:  "0x23456",
+					TransactionIndex: 1,
+					LogIndex:         2,
+					Timestamp:        fftypes.Now(),
+				},
+				Info: &testEventInfo{Address: "0x34567", Ratio: 0.5, Negative: -1},
+				Data: fftypes.JSONAnyPtr(`{"from":"0xaaaa","to":"0xbbbb","value":"1000","nested":[1,true,null]}`),
+			},
+		},
+		{
+			StandardContext: EventContext{ListenerName: "listener2", RolledBack: true},
+			Event: ffcapi.Event{
+				ID: ffcapi.EventID{BlockNumber: 12345},
+			},
+		},
+	}
+}
+
+func TestPayloadRoundTrip(t *testing.T) {
+
+	batch := testEventBatch()
+	jsonBatch, err := json.Marshal(batch)
+	assert.NoError(t, err)
+	var expected []map[string]interface{}
+	err = json.Unmarshal(jsonBatch, &expected)
+	assert.NoError(t, err)
+
+	for _, encoding := range []PayloadEncoding{PayloadEncodingJSON, PayloadEncodingMsgpack} {
+		data, err := MarshalPayload(encoding, batch)
+		assert.NoError(t, err)
+
+		var decoded []map[string]interface{}
+		err = UnmarshalPayload(encoding, data, &decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, decoded, encoding)
+	}
+
+}
+
+func TestPayloadMsgpackIsBinary(t *testing.T) {
+
+	batch := testEventBatch()
+	jsonBatch, err := MarshalPayload(PayloadEncodingJSON, batch)
+	assert.NoError(t, err)
+	msgpackBatch, err := MarshalPayload(PayloadEncodingMsgpack, batch)
+	assert.NoError(t, err)
+	assert.Less(t, len(msgpackBatch), len(jsonBatch))
+
+	// Readable by any msgpack decoder, with integers preserved exactly
+	var decoded []map[string]interface{}
+	err = msgpack.Unmarshal(msgpackBatch, &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "listener1", decoded[0]["listenerName"])
+	assert.Equal(t, "18446744073709551615", decoded[0]["blockNumber"])
+	assert.EqualValues(t, -1, decoded[0]["negative"])
+	assert.Equal(t, 0.5, decoded[0]["ratio"])
+	assert.Equal(t, "0xaaaa", decoded[0]["data"].(map[string]interface{})["from"])
+
+}
+
+func TestPayloadMsgpackNumbers(t *testing.T) {
+
+	data, err := MarshalPayload(PayloadEncodingMsgpack, json.RawMessage(`[12345, -1, 18446744073709551615, 123456789012345678901234567890]`))
+	assert.NoError(t, err)
+	var decoded []interface{}
+	err = msgpack.Unmarshal(data, &decoded)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 12345, decoded[0])
+	assert.EqualValues(t, -1, decoded[1])
+	assert.Equal(t, uint64(18446744073709551615), decoded[2])
+	assert.Equal(t, 1.2345678901234568e+29, decoded[3])
+
+}
+
+func TestPayloadContentType(t *testing.T) {
+	assert.Equal(t, "application/json", PayloadContentType(PayloadEncodingJSON))
+	assert.Equal(t, "application/msgpack", PayloadContentType(PayloadEncodingMsgpack))
+}
+
+func TestMarshalPayloadFail(t *testing.T) {
+	_, err := MarshalPayload(PayloadEncodingMsgpack, map[bool]bool{false: true})
+	assert.Error(t, err)
+}
+
+func TestUnmarshalPayloadFail(t *testing.T) {
+	var v interface{}
+	err := UnmarshalPayload(PayloadEncodingMsgpack, []byte{0xc1}, &v)
+	assert.Error(t, err)
+
+	err = UnmarshalPayload(PayloadEncodingJSON, []byte{0xc1}, &v)
+	assert.Error(t, err)
+
+	// A msgpack document that has no JSON equivalent
+	data, _ := msgpack.Marshal(map[interface{}]interface{}{true: 1})
+	err = UnmarshalPayload(PayloadEncodingMsgpack, data, &v)
+	assert.Error(t, err)
+}
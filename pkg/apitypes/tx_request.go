@@ -17,6 +17,7 @@
 package apitypes
 
 import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
@@ -26,7 +27,45 @@ type TransactionRequest struct {
 	ffcapi.TransactionInput
 }
 
+// TransactionEstimate is the result of estimating a transaction, without submitting it.
+// If the connector reports the transaction would revert, the reason is returned instead of the gas details.
+type TransactionEstimate struct {
+	Gas          *fftypes.FFBigInt `json:"gas,omitempty"`
+	GasPrice     *fftypes.JSONAny  `json:"gasPrice,omitempty"`
+	RevertReason string            `json:"revertReason,omitempty"`
+}
+
 // ContractDeployRequest is the payload sent to initiate a new transaction
+// RawTransactionRequest is used to submit a transaction that has already been signed outside of FFTM,
+// for FFTM to submit and track through to confirmation. The nonce is the one the transaction was signed
+// with, rather than being assigned by FFTM, and the gas and gas price cannot be changed on resubmission.
+type RawTransactionRequest struct {
+	Headers         RequestHeaders    `json:"headers"`
+	From            string            `json:"from"`
+	Nonce           *fftypes.FFBigInt `json:"nonce"`
+	TransactionHash string            `json:"transactionHash,omitempty"` // optional - the hash of the signed transaction, if known
+	RawTransaction  string            `json:"rawTransaction"`
+	ReservationID   *fftypes.UUID     `json:"reservationId,omitempty"` // optional - a nonce reservation the transaction was signed with, which supplies the nonce if not set
+}
+
+// TypedDataRequest is the payload sent to sign EIP-712 typed data with the key of a signer. Nothing is submitted
+// to the blockchain, so no nonce is assigned, and the signature is returned on the response.
+type TypedDataRequest struct {
+	Headers RequestHeaders `json:"headers"`
+	ffcapi.SignTypedDataRequest
+}
+
+// SignedTypedData is the result of signing EIP-712 typed data
+type SignedTypedData struct {
+	ID        string            `json:"id"`
+	RequestID string            `json:"requestId,omitempty"`
+	From      string            `json:"from"`
+	TypedData *ffcapi.TypedData `json:"typedData"`
+	Hash      string            `json:"hash"`
+	Signature string            `json:"signature"`
+	Signed    *fftypes.FFTime   `json:"signed"`
+}
+
 type ContractDeployRequest struct {
 	Headers RequestHeaders `json:"headers"`
 	ffcapi.ContractDeployPrepareRequest
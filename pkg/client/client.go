@@ -0,0 +1,219 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// Client is a typed client for the FFTM REST API, for Go services that submit transactions to FFTM
+// and manage its event streams. Every call can be cancelled, or bounded with a deadline, using the
+// supplied context.
+type Client interface {
+	SubmitTransaction(ctx context.Context, req *apitypes.TransactionRequest) (*apitypes.ManagedTX, error)
+	GetTransaction(ctx context.Context, txID string) (*apitypes.ManagedTX, error)
+	ListTransactions(ctx context.Context, filter *TransactionFilter) ([]*apitypes.ManagedTX, error)
+	DeleteTransaction(ctx context.Context, txID string) (*apitypes.ManagedTX, error)
+
+	CreateEventStream(ctx context.Context, es *apitypes.EventStream) (*apitypes.EventStream, error)
+	GetEventStream(ctx context.Context, streamID string) (*apitypes.EventStreamWithStatus, error)
+	ListEventStreams(ctx context.Context, after string, limit int) ([]*apitypes.EventStream, error)
+	UpdateEventStream(ctx context.Context, streamID string, es *apitypes.EventStream) (*apitypes.EventStream, error)
+	DeleteEventStream(ctx context.Context, streamID string) error
+	SuspendEventStream(ctx context.Context, streamID string) error
+	ResumeEventStream(ctx context.Context, streamID string) error
+}
+
+// Options configure the connection to the FFTM API
+type Options struct {
+	URL            string       // the base URL of the FFTM API, such as http://localhost:5008
+	AuthHeader     string       // optional - value of the authorization header sent on every request, such as "Bearer <token>"
+	AuthHeaderName string       // optional - name of the authorization header, defaults to Authorization
+	HTTPClient     *http.Client // optional - the HTTP client to use, for example to configure TLS
+}
+
+// TransactionFilter selects the transactions returned by ListTransactions, with the same semantics
+// as the query parameters of GET /transactions. All fields are optional.
+type TransactionFilter struct {
+	Limit     int
+	After     string
+	Signer    string
+	Pending   bool
+	Status    apitypes.TxStatus
+	FromTime  *fftypes.FFTime
+	ToTime    *fftypes.FFTime
+	RequestID string
+	Direction string // asc/ascending or desc/descending
+}
+
+type client struct {
+	rest *resty.Client
+}
+
+// New creates a client for the FFTM API
+func New(opts *Options) Client {
+	var rest *resty.Client
+	if opts.HTTPClient != nil {
+		rest = resty.NewWithClient(opts.HTTPClient)
+	} else {
+		rest = resty.New()
+	}
+	rest.SetBaseURL(strings.TrimSuffix(opts.URL, "/"))
+	if opts.AuthHeader != "" {
+		name := opts.AuthHeaderName
+		if name == "" {
+			name = "Authorization"
+		}
+		rest.SetHeader(name, opts.AuthHeader)
+	}
+	return &client{rest: rest}
+}
+
+// errorBody is the error payload returned by the FFTM API
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func (c *client) do(ctx context.Context, method, path string, body, result interface{}, query map[string]string) error {
+	var errRes errorBody
+	req := c.rest.R().
+		SetContext(ctx).
+		SetError(&errRes).
+		SetQueryParams(query)
+	if body != nil {
+		req.SetBody(body)
+	}
+	if result != nil {
+		req.SetResult(result)
+	}
+	res, err := req.Execute(method, path)
+	if err != nil {
+		return i18n.WrapError(ctx, err, tmmsgs.MsgClientRequestFailed, method, path, err)
+	}
+	if res.IsError() {
+		msg := errRes.Error
+		if msg == "" {
+			msg = res.String()
+		}
+		return i18n.NewError(ctx, tmmsgs.MsgClientRequestError, method, path, res.StatusCode(), msg)
+	}
+	return nil
+}
+
+func (c *client) SubmitTransaction(ctx context.Context, req *apitypes.TransactionRequest) (*apitypes.ManagedTX, error) {
+	if req.Headers.Type == "" {
+		req.Headers.Type = apitypes.RequestTypeSendTransaction
+	}
+	var mtx *apitypes.ManagedTX
+	err := c.do(ctx, http.MethodPost, "/", req, &mtx, nil)
+	return mtx, err
+}
+
+func (c *client) GetTransaction(ctx context.Context, txID string) (*apitypes.ManagedTX, error) {
+	var mtx *apitypes.ManagedTX
+	err := c.do(ctx, http.MethodGet, "/transactions/"+url.PathEscape(txID), nil, &mtx, nil)
+	return mtx, err
+}
+
+func (c *client) ListTransactions(ctx context.Context, filter *TransactionFilter) ([]*apitypes.ManagedTX, error) {
+	query := make(map[string]string)
+	if filter != nil {
+		if filter.Limit > 0 {
+			query["limit"] = strconv.Itoa(filter.Limit)
+		}
+		setIfSet(query, "after", filter.After)
+		setIfSet(query, "signer", filter.Signer)
+		if filter.Pending {
+			query["pending"] = "true"
+		}
+		setIfSet(query, "status", string(filter.Status))
+		if filter.FromTime != nil {
+			query["fromTime"] = filter.FromTime.String()
+		}
+		if filter.ToTime != nil {
+			query["toTime"] = filter.ToTime.String()
+		}
+		setIfSet(query, "requestId", filter.RequestID)
+		setIfSet(query, "direction", filter.Direction)
+	}
+	var txs []*apitypes.ManagedTX
+	err := c.do(ctx, http.MethodGet, "/transactions", nil, &txs, query)
+	return txs, err
+}
+
+func (c *client) DeleteTransaction(ctx context.Context, txID string) (*apitypes.ManagedTX, error) {
+	var mtx *apitypes.ManagedTX
+	err := c.do(ctx, http.MethodDelete, "/transactions/"+url.PathEscape(txID), nil, &mtx, nil)
+	return mtx, err
+}
+
+func (c *client) CreateEventStream(ctx context.Context, es *apitypes.EventStream) (*apitypes.EventStream, error) {
+	var created *apitypes.EventStream
+	err := c.do(ctx, http.MethodPost, "/eventstreams", es, &created, nil)
+	return created, err
+}
+
+func (c *client) GetEventStream(ctx context.Context, streamID string) (*apitypes.EventStreamWithStatus, error) {
+	var es *apitypes.EventStreamWithStatus
+	err := c.do(ctx, http.MethodGet, "/eventstreams/"+url.PathEscape(streamID), nil, &es, nil)
+	return es, err
+}
+
+func (c *client) ListEventStreams(ctx context.Context, after string, limit int) ([]*apitypes.EventStream, error) {
+	query := make(map[string]string)
+	setIfSet(query, "after", after)
+	if limit > 0 {
+		query["limit"] = strconv.Itoa(limit)
+	}
+	var streams []*apitypes.EventStream
+	err := c.do(ctx, http.MethodGet, "/eventstreams", nil, &streams, query)
+	return streams, err
+}
+
+func (c *client) UpdateEventStream(ctx context.Context, streamID string, es *apitypes.EventStream) (*apitypes.EventStream, error) {
+	var updated *apitypes.EventStream
+	err := c.do(ctx, http.MethodPatch, "/eventstreams/"+url.PathEscape(streamID), es, &updated, nil)
+	return updated, err
+}
+
+func (c *client) DeleteEventStream(ctx context.Context, streamID string) error {
+	return c.do(ctx, http.MethodDelete, "/eventstreams/"+url.PathEscape(streamID), nil, nil, nil)
+}
+
+func (c *client) SuspendEventStream(ctx context.Context, streamID string) error {
+	return c.do(ctx, http.MethodPost, "/eventstreams/"+url.PathEscape(streamID)+"/suspend", struct{}{}, nil, nil)
+}
+
+func (c *client) ResumeEventStream(ctx context.Context, streamID string) error {
+	return c.do(ctx, http.MethodPost, "/eventstreams/"+url.PathEscape(streamID)+"/resume", struct{}{}, nil, nil)
+}
+
+func setIfSet(query map[string]string, name, value string) {
+	if value != "" {
+		query[name] = value
+	}
+}
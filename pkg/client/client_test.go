@@ -0,0 +1,179 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (Client, func()) {
+	server := httptest.NewServer(handler)
+	return New(&Options{URL: server.URL + "/", AuthHeader: "Bearer token1"}), server.Close
+}
+
+func TestAuthHeader(t *testing.T) {
+	c, done := newTestClient(t, func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token1", req.Header.Get("Authorization"))
+		res.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(res).Encode(&apitypes.ManagedTX{ID: "tx1"})
+	})
+	defer done()
+
+	mtx, err := c.GetTransaction(context.Background(), "tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, "tx1", mtx.ID)
+}
+
+func TestAuthHeaderName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "key1", req.Header.Get("X-API-Key"))
+		assert.Empty(t, req.Header.Get("Authorization"))
+		res.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	c := New(&Options{URL: server.URL, AuthHeader: "key1", AuthHeaderName: "X-API-Key", HTTPClient: server.Client()})
+
+	err := c.DeleteEventStream(context.Background(), "es1")
+	assert.NoError(t, err)
+}
+
+func TestListTransactionsFilter(t *testing.T) {
+	fromTime := fftypes.Now()
+	c, done := newTestClient(t, func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/transactions", req.URL.Path)
+		q := req.URL.Query()
+		assert.Equal(t, "10", q.Get("limit"))
+		assert.Equal(t, "tx1", q.Get("after"))
+		assert.Equal(t, "0xaaaaa", q.Get("signer"))
+		assert.Equal(t, "true", q.Get("pending"))
+		assert.Equal(t, "Pending", q.Get("status"))
+		assert.Equal(t, fromTime.String(), q.Get("fromTime"))
+		assert.Equal(t, fromTime.String(), q.Get("toTime"))
+		assert.Equal(t, "req1", q.Get("requestId"))
+		assert.Equal(t, "asc", q.Get("direction"))
+		res.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(res).Encode([]*apitypes.ManagedTX{{ID: "tx2"}})
+	})
+	defer done()
+
+	txs, err := c.ListTransactions(context.Background(), &TransactionFilter{
+		Limit:     10,
+		After:     "tx1",
+		Signer:    "0xaaaaa",
+		Pending:   true,
+		Status:    apitypes.TxStatusPending,
+		FromTime:  fromTime,
+		ToTime:    fromTime,
+		RequestID: "req1",
+		Direction: "asc",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, txs, 1)
+}
+
+func TestListEventStreamsQuery(t *testing.T) {
+	c, done := newTestClient(t, func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "es1", req.URL.Query().Get("after"))
+		assert.Equal(t, "5", req.URL.Query().Get("limit"))
+		res.Header().Set("Content-Type", "application/json")
+		_, _ = res.Write([]byte(`[]`))
+	})
+	defer done()
+
+	streams, err := c.ListEventStreams(context.Background(), "es1", 5)
+	assert.NoError(t, err)
+	assert.Empty(t, streams)
+}
+
+func TestErrorResponse(t *testing.T) {
+	c, done := newTestClient(t, func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusNotFound)
+		_, _ = res.Write([]byte(`{"error":"FF21067: Transaction 'tx1' not found"}`))
+	})
+	defer done()
+
+	_, err := c.GetTransaction(context.Background(), "tx1")
+	assert.Regexp(t, "FF21134.*GET /transactions/tx1.*404.*FF21067", err)
+}
+
+func TestErrorResponseNotJSON(t *testing.T) {
+	c, done := newTestClient(t, func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusBadGateway)
+		_, _ = res.Write([]byte(`bad gateway`))
+	})
+	defer done()
+
+	err := c.SuspendEventStream(context.Background(), "es1")
+	assert.Regexp(t, "FF21134.*502.*bad gateway", err)
+}
+
+func TestRequestFailed(t *testing.T) {
+	c, done := newTestClient(t, func(res http.ResponseWriter, req *http.Request) {})
+	done()
+
+	_, err := c.SubmitTransaction(context.Background(), &apitypes.TransactionRequest{})
+	assert.Regexp(t, "FF21133", err)
+}
+
+func TestContextCancelled(t *testing.T) {
+	blocked := make(chan struct{})
+	c, done := newTestClient(t, func(res http.ResponseWriter, req *http.Request) {
+		<-blocked
+	})
+	defer done()
+	defer close(blocked)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := c.ResumeEventStream(ctx, "es1")
+	assert.Regexp(t, "FF21133.*context canceled", err)
+}
+
+func TestRequestPaths(t *testing.T) {
+	var paths []string
+	c, done := newTestClient(t, func(res http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.Method+" "+req.URL.Path)
+		res.Header().Set("Content-Type", "application/json")
+		_, _ = res.Write([]byte(`{}`))
+	})
+	defer done()
+
+	ctx := context.Background()
+	_, err := c.DeleteTransaction(ctx, "ns1:tx1")
+	assert.NoError(t, err)
+	_, err = c.CreateEventStream(ctx, &apitypes.EventStream{})
+	assert.NoError(t, err)
+	_, err = c.GetEventStream(ctx, "es1")
+	assert.NoError(t, err)
+	_, err = c.UpdateEventStream(ctx, "es1", &apitypes.EventStream{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"DELETE /transactions/ns1:tx1",
+		"POST /eventstreams",
+		"GET /eventstreams/es1",
+		"PATCH /eventstreams/es1",
+	}, paths)
+}
@@ -0,0 +1,29 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchangerate
+
+import (
+	"context"
+	"math/big"
+)
+
+// RateProvider is a source of the fiat price of the native currency of the chain, for a policy engine to
+// convert limits expressed in fiat into gas prices
+type RateProvider interface {
+	// Rate returns the fiat price of one whole unit of the native currency, such as the USD price of one ETH
+	Rate(ctx context.Context) (rate *big.Rat, err error)
+}
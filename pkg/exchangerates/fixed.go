@@ -0,0 +1,65 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchangerates
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/exchangerate"
+)
+
+const FixedRate = "rate" // a decimal string, such as "3000.50"
+
+// FixedFactory creates an exchange-rate provider that always returns the configured rate
+type FixedFactory struct{}
+
+func (f *FixedFactory) Name() string {
+	return "fixed"
+}
+
+func (f *FixedFactory) InitConfig(conf config.Section) {
+	conf.AddKnownKey(FixedRate)
+}
+
+func (f *FixedFactory) NewRateProvider(ctx context.Context, conf config.Section) (exchangerate.RateProvider, error) {
+	rate, err := parseRate(ctx, conf.GetString(FixedRate))
+	if err != nil {
+		return nil, err
+	}
+	return &fixedRateProvider{rate: rate}, nil
+}
+
+type fixedRateProvider struct {
+	rate *big.Rat
+}
+
+func (o *fixedRateProvider) Rate(ctx context.Context) (*big.Rat, error) {
+	return o.rate, nil
+}
+
+// parseRate parses a decimal exchange rate, which must be positive
+func parseRate(ctx context.Context, rateStr string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(rateStr)
+	if !ok || rate.Sign() <= 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidExchangeRate, rateStr)
+	}
+	return rate, nil
+}
@@ -0,0 +1,63 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchangerates
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/exchangerate"
+)
+
+var rateProviders = make(map[string]Factory)
+
+func init() {
+	RegisterProvider(&FixedFactory{})
+	RegisterProvider(&RESTAPIFactory{})
+}
+
+// NewRateProvider creates the exchange-rate provider registered with the name, using the config section of a policy engine
+func NewRateProvider(ctx context.Context, conf config.Section, name string) (exchangerate.RateProvider, error) {
+	factory, ok := rateProviders[name]
+	if !ok {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgExchangeRateNotRegistered, name)
+	}
+	return factory.NewRateProvider(ctx, conf)
+}
+
+type Factory interface {
+	Name() string
+	InitConfig(conf config.Section)
+	NewRateProvider(ctx context.Context, conf config.Section) (exchangerate.RateProvider, error)
+}
+
+// RegisterProvider adds an exchange-rate provider that policy engines can select by name. Providers must be registered
+// before the policy engines that use them, so their config is initialized in the section of each engine.
+func RegisterProvider(factory Factory) string {
+	name := factory.Name()
+	rateProviders[name] = factory
+	return name
+}
+
+// InitConfig initializes the config of every registered exchange-rate provider, in the config section of a policy engine
+func InitConfig(conf config.Section) {
+	for _, factory := range rateProviders {
+		factory.InitConfig(conf)
+	}
+}
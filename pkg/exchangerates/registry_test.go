@@ -0,0 +1,154 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchangerates
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/exchangeratemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/exchangerate"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRateProviderFactory struct {
+	provider *exchangeratemocks.RateProvider
+}
+
+func (f *testRateProviderFactory) Name() string { return "test" }
+
+func (f *testRateProviderFactory) InitConfig(conf config.Section) {
+	conf.AddKnownKey("currency", "usd")
+}
+
+func (f *testRateProviderFactory) NewRateProvider(ctx context.Context, conf config.Section) (exchangerate.RateProvider, error) {
+	return f.provider, nil
+}
+
+func newTestRateProviderConfig(t *testing.T) config.Section {
+	tmconfig.Reset()
+	conf := config.RootSection("unittest.exchangerate")
+	InitConfig(conf)
+	return conf
+}
+
+func TestRegistry(t *testing.T) {
+
+	f := &testRateProviderFactory{provider: &exchangeratemocks.RateProvider{}}
+	assert.Equal(t, "test", RegisterProvider(f))
+	defer delete(rateProviders, "test")
+	conf := newTestRateProviderConfig(t)
+	assert.Equal(t, "usd", conf.GetString("currency"))
+
+	p, err := NewRateProvider(context.Background(), conf, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, f.provider, p)
+
+	p, err = NewRateProvider(context.Background(), conf, "bob")
+	assert.Nil(t, p)
+	assert.Regexp(t, "FF21175", err)
+
+}
+
+func TestRESTAPIRateProvider(t *testing.T) {
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ethereum": {"usd": 3012.45}}`))
+	}))
+	defer server.Close()
+
+	conf := newTestRateProviderConfig(t)
+	conf.Set(ffresty.HTTPConfigURL, fmt.Sprintf("http://%s", server.Listener.Addr()))
+	conf.Set(RESTAPIMethod, http.MethodPost)
+	conf.Set(RESTAPITemplate, `{{ .ethereum.usd }}`)
+	p, err := NewRateProvider(context.Background(), conf, "restapi")
+	assert.NoError(t, err)
+
+	rate, err := p.Rate(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, big.NewRat(301245, 100).Cmp(rate))
+
+	status = http.StatusInternalServerError
+	_, err = p.Rate(context.Background())
+	assert.Regexp(t, "FF21177.*500", err)
+
+}
+
+func TestRESTAPIRateProviderErrors(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ethereum": {"usd": "unknown"}}`))
+	}))
+	defer server.Close()
+
+	conf := newTestRateProviderConfig(t)
+	_, err := NewRateProvider(context.Background(), conf, "restapi")
+	assert.Regexp(t, "FF21178", err)
+
+	conf.Set(RESTAPITemplate, "{{ !!! wrong")
+	_, err = NewRateProvider(context.Background(), conf, "restapi")
+	assert.Regexp(t, "FF21025", err)
+
+	conf.Set(ffresty.HTTPConfigURL, fmt.Sprintf("http://%s", server.Listener.Addr()))
+	conf.Set(RESTAPITemplate, "{{ .wrong.thing | len }}")
+	p, err := NewRateProvider(context.Background(), conf, "restapi")
+	assert.NoError(t, err)
+	_, err = p.Rate(context.Background())
+	assert.Regexp(t, "FF21179", err)
+
+	conf.Set(RESTAPITemplate, "{{ .ethereum.usd }}")
+	p, err = NewRateProvider(context.Background(), conf, "restapi")
+	assert.NoError(t, err)
+	_, err = p.Rate(context.Background())
+	assert.Regexp(t, "FF21176.*unknown", err)
+
+	server.Close()
+	_, err = p.Rate(context.Background())
+	assert.Regexp(t, "FF21177", err)
+
+}
+
+func TestFixedRateProvider(t *testing.T) {
+
+	conf := newTestRateProviderConfig(t)
+	_, err := NewRateProvider(context.Background(), conf, "fixed")
+	assert.Regexp(t, "FF21176", err)
+
+	conf.Set(FixedRate, "-1")
+	_, err = NewRateProvider(context.Background(), conf, "fixed")
+	assert.Regexp(t, "FF21176", err)
+
+	conf.Set(FixedRate, "2500.5")
+	p, err := NewRateProvider(context.Background(), conf, "fixed")
+	assert.NoError(t, err)
+	rate, err := p.Rate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "5001/2", rate.String())
+
+}
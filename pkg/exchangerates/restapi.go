@@ -0,0 +1,96 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exchangerates
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/exchangerate"
+)
+
+const (
+	RESTAPIMethod   = "method"
+	RESTAPITemplate = "template"
+)
+
+const defaultRESTAPIMethod = http.MethodGet
+
+// RESTAPIFactory creates an exchange-rate provider that calls an external price REST API, and uses a go template
+// to extract the rate from the JSON response
+type RESTAPIFactory struct{}
+
+func (f *RESTAPIFactory) Name() string {
+	return "restapi"
+}
+
+func (f *RESTAPIFactory) InitConfig(conf config.Section) {
+	ffresty.InitConfig(conf)
+	conf.AddKnownKey(RESTAPIMethod, defaultRESTAPIMethod)
+	conf.AddKnownKey(RESTAPITemplate)
+}
+
+func (f *RESTAPIFactory) NewRateProvider(ctx context.Context, conf config.Section) (exchangerate.RateProvider, error) {
+	templateString := conf.GetString(RESTAPITemplate)
+	if templateString == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgMissingExchangeRateTemplate)
+	}
+	t, err := template.New("").Parse(templateString)
+	if err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgBadGOTemplate, err)
+	}
+	return &restapiRateProvider{
+		client:   ffresty.New(ctx, conf),
+		method:   conf.GetString(RESTAPIMethod),
+		template: t,
+	}, nil
+}
+
+type restapiRateProvider struct {
+	client   *resty.Client
+	method   string
+	template *template.Template
+}
+
+func (o *restapiRateProvider) Rate(ctx context.Context) (*big.Rat, error) {
+	var jsonResponse map[string]interface{}
+	res, err := o.client.R().
+		SetContext(ctx).
+		SetResult(&jsonResponse).
+		Execute(o.method, "")
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingExchangeRateAPI, -1, err.Error())
+	}
+	if res.IsError() {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingExchangeRateAPI, res.StatusCode(), res.RawResponse)
+	}
+	buff := new(bytes.Buffer)
+	err = o.template.Execute(buff, jsonResponse)
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgExchangeRateResultError)
+	}
+	return parseRate(ctx, strings.TrimSpace(buff.String()))
+}
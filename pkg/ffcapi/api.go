@@ -79,6 +79,9 @@ type API interface {
 
 	// NewBlockListener creates a new block listener, decoupled from an event stream
 	NewBlockListener(ctx context.Context, req *NewBlockListenerRequest) (*NewBlockListenerResponse, ErrorReason, error)
+
+	// ConnectorInfo returns the name and version of the connector, and the optional capabilities it supports
+	ConnectorInfo(ctx context.Context, req *ConnectorInfoRequest) (*ConnectorInfoResponse, ErrorReason, error)
 }
 
 type BlockHashEvent struct {
@@ -165,7 +168,7 @@ type ErrorReason string
 const (
 	// ErrorReasonInvalidInputs transaction inputs could not be parsed by the connector according to the interface (nothing was sent to the blockchain)
 	ErrorReasonInvalidInputs ErrorReason = "invalid_inputs"
-	// ErrorReasonTransactionReverted on-chain execution (only expected to be returned when the connector is doing gas estimation, or executing a query)
+	// ErrorReasonTransactionReverted on-chain execution (only expected to be returned when the connector is doing gas estimation, simulating a transaction, or executing a query)
 	ErrorReasonTransactionReverted ErrorReason = "transaction_reverted"
 	// ErrorReasonNonceTooLow on transaction submission, if the nonce has already been used for a transaction that has made it into a block on the canonical chain known to the local node
 	ErrorReasonNonceTooLow ErrorReason = "nonce_too_low"
@@ -192,11 +195,21 @@ type TransactionInput struct {
 }
 
 type TransactionHeaders struct {
-	From  string            `json:"from,omitempty"`
-	To    string            `json:"to,omitempty"`
-	Nonce *fftypes.FFBigInt `json:"nonce,omitempty"`
-	Gas   *fftypes.FFBigInt `json:"gas,omitempty"`
-	Value *fftypes.FFBigInt `json:"value,omitempty"`
+	From       string            `json:"from,omitempty"`
+	To         string            `json:"to,omitempty"`
+	Nonce      *fftypes.FFBigInt `json:"nonce,omitempty"`
+	Gas        *fftypes.FFBigInt `json:"gas,omitempty"`
+	Value      *fftypes.FFBigInt `json:"value,omitempty"`
+	AccessList AccessList        `json:"accessList,omitempty"` // optional - passed to the connector for inclusion in the signed transaction
+}
+
+// AccessList is an EIP-2930 list of the addresses and storage keys a transaction will access,
+// which are charged at a reduced gas cost when declared up front
+type AccessList []*AccessListEntry
+
+type AccessListEntry struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
 }
 
 type BlockInfo struct {
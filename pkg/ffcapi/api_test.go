@@ -18,6 +18,7 @@ package ffcapi
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"math/big"
 	"sort"
 	"strings"
@@ -55,3 +56,51 @@ func TestSortEvents(t *testing.T) {
 		assert.LessOrEqual(t, strings.Compare(listenerUpdates[i-1].Event.ID.ProtocolID(), listenerUpdates[i].Event.ID.ProtocolID()), 0)
 	}
 }
+
+func TestAccessListJSONRoundTrip(t *testing.T) {
+
+	jsonIn := `{
+		"from": "0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8",
+		"accessList": [
+			{
+				"address": "0xe1a078b9e2b145d0a7387f09277c6ae1d9470771",
+				"storageKeys": [
+					"0x0000000000000000000000000000000000000000000000000000000000000003",
+					"0x0000000000000000000000000000000000000000000000000000000000000007"
+				]
+			},
+			{
+				"address": "0xbbbb78b9e2b145d0a7387f09277c6ae1d9470771",
+				"storageKeys": []
+			}
+		],
+		"transactionData": "0x123456"
+	}`
+
+	var req TransactionSendRequest
+	err := json.Unmarshal([]byte(jsonIn), &req)
+	assert.NoError(t, err)
+	assert.Len(t, req.AccessList, 2)
+	assert.Equal(t, "0xe1a078b9e2b145d0a7387f09277c6ae1d9470771", req.AccessList[0].Address)
+	assert.Len(t, req.AccessList[0].StorageKeys, 2)
+
+	jsonOut, err := json.Marshal(&req)
+	assert.NoError(t, err)
+	assert.JSONEq(t, jsonIn, string(jsonOut))
+
+	// Omitted when not supplied
+	jsonOut, err = json.Marshal(&TransactionHeaders{From: "0xaaaa"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"from":"0xaaaa"}`, string(jsonOut))
+
+}
+
+func TestConnectorInfoSupports(t *testing.T) {
+	info := &ConnectorInfoResponse{
+		Capabilities: []Capability{CapabilityEIP1559, CapabilityBatchReceipts},
+	}
+	assert.True(t, info.Supports(CapabilityEIP1559))
+	assert.True(t, info.Supports(CapabilityBatchReceipts))
+	assert.False(t, info.Supports(CapabilityTrace))
+	assert.False(t, (&ConnectorInfoResponse{}).Supports(CapabilityRawTransactions))
+}
@@ -0,0 +1,58 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+// Capability is an optional feature a connector can report that it supports
+type Capability string
+
+const (
+	// CapabilityEIP1559 the connector accepts EIP-1559 fee market gas prices (maxFeePerGas / maxPriorityFeePerGas)
+	CapabilityEIP1559 Capability = "eip1559"
+	// CapabilityTrace the connector implements TraceAPI
+	CapabilityTrace Capability = "trace"
+	// CapabilityBatchReceipts the connector implements BatchReceiptAPI
+	CapabilityBatchReceipts Capability = "batchReceipts"
+	// CapabilityRawTransactions the connector implements RawTransactionAPI
+	CapabilityRawTransactions Capability = "rawTransactions"
+	// CapabilityEventReplay the connector implements EventReplayAPI
+	CapabilityEventReplay Capability = "eventReplay"
+	// CapabilityTypedData the connector implements TypedDataAPI
+	CapabilityTypedData Capability = "typedData"
+	// CapabilityFinality the connector implements FinalityAPI
+	CapabilityFinality Capability = "finality"
+	// CapabilitySimulation the connector implements SimulationAPI
+	CapabilitySimulation Capability = "simulation"
+)
+
+type ConnectorInfoRequest struct {
+}
+
+type ConnectorInfoResponse struct {
+	Name         string       `json:"name"`
+	Version      string       `json:"version"`
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// Supports returns true if the connector reported the capability
+func (r *ConnectorInfoResponse) Supports(c Capability) bool {
+	for _, rc := range r.Capabilities {
+		if rc == c {
+			return true
+		}
+	}
+	return false
+}
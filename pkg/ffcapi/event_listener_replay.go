@@ -0,0 +1,41 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+type EventListenerReplayRequest struct {
+	EventListenerOptions
+	ListenerID *fftypes.UUID // The listener to query the historical events for
+	StreamID   *fftypes.UUID // The event stream the listener belongs to
+	FromBlock  uint64        // The first block of the range (inclusive)
+	ToBlock    uint64        // The last block of the range (inclusive)
+}
+
+type EventListenerReplayResponse struct {
+	Events ListenerEvents // All the events matching the listener in the block range, with the checkpoint of each
+}
+
+// EventReplayAPI is an optional interface a connector can implement, to query the events that matched a listener
+// in a historical range of blocks, without affecting the live detection of events for that listener
+type EventReplayAPI interface {
+	EventListenerReplay(ctx context.Context, req *EventListenerReplayRequest) (*EventListenerReplayResponse, ErrorReason, error)
+}
@@ -0,0 +1,38 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+type FinalizedBlockRequest struct {
+}
+
+type FinalizedBlockResponse struct {
+	BlockNumber *fftypes.FFBigInt `json:"blockNumber"`
+	BlockHash   string            `json:"blockHash"`
+}
+
+// FinalityAPI is an optional interface a connector can implement, to return the latest block the chain
+// considers final (such as the "finalized" block tag), so confirmation can wait for finality rather than
+// counting a number of blocks
+type FinalityAPI interface {
+	FinalizedBlock(ctx context.Context, req *FinalizedBlockRequest) (*FinalizedBlockResponse, ErrorReason, error)
+}
@@ -0,0 +1,53 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+// TypedData is an EIP-712 typed data structure, to be hashed and signed by the connector
+type TypedData struct {
+	Types       map[string][]*TypedDataField `json:"types"`
+	PrimaryType string                       `json:"primaryType"`
+	Domain      *fftypes.JSONAny             `json:"domain"`
+	Message     *fftypes.JSONAny             `json:"message"`
+}
+
+// TypedDataField is a member of a struct type declared in EIP-712 typed data
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type SignTypedDataRequest struct {
+	From      string     `json:"from"`
+	TypedData *TypedData `json:"typedData"`
+}
+
+type SignTypedDataResponse struct {
+	Hash      string `json:"hash"`      // the EIP-712 hash of the typed data that was signed
+	Signature string `json:"signature"` // the signature, encoded by the connector (such as the 65 byte r,s,v hex encoding)
+}
+
+// TypedDataAPI is an optional interface a connector can implement, to resolve EIP-712 typed data to its
+// hash and sign it with the key of the signer (such as using eth_signTypedData_v4)
+type TypedDataAPI interface {
+	SignTypedData(ctx context.Context, req *SignTypedDataRequest) (*SignTypedDataResponse, ErrorReason, error)
+}
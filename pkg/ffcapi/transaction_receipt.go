@@ -17,6 +17,8 @@
 package ffcapi
 
 import (
+	"context"
+
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 )
 
@@ -30,4 +32,53 @@ type TransactionReceiptResponse struct {
 	BlockHash        string            `json:"blockHash"`
 	Success          bool              `json:"success"`
 	ExtraInfo        *fftypes.JSONAny  `json:"extraInfo"`
+	ContractLocation *fftypes.JSONAny  `json:"contractLocation,omitempty"` // for a contract creation, the deployed contract - such as {"address":"0x..."} for EVM
+}
+
+type TransactionReceiptsRequest struct {
+	TransactionHashes []string `json:"transactionHashes"`
+}
+
+// TransactionReceiptResult is the outcome of an individual receipt query within a batch.
+// ErrorReason and Error are set (and Receipt is nil) if the query for that hash failed.
+type TransactionReceiptResult struct {
+	Receipt     *TransactionReceiptResponse `json:"receipt,omitempty"`
+	ErrorReason ErrorReason                 `json:"errorReason,omitempty"`
+	Error       string                      `json:"error,omitempty"`
+}
+
+type TransactionReceiptsResponse struct {
+	Results []*TransactionReceiptResult `json:"results"` // in the same order as the request
+}
+
+// BatchReceiptAPI is an optional interface a connector can implement, to query receipts for
+// multiple transactions in a single call (such as a JSON-RPC batch request)
+type BatchReceiptAPI interface {
+	TransactionReceipts(ctx context.Context, req *TransactionReceiptsRequest) (*TransactionReceiptsResponse, ErrorReason, error)
+}
+
+// TransactionReceipts queries the receipts for a set of transactions, using a single batch call if
+// the connector implements BatchReceiptAPI, or falling back to individual TransactionReceipt calls.
+// Failures for individual hashes are returned in the results - an error is only returned if the
+// batch as a whole failed.
+func TransactionReceipts(ctx context.Context, api API, req *TransactionReceiptsRequest) (*TransactionReceiptsResponse, ErrorReason, error) {
+	if batchAPI, ok := api.(BatchReceiptAPI); ok {
+		return batchAPI.TransactionReceipts(ctx, req)
+	}
+	res := &TransactionReceiptsResponse{
+		Results: make([]*TransactionReceiptResult, len(req.TransactionHashes)),
+	}
+	for i, txHash := range req.TransactionHashes {
+		receipt, reason, err := api.TransactionReceipt(ctx, &TransactionReceiptRequest{
+			TransactionHash: txHash,
+		})
+		result := &TransactionReceiptResult{Receipt: receipt}
+		if err != nil {
+			result.Receipt = nil
+			result.ErrorReason = reason
+			result.Error = err.Error()
+		}
+		res.Results[i] = result
+	}
+	return res, "", nil
 }
@@ -0,0 +1,81 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/stretchr/testify/assert"
+)
+
+type singleReceiptAPI struct {
+	API
+	calls int
+}
+
+func (s *singleReceiptAPI) TransactionReceipt(ctx context.Context, req *TransactionReceiptRequest) (*TransactionReceiptResponse, ErrorReason, error) {
+	s.calls++
+	if req.TransactionHash == "0x2" {
+		return nil, ErrorReasonNotFound, fmt.Errorf("not found")
+	}
+	return &TransactionReceiptResponse{BlockNumber: fftypes.NewFFBigInt(1), BlockHash: req.TransactionHash}, "", nil
+}
+
+type batchReceiptAPI struct {
+	singleReceiptAPI
+	batchCalls int
+}
+
+func (b *batchReceiptAPI) TransactionReceipts(ctx context.Context, req *TransactionReceiptsRequest) (*TransactionReceiptsResponse, ErrorReason, error) {
+	b.batchCalls++
+	return &TransactionReceiptsResponse{
+		Results: make([]*TransactionReceiptResult, len(req.TransactionHashes)),
+	}, "", nil
+}
+
+func TestTransactionReceiptsFallback(t *testing.T) {
+
+	api := &singleReceiptAPI{}
+	res, _, err := TransactionReceipts(context.Background(), api, &TransactionReceiptsRequest{
+		TransactionHashes: []string{"0x1", "0x2", "0x3"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, api.calls)
+	assert.Len(t, res.Results, 3)
+	assert.Equal(t, "0x1", res.Results[0].Receipt.BlockHash)
+	assert.Nil(t, res.Results[1].Receipt)
+	assert.Equal(t, ErrorReasonNotFound, res.Results[1].ErrorReason)
+	assert.Equal(t, "not found", res.Results[1].Error)
+	assert.Equal(t, "0x3", res.Results[2].Receipt.BlockHash)
+
+}
+
+func TestTransactionReceiptsBatched(t *testing.T) {
+
+	api := &batchReceiptAPI{}
+	res, _, err := TransactionReceipts(context.Background(), api, &TransactionReceiptsRequest{
+		TransactionHashes: []string{"0x1", "0x2", "0x3"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, api.batchCalls)
+	assert.Equal(t, 0, api.calls)
+	assert.Len(t, res.Results, 3)
+
+}
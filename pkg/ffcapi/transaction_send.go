@@ -27,6 +27,7 @@ type TransactionSendRequest struct {
 	GasPrice *fftypes.JSONAny `json:"gasPrice,omitempty"` // can be a simple string/number, or a complex object - contract is between policy engine and blockchain connector
 	TransactionHeaders
 	TransactionData string `json:"transactionData"`
+	DeployContract  bool   `json:"deployContract,omitempty"` // a contract creation, with no "to" address and the contract (such as the EVM bytecode and constructor inputs) as the transaction data
 }
 
 type TransactionSendResponse struct {
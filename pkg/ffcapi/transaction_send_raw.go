@@ -0,0 +1,33 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"context"
+)
+
+// TransactionSendRawRequest is used to send a transaction that has already been signed outside of FFTM
+type TransactionSendRawRequest struct {
+	RawTransaction string `json:"rawTransaction"`
+}
+
+// RawTransactionAPI is an optional interface a connector can implement, to submit pre-signed transactions
+// to the transaction pool of the blockchain (such as using eth_sendRawTransaction).
+// The connector returns the same error reasons as TransactionSend, including for a transaction that is already known.
+type RawTransactionAPI interface {
+	TransactionSendRaw(ctx context.Context, req *TransactionSendRawRequest) (*TransactionSendResponse, ErrorReason, error)
+}
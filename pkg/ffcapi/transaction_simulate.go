@@ -0,0 +1,43 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+// TransactionSimulateRequest is used to execute a prepared transaction against the current state of the
+// chain, without submitting it (such as using eth_call)
+type TransactionSimulateRequest struct {
+	GasPrice *fftypes.JSONAny `json:"gasPrice,omitempty"` // the gas price the transaction would be submitted with
+	TransactionHeaders
+	TransactionData string `json:"transactionData"`
+	DeployContract  bool   `json:"deployContract,omitempty"`
+}
+
+type TransactionSimulateResponse struct {
+	Output *fftypes.JSONAny `json:"output,omitempty"` // connector specific output of the execution, such as the return data
+}
+
+// SimulationAPI is an optional interface a connector can implement, to check a transaction would not revert
+// before it is submitted. If the simulation reverts, the connector must return ErrorReasonTransactionReverted
+// with an error containing the decoded revert reason.
+type SimulationAPI interface {
+	TransactionSimulate(ctx context.Context, req *TransactionSimulateRequest) (*TransactionSimulateResponse, ErrorReason, error)
+}
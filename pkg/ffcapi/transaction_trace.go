@@ -0,0 +1,38 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ffcapi
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+type TransactionTraceRequest struct {
+	TransactionHash string `json:"transactionHash"`
+}
+
+type TransactionTraceResponse struct {
+	RevertReason string           `json:"revertReason,omitempty"` // decoded revert reason, if the transaction reverted
+	Trace        *fftypes.JSONAny `json:"trace,omitempty"`        // connector specific trace of the execution of the transaction
+}
+
+// TraceAPI is an optional interface a connector can implement, to return the revert reason
+// and an execution trace for a transaction that has been mined (such as using debug_traceTransaction)
+type TraceAPI interface {
+	TransactionTrace(ctx context.Context, req *TransactionTraceRequest) (*TransactionTraceResponse, ErrorReason, error)
+}
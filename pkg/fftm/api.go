@@ -67,6 +67,13 @@ func (m *manager) router() *mux.Router {
 	}))
 
 	mux.HandleFunc("/ws", m.wsServer.Handler)
+	mux.Path("/eventstreams/{streamId}/sse").Methods(http.MethodGet).HandlerFunc(m.eventStreamSSEHandler)
+	mux.Path("/livez").Methods(http.MethodGet).HandlerFunc(m.livenessHandler)
+	mux.Path("/readyz").Methods(http.MethodGet).HandlerFunc(m.readinessHandler)
+
+	// Every matched route is authorized, including the websocket, Server-Sent Events and health endpoints
+	mux.Use(tracingMiddleware)
+	mux.Use(m.authMiddleware(&hf))
 
 	mux.NotFoundHandler = hf.APIWrapper(func(res http.ResponseWriter, req *http.Request) (status int, err error) {
 		return 404, i18n.NewError(req.Context(), i18n.Msg404NotFound)
@@ -17,6 +17,7 @@
 package fftm
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"testing"
@@ -148,6 +149,209 @@ func TestSendTransactionE2E(t *testing.T) {
 
 }
 
+func TestSendTransactionIdempotencyKeyHeader(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("DeployContractPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x106215b9c0c9372e3f541beff0cdc3cd061a26f69f3808e28fd139a1abc9d345",
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+
+	m.Start()
+
+	var mtx1, mtx2, mtx3 apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetHeader(apitypes.IdempotencyKeyHeader, "key1").
+		SetBody(strings.NewReader(sampleSendTX)).
+		SetResult(&mtx1).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Equal(t, "key1", mtx1.IdempotencyKey)
+
+	// A retry of the same request returns the existing transaction
+	res, err = resty.New().R().
+		SetHeader(apitypes.IdempotencyKeyHeader, "key1").
+		SetBody(strings.NewReader(sampleSendTX)).
+		SetResult(&mtx2).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Equal(t, mtx1.SequenceID, mtx2.SequenceID)
+
+	res, err = resty.New().R().
+		SetHeader(apitypes.IdempotencyKeyHeader, "key1").
+		SetBody(strings.NewReader(sampleDeployTX)).
+		SetResult(&mtx3).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Equal(t, mtx1.SequenceID, mtx3.SequenceID)
+
+	mFFC.AssertExpectations(t)
+
+}
+
+func TestSendTransactionLabels(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x106215b9c0c9372e3f541beff0cdc3cd061a26f69f3808e28fd139a1abc9d345",
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+
+	m.Start()
+
+	send := func(id, labels string) *apitypes.ManagedTX {
+		var mtx apitypes.ManagedTX
+		body := strings.Replace(sampleSendTX, `"type": "SendTransaction"`, `"type": "SendTransaction", "labels": `+labels, 1)
+		res, err := resty.New().R().
+			SetBody(strings.NewReader(strings.Replace(body, "ns1:904F177C", id, 1))).
+			SetResult(&mtx).
+			Post(url)
+		assert.NoError(t, err)
+		assert.Equal(t, 202, res.StatusCode())
+		return &mtx
+	}
+	mtx1 := send("ns1:1111177C", `{"app": "payments", "env": "prod"}`)
+	mtx2 := send("ns1:2222277C", `{"app": "payments", "env": "test"}`)
+	send("ns1:3333377C", `{"app": "payroll", "env": "prod"}`)
+
+	// Labels are returned on the transaction
+	var mtx apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetResult(&mtx).
+		Get(url + "/transactions/" + mtx1.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, map[string]string{"app": "payments", "env": "prod"}, mtx.Labels)
+
+	getTransactions := func(query string) []*apitypes.ManagedTX {
+		var txns []*apitypes.ManagedTX
+		res, err := resty.New().R().
+			SetResult(&txns).
+			Get(url + "/transactions?" + query)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		return txns
+	}
+
+	// Single label
+	txns := getTransactions("label=app=payments")
+	assert.Len(t, txns, 2)
+	assert.Equal(t, mtx2.ID, txns[0].ID)
+	assert.Equal(t, mtx1.ID, txns[1].ID)
+
+	// Multiple labels must all match
+	txns = getTransactions("label=app=payments&label=env=prod")
+	assert.Len(t, txns, 1)
+	assert.Equal(t, mtx1.ID, txns[0].ID)
+
+	txns = getTransactions("label=app=payments&label=env=staging")
+	assert.Empty(t, txns)
+
+	res, err = resty.New().R().
+		Get(url + "/transactions?label=app")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21164", res.String())
+
+	res, err = resty.New().R().
+		Get(url + "/transactions?label=app=payments&status=pending")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21165", res.String())
+
+}
+
+func TestSendTransactionRequestIDHeader(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("DeployContractPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x106215b9c0c9372e3f541beff0cdc3cd061a26f69f3808e28fd139a1abc9d345",
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+
+	m.Start()
+
+	var mtx1, mtx2 apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetHeader(apitypes.RequestIDHeader, "trace1").
+		SetBody(strings.NewReader(sampleSendTX)).
+		SetResult(&mtx1).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Equal(t, "trace1", mtx1.RequestID)
+
+	res, err = resty.New().R().
+		SetHeader(apitypes.RequestIDHeader, "trace2").
+		SetBody(strings.NewReader(strings.Replace(sampleDeployTX, "ns1:904F177C", "ns1:1111177C", 1))).
+		SetResult(&mtx2).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Equal(t, "trace2", mtx2.RequestID)
+
+	var txns []*apitypes.ManagedTX
+	res, err = resty.New().R().
+		SetResult(&txns).
+		Get(url + "/transactions?requestId=trace2")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, txns, 1)
+	assert.Equal(t, mtx2.ID, txns[0].ID)
+
+	res, err = resty.New().R().
+		SetResult(&txns).
+		Get(url + "/transactions?requestId=unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Empty(t, txns)
+
+}
+
 func TestDeployTransactionE2E(t *testing.T) {
 
 	txSent := make(chan struct{})
@@ -178,6 +382,8 @@ func TestDeployTransactionE2E(t *testing.T) {
 
 	mFFC.On("TransactionSend", mock.Anything, mock.MatchedBy(func(sendTX *ffcapi.TransactionSendRequest) bool {
 		matches := "0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8" == sendTX.From &&
+			"" == sendTX.To &&
+			sendTX.DeployContract &&
 			uint64(2000000) == sendTX.Gas.Uint64() &&
 			`223344556677` == sendTX.GasPrice.String() &&
 			"RAW_UNSIGNED_BYTES" == sendTX.TransactionData
@@ -208,6 +414,98 @@ func TestDeployTransactionE2E(t *testing.T) {
 
 }
 
+func TestDeployTransactionConfirmedContractLocation(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("DeployContractPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionSend", mock.Anything, mock.MatchedBy(func(sendTX *ffcapi.TransactionSendRequest) bool {
+		return sendTX.DeployContract
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction
+	})).Run(func(args mock.Arguments) {
+		n := args[0].(*confirmations.Notification)
+		n.Transaction.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+			BlockNumber:      fftypes.NewFFBigInt(12345),
+			BlockHash:        fftypes.NewRandB32().String(),
+			Success:          true,
+			ContractLocation: fftypes.JSONAnyPtr(`{"address":"0xd0a0a0a0"}`),
+		})
+		n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+	}).Return(nil)
+
+	m.Start()
+
+	var mtx apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetBody(strings.NewReader(sampleDeployTX)).
+		SetResult(&mtx).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.True(t, mtx.DeployContract)
+	assert.Nil(t, mtx.ContractLocation)
+
+	status, rtx, err := m.waitTransactionConfirmed(m.ctx, mtx.ID, "5s")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, apitypes.TxStatusSucceeded, rtx.Status)
+	assert.JSONEq(t, `{"address":"0xd0a0a0a0"}`, rtx.ContractLocation.String())
+
+	mFFC.AssertExpectations(t)
+
+}
+
+func TestDeployTransactionWithToAddress(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+
+	m.Start()
+
+	res, err := resty.New().R().
+		SetBody(strings.NewReader(strings.Replace(sampleDeployTX, `"gas": 1000000,`, `"gas": 1000000, "to": "0xe1a078b9e2b145d0a7387f09277c6ae1d9470771",`, 1))).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21156", res.String())
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.AssertNotCalled(t, "DeployContractPrepare", mock.Anything, mock.Anything)
+
+}
+
+func TestSendTransactionReceiptContractLocationIgnored(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendSampleTX(t, m, "0xaaaaa", 12345)
+	mtx.FirstSubmit = fftypes.Now()
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{
+		Success:          true,
+		ContractLocation: fftypes.JSONAnyPtr(`{"address":"0xd0a0a0a0"}`),
+	}
+	err := m.execPolicy(m.ctx, &pendingState{mtx: mtx, confirmed: true}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusSucceeded, mtx.Status)
+	assert.Nil(t, mtx.ContractLocation)
+
+}
+
 func TestSendInvalidRequestBadTXType(t *testing.T) {
 
 	url, m, cancel := newTestManager(t)
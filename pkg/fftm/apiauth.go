@@ -0,0 +1,73 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apiauth"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apiauths"
+)
+
+// noopAPIAuth is used when no API auth plugin is configured, and allows all requests
+type noopAPIAuth struct{}
+
+func (na *noopAPIAuth) Authorize(ctx context.Context, req *apiauth.AuthRequest) error {
+	return nil
+}
+
+func (m *manager) initAPIAuth(ctx context.Context) (err error) {
+	name := config.GetString(tmconfig.APIAuthName)
+	if name == "" {
+		m.apiAuth = &noopAPIAuth{}
+		return nil
+	}
+	m.apiAuth, err = apiauths.NewAPIAuth(ctx, tmconfig.APIAuthBaseConfig, name)
+	return err
+}
+
+// authMiddleware passes each request to the API auth plugin once it has been matched to a route,
+// so the plugin can authorize it against the route, and rejects the request if the plugin returns an error
+func (m *manager) authMiddleware(hf *ffapi.HandlerFactory) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			authReq := &apiauth.AuthRequest{
+				Method:      req.Method,
+				Path:        req.URL.Path,
+				RouteParams: mux.Vars(req),
+				Header:      req.Header,
+				TLS:         req.TLS,
+				RemoteAddr:  req.RemoteAddr,
+			}
+			if route := mux.CurrentRoute(req); route != nil {
+				authReq.Route, _ = route.GetPathTemplate()
+			}
+			if err := m.apiAuth.Authorize(req.Context(), authReq); err != nil {
+				hf.APIWrapper(func(res http.ResponseWriter, req *http.Request) (int, error) {
+					return http.StatusUnauthorized, err
+				})(res, req)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
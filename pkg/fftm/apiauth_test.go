@@ -0,0 +1,206 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apiauth"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apiauths"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubAuthFactory struct{}
+
+type stubAuth struct {
+	requests []*apiauth.AuthRequest
+}
+
+func (f *stubAuthFactory) Name() string {
+	return "stub"
+}
+
+func (f *stubAuthFactory) InitConfig(conf config.Section) {}
+
+func (f *stubAuthFactory) NewAPIAuth(ctx context.Context, conf config.Section) (apiauth.APIAuth, error) {
+	return &stubAuth{}, nil
+}
+
+// Authorize allows requests with the right bearer token, except for deleting event streams which is forbidden
+func (a *stubAuth) Authorize(ctx context.Context, req *apiauth.AuthRequest) error {
+	a.requests = append(a.requests, req)
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		return fmt.Errorf("pop")
+	}
+	if req.Method == http.MethodDelete && req.Route == "/eventstreams/{streamId}" {
+		return i18n.NewError(ctx, i18n.MsgForbidden)
+	}
+	return nil
+}
+
+// stubWSAuth additionally authorizes websocket connections, allowing the "secret" token to listen only on "stream1"
+type stubWSAuth struct {
+	stubAuth
+	identities []string
+}
+
+func (a *stubWSAuth) Authenticate(ctx context.Context, token string) (string, error) {
+	if token != "secret" {
+		return "", fmt.Errorf("pop")
+	}
+	return "user1", nil
+}
+
+func (a *stubWSAuth) AuthorizeListen(ctx context.Context, identity, stream string) error {
+	a.identities = append(a.identities, identity)
+	if stream != "stream1" {
+		return fmt.Errorf("not allowed to listen on %s", stream)
+	}
+	return nil
+}
+
+func authTestRequest(t *testing.T, method, url, token string) int {
+	req, err := http.NewRequest(method, url, nil)
+	assert.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestAPIAuthRejectsAndAllows(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	auth := &stubAuth{}
+	m.apiAuth = auth
+
+	server := httptest.NewServer(m.router())
+	defer server.Close()
+	streamID := fftypes.NewUUID().String()
+
+	// No token
+	assert.Equal(t, http.StatusUnauthorized, authTestRequest(t, http.MethodGet, server.URL+"/livez", ""))
+	assert.Equal(t, http.StatusUnauthorized, authTestRequest(t, http.MethodGet, server.URL+"/eventstreams/"+streamID, "wrong"))
+
+	// Allowed, so the route handler runs and finds no stream
+	assert.Equal(t, http.StatusOK, authTestRequest(t, http.MethodGet, server.URL+"/livez", "secret"))
+	assert.Equal(t, http.StatusNotFound, authTestRequest(t, http.MethodGet, server.URL+"/eventstreams/"+streamID, "secret"))
+
+	// Rejected per-route, with the status of the error returned by the plugin
+	assert.Equal(t, http.StatusForbidden, authTestRequest(t, http.MethodDelete, server.URL+"/eventstreams/"+streamID, "secret"))
+
+	assert.Len(t, auth.requests, 5)
+	authReq := auth.requests[4]
+	assert.Equal(t, http.MethodDelete, authReq.Method)
+	assert.Equal(t, "/eventstreams/{streamId}", authReq.Route)
+	assert.Equal(t, "/eventstreams/"+streamID, authReq.Path)
+	assert.Equal(t, streamID, authReq.RouteParams["streamId"])
+	assert.Nil(t, authReq.TLS)
+
+}
+
+func TestAPIAuthDefaultAllowsAll(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	assert.IsType(t, &noopAPIAuth{}, m.apiAuth)
+
+	server := httptest.NewServer(m.router())
+	defer server.Close()
+
+	assert.Equal(t, http.StatusOK, authTestRequest(t, http.MethodGet, server.URL+"/livez", ""))
+
+}
+
+func TestInitAPIAuthPlugin(t *testing.T) {
+
+	tmconfig.Reset()
+	apiauths.RegisterAuth(&stubAuthFactory{})
+	config.Set(tmconfig.APIAuthName, "stub")
+
+	m := newManager(context.Background(), nil)
+	err := m.initAPIAuth(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &stubAuth{}, m.apiAuth)
+
+}
+
+func TestInitAPIAuthNotRegistered(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.APIAuthName, "wrong")
+
+	m := newManager(context.Background(), nil)
+	err := m.initAPIAuth(context.Background())
+	assert.Regexp(t, "FF21107", err)
+
+}
+
+func TestAPIAuthWebSocketListen(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	auth := &stubWSAuth{}
+	m.apiAuth = auth
+	err := m.initWebSocketServer(m.ctx)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(m.router())
+	defer server.Close()
+	wsURL := strings.Replace(server.URL, "http", "ws", 1) + "/ws"
+	header := http.Header{"Authorization": []string{"Bearer secret"}}
+
+	// Allowed to listen on stream1
+	c1, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	assert.NoError(t, err)
+	defer c1.Close()
+	err = c1.WriteJSON(map[string]string{"type": "listen", "stream": "stream1"})
+	assert.NoError(t, err)
+	for !m.wsServer.HasListeners("stream1") {
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	// Rejected from listening on any other stream, with a policy violation
+	c2, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	assert.NoError(t, err)
+	defer c2.Close()
+	err = c2.WriteJSON(map[string]string{"type": "listen", "stream": "stream2"})
+	assert.NoError(t, err)
+	_ = c2.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = c2.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+	assert.Regexp(t, "not allowed to listen on stream2", err)
+	assert.False(t, m.wsServer.HasListeners("stream2"))
+
+	assert.Equal(t, []string{"user1", "user1"}, auth.identities)
+
+}
@@ -0,0 +1,47 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// startBlockListeners creates a connector block listener for each configured block listener. These
+// run on every replica, as event streams are not restricted to the leader.
+func (m *manager) startBlockListeners() error {
+	done := make([]chan struct{}, 0, len(m.blockListeners))
+	m.blockListenersDone = make(chan struct{})
+	defer func() {
+		go func() {
+			for _, d := range done {
+				<-d
+			}
+			close(m.blockListenersDone)
+		}()
+	}()
+	for _, r := range m.blockListeners {
+		blReq := &ffcapi.NewBlockListenerRequest{ListenerContext: m.ctx, ID: fftypes.NewUUID(), Filters: r.Filters}
+		var routerDone chan struct{}
+		blReq.BlockListener, routerDone = r.Start(m.ctx)
+		done = append(done, routerDone)
+		if _, _, err := m.connector.NewBlockListener(m.ctx, blReq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/blocklistener"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStartBlockListenersRoutesToStreams(t *testing.T) {
+
+	InitConfig()
+	m := newManager(context.Background(), &ffcapimocks.API{})
+	defer m.cancelCtx()
+	m.blockListeners = blocklistener.Routers{
+		"tokens": blocklistener.NewRouter("tokens", []fftypes.JSONAny{`{"address":"0x1111"}`}),
+		"nfts":   blocklistener.NewRouter("nfts", []fftypes.JSONAny{`{"address":"0x2222"}`}),
+	}
+
+	// Each configured listener is created on the connector with its own filters
+	connectorListeners := make(map[string]chan<- *ffcapi.BlockHashEvent)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NewBlockListener", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		req := args[1].(*ffcapi.NewBlockListenerRequest)
+		assert.NotNil(t, req.ID)
+		assert.Equal(t, m.ctx, req.ListenerContext)
+		connectorListeners[req.Filters[0].JSONObject().GetString("address")] = req.BlockListener
+	}).Return(&ffcapi.NewBlockListenerResponse{}, ffcapi.ErrorReason(""), nil).Twice()
+
+	err := m.startBlockListeners()
+	assert.NoError(t, err)
+	assert.Len(t, connectorListeners, 2)
+
+	tokenStream, nftStream := make(chan *ffcapi.BlockHashEvent, 1), make(chan *ffcapi.BlockHashEvent, 1)
+	m.blockListeners["tokens"].AddConsumer(fftypes.NewUUID(), tokenStream)
+	m.blockListeners["nfts"].AddConsumer(fftypes.NewUUID(), nftStream)
+
+	connectorListeners["0x1111"] <- &ffcapi.BlockHashEvent{BlockHashes: []string{"0xaaaa"}}
+	assert.Equal(t, []string{"0xaaaa"}, (<-tokenStream).BlockHashes)
+	connectorListeners["0x2222"] <- &ffcapi.BlockHashEvent{BlockHashes: []string{"0xbbbb"}}
+	assert.Equal(t, []string{"0xbbbb"}, (<-nftStream).BlockHashes)
+	assert.Empty(t, tokenStream)
+
+	// All the listeners stop with the manager
+	m.cancelCtx()
+	<-m.blockListenersDone
+	mfc.AssertExpectations(t)
+
+}
+
+func TestStartBlockListenersFail(t *testing.T) {
+
+	InitConfig()
+	m := newManager(context.Background(), &ffcapimocks.API{})
+	m.blockListeners = blocklistener.Routers{
+		"tokens": blocklistener.NewRouter("tokens", nil),
+	}
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	err := m.Start()
+	assert.Regexp(t, "pop", err)
+
+	m.cancelCtx()
+	<-m.blockListenersDone
+
+}
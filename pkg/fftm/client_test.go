@@ -0,0 +1,132 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/client"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestClientAgainstRouter runs the typed client in pkg/client against the real router, so that
+// changes to the routes that break the client are caught here. The manager is started, so the
+// routes run alongside its background loops just as they do in a running server.
+func TestClientAgainstRouter(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil)
+	mfc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "0x123456",
+		Gas:             fftypes.NewFFBigInt(100000),
+	}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(m.router())
+	defer server.Close()
+	c := client.New(&client.Options{URL: server.URL})
+	ctx := context.Background()
+
+	// Transactions
+	mtx, err := c.SubmitTransaction(ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{ID: "ns1:tx1", RequestID: "req1"},
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa", To: "0xbbbbb"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ns1:tx1", mtx.ID)
+	assert.Equal(t, int64(12345), mtx.Nonce.Int64())
+
+	mtx, err = c.GetTransaction(ctx, "ns1:tx1")
+	assert.NoError(t, err)
+	assert.Equal(t, "req1", mtx.RequestID)
+
+	txs, err := c.ListTransactions(ctx, &client.TransactionFilter{Signer: "0xaaaaa", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, txs, 1)
+	txs, err = c.ListTransactions(ctx, &client.TransactionFilter{RequestID: "req1", Direction: "asc"})
+	assert.NoError(t, err)
+	assert.Len(t, txs, 1)
+	txs, err = c.ListTransactions(ctx, &client.TransactionFilter{Status: apitypes.TxStatusSucceeded})
+	assert.NoError(t, err)
+	assert.Empty(t, txs)
+	txs, err = c.ListTransactions(ctx, nil)
+	assert.NoError(t, err)
+	assert.Len(t, txs, 1)
+
+	mtx, err = c.DeleteTransaction(ctx, "ns1:tx1")
+	assert.NoError(t, err)
+	assert.NotNil(t, mtx.DeleteRequested)
+
+	_, err = c.GetTransaction(ctx, "ns1:unknown")
+	assert.Regexp(t, "FF21134.*404.*FF21067", err)
+
+	// Event streams
+	es, err := c.CreateEventStream(ctx, &apitypes.EventStream{Name: strPtr("stream1")})
+	assert.NoError(t, err)
+	assert.NotNil(t, es.ID)
+
+	esWithStatus, err := c.GetEventStream(ctx, es.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "stream1", *esWithStatus.Name)
+
+	streams, err := c.ListEventStreams(ctx, "", 10)
+	assert.NoError(t, err)
+	assert.Len(t, streams, 1)
+
+	es, err = c.UpdateEventStream(ctx, es.ID.String(), &apitypes.EventStream{Name: strPtr("stream2")})
+	assert.NoError(t, err)
+	assert.Equal(t, "stream2", *es.Name)
+
+	err = c.SuspendEventStream(ctx, es.ID.String())
+	assert.NoError(t, err)
+	esWithStatus, err = c.GetEventStream(ctx, es.ID.String())
+	assert.NoError(t, err)
+	assert.True(t, *esWithStatus.Suspended)
+	assert.Equal(t, apitypes.EventStreamStatusStopped, esWithStatus.Status)
+
+	err = c.ResumeEventStream(ctx, es.ID.String())
+	assert.NoError(t, err)
+	esWithStatus, err = c.GetEventStream(ctx, es.ID.String())
+	assert.NoError(t, err)
+	assert.False(t, *esWithStatus.Suspended)
+	assert.Equal(t, apitypes.EventStreamStatusStarted, esWithStatus.Status)
+
+	err = c.DeleteEventStream(ctx, es.ID.String())
+	assert.NoError(t, err)
+	_, err = c.GetEventStream(ctx, es.ID.String())
+	assert.Regexp(t, "FF21134.*404", err)
+
+}
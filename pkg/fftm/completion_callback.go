@@ -0,0 +1,95 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/events"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+func validateCompletionCallback(ctx context.Context, callback string) error {
+	if callback == "" {
+		return nil
+	}
+	u, err := url.Parse(callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidCompletionCallback, callback)
+	}
+	return nil
+}
+
+// deliverCompletionCallback POSTs a transaction that has been confirmed, or has failed, to the
+// completion callback URL supplied when it was submitted. Delivery happens in the background
+// with retry, so the policy loop is never blocked. Once the configured maximum number of
+// attempts is exceeded, the delivery is logged and abandoned.
+func (m *manager) deliverCompletionCallback(mtx *apitypes.ManagedTX) {
+	if mtx.CompletionCallback == "" ||
+		(mtx.Status != apitypes.TxStatusSucceeded && mtx.Status != apitypes.TxStatusFailed) {
+		return
+	}
+	deliveryID := fftypes.NewUUID()
+	ctx := log.WithLogField(txLogContext(m.ctx, mtx), "callback", deliveryID.String())
+	txSnapshot := *mtx
+	m.callbacksActive.Add(1)
+	go func() {
+		defer m.callbacksActive.Done()
+		err := m.callbackRetry.Do(ctx, fmt.Sprintf("completion callback for transaction %s", mtx.ID), func(attempt int) (retry bool, err error) {
+			err = m.attemptCompletionCallback(ctx, deliveryID, &txSnapshot)
+			return attempt < m.callbackMaxAttempts, err
+		})
+		if err != nil {
+			log.L(ctx).Errorf("Abandoned completion callback for transaction %s to '%s': %s", txSnapshot.ID, txSnapshot.CompletionCallback, err)
+			return
+		}
+		log.L(ctx).Infof("Delivered completion callback for transaction %s (status=%s)", txSnapshot.ID, txSnapshot.Status)
+	}()
+}
+
+func (m *manager) attemptCompletionCallback(ctx context.Context, deliveryID *fftypes.UUID, mtx *apitypes.ManagedTX) error {
+	// We perform DNS resolution before each attempt, to exclude private IP address ranges from the target
+	u, _ := url.Parse(mtx.CompletionCallback)
+	if err := events.CheckWebhookHost(ctx, config.GetBool(tmconfig.WebhooksAllowPrivateIPs), u); err != nil {
+		return err
+	}
+	var resBody []byte
+	res, err := m.callbackClient.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader(apitypes.CompletionCallbackDeliveryIDHeader, deliveryID.String()).
+		SetBody(mtx).
+		SetResult(&resBody).
+		SetError(&resBody).
+		Post(u.String())
+	if err != nil {
+		return i18n.NewError(ctx, tmmsgs.MsgWebhookErr, err)
+	}
+	if res.IsError() {
+		log.L(ctx).Errorf("Completion callback %s [%d]: %s", u, res.StatusCode(), resBody)
+		return i18n.NewError(ctx, tmmsgs.MsgWebhookFailedStatus, res.StatusCode())
+	}
+	return nil
+}
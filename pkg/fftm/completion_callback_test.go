@@ -0,0 +1,243 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testCallback struct {
+	deliveryID string
+	mtx        *apitypes.ManagedTX
+}
+
+func newTestCallbackServer(t *testing.T, failures int) (*httptest.Server, chan *testCallback) {
+	delivered := make(chan *testCallback, 10)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		attempts++
+		if attempts <= failures {
+			w.WriteHeader(500)
+			return
+		}
+		var mtx apitypes.ManagedTX
+		err := json.NewDecoder(r.Body).Decode(&mtx)
+		assert.NoError(t, err)
+		w.WriteHeader(204)
+		delivered <- &testCallback{
+			deliveryID: r.Header.Get(apitypes.CompletionCallbackDeliveryIDHeader),
+			mtx:        &mtx,
+		}
+	}))
+	return server, delivered
+}
+
+func newTestManagerCallbacks(t *testing.T) (*manager, func()) {
+	_, m, cancel := newTestManagerMockPersistence(t)
+	m.callbackRetry.InitialDelay = 0
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil)
+	return m, cancel
+}
+
+func TestCompletionCallbackOnConfirm(t *testing.T) {
+
+	m, cancel := newTestManagerCallbacks(t)
+	defer cancel()
+
+	server, delivered := newTestCallbackServer(t, 2)
+	defer server.Close()
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	mtx.CompletionCallback = server.URL
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+	pending := &pendingState{mtx: mtx, confirmed: true}
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+
+	cb := <-delivered
+	assert.Equal(t, mtx.ID, cb.mtx.ID)
+	assert.Equal(t, apitypes.TxStatusSucceeded, cb.mtx.Status)
+	assert.NotEmpty(t, cb.deliveryID)
+
+}
+
+func TestCompletionCallbackOnFail(t *testing.T) {
+
+	m, cancel := newTestManagerCallbacks(t)
+	defer cancel()
+
+	server, delivered := newTestCallbackServer(t, 0)
+	defer server.Close()
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	mtx.CompletionCallback = server.URL
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{Success: false}
+	pending := &pendingState{mtx: mtx, confirmed: true}
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+
+	cb := <-delivered
+	assert.Equal(t, mtx.ID, cb.mtx.ID)
+	assert.Equal(t, apitypes.TxStatusFailed, cb.mtx.Status)
+	assert.Regexp(t, "FF21066", cb.mtx.ErrorMessage)
+
+}
+
+func TestCompletionCallbackSameDeliveryIDOnRetry(t *testing.T) {
+
+	m, cancel := newTestManagerCallbacks(t)
+	defer cancel()
+
+	deliveryIDs := make(chan string, 2)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveryIDs <- r.Header.Get(apitypes.CompletionCallbackDeliveryIDHeader)
+		attempts++
+		if attempts == 1 {
+			// Simulate a failure on the first attempt
+			w.WriteHeader(500)
+			return
+		}
+		w.WriteHeader(204)
+	}))
+	defer server.Close()
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusSucceeded)
+	mtx.CompletionCallback = server.URL
+	m.deliverCompletionCallback(mtx)
+
+	id1 := <-deliveryIDs
+	id2 := <-deliveryIDs
+	assert.NotEmpty(t, id1)
+	assert.Equal(t, id1, id2)
+
+}
+
+func TestCompletionCallbackAbandoned(t *testing.T) {
+
+	m, cancel := newTestManagerCallbacks(t)
+	defer cancel()
+	m.callbackMaxAttempts = 3
+
+	attempts := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- struct{}{}
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusFailed)
+	mtx.CompletionCallback = server.URL
+
+	done := make(chan struct{})
+	m.callbackRetry.ErrCallback = func(err error) {
+		if len(attempts) == m.callbackMaxAttempts {
+			close(done)
+		}
+	}
+	m.deliverCompletionCallback(mtx)
+	<-done
+
+	assert.Len(t, attempts, 3)
+
+}
+
+func TestCompletionCallbackBlockedAddress(t *testing.T) {
+
+	m, cancel := newTestManagerCallbacks(t)
+	defer cancel()
+	config.Set(tmconfig.WebhooksAllowPrivateIPs, false)
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusSucceeded)
+	mtx.CompletionCallback = "http://127.0.0.1:12345"
+	err := m.attemptCompletionCallback(context.Background(), nil, mtx)
+	assert.Regexp(t, "FF21033", err)
+
+}
+
+func TestCompletionCallbackRequestFail(t *testing.T) {
+
+	m, cancel := newTestManagerCallbacks(t)
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusSucceeded)
+	mtx.CompletionCallback = server.URL
+	err := m.attemptCompletionCallback(context.Background(), nil, mtx)
+	assert.Regexp(t, "FF21042", err)
+
+}
+
+func TestCompletionCallbackNotTerminal(t *testing.T) {
+
+	m, cancel := newTestManagerCallbacks(t)
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "unexpected callback")
+	}))
+	defer server.Close()
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusWouldSubmit)
+	mtx.CompletionCallback = server.URL
+	m.deliverCompletionCallback(mtx)
+
+}
+
+func TestValidateCompletionCallback(t *testing.T) {
+
+	ctx := context.Background()
+	assert.NoError(t, validateCompletionCallback(ctx, ""))
+	assert.NoError(t, validateCompletionCallback(ctx, "https://example.com/callback"))
+	for _, invalid := range []string{"ftp://example.com", "http://", "::not a url", "/relative"} {
+		assert.Regexp(t, "FF21080", validateCompletionCallback(ctx, invalid), fmt.Sprintf("url=%s", invalid))
+	}
+
+}
+
+func TestSubmitInvalidCompletionCallback(t *testing.T) {
+
+	m, cancel := newTestManagerCallbacks(t)
+	defer cancel()
+
+	_, err := m.submitPreparedTX(context.Background(), &apitypes.RequestHeaders{
+		ID:                 "id1",
+		CompletionCallback: "not a url",
+	}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, nil, "")
+	assert.Regexp(t, "FF21080", err)
+
+}
@@ -0,0 +1,167 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/internal/events"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+func (m *manager) initConfirmationWebhooks(ctx context.Context) error {
+	configured := config.GetStringSlice(tmconfig.TransactionsConfirmationWebhookMilestones)
+	milestones := make([]int, len(configured))
+	for i, s := range configured {
+		milestone, err := strconv.Atoi(s)
+		if err != nil {
+			return i18n.NewError(ctx, tmmsgs.MsgInvalidConfirmationMilestones, configured, config.GetInt(tmconfig.ConfirmationsMaxRequired))
+		}
+		milestones[i] = milestone
+	}
+	var err error
+	m.webhookMilestones, err = validateMilestones(ctx, milestones)
+	return err
+}
+
+// validateMilestones returns the milestones in ascending order without duplicates, checking each is a number of
+// confirmations the confirmation manager can reasonably be asked to track a transaction for
+func validateMilestones(ctx context.Context, milestones []int) ([]int, error) {
+	maxMilestone := config.GetInt(tmconfig.ConfirmationsMaxRequired)
+	sorted := make([]int, 0, len(milestones))
+	for _, milestone := range milestones {
+		if milestone < 1 || milestone > maxMilestone {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidConfirmationMilestones, milestones, maxMilestone)
+		}
+		sorted = append(sorted, milestone)
+	}
+	sort.Ints(sorted)
+	deduped := sorted[:0]
+	for i, milestone := range sorted {
+		if i == 0 || milestone != sorted[i-1] {
+			deduped = append(deduped, milestone)
+		}
+	}
+	return deduped, nil
+}
+
+// validateConfirmationWebhook checks the confirmation webhook of a submission, and returns the milestones to notify
+// it at - which are the configured milestones, unless the submission supplies its own
+func (m *manager) validateConfirmationWebhook(ctx context.Context, reqHeaders *apitypes.RequestHeaders) ([]int, error) {
+	if reqHeaders.ConfirmationWebhook == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(reqHeaders.ConfirmationWebhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidConfirmationWebhook, reqHeaders.ConfirmationWebhook)
+	}
+	if m.webhookSecret == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgConfirmationWebhookNoSecret)
+	}
+	if len(reqHeaders.Milestones) == 0 {
+		return m.webhookMilestones, nil
+	}
+	return validateMilestones(ctx, reqHeaders.Milestones)
+}
+
+// signWebhookPayload returns the value of the signature header for a payload, which the receiver can verify by
+// computing the HMAC-SHA256 of the request body with the shared secret
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverConfirmationMilestone POSTs a signed notification to the confirmation webhook of a transaction, when the
+// confirmation manager reports it has reached one of its milestones. Like completion callbacks, delivery happens in
+// the background with retry, and is abandoned once the configured maximum number of attempts is exceeded.
+func (m *manager) deliverConfirmationMilestone(pending *pendingState, blocks []confirmations.BlockInfo) {
+	m.mux.Lock()
+	mtx := pending.mtx
+	milestone := &apitypes.ConfirmationMilestone{
+		ID:              mtx.ID,
+		RequestID:       mtx.RequestID,
+		TransactionHash: mtx.TransactionHash,
+		Milestone:       len(blocks),
+		Confirmations:   blocks,
+	}
+	if mtx.Receipt != nil {
+		milestone.BlockNumber = mtx.Receipt.BlockNumber
+		milestone.BlockHash = mtx.Receipt.BlockHash
+	}
+	webhook := mtx.ConfirmationWebhook
+	ctx := txLogContext(m.ctx, mtx)
+	m.mux.Unlock()
+
+	deliveryID := fftypes.NewUUID()
+	ctx = log.WithLogField(ctx, "webhook", deliveryID.String())
+	payload, _ := json.Marshal(milestone)
+	m.callbacksActive.Add(1)
+	go func() {
+		defer m.callbacksActive.Done()
+		err := m.webhookRetry.Do(ctx, fmt.Sprintf("confirmation webhook for transaction %s", milestone.ID), func(attempt int) (retry bool, err error) {
+			err = m.attemptConfirmationWebhook(ctx, deliveryID, webhook, payload)
+			return attempt < m.webhookMaxAttempts, err
+		})
+		if err != nil {
+			log.L(ctx).Errorf("Abandoned confirmation webhook for transaction %s at %d confirmations to '%s': %s", milestone.ID, milestone.Milestone, webhook, err)
+			return
+		}
+		log.L(ctx).Infof("Delivered confirmation webhook for transaction %s at %d confirmations", milestone.ID, milestone.Milestone)
+	}()
+}
+
+func (m *manager) attemptConfirmationWebhook(ctx context.Context, deliveryID *fftypes.UUID, webhook string, payload []byte) error {
+	// We perform DNS resolution before each attempt, to exclude private IP address ranges from the target
+	u, _ := url.Parse(webhook)
+	if err := events.CheckWebhookHost(ctx, config.GetBool(tmconfig.WebhooksAllowPrivateIPs), u); err != nil {
+		return err
+	}
+	var resBody []byte
+	res, err := m.callbackClient.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader(apitypes.CompletionCallbackDeliveryIDHeader, deliveryID.String()).
+		SetHeader(apitypes.ConfirmationWebhookSignatureHeader, signWebhookPayload(m.webhookSecret, payload)).
+		SetBody(payload).
+		SetResult(&resBody).
+		SetError(&resBody).
+		Post(u.String())
+	if err != nil {
+		return i18n.NewError(ctx, tmmsgs.MsgWebhookErr, err)
+	}
+	if res.IsError() {
+		log.L(ctx).Errorf("Confirmation webhook %s [%d]: %s", u, res.StatusCode(), resBody)
+		return i18n.NewError(ctx, tmmsgs.MsgWebhookFailedStatus, res.StatusCode())
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testWebhookSecret = "s3cret"
+
+func newTestManagerWebhooks(t *testing.T) (*manager, func()) {
+	_, m, cancel := newTestManagerMockPersistence(t)
+	m.webhookSecret = testWebhookSecret
+	m.webhookRetry.InitialDelay = 0
+	return m, cancel
+}
+
+// newTestWebhookServer verifies the signature of each delivery, failing the first attempt of each to exercise retry
+func newTestWebhookServer(t *testing.T) (*httptest.Server, chan *apitypes.ConfirmationMilestone) {
+	delivered := make(chan *apitypes.ConfirmationMilestone, 10)
+	attempted := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		deliveryID := r.Header.Get(apitypes.CompletionCallbackDeliveryIDHeader)
+		if !attempted[deliveryID] {
+			attempted[deliveryID] = true
+			w.WriteHeader(500)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get(apitypes.ConfirmationWebhookSignatureHeader))
+		var milestone apitypes.ConfirmationMilestone
+		err = json.Unmarshal(body, &milestone)
+		assert.NoError(t, err)
+		w.WriteHeader(204)
+		delivered <- &milestone
+	}))
+	return server, delivered
+}
+
+func testConfirmationBlocks(count int) []confirmations.BlockInfo {
+	blocks := make([]confirmations.BlockInfo, count)
+	for i := range blocks {
+		blocks[i] = confirmations.BlockInfo{
+			BlockNumber: fftypes.FFuint64(1002 + i),
+			BlockHash:   fftypes.NewRandB32().String(),
+		}
+	}
+	return blocks
+}
+
+func TestConfirmationWebhookMilestones(t *testing.T) {
+
+	m, cancel := newTestManagerWebhooks(t)
+	defer cancel()
+
+	server, delivered := newTestWebhookServer(t)
+	defer server.Close()
+
+	var notification *confirmations.Notification
+	mcm := &confirmationsmocks.Manager{}
+	mcm.On("Notify", mock.Anything).Run(func(args mock.Arguments) {
+		notification = args[0].(*confirmations.Notification)
+	}).Return(nil)
+	m.confirmations = mcm
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	mtx.TransactionHash = "0x1111"
+	mtx.ConfirmationWebhook = server.URL
+	mtx.Milestones = []int{1, 6, 12}
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{
+		BlockNumber: fftypes.NewFFBigInt(1001),
+		BlockHash:   "0x2222",
+	}
+	pending := &pendingState{mtx: mtx}
+	m.trackSubmittedTransaction(m.ctx, pending)
+	assert.Equal(t, []int{1, 6, 12}, notification.Transaction.Milestones)
+
+	// The confirmation manager calls back at each milestone, with the confirmations up to that depth
+	for _, depth := range []int{1, 6, 12} {
+		notification.Transaction.Milestone(m.ctx, testConfirmationBlocks(depth))
+		milestone := <-delivered
+		assert.Equal(t, mtx.ID, milestone.ID)
+		assert.Equal(t, "0x1111", milestone.TransactionHash)
+		assert.Equal(t, int64(1001), milestone.BlockNumber.Int64())
+		assert.Equal(t, "0x2222", milestone.BlockHash)
+		assert.Equal(t, depth, milestone.Milestone)
+		assert.Len(t, milestone.Confirmations, depth)
+	}
+
+	mcm.AssertExpectations(t)
+
+}
+
+func TestConfirmationWebhookNotRegistered(t *testing.T) {
+
+	m, cancel := newTestManagerWebhooks(t)
+	defer cancel()
+
+	var notification *confirmations.Notification
+	mcm := &confirmationsmocks.Manager{}
+	mcm.On("Notify", mock.Anything).Run(func(args mock.Arguments) {
+		notification = args[0].(*confirmations.Notification)
+	}).Return(nil)
+	m.confirmations = mcm
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	mtx.TransactionHash = "0x1111"
+	m.trackSubmittedTransaction(m.ctx, &pendingState{mtx: mtx})
+	assert.Empty(t, notification.Transaction.Milestones)
+	assert.Nil(t, notification.Transaction.Milestone)
+
+}
+
+func TestConfirmationWebhookAbandoned(t *testing.T) {
+
+	m, cancel := newTestManagerWebhooks(t)
+	defer cancel()
+	m.webhookMaxAttempts = 3
+
+	attempts := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- struct{}{}
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	mtx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	mtx.ConfirmationWebhook = server.URL
+
+	done := make(chan struct{})
+	m.webhookRetry.ErrCallback = func(err error) {
+		if len(attempts) == m.webhookMaxAttempts {
+			close(done)
+		}
+	}
+	m.deliverConfirmationMilestone(&pendingState{mtx: mtx}, testConfirmationBlocks(1))
+	<-done
+
+	assert.Len(t, attempts, 3)
+
+}
+
+func TestConfirmationWebhookBlockedAddress(t *testing.T) {
+
+	m, cancel := newTestManagerWebhooks(t)
+	defer cancel()
+	config.Set(tmconfig.WebhooksAllowPrivateIPs, false)
+
+	err := m.attemptConfirmationWebhook(context.Background(), fftypes.NewUUID(), "http://127.0.0.1:12345", []byte("{}"))
+	assert.Regexp(t, "FF21033", err)
+
+}
+
+func TestConfirmationWebhookRequestFail(t *testing.T) {
+
+	m, cancel := newTestManagerWebhooks(t)
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	err := m.attemptConfirmationWebhook(context.Background(), fftypes.NewUUID(), server.URL, []byte("{}"))
+	assert.Regexp(t, "FF21042", err)
+
+}
+
+func TestValidateConfirmationWebhook(t *testing.T) {
+
+	m, cancel := newTestManagerWebhooks(t)
+	defer cancel()
+	ctx := context.Background()
+
+	milestones, err := m.validateConfirmationWebhook(ctx, &apitypes.RequestHeaders{})
+	assert.NoError(t, err)
+	assert.Nil(t, milestones)
+
+	// The configured milestones are used by default
+	milestones, err = m.validateConfirmationWebhook(ctx, &apitypes.RequestHeaders{ConfirmationWebhook: "https://example.com/hook"})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 6, 12}, milestones)
+
+	milestones, err = m.validateConfirmationWebhook(ctx, &apitypes.RequestHeaders{ConfirmationWebhook: "https://example.com/hook", Milestones: []int{12, 3, 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 12}, milestones)
+
+	_, err = m.validateConfirmationWebhook(ctx, &apitypes.RequestHeaders{ConfirmationWebhook: "https://example.com/hook", Milestones: []int{0}})
+	assert.Regexp(t, "FF21198", err)
+
+	_, err = m.validateConfirmationWebhook(ctx, &apitypes.RequestHeaders{ConfirmationWebhook: "https://example.com/hook", Milestones: []int{101}})
+	assert.Regexp(t, "FF21198", err)
+
+	_, err = m.validateConfirmationWebhook(ctx, &apitypes.RequestHeaders{ConfirmationWebhook: "ftp://example.com/hook"})
+	assert.Regexp(t, "FF21196", err)
+
+	m.webhookSecret = ""
+	_, err = m.validateConfirmationWebhook(ctx, &apitypes.RequestHeaders{ConfirmationWebhook: "https://example.com/hook"})
+	assert.Regexp(t, "FF21197", err)
+
+}
+
+func TestInitConfirmationWebhooksBadMilestones(t *testing.T) {
+
+	tmconfig.Reset()
+	m := newManager(context.Background(), nil)
+
+	config.Set(tmconfig.TransactionsConfirmationWebhookMilestones, []string{"bad"})
+	err := m.initConfirmationWebhooks(context.Background())
+	assert.Regexp(t, "FF21198", err)
+
+	config.Set(tmconfig.TransactionsConfirmationWebhookMilestones, []int{0})
+	err = m.initConfirmationWebhooks(context.Background())
+	assert.Regexp(t, "FF21198", err)
+
+}
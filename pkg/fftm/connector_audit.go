@@ -0,0 +1,350 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redactedValue replaces the value of any field configured for redaction in the connector audit log
+const redactedValue = "***"
+
+// connectorAudit logs every call to the connector, with the method, the request and response with
+// any sensitive fields redacted, the duration, and the error reason and error if the call failed.
+// Each call is a single log entry, with these in structured fields.
+// When tracing is enabled, it also records a span for each call, as a child of the span of the caller.
+//
+// It is only put in place when the audit log or tracing is enabled, directly around the connector, so that it logs the calls that
+// actually reach the connector - beneath the circuit breaker if that is also enabled. Like the breaker
+// it implements all the optional connector interfaces, so checks of the optional interfaces the
+// connector implements must be made on the connector.
+type connectorAudit struct {
+	ffcapi.API
+	log    bool // false if only in place for tracing
+	level  logrus.Level
+	redact map[string]bool
+	tracer trace.Tracer // nil unless tracing is enabled
+}
+
+func newConnectorAudit(connector ffcapi.API, level logrus.Level, redactFields []string) *connectorAudit {
+	ca := &connectorAudit{
+		API:    connector,
+		log:    true,
+		level:  level,
+		redact: make(map[string]bool),
+	}
+	for _, f := range redactFields {
+		ca.redact[f] = true
+	}
+	return ca
+}
+
+// initConnectorAudit must be called before the connector is passed to any other component, and
+// before initErrorReasons, so that the audit log is beneath the circuit breaker
+func (m *manager) initConnectorAudit(ctx context.Context) error {
+	enabled := config.GetBool(tmconfig.ConnectorAuditEnabled)
+	if !enabled && m.tracerProvider == nil {
+		return nil
+	}
+	levelStr := config.GetString(tmconfig.ConnectorAuditLevel)
+	level, err := logrus.ParseLevel(levelStr)
+	if err != nil {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidConnectorAuditLevel, levelStr)
+	}
+	m.connectorAudit = newConnectorAudit(m.baseConnector(), level, config.GetStringSlice(tmconfig.ConnectorAuditRedactFields))
+	m.connectorAudit.log = enabled
+	if m.tracerProvider != nil {
+		m.connectorAudit.tracer = m.tracer
+	}
+	if m.connectorBreaker != nil {
+		m.connectorBreaker.API = m.connectorAudit
+	} else {
+		m.connector = m.connectorAudit
+	}
+	return nil
+}
+
+// sanitize returns the JSON of a request or response, with any fields configured for redaction replaced
+func (ca *connectorAudit) sanitize(v interface{}) *fftypes.JSONAny {
+	b, err := json.Marshal(v)
+	if err != nil {
+		// Such as a request containing channels to deliver events on
+		return fftypes.JSONAnyPtr(fmt.Sprintf(`"%T"`, v))
+	}
+	if len(ca.redact) > 0 {
+		var parsed interface{}
+		_ = json.Unmarshal(b, &parsed)
+		b, _ = json.Marshal(ca.redactValue(parsed))
+	}
+	return fftypes.JSONAnyPtrBytes(b)
+}
+
+func (ca *connectorAudit) redactValue(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range vt {
+			if ca.redact[k] {
+				vt[k] = redactedValue
+			} else {
+				vt[k] = ca.redactValue(fv)
+			}
+		}
+	case []interface{}:
+		for i, iv := range vt {
+			vt[i] = ca.redactValue(iv)
+		}
+	}
+	return v
+}
+
+func (ca *connectorAudit) record(ctx context.Context, method string, startTime time.Time, req, res interface{}, reason ffcapi.ErrorReason, err error) {
+	if ca.tracer != nil {
+		// The span is recorded once the call is complete, with the time the call started
+		_, span := ca.tracer.Start(ctx, "connector."+method, trace.WithTimestamp(startTime), trace.WithSpanKind(trace.SpanKindClient))
+		if err != nil {
+			span.SetAttributes(attribute.String("fftm.connector.reason", string(reason)))
+		}
+		endSpan(span, err)
+	}
+	l := log.L(ctx)
+	if !ca.log || !l.Logger.IsLevelEnabled(ca.level) {
+		return
+	}
+	fields := logrus.Fields{
+		"method":     method,
+		"request":    ca.sanitize(req),
+		"durationMs": float64(time.Since(startTime)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		fields["reason"] = reason
+		fields[logrus.ErrorKey] = err.Error()
+	} else {
+		fields["response"] = ca.sanitize(res)
+	}
+	l.WithFields(fields).Log(ca.level, "Connector audit")
+}
+
+func (ca *connectorAudit) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (*ffcapi.BlockInfoByHashResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.BlockInfoByHash(ctx, req)
+	ca.record(ctx, "BlockInfoByHash", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (*ffcapi.BlockInfoByNumberResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.BlockInfoByNumber(ctx, req)
+	ca.record(ctx, "BlockInfoByNumber", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (*ffcapi.NextNonceForSignerResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.NextNonceForSigner(ctx, req)
+	ca.record(ctx, "NextNonceForSigner", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (*ffcapi.GasPriceEstimateResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.GasPriceEstimate(ctx, req)
+	ca.record(ctx, "GasPriceEstimate", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (*ffcapi.QueryInvokeResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.QueryInvoke(ctx, req)
+	ca.record(ctx, "QueryInvoke", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (*ffcapi.TransactionReceiptResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.TransactionReceipt(ctx, req)
+	ca.record(ctx, "TransactionReceipt", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.TransactionPrepare(ctx, req)
+	ca.record(ctx, "TransactionPrepare", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.TransactionSend(ctx, req)
+	ca.record(ctx, "TransactionSend", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.DeployContractPrepare(ctx, req)
+	ca.record(ctx, "DeployContractPrepare", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (*ffcapi.EventStreamStartResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.EventStreamStart(ctx, req)
+	ca.record(ctx, "EventStreamStart", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (*ffcapi.EventStreamStoppedResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.EventStreamStopped(ctx, req)
+	ca.record(ctx, "EventStreamStopped", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (*ffcapi.EventListenerVerifyOptionsResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.EventListenerVerifyOptions(ctx, req)
+	ca.record(ctx, "EventListenerVerifyOptions", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (*ffcapi.EventListenerAddResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.EventListenerAdd(ctx, req)
+	ca.record(ctx, "EventListenerAdd", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (*ffcapi.EventListenerRemoveResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.EventListenerRemove(ctx, req)
+	ca.record(ctx, "EventListenerRemove", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (*ffcapi.EventListenerHWMResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.EventListenerHWM(ctx, req)
+	ca.record(ctx, "EventListenerHWM", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (*ffcapi.NewBlockListenerResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.NewBlockListener(ctx, req)
+	ca.record(ctx, "NewBlockListener", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) ConnectorInfo(ctx context.Context, req *ffcapi.ConnectorInfoRequest) (*ffcapi.ConnectorInfoResponse, ffcapi.ErrorReason, error) {
+	startTime := time.Now()
+	res, reason, err := ca.API.ConnectorInfo(ctx, req)
+	ca.record(ctx, "ConnectorInfo", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) TransactionReceipts(ctx context.Context, req *ffcapi.TransactionReceiptsRequest) (*ffcapi.TransactionReceiptsResponse, ffcapi.ErrorReason, error) {
+	batchAPI, ok := ca.API.(ffcapi.BatchReceiptAPI)
+	if !ok {
+		// Fall back to individual calls, which are each logged, hiding this function so we do not recurse
+		return ffcapi.TransactionReceipts(ctx, struct{ ffcapi.API }{ca}, req)
+	}
+	startTime := time.Now()
+	res, reason, err := batchAPI.TransactionReceipts(ctx, req)
+	ca.record(ctx, "TransactionReceipts", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) TransactionTrace(ctx context.Context, req *ffcapi.TransactionTraceRequest) (*ffcapi.TransactionTraceResponse, ffcapi.ErrorReason, error) {
+	traceAPI, ok := ca.API.(ffcapi.TraceAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "TraceAPI")
+	}
+	startTime := time.Now()
+	res, reason, err := traceAPI.TransactionTrace(ctx, req)
+	ca.record(ctx, "TransactionTrace", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) TransactionSendRaw(ctx context.Context, req *ffcapi.TransactionSendRawRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	rawAPI, ok := ca.API.(ffcapi.RawTransactionAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "RawTransactionAPI")
+	}
+	startTime := time.Now()
+	res, reason, err := rawAPI.TransactionSendRaw(ctx, req)
+	ca.record(ctx, "TransactionSendRaw", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) EventListenerReplay(ctx context.Context, req *ffcapi.EventListenerReplayRequest) (*ffcapi.EventListenerReplayResponse, ffcapi.ErrorReason, error) {
+	replayAPI, ok := ca.API.(ffcapi.EventReplayAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "EventReplayAPI")
+	}
+	startTime := time.Now()
+	res, reason, err := replayAPI.EventListenerReplay(ctx, req)
+	ca.record(ctx, "EventListenerReplay", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) SignTypedData(ctx context.Context, req *ffcapi.SignTypedDataRequest) (*ffcapi.SignTypedDataResponse, ffcapi.ErrorReason, error) {
+	typedDataAPI, ok := ca.API.(ffcapi.TypedDataAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "TypedDataAPI")
+	}
+	startTime := time.Now()
+	res, reason, err := typedDataAPI.SignTypedData(ctx, req)
+	ca.record(ctx, "SignTypedData", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) FinalizedBlock(ctx context.Context, req *ffcapi.FinalizedBlockRequest) (*ffcapi.FinalizedBlockResponse, ffcapi.ErrorReason, error) {
+	finalityAPI, ok := ca.API.(ffcapi.FinalityAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "FinalityAPI")
+	}
+	startTime := time.Now()
+	res, reason, err := finalityAPI.FinalizedBlock(ctx, req)
+	ca.record(ctx, "FinalizedBlock", startTime, req, res, reason, err)
+	return res, reason, err
+}
+
+func (ca *connectorAudit) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (*ffcapi.TransactionSimulateResponse, ffcapi.ErrorReason, error) {
+	simulationAPI, ok := ca.API.(ffcapi.SimulationAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "SimulationAPI")
+	}
+	startTime := time.Now()
+	res, reason, err := simulationAPI.TransactionSimulate(ctx, req)
+	ca.record(ctx, "TransactionSimulate", startTime, req, res, reason, err)
+	return res, reason, err
+}
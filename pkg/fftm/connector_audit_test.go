@@ -0,0 +1,245 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func auditEntries(logHook *logtest.Hook) []*logrus.Entry {
+	entries := []*logrus.Entry{}
+	for _, e := range logHook.AllEntries() {
+		if e.Message == "Connector audit" {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func TestConnectorAuditAllCallsLogged(t *testing.T) {
+
+	logHook := logtest.NewGlobal()
+	defer logHook.Reset()
+
+	for name, call := range breakerCalls {
+		fc, mocks := newTestFullConnector()
+		for _, m := range mocks {
+			m.On(name, mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), nil).Maybe()
+		}
+		// A breaker that never opens, so we can reuse the calls through it
+		cb := newConnectorBreaker(newConnectorAudit(fc, logrus.InfoLevel, nil), 0, 0)
+		logHook.Reset()
+		assert.NoError(t, call(context.Background(), cb), name)
+		entries := auditEntries(logHook)
+		assert.Len(t, entries, 1, name)
+		assert.Equal(t, name, entries[0].Data["method"])
+		assert.Equal(t, logrus.InfoLevel, entries[0].Level)
+	}
+
+}
+
+func TestConnectorAuditSendRedacted(t *testing.T) {
+
+	logHook := logtest.NewGlobal()
+	defer logHook.Reset()
+
+	fc, _ := newTestFullConnector()
+	fc.RawTransactionAPI.On("TransactionSendRaw", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+	ca := newConnectorAudit(fc, logrus.WarnLevel, []string{"rawTransaction"})
+
+	res, _, err := ca.TransactionSendRaw(context.Background(), &ffcapi.TransactionSendRawRequest{
+		RawTransaction: "0xsecret",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x12345", res.TransactionHash)
+
+	entries := auditEntries(logHook)
+	assert.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, logrus.WarnLevel, e.Level)
+	assert.Equal(t, "TransactionSendRaw", e.Data["method"])
+	assert.JSONEq(t, `{"rawTransaction":"***"}`, e.Data["request"].(*fftypes.JSONAny).String())
+	assert.JSONEq(t, `{"transactionHash":"0x12345"}`, e.Data["response"].(*fftypes.JSONAny).String())
+	assert.IsType(t, float64(0), e.Data["durationMs"])
+	assert.NotContains(t, e.Data, logrus.ErrorKey)
+
+}
+
+func TestConnectorAuditSendError(t *testing.T) {
+
+	logHook := logtest.NewGlobal()
+	defer logHook.Reset()
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low"))
+	ca := newConnectorAudit(mfc, logrus.InfoLevel, []string{"gasPrice"})
+
+	_, reason, err := ca.TransactionSend(context.Background(), &ffcapi.TransactionSendRequest{
+		GasPrice:        fftypes.JSONAnyPtr(`{"maxFeePerGas":12345}`),
+		TransactionData: "0xfeedbeef",
+	})
+	assert.Regexp(t, "nonce too low", err)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+
+	entries := auditEntries(logHook)
+	assert.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, "TransactionSend", e.Data["method"])
+	assert.Regexp(t, `"gasPrice":"\*\*\*"`, e.Data["request"].(*fftypes.JSONAny).String())
+	assert.Regexp(t, `"transactionData":"0xfeedbeef"`, e.Data["request"].(*fftypes.JSONAny).String())
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, e.Data["reason"])
+	assert.Equal(t, "nonce too low", e.Data[logrus.ErrorKey])
+	assert.NotContains(t, e.Data, "response")
+
+}
+
+func TestConnectorAuditRedactNested(t *testing.T) {
+
+	ca := newConnectorAudit(&ffcapimocks.API{}, logrus.InfoLevel, []string{"signature"})
+	sanitized := ca.sanitize(map[string]interface{}{
+		"results": []interface{}{
+			map[string]interface{}{"signature": "0xaaaa", "hash": "0xbbbb"},
+		},
+	})
+	assert.JSONEq(t, `{"results":[{"signature":"***","hash":"0xbbbb"}]}`, sanitized.String())
+
+	// Requests that cannot be serialized are logged by type
+	sanitized = ca.sanitize(&ffcapi.EventStreamStartRequest{})
+	assert.Equal(t, `"*ffcapi.EventStreamStartRequest"`, sanitized.String())
+
+}
+
+func TestConnectorAuditLevelDisabled(t *testing.T) {
+
+	logHook := logtest.NewGlobal()
+	defer logHook.Reset()
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{}, ffcapi.ErrorReason(""), nil)
+	ca := newConnectorAudit(mfc, logrus.TraceLevel, nil)
+	_, _, err := ca.ConnectorInfo(context.Background(), &ffcapi.ConnectorInfoRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, auditEntries(logHook))
+
+}
+
+func TestConnectorAuditOptionalInterfacesNotImplemented(t *testing.T) {
+
+	logHook := logtest.NewGlobal()
+	defer logHook.Reset()
+
+	mfc := &ffcapimocks.API{}
+	ca := newConnectorAudit(mfc, logrus.InfoLevel, nil)
+	cb := newConnectorBreaker(ca, 0, 0)
+	ctx := context.Background()
+
+	assert.Regexp(t, "FF21126.*TraceAPI", breakerCalls["TransactionTrace"](ctx, cb))
+	assert.Regexp(t, "FF21126.*RawTransactionAPI", breakerCalls["TransactionSendRaw"](ctx, cb))
+	assert.Regexp(t, "FF21126.*EventReplayAPI", breakerCalls["EventListenerReplay"](ctx, cb))
+	assert.Regexp(t, "FF21126.*TypedDataAPI", breakerCalls["SignTypedData"](ctx, cb))
+	assert.Regexp(t, "FF21126.*FinalityAPI", breakerCalls["FinalizedBlock"](ctx, cb))
+	assert.Regexp(t, "FF21126.*SimulationAPI", breakerCalls["TransactionSimulate"](ctx, cb))
+	assert.Empty(t, auditEntries(logHook))
+
+	// Receipts fall back to individual calls, which are each logged
+	mfc.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found"))
+	_, _, err := ca.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{
+		TransactionHashes: []string{"0x1111", "0x2222"},
+	})
+	assert.NoError(t, err)
+	entries := auditEntries(logHook)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "TransactionReceipt", entries[0].Data["method"])
+
+}
+
+func TestInitConnectorAuditBeneathBreaker(t *testing.T) {
+
+	InitConfig()
+	config.Set(tmconfig.ConnectorAuditEnabled, true)
+	config.Set(tmconfig.ConnectorAuditLevel, "debug")
+	config.Set(tmconfig.CircuitBreakerFailureThreshold, 1)
+	mfc := &ffcapimocks.API{}
+	m := newManager(context.Background(), mfc)
+	defer m.cancelCtx()
+	err := m.initConnectorAudit(m.ctx)
+	assert.NoError(t, err)
+
+	assert.Equal(t, m.connectorBreaker, m.connector)
+	assert.Equal(t, m.connectorAudit, m.connectorBreaker.API)
+	assert.Equal(t, logrus.DebugLevel, m.connectorAudit.level)
+	assert.True(t, m.connectorAudit.redact["rawTransaction"])
+	assert.True(t, m.connectorAudit.redact["signature"])
+	assert.Equal(t, mfc, m.baseConnector())
+
+}
+
+func TestInitConnectorAuditWrapsConnector(t *testing.T) {
+
+	InitConfig()
+	config.Set(tmconfig.ConnectorAuditEnabled, true)
+	mfc := &ffcapimocks.API{}
+	m := newManager(context.Background(), mfc)
+	defer m.cancelCtx()
+	err := m.initConnectorAudit(m.ctx)
+	assert.NoError(t, err)
+
+	assert.Equal(t, m.connectorAudit, m.connector)
+	assert.Equal(t, logrus.InfoLevel, m.connectorAudit.level)
+	assert.Equal(t, mfc, m.baseConnector())
+
+}
+
+func TestInitConnectorAuditDisabled(t *testing.T) {
+
+	InitConfig()
+	mfc := &ffcapimocks.API{}
+	m := newManager(context.Background(), mfc)
+	defer m.cancelCtx()
+	err := m.initConnectorAudit(m.ctx)
+	assert.NoError(t, err)
+
+	assert.Nil(t, m.connectorAudit)
+	assert.Equal(t, mfc, m.connector)
+
+}
+
+func TestInitServicesBadConnectorAuditLevel(t *testing.T) {
+
+	InitConfig()
+	config.Set(tmconfig.ConnectorAuditEnabled, true)
+	config.Set(tmconfig.ConnectorAuditLevel, "wrong")
+	m := newManager(context.Background(), &ffcapimocks.API{})
+	defer m.cancelCtx()
+	err := m.initServices(m.ctx)
+	assert.Regexp(t, "FF21150.*wrong", err)
+
+}
@@ -0,0 +1,388 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// connectorBreaker is a circuit breaker around the connector, so that during an outage calls fail
+// immediately rather than each waiting for a timeout. After a number of consecutive failures the breaker
+// opens, and all calls fail until the cooldown has passed. Then a single call is let through to probe
+// the connector (half-open), which closes the breaker if it succeeds, or opens it again if it fails.
+//
+// Only errors without a reason count as failures. An error with a reason, such as a nonce that is too
+// low or a reverted transaction, was returned by a connector that is reachable.
+//
+// The breaker is also where errors without a reason are given one from the configured error reason
+// mappings, so it is in place whenever mappings are configured - with a zero failure threshold if the
+// breaker itself is disabled, in which case it never opens.
+//
+// The breaker implements all the optional connector interfaces, so that it can be used in place of the
+// connector. Checks of the optional interfaces the connector implements must be made on the connector.
+type connectorBreaker struct {
+	ffcapi.API
+	failureThreshold    int
+	cooldown            time.Duration
+	mux                 sync.Mutex
+	state               apitypes.CircuitBreakerState
+	consecutiveFailures int
+	openUntil           time.Time
+	errorReasons        []*errorReasonMapping
+}
+
+func newConnectorBreaker(connector ffcapi.API, failureThreshold int, cooldown time.Duration) *connectorBreaker {
+	return &connectorBreaker{
+		API:              connector,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		state:            apitypes.CircuitBreakerClosed,
+	}
+}
+
+// allow returns an error if the call must fail immediately, as the breaker is open or a probe is in progress
+func (cb *connectorBreaker) allow(ctx context.Context) error {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	switch {
+	case cb.state == apitypes.CircuitBreakerOpen && !time.Now().Before(cb.openUntil):
+		log.L(ctx).Infof("Connector circuit breaker half-open, checking whether the connector has recovered")
+		cb.state = apitypes.CircuitBreakerHalfOpen
+		return nil
+	case cb.state != apitypes.CircuitBreakerClosed:
+		return i18n.NewError(ctx, tmmsgs.MsgConnectorUnavailable, cb.consecutiveFailures)
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the result of a call to the connector, returning the reason for the
+// error - which is taken from the error reason mappings if the connector did not supply one
+func (cb *connectorBreaker) record(ctx context.Context, reason ffcapi.ErrorReason, err error) ffcapi.ErrorReason {
+	reason = classifyError(ctx, cb.errorReasons, reason, err)
+	if cb.failureThreshold <= 0 {
+		return reason
+	}
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	if err != nil && reason == "" {
+		cb.consecutiveFailures++
+		if cb.state == apitypes.CircuitBreakerHalfOpen || cb.consecutiveFailures >= cb.failureThreshold {
+			if cb.state != apitypes.CircuitBreakerOpen {
+				log.L(ctx).Warnf("Connector circuit breaker open after %d consecutive failures, for %s: %s", cb.consecutiveFailures, cb.cooldown, err)
+			}
+			cb.state = apitypes.CircuitBreakerOpen
+			cb.openUntil = time.Now().Add(cb.cooldown)
+		}
+		return reason
+	}
+	if cb.state != apitypes.CircuitBreakerClosed {
+		log.L(ctx).Infof("Connector circuit breaker closed, as the connector has recovered")
+	}
+	cb.state = apitypes.CircuitBreakerClosed
+	cb.consecutiveFailures = 0
+	return reason
+}
+
+// isOpen returns true while calls are failing immediately, without waiting on the connector
+func (cb *connectorBreaker) isOpen() bool {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	return cb.state == apitypes.CircuitBreakerHalfOpen ||
+		(cb.state == apitypes.CircuitBreakerOpen && time.Now().Before(cb.openUntil))
+}
+
+func (cb *connectorBreaker) status() *apitypes.CircuitBreakerStatus {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	status := &apitypes.CircuitBreakerStatus{
+		State:               cb.state,
+		ConsecutiveFailures: cb.consecutiveFailures,
+	}
+	if cb.state == apitypes.CircuitBreakerOpen {
+		openUntil := fftypes.FFTime(cb.openUntil)
+		status.OpenUntil = &openUntil
+	}
+	return status
+}
+
+func (cb *connectorBreaker) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (*ffcapi.BlockInfoByHashResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.BlockInfoByHash(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (*ffcapi.BlockInfoByNumberResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.BlockInfoByNumber(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (*ffcapi.NextNonceForSignerResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.NextNonceForSigner(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (*ffcapi.GasPriceEstimateResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.GasPriceEstimate(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (*ffcapi.QueryInvokeResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.QueryInvoke(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (*ffcapi.TransactionReceiptResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.TransactionReceipt(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.TransactionPrepare(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.TransactionSend(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.DeployContractPrepare(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (*ffcapi.EventStreamStartResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.EventStreamStart(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (*ffcapi.EventStreamStoppedResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.EventStreamStopped(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (*ffcapi.EventListenerVerifyOptionsResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.EventListenerVerifyOptions(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (*ffcapi.EventListenerAddResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.EventListenerAdd(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (*ffcapi.EventListenerRemoveResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.EventListenerRemove(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (*ffcapi.EventListenerHWMResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.EventListenerHWM(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (*ffcapi.NewBlockListenerResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.NewBlockListener(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) ConnectorInfo(ctx context.Context, req *ffcapi.ConnectorInfoRequest) (*ffcapi.ConnectorInfoResponse, ffcapi.ErrorReason, error) {
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := cb.API.ConnectorInfo(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) TransactionReceipts(ctx context.Context, req *ffcapi.TransactionReceiptsRequest) (*ffcapi.TransactionReceiptsResponse, ffcapi.ErrorReason, error) {
+	batchAPI, ok := cb.API.(ffcapi.BatchReceiptAPI)
+	if !ok {
+		// Fall back to individual calls through the breaker, hiding this function so we do not recurse
+		return ffcapi.TransactionReceipts(ctx, struct{ ffcapi.API }{cb}, req)
+	}
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := batchAPI.TransactionReceipts(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) TransactionTrace(ctx context.Context, req *ffcapi.TransactionTraceRequest) (*ffcapi.TransactionTraceResponse, ffcapi.ErrorReason, error) {
+	traceAPI, ok := cb.API.(ffcapi.TraceAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "TraceAPI")
+	}
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := traceAPI.TransactionTrace(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) TransactionSendRaw(ctx context.Context, req *ffcapi.TransactionSendRawRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	rawAPI, ok := cb.API.(ffcapi.RawTransactionAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "RawTransactionAPI")
+	}
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := rawAPI.TransactionSendRaw(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) EventListenerReplay(ctx context.Context, req *ffcapi.EventListenerReplayRequest) (*ffcapi.EventListenerReplayResponse, ffcapi.ErrorReason, error) {
+	replayAPI, ok := cb.API.(ffcapi.EventReplayAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "EventReplayAPI")
+	}
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := replayAPI.EventListenerReplay(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) SignTypedData(ctx context.Context, req *ffcapi.SignTypedDataRequest) (*ffcapi.SignTypedDataResponse, ffcapi.ErrorReason, error) {
+	typedDataAPI, ok := cb.API.(ffcapi.TypedDataAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "TypedDataAPI")
+	}
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := typedDataAPI.SignTypedData(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) FinalizedBlock(ctx context.Context, req *ffcapi.FinalizedBlockRequest) (*ffcapi.FinalizedBlockResponse, ffcapi.ErrorReason, error) {
+	finalityAPI, ok := cb.API.(ffcapi.FinalityAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "FinalityAPI")
+	}
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := finalityAPI.FinalizedBlock(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+func (cb *connectorBreaker) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (*ffcapi.TransactionSimulateResponse, ffcapi.ErrorReason, error) {
+	simulationAPI, ok := cb.API.(ffcapi.SimulationAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "SimulationAPI")
+	}
+	if err := cb.allow(ctx); err != nil {
+		return nil, "", err
+	}
+	res, reason, err := simulationAPI.TransactionSimulate(ctx, req)
+	reason = cb.record(ctx, reason, err)
+	return res, reason, err
+}
+
+// baseConnector returns the connector beneath the circuit breaker and audit log (if enabled), to check
+// which of the optional interfaces it implements
+func (m *manager) baseConnector() ffcapi.API {
+	switch {
+	case m.connectorAudit != nil:
+		return m.connectorAudit.API
+	case m.connectorBreaker != nil:
+		return m.connectorBreaker.API
+	default:
+		return m.connector
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type fullConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.BatchReceiptAPI
+	*ffcapimocks.TraceAPI
+	*ffcapimocks.RawTransactionAPI
+	*ffcapimocks.EventReplayAPI
+	*ffcapimocks.TypedDataAPI
+	*ffcapimocks.FinalityAPI
+	*ffcapimocks.SimulationAPI
+}
+
+// breakerCalls invokes every function of the connector through the breaker
+var breakerCalls = map[string]func(ctx context.Context, cb *connectorBreaker) error{
+	"BlockInfoByHash": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{})
+		return err
+	},
+	"BlockInfoByNumber": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{})
+		return err
+	},
+	"NextNonceForSigner": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{})
+		return err
+	},
+	"GasPriceEstimate": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+		return err
+	},
+	"QueryInvoke": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{})
+		return err
+	},
+	"TransactionReceipt": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{})
+		return err
+	},
+	"TransactionPrepare": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{})
+		return err
+	},
+	"TransactionSend": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+		return err
+	},
+	"DeployContractPrepare": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{})
+		return err
+	},
+	"EventStreamStart": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{})
+		return err
+	},
+	"EventStreamStopped": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.EventStreamStopped(ctx, &ffcapi.EventStreamStoppedRequest{})
+		return err
+	},
+	"EventListenerVerifyOptions": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{})
+		return err
+	},
+	"EventListenerAdd": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{})
+		return err
+	},
+	"EventListenerRemove": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{})
+		return err
+	},
+	"EventListenerHWM": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{})
+		return err
+	},
+	"NewBlockListener": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{})
+		return err
+	},
+	"ConnectorInfo": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.ConnectorInfo(ctx, &ffcapi.ConnectorInfoRequest{})
+		return err
+	},
+	"TransactionReceipts": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+		return err
+	},
+	"TransactionTrace": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.TransactionTrace(ctx, &ffcapi.TransactionTraceRequest{})
+		return err
+	},
+	"TransactionSendRaw": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.TransactionSendRaw(ctx, &ffcapi.TransactionSendRawRequest{})
+		return err
+	},
+	"EventListenerReplay": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.EventListenerReplay(ctx, &ffcapi.EventListenerReplayRequest{})
+		return err
+	},
+	"SignTypedData": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.SignTypedData(ctx, &ffcapi.SignTypedDataRequest{})
+		return err
+	},
+	"FinalizedBlock": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.FinalizedBlock(ctx, &ffcapi.FinalizedBlockRequest{})
+		return err
+	},
+	"TransactionSimulate": func(ctx context.Context, cb *connectorBreaker) (err error) {
+		_, _, err = cb.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+		return err
+	},
+}
+
+func newTestFullConnector() (*fullConnector, []*mock.Mock) {
+	fc := &fullConnector{
+		API:               &ffcapimocks.API{},
+		BatchReceiptAPI:   &ffcapimocks.BatchReceiptAPI{},
+		TraceAPI:          &ffcapimocks.TraceAPI{},
+		RawTransactionAPI: &ffcapimocks.RawTransactionAPI{},
+		EventReplayAPI:    &ffcapimocks.EventReplayAPI{},
+		TypedDataAPI:      &ffcapimocks.TypedDataAPI{},
+		FinalityAPI:       &ffcapimocks.FinalityAPI{},
+		SimulationAPI:     &ffcapimocks.SimulationAPI{},
+	}
+	return fc, []*mock.Mock{&fc.API.Mock, &fc.BatchReceiptAPI.Mock, &fc.TraceAPI.Mock, &fc.RawTransactionAPI.Mock, &fc.EventReplayAPI.Mock, &fc.TypedDataAPI.Mock, &fc.FinalityAPI.Mock, &fc.SimulationAPI.Mock}
+}
+
+func TestConnectorBreakerAllCallsTripAndFailFast(t *testing.T) {
+
+	for name, call := range breakerCalls {
+		fc, mocks := newTestFullConnector()
+		for _, m := range mocks {
+			m.On(name, mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Maybe()
+		}
+		cb := newConnectorBreaker(fc, 2, time.Hour)
+		ctx := context.Background()
+
+		assert.Regexp(t, "pop", call(ctx, cb), name)
+		assert.Equal(t, apitypes.CircuitBreakerClosed, cb.status().State, name)
+		assert.Regexp(t, "pop", call(ctx, cb), name)
+		assert.Equal(t, apitypes.CircuitBreakerOpen, cb.status().State, name)
+
+		// Now the connector is not called
+		assert.Regexp(t, "FF21125", call(ctx, cb), name)
+		calls := 0
+		for _, m := range mocks {
+			calls += len(m.Calls)
+		}
+		assert.Equal(t, 2, calls, name)
+	}
+
+}
+
+func TestConnectorBreakerAllCallsPassThrough(t *testing.T) {
+
+	for name, call := range breakerCalls {
+		fc, mocks := newTestFullConnector()
+		for _, m := range mocks {
+			m.On(name, mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), nil).Maybe()
+		}
+		cb := newConnectorBreaker(fc, 1, time.Hour)
+		assert.NoError(t, call(context.Background(), cb), name)
+		calls := 0
+		for _, m := range mocks {
+			calls += len(m.Calls)
+		}
+		assert.Equal(t, 1, calls, name)
+	}
+
+}
+
+func TestConnectorBreakerOptionalInterfacesNotImplemented(t *testing.T) {
+
+	mfc := &ffcapimocks.API{}
+	cb := newConnectorBreaker(mfc, 1, time.Hour)
+	ctx := context.Background()
+
+	assert.Regexp(t, "FF21126.*TraceAPI", breakerCalls["TransactionTrace"](ctx, cb))
+	assert.Regexp(t, "FF21126.*RawTransactionAPI", breakerCalls["TransactionSendRaw"](ctx, cb))
+	assert.Regexp(t, "FF21126.*EventReplayAPI", breakerCalls["EventListenerReplay"](ctx, cb))
+	assert.Regexp(t, "FF21126.*TypedDataAPI", breakerCalls["SignTypedData"](ctx, cb))
+	assert.Regexp(t, "FF21126.*FinalityAPI", breakerCalls["FinalizedBlock"](ctx, cb))
+	assert.Regexp(t, "FF21126.*SimulationAPI", breakerCalls["TransactionSimulate"](ctx, cb))
+
+	// Receipts fall back to individual calls, each through the breaker
+	mfc.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	res, _, err := cb.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{
+		TransactionHashes: []string{"0x1111", "0x2222"},
+	})
+	assert.NoError(t, err)
+	assert.Regexp(t, "pop", res.Results[0].Error)
+	assert.Regexp(t, "FF21125", res.Results[1].Error)
+	mfc.AssertNumberOfCalls(t, "TransactionReceipt", 1)
+
+}
+
+func TestConnectorBreakerIgnoresErrorsWithReason(t *testing.T) {
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low"))
+	cb := newConnectorBreaker(mfc, 1, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		_, reason, err := cb.TransactionSend(context.Background(), &ffcapi.TransactionSendRequest{})
+		assert.Regexp(t, "nonce too low", err)
+		assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+	}
+	assert.Equal(t, apitypes.CircuitBreakerClosed, cb.status().State)
+	assert.Zero(t, cb.status().ConsecutiveFailures)
+
+}
+
+func TestConnectorBreakerSuccessResetsFailures(t *testing.T) {
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Twice()
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Twice()
+	cb := newConnectorBreaker(mfc, 3, time.Hour)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		_ = breakerCalls["GasPriceEstimate"](ctx, cb)
+	}
+	assert.Equal(t, apitypes.CircuitBreakerClosed, cb.status().State)
+	assert.Equal(t, 2, cb.status().ConsecutiveFailures)
+	mfc.AssertExpectations(t)
+
+}
+
+func TestConnectorBreakerHalfOpenProbe(t *testing.T) {
+
+	mfc := &ffcapimocks.API{}
+	cb := newConnectorBreaker(mfc, 2, 10*time.Millisecond)
+	ctx := context.Background()
+	gasPrice := breakerCalls["GasPriceEstimate"]
+
+	// Repeated failures trip the breaker
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Twice()
+	assert.Regexp(t, "pop", gasPrice(ctx, cb))
+	assert.Regexp(t, "pop", gasPrice(ctx, cb))
+	status := cb.status()
+	assert.Equal(t, apitypes.CircuitBreakerOpen, status.State)
+	assert.Equal(t, 2, status.ConsecutiveFailures)
+	assert.NotNil(t, status.OpenUntil)
+	assert.True(t, cb.isOpen())
+	assert.Regexp(t, "FF21125", gasPrice(ctx, cb))
+
+	// After the cooldown a single probe is let through, which fails and re-opens the breaker
+	assert.Eventually(t, func() bool { return !cb.isOpen() }, time.Second, time.Millisecond)
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop again")).Once().Run(func(args mock.Arguments) {
+		assert.Equal(t, apitypes.CircuitBreakerHalfOpen, cb.status().State)
+		assert.True(t, cb.isOpen())
+		assert.Regexp(t, "FF21125", gasPrice(ctx, cb))
+	})
+	assert.Regexp(t, "pop again", gasPrice(ctx, cb))
+	assert.Equal(t, apitypes.CircuitBreakerOpen, cb.status().State)
+	assert.Equal(t, 3, cb.status().ConsecutiveFailures)
+
+	// The next probe succeeds, closing the breaker
+	assert.Eventually(t, func() bool { return !cb.isOpen() }, time.Second, time.Millisecond)
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	assert.NoError(t, gasPrice(ctx, cb))
+	status = cb.status()
+	assert.Equal(t, apitypes.CircuitBreakerClosed, status.State)
+	assert.Zero(t, status.ConsecutiveFailures)
+	assert.Nil(t, status.OpenUntil)
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestConnectorBreakerEnabledFromConfig(t *testing.T) {
+
+	InitConfig()
+	config.Set(tmconfig.CircuitBreakerFailureThreshold, 5)
+	config.Set(tmconfig.CircuitBreakerCooldown, "1m")
+	mfc := &ffcapimocks.API{}
+	m := newManager(context.Background(), mfc)
+	defer m.cancelCtx()
+
+	assert.Equal(t, m.connectorBreaker, m.connector)
+	assert.Equal(t, 5, m.connectorBreaker.failureThreshold)
+	assert.Equal(t, time.Minute, m.connectorBreaker.cooldown)
+	assert.Equal(t, mfc, m.baseConnector())
+
+}
+
+func TestConnectorBreakerPausesPolicyLoopAndReadiness(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	mfc := m.connector.(*ffcapimocks.API)
+	m.connectorBreaker = newConnectorBreaker(mfc, 2, time.Hour)
+	m.connector = m.connectorBreaker
+
+	mcm := m.confirmations.(*confirmationsmocks.Manager)
+	mcm.On("HighestBlockSeen").Return(uint64(12345))
+	m.streamsRestored = true
+	m.lastPolicyLoop = m.lastHealthCheck
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+	m.policyLoopCycle(m.ctx, true)
+	mpe.AssertNumberOfCalls(t, "Execute", 1)
+	status := m.readiness()
+	assert.True(t, status.Ready)
+	assert.Equal(t, apitypes.CircuitBreakerClosed, status.ConnectorBreaker.State)
+
+	// The connector fails, tripping the breaker
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("timeout"))
+	for i := 0; i < 2; i++ {
+		_, _, err := m.connector.NextNonceForSigner(m.ctx, &ffcapi.NextNonceForSignerRequest{})
+		assert.Regexp(t, "timeout", err)
+	}
+
+	// The policy engine is not invoked, and we are not ready
+	m.policyLoopCycle(m.ctx, false)
+	mpe.AssertNumberOfCalls(t, "Execute", 1)
+	status = m.readiness()
+	assert.False(t, status.Ready)
+	assert.True(t, status.ConnectorHealthy)
+	assert.Equal(t, apitypes.CircuitBreakerOpen, status.ConnectorBreaker.State)
+	assert.Equal(t, 2, status.ConnectorBreaker.ConsecutiveFailures)
+
+	// The connector recovers after the cooldown
+	m.connectorBreaker.openUntil = time.Now()
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{}, ffcapi.ErrorReason(""), nil)
+	assert.True(t, m.checkConnectorHealth(m.ctx))
+	m.policyLoopCycle(m.ctx, false)
+	mpe.AssertNumberOfCalls(t, "Execute", 2)
+	status = m.readiness()
+	assert.True(t, status.Ready)
+	assert.Equal(t, apitypes.CircuitBreakerClosed, status.ConnectorBreaker.State)
+
+}
+
+func TestConnectorBreakerOptionalInterfacesCheckedOnConnector(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.connectorBreaker = newConnectorBreaker(m.connector, 2, time.Hour)
+	m.connector = m.connectorBreaker
+
+	txIn := writeTestMinedTxn(t, m, apitypes.TxStatusFailed)
+	trace, err := m.getTransactionTrace(m.ctx, txIn.ID)
+	assert.NoError(t, err)
+	assert.False(t, trace.Supported)
+
+	_, err = m.sendRawTransaction(m.ctx, sampleRawTX(1000))
+	assert.Regexp(t, "FF21116", err)
+
+}
@@ -0,0 +1,645 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const (
+	failoverStrategyPriority   = "priority"
+	failoverStrategyRoundRobin = "roundRobin"
+)
+
+type failoverBackend struct {
+	index               int
+	api                 ffcapi.API
+	consecutiveFailures int
+	unhealthyUntil      time.Time
+}
+
+// failoverBlockListener is a block listener started on a backend, which is re-established on another
+// backend if that one is marked unhealthy
+type failoverBlockListener struct {
+	req     *ffcapi.NewBlockListenerRequest // as supplied by FFTM
+	backend *failoverBackend
+	cancel  func() // stops the listener on the backend, without stopping the listener context of FFTM
+}
+
+// failoverStream is an event stream started on a backend, which is re-established on another backend if that
+// one is marked unhealthy. Events are relayed to FFTM, so the stream can resume from the last event delivered.
+type failoverStream struct {
+	req       *ffcapi.EventStreamStartRequest // as supplied by FFTM
+	backend   *failoverBackend
+	cancel    func() // stops the stream on the backend, without stopping the stream context of FFTM
+	relayDone chan struct{}
+	listeners map[fftypes.UUID]*ffcapi.EventListenerAddRequest // with the checkpoint of the last event relayed for each
+}
+
+// connectorFailover spreads calls across multiple connector backends, such as connectors in front of
+// different RPC endpoints of the same chain. Healthy backends are tried first, in priority or round
+// robin order. A backend with a number of consecutive failures is marked unhealthy, and is only tried
+// as a last resort until the recovery interval has passed - after which it is tried again, and is
+// re-included as soon as a call succeeds.
+//
+// As with the circuit breaker, only errors without a reason count as failures. An error with a reason
+// was returned by a connector that is reachable, so is returned without trying the other backends.
+//
+// Calls that only read are retried on each backend in turn. Calls that might have taken effect even
+// though they failed, such as sending a transaction, are made exactly once on a single backend - so a
+// transaction is never broadcast twice - and the policy engine retries them as normal. Event streams
+// are pinned to the backend that started them, as the listeners of a stream live in that backend.
+// When the backend of a block listener or event stream is marked unhealthy, it is re-established on
+// a healthy backend, with each event listener resuming from the last event delivered to FFTM.
+type connectorFailover struct {
+	backends         []*failoverBackend
+	roundRobin       bool
+	failureThreshold int
+	recoveryInterval time.Duration
+	mux              sync.Mutex
+	next             int
+	blockListeners   []*failoverBlockListener
+	streams          map[fftypes.UUID]*failoverStream
+}
+
+// NewFailoverConnector creates a connector for each of the configured connectorfailover.endpoints,
+// and returns a single connector that fails over between them, to be passed to NewManager
+func NewFailoverConnector(ctx context.Context, newConnector func(ctx context.Context, endpoint string) (ffcapi.API, error)) (ffcapi.API, error) {
+	endpoints := config.GetStringSlice(tmconfig.ConnectorFailoverEndpoints)
+	if len(endpoints) == 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgNoFailoverEndpoints)
+	}
+	strategy := config.GetString(tmconfig.ConnectorFailoverStrategy)
+	if strategy != failoverStrategyPriority && strategy != failoverStrategyRoundRobin {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgUnknownFailoverStrategy, strategy)
+	}
+	backends := make([]ffcapi.API, len(endpoints))
+	for i, endpoint := range endpoints {
+		backend, err := newConnector(ctx, endpoint)
+		if err != nil {
+			return nil, i18n.WrapError(ctx, err, tmmsgs.MsgFailoverEndpointInitFailed, i)
+		}
+		backends[i] = backend
+	}
+	return newConnectorFailover(backends, strategy == failoverStrategyRoundRobin,
+		config.GetInt(tmconfig.ConnectorFailoverFailureThreshold),
+		config.GetDuration(tmconfig.ConnectorFailoverRecoveryInterval)), nil
+}
+
+func newConnectorFailover(backends []ffcapi.API, roundRobin bool, failureThreshold int, recoveryInterval time.Duration) *connectorFailover {
+	cf := &connectorFailover{
+		backends:         make([]*failoverBackend, len(backends)),
+		roundRobin:       roundRobin,
+		failureThreshold: failureThreshold,
+		recoveryInterval: recoveryInterval,
+		streams:          make(map[fftypes.UUID]*failoverStream),
+	}
+	for i, api := range backends {
+		cf.backends[i] = &failoverBackend{index: i, api: api}
+	}
+	return cf
+}
+
+// candidates returns the backends in the order to try them - healthy ones first in strategy order,
+// then the unhealthy ones as a last resort
+func (cf *connectorFailover) candidates() []*failoverBackend {
+	cf.mux.Lock()
+	defer cf.mux.Unlock()
+	start := 0
+	if cf.roundRobin {
+		start = cf.next
+		cf.next = (cf.next + 1) % len(cf.backends)
+	}
+	now := time.Now()
+	healthy := make([]*failoverBackend, 0, len(cf.backends))
+	var unhealthy []*failoverBackend
+	for i := range cf.backends {
+		b := cf.backends[(start+i)%len(cf.backends)]
+		if now.Before(b.unhealthyUntil) {
+			unhealthy = append(unhealthy, b)
+		} else {
+			healthy = append(healthy, b)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// record updates the health of a backend from the result of a call, returning true if the call failed.
+// The block listeners and event streams of a backend are moved to another as soon as it is marked unhealthy.
+func (cf *connectorFailover) record(ctx context.Context, b *failoverBackend, reason ffcapi.ErrorReason, err error) bool {
+	failed, markedUnhealthy := cf.recordHealth(ctx, b, reason, err)
+	if markedUnhealthy {
+		cf.moveListeners(b)
+	}
+	return failed
+}
+
+func (cf *connectorFailover) recordHealth(ctx context.Context, b *failoverBackend, reason ffcapi.ErrorReason, err error) (failed, markedUnhealthy bool) {
+	cf.mux.Lock()
+	defer cf.mux.Unlock()
+	if err == nil || reason != "" {
+		if b.consecutiveFailures >= cf.failureThreshold {
+			log.L(ctx).Infof("Connector backend %d has recovered, and is re-included", b.index)
+		}
+		b.consecutiveFailures = 0
+		b.unhealthyUntil = time.Time{}
+		return false, false
+	}
+	b.consecutiveFailures++
+	if b.consecutiveFailures >= cf.failureThreshold {
+		if b.consecutiveFailures == cf.failureThreshold {
+			log.L(ctx).Warnf("Connector backend %d marked unhealthy after %d consecutive failures: %s", b.index, b.consecutiveFailures, err)
+			markedUnhealthy = true
+		}
+		b.unhealthyUntil = time.Now().Add(cf.recoveryInterval)
+	}
+	return true, markedUnhealthy
+}
+
+// read makes a call that is safe to repeat, trying each backend in turn until one does not fail
+func (cf *connectorFailover) read(ctx context.Context, call func(b *failoverBackend) (ffcapi.ErrorReason, error)) (reason ffcapi.ErrorReason, err error) {
+	for _, b := range cf.candidates() {
+		reason, err = call(b)
+		if !cf.record(ctx, b, reason, err) || ctx.Err() != nil {
+			return reason, err
+		}
+		log.L(ctx).Debugf("Connector backend %d failed, trying the next backend: %s", b.index, err)
+	}
+	return reason, err
+}
+
+// once makes a call that must not be repeated on another backend, as it might have taken effect even if it failed
+func (cf *connectorFailover) once(ctx context.Context, b *failoverBackend, call func(b *failoverBackend) (ffcapi.ErrorReason, error)) (ffcapi.ErrorReason, error) {
+	if b == nil {
+		b = cf.candidates()[0]
+	}
+	reason, err := call(b)
+	cf.record(ctx, b, reason, err)
+	return reason, err
+}
+
+// streamBackend returns the backend an event stream was started on, or nil if it is not known
+func (cf *connectorFailover) streamBackend(streamID *fftypes.UUID) *failoverBackend {
+	if streamID == nil {
+		return nil
+	}
+	cf.mux.Lock()
+	defer cf.mux.Unlock()
+	if s := cf.streams[*streamID]; s != nil {
+		return s.backend
+	}
+	return nil
+}
+
+// orBackground returns the context, or the background context if it is nil
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// startBlockListener starts the block listener on a backend, with a context that can be cancelled to move it to another
+func (cf *connectorFailover) startBlockListener(ctx context.Context, bl *failoverBlockListener, b *failoverBackend) (res *ffcapi.NewBlockListenerResponse, reason ffcapi.ErrorReason, err error) {
+	blCtx, cancel := context.WithCancel(orBackground(bl.req.ListenerContext))
+	req := *bl.req
+	req.ListenerContext = blCtx
+	res, reason, err = b.api.NewBlockListener(ctx, &req)
+	if err != nil {
+		cancel()
+		return nil, reason, err
+	}
+	cf.mux.Lock()
+	bl.backend, bl.cancel = b, cancel
+	cf.mux.Unlock()
+	return res, reason, nil
+}
+
+// startStream starts the event stream on a backend, with a context that can be cancelled to move it to another,
+// and the listeners the stream has when it is started
+func (cf *connectorFailover) startStream(ctx context.Context, s *failoverStream, b *failoverBackend) (res *ffcapi.EventStreamStartResponse, reason ffcapi.ErrorReason, err error) {
+	sCtx, cancel := context.WithCancel(orBackground(s.req.StreamContext))
+	events := make(chan *ffcapi.ListenerEvent)
+	relayDone := make(chan struct{})
+	req := *s.req
+	req.StreamContext = sCtx
+	req.EventStream = events
+	req.InitialListeners = make([]*ffcapi.EventListenerAddRequest, 0, len(s.listeners))
+	cf.mux.Lock()
+	for _, l := range s.listeners {
+		lCopy := *l
+		req.InitialListeners = append(req.InitialListeners, &lCopy)
+	}
+	cf.mux.Unlock()
+	go cf.relayEvents(sCtx, s, events, relayDone)
+	res, reason, err = b.api.EventStreamStart(ctx, &req)
+	if err != nil {
+		cancel()
+		return nil, reason, err
+	}
+	cf.mux.Lock()
+	s.backend, s.cancel, s.relayDone = b, cancel, relayDone
+	cf.mux.Unlock()
+	return res, reason, nil
+}
+
+// relayEvents passes the events of the stream on the backend to FFTM, recording the checkpoint of each listener
+func (cf *connectorFailover) relayEvents(ctx context.Context, s *failoverStream, events chan *ffcapi.ListenerEvent, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case ev := <-events:
+			select {
+			case s.req.EventStream <- ev:
+			case <-ctx.Done():
+				return
+			}
+			if !ev.Removed && ev.Checkpoint != nil && ev.Event != nil && ev.Event.ID.ListenerID != nil {
+				cf.mux.Lock()
+				if l := s.listeners[*ev.Event.ID.ListenerID]; l != nil {
+					l.Checkpoint = ev.Checkpoint
+				}
+				cf.mux.Unlock()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// moveListeners re-establishes the block listeners and event streams of a backend that has been marked unhealthy
+func (cf *connectorFailover) moveListeners(from *failoverBackend) {
+	cf.mux.Lock()
+	var blockListeners []*failoverBlockListener
+	active := cf.blockListeners[:0]
+	for _, bl := range cf.blockListeners {
+		if bl.req.ListenerContext != nil && bl.req.ListenerContext.Err() != nil {
+			continue // stopped by FFTM
+		}
+		active = append(active, bl)
+		if bl.backend == from {
+			blockListeners = append(blockListeners, bl)
+		}
+	}
+	cf.blockListeners = active
+	var streams []*failoverStream
+	for _, s := range cf.streams {
+		if s.backend == from {
+			streams = append(streams, s)
+		}
+	}
+	cf.mux.Unlock()
+
+	for _, bl := range blockListeners {
+		ctx := orBackground(bl.req.ListenerContext)
+		cf.mux.Lock()
+		cancel := bl.cancel
+		cf.mux.Unlock()
+		cancel()
+		reason, err := cf.read(ctx, func(b *failoverBackend) (ffcapi.ErrorReason, error) {
+			_, reason, err := cf.startBlockListener(ctx, bl, b)
+			return reason, err
+		})
+		if err != nil {
+			log.L(ctx).Errorf("Failed to re-establish block listener %s after connector backend %d was marked unhealthy (reason=%s): %s", bl.req.ID, from.index, reason, err)
+		} else {
+			log.L(ctx).Infof("Block listener %s moved from connector backend %d to %d", bl.req.ID, from.index, bl.backend.index)
+		}
+	}
+	for _, s := range streams {
+		ctx := orBackground(s.req.StreamContext)
+		// Wait for the relay to stop, so each listener resumes from the last event that was delivered
+		cf.mux.Lock()
+		cancel, relayDone := s.cancel, s.relayDone
+		cf.mux.Unlock()
+		cancel()
+		<-relayDone
+		if ctx.Err() != nil {
+			continue // stopped by FFTM
+		}
+		reason, err := cf.read(ctx, func(b *failoverBackend) (ffcapi.ErrorReason, error) {
+			_, reason, err := cf.startStream(ctx, s, b)
+			return reason, err
+		})
+		if err != nil {
+			log.L(ctx).Errorf("Failed to re-establish event stream %s after connector backend %d was marked unhealthy (reason=%s): %s", s.req.ID, from.index, reason, err)
+		} else {
+			log.L(ctx).Infof("Event stream %s moved from connector backend %d to %d", s.req.ID, from.index, s.backend.index)
+		}
+	}
+}
+
+func (cf *connectorFailover) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (res *ffcapi.BlockInfoByHashResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.BlockInfoByHash(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (res *ffcapi.BlockInfoByNumberResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.BlockInfoByNumber(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (res *ffcapi.NextNonceForSignerResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.NextNonceForSigner(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (res *ffcapi.GasPriceEstimateResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.GasPriceEstimate(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (res *ffcapi.QueryInvokeResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.QueryInvoke(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (res *ffcapi.TransactionReceiptResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.TransactionReceipt(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (res *ffcapi.TransactionPrepareResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.TransactionPrepare(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (res *ffcapi.TransactionSendResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.once(ctx, nil, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.TransactionSend(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (res *ffcapi.TransactionPrepareResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.DeployContractPrepare(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (res *ffcapi.EventStreamStartResponse, reason ffcapi.ErrorReason, err error) {
+	if req.ID == nil {
+		// Only a stream with an ID can be pinned, and moved
+		reason, err = cf.once(ctx, nil, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+			res, reason, err = b.api.EventStreamStart(ctx, req)
+			return reason, err
+		})
+		return res, reason, err
+	}
+	s := &failoverStream{req: req, listeners: make(map[fftypes.UUID]*ffcapi.EventListenerAddRequest)}
+	for _, l := range req.InitialListeners {
+		lCopy := *l
+		s.listeners[*l.ListenerID] = &lCopy
+	}
+	reason, err = cf.once(ctx, cf.streamBackend(req.ID), func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = cf.startStream(ctx, s, b)
+		return reason, err
+	})
+	if err == nil {
+		cf.mux.Lock()
+		cf.streams[*req.ID] = s
+		cf.mux.Unlock()
+	}
+	return res, reason, err
+}
+
+func (cf *connectorFailover) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (res *ffcapi.EventStreamStoppedResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.once(ctx, cf.streamBackend(req.ID), func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.EventStreamStopped(ctx, req)
+		return reason, err
+	})
+	if req.ID != nil {
+		cf.mux.Lock()
+		if s := cf.streams[*req.ID]; s != nil {
+			s.cancel()
+			delete(cf.streams, *req.ID)
+		}
+		cf.mux.Unlock()
+	}
+	return res, reason, err
+}
+
+func (cf *connectorFailover) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (res *ffcapi.EventListenerVerifyOptionsResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.EventListenerVerifyOptions(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (res *ffcapi.EventListenerAddResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.once(ctx, cf.streamBackend(req.StreamID), func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.EventListenerAdd(ctx, req)
+		return reason, err
+	})
+	if err == nil && req.StreamID != nil && req.ListenerID != nil {
+		cf.mux.Lock()
+		if s := cf.streams[*req.StreamID]; s != nil {
+			l := *req
+			s.listeners[*req.ListenerID] = &l
+		}
+		cf.mux.Unlock()
+	}
+	return res, reason, err
+}
+
+func (cf *connectorFailover) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (res *ffcapi.EventListenerRemoveResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.once(ctx, cf.streamBackend(req.StreamID), func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.EventListenerRemove(ctx, req)
+		return reason, err
+	})
+	if err == nil && req.StreamID != nil && req.ListenerID != nil {
+		cf.mux.Lock()
+		if s := cf.streams[*req.StreamID]; s != nil {
+			delete(s.listeners, *req.ListenerID)
+		}
+		cf.mux.Unlock()
+	}
+	return res, reason, err
+}
+
+func (cf *connectorFailover) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (res *ffcapi.EventListenerHWMResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.once(ctx, cf.streamBackend(req.StreamID), func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.EventListenerHWM(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) EventStreamNewCheckpointStruct() ffcapi.EventListenerCheckpoint {
+	return cf.backends[0].api.EventStreamNewCheckpointStruct()
+}
+
+func (cf *connectorFailover) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (res *ffcapi.NewBlockListenerResponse, reason ffcapi.ErrorReason, err error) {
+	bl := &failoverBlockListener{req: req}
+	reason, err = cf.once(ctx, nil, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = cf.startBlockListener(ctx, bl, b)
+		return reason, err
+	})
+	if err == nil {
+		cf.mux.Lock()
+		cf.blockListeners = append(cf.blockListeners, bl)
+		cf.mux.Unlock()
+	}
+	return res, reason, err
+}
+
+func (cf *connectorFailover) ConnectorInfo(ctx context.Context, req *ffcapi.ConnectorInfoRequest) (res *ffcapi.ConnectorInfoResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		res, reason, err = b.api.ConnectorInfo(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) TransactionReceipts(ctx context.Context, req *ffcapi.TransactionReceiptsRequest) (res *ffcapi.TransactionReceiptsResponse, reason ffcapi.ErrorReason, err error) {
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		if batchAPI, ok := b.api.(ffcapi.BatchReceiptAPI); ok {
+			res, reason, err = batchAPI.TransactionReceipts(ctx, req)
+		} else {
+			res, reason, err = ffcapi.TransactionReceipts(ctx, b.api, req)
+		}
+		return reason, err
+	})
+	return res, reason, err
+}
+
+// The optional interfaces are checked on the first backend, as all backends are expected to be the same connector
+
+func (cf *connectorFailover) TransactionTrace(ctx context.Context, req *ffcapi.TransactionTraceRequest) (res *ffcapi.TransactionTraceResponse, reason ffcapi.ErrorReason, err error) {
+	if _, ok := cf.backends[0].api.(ffcapi.TraceAPI); !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "TraceAPI")
+	}
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		traceAPI, ok := b.api.(ffcapi.TraceAPI)
+		if !ok {
+			return "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "TraceAPI")
+		}
+		res, reason, err = traceAPI.TransactionTrace(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) TransactionSendRaw(ctx context.Context, req *ffcapi.TransactionSendRawRequest) (res *ffcapi.TransactionSendResponse, reason ffcapi.ErrorReason, err error) {
+	if _, ok := cf.backends[0].api.(ffcapi.RawTransactionAPI); !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "RawTransactionAPI")
+	}
+	reason, err = cf.once(ctx, nil, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		rawAPI, ok := b.api.(ffcapi.RawTransactionAPI)
+		if !ok {
+			return "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "RawTransactionAPI")
+		}
+		res, reason, err = rawAPI.TransactionSendRaw(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) EventListenerReplay(ctx context.Context, req *ffcapi.EventListenerReplayRequest) (res *ffcapi.EventListenerReplayResponse, reason ffcapi.ErrorReason, err error) {
+	if _, ok := cf.backends[0].api.(ffcapi.EventReplayAPI); !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "EventReplayAPI")
+	}
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		replayAPI, ok := b.api.(ffcapi.EventReplayAPI)
+		if !ok {
+			return "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "EventReplayAPI")
+		}
+		res, reason, err = replayAPI.EventListenerReplay(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) SignTypedData(ctx context.Context, req *ffcapi.SignTypedDataRequest) (res *ffcapi.SignTypedDataResponse, reason ffcapi.ErrorReason, err error) {
+	if _, ok := cf.backends[0].api.(ffcapi.TypedDataAPI); !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "TypedDataAPI")
+	}
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		typedDataAPI, ok := b.api.(ffcapi.TypedDataAPI)
+		if !ok {
+			return "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "TypedDataAPI")
+		}
+		res, reason, err = typedDataAPI.SignTypedData(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) FinalizedBlock(ctx context.Context, req *ffcapi.FinalizedBlockRequest) (res *ffcapi.FinalizedBlockResponse, reason ffcapi.ErrorReason, err error) {
+	if _, ok := cf.backends[0].api.(ffcapi.FinalityAPI); !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "FinalityAPI")
+	}
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		finalityAPI, ok := b.api.(ffcapi.FinalityAPI)
+		if !ok {
+			return "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "FinalityAPI")
+		}
+		res, reason, err = finalityAPI.FinalizedBlock(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
+
+func (cf *connectorFailover) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (res *ffcapi.TransactionSimulateResponse, reason ffcapi.ErrorReason, err error) {
+	if _, ok := cf.backends[0].api.(ffcapi.SimulationAPI); !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "SimulationAPI")
+	}
+	reason, err = cf.read(ctx, func(b *failoverBackend) (reason ffcapi.ErrorReason, err error) {
+		simulationAPI, ok := b.api.(ffcapi.SimulationAPI)
+		if !ok {
+			return "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "SimulationAPI")
+		}
+		res, reason, err = simulationAPI.TransactionSimulate(ctx, req)
+		return reason, err
+	})
+	return res, reason, err
+}
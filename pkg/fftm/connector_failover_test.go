@@ -0,0 +1,595 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// failoverCalls invokes every function of the connector through the failover connector,
+// with whether the call is made only once rather than failing over
+var failoverCalls = map[string]struct {
+	once bool
+	call func(ctx context.Context, cf *connectorFailover) error
+}{
+	"BlockInfoByHash": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{})
+		return err
+	}},
+	"BlockInfoByNumber": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{})
+		return err
+	}},
+	"NextNonceForSigner": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{})
+		return err
+	}},
+	"GasPriceEstimate": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+		return err
+	}},
+	"QueryInvoke": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{})
+		return err
+	}},
+	"TransactionReceipt": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{})
+		return err
+	}},
+	"TransactionPrepare": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{})
+		return err
+	}},
+	"TransactionSend": {true, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+		return err
+	}},
+	"DeployContractPrepare": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{})
+		return err
+	}},
+	"EventStreamStart": {true, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{})
+		return err
+	}},
+	"EventStreamStopped": {true, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.EventStreamStopped(ctx, &ffcapi.EventStreamStoppedRequest{})
+		return err
+	}},
+	"EventListenerVerifyOptions": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.EventListenerVerifyOptions(ctx, &ffcapi.EventListenerVerifyOptionsRequest{})
+		return err
+	}},
+	"EventListenerAdd": {true, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{})
+		return err
+	}},
+	"EventListenerRemove": {true, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{})
+		return err
+	}},
+	"EventListenerHWM": {true, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{})
+		return err
+	}},
+	"NewBlockListener": {true, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{})
+		return err
+	}},
+	"ConnectorInfo": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.ConnectorInfo(ctx, &ffcapi.ConnectorInfoRequest{})
+		return err
+	}},
+	"TransactionReceipts": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.TransactionReceipts(ctx, &ffcapi.TransactionReceiptsRequest{})
+		return err
+	}},
+	"TransactionTrace": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.TransactionTrace(ctx, &ffcapi.TransactionTraceRequest{})
+		return err
+	}},
+	"TransactionSendRaw": {true, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.TransactionSendRaw(ctx, &ffcapi.TransactionSendRawRequest{})
+		return err
+	}},
+	"EventListenerReplay": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.EventListenerReplay(ctx, &ffcapi.EventListenerReplayRequest{})
+		return err
+	}},
+	"SignTypedData": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.SignTypedData(ctx, &ffcapi.SignTypedDataRequest{})
+		return err
+	}},
+	"FinalizedBlock": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.FinalizedBlock(ctx, &ffcapi.FinalizedBlockRequest{})
+		return err
+	}},
+	"TransactionSimulate": {false, func(ctx context.Context, cf *connectorFailover) (err error) {
+		_, _, err = cf.TransactionSimulate(ctx, &ffcapi.TransactionSimulateRequest{})
+		return err
+	}},
+}
+
+func countCalls(mocks []*mock.Mock) int {
+	calls := 0
+	for _, m := range mocks {
+		calls += len(m.Calls)
+	}
+	return calls
+}
+
+func TestConnectorFailoverAllCalls(t *testing.T) {
+
+	for name, fc := range failoverCalls {
+		failing, failingMocks := newTestFullConnector()
+		for _, m := range failingMocks {
+			m.On(name, mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Maybe()
+		}
+		working, workingMocks := newTestFullConnector()
+		for _, m := range workingMocks {
+			m.On(name, mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), nil).Maybe()
+		}
+		cf := newConnectorFailover([]ffcapi.API{failing, working}, false, 5, time.Hour)
+		err := fc.call(context.Background(), cf)
+		assert.Equal(t, 1, countCalls(failingMocks), name)
+		if fc.once {
+			// Not repeated on the other backend, as it might have taken effect
+			assert.Regexp(t, "pop", err, name)
+			assert.Zero(t, countCalls(workingMocks), name)
+		} else {
+			assert.NoError(t, err, name)
+			assert.Equal(t, 1, countCalls(workingMocks), name)
+		}
+	}
+
+}
+
+func TestConnectorFailoverAllBackendsFail(t *testing.T) {
+
+	mfc1 := &ffcapimocks.API{}
+	mfc1.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop1"))
+	mfc2 := &ffcapimocks.API{}
+	mfc2.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop2"))
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 5, time.Hour)
+
+	err := failoverCalls["GasPriceEstimate"].call(context.Background(), cf)
+	assert.Regexp(t, "pop2", err)
+	mfc1.AssertExpectations(t)
+	mfc2.AssertExpectations(t)
+
+}
+
+func TestConnectorFailoverErrorWithReasonNotRetried(t *testing.T) {
+
+	mfc1 := &ffcapimocks.API{}
+	mfc1.On("QueryInvoke", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("reverted"))
+	mfc2 := &ffcapimocks.API{}
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 1, time.Hour)
+
+	_, reason, err := cf.QueryInvoke(context.Background(), &ffcapi.QueryInvokeRequest{})
+	assert.Regexp(t, "reverted", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, reason)
+	assert.Zero(t, cf.backends[0].consecutiveFailures)
+	mfc1.AssertExpectations(t)
+	mfc2.AssertExpectations(t)
+
+}
+
+func TestConnectorFailoverCancelledContextNotRetried(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mfc1 := &ffcapimocks.API{}
+	mfc1.On("QueryInvoke", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("cancelled"))
+	mfc2 := &ffcapimocks.API{}
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 1, time.Hour)
+
+	err := failoverCalls["QueryInvoke"].call(ctx, cf)
+	assert.Regexp(t, "cancelled", err)
+	mfc1.AssertExpectations(t)
+	mfc2.AssertExpectations(t)
+
+}
+
+func TestConnectorFailoverUnhealthyAndRecovery(t *testing.T) {
+
+	mfc1 := &ffcapimocks.API{}
+	mfc1.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Twice()
+	mfc2 := &ffcapimocks.API{}
+	mfc2.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{}, ffcapi.ErrorReason(""), nil)
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 2, time.Hour)
+	ctx := context.Background()
+
+	// Each call fails over from the first backend, until it is marked unhealthy
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, failoverCalls["GasPriceEstimate"].call(ctx, cf))
+	}
+	assert.Equal(t, 2, cf.backends[0].consecutiveFailures)
+	assert.True(t, cf.backends[0].unhealthyUntil.After(time.Now()))
+
+	// Now the unhealthy backend is skipped
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, failoverCalls["GasPriceEstimate"].call(ctx, cf))
+	}
+	mfc1.AssertNumberOfCalls(t, "GasPriceEstimate", 2)
+	mfc2.AssertNumberOfCalls(t, "GasPriceEstimate", 5)
+
+	// Once the recovery interval has passed it is tried again, and re-included on success
+	cf.backends[0].unhealthyUntil = time.Now().Add(-1 * time.Second)
+	mfc1.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{}, ffcapi.ErrorReason(""), nil)
+	assert.NoError(t, failoverCalls["GasPriceEstimate"].call(ctx, cf))
+	assert.Zero(t, cf.backends[0].consecutiveFailures)
+	assert.True(t, cf.backends[0].unhealthyUntil.IsZero())
+	mfc1.AssertNumberOfCalls(t, "GasPriceEstimate", 3)
+	mfc2.AssertNumberOfCalls(t, "GasPriceEstimate", 5)
+
+}
+
+func TestConnectorFailoverUnhealthyUsedAsLastResort(t *testing.T) {
+
+	mfc1 := &ffcapimocks.API{}
+	mfc1.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc2 := &ffcapimocks.API{}
+	mfc2.On("ConnectorInfo", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 1, time.Hour)
+	cf.backends[0].consecutiveFailures = 1
+	cf.backends[0].unhealthyUntil = time.Now().Add(time.Hour)
+
+	assert.NoError(t, failoverCalls["ConnectorInfo"].call(context.Background(), cf))
+	assert.Zero(t, cf.backends[0].consecutiveFailures)
+	assert.True(t, cf.backends[1].unhealthyUntil.After(time.Now()))
+	mfc1.AssertExpectations(t)
+	mfc2.AssertExpectations(t)
+
+}
+
+func TestConnectorFailoverSendMovesToHealthyBackend(t *testing.T) {
+
+	mfc1 := &ffcapimocks.API{}
+	mfc1.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc2 := &ffcapimocks.API{}
+	mfc2.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x12345"}, ffcapi.ErrorReason(""), nil).Once()
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 1, time.Hour)
+	ctx := context.Background()
+
+	// The first send fails, and is not repeated on the other backend
+	_, _, err := cf.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+	assert.Regexp(t, "pop", err)
+
+	// The retry goes to the healthy backend
+	res, _, err := cf.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x12345", res.TransactionHash)
+	mfc1.AssertExpectations(t)
+	mfc2.AssertExpectations(t)
+
+}
+
+func TestConnectorFailoverRoundRobin(t *testing.T) {
+
+	mocks := make([]*ffcapimocks.API, 3)
+	backends := make([]ffcapi.API, 3)
+	for i := range mocks {
+		mocks[i] = &ffcapimocks.API{}
+		mocks[i].On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(&ffcapi.BlockInfoByNumberResponse{}, ffcapi.ErrorReason(""), nil)
+		backends[i] = mocks[i]
+	}
+	cf := newConnectorFailover(backends, true, 1, time.Hour)
+
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, failoverCalls["BlockInfoByNumber"].call(context.Background(), cf))
+	}
+	for _, m := range mocks {
+		m.AssertNumberOfCalls(t, "BlockInfoByNumber", 2)
+	}
+
+}
+
+func TestConnectorFailoverStreamPinnedToBackend(t *testing.T) {
+
+	streamID := fftypes.NewUUID()
+	mfc1 := &ffcapimocks.API{}
+	mfc2 := &ffcapimocks.API{}
+	mfc2.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc2.On("EventListenerAdd", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerAddResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc2.On("EventListenerHWM", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerHWMResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc2.On("EventListenerRemove", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerRemoveResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc2.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil)
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 1, time.Hour)
+	cf.backends[0].consecutiveFailures = 1
+	cf.backends[0].unhealthyUntil = time.Now().Add(time.Hour)
+	ctx := context.Background()
+
+	_, _, err := cf.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{ID: streamID})
+	assert.NoError(t, err)
+
+	// The first backend recovers, but the stream stays on the backend it was started on
+	cf.backends[0].consecutiveFailures = 0
+	cf.backends[0].unhealthyUntil = time.Time{}
+	_, _, err = cf.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{StreamID: streamID})
+	assert.NoError(t, err)
+	_, _, err = cf.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: streamID})
+	assert.NoError(t, err)
+	_, _, err = cf.EventListenerRemove(ctx, &ffcapi.EventListenerRemoveRequest{StreamID: streamID})
+	assert.NoError(t, err)
+	_, _, err = cf.EventStreamStopped(ctx, &ffcapi.EventStreamStoppedRequest{ID: streamID})
+	assert.NoError(t, err)
+	assert.Empty(t, cf.streams)
+
+	mfc1.AssertExpectations(t)
+	mfc2.AssertExpectations(t)
+
+}
+
+func TestConnectorFailoverMovesListenersFromUnhealthyBackend(t *testing.T) {
+
+	streamID := fftypes.NewUUID()
+	listener1 := fftypes.NewUUID()
+	listener2 := fftypes.NewUUID()
+	blocks := make(chan *ffcapi.BlockHashEvent)
+	events := make(chan *ffcapi.ListenerEvent, 1)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	var bl1Req, bl2Req *ffcapi.NewBlockListenerRequest
+	var es1Req, es2Req *ffcapi.EventStreamStartRequest
+	mfc1 := &ffcapimocks.API{}
+	mfc1.On("NewBlockListener", mock.Anything, mock.Anything).Return(&ffcapi.NewBlockListenerResponse{}, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		bl1Req = args[1].(*ffcapi.NewBlockListenerRequest)
+	}).Once()
+	mfc1.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		es1Req = args[1].(*ffcapi.EventStreamStartRequest)
+	}).Once()
+	mfc1.On("EventListenerAdd", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerAddResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc1.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc2 := &ffcapimocks.API{}
+	mfc2.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc2.On("NewBlockListener", mock.Anything, mock.Anything).Return(&ffcapi.NewBlockListenerResponse{}, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		bl2Req = args[1].(*ffcapi.NewBlockListenerRequest)
+	}).Once()
+	mfc2.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		es2Req = args[1].(*ffcapi.EventStreamStartRequest)
+	}).Once()
+	mfc2.On("EventListenerHWM", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerHWMResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 1, time.Hour)
+
+	_, _, err := cf.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{ID: fftypes.NewUUID(), ListenerContext: ctx, BlockListener: blocks})
+	assert.NoError(t, err)
+	_, _, err = cf.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{
+		ID:            streamID,
+		StreamContext: ctx,
+		EventStream:   events,
+		InitialListeners: []*ffcapi.EventListenerAddRequest{
+			{ListenerID: listener1, StreamID: streamID, Checkpoint: &testBlockCheckpoint{Block: 100}},
+		},
+	})
+	assert.NoError(t, err)
+	_, _, err = cf.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{ListenerID: listener2, StreamID: streamID})
+	assert.NoError(t, err)
+
+	// An event delivered from the first backend moves the checkpoint of its listener on
+	es1Req.EventStream <- &ffcapi.ListenerEvent{
+		Event:      &ffcapi.Event{ID: ffcapi.EventID{ListenerID: listener1}},
+		Checkpoint: &testBlockCheckpoint{Block: 200},
+	}
+	<-events
+
+	// Once the first backend is marked unhealthy, both are stopped there and started again on the second
+	assert.NoError(t, failoverCalls["GasPriceEstimate"].call(ctx, cf))
+	assert.Error(t, bl1Req.ListenerContext.Err())
+	assert.Error(t, es1Req.StreamContext.Err())
+	assert.NoError(t, bl2Req.ListenerContext.Err())
+	assert.NoError(t, es2Req.StreamContext.Err())
+	assert.Equal(t, (chan<- *ffcapi.BlockHashEvent)(blocks), bl2Req.BlockListener)
+	assert.Len(t, es2Req.InitialListeners, 2)
+	for _, l := range es2Req.InitialListeners {
+		if *l.ListenerID == *listener1 {
+			assert.Equal(t, int64(200), l.Checkpoint.(*testBlockCheckpoint).Block)
+		} else {
+			assert.Equal(t, listener2, l.ListenerID)
+		}
+	}
+
+	// Events from the second backend are delivered to the same stream, and the stream is now pinned there
+	es2Req.EventStream <- &ffcapi.ListenerEvent{
+		Event:      &ffcapi.Event{ID: ffcapi.EventID{ListenerID: listener2}},
+		Checkpoint: &testBlockCheckpoint{Block: 300},
+	}
+	ev := <-events
+	assert.Equal(t, listener2, ev.Event.ID.ListenerID)
+	_, _, err = cf.EventListenerHWM(ctx, &ffcapi.EventListenerHWMRequest{StreamID: streamID})
+	assert.NoError(t, err)
+
+	// Stopping the listener context in FFTM stops the listeners on the backend
+	cancelCtx()
+	assert.Error(t, bl2Req.ListenerContext.Err())
+	assert.Error(t, es2Req.StreamContext.Err())
+
+	mfc1.AssertExpectations(t)
+	mfc2.AssertExpectations(t)
+
+}
+
+func TestConnectorFailoverMoveListenersFail(t *testing.T) {
+
+	streamID := fftypes.NewUUID()
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	mfc1 := &ffcapimocks.API{}
+	mfc1.On("NewBlockListener", mock.Anything, mock.Anything).Return(&ffcapi.NewBlockListenerResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc1.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc1.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc1.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc1.On("EventStreamStart", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc2 := &ffcapimocks.API{}
+	mfc2.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc2.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc2.On("EventStreamStart", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 2, time.Hour)
+
+	_, _, err := cf.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{ID: fftypes.NewUUID(), ListenerContext: ctx})
+	assert.NoError(t, err)
+	_, _, err = cf.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{ID: streamID, StreamContext: ctx})
+	assert.NoError(t, err)
+
+	// The first backend reaches the threshold, but there is no other backend to move to
+	cf.backends[0].consecutiveFailures = 1
+	assert.Regexp(t, "pop", failoverCalls["GasPriceEstimate"].call(ctx, cf))
+
+	mfc1.AssertExpectations(t)
+	mfc2.AssertExpectations(t)
+
+}
+
+func TestConnectorFailoverMoveListenersSkipsStopped(t *testing.T) {
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+
+	mfc1 := &ffcapimocks.API{}
+	mfc1.On("NewBlockListener", mock.Anything, mock.Anything).Return(&ffcapi.NewBlockListenerResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc1.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	mfc1.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc2 := &ffcapimocks.API{}
+	mfc2.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{}, ffcapi.ErrorReason(""), nil).Once()
+	cf := newConnectorFailover([]ffcapi.API{mfc1, mfc2}, false, 1, time.Hour)
+
+	_, _, err := cf.NewBlockListener(ctx, &ffcapi.NewBlockListenerRequest{ID: fftypes.NewUUID(), ListenerContext: ctx})
+	assert.NoError(t, err)
+	_, _, err = cf.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{ID: fftypes.NewUUID(), StreamContext: ctx})
+	assert.NoError(t, err)
+
+	// Listeners that FFTM has stopped are not started again on the other backend
+	cancelCtx()
+	assert.NoError(t, failoverCalls["GasPriceEstimate"].call(context.Background(), cf))
+	assert.Empty(t, cf.blockListeners)
+
+	mfc1.AssertExpectations(t)
+	mfc2.AssertExpectations(t)
+
+}
+
+func TestConnectorFailoverOptionalInterfacesNotImplemented(t *testing.T) {
+
+	cf := newConnectorFailover([]ffcapi.API{&ffcapimocks.API{}}, false, 1, time.Hour)
+	ctx := context.Background()
+
+	assert.Regexp(t, "FF21126.*TraceAPI", failoverCalls["TransactionTrace"].call(ctx, cf))
+	assert.Regexp(t, "FF21126.*RawTransactionAPI", failoverCalls["TransactionSendRaw"].call(ctx, cf))
+	assert.Regexp(t, "FF21126.*EventReplayAPI", failoverCalls["EventListenerReplay"].call(ctx, cf))
+	assert.Regexp(t, "FF21126.*TypedDataAPI", failoverCalls["SignTypedData"].call(ctx, cf))
+	assert.Regexp(t, "FF21126.*FinalityAPI", failoverCalls["FinalizedBlock"].call(ctx, cf))
+	assert.Regexp(t, "FF21126.*SimulationAPI", failoverCalls["TransactionSimulate"].call(ctx, cf))
+
+	// Receipts fall back to individual calls
+	assert.NoError(t, failoverCalls["TransactionReceipts"].call(ctx, cf))
+
+}
+
+func TestConnectorFailoverOptionalInterfacesMissingOnOtherBackend(t *testing.T) {
+
+	for _, name := range []string{"TransactionTrace", "TransactionSendRaw", "EventListenerReplay", "SignTypedData", "FinalizedBlock", "TransactionSimulate"} {
+		fc, mocks := newTestFullConnector()
+		for _, m := range mocks {
+			m.On(name, mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Maybe()
+		}
+		cf := newConnectorFailover([]ffcapi.API{fc, &ffcapimocks.API{}}, false, 1, time.Hour)
+		ctx := context.Background()
+
+		// Mark the full connector unhealthy, so the other backend is tried first
+		assert.Error(t, failoverCalls[name].call(ctx, cf), name)
+		assert.Regexp(t, "FF21126", failoverCalls[name].call(ctx, cf), name)
+	}
+
+}
+
+func TestConnectorFailoverCheckpointStruct(t *testing.T) {
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("EventStreamNewCheckpointStruct").Return(func() ffcapi.EventListenerCheckpoint { return &testBlockCheckpoint{} })
+	cf := newConnectorFailover([]ffcapi.API{mfc}, false, 1, time.Hour)
+	assert.NotNil(t, cf.EventStreamNewCheckpointStruct())
+	mfc.AssertExpectations(t)
+
+}
+
+func TestNewFailoverConnector(t *testing.T) {
+
+	InitConfig()
+	config.Set(tmconfig.ConnectorFailoverEndpoints, []string{"http://rpc1", "http://rpc2"})
+	config.Set(tmconfig.ConnectorFailoverStrategy, "roundRobin")
+	config.Set(tmconfig.ConnectorFailoverFailureThreshold, 5)
+	config.Set(tmconfig.ConnectorFailoverRecoveryInterval, "1m")
+
+	var endpoints []string
+	c, err := NewFailoverConnector(context.Background(), func(ctx context.Context, endpoint string) (ffcapi.API, error) {
+		endpoints = append(endpoints, endpoint)
+		return &ffcapimocks.API{}, nil
+	})
+	assert.NoError(t, err)
+	cf := c.(*connectorFailover)
+	assert.Equal(t, []string{"http://rpc1", "http://rpc2"}, endpoints)
+	assert.Len(t, cf.backends, 2)
+	assert.True(t, cf.roundRobin)
+	assert.Equal(t, 5, cf.failureThreshold)
+	assert.Equal(t, time.Minute, cf.recoveryInterval)
+
+}
+
+func TestNewFailoverConnectorNoEndpoints(t *testing.T) {
+
+	InitConfig()
+	_, err := NewFailoverConnector(context.Background(), func(ctx context.Context, endpoint string) (ffcapi.API, error) {
+		return &ffcapimocks.API{}, nil
+	})
+	assert.Regexp(t, "FF21171", err)
+
+}
+
+func TestNewFailoverConnectorBadStrategy(t *testing.T) {
+
+	InitConfig()
+	config.Set(tmconfig.ConnectorFailoverEndpoints, []string{"http://rpc1"})
+	config.Set(tmconfig.ConnectorFailoverStrategy, "random")
+	_, err := NewFailoverConnector(context.Background(), func(ctx context.Context, endpoint string) (ffcapi.API, error) {
+		return &ffcapimocks.API{}, nil
+	})
+	assert.Regexp(t, "FF21170.*random", err)
+
+}
+
+func TestNewFailoverConnectorBackendFail(t *testing.T) {
+
+	InitConfig()
+	config.Set(tmconfig.ConnectorFailoverEndpoints, []string{"http://rpc1"})
+	_, err := NewFailoverConnector(context.Background(), func(ctx context.Context, endpoint string) (ffcapi.API, error) {
+		return nil, fmt.Errorf("pop")
+	})
+	assert.Regexp(t, "FF21172.*pop", err)
+
+}
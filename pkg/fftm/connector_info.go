@@ -0,0 +1,87 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// connectorInfoLoop periodically refreshes the cached connector info, so an upgrade of the
+// connector is picked up without restarting the manager
+func (m *manager) connectorInfoLoop() {
+	defer close(m.connectorInfoDone)
+	ctx := log.WithLogField(m.ctx, "role", "connectorinfo")
+	for {
+		timer := time.NewTimer(m.connectorInfoInterval)
+		select {
+		case <-timer.C:
+			_, _ = m.refreshConnectorInfo(ctx)
+		case <-ctx.Done():
+			timer.Stop()
+			log.L(ctx).Debugf("Connector info refresh exiting")
+			return
+		}
+	}
+}
+
+// refreshConnectorInfo queries the connector for its info, and caches it. On failure any
+// previously cached info is kept.
+func (m *manager) refreshConnectorInfo(ctx context.Context) (*apitypes.ConnectorInfo, error) {
+	res, _, err := m.connector.ConnectorInfo(ctx, &ffcapi.ConnectorInfoRequest{})
+	if err != nil {
+		log.L(ctx).Warnf("Failed to query connector info: %s", err)
+		return nil, err
+	}
+	info := &apitypes.ConnectorInfo{
+		ConnectorInfoResponse: *res,
+		LastRefresh:           fftypes.Now(),
+	}
+	m.mux.Lock()
+	changed := m.connectorInfo == nil || m.connectorInfo.Version != info.Version
+	m.connectorInfo = info
+	m.mux.Unlock()
+	if changed {
+		log.L(ctx).Infof("Connector %s version=%s capabilities=%v", info.Name, info.Version, info.Capabilities)
+	}
+	return info, nil
+}
+
+// getConnectorInfo returns the cached connector info, querying the connector if it has not been cached yet
+func (m *manager) getConnectorInfo(ctx context.Context) (*apitypes.ConnectorInfo, error) {
+	m.mux.Lock()
+	info := m.connectorInfo
+	m.mux.Unlock()
+	if info != nil {
+		return info, nil
+	}
+	return m.refreshConnectorInfo(ctx)
+}
+
+// connectorSupports checks the capabilities reported by the connector, to gate the features that
+// depend on an optional connector API. Until the connector has reported its capabilities, we rely
+// on whether it implements the optional API.
+func (m *manager) connectorSupports(c ffcapi.Capability) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.connectorInfo == nil || m.connectorInfo.Supports(c)
+}
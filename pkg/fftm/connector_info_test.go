@@ -0,0 +1,137 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestConnectorInfoRefreshedPeriodically(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	m.connectorInfoInterval = 1 * time.Millisecond
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Name:    "evmconnect",
+		Version: "v1.0.0",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	// A failed refresh keeps the info we already have
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Name:         "evmconnect",
+		Version:      "v1.1.0",
+		Capabilities: []ffcapi.Capability{ffcapi.CapabilityRawTransactions},
+	}, ffcapi.ErrorReason(""), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	for {
+		info, err := m.getConnectorInfo(m.ctx)
+		assert.NoError(t, err)
+		if info.Version == "v1.1.0" {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	assert.True(t, m.connectorSupports(ffcapi.CapabilityRawTransactions))
+
+}
+
+func TestConnectorInfoStartupFailureNotFatal(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	m.connectorInfoInterval = 1 * time.Hour
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	err := m.Start()
+	assert.NoError(t, err)
+	assert.Nil(t, m.connectorInfo)
+
+	// Features are not disabled until the connector reports its capabilities
+	assert.True(t, m.connectorSupports(ffcapi.CapabilityTrace))
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestConnectorCapabilitiesGateTrace(t *testing.T) {
+
+	url, m, mta, done := newTestTracingManager(t)
+	defer done()
+
+	// The connector implements the trace API, but reports it is not enabled
+	mfc := m.connector.(*tracingConnector).API
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Capabilities: []ffcapi.Capability{ffcapi.CapabilityEIP1559},
+	}, ffcapi.ErrorReason(""), nil)
+	_, err := m.refreshConnectorInfo(m.ctx)
+	assert.NoError(t, err)
+
+	txIn := writeTestMinedTxn(t, m, apitypes.TxStatusFailed)
+	var trace apitypes.TxTrace
+	res, err := resty.New().R().
+		SetResult(&trace).
+		Get(fmt.Sprintf("%s/transactions/%s/trace", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.False(t, trace.Supported)
+
+	mta.AssertNotCalled(t, "TransactionTrace", mock.Anything, mock.Anything)
+
+}
+
+func TestConnectorCapabilitiesGateRawTransactions(t *testing.T) {
+
+	url, m, mfc, mra, done := newTestRawManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	err := m.Start()
+	assert.NoError(t, err)
+
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Capabilities: []ffcapi.Capability{ffcapi.CapabilityTrace},
+	}, ffcapi.ErrorReason(""), nil)
+	_, err = m.refreshConnectorInfo(m.ctx)
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		SetBody(sampleRawTX(1000)).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 501, res.StatusCode())
+	assert.Regexp(t, "FF21116", res.String())
+
+	mra.AssertNotCalled(t, "TransactionSendRaw", mock.Anything, mock.Anything)
+
+}
@@ -0,0 +1,133 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/blocklistener"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/nonceallocator"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+)
+
+// namedConnector is a connector to a chain other than that of the default connector, for a manager that
+// submits transactions across several chains. Blocks, nonces and gas prices are specific to each chain, so each
+// named connector has its own confirmation manager, block listener, nonce allocator and policy engines.
+type namedConnector struct {
+	name              string
+	connector         ffcapi.API
+	confirmations     confirmations.Manager
+	nonceAllocator    nonceallocator.NonceAllocator
+	policyEngine      policyengine.PolicyEngine // the default policy engine
+	policyEngines     map[string]policyengine.PolicyEngine
+	blockListenerDone chan struct{}
+}
+
+// NewManagerWithConnectors constructs a manager that submits transactions to any of the supplied connectors, with
+// each submission choosing a connector by name. Submissions that do not name a connector are sent to the default
+// connector, which must be one of those supplied. The circuit breaker, connector audit log, health check and event
+// streams only use the default connector.
+func NewManagerWithConnectors(ctx context.Context, defaultConnector string, connectors map[string]ffcapi.API) (Manager, error) {
+	connector, ok := connectors[defaultConnector]
+	if !ok {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgDefaultConnectorMissing, defaultConnector)
+	}
+	m := newManager(ctx, connector)
+	m.defaultConnector = defaultConnector
+	for name, c := range connectors {
+		if name != defaultConnector {
+			m.connectors[name] = &namedConnector{name: name, connector: c}
+		}
+	}
+	return initManager(ctx, m)
+}
+
+func (m *manager) initNamedConnectors(ctx context.Context) {
+	for name, nc := range m.connectors {
+		nc.confirmations = confirmations.NewBlockConfirmationManager(ctx, nc.connector, "receipts."+name, m.requiredConfirmations)
+	}
+}
+
+// getConnector returns the named connector, or the default connector if no name is supplied
+func (m *manager) getConnector(ctx context.Context, name string) (ffcapi.API, error) {
+	if name == "" || name == m.defaultConnector {
+		return m.connector, nil
+	}
+	nc, ok := m.connectors[name]
+	if !ok {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgConnectorNotConfigured, name)
+	}
+	return nc.connector, nil
+}
+
+// txConnectorName returns the connector name recorded on a transaction, which is empty for the default connector
+// so transactions submitted by name to the default connector share nonces with those that do not name one
+func (m *manager) txConnectorName(name string) string {
+	if name == m.defaultConnector {
+		return ""
+	}
+	return name
+}
+
+// connectorFor returns the connector of a transaction, which must already have been checked with getConnector
+func (m *manager) connectorFor(name string) ffcapi.API {
+	if nc := m.connectors[name]; nc != nil {
+		return nc.connector
+	}
+	return m.connector
+}
+
+func (m *manager) confirmationsFor(name string) confirmations.Manager {
+	if nc := m.connectors[name]; nc != nil {
+		return nc.confirmations
+	}
+	return m.confirmations
+}
+
+func (m *manager) nonceAllocatorFor(name string) nonceallocator.NonceAllocator {
+	if nc := m.connectors[name]; nc != nil {
+		return nc.nonceAllocator
+	}
+	return m.nonceAllocator
+}
+
+// startNamedConnectorListeners starts a block listener for each named connector, feeding its confirmation manager.
+// The confirmation managers are started separately, once all the block listeners have started successfully.
+func (m *manager) startNamedConnectorListeners() error {
+	for _, nc := range m.connectors {
+		blReq := &ffcapi.NewBlockListenerRequest{ListenerContext: m.leaderCtx, ID: fftypes.NewUUID()}
+		blReq.BlockListener, nc.blockListenerDone = blocklistener.BufferChannel(m.leaderCtx, nc.confirmations)
+		if _, _, err := nc.connector.NewBlockListener(m.leaderCtx, blReq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stopNamedConnectors waits for the block listener of each named connector to stop, after the leader context is
+// cancelled, and then stops its confirmation manager
+func (m *manager) stopNamedConnectors() {
+	for _, nc := range m.connectors {
+		<-nc.blockListenerDone
+		nc.confirmations.Stop()
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/simple"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newTestMultiConnectorManager returns a manager with the default connector "chain1", and a second connector "chain2"
+func newTestMultiConnectorManager(t *testing.T) (string, *manager, *ffcapimocks.API, *confirmationsmocks.Manager, func()) {
+
+	url := testManagerCommonInit(t)
+
+	dir, err := ioutil.TempDir("", "ldb_*")
+	assert.NoError(t, err)
+	config.Set(tmconfig.PersistenceLevelDBPath, dir)
+
+	mca1 := &ffcapimocks.API{}
+	mca1.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), nil).Maybe()
+	mca2 := &ffcapimocks.API{}
+	mm, err := NewManagerWithConnectors(context.Background(), "chain1", map[string]ffcapi.API{
+		"chain1": mca1,
+		"chain2": mca2,
+	})
+	assert.NoError(t, err)
+
+	m := mm.(*manager)
+	mcm1 := &confirmationsmocks.Manager{}
+	mcm1.On("Start").Return().Maybe()
+	mcm1.On("TransactionProgress", mock.Anything).Return(nil).Maybe()
+	m.confirmations = mcm1
+	mcm2 := &confirmationsmocks.Manager{}
+	mcm2.On("Start").Return().Maybe()
+	mcm2.On("Stop").Return().Maybe()
+	m.connectors["chain2"].confirmations = mcm2
+
+	return url, m, mca2, mcm2, func() {
+		m.Close()
+		os.RemoveAll(dir)
+	}
+
+}
+
+func sendSampleTXToConnector(t *testing.T, m *manager, mca *ffcapimocks.API, connector, signer string) (*apitypes.ManagedTX, error) {
+	mca.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	return m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{
+			Connector: connector,
+		},
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: signer,
+			},
+		},
+	})
+}
+
+func TestNewManagerWithConnectorsMissingDefault(t *testing.T) {
+
+	_, err := NewManagerWithConnectors(context.Background(), "chain3", map[string]ffcapi.API{
+		"chain1": &ffcapimocks.API{},
+	})
+	assert.Regexp(t, "FF21191", err)
+
+}
+
+func TestMultiConnectorNonceIsolation(t *testing.T) {
+
+	_, m, mca2, _, done := newTestMultiConnectorManager(t)
+	defer done()
+	mca1 := m.connector.(*ffcapimocks.API)
+
+	mca1.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(100),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca2.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(200),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	// The same signer has its own nonces on each chain, and naming the default connector is the same as not naming one
+	tx1, err := sendSampleTXToConnector(t, m, mca1, "", "0xaaaaa")
+	assert.NoError(t, err)
+	tx2, err := sendSampleTXToConnector(t, m, mca2, "chain2", "0xaaaaa")
+	assert.NoError(t, err)
+	tx3, err := sendSampleTXToConnector(t, m, mca1, "chain1", "0xaaaaa")
+	assert.NoError(t, err)
+	tx4, err := sendSampleTXToConnector(t, m, mca2, "chain2", "0xaaaaa")
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(100), tx1.Nonce.Int64())
+	assert.Equal(t, "", tx1.Connector)
+	assert.Equal(t, int64(200), tx2.Nonce.Int64())
+	assert.Equal(t, "chain2", tx2.Connector)
+	assert.Equal(t, int64(101), tx3.Nonce.Int64())
+	assert.Equal(t, "", tx3.Connector)
+	assert.Equal(t, int64(201), tx4.Nonce.Int64())
+
+	txns, err := m.persistence.ListTransactionsByNonce(m.ctx, "chain2/0xaaaaa", nil, 0, persistence.SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, tx4.ID, txns[0].ID)
+
+	mca1.AssertExpectations(t)
+	mca2.AssertExpectations(t)
+
+}
+
+func TestMultiConnectorUnknownConnector(t *testing.T) {
+
+	_, m, _, _, done := newTestMultiConnectorManager(t)
+	defer done()
+
+	_, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{
+			Connector: "chain3",
+		},
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: "0xaaaaa",
+			},
+		},
+	})
+	assert.Regexp(t, "FF21190", err)
+
+	// Checked again on submission, for raw transactions which are not prepared by the connector
+	_, err = m.submitTX(m.ctx, &apitypes.RequestHeaders{Connector: "chain3"}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, nil, "", false, nil, nil)
+	assert.Regexp(t, "FF21190", err)
+
+}
+
+func TestMultiConnectorConfirmationsIsolation(t *testing.T) {
+
+	_, m, mca2, mcm2, done := newTestMultiConnectorManager(t)
+	defer done()
+	mca1 := m.connector.(*ffcapimocks.API)
+	mcm1 := m.confirmations.(*confirmationsmocks.Manager)
+
+	mca1.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(100),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca2.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(200),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	tx1, err := sendSampleTXToConnector(t, m, mca1, "", "0xaaaaa")
+	assert.NoError(t, err)
+	tx2, err := sendSampleTXToConnector(t, m, mca2, "chain2", "0xaaaaa")
+	assert.NoError(t, err)
+
+	// Each transaction is sent to its own chain
+	mca1.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+		return r.Nonce.Int64() == 100
+	})).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x111111"}, ffcapi.ErrorReason(""), nil).Once()
+	mca2.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+		return r.Nonce.Int64() == 200
+	})).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x222222"}, ffcapi.ErrorReason(""), nil).Once()
+
+	// Each transaction is tracked by the confirmation manager of its own chain, with any other notification failing the mock
+	confirm := func(mcm *confirmationsmocks.Manager, txHash string, blockNumber int64) {
+		mcm.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+			return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == txHash
+		})).Run(func(args mock.Arguments) {
+			n := args[0].(*confirmations.Notification)
+			n.Transaction.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+				BlockNumber: fftypes.NewFFBigInt(blockNumber),
+				BlockHash:   fmt.Sprintf("0x%d", blockNumber),
+				Success:     true,
+			})
+			n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+		}).Return(nil).Once()
+	}
+	confirm(mcm1, "0x111111", 1000)
+	confirm(mcm2, "0x222222", 2000)
+
+	m.policyLoopCycle(m.ctx, true)
+	m.policyLoopCycle(m.ctx, false)
+
+	rtx1, err := m.persistence.GetTransactionByID(m.ctx, tx1.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusSucceeded, rtx1.Status)
+	assert.Equal(t, int64(1000), rtx1.Receipt.BlockNumber.Int64())
+	rtx2, err := m.persistence.GetTransactionByID(m.ctx, tx2.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusSucceeded, rtx2.Status)
+	assert.Equal(t, "chain2", rtx2.Connector)
+	assert.Equal(t, int64(2000), rtx2.Receipt.BlockNumber.Int64())
+
+	mca1.AssertExpectations(t)
+	mca2.AssertExpectations(t)
+	mcm1.AssertExpectations(t)
+	mcm2.AssertExpectations(t)
+
+}
+
+func TestMultiConnectorGasPriceIsolation(t *testing.T) {
+
+	_, m, mca2, _, done := newTestMultiConnectorManager(t)
+	defer done()
+	mca1 := m.connector.(*ffcapimocks.API)
+
+	// The gas price is queried from each connector, and cached for longer than the test
+	simpleConf := tmconfig.PolicyEngineBaseConfig.SubSection("simple")
+	simpleConf.Set(simple.FixedGasPrice, "")
+	simpleConf.SubSection(simple.GasOracleConfig).Set(simple.GasOracleMode, simple.GasOracleModeConnector)
+	err := m.initPolicyEngines(m.ctx)
+	assert.NoError(t, err)
+
+	mca1.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(100),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca2.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(200),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca1.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`"1000"`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca2.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`"2000"`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca1.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x111111"}, ffcapi.ErrorReason(""), nil).Once()
+	mca2.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x222222"}, ffcapi.ErrorReason(""), nil).Once()
+	m.connectors["chain2"].confirmations.(*confirmationsmocks.Manager).On("Notify", mock.Anything).Return(nil)
+	m.confirmations.(*confirmationsmocks.Manager).On("Notify", mock.Anything).Return(nil)
+
+	tx1, err := sendSampleTXToConnector(t, m, mca1, "", "0xaaaaa")
+	assert.NoError(t, err)
+	tx2, err := sendSampleTXToConnector(t, m, mca2, "chain2", "0xaaaaa")
+	assert.NoError(t, err)
+
+	m.policyLoopCycle(m.ctx, true)
+
+	rtx1, err := m.persistence.GetTransactionByID(m.ctx, tx1.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, `"1000"`, rtx1.GasPrice.String())
+	rtx2, err := m.persistence.GetTransactionByID(m.ctx, tx2.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, `"2000"`, rtx2.GasPrice.String())
+
+	mca1.AssertExpectations(t)
+	mca2.AssertExpectations(t)
+
+}
+
+func TestMultiConnectorPolicyEngines(t *testing.T) {
+
+	_, m, _, _, done := newTestMultiConnectorManager(t)
+	defer done()
+
+	// Each connector has its own instance of the policy engine, which the default connector shares with unnamed submissions
+	pe1, err := m.getPolicyEngine(m.ctx, "", "")
+	assert.NoError(t, err)
+	pe1Named, err := m.getPolicyEngine(m.ctx, "chain1", "simple")
+	assert.NoError(t, err)
+	assert.Same(t, pe1, pe1Named)
+	pe2, err := m.getPolicyEngine(m.ctx, "chain2", "")
+	assert.NoError(t, err)
+	assert.NotSame(t, pe1, pe2)
+
+	_, err = m.getPolicyEngine(m.ctx, "chain2", "wrong")
+	assert.Regexp(t, "FF21078", err)
+
+}
+
+func TestMultiConnectorRestoredWithUnknownConnector(t *testing.T) {
+
+	_, m, _, _, done := newTestMultiConnectorManager(t)
+	defer done()
+
+	// A transaction persisted for a connector that is no longer configured is not sent to any other connector
+	mtx := genTestTxn("0xaaaaa", 100, apitypes.TxStatusPending)
+	mtx.Connector = "chain3"
+	err := m.persistence.WriteTransaction(m.ctx, mtx, true)
+	assert.NoError(t, err)
+
+	m.policyLoopCycle(m.ctx, true)
+	assert.Len(t, m.inflight, 1)
+	assert.Equal(t, 1, m.inflight[0].failedCycles)
+	assert.Regexp(t, "FF21190", m.inflight[0].mtx.ErrorHistory[0].Error)
+
+}
+
+func TestMultiConnectorStartStop(t *testing.T) {
+
+	_, m, mca2, mcm2, done := newTestMultiConnectorManager(t)
+	mca2.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), nil).Once()
+
+	err := m.Start()
+	assert.NoError(t, err)
+	done()
+
+	mca2.AssertExpectations(t)
+	mcm2.AssertCalled(t, "Stop")
+
+}
+
+func TestMultiConnectorStartBlockListenerFail(t *testing.T) {
+
+	_, m, mca2, _, done := newTestMultiConnectorManager(t)
+	defer done()
+	mca2.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	err := m.Start()
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestMultiConnectorQuery(t *testing.T) {
+
+	_, m, mca2, _, done := newTestMultiConnectorManager(t)
+	defer done()
+
+	api, err := m.getConnector(m.ctx, "chain2")
+	assert.NoError(t, err)
+	assert.Equal(t, mca2, api)
+	api, err = m.getConnector(m.ctx, "chain1")
+	assert.NoError(t, err)
+	assert.Equal(t, m.connector, api)
+	_, err = m.getConnector(m.ctx, "chain3")
+	assert.Regexp(t, "FF21190", err)
+
+}
@@ -0,0 +1,79 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+)
+
+// dryRunConnector is passed to the policy engine in dry-run mode, in place of the real connector.
+// All calls pass through to the connector, except transaction submission which is intercepted.
+type dryRunConnector struct {
+	ffcapi.API
+	intercepted    *ffcapi.TransactionSendRequest
+	interceptedRaw *ffcapi.TransactionSendRawRequest
+}
+
+func (dr *dryRunConnector) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	dr.intercepted = req
+	return &ffcapi.TransactionSendResponse{}, "", nil
+}
+
+func (dr *dryRunConnector) TransactionSendRaw(ctx context.Context, req *ffcapi.TransactionSendRawRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	dr.interceptedRaw = req
+	return &ffcapi.TransactionSendResponse{}, "", nil
+}
+
+// TransactionSimulate does not affect the blockchain, so passes through to the connector
+func (dr *dryRunConnector) TransactionSimulate(ctx context.Context, req *ffcapi.TransactionSimulateRequest) (*ffcapi.TransactionSimulateResponse, ffcapi.ErrorReason, error) {
+	simulationAPI, ok := dr.API.(ffcapi.SimulationAPI)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "SimulationAPI")
+	}
+	return simulationAPI.TransactionSimulate(ctx, req)
+}
+
+// execPolicyDryRun executes the policy engine without allowing it to submit anything to the blockchain.
+// If the policy engine attempts to submit the transaction, the intended submission is recorded in the
+// history of the transaction, and it is moved to the WouldSubmit status so it leaves the in-flight set.
+func (m *manager) execPolicyDryRun(ctx context.Context, pe policyengine.PolicyEngine, connector ffcapi.API, mtx *apitypes.ManagedTX) (wouldSubmit bool, update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+	dryRun := &dryRunConnector{API: connector}
+	firstSubmit, lastSubmit, txHash := mtx.FirstSubmit, mtx.LastSubmit, mtx.TransactionHash
+	update, reason, err = pe.Execute(ctx, dryRun, mtx)
+	if err != nil || (dryRun.intercepted == nil && dryRun.interceptedRaw == nil) {
+		return false, update, reason, err
+	}
+
+	// Nothing was actually submitted, so we discard the submission details set by the policy engine
+	mtx.FirstSubmit, mtx.LastSubmit, mtx.TransactionHash = firstSubmit, lastSubmit, txHash
+	mtx.Status = apitypes.TxStatusWouldSubmit
+	intended := "raw transaction"
+	if dryRun.intercepted != nil {
+		intended = fmt.Sprintf("gas=%s gasPrice=%s", dryRun.intercepted.Gas, dryRun.intercepted.GasPrice)
+	}
+	log.L(ctx).Infof("Dry run: transaction %s at nonce %s / %d would be submitted with %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), intended)
+	m.addHistory(mtx, apitypes.TxActionWouldSubmit, intended)
+	return true, policyengine.UpdateYes, "", nil
+}
@@ -0,0 +1,93 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+var knownErrorReasons = map[ffcapi.ErrorReason]bool{
+	ffcapi.ErrorReasonInvalidInputs:          true,
+	ffcapi.ErrorReasonTransactionReverted:    true,
+	ffcapi.ErrorReasonNonceTooLow:            true,
+	ffcapi.ErrorReasonTransactionUnderpriced: true,
+	ffcapi.ErrorReasonInsufficientFunds:      true,
+	ffcapi.ErrorReasonNotFound:               true,
+	ffcapi.ErrorKnownTransaction:             true,
+}
+
+// errorReasonMapping gives a reason to errors from the connector that match the pattern, so operators
+// can teach FFTM how their connector phrases errors it does not classify itself
+type errorReasonMapping struct {
+	pattern *regexp.Regexp
+	reason  ffcapi.ErrorReason
+}
+
+func errorReasonMappingsFromConfig(ctx context.Context, conf config.ArraySection) ([]*errorReasonMapping, error) {
+	mappings := make([]*errorReasonMapping, 0, conf.ArraySize())
+	for i := 0; i < conf.ArraySize(); i++ {
+		entry := conf.ArrayEntry(i)
+		pattern, err := regexp.Compile(entry.GetString(tmconfig.ErrorReasonConfigPattern))
+		if err != nil {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidErrorReasonPattern, entry.GetString(tmconfig.ErrorReasonConfigPattern), i, err)
+		}
+		reason := ffcapi.ErrorReason(entry.GetString(tmconfig.ErrorReasonConfigReason))
+		if !knownErrorReasons[reason] {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidErrorReason, reason, i)
+		}
+		mappings = append(mappings, &errorReasonMapping{pattern: pattern, reason: reason})
+	}
+	return mappings, nil
+}
+
+// classifyError returns the reason for an error from the connector. A reason supplied by the connector
+// always takes precedence, and otherwise the first mapping with a pattern matching the error is used.
+func classifyError(ctx context.Context, mappings []*errorReasonMapping, reason ffcapi.ErrorReason, err error) ffcapi.ErrorReason {
+	if err == nil || reason != "" {
+		return reason
+	}
+	for _, mapping := range mappings {
+		if mapping.pattern.MatchString(err.Error()) {
+			log.L(ctx).Debugf("Error from connector classified as %s by pattern '%s': %s", mapping.reason, mapping.pattern, err)
+			return mapping.reason
+		}
+	}
+	return ""
+}
+
+// initErrorReasons must be called before the connector is passed to any other component, as the
+// circuit breaker is put in place around the connector to apply the mappings if it is not already
+func (m *manager) initErrorReasons(ctx context.Context) error {
+	mappings, err := errorReasonMappingsFromConfig(ctx, tmconfig.ErrorReasonsConfig)
+	if err != nil || len(mappings) == 0 {
+		return err
+	}
+	if m.connectorBreaker == nil {
+		m.connectorBreaker = newConnectorBreaker(m.connector, 0, 0)
+		m.connector = m.connectorBreaker
+	}
+	m.connectorBreaker.errorReasons = mappings
+	return nil
+}
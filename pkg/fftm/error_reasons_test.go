@@ -0,0 +1,174 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setTestErrorReasonsConfig(t *testing.T, yaml string) {
+	InitConfig()
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(yaml))
+	assert.NoError(t, err)
+}
+
+func TestErrorReasonMappingsClassify(t *testing.T) {
+
+	setTestErrorReasonsConfig(t, `
+errorreasons:
+- pattern: "(?i)(nonce too low|invalid nonce)"
+  reason: nonce_too_low
+- pattern: "fee too low"
+  reason: transaction_underpriced
+- pattern: "too low"
+  reason: insufficient_funds
+`)
+	ctx := context.Background()
+	mappings, err := errorReasonMappingsFromConfig(ctx, tmconfig.ErrorReasonsConfig)
+	assert.NoError(t, err)
+	assert.Len(t, mappings, 3)
+
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, classifyError(ctx, mappings, "", fmt.Errorf("Nonce too low")))
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, classifyError(ctx, mappings, "", fmt.Errorf("rpc error: invalid nonce for account")))
+	// The first matching pattern wins
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, classifyError(ctx, mappings, "", fmt.Errorf("replacement fee too low")))
+	assert.Equal(t, ffcapi.ErrorReasonInsufficientFunds, classifyError(ctx, mappings, "", fmt.Errorf("balance too low")))
+	// Unmatched errors stay unclassified
+	assert.Empty(t, classifyError(ctx, mappings, "", fmt.Errorf("connection refused")))
+	// The connector's own classification takes precedence
+	assert.Equal(t, ffcapi.ErrorKnownTransaction, classifyError(ctx, mappings, ffcapi.ErrorKnownTransaction, fmt.Errorf("invalid nonce")))
+	// No error, no reason
+	assert.Empty(t, classifyError(ctx, mappings, "", nil))
+
+}
+
+func TestErrorReasonMappingsBadPattern(t *testing.T) {
+
+	setTestErrorReasonsConfig(t, `
+errorreasons:
+- pattern: "[unclosed"
+  reason: nonce_too_low
+`)
+	_, err := errorReasonMappingsFromConfig(context.Background(), tmconfig.ErrorReasonsConfig)
+	assert.Regexp(t, "FF21140", err)
+
+}
+
+func TestErrorReasonMappingsBadReason(t *testing.T) {
+
+	setTestErrorReasonsConfig(t, `
+errorreasons:
+- pattern: "nonce"
+  reason: nonce_too_high
+`)
+	_, err := errorReasonMappingsFromConfig(context.Background(), tmconfig.ErrorReasonsConfig)
+	assert.Regexp(t, "FF21141.*nonce_too_high", err)
+
+}
+
+func TestInitErrorReasonsWrapsConnector(t *testing.T) {
+
+	setTestErrorReasonsConfig(t, `
+errorreasons:
+- pattern: "invalid nonce"
+  reason: nonce_too_low
+`)
+	mca := &ffcapimocks.API{}
+	m := newManager(context.Background(), mca)
+	err := m.initErrorReasons(m.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, m.connectorBreaker, m.connector)
+	assert.Equal(t, mca, m.baseConnector())
+
+	mca.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("invalid nonce")).Times(5)
+	for i := 0; i < 5; i++ {
+		_, reason, err := m.connector.TransactionSend(m.ctx, &ffcapi.TransactionSendRequest{})
+		assert.Regexp(t, "invalid nonce", err)
+		assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+	}
+
+	// The breaker is only in place for the mappings, so never opens
+	mca.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Times(5)
+	for i := 0; i < 5; i++ {
+		_, reason, err := m.connector.TransactionSend(m.ctx, &ffcapi.TransactionSendRequest{})
+		assert.Regexp(t, "pop", err)
+		assert.Empty(t, reason)
+	}
+	assert.False(t, m.connectorBreaker.isOpen())
+	mca.AssertExpectations(t)
+
+}
+
+func TestInitErrorReasonsWithBreaker(t *testing.T) {
+
+	setTestErrorReasonsConfig(t, `
+errorreasons:
+- pattern: "invalid nonce"
+  reason: nonce_too_low
+`)
+	config.Set(tmconfig.CircuitBreakerFailureThreshold, 1)
+	mca := &ffcapimocks.API{}
+	m := newManager(context.Background(), mca)
+	breaker := m.connectorBreaker
+	err := m.initErrorReasons(m.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, breaker, m.connectorBreaker)
+
+	// A classified error is not a failure, as the connector is reachable
+	mca.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("invalid nonce")).Once()
+	_, reason, err := m.connector.TransactionSend(m.ctx, &ffcapi.TransactionSendRequest{})
+	assert.Regexp(t, "invalid nonce", err)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+	assert.False(t, m.connectorBreaker.isOpen())
+
+}
+
+func TestInitErrorReasonsNoneConfigured(t *testing.T) {
+
+	InitConfig()
+	mca := &ffcapimocks.API{}
+	m := newManager(context.Background(), mca)
+	err := m.initErrorReasons(m.ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, m.connectorBreaker)
+	assert.Equal(t, mca, m.connector)
+
+}
+
+func TestInitServicesBadErrorReasons(t *testing.T) {
+
+	setTestErrorReasonsConfig(t, `
+errorreasons:
+- pattern: "nonce"
+`)
+	m := newManager(context.Background(), &ffcapimocks.API{})
+	err := m.initServices(m.ctx)
+	assert.Regexp(t, "FF21141", err)
+
+}
@@ -0,0 +1,71 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// eventStreamSSEHandler delivers batches from an event stream to a Server-Sent Events client, until the
+// client disconnects. It is registered outside of the API routes, as those apply a request timeout.
+// With ack=manual each batch must be acknowledged before the next is delivered, otherwise each batch
+// is acknowledged once it has been written to the client.
+//
+// The response is ended before the API server write timeout is reached. Clients reconnect automatically,
+// and a batch that was not acknowledged is redelivered.
+func (m *manager) eventStreamSSEHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := log.WithLogField(req.Context(), "sse", fftypes.ShortID())
+	if m.sseConnectionLimit > 0 {
+		var cancelCtx func()
+		ctx, cancelCtx = context.WithTimeout(ctx, m.sseConnectionLimit)
+		defer cancelCtx()
+	}
+	idStr := mux.Vars(req)["streamId"]
+	id, err := fftypes.ParseUUID(ctx, idStr)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, &fftypes.RESTError{Error: err.Error()})
+		return
+	}
+	m.mux.Lock()
+	s := m.eventStreams[*id]
+	m.mux.Unlock()
+	if s == nil {
+		writeJSON(res, http.StatusNotFound, &fftypes.RESTError{Error: i18n.NewError(ctx, tmmsgs.MsgStreamNotFound, idStr).Error()})
+		return
+	}
+	if *s.Spec().Type != apitypes.EventStreamTypeSSE {
+		writeJSON(res, http.StatusBadRequest, &fftypes.RESTError{Error: i18n.NewError(ctx, tmmsgs.MsgStreamNotSSE, idStr).Error()})
+		return
+	}
+
+	manualAck := strings.EqualFold(req.URL.Query().Get("ack"), "manual")
+	log.L(ctx).Infof("SSE client connected to event stream %s (manualAck=%t)", idStr, manualAck)
+	if err := s.ServeSSE(ctx, res, manualAck); err != nil {
+		log.L(ctx).Warnf("SSE client of event stream %s failed: %s", idStr, err)
+		return
+	}
+	log.L(ctx).Infof("SSE client disconnected from event stream %s", idStr)
+}
@@ -0,0 +1,113 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestSSEStream(t *testing.T, url, streamType string) *apitypes.EventStream {
+	var es apitypes.EventStream
+	res, err := resty.New().R().
+		SetBody(map[string]interface{}{
+			"name": "my event stream",
+			"type": streamType,
+		}).
+		SetResult(&es).
+		Post(url + "/eventstreams")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	return &es
+}
+
+func TestEventStreamSSEConnectionLimit(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	m.sseConnectionLimit = 50 * time.Millisecond
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	es := newTestSSEStream(t, url, "sse")
+
+	// The response ends before the server write timeout, so the client reconnects
+	startTime := time.Now()
+	res, err := http.Get(url + "/eventstreams/" + es.ID.String() + "/sse")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	_, err = ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.GreaterOrEqual(t, time.Since(startTime), m.sseConnectionLimit)
+
+}
+
+func TestEventStreamSSEErrors(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	es := newTestSSEStream(t, url, "websocket")
+
+	var errRes struct {
+		Error string `json:"error"`
+	}
+	res, err := resty.New().R().
+		SetError(&errRes).
+		Get(url + "/eventstreams/" + es.ID.String() + "/sse")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21102", errRes.Error)
+
+	res, err = resty.New().R().
+		SetError(&errRes).
+		Get(url + "/eventstreams/6cb1bd4d-c6b4-4d2d-95d5-a5ab1a6be3e1/sse")
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+	assert.Regexp(t, "FF21045", errRes.Error)
+
+	res, err = resty.New().R().
+		SetError(&errRes).
+		Get(url + "/eventstreams/bad/sse")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF00138", errRes.Error)
+
+}
@@ -0,0 +1,118 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// livenessHandler reports the process is up and serving requests, regardless of whether startup is complete
+func (m *manager) livenessHandler(res http.ResponseWriter, req *http.Request) {
+	writeJSON(res, http.StatusOK, map[string]interface{}{})
+}
+
+// readinessHandler returns 503 until the event streams are restored, the block listener has
+// received a block, and the policy loop has completed a cycle, and while the connector is unhealthy
+// or the circuit breaker around it is open.
+// A follower, when leader election is enabled, is ready once the event streams are restored.
+func (m *manager) readinessHandler(res http.ResponseWriter, req *http.Request) {
+	status := m.readiness()
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(res, code, status)
+}
+
+func (m *manager) readiness() *apitypes.ReadinessStatus {
+	m.mux.Lock()
+	status := &apitypes.ReadinessStatus{
+		StreamsRestored:   m.streamsRestored,
+		LastPolicyLoop:    m.lastPolicyLoop,
+		ConnectorHealthy:  m.connectorHealthy,
+		SubmissionsPaused: m.submissionsPaused,
+		LastHealthCheck:   m.lastHealthCheck,
+		Leader:            m.leader,
+		NonceGaps:         m.sortedNonceGaps(),
+	}
+	follower := m.leaderElection && !m.leader
+	m.mux.Unlock()
+	status.LastBlock = m.confirmations.HighestBlockSeen()
+	connectorAvailable := status.ConnectorHealthy
+	if m.connectorBreaker != nil && m.connectorBreaker.failureThreshold > 0 {
+		status.ConnectorBreaker = m.connectorBreaker.status()
+		connectorAvailable = connectorAvailable && status.ConnectorBreaker.State != apitypes.CircuitBreakerOpen
+	}
+	if follower {
+		status.Ready = status.StreamsRestored && connectorAvailable
+	} else {
+		status.Ready = status.StreamsRestored && status.LastBlock > 0 && status.LastPolicyLoop != nil && connectorAvailable
+	}
+	return status
+}
+
+func (m *manager) connectorHealthCheckLoop() {
+	defer close(m.healthCheckDone)
+	ctx := log.WithLogField(m.ctx, "role", "healthcheck")
+	for {
+		timer := time.NewTimer(m.healthCheckInterval)
+		select {
+		case <-timer.C:
+			m.checkConnectorHealth(ctx)
+		case <-ctx.Done():
+			timer.Stop()
+			log.L(ctx).Debugf("Connector health check exiting")
+			return
+		}
+	}
+}
+
+// checkConnectorHealth uses a gas price estimate as a lightweight call that every connector supports,
+// that does not depend on any particular signer or transaction
+func (m *manager) checkConnectorHealth(ctx context.Context) bool {
+	_, _, err := m.connector.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	healthy := err == nil
+
+	m.mux.Lock()
+	wasHealthy := m.connectorHealthy
+	m.connectorHealthy = healthy
+	m.lastHealthCheck = fftypes.Now()
+	m.mux.Unlock()
+
+	switch {
+	case wasHealthy && !healthy:
+		log.L(ctx).Warnf("Blockchain connector is unhealthy, pausing submissions: %s", err)
+	case !wasHealthy && healthy:
+		log.L(ctx).Infof("Blockchain connector is healthy, resuming submissions")
+		m.markInflightUpdate()
+	}
+	return healthy
+}
+
+func writeJSON(res http.ResponseWriter, code int, body interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(code)
+	_ = json.NewEncoder(res).Encode(body)
+}
@@ -0,0 +1,205 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getReadiness(t *testing.T, url string) (int, *apitypes.ReadinessStatus) {
+	res, err := http.Get(url + "/readyz")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	var status apitypes.ReadinessStatus
+	err = json.NewDecoder(res.Body).Decode(&status)
+	assert.NoError(t, err)
+	return res.StatusCode, &status
+}
+
+func TestLiveness(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	server := httptest.NewServer(m.router())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/livez")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+}
+
+func TestReadinessAfterFirstPolicyLoop(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	server := httptest.NewServer(m.router())
+	defer server.Close()
+
+	mcm := m.confirmations.(*confirmationsmocks.Manager)
+	lastBlock := uint64(0)
+	mcm.On("HighestBlockSeen").Return(func() uint64 { return lastBlock })
+
+	code, status := getReadiness(t, server.URL)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, status.Ready)
+
+	m.streamsRestored = true
+	lastBlock = 12345
+	code, status = getReadiness(t, server.URL)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, status.Ready)
+	assert.True(t, status.StreamsRestored)
+	assert.Equal(t, uint64(12345), status.LastBlock)
+	assert.Nil(t, status.LastPolicyLoop)
+
+	// Only ready once the policy loop has completed a cycle
+	m.policyLoopCycle(m.ctx, true)
+	code, status = getReadiness(t, server.URL)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, status.Ready)
+	assert.Equal(t, uint64(12345), status.LastBlock)
+	assert.NotNil(t, status.LastPolicyLoop)
+
+}
+
+func TestReadinessNoBlocks(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mcm := m.confirmations.(*confirmationsmocks.Manager)
+	mcm.On("HighestBlockSeen").Return(uint64(0))
+
+	m.streamsRestored = true
+	m.policyLoopCycle(m.ctx, true)
+	status := m.readiness()
+	assert.False(t, status.Ready)
+	assert.NotNil(t, status.LastPolicyLoop)
+
+}
+
+func TestReadinessAfterStart(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mcm := m.confirmations.(*confirmationsmocks.Manager)
+	mcm.On("HighestBlockSeen").Return(uint64(12345))
+
+	assert.False(t, m.readiness().Ready)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	// The API server starts asynchronously, and the first policy loop cycle runs after it
+	for {
+		res, err := http.Get(url + "/readyz")
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				break
+			}
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	status := m.readiness()
+	assert.True(t, status.StreamsRestored)
+	assert.NotNil(t, status.LastPolicyLoop)
+
+}
+
+func TestConnectorHealthPausesAndResumesSubmissions(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+
+	mcm := m.confirmations.(*confirmationsmocks.Manager)
+	mcm.On("HighestBlockSeen").Return(uint64(12345))
+	m.streamsRestored = true
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{}, ffcapi.ErrorReason(""), nil).Once()
+
+	newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+
+	// Connector goes down - the policy engine is not invoked, and we are not ready
+	assert.False(t, m.checkConnectorHealth(m.ctx))
+	m.policyLoopCycle(m.ctx, true)
+	m.policyLoopCycle(m.ctx, false)
+	mpe.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything)
+	status := m.readiness()
+	assert.False(t, status.Ready)
+	assert.False(t, status.ConnectorHealthy)
+	assert.NotNil(t, status.LastHealthCheck)
+
+	// Connector comes back - the policy loop is woken, and submissions resume
+	assert.True(t, m.checkConnectorHealth(m.ctx))
+	waitInflightSignal(t, m, false)
+	m.policyLoopCycle(m.ctx, false)
+	mpe.AssertNumberOfCalls(t, "Execute", 1)
+	status = m.readiness()
+	assert.True(t, status.Ready)
+	assert.True(t, status.ConnectorHealthy)
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestConnectorHealthCheckLoop(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	m.healthCheckInterval = 1 * time.Millisecond
+
+	checked := make(chan struct{})
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once().Run(func(args mock.Arguments) {
+		close(checked)
+	})
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+	<-checked
+
+	cancel()
+	<-m.healthCheckDone
+	assert.False(t, m.connectorHealthy)
+
+}
@@ -0,0 +1,64 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import "sync"
+
+// inflightSignal wakes the policy loop when there is work to do. Signals are coalesced, rather
+// than queued, but a signal raised at any point (including while a cycle is running) guarantees
+// at least one further cycle starts after it - without waiting for the policy loop interval.
+//
+// - stale means the in-flight set must be reloaded from persistence (such as for a new submission)
+// - update means an in-flight transaction has changed in memory (such as a receipt arriving)
+type inflightSignal struct {
+	mux    sync.Mutex
+	stale  bool
+	update bool
+	wake   chan struct{}
+}
+
+func newInflightSignal() *inflightSignal {
+	return &inflightSignal{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// mark records the signal, before ensuring the policy loop is woken. The flags are always set
+// before the wake, so a loop that has just consumed a wake will see them when it calls take.
+func (s *inflightSignal) mark(stale bool) {
+	s.mux.Lock()
+	if stale {
+		s.stale = true
+	} else {
+		s.update = true
+	}
+	s.mux.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns, and clears, the signals raised since the last call. A wake that is left over
+// after its signals were consumed by an earlier take returns pending=false.
+func (s *inflightSignal) take() (pending, stale bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	pending, stale = s.stale || s.update, s.stale
+	s.stale, s.update = false, false
+	return pending, stale
+}
@@ -0,0 +1,160 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func waitInflightSignal(t *testing.T, m *manager, stale bool) {
+	<-m.inflightSignal.wake
+	pending, wasStale := m.inflightSignal.take()
+	assert.True(t, pending)
+	assert.Equal(t, stale, wasStale)
+}
+
+func TestInflightSignalCoalesces(t *testing.T) {
+
+	s := newInflightSignal()
+	pending, _ := s.take()
+	assert.False(t, pending)
+
+	s.mark(false)
+	s.mark(true)
+	s.mark(false)
+	<-s.wake
+	assert.Empty(t, s.wake)
+	pending, stale := s.take()
+	assert.True(t, pending)
+	assert.True(t, stale)
+
+	pending, _ = s.take()
+	assert.False(t, pending)
+
+}
+
+func TestInflightSignalAfterTakeWakesAgain(t *testing.T) {
+
+	s := newInflightSignal()
+	s.mark(true)
+
+	// A signal that arrives between the wake and the take is included in the take, and leaves a
+	// spare wake behind that finds nothing pending
+	<-s.wake
+	s.mark(false)
+	pending, stale := s.take()
+	assert.True(t, pending)
+	assert.True(t, stale)
+	<-s.wake
+	pending, _ = s.take()
+	assert.False(t, pending)
+
+	// A signal that arrives after the take always results in a further wake
+	s.mark(false)
+	<-s.wake
+	pending, stale = s.take()
+	assert.True(t, pending)
+	assert.False(t, stale)
+
+}
+
+func TestSubmitDuringPolicyCycleProcessedPromptly(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 1 * time.Hour
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	inCycle := make(chan struct{})
+	releaseCycle := make(chan struct{})
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.TransactionHeaders.From == "0xaaaaa"
+	})).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once().Run(func(args mock.Arguments) {
+		close(inCycle)
+		<-releaseCycle
+	})
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.TransactionHeaders.From == "0xaaaaa"
+	})).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+	secondProcessed := make(chan struct{})
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.TransactionHeaders.From == "0xbbbbb"
+	})).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once().Run(func(args mock.Arguments) {
+		close(secondProcessed)
+	})
+
+	_ = sendSampleTX(t, m, "0xaaaaa", 12345)
+	err := m.Start()
+	assert.NoError(t, err)
+
+	// Submit the second transaction while the policy loop is mid-cycle
+	<-inCycle
+	_ = sendSampleTX(t, m, "0xbbbbb", 12345)
+	close(releaseCycle)
+
+	select {
+	case <-secondProcessed:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Transaction submitted during a policy cycle was not processed")
+	}
+
+}
+
+func TestSubmitBurstProcessedPromptly(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 1 * time.Hour
+	m.maxInFlight = 10
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	processed := make(chan string, 100)
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		processed <- args[2].(*apitypes.ManagedTX).ID
+	})
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	signers := []string{"0xaaaaa", "0xbbbbb", "0xccccc", "0xddddd", "0xeeeee"}
+	remaining := make(map[string]bool)
+	for _, signer := range signers {
+		remaining[sendSampleTX(t, m, signer, 1000).ID] = true
+	}
+
+	timeout := time.After(5 * time.Second)
+	for len(remaining) > 0 {
+		select {
+		case id := <-processed:
+			delete(remaining, id)
+		case <-timeout:
+			assert.Fail(t, "Burst of submissions was not processed", "remaining=%v", remaining)
+			return
+		}
+	}
+
+}
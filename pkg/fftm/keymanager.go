@@ -0,0 +1,71 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/keymanager"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/keymanagers"
+)
+
+// passthroughKeyManager is used when no key manager is configured, and uses each key name as the signing address
+type passthroughKeyManager struct{}
+
+func (pk *passthroughKeyManager) ResolveKey(ctx context.Context, keyName string) (string, error) {
+	return keyName, nil
+}
+
+func (pk *passthroughKeyManager) ListKeys(ctx context.Context) ([]*keymanager.KeyMapping, error) {
+	return []*keymanager.KeyMapping{}, nil
+}
+
+func (m *manager) initKeyManager(ctx context.Context) (err error) {
+	name := config.GetString(tmconfig.KeyManagerName)
+	if name == "" {
+		m.keyManager = &passthroughKeyManager{}
+		return nil
+	}
+	m.keyManager, err = keymanagers.NewKeyManager(ctx, tmconfig.KeyManagerBaseConfig, name)
+	return err
+}
+
+// resolveSigner sets the from address of a submission that references a key name, to the address the key manager
+// resolves it to. The routes do this before the rate limit is checked, so that a key name shares the bucket of
+// the address it resolves to, and before the transaction is prepared, so the connector only sees the address.
+func (m *manager) resolveSigner(ctx context.Context, reqHeaders *apitypes.RequestHeaders, txHeaders *ffcapi.TransactionHeaders) error {
+	if reqHeaders.KeyName == "" {
+		return nil
+	}
+	if txHeaders.From != "" {
+		return i18n.NewError(ctx, tmmsgs.MsgKeyNameAndFrom)
+	}
+	address, err := m.keyManager.ResolveKey(ctx, reqHeaders.KeyName)
+	if err != nil {
+		return i18n.WrapError(ctx, err, tmmsgs.MsgKeyResolveFailed, reqHeaders.KeyName)
+	}
+	log.L(ctx).Debugf("Resolved key name %s to signing address %s", reqHeaders.KeyName, address)
+	txHeaders.From = address
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/keymanager"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/keymanagers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type stubKeyManagerFactory struct{}
+
+type stubKeyManager struct {
+	keys map[string]string
+}
+
+func (f *stubKeyManagerFactory) Name() string {
+	return "stub"
+}
+
+func (f *stubKeyManagerFactory) InitConfig(conf config.Section) {}
+
+func (f *stubKeyManagerFactory) NewKeyManager(ctx context.Context, conf config.Section) (keymanager.KeyManager, error) {
+	return &stubKeyManager{}, nil
+}
+
+func (km *stubKeyManager) ResolveKey(ctx context.Context, keyName string) (string, error) {
+	address, ok := km.keys[keyName]
+	if !ok {
+		return "", fmt.Errorf("pop")
+	}
+	return address, nil
+}
+
+func (km *stubKeyManager) ListKeys(ctx context.Context) ([]*keymanager.KeyMapping, error) {
+	keys := make([]*keymanager.KeyMapping, 0, len(km.keys))
+	for keyName, address := range km.keys {
+		keys = append(keys, &keymanager.KeyMapping{KeyName: keyName, Address: address})
+	}
+	return keys, nil
+}
+
+func TestKeyManagerResolvesKeyName(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.keyManager = &stubKeyManager{keys: map[string]string{"treasury": "0xaaaaa"}}
+	server := httptest.NewServer(m.router())
+	defer server.Close()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.MatchedBy(func(prepTX *ffcapi.TransactionPrepareRequest) bool {
+		return prepTX.From == "0xaaaaa"
+	})).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("prepared"))
+	mFFC.On("DeployContractPrepare", mock.Anything, mock.MatchedBy(func(prepTX *ffcapi.ContractDeployPrepareRequest) bool {
+		return prepTX.From == "0xaaaaa"
+	})).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("prepared"))
+
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{Type: apitypes.RequestTypeSendTransaction, KeyName: "treasury"},
+		}).
+		Post(server.URL)
+	assert.NoError(t, err)
+	assert.Regexp(t, "prepared", res.String())
+
+	res, err = resty.New().R().
+		SetBody(&apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{KeyName: "treasury"},
+		}).
+		Post(server.URL + "/transactions/estimate")
+	assert.NoError(t, err)
+	assert.Regexp(t, "prepared", res.String())
+
+	res, err = resty.New().R().
+		SetBody(&apitypes.ContractDeployRequest{
+			Headers: apitypes.RequestHeaders{Type: apitypes.RequestTypeDeploy, KeyName: "treasury"},
+		}).
+		Post(server.URL)
+	assert.NoError(t, err)
+	assert.Regexp(t, "prepared", res.String())
+
+	mFFC.AssertExpectations(t)
+
+}
+
+func TestKeyManagerResolveFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.keyManager = &stubKeyManager{keys: map[string]string{}}
+	server := httptest.NewServer(m.router())
+	defer server.Close()
+
+	for _, reqType := range []apitypes.RequestType{apitypes.RequestTypeSendTransaction, apitypes.RequestTypeDeploy} {
+		res, err := resty.New().R().
+			SetBody(&apitypes.TransactionRequest{
+				Headers: apitypes.RequestHeaders{Type: reqType, KeyName: "unknown"},
+			}).
+			Post(server.URL)
+		assert.NoError(t, err)
+		assert.Regexp(t, "FF21195.*unknown.*pop", res.String())
+	}
+
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{KeyName: "unknown"},
+		}).
+		Post(server.URL + "/transactions/estimate")
+	assert.NoError(t, err)
+	assert.Regexp(t, "FF21195.*unknown.*pop", res.String())
+
+}
+
+func TestKeyManagerKeyNameAndFrom(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	server := httptest.NewServer(m.router())
+	defer server.Close()
+
+	req := &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{Type: apitypes.RequestTypeSendTransaction, KeyName: "treasury"},
+	}
+	req.From = "0xaaaaa"
+	res, err := resty.New().R().SetBody(req).Post(server.URL)
+	assert.NoError(t, err)
+	assert.Regexp(t, "FF21194", res.String())
+
+}
+
+func TestKeyManagerDefaultPassthrough(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	assert.IsType(t, &passthroughKeyManager{}, m.keyManager)
+
+	txHeaders := &ffcapi.TransactionHeaders{}
+	err := m.resolveSigner(m.ctx, &apitypes.RequestHeaders{KeyName: "0xaaaaa"}, txHeaders)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xaaaaa", txHeaders.From)
+
+	keys, err := m.keyManager.ListKeys(m.ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+}
+
+func TestInitKeyManagerPlugin(t *testing.T) {
+
+	tmconfig.Reset()
+	keymanagers.RegisterKeyManager(&stubKeyManagerFactory{})
+	config.Set(tmconfig.KeyManagerName, "stub")
+
+	m := newManager(context.Background(), nil)
+	err := m.initKeyManager(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &stubKeyManager{}, m.keyManager)
+
+}
+
+func TestInitKeyManagerNotRegistered(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.KeyManagerName, "wrong")
+
+	m := newManager(context.Background(), nil)
+	err := m.initKeyManager(context.Background())
+	assert.Regexp(t, "FF21193", err)
+
+}
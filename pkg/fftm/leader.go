@@ -0,0 +1,168 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/blocklistener"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const leaderLeaseName = "leader"
+
+// startLeaderServices starts the block listener, policy loop and confirmation manager.
+// When leader election is enabled, these only run on the replica holding the leader lease.
+func (m *manager) startLeaderServices() error {
+	m.leaderCtx, m.cancelLeaderCtx = context.WithCancel(m.ctx)
+	// Check on the first policy loop cycle for scheduled transactions persisted before a restart
+	m.markScheduled(time.Time{})
+	// The emergency stop must be in force before the policy loop runs, so a restart cannot resume submissions
+	if err := m.loadSubmissionControl(m.leaderCtx); err != nil {
+		m.cancelLeaderCtx()
+		return err
+	}
+
+	blReq := &ffcapi.NewBlockListenerRequest{ListenerContext: m.leaderCtx, ID: fftypes.NewUUID()}
+	blReq.BlockListener, m.blockListenerDone = blocklistener.BufferChannel(m.leaderCtx, m.confirmations)
+	_, _, err := m.connector.NewBlockListener(m.leaderCtx, blReq)
+	if err == nil {
+		err = m.startNamedConnectorListeners()
+	}
+	if err != nil {
+		m.cancelLeaderCtx()
+		return err
+	}
+
+	m.policyLoopDone = make(chan struct{})
+	m.policyLoopDrain = make(chan struct{})
+	m.markInflightStale()
+	go m.policyLoop()
+	go m.confirmations.Start()
+	for _, nc := range m.connectors {
+		go nc.confirmations.Start()
+	}
+
+	m.mux.Lock()
+	m.leader = true
+	m.mux.Unlock()
+	return nil
+}
+
+// stopLeaderServices stops everything started by startLeaderServices, and discards the in-flight
+// transactions, so they are reloaded from persistence if this replica becomes the leader again
+func (m *manager) stopLeaderServices() {
+	m.mux.Lock()
+	m.leader = false
+	m.mux.Unlock()
+
+	m.cancelLeaderCtx()
+	<-m.policyLoopDone
+	<-m.blockListenerDone
+	m.confirmations.Stop()
+	m.stopNamedConnectors()
+	m.inflight = nil
+	m.inflightRestored = false
+	m.lastNonceGapCheck = time.Time{}
+	m.mux.Lock()
+	m.nonceGaps = make(map[string]*apitypes.NonceGap)
+	m.inflightBySigner = nil
+	m.mux.Unlock()
+}
+
+// leaderElectionLoop attempts to obtain, or renew, the leader lease at a third of the lease TTL.
+// A replica that cannot renew its lease steps down immediately, so it stops before another
+// replica can take over after the lease expires.
+func (m *manager) leaderElectionLoop() {
+	defer close(m.leaderElectionDone)
+	ctx := log.WithLogField(m.ctx, "role", "leaderelection")
+
+	for {
+		m.checkLeadership(ctx)
+		timer := time.NewTimer(m.leaseTTL / 3)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			m.leaderMux.Lock()
+			if m.isLeader() {
+				m.stopLeaderServices()
+				// Release the lease so another replica can take over without waiting for it to expire
+				if err := m.persistence.ReleaseLease(context.Background(), leaderLeaseName, m.instanceID); err != nil {
+					log.L(ctx).Warnf("Failed to release leader lease: %s", err)
+				}
+			}
+			m.leaderMux.Unlock()
+			log.L(ctx).Debugf("Leader election loop exiting")
+			return
+		}
+	}
+}
+
+func (m *manager) checkLeadership(ctx context.Context) {
+	m.leaderMux.Lock()
+	defer m.leaderMux.Unlock()
+	m.mux.Lock()
+	leader := m.leader
+	draining := m.draining
+	m.mux.Unlock()
+	if draining {
+		return
+	}
+
+	acquired, err := m.persistence.AcquireLease(ctx, leaderLeaseName, m.instanceID, m.leaseTTL)
+	if err != nil {
+		log.L(ctx).Errorf("Failed to obtain leader lease: %s", err)
+		acquired = false
+	}
+	switch {
+	case acquired && !leader:
+		log.L(ctx).Infof("Obtained leader lease as replica %s", m.instanceID)
+		if err := m.startLeaderServices(); err != nil {
+			log.L(ctx).Errorf("Failed to start as leader: %s", err)
+			if err := m.persistence.ReleaseLease(ctx, leaderLeaseName, m.instanceID); err != nil {
+				log.L(ctx).Warnf("Failed to release leader lease: %s", err)
+			}
+		}
+	case !acquired && leader:
+		log.L(ctx).Warnf("Lost leader lease as replica %s", m.instanceID)
+		m.stopLeaderServices()
+	}
+}
+
+func (m *manager) isLeader() bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.leader
+}
+
+// checkLeader rejects requests that would modify transactions on a follower, as only the leader runs the policy loop
+func (m *manager) checkLeader(ctx context.Context) error {
+	m.mux.Lock()
+	follower := m.leaderElection && !m.leader
+	m.mux.Unlock()
+	if follower {
+		return i18n.NewError(ctx, tmmsgs.MsgNotLeader)
+	}
+	return nil
+}
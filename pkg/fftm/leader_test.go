@@ -0,0 +1,246 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// sharedPersistence lets two managers share one store, as replicas would share a database,
+// and allows lease renewal to be failed to simulate a leader losing access to the store
+type sharedPersistence struct {
+	persistence.Persistence
+	mux          sync.Mutex
+	leaseErr     error
+	lastAcquired time.Time // the start of the most recent successful acquire or renewal
+}
+
+func (sp *sharedPersistence) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	sp.mux.Lock()
+	err := sp.leaseErr
+	sp.mux.Unlock()
+	if err != nil {
+		return false, err
+	}
+	started := time.Now()
+	acquired, err := sp.Persistence.AcquireLease(ctx, name, holder, ttl)
+	if acquired {
+		sp.mux.Lock()
+		sp.lastAcquired = started
+		sp.mux.Unlock()
+	}
+	return acquired, err
+}
+
+func (sp *sharedPersistence) setLeaseErr(err error) {
+	sp.mux.Lock()
+	defer sp.mux.Unlock()
+	sp.leaseErr = err
+}
+
+func (sp *sharedPersistence) Close(ctx context.Context) {
+	// The store is closed once both managers are closed
+}
+
+func newTestLeaderElectionManagers(t *testing.T) (m1, m2 *manager, sp1, sp2 *sharedPersistence, done func()) {
+	_, m1, close1 := newTestManager(t)
+	_, m2, close2 := newTestManagerMockPersistence(t)
+
+	store := m1.persistence
+	sp1 = &sharedPersistence{Persistence: store}
+	sp2 = &sharedPersistence{Persistence: store}
+	m1.persistence = sp1
+	m2.persistence = sp2
+
+	mca := m2.connector.(*ffcapimocks.API)
+	mca.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), nil).Maybe()
+	m2.confirmations = &confirmationsmocks.Manager{}
+
+	for _, m := range []*manager{m1, m2} {
+		m.leaderElection = true
+		m.leaseTTL = 100 * time.Millisecond
+		m.healthCheckInterval = 0
+		mcm := m.confirmations.(*confirmationsmocks.Manager)
+		mcm.On("Start").Return().Maybe()
+		mcm.On("Stop").Return().Maybe()
+		mcm.On("HighestBlockSeen").Return(uint64(0)).Maybe()
+	}
+
+	return m1, m2, sp1, sp2, func() {
+		close2()
+		close1()
+		store.Close(context.Background())
+	}
+}
+
+// watchLeaders records every time both managers are seen to be running the policy loop at once
+func watchLeaders(m1, m2 *manager) (stop func() int) {
+	overlaps := 0
+	stopCh := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			if m1.isLeader() && m2.isLeader() {
+				overlaps++
+			}
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(1 * time.Millisecond):
+			}
+		}
+	}()
+	return func() int {
+		close(stopCh)
+		<-stopped
+		return overlaps
+	}
+}
+
+func waitForLeader(t *testing.T, m *manager) {
+	for !m.isLeader() {
+		time.Sleep(1 * time.Millisecond)
+	}
+}
+
+func TestLeaderElectionFailover(t *testing.T) {
+	m1, m2, sp1, _, done := newTestLeaderElectionManagers(t)
+	defer done()
+	stopWatching := watchLeaders(m1, m2)
+
+	err := m1.Start()
+	assert.NoError(t, err)
+	waitForLeader(t, m1)
+
+	err = m2.Start()
+	assert.NoError(t, err)
+	time.Sleep(2 * m2.leaseTTL)
+	assert.True(t, m1.isLeader())
+	assert.False(t, m2.isLeader())
+
+	// The leader loses access to the store, so steps down, and the follower
+	// takes over once the lease it last renewed has expired
+	sp1.setLeaseErr(fmt.Errorf("pop"))
+	waitForLeader(t, m2)
+	sp1.mux.Lock()
+	lastRenewed := sp1.lastAcquired
+	sp1.mux.Unlock()
+	assert.GreaterOrEqual(t, time.Since(lastRenewed), m2.leaseTTL)
+	assert.False(t, m1.isLeader())
+
+	// Once the store recovers, the old leader remains a follower
+	sp1.setLeaseErr(nil)
+	time.Sleep(2 * m1.leaseTTL)
+	assert.False(t, m1.isLeader())
+	assert.True(t, m2.isLeader())
+
+	assert.Zero(t, stopWatching())
+}
+
+func TestLeaderElectionReleaseOnClose(t *testing.T) {
+	m1, m2, _, _, done := newTestLeaderElectionManagers(t)
+	defer done()
+	// The leader writes a lease that would not expire during the test
+	m1.leaseTTL = 1 * time.Hour
+	stopWatching := watchLeaders(m1, m2)
+
+	err := m1.Start()
+	assert.NoError(t, err)
+	waitForLeader(t, m1)
+
+	err = m2.Start()
+	assert.NoError(t, err)
+	time.Sleep(2 * m2.leaseTTL)
+	assert.False(t, m2.isLeader())
+
+	// Closing the leader releases the lease, so the follower does not wait for it to expire
+	m1.Close()
+	waitForLeader(t, m2)
+
+	assert.Zero(t, stopWatching())
+}
+
+func TestLeaderElectionStartFail(t *testing.T) {
+	_, m, done := newTestManagerMockPersistence(t)
+	defer done()
+	m.leaderElection = true
+	m.leaseTTL = 1 * time.Hour
+
+	released := make(chan struct{})
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListStreams", mock.Anything, mock.Anything, startupPaginationLimit, persistence.SortDirectionAscending).Return(nil, nil)
+	mp.On("AcquireLease", mock.Anything, leaderLeaseName, m.instanceID, m.leaseTTL).Return(true, nil)
+	mp.On("GetSubmissionControl", mock.Anything).Return(nil, nil)
+	mp.On("ReleaseLease", mock.Anything, leaderLeaseName, m.instanceID).Return(fmt.Errorf("pop")).Run(func(args mock.Arguments) {
+		close(released)
+	}).Once()
+
+	mca := m.connector.(*ffcapimocks.API)
+	mca.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	err := m.Start()
+	assert.NoError(t, err)
+	<-released
+	assert.False(t, m.isLeader())
+}
+
+func TestFollowerReadOnly(t *testing.T) {
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.leaderElection = true
+
+	_, err := m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, nil, "0x12345")
+	assert.Regexp(t, "FF21098", err)
+
+	_, err = m.resetNonce(m.ctx, "", "0xaaaaa")
+	assert.Regexp(t, "FF21098", err)
+
+	res := m.policyEngineAPIRequest(m.ctx, &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeDelete,
+		txID:        "ns1:tx1",
+	})
+	assert.Regexp(t, "FF21098", res.err)
+}
+
+func TestFollowerReadiness(t *testing.T) {
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.leaderElection = true
+	m.streamsRestored = true
+
+	mcm := &confirmationsmocks.Manager{}
+	mcm.On("HighestBlockSeen").Return(uint64(0))
+	m.confirmations = mcm
+
+	status := m.readiness()
+	assert.True(t, status.Ready)
+	assert.False(t, status.Leader)
+}
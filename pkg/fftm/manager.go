@@ -21,10 +21,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-resty/resty/v2"
 	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/httpserver"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-common/pkg/retry"
 	"github.com/hyperledger/firefly-transaction-manager/internal/blocklistener"
 	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
@@ -33,10 +36,16 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/internal/ws"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apiauth"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/keymanager"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/nonceallocator"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/nonceallocators"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Manager interface {
@@ -48,6 +57,8 @@ type policyEngineAPIRequestType int
 
 const (
 	policyEngineAPIRequestTypeDelete policyEngineAPIRequestType = iota
+	policyEngineAPIRequestTypeCancel
+	policyEngineAPIRequestTypeUndelete
 )
 
 // policyEngineAPIRequest requests are queued to the policy engine thread for processing against a given Transaction
@@ -70,28 +81,121 @@ type manager struct {
 	retry          *retry.Retry
 	connector      ffcapi.API
 	confirmations  confirmations.Manager
-	policyEngine   policyengine.PolicyEngine
+	policyEngine   policyengine.PolicyEngine // the default policy engine
+	policyEngines  map[string]policyengine.PolicyEngine
+	nonceAllocator nonceallocator.NonceAllocator
+	apiAuth        apiauth.APIAuth
+	keyManager     keymanager.KeyManager
 	apiServer      httpserver.HTTPServer
 	wsServer       ws.WebSocketServer
 	persistence    persistence.Persistence
-	inflightStale  chan bool
-	inflightUpdate chan bool
+	inflightSignal *inflightSignal
 	inflight       []*pendingState
+	// inflightRestored is set once the in-flight set loaded after a restart has been put in nonce order
+	inflightRestored bool
+	// inflightBySigner counts the in-flight transactions of each signer, for use outside of the policy loop under the manager mutex
+	inflightBySigner map[string]int
 
 	mux                     sync.Mutex
 	policyEngineAPIRequests []*policyEngineAPIRequest
 	lockedNonces            map[string]*lockedNonce
+	nonceRealignments       map[string]uint64 // next nonce to allocate for a signer, after a reset
+	nonceReservations       map[fftypes.UUID]*nonceReservation
+	reclaimedNonces         map[string][]uint64 // nonces of expired reservations, to assign before allocating new ones
+	txWaiters               map[string][]chan struct{}
 	eventStreams            map[fftypes.UUID]events.Stream
 	streamsByName           map[string]*fftypes.UUID
 	policyLoopDone          chan struct{}
+	policyLoopDrain         chan struct{}
+	draining                bool
+	idempotencyKeys         map[string]bool // submissions in progress, by signer and idempotency key
 	blockListenerDone       chan struct{}
+	blockListeners          blocklistener.Routers
+	blockListenersDone      chan struct{}
 	started                 bool
+	streamsRestored         bool
+	lastPolicyLoop          *fftypes.FFTime
+	connectorHealthy        bool
+	submissionsPaused       bool              // the persisted emergency stop, loaded when the policy loop starts
+	connectorBreaker        *connectorBreaker // nil unless the circuit breaker or error reason mappings are enabled, in which case it is also the connector
+	connectorAudit          *connectorAudit   // nil unless the connector audit log or tracing is enabled, in which case it is beneath the circuit breaker
+	tracer                  trace.Tracer
+	tracerProvider          *sdktrace.TracerProvider // nil unless tracing is enabled
+	lastHealthCheck         *fftypes.FFTime
+	healthCheckDone         chan struct{}
+	connectorInfo           *apitypes.ConnectorInfo // nil until first queried from the connector
+	connectorInfoDone       chan struct{}
+	defaultConnector        string                        // the name of the default connector, if constructed with named connectors
+	connectors              map[string]*namedConnector    // the connectors other than the default, by name
+	nonceGaps               map[string]*apitypes.NonceGap // by nonce key
+	lastNonceGapCheck       time.Time
+	reaperDone              chan struct{}
+	policyDecisions         chan *apitypes.PolicyDecisionEvent
+	policyDecisionsDone     chan struct{}
+	nextScheduled           *time.Time // earliest notBefore of a scheduled transaction, or nil if there are none
 	apiServerDone           chan error
+	leaderMux               sync.Mutex // serializes leadership transitions with shutdown
+	leader                  bool
+	leaderCtx               context.Context
+	cancelLeaderCtx         func()
+	leaderElectionDone      chan struct{}
 
-	policyLoopInterval time.Duration
-	nonceStateTimeout  time.Duration
-	errorHistoryCount  int
-	maxInFlight        int
+	policyLoopInterval     time.Duration // the current interval, which follows the load when adaptive
+	policyLoopAdaptive     bool
+	policyLoopMinInterval  time.Duration
+	policyLoopMaxInterval  time.Duration
+	policyLoopWorkers      int
+	dryRun                 bool
+	drainTimeout           time.Duration
+	healthCheckInterval    time.Duration
+	connectorInfoInterval  time.Duration
+	backoff                *retry.Retry
+	submitRetryLimit       retryLimit
+	resubmitRetryLimit     retryLimit
+	revertRetryMaxAttempts int
+	revertRetryDelay       time.Duration
+	errorHistoryCount      int
+	maxHistoryCount        int
+	maxInFlight            int
+	maxPendingPerSigner    int // zero if there is no maximum
+	backpressure           *backpressurePolicy
+	signerBackpressure     map[string]*backpressurePolicy // signers with their own policy
+	priorityWindow         int
+	strictNonceOrdering    bool
+	nonceGapCheckInterval  time.Duration
+	nonceReservationTTL    time.Duration
+	reaperRetention        time.Duration
+	reaperInterval         time.Duration
+	reaperBatchSize        int
+	deletedRetention       time.Duration
+	submissionTimeout      time.Duration
+	requiredConfirmations  int
+	rateLimiter            *signerRateLimiter // nil if rate limiting is disabled
+	blockGasLimit          int64
+	maxDataSize            int64 // of the encoded transaction data, in bytes
+	maxLabels              int
+	maxLabelLength         int
+	maxBatchLength         int
+	idempotencyWindow      time.Duration
+	signersAllow           map[string]bool // nil if all signers are allowed
+	signersDeny            map[string]bool
+	leaderElection         bool
+	leaseTTL               time.Duration
+	instanceID             string // identifies this replica as the holder of the leader lease
+	sseConnectionLimit     time.Duration
+
+	callbackClient      *resty.Client
+	callbacksActive     sync.WaitGroup
+	callbackRetry       *retry.Retry
+	callbackMaxAttempts int
+	webhookRetry        *retry.Retry
+	webhookMaxAttempts  int
+	webhookSecret       string
+	webhookMilestones   []int
+
+	defaultLimit       int
+	maxLimit           int
+	rejectOverMaxLimit bool
 }
 
 func InitConfig() {
@@ -100,8 +204,11 @@ func InitConfig() {
 }
 
 func NewManager(ctx context.Context, connector ffcapi.API) (Manager, error) {
+	return initManager(ctx, newManager(ctx, connector))
+}
+
+func initManager(ctx context.Context, m *manager) (Manager, error) {
 	var err error
-	m := newManager(ctx, connector)
 	if err = m.initServices(ctx); err != nil {
 		return nil, err
 	}
@@ -113,24 +220,90 @@ func NewManager(ctx context.Context, connector ffcapi.API) (Manager, error) {
 
 func newManager(ctx context.Context, connector ffcapi.API) *manager {
 	m := &manager{
-		connector:     connector,
-		lockedNonces:  make(map[string]*lockedNonce),
-		apiServerDone: make(chan error),
-		eventStreams:  make(map[fftypes.UUID]events.Stream),
-		streamsByName: make(map[string]*fftypes.UUID),
-
-		policyLoopInterval: config.GetDuration(tmconfig.PolicyLoopInterval),
-		errorHistoryCount:  config.GetInt(tmconfig.TransactionsErrorHistoryCount),
-		maxInFlight:        config.GetInt(tmconfig.TransactionsMaxInFlight),
-		nonceStateTimeout:  config.GetDuration(tmconfig.TransactionsNonceStateTimeout),
-		inflightStale:      make(chan bool, 1),
-		inflightUpdate:     make(chan bool, 1),
+		connector:         connector,
+		connectors:        make(map[string]*namedConnector),
+		lockedNonces:      make(map[string]*lockedNonce),
+		nonceRealignments: make(map[string]uint64),
+		nonceReservations: make(map[fftypes.UUID]*nonceReservation),
+		reclaimedNonces:   make(map[string][]uint64),
+		nonceGaps:         make(map[string]*apitypes.NonceGap),
+		txWaiters:         make(map[string][]chan struct{}),
+		idempotencyKeys:   make(map[string]bool),
+		apiServerDone:     make(chan error),
+		eventStreams:      make(map[fftypes.UUID]events.Stream),
+		streamsByName:     make(map[string]*fftypes.UUID),
+
+		policyLoopInterval:    config.GetDuration(tmconfig.PolicyLoopInterval),
+		policyLoopWorkers:     config.GetInt(tmconfig.PolicyLoopWorkers),
+		dryRun:                config.GetBool(tmconfig.PolicyLoopDryRun),
+		drainTimeout:          config.GetDuration(tmconfig.PolicyLoopDrainTimeout),
+		healthCheckInterval:   config.GetDuration(tmconfig.PolicyLoopHealthCheckInterval),
+		connectorInfoInterval: config.GetDuration(tmconfig.ConnectorInfoRefreshInterval),
+		connectorHealthy:      true, // until a health check tells us otherwise
+		backoff: &retry.Retry{
+			InitialDelay: config.GetDuration(tmconfig.PolicyLoopBackoffInitDelay),
+			MaximumDelay: config.GetDuration(tmconfig.PolicyLoopBackoffMaxDelay),
+			Factor:       config.GetFloat64(tmconfig.PolicyLoopBackoffFactor),
+		},
+		errorHistoryCount:     config.GetInt(tmconfig.TransactionsErrorHistoryCount),
+		maxHistoryCount:       config.GetInt(tmconfig.TransactionsMaxHistoryCount),
+		maxInFlight:           config.GetInt(tmconfig.TransactionsMaxInFlight),
+		maxPendingPerSigner:   config.GetInt(tmconfig.TransactionsMaxPendingPerSigner),
+		priorityWindow:        config.GetInt(tmconfig.TransactionsPriorityWindow),
+		strictNonceOrdering:   config.GetBool(tmconfig.TransactionsStrictNonceOrdering),
+		nonceGapCheckInterval: config.GetDuration(tmconfig.TransactionsNonceGapCheckInterval),
+		nonceReservationTTL:   config.GetDuration(tmconfig.TransactionsNonceReservationTTL),
+		reaperRetention:       config.GetDuration(tmconfig.TransactionsReaperRetention),
+		reaperInterval:        config.GetDuration(tmconfig.TransactionsReaperInterval),
+		reaperBatchSize:       config.GetInt(tmconfig.TransactionsReaperBatchSize),
+		deletedRetention:      config.GetDuration(tmconfig.TransactionsReaperDeletedRetention),
+		submissionTimeout:     config.GetDuration(tmconfig.TransactionsSubmissionTimeout),
+		requiredConfirmations: config.GetInt(tmconfig.ConfirmationsRequired),
+		blockGasLimit:         config.GetInt64(tmconfig.TransactionsBlockGasLimit),
+		maxDataSize:           config.GetByteSize(tmconfig.TransactionsMaxDataSize),
+		maxLabels:             config.GetInt(tmconfig.TransactionsLabelsMaxCount),
+		maxLabelLength:        config.GetInt(tmconfig.TransactionsLabelsMaxLength),
+		maxBatchLength:        config.GetInt(tmconfig.APIMaxBatchLength),
+		rateLimiter:           newSignerRateLimiter(config.GetFloat64(tmconfig.TransactionsRateLimitRate), config.GetInt(tmconfig.TransactionsRateLimitBurst)),
+		idempotencyWindow:     config.GetDuration(tmconfig.TransactionsIdempotencyKeyRetention),
+		signersDeny:           signerSet(config.GetStringSlice(tmconfig.TransactionsSignersDeny)),
+		leaderElection:        config.GetBool(tmconfig.LeaderElectionEnabled),
+		leaseTTL:              config.GetDuration(tmconfig.LeaderElectionLeaseTTL),
+		instanceID:            fftypes.NewUUID().String(),
+		inflightSignal:        newInflightSignal(),
+		tracer:                trace.NewNoopTracerProvider().Tracer(tracerName),
 		retry: &retry.Retry{
 			InitialDelay: config.GetDuration(tmconfig.PolicyLoopRetryInitDelay),
 			MaximumDelay: config.GetDuration(tmconfig.PolicyLoopRetryMaxDelay),
 			Factor:       config.GetFloat64(tmconfig.PolicyLoopRetryFactor),
 		},
+		callbackRetry: &retry.Retry{
+			InitialDelay: config.GetDuration(tmconfig.TransactionsCallbackRetryInitDelay),
+			MaximumDelay: config.GetDuration(tmconfig.TransactionsCallbackRetryMaxDelay),
+			Factor:       config.GetFloat64(tmconfig.TransactionsCallbackRetryFactor),
+		},
+		callbackMaxAttempts: config.GetInt(tmconfig.TransactionsCallbackMaxAttempts),
+		webhookRetry: &retry.Retry{
+			InitialDelay: config.GetDuration(tmconfig.TransactionsConfirmationWebhookRetryInitDelay),
+			MaximumDelay: config.GetDuration(tmconfig.TransactionsConfirmationWebhookRetryMaxDelay),
+			Factor:       config.GetFloat64(tmconfig.TransactionsConfirmationWebhookRetryFactor),
+		},
+		webhookMaxAttempts: config.GetInt(tmconfig.TransactionsConfirmationWebhookMaxAttempts),
+		webhookSecret:      config.GetString(tmconfig.TransactionsConfirmationWebhookSecret),
+	}
+	if allow := config.GetStringSlice(tmconfig.TransactionsSignersAllow); len(allow) > 0 {
+		m.signersAllow = signerSet(allow)
 	}
+	if threshold := config.GetInt(tmconfig.CircuitBreakerFailureThreshold); threshold > 0 {
+		m.connectorBreaker = newConnectorBreaker(connector, threshold, config.GetDuration(tmconfig.CircuitBreakerCooldown))
+		m.connector = m.connectorBreaker
+	}
+	m.submitRetryLimit = retryLimitConfig(tmconfig.PolicyLoopBackoffSubmitMaxAttempts, tmconfig.PolicyLoopBackoffSubmitMaxElapsed)
+	m.resubmitRetryLimit = retryLimitConfig(tmconfig.PolicyLoopBackoffResubmitMaxAttempts, tmconfig.PolicyLoopBackoffResubmitMaxElapsed)
+	m.revertRetryMaxAttempts = config.GetInt(tmconfig.PolicyLoopRevertRetryMaxAttempts)
+	m.revertRetryDelay = config.GetDuration(tmconfig.PolicyLoopRevertRetryDelay)
+	// The API server write timeout covers the whole response, so SSE responses end before it is reached
+	m.sseConnectionLimit = tmconfig.APIConfig.GetDuration(httpserver.HTTPConfWriteTimeout) * 4 / 5
 	m.ctx, m.cancelCtx = context.WithCancel(ctx)
 	return m
 }
@@ -138,18 +311,63 @@ func newManager(ctx context.Context, connector ffcapi.API) *manager {
 type pendingState struct {
 	mtx                     *apitypes.ManagedTX
 	lastPolicyCycle         time.Time
+	failedCycles            int       // consecutive policy engine errors, reset on success
+	firstFailure            time.Time // time of the first of the consecutive policy engine errors
+	revertRetries           int       // consecutive submissions reported as reverted, when retrying reverts
+	backoffUntil            time.Time // the policy engine is not invoked again until this time after an error
 	confirmed               bool
+	parentSucceeded         bool // set once the transaction this one depends on has succeeded
 	remove                  bool
 	trackingTransactionHash string
+	trackingCancelHash      string     // the no-op replacing a cancelled transaction, tracked alongside the original
+	cancelConfirmed         bool       // set once the no-op replacing a cancelled transaction is confirmed
+	span                    trace.Span // nil for transactions evaluated outside of the in-flight set
 }
 
 func (m *manager) initServices(ctx context.Context) (err error) {
-	m.confirmations = confirmations.NewBlockConfirmationManager(ctx, m.connector, "receipts")
-	m.policyEngine, err = policyengines.NewPolicyEngine(ctx, tmconfig.PolicyEngineBaseConfig, config.GetString(tmconfig.PolicyEngineName))
-	if err != nil {
+	if err = m.initTracing(ctx); err != nil {
+		return err
+	}
+	if err = m.initConnectorAudit(ctx); err != nil {
+		return err
+	}
+	if err = m.initErrorReasons(ctx); err != nil {
+		return err
+	}
+	if err = m.initBackpressure(ctx); err != nil {
+		return err
+	}
+	if err = m.initLimits(ctx); err != nil {
+		return err
+	}
+	m.confirmations = confirmations.NewBlockConfirmationManager(ctx, m.connector, "receipts", m.requiredConfirmations)
+	m.initNamedConnectors(ctx)
+	if err = m.initPolicyLoopInterval(ctx); err != nil {
 		return err
 	}
-	m.wsServer = ws.NewWebSocketServer(ctx)
+	if err = m.initPolicyEngines(ctx); err != nil {
+		return err
+	}
+	if m.blockListeners, err = blocklistener.NewRoutersFromConfig(ctx, tmconfig.BlockListenersConfig); err != nil {
+		return err
+	}
+	if err = m.initNonceAllocator(ctx); err != nil {
+		return err
+	}
+	if err = m.initAPIAuth(ctx); err != nil {
+		return err
+	}
+	if err = m.initKeyManager(ctx); err != nil {
+		return err
+	}
+	if err = m.initConfirmationWebhooks(ctx); err != nil {
+		return err
+	}
+	m.callbackClient = ffresty.New(ctx, tmconfig.WebhookPrefix)
+	if err = m.initWebSocketServer(ctx); err != nil {
+		return err
+	}
+	m.policyDecisions = make(chan *apitypes.PolicyDecisionEvent, policyDecisionBufferSize)
 	m.apiServer, err = httpserver.NewHTTPServer(ctx, "api", m.router(), m.apiServerDone, tmconfig.APIConfig, tmconfig.CorsConfig)
 	if err != nil {
 		return err
@@ -157,48 +375,202 @@ func (m *manager) initServices(ctx context.Context) (err error) {
 	return nil
 }
 
+func (m *manager) initWebSocketServer(ctx context.Context) error {
+	policy := ws.BackpressurePolicy(config.GetString(tmconfig.EventStreamsWebSocketBackpressurePolicy))
+	switch policy {
+	case ws.BackpressureDisconnect, ws.BackpressureDropOldest, ws.BackpressureBlock:
+	default:
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidWebSocketBackpressure, policy)
+	}
+	var authorizer ws.Authorizer
+	if wsAuth, ok := m.apiAuth.(apiauth.WebSocketAuth); ok {
+		authorizer = wsAuth
+	}
+	m.wsServer = ws.NewWebSocketServer(ctx, authorizer, &ws.WebSocketServerConfig{
+		SendBufferSize:     config.GetInt(tmconfig.EventStreamsWebSocketSendBufferSize),
+		BackpressurePolicy: policy,
+		BlockTimeout:       config.GetDuration(tmconfig.EventStreamsWebSocketBlockTimeout),
+	})
+	return nil
+}
+
+func (m *manager) initPolicyEngines(ctx context.Context) (err error) {
+	if m.policyEngine, m.policyEngines, err = newPolicyEngines(ctx); err != nil {
+		return err
+	}
+	for _, nc := range m.connectors {
+		if nc.policyEngine, nc.policyEngines, err = newPolicyEngines(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newPolicyEngines creates the default and additional policy engines for a connector. Policy engines cache
+// what they query from the chain, such as the gas price, so each connector has its own instances.
+func newPolicyEngines(ctx context.Context) (policyengine.PolicyEngine, map[string]policyengine.PolicyEngine, error) {
+	defaultName := config.GetString(tmconfig.PolicyEngineName)
+	engines := make(map[string]policyengine.PolicyEngine)
+	for _, name := range append([]string{defaultName}, config.GetStringSlice(tmconfig.PolicyEngineAdditional)...) {
+		if _, exists := engines[name]; exists {
+			continue
+		}
+		pe, err := policyengines.NewPolicyEngine(ctx, tmconfig.PolicyEngineBaseConfig, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		engines[name] = pe
+	}
+	return engines[defaultName], engines, nil
+}
+
+// getPolicyEngine returns the named policy engine of a connector, or its default policy engine if no name is supplied
+func (m *manager) getPolicyEngine(ctx context.Context, connector, name string) (policyengine.PolicyEngine, error) {
+	defaultEngine, engines := m.policyEngine, m.policyEngines
+	if nc := m.connectors[connector]; nc != nil {
+		defaultEngine, engines = nc.policyEngine, nc.policyEngines
+	}
+	if name == "" {
+		return defaultEngine, nil
+	}
+	pe, ok := engines[name]
+	if !ok {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgPolicyEngineNotConfigured, name)
+	}
+	return pe, nil
+}
+
+func (m *manager) initNonceAllocator(ctx context.Context) (err error) {
+	name := config.GetString(tmconfig.NonceAllocatorName)
+	if name == localNonceAllocatorName {
+		m.nonceAllocator = newLocalNonceAllocator(m, "")
+		for _, nc := range m.connectors {
+			nc.nonceAllocator = newLocalNonceAllocator(m, nc.name)
+		}
+		return nil
+	}
+	if m.nonceAllocator, err = nonceallocators.NewNonceAllocator(ctx, tmconfig.NonceAllocatorBaseConfig, name, m.connector); err != nil {
+		return err
+	}
+	for _, nc := range m.connectors {
+		if nc.nonceAllocator, err = nonceallocators.NewNonceAllocator(ctx, tmconfig.NonceAllocatorBaseConfig, name, nc.connector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *manager) initPersistence(ctx context.Context) (err error) {
+	var p persistence.Persistence
 	pType := config.GetString(tmconfig.PersistenceType)
 	switch pType {
 	case "leveldb":
-		if m.persistence, err = persistence.NewLevelDBPersistence(ctx); err != nil {
-			return i18n.NewError(ctx, tmmsgs.MsgPersistenceInitFail, pType, err)
-		}
-		return nil
+		p, err = persistence.NewLevelDBPersistence(ctx)
+	case "sqlite":
+		p, err = persistence.NewSQLitePersistence(ctx)
 	default:
 		return i18n.NewError(ctx, tmmsgs.MsgUnknownPersistence, pType)
 	}
+	if err != nil {
+		return i18n.NewError(ctx, tmmsgs.MsgPersistenceInitFail, pType, err)
+	}
+	if m.persistence, err = persistence.WithFieldEncryption(ctx, p); err != nil {
+		p.Close(ctx)
+		return err
+	}
+	return nil
 }
 
 func (m *manager) Start() error {
+	if err := m.startBlockListeners(); err != nil {
+		return err
+	}
 	if err := m.restoreStreams(); err != nil {
 		return err
 	}
+	m.mux.Lock()
+	m.streamsRestored = true
+	m.mux.Unlock()
 
-	blReq := &ffcapi.NewBlockListenerRequest{ListenerContext: m.ctx, ID: fftypes.NewUUID()}
-	blReq.BlockListener, m.blockListenerDone = blocklistener.BufferChannel(m.ctx, m.confirmations)
-	_, _, err := m.connector.NewBlockListener(m.ctx, blReq)
-	if err != nil {
+	if m.leaderElection {
+		m.leaderElectionDone = make(chan struct{})
+		go m.leaderElectionLoop()
+	} else if err := m.startLeaderServices(); err != nil {
 		return err
 	}
 
 	go m.runAPIServer()
-	m.policyLoopDone = make(chan struct{})
-	m.markInflightStale()
-	go m.policyLoop()
-	go m.confirmations.Start()
+	m.policyDecisionsDone = make(chan struct{})
+	go m.policyDecisionLoop()
+	if m.healthCheckInterval > 0 {
+		m.healthCheckDone = make(chan struct{})
+		go m.connectorHealthCheckLoop()
+	}
+	if (m.reaperRetention > 0 || m.deletedRetention > 0) && m.reaperInterval > 0 {
+		m.reaperDone = make(chan struct{})
+		go m.reaperLoop()
+	}
+	if m.connectorInfoInterval > 0 {
+		// Startup continues if the connector is not available, as the info is queried again when needed
+		_, _ = m.refreshConnectorInfo(m.ctx)
+		m.connectorInfoDone = make(chan struct{})
+		go m.connectorInfoLoop()
+	}
 
 	m.started = true
 	return nil
 }
 
+// drain stops new transactions being accepted, and waits up to the configured timeout for the
+// policy loop to finish the action it is currently performing. The block listener and
+// confirmation manager continue to run, as the context is not cancelled until after the drain.
+func (m *manager) drain() {
+	m.leaderMux.Lock()
+	defer m.leaderMux.Unlock()
+	m.mux.Lock()
+	m.draining = true
+	leader := m.leader
+	m.mux.Unlock()
+	if !leader {
+		return
+	}
+
+	close(m.policyLoopDrain)
+	select {
+	case <-m.policyLoopDone:
+		log.L(m.ctx).Infof("Policy loop drained")
+	case <-time.After(m.drainTimeout):
+		log.L(m.ctx).Warnf("Policy loop did not drain within %s", m.drainTimeout)
+	}
+}
+
 func (m *manager) Close() {
+	if m.started {
+		m.drain()
+	}
 	m.cancelCtx()
+	m.callbacksActive.Wait()
 	if m.started {
 		m.started = false
 		<-m.apiServerDone
-		<-m.policyLoopDone
-		<-m.blockListenerDone
+		if m.leaderElectionDone != nil {
+			<-m.leaderElectionDone
+		} else {
+			<-m.policyLoopDone
+			<-m.blockListenerDone
+			m.stopNamedConnectors()
+		}
+		<-m.blockListenersDone
+		<-m.policyDecisionsDone
+		if m.healthCheckDone != nil {
+			<-m.healthCheckDone
+		}
+		if m.reaperDone != nil {
+			<-m.reaperDone
+		}
+		if m.connectorInfoDone != nil {
+			<-m.connectorInfoDone
+		}
 
 		streams := []events.Stream{}
 		m.mux.Lock()
@@ -211,4 +583,8 @@ func (m *manager) Close() {
 		}
 	}
 	m.persistence.Close(m.ctx)
+	if m.tracerProvider != nil {
+		// Flush any spans not yet exported - the manager context is already cancelled
+		_ = m.tracerProvider.Shutdown(context.Background())
+	}
 }
@@ -24,6 +24,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/httpserver"
@@ -44,6 +45,8 @@ const testManagerName = "unittest"
 
 func strPtr(s string) *string { return &s }
 
+func boolPtr(b bool) *bool { return &b }
+
 func testManagerCommonInit(t *testing.T) string {
 	InitConfig()
 	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
@@ -57,6 +60,9 @@ func testManagerCommonInit(t *testing.T) string {
 	tmconfig.APIConfig.Set(httpserver.HTTPConfAddress, "127.0.0.1")
 
 	config.Set(tmconfig.PolicyLoopInterval, "1ns")
+	config.Set(tmconfig.PolicyLoopBackoffInitDelay, "1ns")
+	config.Set(tmconfig.ConnectorInfoRefreshInterval, "0")
+	config.Set(tmconfig.TransactionsNonceGapCheckInterval, "0")
 	tmconfig.PolicyEngineBaseConfig.SubSection("simple").Set(simple.FixedGasPrice, "223344556677")
 
 	return fmt.Sprintf("http://127.0.0.1:%s", managerPort)
@@ -78,6 +84,7 @@ func newTestManager(t *testing.T) (string, *manager, func()) {
 	m := mm.(*manager)
 	mcm := &confirmationsmocks.Manager{}
 	mcm.On("Start").Return().Maybe()
+	mcm.On("TransactionProgress", mock.Anything).Return(nil).Maybe()
 	m.confirmations = mcm
 
 	return url,
@@ -137,6 +144,53 @@ func TestNewManagerBadLevelDBConfig(t *testing.T) {
 
 }
 
+func TestNewManagerSQLite(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceType, "sqlite")
+	config.Set(tmconfig.PersistenceSQLitePath, persistence.SQLiteInMemory)
+	tmconfig.APIConfig.Set(httpserver.HTTPConfPort, "0")
+
+	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
+	tmconfig.PolicyEngineBaseConfig.SubSection("simple").Set(simple.FixedGasPrice, "223344556677")
+
+	mm, err := NewManager(context.Background(), &ffcapimocks.API{})
+	assert.NoError(t, err)
+	m := mm.(*manager)
+	defer m.persistence.Close(context.Background())
+
+}
+
+func TestNewManagerBadEncryptionConfig(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceType, "sqlite")
+	config.Set(tmconfig.PersistenceSQLitePath, persistence.SQLiteInMemory)
+	config.Set(tmconfig.PersistenceEncryptionKeyFile, "/does/not/exist")
+	tmconfig.APIConfig.Set(httpserver.HTTPConfPort, "0")
+
+	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
+	tmconfig.PolicyEngineBaseConfig.SubSection("simple").Set(simple.FixedGasPrice, "223344556677")
+
+	_, err := NewManager(context.Background(), &ffcapimocks.API{})
+	assert.Regexp(t, "FF21159", err)
+
+}
+
+func TestNewManagerBadSQLiteConfig(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceType, "sqlite")
+	tmconfig.APIConfig.Set(httpserver.HTTPConfPort, "0")
+
+	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
+	tmconfig.PolicyEngineBaseConfig.SubSection("simple").Set(simple.FixedGasPrice, "223344556677")
+
+	_, err := NewManager(context.Background(), nil)
+	assert.Regexp(t, "FF21049.*FF21074", err)
+
+}
+
 func TestNewManagerBadPersistenceConfig(t *testing.T) {
 
 	tmconfig.Reset()
@@ -151,6 +205,20 @@ func TestNewManagerBadPersistenceConfig(t *testing.T) {
 
 }
 
+func TestNewManagerBadAdditionalPolicyEngine(t *testing.T) {
+
+	tmconfig.Reset()
+	tmconfig.APIConfig.Set(httpserver.HTTPConfPort, "0")
+	config.Set(tmconfig.PolicyEngineAdditional, []string{"simple", "wrong"})
+
+	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
+	tmconfig.PolicyEngineBaseConfig.SubSection("simple").Set(simple.FixedGasPrice, "223344556677")
+
+	_, err := NewManager(context.Background(), nil)
+	assert.Regexp(t, "FF21019.*wrong", err)
+
+}
+
 func TestNewManagerBadPolicyEngine(t *testing.T) {
 
 	tmconfig.Reset()
@@ -161,6 +229,32 @@ func TestNewManagerBadPolicyEngine(t *testing.T) {
 
 }
 
+func TestNewManagerBadNonceAllocator(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.NonceAllocatorName, "wrong")
+
+	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
+	tmconfig.PolicyEngineBaseConfig.SubSection("simple").Set(simple.FixedGasPrice, "223344556677")
+
+	_, err := NewManager(context.Background(), nil)
+	assert.Regexp(t, "FF21070", err)
+
+}
+
+func TestNewManagerBadWebSocketBackpressure(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.EventStreamsWebSocketBackpressurePolicy, "wrong")
+
+	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
+	tmconfig.PolicyEngineBaseConfig.SubSection("simple").Set(simple.FixedGasPrice, "223344556677")
+
+	_, err := NewManager(context.Background(), nil)
+	assert.Regexp(t, "FF21201.*wrong", err)
+
+}
+
 func TestAddErrorMessageMax(t *testing.T) {
 
 	_, m, close := newTestManagerMockPersistence(t)
@@ -195,6 +289,7 @@ func TestStartBlockListenerFail(t *testing.T) {
 
 	mp := m.persistence.(*persistencemocks.Persistence)
 	mp.On("ListStreams", mock.Anything, mock.Anything, startupPaginationLimit, persistence.SortDirectionAscending).Return(nil, nil)
+	mp.On("GetSubmissionControl", mock.Anything).Return(nil, nil)
 
 	mca := m.connector.(*ffcapimocks.API)
 	mca.On("NewBlockListener", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
@@ -203,3 +298,83 @@ func TestStartBlockListenerFail(t *testing.T) {
 	assert.Regexp(t, "pop", err)
 
 }
+
+func TestCloseDrainsInflightSubmission(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	sendSampleTX(t, m, "0xaaaaa", 12345)
+
+	sendStarted := make(chan struct{})
+	sendRelease := make(chan struct{})
+	var sendCtxErr error
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		close(sendStarted)
+		<-sendRelease
+		sendCtxErr = args[0].(context.Context).Err()
+	}).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x106215b9c0c9372e3f541beff0cdc3cd061a26f69f3808e28fd139a1abc9d345",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+	<-sendStarted
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		m.Close()
+	}()
+
+	// New transactions are rejected as soon as we start draining
+	for {
+		m.mux.Lock()
+		draining := m.draining
+		m.mux.Unlock()
+		if draining {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	_, err = m.submitPreparedTX(context.Background(), &apitypes.RequestHeaders{}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, nil, "")
+	assert.Regexp(t, "FF21079", err)
+
+	// The submission in progress is allowed to complete
+	close(sendRelease)
+	<-closed
+	assert.NoError(t, sendCtxErr)
+	assert.Equal(t, "0x106215b9c0c9372e3f541beff0cdc3cd061a26f69f3808e28fd139a1abc9d345", m.inflight[0].mtx.TransactionHash)
+
+	mfc.AssertExpectations(t)
+	mc.AssertExpectations(t)
+}
+
+func TestCloseDrainTimeout(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.drainTimeout = 1 * time.Millisecond
+
+	sendSampleTX(t, m, "0xaaaaa", 12345)
+
+	sendStarted := make(chan struct{})
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		close(sendStarted)
+		// Only the cancellation of the context after the drain timeout releases us
+		<-args[0].(context.Context).Done()
+	}).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	err := m.Start()
+	assert.NoError(t, err)
+	<-sendStarted
+
+	m.Close()
+
+	mfc.AssertExpectations(t)
+}
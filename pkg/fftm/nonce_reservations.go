@@ -0,0 +1,185 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type nonceReservation struct {
+	apitypes.NonceReservation
+	consuming bool // a submission using the reservation is in progress, so it cannot expire
+}
+
+// reserveNonce allocates the next nonce for a signer, for a transaction that will be signed outside of FFTM.
+// The nonce is allocated under the nonce lock in the same way as for a transaction, and then held by the
+// reservation rather than by a persisted transaction.
+func (m *manager) reserveNonce(ctx context.Context, signer string, req *apitypes.NonceReservationRequest) (*apitypes.NonceReservation, error) {
+	if err := m.checkLeader(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.checkSignerAllowed(ctx, signer); err != nil {
+		return nil, err
+	}
+	ttl := m.nonceReservationTTL
+	if req != nil && req.TTL != nil {
+		if *req.TTL <= 0 {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidNonceReservationTTL, req.TTL)
+		}
+		ttl = time.Duration(*req.TTL)
+	}
+
+	id := fftypes.NewUUID()
+	locked, err := m.assignAndLockNonce(ctx, id.String(), "", signer)
+	if err != nil {
+		return nil, err
+	}
+	defer locked.complete(ctx)
+
+	now := time.Now()
+	created, expiry := fftypes.FFTime(now), fftypes.FFTime(now.Add(ttl))
+	res := &nonceReservation{
+		NonceReservation: apitypes.NonceReservation{
+			ID:      id,
+			Signer:  signer,
+			Nonce:   fftypes.NewFFBigInt(int64(locked.nonce)),
+			Created: &created,
+			Expiry:  &expiry,
+		},
+	}
+	m.mux.Lock()
+	m.nonceReservations[*id] = res
+	m.mux.Unlock()
+	log.L(ctx).Infof("Reserved nonce %d for signer %s until %s (reservation=%s)", locked.nonce, signer, res.Expiry, id)
+	r := res.NonceReservation
+	return &r, nil
+}
+
+// expireNonceReservations must be called holding the manager mutex. The nonce of each expired reservation is
+// reclaimed, to be assigned to the next transaction for the signer - as otherwise it would be a permanent gap
+// in the nonces of the signer if later nonces have been assigned.
+func (m *manager) expireNonceReservations(ctx context.Context) {
+	now := time.Now()
+	for id, res := range m.nonceReservations {
+		if !res.consuming && now.After(*res.Expiry.Time()) {
+			log.L(ctx).Warnf("Nonce reservation %s for nonce %s of signer %s expired unused", id, res.Nonce, res.Signer)
+			delete(m.nonceReservations, id)
+			reclaimed := append(m.reclaimedNonces[res.Signer], res.Nonce.Uint64())
+			sort.Slice(reclaimed, func(i, j int) bool { return reclaimed[i] < reclaimed[j] })
+			m.reclaimedNonces[res.Signer] = reclaimed
+		}
+	}
+}
+
+// nonceReserved must be called holding the manager mutex
+func (m *manager) nonceReserved(signer string, nonce uint64) bool {
+	for _, res := range m.nonceReservations {
+		if res.Signer == signer && res.Nonce.Uint64() == nonce {
+			return true
+		}
+	}
+	return false
+}
+
+// takeReclaimedNonce returns the lowest reclaimed nonce for the signer that is still unused, and must be called
+// holding the nonce lock of the signer. A reclaimed nonce is discarded if a transaction has since been recorded
+// with it, or if the blockchain is already past it - as the signed transaction might have been submitted directly.
+func (m *manager) takeReclaimedNonce(ctx context.Context, signer string) (uint64, bool, error) {
+	for {
+		m.mux.Lock()
+		m.expireNonceReservations(ctx)
+		reclaimed := m.reclaimedNonces[signer]
+		if len(reclaimed) == 0 {
+			delete(m.reclaimedNonces, signer)
+			m.mux.Unlock()
+			return 0, false, nil
+		}
+		nonce := reclaimed[0]
+		m.mux.Unlock()
+
+		used, err := m.nonceUsed(ctx, signer, nonce)
+		if err != nil {
+			return 0, false, err
+		}
+		m.mux.Lock()
+		m.reclaimedNonces[signer] = m.reclaimedNonces[signer][1:]
+		m.mux.Unlock()
+		if !used {
+			log.L(ctx).Infof("Assigning reclaimed nonce %d for signer %s", nonce, signer)
+			return nonce, true, nil
+		}
+		log.L(ctx).Infof("Discarding reclaimed nonce %d for signer %s, as it has been used", nonce, signer)
+	}
+}
+
+func (m *manager) nonceUsed(ctx context.Context, signer string, nonce uint64) (bool, error) {
+	after := (*fftypes.FFBigInt)(new(big.Int).SetUint64(nonce + 1))
+	txns, err := m.persistence.ListTransactionsByNonce(ctx, signer, after, 1, persistence.SortDirectionDescending)
+	if err != nil {
+		return false, err
+	}
+	if len(txns) > 0 && txns[0].Nonce.Uint64() == nonce {
+		return true, nil
+	}
+	chainNonce, _, err := m.connector.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: signer})
+	if err != nil {
+		return false, err
+	}
+	return chainNonce.Nonce.Uint64() > nonce, nil
+}
+
+// startConsumingNonceReservation checks a raw transaction matches the reservation it was signed with, filling
+// in the nonce if it was not supplied, and stops the reservation expiring while the submission is in progress.
+// The returned function must be called with the outcome of the submission - the reservation is removed if it
+// succeeded, and otherwise remains in place until it expires.
+func (m *manager) startConsumingNonceReservation(ctx context.Context, request *apitypes.RawTransactionRequest) (func(succeeded bool), error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.expireNonceReservations(ctx)
+	id := *request.ReservationID
+	res := m.nonceReservations[id]
+	switch {
+	case res == nil:
+		return nil, i18n.NewError(ctx, tmmsgs.MsgNonceReservationNotFound, request.ReservationID)
+	case res.Signer != request.From || (request.Nonce != nil && !request.Nonce.Equals(res.Nonce)):
+		return nil, i18n.NewError(ctx, tmmsgs.MsgNonceReservationMismatch, request.ReservationID, res.Nonce, res.Signer)
+	case res.consuming:
+		return nil, i18n.NewError(ctx, tmmsgs.MsgNonceReservationInUse, request.ReservationID)
+	}
+	res.consuming = true
+	request.Nonce = res.Nonce
+	return func(succeeded bool) {
+		m.mux.Lock()
+		defer m.mux.Unlock()
+		res.consuming = false
+		if succeeded {
+			log.L(ctx).Infof("Nonce reservation %s consumed by transaction with nonce %s for signer %s", id, res.Nonce, res.Signer)
+			delete(m.nonceReservations, id)
+		}
+	}, nil
+}
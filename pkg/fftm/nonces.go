@@ -18,21 +18,37 @@ package fftm
 
 import (
 	"context"
+	"math/big"
+	"sort"
 	"time"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
+const localNonceAllocatorName = "local"
+
+// nonceRefreshLockID identifies a nonce refresh as the holder of a nonce lock
+const nonceRefreshLockID = "nonce-refresh"
+
 type lockedNonce struct {
-	m        *manager
-	nsOpID   string
-	signer   string
-	unlocked chan struct{}
-	nonce    uint64
-	spent    *apitypes.ManagedTX
+	m         *manager
+	nsOpID    string
+	connector string // empty for the default connector
+	signer    string
+	lockedAt  *fftypes.FFTime
+	unlocked  chan struct{}
+	released  bool
+	assigned  bool
+	nonce     uint64
+	spent     *apitypes.ManagedTX
 }
 
 // complete must be called for any lockedNonce returned from a successful assignAndLockNonce call
@@ -43,62 +59,279 @@ func (ln *lockedNonce) complete(ctx context.Context) {
 		log.L(ctx).Debugf("Returning next nonce %d for signer %s unspent", ln.nonce, ln.signer)
 	}
 	ln.m.mux.Lock()
-	delete(ln.m.lockedNonces, ln.signer)
-	close(ln.unlocked)
+	ln.release()
 	ln.m.mux.Unlock()
 }
 
-func (m *manager) assignAndLockNonce(ctx context.Context, nsOpID, signer string) (*lockedNonce, error) {
+// release must be called holding the manager mutex. It is safe to call more than once, as a lock can
+// be cleared by an administrative reset before the routine holding it completes.
+func (ln *lockedNonce) release() {
+	key := apitypes.NonceKey(ln.connector, ln.signer)
+	if ln.m.lockedNonces[key] == ln {
+		delete(ln.m.lockedNonces, key)
+	}
+	if !ln.released {
+		ln.released = true
+		close(ln.unlocked)
+	}
+}
+
+// lockNonce waits until no other routine holds the nonce lock for the signer on the connector, and then takes it.
+// Each chain has its own nonces, so the same signer is locked independently on each connector.
+func (m *manager) lockNonce(ctx context.Context, nsOpID, connector, signer string) *lockedNonce {
 
+	key := apitypes.NonceKey(connector, signer)
 	for {
 		// Take the lock to query our nonce cache, and check if we are already locked
 		m.mux.Lock()
-		doLookup := false
-		locked, isLocked := m.lockedNonces[signer]
+		locked, isLocked := m.lockedNonces[key]
 		if !isLocked {
 			locked = &lockedNonce{
-				m:        m,
-				nsOpID:   nsOpID,
-				signer:   signer,
-				unlocked: make(chan struct{}),
+				m:         m,
+				nsOpID:    nsOpID,
+				connector: connector,
+				signer:    signer,
+				lockedAt:  fftypes.Now(),
+				unlocked:  make(chan struct{}),
 			}
-			m.lockedNonces[signer] = locked
-			doLookup = true
+			m.lockedNonces[key] = locked
+			m.mux.Unlock()
+			return locked
 		}
 		m.mux.Unlock()
 
-		// If we're locked, then wait
-		if isLocked {
-			log.L(ctx).Debugf("Contention for next nonce for signer %s", signer)
-			<-locked.unlocked
-		} else if doLookup {
-			// We have to ensure we either successfully return a nonce,
-			// or otherwise we unlock when we send the error
-			nextNonce, err := m.calcNextNonce(ctx, signer)
-			if err != nil {
-				locked.complete(ctx)
-				return nil, err
-			}
-			locked.nonce = nextNonce
-			return locked, nil
+		// We're locked, so wait
+		log.L(ctx).Debugf("Contention for next nonce for signer %s", key)
+		<-locked.unlocked
+	}
+
+}
+
+func (m *manager) assignAndLockNonce(ctx context.Context, nsOpID, connector, signer string) (*lockedNonce, error) {
+
+	// We have to ensure we either successfully return a nonce,
+	// or otherwise we unlock when we send the error
+	locked := m.lockNonce(ctx, nsOpID, connector, signer)
+	key := apitypes.NonceKey(connector, signer)
+
+	// The nonce of an expired reservation is assigned first, so it does not leave a gap.
+	// Reservations are only made on the default connector, where the nonce key is the signer.
+	reclaimed, ok, err := m.takeReclaimedNonce(ctx, key)
+	if err != nil {
+		locked.complete(ctx)
+		return nil, err
+	}
+	if ok {
+		m.mux.Lock()
+		locked.nonce = reclaimed
+		locked.assigned = true
+		m.mux.Unlock()
+		return locked, nil
+	}
+
+	nextNonce, err := m.nonceAllocatorFor(connector).NextNonce(ctx, signer)
+	if err != nil {
+		locked.complete(ctx)
+		return nil, err
+	}
+	m.mux.Lock()
+	if chainNonce, realign := m.nonceRealignments[key]; realign {
+		log.L(ctx).Infof("Realigning next nonce for signer %s from %d to %d after reset", key, nextNonce, chainNonce)
+		nextNonce = chainNonce
+		delete(m.nonceRealignments, key)
+	}
+	// Reserved nonces are not recorded in persistence, so the allocator does not know to skip them
+	for m.nonceReserved(key, nextNonce) {
+		nextNonce++
+	}
+	locked.nonce = nextNonce
+	locked.assigned = true
+	m.mux.Unlock()
+	return locked, nil
+
+}
+
+// lockExternalNonce takes the nonce lock for a signer, for a transaction signed outside of FFTM with its own nonce.
+// Holding the lock means no locally managed transaction can be assigned a nonce while we check for a collision, and
+// write the transaction. As the local allocator assigns nonces after the highest we have recorded, later local
+// transactions are assigned nonces after the external one. A nonce that is already used by a transaction we are
+// tracking is rejected, as only one of the two could ever be mined.
+func (m *manager) lockExternalNonce(ctx context.Context, nsOpID, connector, signer string, nonce *fftypes.FFBigInt) (*lockedNonce, error) {
+
+	locked := m.lockNonce(ctx, nsOpID, connector, signer)
+	after := (*fftypes.FFBigInt)(new(big.Int).Add(nonce.Int(), big.NewInt(1)))
+	txns, err := m.persistence.ListTransactionsByNonce(ctx, apitypes.NonceKey(connector, signer), after, 1, persistence.SortDirectionDescending)
+	if err == nil && len(txns) > 0 && txns[0].Nonce.Equals(nonce) {
+		err = i18n.NewError(ctx, tmmsgs.MsgRawTransactionNonceConflict, nonce, signer, txns[0].ID)
+	}
+	if err != nil {
+		locked.complete(ctx)
+		return nil, err
+	}
+	m.mux.Lock()
+	locked.nonce = nonce.Uint64()
+	locked.assigned = true
+	m.mux.Unlock()
+	return locked, nil
+
+}
+
+// getNonceStatus returns the status of every signer that currently holds a nonce lock, along with
+// the next nonce the blockchain reports for the signer
+func (m *manager) getNonceStatus(ctx context.Context) ([]*apitypes.NonceStatus, error) {
+	m.mux.Lock()
+	statuses := make([]*apitypes.NonceStatus, 0, len(m.lockedNonces))
+	for _, ln := range m.lockedNonces {
+		statuses = append(statuses, m.lockedNonceStatus(ln))
+	}
+	m.mux.Unlock()
+	sort.Slice(statuses, func(i, j int) bool {
+		return apitypes.NonceKey(statuses[i].Connector, statuses[i].Signer) < apitypes.NonceKey(statuses[j].Connector, statuses[j].Signer)
+	})
+
+	for _, status := range statuses {
+		chainNonce, _, err := m.connectorFor(status.Connector).NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: status.Signer})
+		if err != nil {
+			// We still return the local state, which is the most useful part when debugging
+			log.L(ctx).Warnf("Failed to query next nonce for signer %s: %s", status.Signer, err)
+			continue
 		}
+		status.ChainNextNonce = chainNonce.Nonce
 	}
+	for _, status := range statuses {
+		pending, err := m.pendingCount(ctx, apitypes.NonceKey(status.Connector, status.Signer), 0)
+		if err != nil {
+			return nil, err
+		}
+		status.Pending = pending
+	}
+	return statuses, nil
+}
 
+// must be called holding the manager mutex
+func (m *manager) lockedNonceStatus(ln *lockedNonce) *apitypes.NonceStatus {
+	status := &apitypes.NonceStatus{
+		Signer:    ln.signer,
+		Connector: ln.connector,
+		Locked:    true,
+		LockedBy:  ln.nsOpID,
+		LockedAt:  ln.lockedAt,
+	}
+	if ln.assigned {
+		status.LockedNonce = fftypes.NewFFBigInt(int64(ln.nonce))
+	}
+	return status
 }
 
-func (m *manager) calcNextNonce(ctx context.Context, signer string) (uint64, error) {
+// resetNonce clears any nonce lock held for the signer on the connector, releasing routines waiting on it, and
+// realigns the next nonce allocated for the signer with the next nonce reported by the blockchain. Nothing is
+// changed if the blockchain cannot be queried.
+func (m *manager) resetNonce(ctx context.Context, connector, signer string) (*apitypes.NonceStatus, error) {
+	if err := m.checkLeader(ctx); err != nil {
+		return nil, err
+	}
+	api, err := m.getConnector(ctx, connector)
+	if err != nil {
+		return nil, err
+	}
+	connector = m.txConnectorName(connector)
+	chainNonce, _, err := api.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: signer})
+	if err != nil {
+		return nil, err
+	}
+
+	key := apitypes.NonceKey(connector, signer)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if ln := m.lockedNonces[key]; ln != nil {
+		log.L(ctx).Warnf("Clearing nonce lock for signer %s held by %s since %s", key, ln.nsOpID, ln.lockedAt)
+		ln.release()
+	}
+	m.nonceRealignments[key] = chainNonce.Nonce.Uint64()
+	return &apitypes.NonceStatus{
+		Signer:         signer,
+		Connector:      connector,
+		ChainNextNonce: chainNonce.Nonce,
+	}, nil
+}
+
+// refreshNonce re-reads the next nonce for the signer from the blockchain, and moves the next nonce allocated
+// for the signer forwards to it when transactions sent outside of FFTM have used the nonces we would assign.
+// Unlike a reset, the nonce lock for the signer is taken in the same way as for a transaction, so any assignment
+// in progress completes first. The next nonce is never moved backwards, as the chain does not include our
+// transactions that are not yet mined.
+func (m *manager) refreshNonce(ctx context.Context, connector, signer string) (*apitypes.NonceRefresh, error) {
+	if err := m.checkLeader(ctx); err != nil {
+		return nil, err
+	}
+	api, err := m.getConnector(ctx, connector)
+	if err != nil {
+		return nil, err
+	}
+	connector = m.txConnectorName(connector)
+	locked := m.lockNonce(ctx, nonceRefreshLockID, connector, signer)
+	defer locked.complete(ctx)
+
+	oldNextNonce, err := m.nonceAllocatorFor(connector).NextNonce(ctx, signer)
+	if err != nil {
+		return nil, err
+	}
+	chainNonce, _, err := api.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: signer})
+	if err != nil {
+		return nil, err
+	}
+
+	key := apitypes.NonceKey(connector, signer)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if realigned, ok := m.nonceRealignments[key]; ok {
+		oldNextNonce = realigned
+	}
+	newNextNonce := oldNextNonce
+	if chainNextNonce := chainNonce.Nonce.Uint64(); chainNextNonce > oldNextNonce {
+		log.L(ctx).Infof("Refreshing next nonce for signer %s from %d to %d reported by the blockchain", key, oldNextNonce, chainNextNonce)
+		newNextNonce = chainNextNonce
+		m.nonceRealignments[key] = newNextNonce
+	}
+	return &apitypes.NonceRefresh{
+		Signer:         signer,
+		Connector:      connector,
+		OldNextNonce:   fftypes.NewFFBigInt(int64(oldNextNonce)),
+		NewNextNonce:   fftypes.NewFFBigInt(int64(newNextNonce)),
+		ChainNextNonce: chainNonce.Nonce,
+	}, nil
+}
+
+// localNonceAllocator is the default nonce allocator, which assigns nonces from the most recent
+// transaction in our local state store - only querying the node when that state is missing or stale.
+// There is one for each connector, as each chain has its own nonces.
+type localNonceAllocator struct {
+	m                 *manager
+	connector         string // empty for the default connector
+	nonceStateTimeout time.Duration
+}
+
+func newLocalNonceAllocator(m *manager, connector string) *localNonceAllocator {
+	return &localNonceAllocator{
+		m:                 m,
+		connector:         connector,
+		nonceStateTimeout: config.GetDuration(tmconfig.TransactionsNonceStateTimeout),
+	}
+}
+
+func (na *localNonceAllocator) NextNonce(ctx context.Context, signer string) (uint64, error) {
 
 	// First we check our DB to find the last nonce we used for this address.
 	// Note we are within the nonce-lock in assignAndLockNonce for this signer, so we can be sure we're the
 	// only routine attempting this right now.
 	var lastTxn *apitypes.ManagedTX
-	txns, err := m.persistence.ListTransactionsByNonce(ctx, signer, nil, 1, persistence.SortDirectionDescending)
+	txns, err := na.m.persistence.ListTransactionsByNonce(ctx, apitypes.NonceKey(na.connector, signer), nil, 1, persistence.SortDirectionDescending)
 	if err != nil {
 		return 0, err
 	}
 	if len(txns) > 0 {
 		lastTxn = txns[0]
-		if time.Since(*lastTxn.Created.Time()) < m.nonceStateTimeout {
+		if time.Since(*lastTxn.Created.Time()) < na.nonceStateTimeout {
 			nextNonce := lastTxn.Nonce.Uint64() + 1
 			log.L(ctx).Debugf("Allocating next nonce '%s' / '%d' after TX '%s' (status=%s)", signer, nextNonce, lastTxn.ID, lastTxn.Status)
 			return nextNonce, nil
@@ -106,7 +339,7 @@ func (m *manager) calcNextNonce(ctx context.Context, signer string) (uint64, err
 	}
 
 	// If we don't have a fresh answer in our state store, then ask the node.
-	nextNonceRes, _, err := m.connector.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{
+	nextNonceRes, _, err := na.m.connectorFor(na.connector).NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{
 		Signer: signer,
 	})
 	if err != nil {
@@ -125,3 +358,124 @@ func (m *manager) calcNextNonce(ctx context.Context, signer string) (uint64, err
 	return nextNonce, nil
 
 }
+
+// checkNonceGaps looks for nonces that are neither in-flight nor mined, for each signer with in-flight transactions.
+// The node will not mine any transaction from the signer at a higher nonce until the gap is filled, so the signer
+// silently stops making progress. Must be called from the policy loop, as it reads the in-flight set.
+func (m *manager) checkNonceGaps(ctx context.Context) {
+	inflightNonces := make(map[string]map[uint64]bool)
+	signerTXs := make(map[string]*apitypes.ManagedTX)
+	for _, pending := range m.inflight {
+		if pending.remove || pending.mtx.Nonce == nil {
+			continue
+		}
+		signer := pending.mtx.NonceKey()
+		if inflightNonces[signer] == nil {
+			inflightNonces[signer] = make(map[uint64]bool)
+			signerTXs[signer] = pending.mtx
+		}
+		inflightNonces[signer][pending.mtx.Nonce.Uint64()] = true
+	}
+	// When the in-flight set is full, pending transactions at higher nonces can be waiting outside of it
+	inflightFull := len(m.inflight) >= m.maxInFlight
+
+	gaps := make(map[string]*apitypes.NonceGap)
+	for signer, nonces := range inflightNonces {
+		gap, err := m.findNonceGap(ctx, signerTXs[signer].Connector, signerTXs[signer].TransactionHeaders.From, nonces, inflightFull)
+		if err != nil {
+			// Keep what we knew before, until we can check again
+			log.L(ctx).Warnf("Failed to check for a nonce gap for signer %s: %s", signer, err)
+			m.mux.Lock()
+			gap = m.nonceGaps[signer]
+			m.mux.Unlock()
+		}
+		if gap != nil {
+			gaps[signer] = gap
+		}
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for signer, gap := range gaps {
+		if previous := m.nonceGaps[signer]; previous != nil && previous.MissingNonce.Equals(gap.MissingNonce) {
+			gap.Detected = previous.Detected
+		} else {
+			log.L(ctx).Warnf("Nonce gap detected for signer %s: nonce %s is not in-flight or mined (missing=%d chainNextNonce=%s highestNonce=%s)", signer, gap.MissingNonce, gap.MissingCount, gap.ChainNextNonce, gap.HighestNonce)
+		}
+	}
+	for signer := range m.nonceGaps {
+		if gaps[signer] == nil {
+			log.L(ctx).Infof("Nonce gap cleared for signer %s", signer)
+		}
+	}
+	m.nonceGaps = gaps
+}
+
+// findNonceGap compares the nonces between the next nonce reported by the blockchain, and the highest nonce
+// assigned locally, with the in-flight nonces of the signer on the connector - returning nil if none are missing
+func (m *manager) findNonceGap(ctx context.Context, connector, signer string, inflightNonces map[uint64]bool, inflightFull bool) (*apitypes.NonceGap, error) {
+	var highest uint64
+	for nonce := range inflightNonces {
+		if nonce > highest {
+			highest = nonce
+		}
+	}
+	if !inflightFull {
+		txns, err := m.persistence.ListTransactionsByNonce(ctx, apitypes.NonceKey(connector, signer), nil, 1, persistence.SortDirectionDescending)
+		if err != nil {
+			return nil, err
+		}
+		if len(txns) > 0 && txns[0].Nonce.Uint64() > highest {
+			highest = txns[0].Nonce.Uint64()
+		}
+	}
+	chainNonce, _, err := m.connectorFor(connector).NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: signer})
+	if err != nil {
+		return nil, err
+	}
+	chainNext := chainNonce.Nonce.Uint64()
+	if chainNext > highest {
+		return nil, nil
+	}
+
+	missing := int(highest - chainNext + 1)
+	for nonce := range inflightNonces {
+		if nonce >= chainNext {
+			missing--
+		}
+	}
+	if missing == 0 {
+		return nil, nil
+	}
+	lowestMissing := chainNext
+	for inflightNonces[lowestMissing] {
+		lowestMissing++
+	}
+	return &apitypes.NonceGap{
+		Signer:         signer,
+		Connector:      connector,
+		MissingNonce:   fftypes.NewFFBigInt(int64(lowestMissing)),
+		MissingCount:   missing,
+		ChainNextNonce: chainNonce.Nonce,
+		HighestNonce:   fftypes.NewFFBigInt(int64(highest)),
+		Detected:       fftypes.Now(),
+	}, nil
+}
+
+// sortedNonceGaps must be called holding the manager mutex
+func (m *manager) sortedNonceGaps() []*apitypes.NonceGap {
+	gaps := make([]*apitypes.NonceGap, 0, len(m.nonceGaps))
+	for _, gap := range m.nonceGaps {
+		gaps = append(gaps, gap)
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		return apitypes.NonceKey(gaps[i].Connector, gaps[i].Signer) < apitypes.NonceKey(gaps[j].Connector, gaps[j].Signer)
+	})
+	return gaps
+}
+
+func (m *manager) getNonceGaps() []*apitypes.NonceGap {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.sortedNonceGaps()
+}
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
@@ -65,7 +66,7 @@ func TestNonceStaleStateContention(t *testing.T) {
 	go func() {
 		defer close(done1)
 
-		ln, err := m.assignAndLockNonce(context.Background(), "ns1:"+fftypes.NewUUID().String(), "0x12345")
+		ln, err := m.assignAndLockNonce(context.Background(), "ns1:"+fftypes.NewUUID().String(), "", "0x12345")
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(1111), ln.nonce)
 		close(locked1)
@@ -90,7 +91,7 @@ func TestNonceStaleStateContention(t *testing.T) {
 		defer close(done2)
 
 		<-locked1
-		ln, err := m.assignAndLockNonce(context.Background(), "ns2:"+fftypes.NewUUID().String(), "0x12345")
+		ln, err := m.assignAndLockNonce(context.Background(), "ns2:"+fftypes.NewUUID().String(), "", "0x12345")
 		assert.NoError(t, err)
 
 		assert.Equal(t, uint64(1112), ln.nonce)
@@ -170,7 +171,8 @@ func TestNonceListNotStale(t *testing.T) {
 
 	_, m, close := newTestManagerMockPersistence(t)
 	defer close()
-	m.nonceStateTimeout = 1 * time.Hour
+	na := m.nonceAllocator.(*localNonceAllocator)
+	na.nonceStateTimeout = 1 * time.Hour
 
 	mp := m.persistence.(*persistencemocks.Persistence)
 
@@ -179,8 +181,182 @@ func TestNonceListNotStale(t *testing.T) {
 			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
 		}, nil)
 
-	n, err := m.calcNextNonce(context.Background(), "0x12345")
+	n, err := na.NextNonce(context.Background(), "0x12345")
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(1001), n)
 
 }
+
+type stubNonceAllocator struct {
+	nonces map[string]uint64
+}
+
+func (na *stubNonceAllocator) NextNonce(ctx context.Context, signer string) (uint64, error) {
+	n, ok := na.nonces[signer]
+	if !ok {
+		return 0, fmt.Errorf("pop")
+	}
+	return n, nil
+}
+
+func TestNonceStubAllocator(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.nonceAllocator = &stubNonceAllocator{
+		nonces: map[string]uint64{"0x12345": 42},
+	}
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+
+	mtx, err := m.sendManagedTransaction(context.Background(), &apitypes.TransactionRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: "0x12345",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), mtx.Nonce.Int64())
+
+	_, err = m.sendManagedTransaction(context.Background(), &apitypes.TransactionRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: "0x67890",
+			},
+		},
+	})
+	assert.Regexp(t, "pop", err)
+
+	// The node is never queried, as the allocator is responsible
+	mFFC.AssertNotCalled(t, "NextNonceForSigner", mock.Anything, mock.Anything)
+	mFFC.AssertExpectations(t)
+
+}
+
+func newTestNonceGapTxn(t *testing.T, m *manager, signer string, nonce int64, status apitypes.TxStatus) *pendingState {
+	return &pendingState{mtx: newTestTxn(t, m, signer, nonce, status)}
+}
+
+func TestNonceGapDetectedAndCleared(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	noopPolicyEngine(m)
+	m.nonceGapCheckInterval = 1 * time.Nanosecond
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("HighestBlockSeen").Return(uint64(0))
+
+	// Nonce 11 timed out without being mined, so 12 can never be mined
+	tx10 := newTestNonceGapTxn(t, m, "0xaaaaa", 10, apitypes.TxStatusPending)
+	tx11 := newTestNonceGapTxn(t, m, "0xaaaaa", 11, apitypes.TxStatusFailed)
+	tx12 := newTestNonceGapTxn(t, m, "0xaaaaa", 12, apitypes.TxStatusPending)
+	txB5 := newTestNonceGapTxn(t, m, "0xbbbbb", 5, apitypes.TxStatusPending)
+	m.inflight = []*pendingState{tx10, tx12, txB5}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(10),
+	}, ffcapi.ErrorReason(""), nil)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xbbbbb"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(5),
+	}, ffcapi.ErrorReason(""), nil)
+
+	m.policyLoopCycle(m.ctx, false)
+	gaps := m.readiness().NonceGaps
+	assert.Len(t, gaps, 1)
+	assert.Equal(t, "0xaaaaa", gaps[0].Signer)
+	assert.Equal(t, int64(11), gaps[0].MissingNonce.Int64())
+	assert.Equal(t, 1, gaps[0].MissingCount)
+	assert.Equal(t, int64(10), gaps[0].ChainNextNonce.Int64())
+	assert.Equal(t, int64(12), gaps[0].HighestNonce.Int64())
+	detected := gaps[0].Detected
+
+	// The gap is still reported from when it was first detected
+	time.Sleep(1 * time.Millisecond)
+	m.policyLoopCycle(m.ctx, false)
+	gaps = m.getNonceGaps()
+	assert.Len(t, gaps, 1)
+	assert.Equal(t, detected, gaps[0].Detected)
+
+	// Once the nonce is back in-flight, the gap is cleared
+	m.inflight = []*pendingState{tx10, tx11, tx12, txB5}
+	time.Sleep(1 * time.Millisecond)
+	m.policyLoopCycle(m.ctx, false)
+	assert.Empty(t, m.readiness().NonceGaps)
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestNonceGapAboveInflight(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	// The highest nonce we assigned is not in-flight, and has not been mined
+	tx20 := newTestNonceGapTxn(t, m, "0xaaaaa", 20, apitypes.TxStatusPending)
+	newTestNonceGapTxn(t, m, "0xaaaaa", 21, apitypes.TxStatusFailed)
+	m.inflight = []*pendingState{tx20, {mtx: tx20.mtx, remove: true}}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(20),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(20),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(22),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	m.checkNonceGaps(m.ctx)
+	gaps := m.getNonceGaps()
+	assert.Len(t, gaps, 1)
+	assert.Equal(t, int64(21), gaps[0].MissingNonce.Int64())
+	assert.Equal(t, int64(21), gaps[0].HighestNonce.Int64())
+
+	// When the in-flight set is full, later nonces might just be waiting for space
+	m.maxInFlight = 1
+	m.checkNonceGaps(m.ctx)
+	assert.Empty(t, m.getNonceGaps())
+
+	// Everything up to the highest nonce has been mined
+	m.maxInFlight = 100
+	m.checkNonceGaps(m.ctx)
+	assert.Empty(t, m.getNonceGaps())
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestNonceGapCheckFailKeepsPreviousState(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestTxn("0xaaaaa", 10, apitypes.TxStatusPending)
+	m.inflight = []*pendingState{{mtx: tx}}
+	previous := &apitypes.NonceGap{Signer: "0xaaaaa", MissingNonce: fftypes.NewFFBigInt(9)}
+	m.nonceGaps["0xaaaaa"] = previous
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", (*fftypes.FFBigInt)(nil), 1, mock.Anything).Return(nil, fmt.Errorf("pop")).Once()
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", (*fftypes.FFBigInt)(nil), 1, mock.Anything).Return([]*apitypes.ManagedTX{tx}, nil)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	m.checkNonceGaps(m.ctx)
+	assert.Equal(t, previous, m.nonceGaps["0xaaaaa"])
+
+	m.checkNonceGaps(m.ctx)
+	assert.Equal(t, previous, m.nonceGaps["0xaaaaa"])
+
+	mp.AssertExpectations(t)
+	mfc.AssertExpectations(t)
+
+}
@@ -0,0 +1,96 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+const pendingCountPageSize = 100
+
+// checkPendingLimit rejects a submission with a 429, if the signer already has the configured maximum number of
+// pending transactions waiting outside of the in-flight set. Must be called holding the nonce lock for the signer,
+// so concurrent submissions for the signer cannot both pass the check.
+func (m *manager) checkPendingLimit(ctx context.Context, signer string) error {
+	if m.maxPendingPerSigner <= 0 {
+		return nil
+	}
+	pending, err := m.pendingCount(ctx, signer, m.maxPendingPerSigner)
+	if err != nil {
+		return err
+	}
+	if pending >= m.maxPendingPerSigner {
+		log.L(ctx).Warnf("Rejecting submission for signer %s with %d pending transactions", signer, pending)
+		return i18n.NewError(ctx, tmmsgs.MsgTooManyPendingTransactions, signer, m.maxPendingPerSigner)
+	}
+	return nil
+}
+
+// pendingCount returns the number of pending transactions for the signer that are not in the in-flight set,
+// stopping once the limit is reached if one is supplied. The in-flight set is as of the last policy loop cycle.
+func (m *manager) pendingCount(ctx context.Context, signer string, limit int) (int, error) {
+	m.mux.Lock()
+	inflight := m.inflightBySigner[signer]
+	m.mux.Unlock()
+
+	countLimit := 0
+	if limit > 0 {
+		countLimit = limit + inflight
+	}
+	total, err := m.countPending(ctx, signer, countLimit)
+	if err != nil {
+		return 0, err
+	}
+	if total < inflight {
+		return 0, nil
+	}
+	return total - inflight, nil
+}
+
+// countPending returns the number of pending transactions for the signer including those in the in-flight set,
+// or for all signers if no signer is supplied, stopping once the limit is reached if one is supplied
+func (m *manager) countPending(ctx context.Context, signer string, limit int) (int, error) {
+	if signer == "" {
+		// The persistence layer maintains the counts by status, so the backlog across all signers is not read from the store
+		counts, err := m.persistence.CountTransactionsByStatus(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return counts[apitypes.TxStatusPending], nil
+	}
+	// The pending transactions of a signer are indexed, so this does not read its completed history
+	total := 0
+	var after *apitypes.ManagedTX
+	for limit <= 0 || total < limit {
+		page, err := m.persistence.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, signer, after, pendingCountPageSize, persistence.SortDirectionAscending)
+		if err != nil {
+			return 0, err
+		}
+		total += len(page)
+		if len(page) < pendingCountPageSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	return total, nil
+}
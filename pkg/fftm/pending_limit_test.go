@@ -0,0 +1,160 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendTransactionPendingLimit(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.maxPendingPerSigner = 2
+
+	tx1 := sendSampleTX(t, m, "0xaaaaa", 1000)
+	sendSampleTX(t, m, "0xaaaaa", 1001)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(1000),
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	mfc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil)
+	send := func(signer string) error {
+		_, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{From: signer},
+			},
+		})
+		return err
+	}
+
+	// At the limit we are rejected, but other signers are unaffected
+	assert.Regexp(t, "FF21139", send("0xaaaaa"))
+	assert.NoError(t, send("0xbbbbb"))
+
+	// Transactions in the in-flight set do not count towards the limit
+	m.mux.Lock()
+	m.inflightBySigner = map[string]int{"0xaaaaa": 1}
+	m.mux.Unlock()
+	assert.NoError(t, send("0xaaaaa"))
+	assert.Regexp(t, "FF21139", send("0xaaaaa"))
+
+	// Once the backlog drains we are accepted again
+	m.mux.Lock()
+	m.inflightBySigner = nil
+	m.mux.Unlock()
+	tx1.Status = apitypes.TxStatusSucceeded
+	err := m.persistence.WriteTransaction(m.ctx, tx1, false)
+	assert.NoError(t, err)
+	assert.Regexp(t, "FF21139", send("0xaaaaa"))
+	m.mux.Lock()
+	m.inflightBySigner = map[string]int{"0xaaaaa": 1}
+	m.mux.Unlock()
+	assert.NoError(t, send("0xaaaaa"))
+
+}
+
+func TestPendingCountPaginates(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	for i := 0; i < pendingCountPageSize+5; i++ {
+		newTestTxn(t, m, "0xaaaaa", int64(i), apitypes.TxStatusPending)
+	}
+	newTestTxn(t, m, "0xaaaaa", pendingCountPageSize+5, apitypes.TxStatusSucceeded)
+	m.inflightBySigner = map[string]int{"0xaaaaa": 3}
+
+	pending, err := m.pendingCount(m.ctx, "0xaaaaa", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, pendingCountPageSize+2, pending)
+
+	// With a limit we stop reading once it is reached
+	pending, err = m.pendingCount(m.ctx, "0xaaaaa", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, pendingCountPageSize-3, pending)
+
+	// An in-flight count from before transactions completed cannot take us negative
+	m.inflightBySigner = map[string]int{"0xbbbbb": 1}
+	pending, err = m.pendingCount(m.ctx, "0xbbbbb", 0)
+	assert.NoError(t, err)
+	assert.Zero(t, pending)
+
+}
+
+func TestPendingLimitQueryFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.maxPendingPerSigner = 2
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByStatus", mock.Anything, apitypes.TxStatusPending, "0xaaaaa", (*apitypes.ManagedTX)(nil), pendingCountPageSize, persistence.SortDirectionAscending).Return(nil, fmt.Errorf("pop"))
+
+	err := m.checkPendingLimit(m.ctx, "0xaaaaa")
+	assert.Regexp(t, "pop", err)
+
+	m.mux.Lock()
+	m.lockedNonces["0xaaaaa"] = &lockedNonce{m: m, nsOpID: "ns1:tx1", signer: "0xaaaaa", lockedAt: fftypes.Now(), unlocked: make(chan struct{})}
+	m.mux.Unlock()
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(1000),
+	}, ffcapi.ErrorReason(""), nil)
+	_, err = m.getNonceStatus(m.ctx)
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestGetNonceStatusPending(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	newTestTxn(t, m, "0xaaaaa", 20, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xaaaaa", 21, apitypes.TxStatusPending)
+	m.inflightBySigner = map[string]int{"0xaaaaa": 1}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(20),
+	}, ffcapi.ErrorReason(""), nil)
+
+	ln, err := m.assignAndLockNonce(m.ctx, "ns1:tx1", "", "0xaaaaa")
+	assert.NoError(t, err)
+	defer ln.complete(m.ctx)
+
+	statuses, err := m.getNonceStatus(m.ctx)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 1)
+	assert.Equal(t, 1, statuses[0].Pending)
+
+}
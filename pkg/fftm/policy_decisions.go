@@ -0,0 +1,75 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// policyDecisionBufferSize is the number of decision events that can be queued for broadcast, before
+// further events are dropped rather than slowing down the policy loop
+const policyDecisionBufferSize = 1000
+
+// publishPolicyDecision queues an event describing an action recorded against a transaction, for broadcast on the
+// reserved WebSocket topic. Nothing is built when no connection is listening, and the send never blocks.
+func (m *manager) publishPolicyDecision(mtx *apitypes.ManagedTX, action apitypes.TxAction, info string, oldGasPrice *fftypes.JSONAny) {
+	if !m.wsServer.HasListeners(apitypes.PolicyDecisionsTopic) {
+		return
+	}
+	event := &apitypes.PolicyDecisionEvent{
+		Headers: apitypes.ReplyHeaders{
+			RequestID: mtx.ID,
+			Type:      apitypes.PolicyDecision,
+		},
+		TransactionID:   mtx.ID,
+		From:            mtx.TransactionHeaders.From,
+		Nonce:           mtx.Nonce,
+		Action:          action,
+		TransactionHash: mtx.TransactionHash,
+		OldGasPrice:     oldGasPrice,
+		Reason:          info,
+		Time:            fftypes.Now(),
+	}
+	if mtx.GasPrice != nil {
+		event.NewGasPrice = fftypes.JSONAnyPtr(mtx.GasPrice.String())
+	}
+	select {
+	case m.policyDecisions <- event:
+	default:
+		log.L(m.ctx).Warnf("Dropped policy decision event for transaction %s action=%s, as the broadcast buffer is full", mtx.ID, action)
+	}
+}
+
+// policyDecisionLoop broadcasts queued decision events to the connections listening on the reserved topic
+func (m *manager) policyDecisionLoop() {
+	defer close(m.policyDecisionsDone)
+	_, broadcast, _ := m.wsServer.GetChannels(apitypes.PolicyDecisionsTopic)
+	for {
+		select {
+		case event := <-m.policyDecisions:
+			select {
+			case broadcast <- event:
+			case <-m.ctx.Done():
+				return
+			}
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
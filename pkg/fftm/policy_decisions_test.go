@@ -0,0 +1,157 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/ws"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// listeningWSServer reports a listener on every topic, and gives the test the broadcast channel
+type listeningWSServer struct {
+	ws.WebSocketServer
+	broadcast chan interface{}
+}
+
+func (s *listeningWSServer) HasListeners(topic string) bool {
+	return true
+}
+
+func (s *listeningWSServer) GetChannels(topic string) (chan<- interface{}, chan<- interface{}, <-chan error) {
+	return nil, s.broadcast, nil
+}
+
+func TestPolicyDecisionGasBumpEvent(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+	noopPolicyEngine(m)
+	err := m.Start()
+	assert.NoError(t, err)
+
+	c, _, err := websocket.DefaultDialer.Dial(strings.Replace(url, "http", "ws", 1)+"/ws", nil)
+	assert.NoError(t, err)
+	defer c.Close()
+	err = c.WriteJSON(map[string]string{"type": "listen", "topic": apitypes.PolicyDecisionsTopic})
+	assert.NoError(t, err)
+	for !m.wsServer.HasListeners(apitypes.PolicyDecisionsTopic) {
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	m.policyLoopInterval = 0
+	m.confirmations.(*confirmationsmocks.Manager).On("Notify", mock.Anything).Return(nil)
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mtx := args[2].(*apitypes.ManagedTX)
+		mtx.GasPrice = fftypes.JSONAnyPtr(`"200"`)
+	}).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx.GasPrice = fftypes.JSONAnyPtr(`"100"`)
+	tx.TransactionHash = "0x111"
+	tx.FirstSubmit = fftypes.Now()
+	tx.LastSubmit = tx.FirstSubmit
+	err = m.execPolicy(m.ctx, &pendingState{mtx: tx}, false)
+	assert.NoError(t, err)
+
+	var event apitypes.PolicyDecisionEvent
+	err = c.ReadJSON(&event)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.PolicyDecision, event.Headers.Type)
+	assert.Equal(t, tx.ID, event.TransactionID)
+	assert.Equal(t, "0xabcd1234", event.From)
+	assert.Equal(t, int64(12345), event.Nonce.Int64())
+	assert.Equal(t, apitypes.TxActionGasPriceChanged, event.Action)
+	assert.Equal(t, "0x111", event.TransactionHash)
+	assert.Equal(t, `"100"`, event.OldGasPrice.String())
+	assert.Equal(t, `"200"`, event.NewGasPrice.String())
+	assert.NotNil(t, event.Time)
+
+	mpe.AssertExpectations(t)
+}
+
+func TestPolicyDecisionFailureReason(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.wsServer = &listeningWSServer{WebSocketServer: m.wsServer}
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	m.addHistory(tx, apitypes.TxActionFailed, "pop")
+
+	event := <-m.policyDecisions
+	assert.Equal(t, apitypes.TxActionFailed, event.Action)
+	assert.Equal(t, "pop", event.Reason)
+	assert.Nil(t, event.OldGasPrice)
+	assert.Nil(t, event.NewGasPrice)
+}
+
+func TestPolicyDecisionNoListener(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	m.addHistory(tx, apitypes.TxActionFailed, "pop")
+	assert.Empty(t, m.policyDecisions)
+}
+
+func TestPolicyDecisionDroppedWhenBufferFull(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.wsServer = &listeningWSServer{WebSocketServer: m.wsServer}
+	m.policyDecisions = make(chan *apitypes.PolicyDecisionEvent, 1)
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	m.addHistory(tx, apitypes.TxActionSubmitted, "0x111")
+	m.addHistory(tx, apitypes.TxActionFailed, "pop") // does not block
+	assert.Len(t, m.policyDecisions, 1)
+	assert.Len(t, tx.History, 2)
+}
+
+func TestPolicyDecisionLoopStopsWhileBroadcasting(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.wsServer = &listeningWSServer{WebSocketServer: m.wsServer, broadcast: make(chan interface{})}
+	m.policyDecisionsDone = make(chan struct{})
+	m.policyDecisions <- &apitypes.PolicyDecisionEvent{}
+
+	ctx, cancelCtx := context.WithCancel(m.ctx)
+	m.ctx = ctx
+	go m.policyDecisionLoop()
+	for len(m.policyDecisions) > 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	cancelCtx()
+	<-m.policyDecisionsDone
+}
@@ -18,33 +18,104 @@ package fftm
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// initPolicyLoopInterval enables the adaptive policy loop interval, if configured. The configured
+// fixed interval is the starting point, within the bounds.
+func (m *manager) initPolicyLoopInterval(ctx context.Context) error {
+	if !config.GetBool(tmconfig.PolicyLoopAdaptiveEnabled) {
+		return nil
+	}
+	minInterval := config.GetDuration(tmconfig.PolicyLoopAdaptiveMinInterval)
+	maxInterval := config.GetDuration(tmconfig.PolicyLoopAdaptiveMaxInterval)
+	if minInterval <= 0 || minInterval > maxInterval {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidAdaptiveLoopInterval, minInterval, maxInterval)
+	}
+	m.policyLoopAdaptive = true
+	m.policyLoopMinInterval = minInterval
+	m.policyLoopMaxInterval = maxInterval
+	switch {
+	case m.policyLoopInterval < minInterval:
+		m.policyLoopInterval = minInterval
+	case m.policyLoopInterval > maxInterval:
+		m.policyLoopInterval = maxInterval
+	}
+	return nil
+}
+
+// adaptPolicyLoopInterval sets the interval of the policy loop after each cycle, when adaptive.
+// While there are in-flight transactions, or the cycle was woken by a signal, the loop runs at
+// the minimum interval. Each cycle with nothing to do doubles the interval, up to the maximum.
+// The interval is also how often the policy engine is invoked for each in-flight transaction.
+func (m *manager) adaptPolicyLoopInterval(ctx context.Context, signalled bool) {
+	if !m.policyLoopAdaptive {
+		return
+	}
+	previous := m.policyLoopInterval
+	if signalled || len(m.inflight) > 0 {
+		m.policyLoopInterval = m.policyLoopMinInterval
+	} else {
+		m.policyLoopInterval *= 2
+		if m.policyLoopInterval > m.policyLoopMaxInterval {
+			m.policyLoopInterval = m.policyLoopMaxInterval
+		}
+	}
+	if m.policyLoopInterval != previous {
+		log.L(ctx).Debugf("Policy loop interval changed from %s to %s (inflight=%d)", previous, m.policyLoopInterval, len(m.inflight))
+	}
+}
+
 func (m *manager) policyLoop() {
 	defer close(m.policyLoopDone)
-	ctx := log.WithLogField(m.ctx, "role", "policyloop")
+	ctx := log.WithLogField(m.leaderCtx, "role", "policyloop")
 
 	for {
+		// Shutdown takes priority over any pending work, as select picks randomly between ready cases
+		select {
+		case <-m.policyLoopDrain:
+			log.L(ctx).Infof("Policy loop exiting after drain")
+			return
+		case <-ctx.Done():
+			log.L(ctx).Infof("Receipt poller exiting")
+			return
+		default:
+		}
 		timer := time.NewTimer(m.policyLoopInterval)
 		select {
-		case <-m.inflightUpdate:
-			m.policyLoopCycle(ctx, false)
-		case <-m.inflightStale:
-			m.policyLoopCycle(ctx, true)
+		case <-m.inflightSignal.wake:
+			timer.Stop()
+			if pending, stale := m.inflightSignal.take(); pending {
+				m.policyLoopCycle(ctx, stale)
+				m.adaptPolicyLoopInterval(ctx, true)
+			}
 		case <-timer.C:
-			m.policyLoopCycle(ctx, false)
+			// Any signals raised since the last cycle are satisfied by this one
+			pending, stale := m.inflightSignal.take()
+			m.policyLoopCycle(ctx, stale)
+			m.adaptPolicyLoopInterval(ctx, pending)
+		case <-m.policyLoopDrain:
+			log.L(ctx).Infof("Policy loop exiting after drain")
+			return
 		case <-ctx.Done():
 			log.L(ctx).Infof("Receipt poller exiting")
 			return
@@ -53,17 +124,11 @@ func (m *manager) policyLoop() {
 }
 
 func (m *manager) markInflightStale() {
-	select {
-	case m.inflightStale <- true:
-	default:
-	}
+	m.inflightSignal.mark(true)
 }
 
 func (m *manager) markInflightUpdate() {
-	select {
-	case m.inflightUpdate <- true:
-	default:
-	}
+	m.inflightSignal.mark(false)
 }
 
 func (m *manager) updateInflightSet(ctx context.Context) bool {
@@ -78,54 +143,275 @@ func (m *manager) updateInflightSet(ctx context.Context) bool {
 		}
 	}
 
-	// If we are not at maximum, then query if there are more candidates now
+	// If we are not at maximum, then query if there are more candidates now.
+	// We query from the oldest pending transaction, as the in-flight set is selected by priority
+	// and might not be a prefix of the pending transactions.
 	spaces := m.maxInFlight - len(m.inflight)
 	if spaces > 0 {
-		var after *fftypes.UUID
-		if len(m.inflight) > 0 {
-			after = m.inflight[len(m.inflight)-1].mtx.SequenceID
+		window := m.priorityWindow
+		if window < spaces {
+			window = spaces
 		}
-		var additional []*apitypes.ManagedTX
+		var pending []*apitypes.ManagedTX
 		// We retry the get from persistence indefinitely (until the context cancels)
 		err := m.retry.Do(ctx, "get pending transactions", func(attempt int) (retry bool, err error) {
-			additional, err = m.persistence.ListTransactionsPending(ctx, after, spaces, persistence.SortDirectionAscending)
+			pending, err = m.persistence.ListTransactionsPending(ctx, nil, len(m.inflight)+window, persistence.SortDirectionAscending)
 			return true, err
 		})
 		if err != nil {
 			log.L(ctx).Infof("Policy loop context cancelled while retrying")
 			return false
 		}
-		for _, mtx := range additional {
-			m.inflight = append(m.inflight, &pendingState{mtx: mtx})
+		inflightIDs := make(map[string]bool, len(m.inflight))
+		for _, p := range m.inflight {
+			inflightIDs[p.mtx.ID] = true
+		}
+		candidates := make([]*apitypes.ManagedTX, 0, len(pending))
+		for _, mtx := range pending {
+			if !inflightIDs[mtx.ID] {
+				candidates = append(candidates, mtx)
+			}
+		}
+		for _, mtx := range selectByPriority(candidates, spaces) {
+			m.inflight = append(m.inflight, &pendingState{mtx: mtx, span: m.startTransactionSpan(ctx, mtx)})
 		}
 		newLen := len(m.inflight)
 		if newLen > 0 {
-			log.L(ctx).Debugf("Inflight set updated len=%d head-seq=%s tail-seq=%s old-tail=%s", len(m.inflight), m.inflight[0].mtx.SequenceID, m.inflight[newLen-1].mtx.SequenceID, after)
+			log.L(ctx).Debugf("Inflight set updated len=%d head-seq=%s tail-seq=%s", len(m.inflight), m.inflight[0].mtx.SequenceID, m.inflight[newLen-1].mtx.SequenceID)
 		}
 	}
+
+	inflightBySigner := make(map[string]int)
+	for _, p := range m.inflight {
+		inflightBySigner[p.mtx.NonceKey()]++
+	}
+	m.mux.Lock()
+	m.inflightBySigner = inflightBySigner
+	m.mux.Unlock()
 	return true
 
 }
 
+// selectByPriority chooses up to the specified number of candidates to move into the in-flight set.
+// The candidates must be in age order. Higher priority transactions are selected first, with age as
+// the tiebreaker. Transactions from the same signer are only selected in nonce order, so a higher
+// priority transaction raises the priority of the lower nonce transactions from its signer that it
+// must wait for, rather than being selected ahead of them.
+func selectByPriority(candidates []*apitypes.ManagedTX, count int) []*apitypes.ManagedTX {
+	type queued struct {
+		mtx       *apitypes.ManagedTX
+		age       int // position in the candidates
+		effective int // the highest priority of this transaction, and later nonces from the same signer
+	}
+	signers := make([]string, 0)
+	bySigner := make(map[string][]*queued)
+	for i, mtx := range candidates {
+		signer := mtx.NonceKey()
+		if _, ok := bySigner[signer]; !ok {
+			signers = append(signers, signer)
+		}
+		bySigner[signer] = append(bySigner[signer], &queued{mtx: mtx, age: i})
+	}
+	for _, signer := range signers {
+		queue := bySigner[signer]
+		sort.SliceStable(queue, func(i, j int) bool {
+			return queue[i].mtx.Nonce.Int().Cmp(queue[j].mtx.Nonce.Int()) < 0
+		})
+		for i := len(queue) - 1; i >= 0; i-- {
+			queue[i].effective = queue[i].mtx.Priority
+			if i < len(queue)-1 && queue[i+1].effective > queue[i].effective {
+				queue[i].effective = queue[i+1].effective
+			}
+		}
+	}
+
+	selected := make([]*apitypes.ManagedTX, 0, count)
+	for len(selected) < count {
+		// Pick the best transaction at the head of each signer's queue
+		var best string
+		var bestHead *queued
+		for _, signer := range signers {
+			queue := bySigner[signer]
+			if len(queue) == 0 {
+				continue
+			}
+			head := queue[0]
+			if bestHead == nil || head.effective > bestHead.effective || (head.effective == bestHead.effective && head.age < bestHead.age) {
+				best, bestHead = signer, head
+			}
+		}
+		if bestHead == nil {
+			break
+		}
+		selected = append(selected, bestHead.mtx)
+		bySigner[best] = bySigner[best][1:]
+	}
+	return selected
+}
+
+// restoreNonceOrder is an ordering pass over the first in-flight set loaded after a restart.
+// The in-flight set is loaded from a window of pending transactions in the order they were created,
+// which might not contain the lowest pending nonce of every signer. So each signer's transactions in
+// the set are replaced with the lowest nonces that signer has pending.
+func (m *manager) restoreNonceOrder(ctx context.Context) bool {
+	signers := make([]string, 0)
+	positions := make(map[string][]int)
+	existing := make(map[string]*pendingState, len(m.inflight))
+	for i, p := range m.inflight {
+		signer := p.mtx.NonceKey()
+		if _, ok := positions[signer]; !ok {
+			signers = append(signers, signer)
+		}
+		positions[signer] = append(positions[signer], i)
+		existing[p.mtx.ID] = p
+	}
+	for _, signer := range signers {
+		var lowest []*apitypes.ManagedTX
+		// We retry the get from persistence indefinitely (until the context cancels)
+		err := m.retry.Do(ctx, "get pending transactions for signer", func(attempt int) (retry bool, err error) {
+			lowest, err = m.persistence.ListTransactionsByStatus(ctx, apitypes.TxStatusPending, signer, nil, len(positions[signer]), persistence.SortDirectionAscending)
+			return true, err
+		})
+		if err != nil {
+			log.L(ctx).Infof("Policy loop context cancelled while retrying")
+			return false
+		}
+		if len(lowest) != len(positions[signer]) {
+			// Transactions completed while we were querying, so they will be replaced on the next update
+			continue
+		}
+		for i, mtx := range lowest {
+			p := existing[mtx.ID]
+			if p == nil {
+				log.L(ctx).Infof("Restored transaction %s at nonce %s / %d into the in-flight set ahead of later nonces", mtx.ID, signer, mtx.Nonce.Int64())
+				p = &pendingState{mtx: mtx, span: m.startTransactionSpan(ctx, mtx)}
+			}
+			m.inflight[positions[signer][i]] = p
+		}
+	}
+	return true
+}
+
+// orderInflightByNonce re-orders the in-flight set so each signer's transactions are in ascending
+// nonce order. Each signer keeps the positions it already occupies in the set, so the relative
+// order between signers chosen by priority is unchanged. Transactions restored from persistence
+// after a restart are in the order they were created, which is not always the order of their nonces.
+func orderInflightByNonce(inflight []*pendingState) {
+	positions := make(map[string][]int)
+	bySigner := make(map[string][]*pendingState)
+	for i, p := range inflight {
+		signer := p.mtx.NonceKey()
+		positions[signer] = append(positions[signer], i)
+		bySigner[signer] = append(bySigner[signer], p)
+	}
+	for signer, queue := range bySigner {
+		sort.SliceStable(queue, func(i, j int) bool {
+			return queue[i].mtx.Nonce.Int().Cmp(queue[j].mtx.Nonce.Int()) < 0
+		})
+		for i, pos := range positions[signer] {
+			inflight[pos] = queue[i]
+		}
+	}
+}
+
 func (m *manager) policyLoopCycle(ctx context.Context, inflightStale bool) {
 
 	// Process any synchronous commands first - these might not be in our inflight set
 	m.processPolicyAPIRequests(ctx)
 
+	if m.releaseScheduled(ctx) {
+		inflightStale = true
+	}
+
 	if inflightStale {
 		if !m.updateInflightSet(ctx) {
 			return
 		}
+		if m.strictNonceOrdering {
+			if !m.inflightRestored {
+				if !m.restoreNonceOrder(ctx) {
+					return
+				}
+				m.inflightRestored = true
+			}
+			orderInflightByNonce(m.inflight)
+		}
 	}
 
 	// Go through executing the policy engine against them
-	for _, pending := range m.inflight {
+	if m.policyLoopWorkers > 1 {
+		m.execInflightConcurrently(ctx)
+	} else {
+		m.execInflight(ctx, m.inflight)
+	}
+
+	if m.nonceGapCheckInterval > 0 && time.Since(m.lastNonceGapCheck) > m.nonceGapCheckInterval {
+		m.checkNonceGaps(ctx)
+		m.lastNonceGapCheck = time.Now()
+	}
+
+	m.mux.Lock()
+	m.lastPolicyLoop = fftypes.Now()
+	m.mux.Unlock()
+}
+
+// execInflight executes the policy engine against each of the supplied transactions in turn.
+// With strict nonce ordering, once a signer has a transaction that is not yet submitted, we hold
+// the first submission of any later nonces from that signer until the next cycle.
+func (m *manager) execInflight(ctx context.Context, inflight []*pendingState) {
+	unsubmitted := make(map[string]bool)
+	for _, pending := range inflight {
+		signer := pending.mtx.NonceKey()
+		if m.strictNonceOrdering && unsubmitted[signer] && pending.mtx.FirstSubmit == nil {
+			log.L(txLogContext(ctx, pending.mtx)).Debugf("Holding transaction %s at nonce %s / %d until lower nonces are submitted", pending.mtx.ID, signer, pending.mtx.Nonce.Int64())
+			continue
+		}
 		err := m.execPolicy(ctx, pending, false)
 		if err != nil {
-			log.L(ctx).Errorf("Failed policy cycle transaction=%s operation=%s: %s", pending.mtx.TransactionHash, pending.mtx.ID, err)
+			log.L(txLogContext(ctx, pending.mtx)).Errorf("Failed policy cycle transaction=%s operation=%s: %s", pending.mtx.TransactionHash, pending.mtx.ID, err)
+		}
+		if !pending.remove && pending.mtx.FirstSubmit == nil {
+			unsubmitted[signer] = true
 		}
 	}
+}
 
+// execInflightConcurrently splits the inflight set by signer, and hands each signer's transactions to one of
+// a pool of workers. So transactions for different signers are processed concurrently, but the transactions
+// for each signer are processed in order by a single worker - exactly as they would be by execInflight.
+// The inflight set itself is not modified until all the workers have finished.
+func (m *manager) execInflightConcurrently(ctx context.Context) {
+	var signers []string
+	bySigner := make(map[string][]*pendingState)
+	for _, pending := range m.inflight {
+		signer := pending.mtx.NonceKey()
+		if _, ok := bySigner[signer]; !ok {
+			signers = append(signers, signer)
+		}
+		bySigner[signer] = append(bySigner[signer], pending)
+	}
+
+	work := make(chan []*pendingState, len(signers))
+	for _, signer := range signers {
+		work <- bySigner[signer]
+	}
+	close(work)
+
+	workers := m.policyLoopWorkers
+	if workers > len(signers) {
+		workers = len(signers)
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for signerInflight := range work {
+				m.execInflight(ctx, signerInflight)
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 // processPolicyAPIRequests executes any API calls requested that require policy engine involvement - such as transaction deletions
@@ -163,12 +449,24 @@ func (m *manager) processPolicyAPIRequests(ctx context.Context) {
 			if err := m.execPolicy(ctx, pending, true); err != nil {
 				request.response <- policyEngineAPIResponse{err: err}
 			} else {
-				res := policyEngineAPIResponse{tx: pending.mtx, status: http.StatusAccepted}
+				res := policyEngineAPIResponse{tx: m.copyTX(pending.mtx), status: http.StatusAccepted}
 				if pending.remove {
 					res.status = http.StatusOK // synchronously completed
 				}
 				request.response <- res
 			}
+		case policyEngineAPIRequestTypeCancel:
+			if err := m.requestCancel(ctx, pending); err != nil {
+				request.response <- policyEngineAPIResponse{err: err}
+			} else {
+				request.response <- policyEngineAPIResponse{tx: m.copyTX(pending.mtx), status: http.StatusAccepted}
+			}
+		case policyEngineAPIRequestTypeUndelete:
+			if err := m.undelete(ctx, pending); err != nil {
+				request.response <- policyEngineAPIResponse{err: err}
+			} else {
+				request.response <- policyEngineAPIResponse{tx: m.copyTX(pending.mtx), status: http.StatusOK}
+			}
 		default:
 			request.response <- policyEngineAPIResponse{
 				err: i18n.NewError(ctx, tmmsgs.MsgPolicyEngineRequestInvalid, request.requestType),
@@ -178,6 +476,96 @@ func (m *manager) processPolicyAPIRequests(ctx context.Context) {
 
 }
 
+// copyTX returns a copy of the transaction to return to an API caller, as the policy loop and the
+// confirmation manager continue to update the in-flight record after the response is sent.
+func (m *manager) copyTX(mtx *apitypes.ManagedTX) *apitypes.ManagedTX {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	txCopy := *mtx
+	return &txCopy
+}
+
+// requestCancel records that a submitted transaction should be cancelled. The policy engine acts on
+// this the next time it is invoked for the transaction, by submitting a no-op transaction to replace it.
+func (m *manager) requestCancel(ctx context.Context, pending *pendingState) error {
+	mtx := pending.mtx
+	switch {
+	case txComplete(mtx):
+		return i18n.NewError(ctx, tmmsgs.MsgTransactionComplete, mtx.ID, mtx.Status)
+	case mtx.FirstSubmit == nil:
+		return i18n.NewError(ctx, tmmsgs.MsgTransactionNotSubmitted, mtx.ID)
+	case mtx.Cancel != nil:
+		// Already requested
+		return nil
+	}
+	m.mux.Lock()
+	mtx.Cancel = &apitypes.ManagedTXCancel{Requested: fftypes.Now()}
+	mtx.Updated = fftypes.Now()
+	m.addHistory(mtx, apitypes.TxActionCancelRequested, "")
+	m.mux.Unlock()
+	if err := m.persistence.WriteTransaction(ctx, mtx, false); err != nil {
+		return err
+	}
+	// We do not wait for the policy engine interval to pass before invoking it for the cancellation
+	pending.lastPolicyCycle = time.Time{}
+	return nil
+}
+
+// undelete restores a deleted transaction that has not yet been removed by the reaper, to the status it
+// had when it was deleted. A pending transaction rejoins the in-flight set, and continues from where it left off.
+func (m *manager) undelete(ctx context.Context, pending *pendingState) error {
+	mtx := pending.mtx
+	m.mux.Lock()
+	if mtx.Status != apitypes.TxStatusDeleted {
+		m.mux.Unlock()
+		return i18n.NewError(ctx, tmmsgs.MsgTransactionNotDeleted, mtx.ID)
+	}
+	mtx.Status = mtx.DeletedStatus
+	mtx.DeletedStatus = ""
+	mtx.Deleted = nil
+	mtx.DeleteRequested = nil
+	mtx.Updated = fftypes.Now()
+	m.addHistory(mtx, apitypes.TxActionUndeleted, "")
+	m.mux.Unlock()
+	if err := m.persistence.WriteTransaction(ctx, mtx, false); err != nil {
+		return err
+	}
+	log.L(txLogContext(ctx, mtx)).Infof("Transaction %s at nonce %s / %d restored with status %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.Status)
+	switch mtx.Status {
+	case apitypes.TxStatusPending:
+		m.markInflightStale()
+	case apitypes.TxStatusScheduled:
+		m.markScheduled(time.Time(*mtx.NotBefore))
+	}
+	return nil
+}
+
+// expiryReached checks whether a transaction has passed its expiry without being mined, and without
+// cancellation already being under way.
+// Must be called holding the mux, as the receipt is updated by the confirmation manager.
+func (m *manager) expiryReached(mtx *apitypes.ManagedTX) bool {
+	return mtx.Expiry != nil && mtx.Receipt == nil && mtx.Cancel == nil && !time.Now().Before(time.Time(*mtx.Expiry))
+}
+
+// cancelExpired records that a submitted transaction is to be cancelled as it has expired. The policy engine
+// submits the no-op transaction to replace it in this cycle, and the outcome is reported just as for
+// a requested cancellation - except that a transaction cancelled in this way fails with the Expired code.
+func (m *manager) cancelExpired(ctx context.Context, pending *pendingState) error {
+	mtx := pending.mtx
+	log.L(ctx).Infof("Transaction %s at nonce %s / %d reached its expiry at %s - cancelling", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.Expiry)
+	m.mux.Lock()
+	mtx.Cancel = &apitypes.ManagedTXCancel{Requested: fftypes.Now(), Expired: true}
+	mtx.Updated = fftypes.Now()
+	m.addHistory(mtx, apitypes.TxActionExpired, mtx.Expiry.String())
+	m.mux.Unlock()
+	if err := m.persistence.WriteTransaction(ctx, mtx, false); err != nil {
+		log.L(ctx).Errorf("Failed to update transaction %s (status=%s): %s", mtx.ID, mtx.Status, err)
+		return err
+	}
+	pending.lastPolicyCycle = time.Time{}
+	return nil
+}
+
 func (m *manager) addError(mtx *apitypes.ManagedTX, reason ffcapi.ErrorReason, err error) {
 	newLen := len(mtx.ErrorHistory) + 1
 	if newLen > m.errorHistoryCount {
@@ -197,6 +585,262 @@ func (m *manager) addError(mtx *apitypes.ManagedTX, reason ffcapi.ErrorReason, e
 	}
 }
 
+// setFailure records the terminal failure of a transaction, using the current error message
+func (m *manager) setFailure(mtx *apitypes.ManagedTX, reason ffcapi.ErrorReason, def apitypes.TxFailureCode) {
+	mtx.Failure = &apitypes.ManagedTXFailure{
+		Time:   fftypes.Now(),
+		Code:   apitypes.FailureCodeForReason(reason, def),
+		Reason: reason,
+		Error:  mtx.ErrorMessage,
+	}
+}
+
+// markScheduled records that a scheduled transaction needs releasing at the given time, if that is
+// earlier than any existing scheduled transaction
+func (m *manager) markScheduled(notBefore time.Time) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.nextScheduled == nil || notBefore.Before(*m.nextScheduled) {
+		m.nextScheduled = &notBefore
+	}
+}
+
+// releaseScheduled moves scheduled transactions that have reached their notBefore time into the
+// pending state, so they join the in-flight set. Returns true if any were released.
+func (m *manager) releaseScheduled(ctx context.Context) bool {
+	m.mux.Lock()
+	next := m.nextScheduled
+	due := next != nil && !time.Now().Before(*next)
+	if due {
+		// Cleared before we query, so transactions scheduled while we're querying are not lost
+		m.nextScheduled = nil
+	}
+	m.mux.Unlock()
+	if !due {
+		return false
+	}
+
+	released := false
+	var after *apitypes.ManagedTX
+	for {
+		scheduled, err := m.persistence.ListTransactionsByStatus(ctx, apitypes.TxStatusScheduled, "", after, startupPaginationLimit, persistence.SortDirectionAscending)
+		if err != nil {
+			log.L(ctx).Errorf("Failed to query scheduled transactions: %s", err)
+			m.markScheduled(time.Now())
+			return released
+		}
+		for _, mtx := range scheduled {
+			after = mtx
+			ctx := txLogContext(ctx, mtx)
+			if mtx.NotBefore != nil && time.Time(*mtx.NotBefore).After(time.Now()) {
+				m.markScheduled(time.Time(*mtx.NotBefore))
+				continue
+			}
+			mtx.Status = apitypes.TxStatusPending
+			mtx.Updated = fftypes.Now()
+			m.addHistory(mtx, apitypes.TxActionScheduleReached, "")
+			if err := m.persistence.WriteTransaction(ctx, mtx, false); err != nil {
+				log.L(ctx).Errorf("Failed to release scheduled transaction %s: %s", mtx.ID, err)
+				m.markScheduled(time.Now())
+				continue
+			}
+			log.L(ctx).Infof("Released scheduled transaction %s at nonce %s / %d", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64())
+			released = true
+		}
+		if len(scheduled) < startupPaginationLimit {
+			return released
+		}
+	}
+}
+
+// addHistory records an action in the history of the transaction. Once the history reaches the
+// configured maximum, the oldest entries are collapsed into a single summary entry at the start.
+func (m *manager) addHistory(mtx *apitypes.ManagedTX, action apitypes.TxAction, info string) {
+	m.appendHistory(mtx, action, info)
+	m.publishPolicyDecision(mtx, action, info, nil)
+}
+
+func (m *manager) appendHistory(mtx *apitypes.ManagedTX, action apitypes.TxAction, info string) {
+	mtx.History = append(mtx.History, &apitypes.TxHistoryEntry{
+		Time:   fftypes.Now(),
+		Action: action,
+		Info:   info,
+	})
+	if m.maxHistoryCount <= 1 || len(mtx.History) <= m.maxHistoryCount {
+		return
+	}
+	collapse := len(mtx.History) - m.maxHistoryCount + 1
+	summary := &apitypes.TxHistoryEntry{
+		Time:   mtx.History[0].Time,
+		Action: apitypes.TxActionHistorySummary,
+	}
+	for _, h := range mtx.History[0:collapse] {
+		if h.Action == apitypes.TxActionHistorySummary {
+			summary.Count += h.Count
+		} else {
+			summary.Count++
+		}
+	}
+	mtx.History = append([]*apitypes.TxHistoryEntry{summary}, mtx.History[collapse:]...)
+}
+
+// recordPolicyActions compares the state of the transaction before and after the policy engine
+// executed, to record the actions it took in the history of the transaction
+func (m *manager) recordPolicyActions(mtx *apitypes.ManagedTX, lastSubmit *fftypes.FFTime, gasPrice string, cancelLastSubmit *fftypes.FFTime) {
+	if mtx.GasPrice.String() != gasPrice && lastSubmit != nil {
+		m.appendHistory(mtx, apitypes.TxActionGasPriceChanged, mtx.GasPrice.String())
+		var oldGasPrice *fftypes.JSONAny
+		if gasPrice != "" {
+			oldGasPrice = fftypes.JSONAnyPtr(gasPrice)
+		}
+		m.publishPolicyDecision(mtx, apitypes.TxActionGasPriceChanged, "", oldGasPrice)
+	}
+	if mtx.LastSubmit != nil && !mtx.LastSubmit.Equal(lastSubmit) {
+		if lastSubmit == nil {
+			m.addHistory(mtx, apitypes.TxActionSubmitted, mtx.TransactionHash)
+		} else {
+			m.addHistory(mtx, apitypes.TxActionResubmitted, mtx.TransactionHash)
+		}
+	}
+	if mtx.Cancel != nil && mtx.Cancel.LastSubmit != nil && !mtx.Cancel.LastSubmit.Equal(cancelLastSubmit) {
+		m.addHistory(mtx, apitypes.TxActionCancelSubmitted, mtx.Cancel.TransactionHash)
+	}
+}
+
+// submissionTimeoutExpired checks whether a submitted transaction has gone without a receipt for longer than
+// its submission timeout. The window starts at the first submission, and restarts whenever the gas price is changed,
+// as a transaction that is re-priced gets a fresh chance of being mined.
+// Must be called holding the mux, as the receipt is updated by the confirmation manager.
+func (m *manager) submissionTimeoutExpired(mtx *apitypes.ManagedTX) (time.Duration, bool) {
+	if mtx.FirstSubmit == nil || mtx.Receipt != nil {
+		return 0, false
+	}
+	timeout := m.submissionTimeout
+	if mtx.SubmissionTimeout != nil {
+		timeout = time.Duration(*mtx.SubmissionTimeout)
+	}
+	if timeout <= 0 {
+		return 0, false
+	}
+	windowStart := *mtx.FirstSubmit.Time()
+	for _, h := range mtx.History {
+		if h.Action == apitypes.TxActionGasPriceChanged && h.Time.Time().After(windowStart) {
+			windowStart = *h.Time.Time()
+		}
+	}
+	return timeout, time.Since(windowStart) > timeout
+}
+
+// checkParentSucceeded determines whether a transaction is still waiting for the transaction it depends on,
+// returning an error if that transaction did not succeed. The parent is checked at most once per policy interval.
+func (m *manager) checkParentSucceeded(ctx context.Context, pending *pendingState) (bool, error) {
+	if time.Since(pending.lastPolicyCycle) <= m.policyLoopInterval {
+		return true, nil
+	}
+	pending.lastPolicyCycle = time.Now()
+	mtx := pending.mtx
+	parent, err := m.persistence.GetTransactionByID(ctx, mtx.DependsOn)
+	if err != nil {
+		log.L(ctx).Errorf("Failed to query transaction %s that %s depends on: %s", mtx.DependsOn, mtx.ID, err)
+		return true, nil
+	}
+	switch {
+	case parent == nil:
+		return false, i18n.NewError(ctx, tmmsgs.MsgDependencyNotFound, mtx.DependsOn)
+	case parent.Status == apitypes.TxStatusSucceeded:
+		log.L(ctx).Infof("Transaction %s that %s depends on has succeeded", mtx.DependsOn, mtx.ID)
+		pending.parentSucceeded = true
+		// Allow the policy engine to run immediately
+		pending.lastPolicyCycle = time.Time{}
+		return false, nil
+	case parent.Status == apitypes.TxStatusFailed:
+		return false, i18n.NewError(ctx, tmmsgs.MsgDependencyFailed, mtx.DependsOn, parent.Status)
+	default:
+		return true, nil
+	}
+}
+
+// retryLimit bounds how long the policy engine is retried for a transaction that continues to return errors.
+// Zero values mean no limit
+type retryLimit struct {
+	maxAttempts int
+	maxElapsed  time.Duration
+}
+
+// retryLimitConfig reads the limit for a type of operation, falling back to the policy loop wide
+// limit for any setting that is not overridden for that operation
+func retryLimitConfig(maxAttemptsKey, maxElapsedKey config.RootKey) retryLimit {
+	limit := retryLimit{
+		maxAttempts: config.GetInt(tmconfig.PolicyLoopBackoffMaxAttempts),
+		maxElapsed:  config.GetDuration(tmconfig.PolicyLoopBackoffMaxElapsed),
+	}
+	if maxAttempts := config.GetInt(maxAttemptsKey); maxAttempts > 0 {
+		limit.maxAttempts = maxAttempts
+	}
+	if maxElapsed := config.GetDuration(maxElapsedKey); maxElapsed > 0 {
+		limit.maxElapsed = maxElapsed
+	}
+	return limit
+}
+
+// retryLimitReached checks whether a transaction has exceeded the limit for consecutive policy engine errors,
+// using the limit for submission if the transaction has not yet been submitted, and for resubmission if it has
+func (m *manager) retryLimitReached(mtx *apitypes.ManagedTX, pending *pendingState) bool {
+	limit := m.resubmitRetryLimit
+	if mtx.FirstSubmit == nil {
+		limit = m.submitRetryLimit
+	}
+	if limit.maxAttempts > 0 && pending.failedCycles >= limit.maxAttempts {
+		return true
+	}
+	return limit.maxElapsed > 0 && time.Since(pending.firstFailure) >= limit.maxElapsed
+}
+
+// failureBackoff returns how long to wait before invoking the policy engine again for a transaction,
+// after the specified number of consecutive failures. The delay grows exponentially up to the maximum,
+// with jitter so that transactions that fail together do not all retry together.
+func (m *manager) failureBackoff(failures int) time.Duration {
+	factor := m.backoff.Factor
+	if factor < 1 {
+		factor = 2
+	}
+	delay := float64(m.backoff.InitialDelay)
+	for i := 1; i < failures && delay < float64(m.backoff.MaximumDelay); i++ {
+		delay *= factor
+	}
+	if delay > float64(m.backoff.MaximumDelay) {
+		delay = float64(m.backoff.MaximumDelay)
+	}
+	// Use a random delay between half and all of the calculated delay
+	half := int64(delay) / 2
+	return time.Duration(half + rand.Int63n(half+1)) // #nosec G404 - jitter does not need a secure random source
+}
+
+// checkRevertRetry handles a submission the connector reported as reverted, scheduling a retry after the
+// configured delay, as the revert might be caused by transient state on the node. Returns true if the
+// transaction has been marked as failed, because the limit was reached or the revert was caused by invalid
+// inputs - which cannot succeed on retry.
+func (m *manager) checkRevertRetry(ctx context.Context, pending *pendingState, reason ffcapi.ErrorReason, err error) (failed bool) {
+	mtx := pending.mtx
+	pending.revertRetries++
+	switch {
+	case reason == ffcapi.ErrorReasonInvalidInputs:
+		mtx.ErrorMessage = i18n.NewError(ctx, tmmsgs.MsgDeterministicRevert, err).Error()
+	case pending.revertRetries > m.revertRetryMaxAttempts:
+		mtx.ErrorMessage = i18n.NewError(ctx, tmmsgs.MsgRevertRetriesExhausted, pending.revertRetries, err).Error()
+	default:
+		log.L(ctx).Infof("Retrying reverted transaction %s in %s (retry %d/%d)", mtx.ID, m.revertRetryDelay, pending.revertRetries, m.revertRetryMaxAttempts)
+		m.addHistory(mtx, apitypes.TxActionRevertRetry, fmt.Sprintf("%d/%d: %s", pending.revertRetries, m.revertRetryMaxAttempts, err))
+		pending.backoffUntil = time.Now().Add(m.revertRetryDelay)
+		return false
+	}
+	mtx.Status = apitypes.TxStatusFailed
+	m.setFailure(mtx, reason, apitypes.TxFailureReverted)
+	log.L(ctx).Warnf("Transaction %s at nonce %s / %d failed: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.ErrorMessage)
+	m.addHistory(mtx, apitypes.TxActionFailed, mtx.ErrorMessage)
+	return true
+}
+
 func (m *manager) execPolicy(ctx context.Context, pending *pendingState, syncDeleteRequest bool) (err error) {
 
 	update := policyengine.UpdateNo
@@ -206,41 +850,217 @@ func (m *manager) execPolicy(ctx context.Context, pending *pendingState, syncDel
 	m.mux.Lock()
 	mtx := pending.mtx
 	confirmed := pending.confirmed
+	cancelConfirmed := pending.cancelConfirmed
+	timeout, timedOut := m.submissionTimeoutExpired(mtx)
+	expired := !confirmed && !cancelConfirmed && !syncDeleteRequest && m.expiryReached(mtx)
+	// The health check and circuit breaker only cover the default connector
+	connectorHealthy := mtx.Connector != "" || (m.connectorHealthy && (m.connectorBreaker == nil || !m.connectorBreaker.isOpen()))
+	submissionsPaused := m.submissionsPaused
 	if syncDeleteRequest && mtx.DeleteRequested == nil {
 		mtx.DeleteRequested = fftypes.Now()
+		m.addHistory(mtx, apitypes.TxActionDeleteRequested, "")
 	}
 	m.mux.Unlock()
+	ctx = txLogContext(ctx, mtx)
+	if pending.span != nil {
+		ctx = trace.ContextWithSpan(ctx, pending.span)
+	}
+
+	// A submitted transaction that has expired is actively cancelled, using the same flow as a requested cancellation
+	if expired && mtx.FirstSubmit != nil {
+		if err := m.cancelExpired(ctx, pending); err != nil {
+			return err
+		}
+	}
+
+	// A transaction that depends on another is not submitted until that transaction succeeds
+	waitingForParent := false
+	var parentErr error
+	if !confirmed && !syncDeleteRequest && mtx.FirstSubmit == nil && mtx.DependsOn != "" && !pending.parentSucceeded {
+		waitingForParent, parentErr = m.checkParentSucceeded(ctx, pending)
+	}
 
 	switch {
 	case confirmed && !syncDeleteRequest:
 		update = policyengine.UpdateYes
 		completed = true
+		if mtx.Cancel != nil {
+			// The original transaction was mined at the nonce before the cancellation
+			mtx.Cancel.Outcome = apitypes.CancelOutcomeConfirmed
+		}
 		if mtx.Receipt.Success {
 			mtx.Status = apitypes.TxStatusSucceeded
 			mtx.ErrorMessage = ""
+			mtx.Failure = nil
+			if mtx.DeployContract {
+				mtx.ContractLocation = mtx.Receipt.ContractLocation
+				log.L(ctx).Infof("Transaction %s deployed contract %s", mtx.ID, mtx.ContractLocation)
+			}
+			m.addHistory(mtx, apitypes.TxActionConfirmed, "")
 		} else {
 			mtx.Status = apitypes.TxStatusFailed
 			mtx.ErrorMessage = i18n.NewError(ctx, tmmsgs.MsgTransactionFailed).Error()
+			m.setFailure(mtx, ffcapi.ErrorReasonTransactionReverted, apitypes.TxFailureReverted)
+			m.addHistory(mtx, apitypes.TxActionFailed, mtx.ErrorMessage)
 		}
 
+	case cancelConfirmed && !syncDeleteRequest:
+		// The no-op transaction was mined at the nonce, so the original can never be mined
+		update = policyengine.UpdateYes
+		completed = true
+		mtx.Status = apitypes.TxStatusFailed
+		mtx.Cancel.Outcome = apitypes.CancelOutcomeCancelled
+		mtx.ErrorMessage = i18n.NewError(ctx, tmmsgs.MsgTransactionCancelled, mtx.Cancel.TransactionHash).Error()
+		failureCode := apitypes.TxFailureCancelled
+		if mtx.Cancel.Expired {
+			failureCode = apitypes.TxFailureExpired
+		}
+		m.setFailure(mtx, "", failureCode)
+		log.L(ctx).Infof("Transaction %s at nonce %s / %d cancelled: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.ErrorMessage)
+		m.addHistory(mtx, apitypes.TxActionCancelled, mtx.Cancel.TransactionHash)
+		m.untrackSubmittedTransaction(ctx, pending)
+
+	case timedOut && !syncDeleteRequest:
+		// The transaction was accepted by the connector, but has not been mined in the window we allow
+		update = policyengine.UpdateYes
+		completed = true
+		mtx.Status = apitypes.TxStatusFailed
+		mtx.ErrorMessage = i18n.NewError(ctx, tmmsgs.MsgSubmissionTimeout, timeout).Error()
+		// The most recent error from the connector (if any) is the best indication of why it was not mined
+		var lastReason ffcapi.ErrorReason
+		if len(mtx.ErrorHistory) > 0 {
+			lastReason = mtx.ErrorHistory[0].Mapped
+		}
+		m.setFailure(mtx, lastReason, apitypes.TxFailureSubmissionTimeout)
+		log.L(ctx).Warnf("Transaction %s at nonce %s / %d failed: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.ErrorMessage)
+		m.addHistory(mtx, apitypes.TxActionSubmissionTimeout, mtx.ErrorMessage)
+		m.untrackSubmittedTransaction(ctx, pending)
+
+	case expired && mtx.FirstSubmit == nil:
+		// Nothing was submitted, so there is nothing to cancel on-chain
+		update = policyengine.UpdateYes
+		completed = true
+		mtx.Status = apitypes.TxStatusFailed
+		mtx.ErrorMessage = i18n.NewError(ctx, tmmsgs.MsgTransactionExpired, mtx.Expiry).Error()
+		m.setFailure(mtx, "", apitypes.TxFailureExpired)
+		log.L(ctx).Warnf("Transaction %s at nonce %s / %d failed: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.ErrorMessage)
+		m.addHistory(mtx, apitypes.TxActionExpired, mtx.ErrorMessage)
+
+	case parentErr != nil:
+		update = policyengine.UpdateYes
+		completed = true
+		mtx.Status = apitypes.TxStatusFailed
+		mtx.ErrorMessage = parentErr.Error()
+		m.setFailure(mtx, "", apitypes.TxFailureDependencyFailed)
+		log.L(ctx).Warnf("Transaction %s at nonce %s / %d failed: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.ErrorMessage)
+		m.addHistory(mtx, apitypes.TxActionDependencyFailed, mtx.ErrorMessage)
+
+	case waitingForParent:
+		// Nothing to do until the parent transaction completes
+
+	case !connectorHealthy && !syncDeleteRequest:
+		// Submissions are paused until the health check finds the connector reachable again, and while the
+		// circuit breaker around the connector is open.
+		// Errors up to this point might have been caused by the outage, so they do not count against the retry limit.
+		pending.failedCycles = 0
+		pending.firstFailure = time.Time{}
+		pending.backoffUntil = time.Time{}
+
+	case submissionsPaused && !syncDeleteRequest:
+		// The emergency stop is set, so nothing is sent to the connector until submissions are resumed.
+		// The transaction stays pending, and confirmations continue to be processed above.
+
 	default:
 		// We get woken for lots of reasons to go through the policy loop, but we only want
 		// to drive the policy engine at regular intervals.
 		// So we track the last time we ran the policy engine against each pending item.
 		// We always call the policy engine on every loop, when deletion has been requested.
-		if syncDeleteRequest || time.Since(pending.lastPolicyCycle) > m.policyLoopInterval {
+		// After an error, we back off from calling the policy engine for this transaction (only).
+		now := time.Now()
+		if syncDeleteRequest || (now.Sub(pending.lastPolicyCycle) > m.policyLoopInterval && !now.Before(pending.backoffUntil)) {
 			// Pass the state to the pluggable policy engine to potentially perform more actions against it,
 			// such as submitting for the first time, or raising the gas etc.
 			var reason ffcapi.ErrorReason
-			update, reason, err = m.policyEngine.Execute(ctx, m.connector, pending.mtx)
+			lastSubmit, gasPrice := mtx.LastSubmit, mtx.GasPrice.String()
+			var cancelLastSubmit *fftypes.FFTime
+			if mtx.Cancel != nil {
+				cancelLastSubmit = mtx.Cancel.LastSubmit
+			}
+			var pe policyengine.PolicyEngine
+			var connector ffcapi.API
+			pe, err = m.getPolicyEngine(ctx, mtx.Connector, mtx.PolicyEngine)
+			if err == nil {
+				// A transaction restored after a restart might name a connector that is no longer configured
+				connector, err = m.getConnector(ctx, mtx.Connector)
+			}
+			if err == nil {
+				if m.dryRun {
+					completed, update, reason, err = m.execPolicyDryRun(ctx, pe, connector, mtx)
+				} else {
+					peCtx, span := m.tracer.Start(ctx, "policyEngine.execute", trace.WithAttributes(attribute.String("fftm.policyEngine", mtx.PolicyEngine)))
+					update, reason, err = pe.Execute(peCtx, connector, pending.mtx)
+					endSpan(span, err)
+					m.recordPolicyActions(mtx, lastSubmit, gasPrice, cancelLastSubmit)
+				}
+			}
 			if err != nil {
 				log.L(ctx).Errorf("Policy engine returned error for transaction %s reason=%s: %s", mtx.ID, reason, err)
 				m.addError(mtx, reason, err)
+				if update == policyengine.UpdateFailed {
+					// The policy engine has determined the transaction cannot succeed, such as when a simulation reverts
+					update = policyengine.UpdateYes
+					completed = true
+					mtx.Status = apitypes.TxStatusFailed
+					m.setFailure(mtx, reason, apitypes.TxFailureUnknown)
+					log.L(ctx).Warnf("Transaction %s at nonce %s / %d failed: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.ErrorMessage)
+					m.addHistory(mtx, apitypes.TxActionFailed, mtx.ErrorMessage)
+					m.untrackSubmittedTransaction(ctx, pending)
+					err = nil
+				} else if m.revertRetryMaxAttempts > 0 && (reason == ffcapi.ErrorReasonTransactionReverted || reason == ffcapi.ErrorReasonInvalidInputs) {
+					// Reverts are retried on their own schedule, rather than with the backoff for other errors
+					if m.checkRevertRetry(ctx, pending, reason, err) {
+						update = policyengine.UpdateYes
+						completed = true
+						m.untrackSubmittedTransaction(ctx, pending)
+						err = nil
+					}
+				} else {
+					m.addHistory(mtx, apitypes.TxActionPolicyError, err.Error())
+					pending.failedCycles++
+					if pending.failedCycles == 1 {
+						pending.firstFailure = now
+					}
+					if m.retryLimitReached(mtx, pending) {
+						update = policyengine.UpdateYes
+						completed = true
+						mtx.Status = apitypes.TxStatusFailed
+						mtx.ErrorMessage = i18n.NewError(ctx, tmmsgs.MsgPolicyRetriesExhausted, pending.failedCycles, time.Since(pending.firstFailure).Round(time.Millisecond), err).Error()
+						m.setFailure(mtx, reason, apitypes.TxFailureRetriesExhausted)
+						log.L(ctx).Warnf("Transaction %s at nonce %s / %d failed: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.ErrorMessage)
+						m.addHistory(mtx, apitypes.TxActionRetriesExhausted, mtx.ErrorMessage)
+						m.untrackSubmittedTransaction(ctx, pending)
+						err = nil
+					} else {
+						pending.backoffUntil = time.Now().Add(m.failureBackoff(pending.failedCycles))
+					}
+				}
 			} else {
+				pending.failedCycles = 0
+				pending.revertRetries = 0
+				pending.firstFailure = time.Time{}
+				pending.backoffUntil = time.Time{}
+				// The policy engine might have recorded warnings in the error history
+				if len(mtx.ErrorHistory) > m.errorHistoryCount {
+					mtx.ErrorHistory = mtx.ErrorHistory[0:m.errorHistoryCount]
+				}
 				if mtx.FirstSubmit != nil && pending.trackingTransactionHash != mtx.TransactionHash {
 					// If now submitted, add to confirmations manager for receipt checking
 					m.trackSubmittedTransaction(ctx, pending)
 				}
+				if mtx.Cancel != nil && mtx.Cancel.TransactionHash != "" && pending.trackingCancelHash != mtx.Cancel.TransactionHash {
+					// Race the no-op replacement against the original for the same nonce
+					m.trackCancelTransaction(ctx, pending)
+				}
 				pending.lastPolicyCycle = time.Now()
 			}
 		}
@@ -256,17 +1076,37 @@ func (m *manager) execPolicy(ctx context.Context, pending *pendingState, syncDel
 				return err
 			}
 			if completed {
+				m.untrackCancelTransaction(ctx, pending)
 				pending.remove = true // for the next time round the loop
+				endTransactionSpan(pending)
 				m.markInflightStale()
+				m.notifyTransactionWaiters(mtx.ID)
+				m.deliverCompletionCallback(mtx)
 			}
 		case policyengine.UpdateDelete:
-			err := m.persistence.DeleteTransaction(ctx, mtx.ID)
+			var err error
+			if m.deletedRetention > 0 && mtx.Status != apitypes.TxStatusDeleted {
+				// The transaction is retained so it can be restored, until the reaper removes it.
+				// Deleting a transaction that is already deleted removes it immediately.
+				m.mux.Lock()
+				mtx.DeletedStatus = mtx.Status
+				mtx.Status = apitypes.TxStatusDeleted
+				mtx.Deleted = fftypes.Now()
+				mtx.Updated = mtx.Deleted
+				m.addHistory(mtx, apitypes.TxActionDeleted, "")
+				m.mux.Unlock()
+				err = m.persistence.WriteTransaction(ctx, mtx, false)
+			} else {
+				err = m.persistence.DeleteTransaction(ctx, mtx.ID)
+			}
 			if err != nil {
 				log.L(ctx).Errorf("Failed to delete transaction %s (status=%s): %s", mtx.ID, mtx.Status, err)
 				return err
 			}
 			pending.remove = true // for the next time round the loop
+			endTransactionSpan(pending)
 			m.markInflightStale()
+			m.notifyTransactionWaiters(mtx.ID)
 		}
 		m.sendWSReply(mtx)
 	}
@@ -293,12 +1133,22 @@ func (m *manager) sendWSReply(mtx *apitypes.ManagedTX) {
 	m.wsServer.SendReply(wsr)
 }
 
+// txConfirmationRequirement returns the requirement to track a transaction with. The strategy was validated
+// on submission, so this only fails if the connector has since stopped supporting it.
+func (m *manager) txConfirmationRequirement(ctx context.Context, mtx *apitypes.ManagedTX) *confirmations.Requirement {
+	required, err := m.confirmationRequirement(ctx, mtx.ConfirmationStrategy)
+	if err != nil {
+		log.L(ctx).Warnf("Tracking transaction %s with the default confirmations: %s", mtx.ID, err)
+	}
+	return required
+}
+
 func (m *manager) trackSubmittedTransaction(ctx context.Context, pending *pendingState) {
 	var err error
 
 	// Clear any old transaction hash
 	if pending.trackingTransactionHash != "" {
-		err = m.confirmations.Notify(&confirmations.Notification{
+		err = m.confirmationsFor(pending.mtx.Connector).Notify(&confirmations.Notification{
 			NotificationType: confirmations.RemovedTransaction,
 			Transaction: &confirmations.TransactionInfo{
 				TransactionHash: pending.trackingTransactionHash,
@@ -308,16 +1158,25 @@ func (m *manager) trackSubmittedTransaction(ctx context.Context, pending *pendin
 
 	// Notify of the new
 	if err == nil {
-		err = m.confirmations.Notify(&confirmations.Notification{
+		var milestone func(ctx context.Context, confirmations []confirmations.BlockInfo)
+		if pending.mtx.ConfirmationWebhook != "" {
+			milestone = func(ctx context.Context, confirmations []confirmations.BlockInfo) {
+				m.deliverConfirmationMilestone(pending, confirmations)
+			}
+		}
+		err = m.confirmationsFor(pending.mtx.Connector).Notify(&confirmations.Notification{
 			NotificationType: confirmations.NewTransaction,
 			Transaction: &confirmations.TransactionInfo{
 				TransactionHash: pending.mtx.TransactionHash,
+				Required:        m.txConfirmationRequirement(ctx, pending.mtx),
+				Milestones:      pending.mtx.Milestones,
+				Milestone:       milestone,
 				Receipt: func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse) {
 					// Will be picked up on the next policy loop cycle - guaranteed to occur before Confirmed
 					m.mux.Lock()
 					pending.mtx.Receipt = receipt
 					m.mux.Unlock()
-					log.L(m.ctx).Debugf("Receipt received for transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), pending.mtx.TransactionHash)
+					log.L(txLogContext(m.ctx, pending.mtx)).Debugf("Receipt received for transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), pending.mtx.TransactionHash)
 					m.markInflightUpdate()
 				},
 				Confirmed: func(ctx context.Context, confirmations []confirmations.BlockInfo) {
@@ -326,7 +1185,7 @@ func (m *manager) trackSubmittedTransaction(ctx context.Context, pending *pendin
 					pending.confirmed = true
 					pending.mtx.Confirmations = confirmations
 					m.mux.Unlock()
-					log.L(m.ctx).Debugf("Confirmed transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), pending.mtx.TransactionHash)
+					log.L(txLogContext(m.ctx, pending.mtx)).Debugf("Confirmed transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), pending.mtx.TransactionHash)
 					m.markInflightUpdate()
 				},
 			},
@@ -341,13 +1200,88 @@ func (m *manager) trackSubmittedTransaction(ctx context.Context, pending *pendin
 	}
 }
 
+func (m *manager) untrackSubmittedTransaction(ctx context.Context, pending *pendingState) {
+	if pending.trackingTransactionHash == "" {
+		return
+	}
+	err := m.confirmationsFor(pending.mtx.Connector).Notify(&confirmations.Notification{
+		NotificationType: confirmations.RemovedTransaction,
+		Transaction: &confirmations.TransactionInfo{
+			TransactionHash: pending.trackingTransactionHash,
+		},
+	})
+	if err != nil {
+		log.L(ctx).Infof("Error detected notifying confirmation manager: %s", err)
+	} else {
+		pending.trackingTransactionHash = ""
+	}
+}
+
+// trackCancelTransaction registers the no-op replacement submitted to cancel a transaction with the
+// confirmation manager, so whichever of the two transactions is mined at the nonce decides the outcome
+func (m *manager) trackCancelTransaction(ctx context.Context, pending *pendingState) {
+	m.untrackCancelTransaction(ctx, pending)
+	if pending.trackingCancelHash != "" {
+		return
+	}
+	cancelHash := pending.mtx.Cancel.TransactionHash
+	err := m.confirmationsFor(pending.mtx.Connector).Notify(&confirmations.Notification{
+		NotificationType: confirmations.NewTransaction,
+		Transaction: &confirmations.TransactionInfo{
+			TransactionHash: cancelHash,
+			Required:        m.txConfirmationRequirement(ctx, pending.mtx),
+			Receipt: func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse) {
+				m.mux.Lock()
+				pending.mtx.Cancel.Receipt = receipt
+				m.mux.Unlock()
+				log.L(txLogContext(m.ctx, pending.mtx)).Debugf("Receipt received for cancellation of transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), cancelHash)
+				m.markInflightUpdate()
+			},
+			Confirmed: func(ctx context.Context, confirmations []confirmations.BlockInfo) {
+				m.mux.Lock()
+				pending.cancelConfirmed = true
+				m.mux.Unlock()
+				log.L(txLogContext(m.ctx, pending.mtx)).Debugf("Confirmed cancellation of transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), cancelHash)
+				m.markInflightUpdate()
+			},
+		},
+	})
+	if err != nil {
+		log.L(ctx).Infof("Error detected notifying confirmation manager: %s", err)
+	} else {
+		pending.trackingCancelHash = cancelHash
+	}
+}
+
+func (m *manager) untrackCancelTransaction(ctx context.Context, pending *pendingState) {
+	if pending.trackingCancelHash == "" {
+		return
+	}
+	err := m.confirmationsFor(pending.mtx.Connector).Notify(&confirmations.Notification{
+		NotificationType: confirmations.RemovedTransaction,
+		Transaction: &confirmations.TransactionInfo{
+			TransactionHash: pending.trackingCancelHash,
+		},
+	})
+	if err != nil {
+		log.L(ctx).Infof("Error detected notifying confirmation manager: %s", err)
+	} else {
+		pending.trackingCancelHash = ""
+	}
+}
+
 func (m *manager) policyEngineAPIRequest(ctx context.Context, req *policyEngineAPIRequest) policyEngineAPIResponse {
+	// Only the leader runs the policy loop that processes these requests
+	if err := m.checkLeader(ctx); err != nil {
+		return policyEngineAPIResponse{err: err}
+	}
+	// The request must be complete before it is queued, as the policy loop picks it up straight away
+	req.response = make(chan policyEngineAPIResponse, 1)
+	req.startTime = time.Now()
 	m.mux.Lock()
 	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
 	m.mux.Unlock()
 	m.markInflightUpdate()
-	req.response = make(chan policyEngineAPIResponse, 1)
-	req.startTime = time.Now()
 	select {
 	case res := <-req.response:
 		return res
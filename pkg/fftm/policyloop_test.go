@@ -20,12 +20,17 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
@@ -46,7 +51,7 @@ func sendSampleTX(t *testing.T, m *manager, signer string, nonce int64) *apitype
 	}
 
 	mfc := m.connector.(*ffcapimocks.API)
-	mfc.On("NextNonceForSigner", m.ctx, &ffcapi.NextNonceForSignerRequest{
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{
 		Signer: signer,
 	}).Return(&ffcapi.NextNonceForSignerResponse{
 		Nonce: fftypes.NewFFBigInt(nonce),
@@ -74,7 +79,7 @@ func TestPolicyLoopE2EOk(t *testing.T) {
 	txHash := "0x" + fftypes.NewRandB32().String()
 
 	mfc := m.connector.(*ffcapimocks.API)
-	mfc.On("TransactionSend", m.ctx, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
 		return r.Nonce.Equals(fftypes.NewFFBigInt(12345))
 	})).Return(&ffcapi.TransactionSendResponse{
 		TransactionHash: txHash,
@@ -95,7 +100,7 @@ func TestPolicyLoopE2EOk(t *testing.T) {
 	}).Return(nil)
 
 	// Run the policy once to do the send
-	<-m.inflightStale // from sending the TX
+	waitInflightSignal(t, m, true) // from sending the TX
 	m.policyLoopCycle(m.ctx, true)
 	assert.Equal(t, mtx.ID, m.inflight[0].mtx.ID)
 	assert.Equal(t, apitypes.TxStatusPending, m.inflight[0].mtx.Status)
@@ -103,7 +108,7 @@ func TestPolicyLoopE2EOk(t *testing.T) {
 	// A second time will mark it complete for flush
 	m.policyLoopCycle(m.ctx, false)
 
-	<-m.inflightStale // policy loop should have marked us stale, to clean up the TX
+	waitInflightSignal(t, m, true) // policy loop should have marked us stale, to clean up the TX
 	m.policyLoopCycle(m.ctx, true)
 	assert.Empty(t, m.inflight)
 
@@ -125,7 +130,7 @@ func TestPolicyLoopE2EReverted(t *testing.T) {
 	txHash := "0x" + fftypes.NewRandB32().String()
 
 	mfc := m.connector.(*ffcapimocks.API)
-	mfc.On("TransactionSend", m.ctx, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
 		return r.Nonce.Equals(fftypes.NewFFBigInt(12345))
 	})).Return(&ffcapi.TransactionSendResponse{
 		TransactionHash: txHash,
@@ -146,7 +151,7 @@ func TestPolicyLoopE2EReverted(t *testing.T) {
 	}).Return(nil)
 
 	// Run the policy once to do the send
-	<-m.inflightStale // from sending the TX
+	waitInflightSignal(t, m, true) // from sending the TX
 	m.policyLoopCycle(m.ctx, true)
 	assert.Equal(t, mtx.ID, m.inflight[0].mtx.ID)
 	assert.Equal(t, apitypes.TxStatusPending, m.inflight[0].mtx.Status)
@@ -154,7 +159,7 @@ func TestPolicyLoopE2EReverted(t *testing.T) {
 	// A second time will mark it complete for flush
 	m.policyLoopCycle(m.ctx, false)
 
-	<-m.inflightStale // policy loop should have marked us stale, to clean up the TX
+	waitInflightSignal(t, m, true) // policy loop should have marked us stale, to clean up the TX
 	m.policyLoopCycle(m.ctx, true)
 	assert.Empty(t, m.inflight)
 
@@ -162,6 +167,9 @@ func TestPolicyLoopE2EReverted(t *testing.T) {
 	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Equal(t, apitypes.TxFailureReverted, rtx.Failure.Code)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, rtx.Failure.Reason)
+	assert.Regexp(t, "FF21066", rtx.Failure.Error)
 
 	mc.AssertExpectations(t)
 	mfc.AssertExpectations(t)
@@ -217,7 +225,7 @@ func TestPolicyLoopResubmitNewTXID(t *testing.T) {
 	}).Return(nil)
 
 	// Run the policy once to do the send with the first hash
-	<-m.inflightStale // from sending the TX
+	waitInflightSignal(t, m, true) // from sending the TX
 	m.policyLoopCycle(m.ctx, true)
 	assert.Len(t, m.inflight, 1)
 	assert.Equal(t, mtx.ID, m.inflight[0].mtx.ID)
@@ -268,7 +276,7 @@ func TestInflightSetListFailCancel(t *testing.T) {
 	close()
 
 	mp := m.persistence.(*persistencemocks.Persistence)
-	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), m.maxInFlight, persistence.SortDirectionAscending).
+	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), m.priorityWindow, persistence.SortDirectionAscending).
 		Return(nil, fmt.Errorf("pop"))
 
 	m.policyLoopCycle(m.ctx, true)
@@ -492,3 +500,2032 @@ func TestBadPolicyAPITimeout(t *testing.T) {
 	assert.Regexp(t, "FF21068", res.err)
 
 }
+
+func TestExecPolicyTrimsWarningHistory(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.errorHistoryCount = 2
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mtx := args[2].(*apitypes.ManagedTX)
+		for i := 0; i < 3; i++ {
+			mtx.ErrorHistory = append([]*apitypes.ManagedTXError{{Time: fftypes.Now(), Error: fmt.Sprintf("warning%d", i)}}, mtx.ErrorHistory...)
+		}
+	}).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	err := m.execPolicy(m.ctx, &pendingState{mtx: tx}, false)
+	assert.NoError(t, err)
+	assert.Len(t, tx.ErrorHistory, 2)
+	assert.Equal(t, "warning2", tx.ErrorHistory[0].Error)
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestFailureBackoffGrowsWithJitter(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.backoff.InitialDelay = 100 * time.Millisecond
+	m.backoff.MaximumDelay = 1 * time.Second
+	m.backoff.Factor = 0 // defaults to 2
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		1 * time.Second, // capped
+		1 * time.Second,
+	}
+	for i, max := range expected {
+		delay := m.failureBackoff(i + 1)
+		assert.GreaterOrEqual(t, delay, max/2)
+		assert.LessOrEqual(t, delay, max)
+	}
+
+}
+
+func TestExecPolicyBackoffOnFailureResetOnSuccess(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.backoff.InitialDelay = 1 * time.Hour
+	m.backoff.MaximumDelay = 100 * time.Hour
+
+	tx1 := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx2 := genTestTxn("0xabcd1234", 12346, apitypes.TxStatusPending)
+	pending1 := &pendingState{mtx: tx1}
+	pending2 := &pendingState{mtx: tx2}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx1).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Twice()
+	mpe.On("Execute", mock.Anything, mock.Anything, tx1).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+	mpe.On("Execute", mock.Anything, mock.Anything, tx2).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	// First failure starts the backoff
+	err := m.execPolicy(m.ctx, pending1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pending1.failedCycles)
+	firstDelay := time.Until(pending1.backoffUntil)
+	assert.Greater(t, firstDelay, 29*time.Minute)
+
+	// The policy engine is not called again for this transaction during the backoff,
+	// but other in-flight transactions are unaffected
+	err = m.execPolicy(m.ctx, pending1, false)
+	assert.NoError(t, err)
+	err = m.execPolicy(m.ctx, pending2, false)
+	assert.NoError(t, err)
+	mpe.AssertNumberOfCalls(t, "Execute", 2)
+
+	// Second failure grows the backoff
+	pending1.backoffUntil = time.Now().Add(-1 * time.Second)
+	err = m.execPolicy(m.ctx, pending1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, pending1.failedCycles)
+	assert.Greater(t, time.Until(pending1.backoffUntil), 59*time.Minute)
+
+	// Success resets the backoff
+	pending1.backoffUntil = time.Now().Add(-1 * time.Second)
+	err = m.execPolicy(m.ctx, pending1, false)
+	assert.NoError(t, err)
+	assert.Zero(t, pending1.failedCycles)
+	assert.True(t, pending1.backoffUntil.IsZero())
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyRetryLimitMarksFailed(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.backoff.InitialDelay = 0
+	m.submitRetryLimit = retryLimit{maxAttempts: 3}
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.Status == apitypes.TxStatusFailed
+	}), false).Return(nil).Once()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Times(3)
+
+	for i := 1; i <= 3; i++ {
+		err := m.execPolicy(m.ctx, pending, false)
+		assert.NoError(t, err)
+		assert.Equal(t, i, pending.failedCycles)
+	}
+
+	// The loop gives up on the configured attempt, and the transaction is failed
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusFailed, tx.Status)
+	assert.Regexp(t, "FF21106.*3.*pop", tx.ErrorMessage)
+	assert.Equal(t, apitypes.TxFailureRetriesExhausted, tx.Failure.Code)
+	assert.Equal(t, apitypes.TxActionRetriesExhausted, tx.History[len(tx.History)-1].Action)
+
+	mpe.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecPolicyRetryLimitPerOperation(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.backoff.InitialDelay = 0
+	m.submitRetryLimit = retryLimit{maxAttempts: 1}
+	m.resubmitRetryLimit = retryLimit{maxAttempts: 2}
+	mc := &confirmationsmocks.Manager{}
+	m.confirmations = mc
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction
+	})).Return(nil).Once()
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil).Once()
+
+	// Already submitted, so the resubmit limit applies
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx.FirstSubmit = fftypes.Now()
+	tx.TransactionHash = "0x12345"
+	pending := &pendingState{mtx: tx, trackingTransactionHash: "0x12345"}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("pop")).Twice()
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusFailed, tx.Status)
+	// The reason from the connector is more specific than the limit being reached
+	assert.Equal(t, apitypes.TxFailureUnderpriced, tx.Failure.Code)
+	assert.Empty(t, pending.trackingTransactionHash)
+
+	mpe.AssertExpectations(t)
+	mp.AssertExpectations(t)
+	mc.AssertExpectations(t)
+
+}
+
+func TestExecPolicyRetryLimitElapsed(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.backoff.InitialDelay = 0
+	m.submitRetryLimit = retryLimit{maxElapsed: 1 * time.Hour}
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil).Once()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Twice()
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+
+	pending.firstFailure = time.Now().Add(-2 * time.Hour)
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxFailureRetriesExhausted, tx.Failure.Code)
+
+	mpe.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecPolicyRevertRetrySucceeds(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.revertRetryMaxAttempts = 2
+	m.revertRetryDelay = 1 * time.Hour
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("reverted")).Twice()
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+
+	// The first revert schedules a retry after the delay
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pending.revertRetries)
+	assert.Zero(t, pending.failedCycles)
+	assert.Greater(t, time.Until(pending.backoffUntil), 59*time.Minute)
+	assert.Equal(t, apitypes.TxActionRevertRetry, tx.History[len(tx.History)-1].Action)
+	assert.Equal(t, "1/2: reverted", tx.History[len(tx.History)-1].Info)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, tx.ErrorHistory[0].Mapped)
+
+	// Nothing happens during the delay
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	mpe.AssertNumberOfCalls(t, "Execute", 1)
+
+	pending.backoffUntil = time.Now().Add(-1 * time.Second)
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, pending.revertRetries)
+	assert.Equal(t, "2/2: reverted", tx.History[len(tx.History)-1].Info)
+
+	// The state the transaction relied on is now available, so the retry succeeds
+	pending.backoffUntil = time.Now().Add(-1 * time.Second)
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Zero(t, pending.revertRetries)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+	assert.Nil(t, tx.Failure)
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyRevertRetryExhausted(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.revertRetryMaxAttempts = 1
+	m.revertRetryDelay = 0
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.Status == apitypes.TxStatusFailed
+	}), false).Return(nil).Once()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("reverted")).Twice()
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusFailed, tx.Status)
+	assert.Regexp(t, "FF21129.*2.*reverted", tx.ErrorMessage)
+	assert.Equal(t, apitypes.TxFailureReverted, tx.Failure.Code)
+	assert.Equal(t, apitypes.TxActionRevertRetry, tx.History[len(tx.History)-2].Action)
+	assert.Equal(t, apitypes.TxActionFailed, tx.History[len(tx.History)-1].Action)
+
+	mpe.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecPolicyRevertRetryInvalidInputsFailsImmediately(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.revertRetryMaxAttempts = 5
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil).Once()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReasonInvalidInputs, fmt.Errorf("bad input")).Once()
+
+	// A deterministic revert is not retried
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusFailed, tx.Status)
+	assert.Regexp(t, "FF21130.*bad input", tx.ErrorMessage)
+	assert.Equal(t, apitypes.TxFailureInvalidInputs, tx.Failure.Code)
+	for _, h := range tx.History {
+		assert.NotEqual(t, apitypes.TxActionRevertRetry, h.Action)
+	}
+
+	mpe.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecPolicyRevertRetryDisabled(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	assert.Zero(t, m.revertRetryMaxAttempts)
+	assert.Equal(t, 5*time.Second, m.revertRetryDelay)
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReasonInvalidInputs, fmt.Errorf("bad input")).Once()
+
+	// Reverts follow the backoff for any other error
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+	assert.Equal(t, 1, pending.failedCycles)
+	assert.Zero(t, pending.revertRetries)
+	assert.Equal(t, apitypes.TxActionPolicyError, tx.History[len(tx.History)-1].Action)
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyRetryLimitResetByConnectorOutage(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.backoff.InitialDelay = 0
+	m.submitRetryLimit = retryLimit{maxAttempts: 2}
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Twice()
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pending.failedCycles)
+
+	// Errors before the outage was detected do not count towards the limit
+	m.connectorHealthy = false
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Zero(t, pending.failedCycles)
+
+	m.connectorHealthy = true
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pending.failedCycles)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestRetryLimitConfig(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.PolicyLoopBackoffMaxAttempts, 5)
+	config.Set(tmconfig.PolicyLoopBackoffMaxElapsed, "1h")
+	config.Set(tmconfig.PolicyLoopBackoffResubmitMaxAttempts, 10)
+
+	assert.Equal(t, retryLimit{maxAttempts: 5, maxElapsed: 1 * time.Hour},
+		retryLimitConfig(tmconfig.PolicyLoopBackoffSubmitMaxAttempts, tmconfig.PolicyLoopBackoffSubmitMaxElapsed))
+	assert.Equal(t, retryLimit{maxAttempts: 10, maxElapsed: 1 * time.Hour},
+		retryLimitConfig(tmconfig.PolicyLoopBackoffResubmitMaxAttempts, tmconfig.PolicyLoopBackoffResubmitMaxElapsed))
+
+}
+
+func TestExecPolicyRecordsHistory(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.backoff.InitialDelay = 0
+	mc := &confirmationsmocks.Manager{}
+	m.confirmations = mc
+	mc.On("Notify", mock.Anything).Return(nil)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil)
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	submit := func(hash string, gasPrice string) func(args mock.Arguments) {
+		return func(args mock.Arguments) {
+			mtx := args[2].(*apitypes.ManagedTX)
+			mtx.GasPrice = fftypes.JSONAnyPtr(gasPrice)
+			mtx.TransactionHash = hash
+			mtx.LastSubmit = fftypes.Now()
+			if mtx.FirstSubmit == nil {
+				mtx.FirstSubmit = mtx.LastSubmit
+			}
+		}
+	}
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Run(submit("0x111", "100")).Return(policyengine.UpdateYes, ffcapi.ErrorReason(""), nil).Once()
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Run(submit("0x222", "200")).Return(policyengine.UpdateYes, ffcapi.ErrorReason(""), nil).Once()
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx}
+	for i := 0; i < 4; i++ {
+		pending.backoffUntil = time.Time{}
+		err := m.execPolicy(m.ctx, pending, false)
+		assert.NoError(t, err)
+	}
+
+	pending.confirmed = true
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+
+	actions := make([]apitypes.TxAction, len(tx.History))
+	for i, h := range tx.History {
+		actions[i] = h.Action
+	}
+	assert.Equal(t, []apitypes.TxAction{
+		apitypes.TxActionSubmitted,
+		apitypes.TxActionGasPriceChanged,
+		apitypes.TxActionResubmitted,
+		apitypes.TxActionPolicyError,
+		apitypes.TxActionConfirmed,
+	}, actions)
+	assert.Equal(t, "0x111", tx.History[0].Info)
+	assert.Equal(t, "200", tx.History[1].Info)
+	assert.Equal(t, "0x222", tx.History[2].Info)
+	assert.Equal(t, "pop", tx.History[3].Info)
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyRecordsHistoryFailedAndDeleted(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil)
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateYes, ffcapi.ErrorReason(""), nil)
+
+	tx1 := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx1.Receipt = &ffcapi.TransactionReceiptResponse{Success: false}
+	err := m.execPolicy(m.ctx, &pendingState{mtx: tx1, confirmed: true}, false)
+	assert.NoError(t, err)
+	assert.Len(t, tx1.History, 1)
+	assert.Equal(t, apitypes.TxActionFailed, tx1.History[0].Action)
+	assert.Regexp(t, "FF21066", tx1.History[0].Info)
+
+	tx2 := genTestTxn("0xabcd1234", 12346, apitypes.TxStatusPending)
+	err = m.execPolicy(m.ctx, &pendingState{mtx: tx2}, true)
+	assert.NoError(t, err)
+	assert.Len(t, tx2.History, 1)
+	assert.Equal(t, apitypes.TxActionDeleteRequested, tx2.History[0].Action)
+
+}
+
+func TestAddHistoryCollapsesToSummary(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.maxHistoryCount = 3
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	for i := 0; i < 3; i++ {
+		m.addHistory(tx, apitypes.TxActionResubmitted, fmt.Sprintf("0x%d", i))
+	}
+	assert.Len(t, tx.History, 3)
+	firstTime := tx.History[0].Time
+
+	m.addHistory(tx, apitypes.TxActionResubmitted, "0x3")
+	assert.Len(t, tx.History, 3)
+	assert.Equal(t, apitypes.TxActionHistorySummary, tx.History[0].Action)
+	assert.Equal(t, 2, tx.History[0].Count)
+	assert.Equal(t, firstTime, tx.History[0].Time)
+	assert.Equal(t, "0x2", tx.History[1].Info)
+	assert.Equal(t, "0x3", tx.History[2].Info)
+
+	// The summary accumulates further collapsed entries
+	m.addHistory(tx, apitypes.TxActionResubmitted, "0x4")
+	assert.Len(t, tx.History, 3)
+	assert.Equal(t, apitypes.TxActionHistorySummary, tx.History[0].Action)
+	assert.Equal(t, 3, tx.History[0].Count)
+	assert.Equal(t, "0x3", tx.History[1].Info)
+	assert.Equal(t, "0x4", tx.History[2].Info)
+
+}
+
+func TestPolicyLoopDispatchesToSelectedEngine(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil)
+
+	executed := func(mpe *policyenginemocks.PolicyEngine) chan string {
+		ids := make(chan string, 1)
+		mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			select {
+			case ids <- args[2].(*apitypes.ManagedTX).ID:
+			default:
+			}
+		}).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+		return ids
+	}
+	mpe1 := &policyenginemocks.PolicyEngine{}
+	mpe2 := &policyenginemocks.PolicyEngine{}
+	executed1 := executed(mpe1)
+	executed2 := executed(mpe2)
+	m.policyEngine = mpe1
+	m.policyEngines = map[string]policyengine.PolicyEngine{
+		"engine1": mpe1,
+		"engine2": mpe2,
+	}
+
+	// Separate signers, so neither transaction waits for the other to be submitted
+	sendTX := func(id, signer, policyEngine string) {
+		mtx, err := m.sendManagedTransaction(context.Background(), &apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{
+				ID:           id,
+				PolicyEngine: policyEngine,
+			},
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{
+					From: signer,
+				},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, policyEngine, mtx.PolicyEngine)
+	}
+	sendTX("tx1", "0xaaaaa", "engine1")
+	sendTX("tx2", "0xbbbbb", "engine2")
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "tx1", <-executed1)
+	assert.Equal(t, "tx2", <-executed2)
+	mpe1.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.ID == "tx2"
+	}))
+	mpe2.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.ID == "tx1"
+	}))
+
+}
+
+func TestExecPolicyEngineNotConfigured(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx.PolicyEngine = "removed"
+	pending := &pendingState{mtx: tx}
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Regexp(t, "FF21078.*removed", tx.ErrorMessage)
+	assert.Equal(t, 1, pending.failedCycles)
+
+}
+
+func TestPolicyLoopDryRunInterceptsSubmission(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.dryRun = true
+
+	mtx := sendSampleTX(t, m, "0xaaaaa", 12345)
+
+	// Run the policy once, which would do the send if we were not in dry-run mode
+	waitInflightSignal(t, m, true) // from sending the TX
+	m.policyLoopCycle(m.ctx, true)
+
+	waitInflightSignal(t, m, true) // policy loop should have marked us stale, as the TX leaves the in-flight set
+	m.policyLoopCycle(m.ctx, true)
+	assert.Empty(t, m.inflight)
+
+	// Check the intended submission is persisted, without anything being sent
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusWouldSubmit, rtx.Status)
+	assert.NotNil(t, rtx.GasPrice)
+	assert.Nil(t, rtx.FirstSubmit)
+	assert.Empty(t, rtx.TransactionHash)
+	assert.Len(t, rtx.History, 1)
+	assert.Equal(t, apitypes.TxActionWouldSubmit, rtx.History[0].Action)
+	assert.Equal(t, fmt.Sprintf("gas=%s gasPrice=%s", mtx.Gas, rtx.GasPrice), rtx.History[0].Info)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.AssertNotCalled(t, "TransactionSend", mock.Anything, mock.Anything)
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.AssertNotCalled(t, "Notify", mock.Anything)
+}
+
+func TestExecPolicyDryRunInterceptsRawSubmission(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.dryRun = true
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil)
+
+	tx1 := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx1.RawTransaction = "0xf86c0a8502540be400"
+	pending := &pendingState{mtx: tx1}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx1).Run(func(args mock.Arguments) {
+		rawAPI, ok := args[1].(ffcapi.RawTransactionAPI)
+		assert.True(t, ok)
+		res, _, err := rawAPI.TransactionSendRaw(m.ctx, &ffcapi.TransactionSendRawRequest{RawTransaction: tx1.RawTransaction})
+		assert.NoError(t, err)
+		tx1.TransactionHash = res.TransactionHash
+		tx1.FirstSubmit = fftypes.Now()
+	}).Return(policyengine.UpdateYes, ffcapi.ErrorReason(""), nil)
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusWouldSubmit, tx1.Status)
+	assert.Nil(t, tx1.FirstSubmit)
+	assert.Len(t, tx1.History, 1)
+	assert.Equal(t, apitypes.TxActionWouldSubmit, tx1.History[0].Action)
+	assert.Equal(t, "raw transaction", tx1.History[0].Info)
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyDryRunNoSubmission(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.dryRun = true
+
+	tx1 := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx1}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx1).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx1.Status)
+	assert.Empty(t, tx1.History)
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyDryRunSimulationPassesThrough(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.dryRun = true
+	fc, _ := newTestFullConnector()
+	m.connector = fc
+	fc.SimulationAPI.On("TransactionSimulate", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSimulateResponse{}, ffcapi.ErrorReason(""), nil).Once()
+
+	tx1 := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx1}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx1).Run(func(args mock.Arguments) {
+		simulationAPI, ok := args[1].(ffcapi.SimulationAPI)
+		assert.True(t, ok)
+		_, _, err := simulationAPI.TransactionSimulate(m.ctx, &ffcapi.TransactionSimulateRequest{})
+		assert.NoError(t, err)
+	}).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+
+	// A connector without simulation support
+	m.connector = &ffcapimocks.API{}
+	mpe.On("Execute", mock.Anything, mock.Anything, tx1).Run(func(args mock.Arguments) {
+		_, _, err := args[1].(ffcapi.SimulationAPI).TransactionSimulate(m.ctx, &ffcapi.TransactionSimulateRequest{})
+		assert.Regexp(t, "FF21126.*SimulationAPI", err)
+	}).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+	pending.lastPolicyCycle = time.Time{}
+
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+
+	mpe.AssertExpectations(t)
+	fc.SimulationAPI.AssertExpectations(t)
+
+}
+
+func TestExecPolicyUpdateFailed(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.revertRetryMaxAttempts = 3
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil)
+
+	tx1 := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	pending := &pendingState{mtx: tx1}
+
+	// The transaction is failed without retry, even though reverts are normally retried
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx1).
+		Return(policyengine.UpdateFailed, ffcapi.ErrorReasonTransactionReverted, i18n.NewError(m.ctx, tmmsgs.MsgSimulationReverted, "execution reverted: Not allowed")).
+		Once()
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusFailed, tx1.Status)
+	assert.Regexp(t, "FF21187.*Not allowed", tx1.ErrorMessage)
+	assert.Equal(t, apitypes.TxFailureReverted, tx1.Failure.Code)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, tx1.Failure.Reason)
+	assert.Nil(t, tx1.FirstSubmit)
+	assert.Equal(t, apitypes.TxActionFailed, tx1.History[len(tx1.History)-1].Action)
+	assert.Zero(t, pending.revertRetries)
+
+	mpe.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecPolicySubmissionTimeoutExpires(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.submissionTimeout = 1 * time.Hour
+	mc := &confirmationsmocks.Manager{}
+	m.confirmations = mc
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction && n.Transaction.TransactionHash == "0x111"
+	})).Return(nil)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil)
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	submitted := fftypes.FFTime(time.Now().Add(-2 * time.Hour))
+	tx.FirstSubmit = &submitted
+	tx.TransactionHash = "0x111"
+	pending := &pendingState{mtx: tx, trackingTransactionHash: "0x111"}
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, apitypes.TxStatusFailed, tx.Status)
+	assert.Regexp(t, "FF21085.*1h0m0s", tx.ErrorMessage)
+	assert.Equal(t, apitypes.TxActionSubmissionTimeout, tx.History[len(tx.History)-1].Action)
+	assert.True(t, pending.remove)
+	assert.Empty(t, pending.trackingTransactionHash)
+
+	mpe.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything)
+	mc.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecPolicySubmissionTimeoutNotExpired(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.submissionTimeout = 1 * time.Hour
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	longAgo := fftypes.FFTime(time.Now().Add(-2 * time.Hour))
+	recently := fftypes.FFTime(time.Now().Add(-10 * time.Minute))
+	zero := fftypes.FFDuration(0)
+	longer := fftypes.FFDuration(3 * time.Hour)
+
+	// A gas price change restarts the window
+	tx1 := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx1.FirstSubmit = &longAgo
+	tx1.History = []*apitypes.TxHistoryEntry{
+		{Time: &longAgo, Action: apitypes.TxActionSubmitted},
+		{Time: &recently, Action: apitypes.TxActionGasPriceChanged},
+	}
+
+	// The timeout can be disabled, or extended, for individual transactions
+	tx2 := genTestTxn("0xabcd1234", 12346, apitypes.TxStatusPending)
+	tx2.FirstSubmit = &longAgo
+	tx2.SubmissionTimeout = &zero
+	tx3 := genTestTxn("0xabcd1234", 12347, apitypes.TxStatusPending)
+	tx3.FirstSubmit = &longAgo
+	tx3.SubmissionTimeout = &longer
+
+	// A receipt has arrived, but the transaction is not yet confirmed
+	tx4 := genTestTxn("0xabcd1234", 12348, apitypes.TxStatusPending)
+	tx4.FirstSubmit = &longAgo
+	tx4.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+
+	for _, tx := range []*apitypes.ManagedTX{tx1, tx2, tx3, tx4} {
+		pending := &pendingState{mtx: tx}
+		err := m.execPolicy(m.ctx, pending, false)
+		assert.NoError(t, err)
+		assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+		assert.False(t, pending.remove)
+	}
+
+	mpe.AssertNumberOfCalls(t, "Execute", 4)
+
+}
+
+func TestPolicyLoopSubmissionTimeoutLateConfirmation(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.submissionTimeout = 1 * time.Hour
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil)
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	// Mined just before the timeout would have expired, and confirmed after it
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	submitted := fftypes.FFTime(time.Now().Add(-2 * time.Hour))
+	tx.FirstSubmit = &submitted
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+	pending := &pendingState{mtx: tx, confirmed: true}
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, apitypes.TxStatusSucceeded, tx.Status)
+	assert.Empty(t, tx.ErrorMessage)
+	assert.True(t, pending.remove)
+
+	mp.AssertExpectations(t)
+
+}
+
+func sendScheduledSampleTX(t *testing.T, m *manager, signer string, nonce int64, notBefore time.Time) *apitypes.ManagedTX {
+	nb := fftypes.FFTime(notBefore)
+	return sendSampleTXWithHeaders(t, m, signer, nonce, apitypes.RequestHeaders{NotBefore: &nb})
+}
+
+func sendSampleTXWithHeaders(t *testing.T, m *manager, signer string, nonce int64, headers apitypes.RequestHeaders) *apitypes.ManagedTX {
+
+	txInput := ffcapi.TransactionInput{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: signer,
+		},
+	}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(nonce),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	mtx, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers:          headers,
+		TransactionInput: txInput,
+	})
+	assert.NoError(t, err)
+	return mtx
+}
+
+func TestPolicyLoopScheduledTXNotSubmittedEarly(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendScheduledSampleTX(t, m, "0xaaaaa", 12345, time.Now().Add(1*time.Hour))
+	assert.Equal(t, apitypes.TxStatusScheduled, mtx.Status)
+	assert.NotNil(t, m.nextScheduled)
+
+	// Scheduled transactions do not go into the in-flight set, so nothing is submitted
+	m.policyLoopCycle(m.ctx, true)
+	m.policyLoopCycle(m.ctx, false)
+	assert.Empty(t, m.inflight)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusScheduled, rtx.Status)
+
+	// Shows in listings with the scheduled status
+	txns, err := m.getTransactions(m.ctx, "", "", "", false, string(apitypes.TxStatusScheduled), "", "", "", "", false, nil)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.AssertNotCalled(t, "TransactionSend", mock.Anything, mock.Anything)
+}
+
+func TestPolicyLoopScheduledTXReleased(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendScheduledSampleTX(t, m, "0xaaaaa", 12345, time.Now().Add(50*time.Millisecond))
+	assert.Equal(t, apitypes.TxStatusScheduled, mtx.Status)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x" + fftypes.NewRandB32().String(),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil)
+
+	time.Sleep(100 * time.Millisecond)
+	m.policyLoopCycle(m.ctx, false)
+	assert.Nil(t, m.nextScheduled)
+	assert.Len(t, m.inflight, 1)
+	assert.Equal(t, mtx.ID, m.inflight[0].mtx.ID)
+	assert.Equal(t, apitypes.TxStatusPending, m.inflight[0].mtx.Status)
+	assert.Equal(t, apitypes.TxActionScheduleReached, m.inflight[0].mtx.History[0].Action)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestPolicyLoopScheduledTXRestart(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendScheduledSampleTX(t, m, "0xaaaaa", 12345, time.Now().Add(1*time.Hour))
+
+	// Simulate a restart, where we've lost the in-memory schedule, and the time has since passed
+	m.nextScheduled = nil
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	past := fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	rtx.NotBefore = &past
+	err = m.persistence.WriteTransaction(m.ctx, rtx, false)
+	assert.NoError(t, err)
+
+	m.policyLoopCycle(m.ctx, true)
+	assert.Empty(t, m.inflight)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x" + fftypes.NewRandB32().String(),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil)
+
+	m.markScheduled(time.Time{}) // as performed by Start()
+	m.policyLoopCycle(m.ctx, false)
+	assert.Len(t, m.inflight, 1)
+	assert.Equal(t, apitypes.TxStatusPending, m.inflight[0].mtx.Status)
+}
+
+func TestReleaseScheduledListFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByStatus", m.ctx, apitypes.TxStatusScheduled, "", (*apitypes.ManagedTX)(nil), startupPaginationLimit, persistence.SortDirectionAscending).
+		Return(nil, fmt.Errorf("pop"))
+
+	m.markScheduled(time.Time{})
+	assert.False(t, m.releaseScheduled(m.ctx))
+	assert.NotNil(t, m.nextScheduled)
+
+	mp.AssertExpectations(t)
+}
+
+func TestReleaseScheduledWriteFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	close()
+
+	past := fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	future := fftypes.FFTime(time.Now().Add(1 * time.Hour))
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByStatus", m.ctx, apitypes.TxStatusScheduled, "", (*apitypes.ManagedTX)(nil), startupPaginationLimit, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{
+			{ID: "ns1:" + fftypes.NewUUID().String(), Status: apitypes.TxStatusScheduled, NotBefore: &past, Nonce: fftypes.NewFFBigInt(1)},
+			{ID: "ns1:" + fftypes.NewUUID().String(), Status: apitypes.TxStatusScheduled, NotBefore: &future, Nonce: fftypes.NewFFBigInt(2)},
+		}, nil)
+	mp.On("WriteTransaction", m.ctx, mock.Anything, false).Return(fmt.Errorf("pop"))
+
+	m.markScheduled(time.Time{})
+	assert.False(t, m.releaseScheduled(m.ctx))
+	assert.True(t, m.nextScheduled.Before(time.Time(future)))
+
+	mp.AssertExpectations(t)
+}
+
+func TestExecPolicyFailureCodeFromConnectorError(t *testing.T) {
+
+	for _, tc := range []struct {
+		reason ffcapi.ErrorReason
+		code   apitypes.TxFailureCode
+	}{
+		{ffcapi.ErrorReasonNonceTooLow, apitypes.TxFailureNonceTooLow},
+		{ffcapi.ErrorReasonInsufficientFunds, apitypes.TxFailureInsufficientFunds},
+		{ffcapi.ErrorReasonTransactionReverted, apitypes.TxFailureReverted},
+		{ffcapi.ErrorReasonTransactionUnderpriced, apitypes.TxFailureUnderpriced},
+		{ffcapi.ErrorReasonInvalidInputs, apitypes.TxFailureInvalidInputs},
+		{ffcapi.ErrorReason("something_else"), apitypes.TxFailureSubmissionTimeout},
+		{ffcapi.ErrorReason(""), apitypes.TxFailureSubmissionTimeout},
+	} {
+		_, m, cancel := newTestManagerMockPersistence(t)
+		m.submissionTimeout = 1 * time.Hour
+		mc := &confirmationsmocks.Manager{}
+		m.confirmations = mc
+		mc.On("Notify", mock.Anything).Return(nil)
+		mp := m.persistence.(*persistencemocks.Persistence)
+		mp.On("WriteTransaction", mock.Anything, mock.Anything, false).Return(nil)
+
+		// The connector reports an error when the policy engine attempts a resubmission
+		mpe := &policyenginemocks.PolicyEngine{}
+		m.policyEngine = mpe
+		mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+			Return(policyengine.UpdateNo, tc.reason, fmt.Errorf("pop")).Once()
+
+		tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+		submitted := fftypes.Now()
+		tx.FirstSubmit = submitted
+		pending := &pendingState{mtx: tx}
+		err := m.execPolicy(m.ctx, pending, false)
+		assert.NoError(t, err)
+		assert.Nil(t, tx.Failure)
+
+		// Then the submission window passes, which is terminal
+		longAgo := fftypes.FFTime(time.Now().Add(-2 * time.Hour))
+		tx.FirstSubmit = &longAgo
+		err = m.execPolicy(m.ctx, pending, false)
+		assert.NoError(t, err)
+
+		assert.Equal(t, apitypes.TxStatusFailed, tx.Status)
+		assert.Equal(t, tc.code, tx.Failure.Code, string(tc.reason))
+		assert.Equal(t, tc.reason, tx.Failure.Reason)
+		assert.Regexp(t, "FF21085", tx.Failure.Error)
+
+		mpe.AssertExpectations(t)
+		cancel()
+	}
+
+}
+
+func TestPolicyLoopDependentTXReleasedOnParentSuccess(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+
+	var executed []string
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		executed = append(executed, args[2].(*apitypes.ManagedTX).ID)
+	}).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	// The parent is from another signer, so the child is not also held for nonce ordering
+	parent := newTestTxn(t, m, "0xbbbbb", 12344, apitypes.TxStatusPending)
+	child := sendSampleTXWithHeaders(t, m, "0xaaaaa", 12345, apitypes.RequestHeaders{DependsOn: parent.ID})
+	assert.Equal(t, parent.ID, child.DependsOn)
+
+	// The child is in-flight, but the policy engine is only invoked for the parent
+	m.policyLoopCycle(m.ctx, true)
+	assert.Len(t, m.inflight, 2)
+	m.policyLoopCycle(m.ctx, false)
+	assert.NotContains(t, executed, child.ID)
+	assert.Contains(t, executed, parent.ID)
+
+	// Once the parent succeeds, the child is released to the policy engine
+	parent.Status = apitypes.TxStatusSucceeded
+	err := m.persistence.WriteTransaction(m.ctx, parent, false)
+	assert.NoError(t, err)
+	m.policyLoopCycle(m.ctx, false)
+	assert.Contains(t, executed, child.ID)
+	assert.Equal(t, apitypes.TxStatusPending, child.Status)
+
+}
+
+func TestPolicyLoopDependentTXFailsOnParentFailure(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	noopPolicyEngine(m)
+
+	parent := newTestTxn(t, m, "0xaaaaa", 12344, apitypes.TxStatusFailed)
+	child := sendSampleTXWithHeaders(t, m, "0xaaaaa", 12345, apitypes.RequestHeaders{DependsOn: parent.ID})
+
+	m.policyLoopCycle(m.ctx, true)
+	assert.True(t, m.inflight[0].remove)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, child.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Regexp(t, "FF21094.*"+parent.ID, rtx.ErrorMessage)
+	assert.Equal(t, apitypes.TxFailureDependencyFailed, rtx.Failure.Code)
+	assert.Equal(t, apitypes.TxActionDependencyFailed, rtx.History[len(rtx.History)-1].Action)
+
+	mpe := m.policyEngine.(*policyenginemocks.PolicyEngine)
+	mpe.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything)
+
+}
+
+func TestCheckParentSucceeded(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	close()
+	m.policyLoopInterval = 1 * time.Hour
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, "parent1").Return(nil, fmt.Errorf("pop")).Once()
+	mp.On("GetTransactionByID", m.ctx, "parent1").Return(nil, nil).Once()
+
+	pending := &pendingState{mtx: &apitypes.ManagedTX{ID: "child1", DependsOn: "parent1"}}
+
+	// A query failure leaves the transaction waiting
+	waiting, err := m.checkParentSucceeded(m.ctx, pending)
+	assert.NoError(t, err)
+	assert.True(t, waiting)
+
+	// Within the policy interval we do not query again
+	waiting, err = m.checkParentSucceeded(m.ctx, pending)
+	assert.NoError(t, err)
+	assert.True(t, waiting)
+
+	// A parent that has been deleted fails the child
+	pending.lastPolicyCycle = time.Time{}
+	_, err = m.checkParentSucceeded(m.ctx, pending)
+	assert.Regexp(t, "FF21092", err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func genPriorityTxn(signer string, nonce int64, priority int) *apitypes.ManagedTX {
+	mtx := genTestTxn(signer, nonce, apitypes.TxStatusPending)
+	mtx.Priority = priority
+	return mtx
+}
+
+func TestInflightSetSelectsHigherPriorityFirst(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.maxInFlight = 2
+
+	// In age order
+	txA := genPriorityTxn("0xaaaa", 1, 0)
+	txB := genPriorityTxn("0xbbbb", 1, 5)
+	txC := genPriorityTxn("0xcccc", 1, 10)
+	txD := genPriorityTxn("0xdddd", 1, 5)
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), m.priorityWindow, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{txA, txB, txC, txD}, nil).Once()
+
+	ok := m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 2)
+	assert.Equal(t, txC.ID, m.inflight[0].mtx.ID)
+	assert.Equal(t, txB.ID, m.inflight[1].mtx.ID) // older than txD at the same priority
+
+	// When one completes, the next highest priority that is not already in-flight is selected
+	m.inflight[0].remove = true
+	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), 1+m.priorityWindow, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{txA, txB, txD}, nil).Once()
+
+	ok = m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 2)
+	assert.Equal(t, txB.ID, m.inflight[0].mtx.ID)
+	assert.Equal(t, txD.ID, m.inflight[1].mtx.ID)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSelectByPriorityRespectsNonceOrder(t *testing.T) {
+
+	// In age order
+	tx1 := genPriorityTxn("0xaaaa", 1, 0)
+	tx2 := genPriorityTxn("0xbbbb", 1, 5)
+	tx3 := genPriorityTxn("0xaaaa", 2, 10)
+	tx4 := genPriorityTxn("0xbbbb", 2, 0)
+	tx5 := genPriorityTxn("0xcccc", 1, 1)
+
+	// The urgent tx3 cannot jump ahead of tx1 from the same signer, so tx1 is selected first
+	selected := selectByPriority([]*apitypes.ManagedTX{tx1, tx2, tx3, tx4, tx5}, 4)
+	assert.Equal(t, []*apitypes.ManagedTX{tx1, tx3, tx2, tx5}, selected)
+
+	// Nonce order is used within a signer, even if not in age order
+	selected = selectByPriority([]*apitypes.ManagedTX{tx3, tx1}, 1)
+	assert.Equal(t, []*apitypes.ManagedTX{tx1}, selected)
+
+	// All candidates are selected when there is space
+	selected = selectByPriority([]*apitypes.ManagedTX{tx1, tx2}, 10)
+	assert.Equal(t, []*apitypes.ManagedTX{tx2, tx1}, selected)
+
+}
+
+func recordSubmissionOrder(m *manager, submitNonce func(nonce int64) bool) *[]int64 {
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+	submitted := []int64{}
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mtx := args[2].(*apitypes.ManagedTX)
+		if mtx.FirstSubmit == nil && submitNonce(mtx.Nonce.Int64()) {
+			submitted = append(submitted, mtx.Nonce.Int64())
+			mtx.TransactionHash = fmt.Sprintf("0x%d", mtx.Nonce.Int64())
+			mtx.FirstSubmit = fftypes.Now()
+		}
+	}).Return(policyengine.UpdateYes, ffcapi.ErrorReason(""), nil)
+	return &submitted
+}
+
+func TestPolicyLoopRestoresNonceOrderAfterRestart(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.maxInFlight = 2
+	m.priorityWindow = 1
+
+	// Persisted out of nonce order, so the lowest nonce is outside the window first queried
+	newTestTxn(t, m, "0xaaaaa", 12346, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xaaaaa", 12347, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xaaaaa", 12345, apitypes.TxStatusPending)
+	submitted := recordSubmissionOrder(m, func(nonce int64) bool { return true })
+
+	m.policyLoopCycle(m.ctx, true)
+	assert.True(t, m.inflightRestored)
+	assert.Len(t, m.inflight, 2)
+	assert.Equal(t, []int64{12345, 12346}, *submitted)
+
+}
+
+func TestPolicyLoopHoldsHigherNonceUntilLowerSubmitted(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+
+	newTestTxn(t, m, "0xaaaaa", 12346, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xbbbbb", 500, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xaaaaa", 12345, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xaaaaa", 12347, apitypes.TxStatusPending)
+	blocked := true
+	submitted := recordSubmissionOrder(m, func(nonce int64) bool { return nonce != 12345 || !blocked })
+
+	// The lowest nonce is not submitted, so later nonces from the same signer are held, but other signers are not
+	m.policyLoopCycle(m.ctx, true)
+	assert.Equal(t, []int64{500}, *submitted)
+
+	blocked = false
+	m.policyLoopCycle(m.ctx, false)
+	assert.Equal(t, []int64{500, 12345, 12346, 12347}, *submitted)
+
+}
+
+func TestPolicyLoopNonceOrderingDisabled(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.strictNonceOrdering = false
+	m.maxInFlight = 2
+	m.priorityWindow = 1
+
+	newTestTxn(t, m, "0xaaaaa", 12346, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xaaaaa", 12347, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xaaaaa", 12345, apitypes.TxStatusPending)
+	submitted := recordSubmissionOrder(m, func(nonce int64) bool { return nonce != 12346 })
+
+	m.policyLoopCycle(m.ctx, true)
+	assert.False(t, m.inflightRestored)
+	assert.Equal(t, []int64{12347}, *submitted)
+
+}
+
+func TestOrderInflightByNonceKeepsSignerPositions(t *testing.T) {
+
+	inflight := []*pendingState{
+		{mtx: genTestTxn("0xaaaaa", 3, apitypes.TxStatusPending)},
+		{mtx: genTestTxn("0xbbbbb", 2, apitypes.TxStatusPending)},
+		{mtx: genTestTxn("0xaaaaa", 1, apitypes.TxStatusPending)},
+		{mtx: genTestTxn("0xbbbbb", 1, apitypes.TxStatusPending)},
+		{mtx: genTestTxn("0xaaaaa", 2, apitypes.TxStatusPending)},
+	}
+	orderInflightByNonce(inflight)
+
+	order := make([]string, len(inflight))
+	for i, p := range inflight {
+		order[i] = fmt.Sprintf("%s/%d", p.mtx.TransactionHeaders.From, p.mtx.Nonce.Int64())
+	}
+	assert.Equal(t, []string{"0xaaaaa/1", "0xbbbbb/1", "0xaaaaa/2", "0xbbbbb/2", "0xaaaaa/3"}, order)
+
+}
+
+func TestRestoreNonceOrderListFailCancel(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	close()
+
+	tx1 := genTestTxn("0xaaaaa", 12346, apitypes.TxStatusPending)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), m.priorityWindow, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{tx1}, nil)
+	mp.On("ListTransactionsByStatus", m.ctx, apitypes.TxStatusPending, "0xaaaaa", (*apitypes.ManagedTX)(nil), 1, persistence.SortDirectionAscending).
+		Return(nil, fmt.Errorf("pop"))
+
+	m.policyLoopCycle(m.ctx, true)
+	assert.False(t, m.inflightRestored)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestRestoreNonceOrderTransactionCompleted(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.inflight = []*pendingState{
+		{mtx: genTestTxn("0xaaaaa", 12346, apitypes.TxStatusPending)},
+		{mtx: genTestTxn("0xaaaaa", 12347, apitypes.TxStatusPending)},
+	}
+
+	// One of the transactions completed while we were querying
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByStatus", m.ctx, apitypes.TxStatusPending, "0xaaaaa", (*apitypes.ManagedTX)(nil), 2, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{m.inflight[1].mtx}, nil)
+
+	ok := m.restoreNonceOrder(m.ctx)
+	assert.True(t, ok)
+	assert.Equal(t, int64(12346), m.inflight[0].mtx.Nonce.Int64())
+	assert.Equal(t, int64(12347), m.inflight[1].mtx.Nonce.Int64())
+
+	mp.AssertExpectations(t)
+
+}
+
+func requestTestCancel(t *testing.T, m *manager, txID string) policyEngineAPIResponse {
+	req := &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeCancel,
+		txID:        txID,
+		response:    make(chan policyEngineAPIResponse, 1),
+	}
+	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+	m.processPolicyAPIRequests(m.ctx)
+	return <-req.response
+}
+
+func historyActions(mtx *apitypes.ManagedTX) []apitypes.TxAction {
+	actions := make([]apitypes.TxAction, len(mtx.History))
+	for i, h := range mtx.History {
+		actions[i] = h.Action
+	}
+	return actions
+}
+
+func TestPolicyLoopCancelWins(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendSampleTX(t, m, "0xaaaaa", 12345)
+	txHash := "0x" + fftypes.NewRandB32().String()
+	cancelHash := "0x" + fftypes.NewRandB32().String()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+		return r.To == "" && r.TransactionData == "0xabce1234"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: txHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+		return r.To == "0xaaaaa" && r.TransactionData == "" && r.Nonce.Int64() == 12345
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: cancelHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == txHash
+	})).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == cancelHash
+	})).Run(func(args mock.Arguments) {
+		n := args[0].(*confirmations.Notification)
+		n.Transaction.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+			BlockNumber:      fftypes.NewFFBigInt(12345),
+			TransactionIndex: fftypes.NewFFBigInt(10),
+			BlockHash:        fftypes.NewRandB32().String(),
+			Success:          true,
+		})
+		n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+	}).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction && n.Transaction.TransactionHash == txHash
+	})).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction && n.Transaction.TransactionHash == cancelHash
+	})).Return(nil).Once()
+
+	// Submit the original
+	waitInflightSignal(t, m, true) // from sending the TX
+	m.policyLoopCycle(m.ctx, true)
+	assert.Equal(t, txHash, m.inflight[0].mtx.TransactionHash)
+
+	res := requestTestCancel(t, m, mtx.ID)
+	assert.NoError(t, res.err)
+	assert.Equal(t, http.StatusAccepted, res.status)
+	assert.NotNil(t, res.tx.Cancel.Requested)
+
+	// The no-op is submitted, and confirmed ahead of the original
+	m.policyLoopCycle(m.ctx, false)
+	assert.Equal(t, cancelHash, m.inflight[0].mtx.Cancel.TransactionHash)
+	assert.NotNil(t, m.inflight[0].mtx.Cancel.Receipt)
+
+	// The next cycle marks the transaction cancelled
+	m.policyLoopCycle(m.ctx, false)
+	waitInflightSignal(t, m, true)
+	m.policyLoopCycle(m.ctx, true)
+	assert.Empty(t, m.inflight)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Equal(t, apitypes.TxFailureCancelled, rtx.Failure.Code)
+	assert.Regexp(t, "FF21120.*"+cancelHash, rtx.ErrorMessage)
+	assert.Equal(t, apitypes.CancelOutcomeCancelled, rtx.Cancel.Outcome)
+	assert.Equal(t, txHash, rtx.TransactionHash)
+	assert.Equal(t, []apitypes.TxAction{
+		apitypes.TxActionSubmitted,
+		apitypes.TxActionCancelRequested,
+		apitypes.TxActionCancelSubmitted,
+		apitypes.TxActionCancelled,
+	}, historyActions(rtx))
+
+	mc.AssertExpectations(t)
+	mfc.AssertExpectations(t)
+}
+
+func TestPolicyLoopCancelOriginalWins(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendSampleTX(t, m, "0xaaaaa", 12345)
+	txHash := "0x" + fftypes.NewRandB32().String()
+	cancelHash := "0x" + fftypes.NewRandB32().String()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+		return r.To == ""
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: txHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+		return r.To == "0xaaaaa"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: cancelHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	var original *confirmations.TransactionInfo
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == txHash
+	})).Run(func(args mock.Arguments) {
+		original = args[0].(*confirmations.Notification).Transaction
+	}).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == cancelHash
+	})).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction && n.Transaction.TransactionHash == cancelHash
+	})).Return(nil).Once()
+
+	// Submit the original, then the no-op to cancel it
+	waitInflightSignal(t, m, true) // from sending the TX
+	m.policyLoopCycle(m.ctx, true)
+	res := requestTestCancel(t, m, mtx.ID)
+	assert.NoError(t, res.err)
+	m.policyLoopCycle(m.ctx, false)
+	assert.Equal(t, cancelHash, m.inflight[0].mtx.Cancel.TransactionHash)
+
+	// Requesting again is a no-op
+	res = requestTestCancel(t, m, mtx.ID)
+	assert.NoError(t, res.err)
+	assert.Equal(t, cancelHash, res.tx.Cancel.TransactionHash)
+
+	// The original is mined first
+	original.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+		BlockNumber:      fftypes.NewFFBigInt(12345),
+		TransactionIndex: fftypes.NewFFBigInt(10),
+		BlockHash:        fftypes.NewRandB32().String(),
+		Success:          true,
+	})
+	original.Confirmed(context.Background(), []confirmations.BlockInfo{})
+	m.policyLoopCycle(m.ctx, false)
+	waitInflightSignal(t, m, true)
+	m.policyLoopCycle(m.ctx, true)
+	assert.Empty(t, m.inflight)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusSucceeded, rtx.Status)
+	assert.Equal(t, apitypes.CancelOutcomeConfirmed, rtx.Cancel.Outcome)
+	assert.Equal(t, cancelHash, rtx.Cancel.TransactionHash)
+
+	mc.AssertExpectations(t)
+	mfc.AssertExpectations(t)
+}
+
+func TestRequestCancelWriteFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx.FirstSubmit = fftypes.Now()
+	m.inflight = []*pendingState{{mtx: tx}}
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(fmt.Errorf("pop"))
+
+	res := requestTestCancel(t, m, tx.ID)
+	assert.Regexp(t, "pop", res.err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestTrackCancelTransactionNotifyFail(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(fmt.Errorf("pop"))
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx.Cancel = &apitypes.ManagedTXCancel{TransactionHash: "0x12345"}
+	pending := &pendingState{mtx: tx}
+	m.trackCancelTransaction(m.ctx, pending)
+	assert.Empty(t, pending.trackingCancelHash)
+
+	pending.trackingCancelHash = "0x11111"
+	m.trackCancelTransaction(m.ctx, pending)
+	assert.Equal(t, "0x11111", pending.trackingCancelHash)
+
+}
+
+func TestTrackTransactionConfirmationStrategy(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	fc, _ := newTestFullConnector()
+	m.connector = fc
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == "0x11111" &&
+			*n.Transaction.Required == confirmations.Requirement{Finalized: true}
+	})).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == "0x22222" &&
+			*n.Transaction.Required == confirmations.Requirement{Finalized: true}
+	})).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == "0x33333" &&
+			n.Transaction.Required == nil
+	})).Return(nil).Once()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx.ConfirmationStrategy = confirmations.StrategyFinalized
+	tx.TransactionHash = "0x11111"
+	tx.Cancel = &apitypes.ManagedTXCancel{TransactionHash: "0x22222"}
+	pending := &pendingState{mtx: tx}
+	m.trackSubmittedTransaction(m.ctx, pending)
+	m.trackCancelTransaction(m.ctx, pending)
+
+	// Falls back to the default if the connector no longer supports the strategy
+	m.connector = &ffcapimocks.API{}
+	tx = genTestTxn("0xabcd1234", 12346, apitypes.TxStatusPending)
+	tx.ConfirmationStrategy = confirmations.StrategyFinalized
+	tx.TransactionHash = "0x33333"
+	m.trackSubmittedTransaction(m.ctx, &pendingState{mtx: tx})
+
+	mc.AssertExpectations(t)
+
+}
+
+func TestPolicyLoopWorkersProcessSignersConcurrently(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.policyLoopWorkers = 4
+	m.strictNonceOrdering = false
+
+	// Interleave the nonces of many signers in the inflight set
+	signers := make([]string, 8)
+	for i := range signers {
+		signers[i] = fmt.Sprintf("0x%05d", i)
+	}
+	for nonce := int64(10001); nonce <= 10003; nonce++ {
+		for _, signer := range signers {
+			newTestTxn(t, m, signer, nonce, apitypes.TxStatusPending)
+		}
+	}
+
+	// Each execution waits for the pool to be fully busy, so this only completes
+	// promptly if the workers process transactions concurrently
+	var mux sync.Mutex
+	active := 0
+	allActive := make(chan struct{})
+	executed := make(map[string][]int64)
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mtx := args[2].(*apitypes.ManagedTX)
+		mux.Lock()
+		active++
+		if active == m.policyLoopWorkers {
+			close(allActive)
+		}
+		mux.Unlock()
+		select {
+		case <-allActive:
+		case <-time.After(5 * time.Second):
+		}
+		mux.Lock()
+		executed[mtx.TransactionHeaders.From] = append(executed[mtx.TransactionHeaders.From], mtx.Nonce.Int64())
+		mux.Unlock()
+	}).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	start := time.Now()
+	m.policyLoopCycle(m.ctx, true)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	mpe.AssertNumberOfCalls(t, "Execute", 24)
+	for _, signer := range signers {
+		assert.Equal(t, []int64{10001, 10002, 10003}, executed[signer])
+	}
+
+}
+
+func TestPolicyLoopWorkersHoldUnsubmittedPerSigner(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	m.policyLoopWorkers = 4
+	m.strictNonceOrdering = true
+	m.inflightRestored = true
+
+	// More workers than signers
+	newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xbbbbb", 10001, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xaaaaa", 10002, apitypes.TxStatusPending)
+
+	// Signer 0xaaaaa fails to submit its first nonce, so its second is held,
+	// while 0xbbbbb is submitted in the same cycle
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.TransactionHeaders.From == "0xaaaaa"
+	})).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.TransactionHeaders.From == "0xbbbbb"
+	})).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+
+	m.policyLoopCycle(m.ctx, true)
+
+	mpe.AssertNumberOfCalls(t, "Execute", 2)
+	for _, pending := range m.inflight {
+		if pending.mtx.Nonce.Int64() == 10002 {
+			assert.True(t, pending.lastPolicyCycle.IsZero())
+		}
+	}
+
+}
+
+func TestPolicyLoopExpiryTriggersCancel(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendSampleTX(t, m, "0xaaaaa", 12345)
+	txHash := "0x" + fftypes.NewRandB32().String()
+	cancelHash := "0x" + fftypes.NewRandB32().String()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+		return r.To == ""
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: txHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionSendRequest) bool {
+		return r.To == "0xaaaaa" && r.TransactionData == "" && r.Nonce.Int64() == 12345
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: cancelHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == txHash
+	})).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == cancelHash
+	})).Run(func(args mock.Arguments) {
+		n := args[0].(*confirmations.Notification)
+		n.Transaction.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+			BlockNumber:      fftypes.NewFFBigInt(12345),
+			TransactionIndex: fftypes.NewFFBigInt(10),
+			BlockHash:        fftypes.NewRandB32().String(),
+			Success:          true,
+		})
+		n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+	}).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction
+	})).Return(nil)
+
+	// Submit the original, which is not mined before its expiry
+	waitInflightSignal(t, m, true) // from sending the TX
+	m.policyLoopCycle(m.ctx, true)
+	assert.Equal(t, txHash, m.inflight[0].mtx.TransactionHash)
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	m.inflight[0].mtx.Expiry = &expiry
+
+	// The no-op is submitted in the same cycle the expiry is detected, and confirmed ahead of the original
+	m.policyLoopCycle(m.ctx, false)
+	assert.True(t, m.inflight[0].mtx.Cancel.Expired)
+	assert.Equal(t, cancelHash, m.inflight[0].mtx.Cancel.TransactionHash)
+
+	// The next cycle marks the transaction expired
+	m.policyLoopCycle(m.ctx, false)
+	waitInflightSignal(t, m, true)
+	m.policyLoopCycle(m.ctx, true)
+	assert.Empty(t, m.inflight)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Equal(t, apitypes.TxFailureExpired, rtx.Failure.Code)
+	assert.Equal(t, apitypes.CancelOutcomeCancelled, rtx.Cancel.Outcome)
+	assert.Equal(t, []apitypes.TxAction{
+		apitypes.TxActionSubmitted,
+		apitypes.TxActionExpired,
+		apitypes.TxActionCancelSubmitted,
+		apitypes.TxActionCancelled,
+	}, historyActions(rtx))
+
+	mc.AssertExpectations(t)
+	mfc.AssertExpectations(t)
+}
+
+func TestPolicyLoopMinedBeforeExpiryNoCancel(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendSampleTX(t, m, "0xaaaaa", 12345)
+	txHash := "0x" + fftypes.NewRandB32().String()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: txHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	var original *confirmations.TransactionInfo
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == txHash
+	})).Run(func(args mock.Arguments) {
+		original = args[0].(*confirmations.Notification).Transaction
+	}).Return(nil).Once()
+
+	waitInflightSignal(t, m, true) // from sending the TX
+	m.policyLoopCycle(m.ctx, true)
+	expiry := fftypes.FFTime(time.Now().Add(1 * time.Hour))
+	m.inflight[0].mtx.Expiry = &expiry
+
+	// The original is mined before its expiry, then the expiry passes while it is being confirmed
+	original.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+		BlockNumber:      fftypes.NewFFBigInt(12345),
+		TransactionIndex: fftypes.NewFFBigInt(10),
+		BlockHash:        fftypes.NewRandB32().String(),
+		Success:          true,
+	})
+	expiry = fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	m.policyLoopCycle(m.ctx, false)
+	assert.Nil(t, m.inflight[0].mtx.Cancel)
+
+	original.Confirmed(context.Background(), []confirmations.BlockInfo{})
+	m.policyLoopCycle(m.ctx, false)
+	waitInflightSignal(t, m, true)
+	m.policyLoopCycle(m.ctx, true)
+	assert.Empty(t, m.inflight)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusSucceeded, rtx.Status)
+	assert.Nil(t, rtx.Cancel)
+	assert.Nil(t, rtx.Failure)
+
+	mc.AssertExpectations(t)
+	mfc.AssertExpectations(t)
+}
+
+func TestPolicyLoopExpiryBeforeSubmitFails(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+
+	tx := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	tx.Expiry = &expiry
+	err := m.persistence.WriteTransaction(m.ctx, tx, true)
+	assert.NoError(t, err)
+
+	m.policyLoopCycle(m.ctx, true)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Equal(t, apitypes.TxFailureExpired, rtx.Failure.Code)
+	assert.Regexp(t, "FF21132", rtx.ErrorMessage)
+	assert.Nil(t, rtx.Cancel)
+	assert.Equal(t, []apitypes.TxAction{apitypes.TxActionExpired}, historyActions(rtx))
+	mpe.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything, mock.Anything)
+
+}
+
+func TestCancelExpiredWriteFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx.FirstSubmit = fftypes.Now()
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	tx.Expiry = &expiry
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(fmt.Errorf("pop"))
+
+	err := m.execPolicy(m.ctx, &pendingState{mtx: tx}, false)
+	assert.Regexp(t, "pop", err)
+	assert.True(t, tx.Cancel.Expired)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestInitPolicyLoopIntervalAdaptive(t *testing.T) {
+
+	testManagerCommonInit(t)
+	config.Set(tmconfig.PolicyLoopAdaptiveEnabled, true)
+	config.Set(tmconfig.PolicyLoopAdaptiveMinInterval, "100ms")
+	config.Set(tmconfig.PolicyLoopAdaptiveMaxInterval, "30s")
+
+	// The configured interval is the starting point, within the bounds
+	m := newManager(context.Background(), &ffcapimocks.API{})
+	err := m.initPolicyLoopInterval(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, m.policyLoopAdaptive)
+	assert.Equal(t, 100*time.Millisecond, m.policyLoopInterval)
+
+	config.Set(tmconfig.PolicyLoopInterval, "1m")
+	m = newManager(context.Background(), &ffcapimocks.API{})
+	err = m.initPolicyLoopInterval(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, m.policyLoopInterval)
+
+	config.Set(tmconfig.PolicyLoopInterval, "5s")
+	m = newManager(context.Background(), &ffcapimocks.API{})
+	err = m.initPolicyLoopInterval(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, m.policyLoopInterval)
+
+}
+
+func TestInitPolicyLoopIntervalAdaptiveBadBounds(t *testing.T) {
+
+	testManagerCommonInit(t)
+	config.Set(tmconfig.PolicyLoopAdaptiveEnabled, true)
+	config.Set(tmconfig.PolicyLoopAdaptiveMinInterval, "10s")
+	config.Set(tmconfig.PolicyLoopAdaptiveMaxInterval, "1s")
+
+	_, err := NewManager(context.Background(), &ffcapimocks.API{})
+	assert.Regexp(t, "FF21155", err)
+
+	config.Set(tmconfig.PolicyLoopAdaptiveMinInterval, "0")
+	_, err = NewManager(context.Background(), &ffcapimocks.API{})
+	assert.Regexp(t, "FF21155", err)
+
+}
+
+func TestAdaptPolicyLoopIntervalShrinksUnderLoadGrowsWhenIdle(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopAdaptive = true
+	m.policyLoopMinInterval = 100 * time.Millisecond
+	m.policyLoopMaxInterval = 1 * time.Second
+	m.policyLoopInterval = 400 * time.Millisecond
+
+	// Idle cycles back off towards the maximum
+	m.adaptPolicyLoopInterval(m.ctx, false)
+	assert.Equal(t, 800*time.Millisecond, m.policyLoopInterval)
+	m.adaptPolicyLoopInterval(m.ctx, false)
+	assert.Equal(t, 1*time.Second, m.policyLoopInterval)
+	m.adaptPolicyLoopInterval(m.ctx, false)
+	assert.Equal(t, 1*time.Second, m.policyLoopInterval)
+
+	// A signal drops straight to the minimum
+	m.adaptPolicyLoopInterval(m.ctx, true)
+	assert.Equal(t, 100*time.Millisecond, m.policyLoopInterval)
+
+	// As do in-flight transactions, for as long as there are any
+	m.policyLoopInterval = 1 * time.Second
+	m.inflight = []*pendingState{{mtx: &apitypes.ManagedTX{ID: "tx1"}}}
+	m.adaptPolicyLoopInterval(m.ctx, false)
+	assert.Equal(t, 100*time.Millisecond, m.policyLoopInterval)
+	m.adaptPolicyLoopInterval(m.ctx, false)
+	assert.Equal(t, 100*time.Millisecond, m.policyLoopInterval)
+
+	// Then back off again once they complete
+	m.inflight = nil
+	m.adaptPolicyLoopInterval(m.ctx, false)
+	assert.Equal(t, 200*time.Millisecond, m.policyLoopInterval)
+
+}
+
+func TestAdaptPolicyLoopIntervalFixed(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 5 * time.Second
+
+	m.adaptPolicyLoopInterval(m.ctx, true)
+	assert.Equal(t, 5*time.Second, m.policyLoopInterval)
+	m.adaptPolicyLoopInterval(m.ctx, false)
+	assert.Equal(t, 5*time.Second, m.policyLoopInterval)
+
+}
+
+func TestPolicyLoopAdaptiveIntervalGrowsWhenIdle(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopAdaptive = true
+	m.policyLoopMinInterval = 1 * time.Millisecond
+	m.policyLoopMaxInterval = 1 * time.Hour
+	m.policyLoopInterval = 1 * time.Millisecond
+	m.leaderCtx = m.ctx
+	m.policyLoopDone = make(chan struct{})
+	m.policyLoopDrain = make(chan struct{})
+
+	go m.policyLoop()
+	time.Sleep(50 * time.Millisecond)
+	close(m.policyLoopDrain)
+	<-m.policyLoopDone
+
+	// Each timer cycle with nothing to do doubles the interval
+	assert.Greater(t, int64(m.policyLoopInterval), int64(m.policyLoopMinInterval))
+	assert.Less(t, int64(m.policyLoopInterval), int64(time.Second))
+
+}
@@ -0,0 +1,116 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+)
+
+// signerRateLimiter is an in-memory token bucket per signer, applied to new submissions.
+// Buckets that have refilled completely are indistinguishable from new ones, so are
+// periodically discarded to stop the map growing with every signer ever seen.
+type signerRateLimiter struct {
+	rate      float64 // tokens added per second
+	burst     float64
+	mux       sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time // the clock, which tests can replace
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newSignerRateLimiter(rate float64, burst int) *signerRateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &signerRateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// refill returns the tokens in a bucket at the supplied time
+func (rl *signerRateLimiter) refill(b *tokenBucket, now time.Time) float64 {
+	return math.Min(rl.burst, b.tokens+now.Sub(b.updated).Seconds()*rl.rate)
+}
+
+// take consumes a token for the signer if one is available, returning zero.
+// Otherwise it returns how long until a token will be available.
+func (rl *signerRateLimiter) take(signer string) time.Duration {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+
+	now := rl.now()
+	fullRefill := time.Duration(rl.burst / rl.rate * float64(time.Second))
+	if now.Sub(rl.lastSweep) > fullRefill {
+		for key, b := range rl.buckets {
+			if rl.refill(b, now) >= rl.burst {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	key := strings.ToLower(signer)
+	b := rl.buckets[key]
+	if b == nil {
+		b = &tokenBucket{tokens: rl.burst, updated: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = rl.refill(b, now)
+	b.updated = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// checkRateLimit rejects a submission with a 429 and a Retry-After header, if the signer has exceeded
+// the configured rate. This is only applied on the submission path, never in the policy loop.
+func (m *manager) checkRateLimit(r *ffapi.APIRequest, signer string) error {
+	if m.rateLimiter == nil {
+		return nil
+	}
+	wait := m.rateLimiter.take(signer)
+	if wait == 0 {
+		return nil
+	}
+	ctx := r.Req.Context()
+	log.L(ctx).Warnf("Rejecting submission for signer %s due to rate limit (retry after %s)", signer, wait)
+	r.ResponseHeaders.Set("Retry-After", fmt.Sprintf("%d", int64(math.Ceil(wait.Seconds()))))
+	return i18n.NewError(ctx, tmmsgs.MsgSubmissionRateLimited, signer)
+}
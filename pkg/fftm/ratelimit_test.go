@@ -0,0 +1,217 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testClock is a manually advanced clock, so the refill of the token buckets is deterministic
+type testClock struct {
+	mux sync.Mutex
+	t   time.Time
+}
+
+func useTestClock(rl *signerRateLimiter) *testClock {
+	c := &testClock{t: time.Now()}
+	rl.now = c.now
+	rl.lastSweep = c.t
+	return c
+}
+
+func (c *testClock) now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	assert.Nil(t, newSignerRateLimiter(0, 10))
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	assert.Nil(t, m.rateLimiter)
+	assert.NoError(t, m.checkRateLimit(nil, "0xaaaa"))
+}
+
+func TestRateLimiterBurstAndRefill(t *testing.T) {
+
+	rl := newSignerRateLimiter(20, 3) // a token every 50ms
+	clock := useTestClock(rl)
+
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, rl.take("0xAAAA"))
+	}
+	assert.Equal(t, 50*time.Millisecond, rl.take("0xaaaa")) // signers are case insensitive
+
+	// Other signers have their own bucket
+	assert.Zero(t, rl.take("0xbbbb"))
+
+	// A partial refill shortens the wait
+	clock.advance(20 * time.Millisecond)
+	assert.Equal(t, 30*time.Millisecond, rl.take("0xaaaa"))
+
+	clock.advance(30 * time.Millisecond)
+	assert.Zero(t, rl.take("0xaaaa"))
+	assert.Equal(t, 50*time.Millisecond, rl.take("0xaaaa"))
+
+}
+
+func TestRateLimiterMinBurst(t *testing.T) {
+
+	rl := newSignerRateLimiter(1, 0)
+	assert.Zero(t, rl.take("0xaaaa"))
+	assert.Greater(t, rl.take("0xaaaa"), time.Duration(0))
+
+}
+
+func TestRateLimiterSweepsFullBuckets(t *testing.T) {
+
+	rl := newSignerRateLimiter(1, 2)
+	clock := useTestClock(rl)
+	assert.Zero(t, rl.take("0xaaaa"))
+	assert.Zero(t, rl.take("0xbbbb"))
+	assert.Zero(t, rl.take("0xbbbb"))
+	assert.Len(t, rl.buckets, 2)
+
+	// After a full refill interval, both buckets are full again and are discarded
+	clock.advance(5 * time.Second)
+	assert.Zero(t, rl.take("0xcccc"))
+	assert.Len(t, rl.buckets, 1)
+	assert.NotNil(t, rl.buckets["0xcccc"])
+
+}
+
+func TestSendTransactionRateLimited(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+	m.rateLimiter = newSignerRateLimiter(0.1, 2) // a token every 10s
+	clock := useTestClock(m.rateLimiter)
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("DeployContractPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x106215b9c0c9372e3f541beff0cdc3cd061a26f69f3808e28fd139a1abc9d345",
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+
+	m.Start()
+
+	send := func(body string) *resty.Response {
+		res, err := resty.New().R().
+			SetBody(strings.NewReader(strings.Replace(body, "ns1:904F177C", fftypes.NewUUID().String()[0:12], 1))).
+			Post(url)
+		assert.NoError(t, err)
+		return res
+	}
+
+	// The burst is accepted, including deployments from the same signer
+	assert.Equal(t, 202, send(sampleSendTX).StatusCode())
+	assert.Equal(t, 202, send(sampleDeployTX).StatusCode())
+
+	// Beyond the burst, we are rejected
+	res := send(sampleSendTX)
+	assert.Equal(t, 429, res.StatusCode())
+	assert.Equal(t, "10", res.Header().Get("Retry-After"))
+	assert.Regexp(t, "FF21112", res.String())
+
+	// After the refill interval we are accepted again
+	clock.advance(10 * time.Second)
+	assert.Equal(t, 202, send(sampleSendTX).StatusCode())
+
+}
+
+func TestSendTransactionRateLimitedByResolvedKey(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+	m.rateLimiter = newSignerRateLimiter(0.1, 1) // a token every 10s
+	useTestClock(m.rateLimiter)
+	m.keyManager = &stubKeyManager{keys: map[string]string{"treasury": "0xaaaaa", "payroll": "0xbbbbb"}}
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x106215b9c0c9372e3f541beff0cdc3cd061a26f69f3808e28fd139a1abc9d345",
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+
+	m.Start()
+
+	send := func(keyName, from string) *resty.Response {
+		req := &apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{
+				ID:      fftypes.NewUUID().String(),
+				Type:    apitypes.RequestTypeSendTransaction,
+				KeyName: keyName,
+			},
+		}
+		req.From = from
+		res, err := resty.New().R().SetBody(req).Post(url)
+		assert.NoError(t, err)
+		return res
+	}
+
+	// Each key name has the bucket of the address it resolves to, rather than sharing one
+	assert.Equal(t, 202, send("treasury", "").StatusCode())
+	assert.Equal(t, 202, send("payroll", "").StatusCode())
+
+	// So the key name, and the address it resolves to, are both limited
+	res := send("treasury", "")
+	assert.Equal(t, 429, res.StatusCode())
+	assert.Regexp(t, "FF21112.*0xaaaaa", res.String())
+	res = send("", "0xaaaaa")
+	assert.Equal(t, 429, res.StatusCode())
+
+}
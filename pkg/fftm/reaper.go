@@ -0,0 +1,125 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// reaperLoop periodically deletes transactions that completed longer ago than the retention period, and
+// removes deleted transactions once the deleted retention period has passed.
+// It runs separately from the policy loop, and only on the leader when leader election is enabled.
+func (m *manager) reaperLoop() {
+	defer close(m.reaperDone)
+	ctx := log.WithLogField(m.ctx, "role", "reaper")
+	for {
+		timer := time.NewTimer(m.reaperInterval)
+		select {
+		case <-timer.C:
+			if m.isLeader() && m.reaperRetention > 0 {
+				m.reapTransactions(ctx)
+			}
+			if m.isLeader() && m.deletedRetention > 0 {
+				m.reapDeletedTransactions(ctx)
+			}
+		case <-ctx.Done():
+			timer.Stop()
+			log.L(ctx).Debugf("Transaction reaper exiting")
+			return
+		}
+	}
+}
+
+// reapTransactions deletes succeeded and failed transactions last updated before the retention period.
+// The history and receipt are stored on the transaction, so are deleted with it.
+func (m *manager) reapTransactions(ctx context.Context) int {
+	cutoff := time.Now().Add(-m.reaperRetention)
+	// A transaction cannot be updated before it was created, so only those created before the cutoff are candidates
+	to := fftypes.FFTime(cutoff)
+	var after *apitypes.ManagedTX
+	deleted := 0
+	for {
+		page, err := m.persistence.ListTransactionsByCreateTimeRange(ctx, nil, &to, after, m.reaperBatchSize, persistence.SortDirectionAscending)
+		if err != nil {
+			log.L(ctx).Errorf("Failed to list transactions for deletion: %s", err)
+			break
+		}
+		for _, mtx := range page {
+			if !isTerminal(mtx.Status) || (mtx.Updated != nil && mtx.Updated.Time().After(cutoff)) {
+				continue
+			}
+			if err := m.persistence.DeleteTransaction(ctx, mtx.ID); err != nil {
+				log.L(ctx).Errorf("Failed to delete transaction %s: %s", mtx.ID, err)
+				continue
+			}
+			log.L(ctx).Debugf("Deleted transaction %s (status=%s updated=%s)", mtx.ID, mtx.Status, mtx.Updated)
+			deleted++
+		}
+		if len(page) < m.reaperBatchSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if deleted > 0 {
+		log.L(ctx).Infof("Deleted %d transactions completed before %s", deleted, cutoff)
+	}
+	return deleted
+}
+
+// reapDeletedTransactions removes deleted transactions from persistence, once they have been retained
+// for the deleted retention period. Until then they can be restored with undelete.
+func (m *manager) reapDeletedTransactions(ctx context.Context) int {
+	cutoff := time.Now().Add(-m.deletedRetention)
+	var after *apitypes.ManagedTX
+	removed := 0
+	for {
+		page, err := m.persistence.ListTransactionsByStatus(ctx, apitypes.TxStatusDeleted, "", after, m.reaperBatchSize, persistence.SortDirectionAscending)
+		if err != nil {
+			log.L(ctx).Errorf("Failed to list deleted transactions: %s", err)
+			break
+		}
+		for _, mtx := range page {
+			if mtx.Deleted == nil || mtx.Deleted.Time().After(cutoff) {
+				continue
+			}
+			if err := m.persistence.DeleteTransaction(ctx, mtx.ID); err != nil {
+				log.L(ctx).Errorf("Failed to remove deleted transaction %s: %s", mtx.ID, err)
+				continue
+			}
+			log.L(ctx).Debugf("Removed transaction %s (deleted=%s)", mtx.ID, mtx.Deleted)
+			removed++
+		}
+		if len(page) < m.reaperBatchSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if removed > 0 {
+		log.L(ctx).Infof("Removed %d transactions deleted before %s", removed, cutoff)
+	}
+	return removed
+}
+
+func isTerminal(status apitypes.TxStatus) bool {
+	return status == apitypes.TxStatusSucceeded || status == apitypes.TxStatusFailed
+}
@@ -0,0 +1,239 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newReaperTestTxn(t *testing.T, m *manager, nonce int64, status apitypes.TxStatus, created, updated time.Duration) *apitypes.ManagedTX {
+	tx := genTestTxn("0xaaaaa", nonce, status)
+	createdTime := fftypes.FFTime(time.Now().Add(-created))
+	updatedTime := fftypes.FFTime(time.Now().Add(-updated))
+	tx.Created = &createdTime
+	tx.Updated = &updatedTime
+	err := m.persistence.WriteTransaction(m.ctx, tx, true)
+	assert.NoError(t, err)
+	return tx
+}
+
+func TestReapTransactionsOnlyOldTerminal(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.reaperRetention = 24 * time.Hour
+	m.reaperBatchSize = 2
+
+	day := 24 * time.Hour
+	oldSucceeded := newReaperTestTxn(t, m, 1, apitypes.TxStatusSucceeded, 10*day, 9*day)
+	oldPending := newReaperTestTxn(t, m, 2, apitypes.TxStatusPending, 10*day, 10*day)
+	oldFailed := newReaperTestTxn(t, m, 3, apitypes.TxStatusFailed, 5*day, 5*day)
+	oldScheduled := newReaperTestTxn(t, m, 4, apitypes.TxStatusScheduled, 5*day, 5*day)
+	recentlyFailed := newReaperTestTxn(t, m, 5, apitypes.TxStatusFailed, 5*day, 1*time.Hour)
+	recentSucceeded := newReaperTestTxn(t, m, 6, apitypes.TxStatusSucceeded, 1*time.Hour, 1*time.Minute)
+
+	deleted := m.reapTransactions(m.ctx)
+	assert.Equal(t, 2, deleted)
+
+	for _, tx := range []*apitypes.ManagedTX{oldSucceeded, oldFailed} {
+		stored, err := m.persistence.GetTransactionByID(m.ctx, tx.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, stored, tx.Status)
+	}
+	for _, tx := range []*apitypes.ManagedTX{oldPending, oldScheduled, recentlyFailed, recentSucceeded} {
+		stored, err := m.persistence.GetTransactionByID(m.ctx, tx.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, stored, tx.Status)
+	}
+
+	// The pending transaction is still available to the policy loop
+	pending, err := m.persistence.ListTransactionsPending(m.ctx, nil, 0, persistence.SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, oldPending.ID, pending[0].ID)
+
+}
+
+func TestReaperLoop(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.reaperRetention = 1 * time.Hour
+	m.reaperInterval = 1 * time.Millisecond
+	m.leader = true
+
+	tx := newReaperTestTxn(t, m, 1, apitypes.TxStatusSucceeded, 2*time.Hour, 2*time.Hour)
+
+	m.reaperDone = make(chan struct{})
+	go m.reaperLoop()
+	for {
+		stored, err := m.persistence.GetTransactionByID(m.ctx, tx.ID)
+		assert.NoError(t, err)
+		if stored == nil {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	m.cancelCtx()
+	<-m.reaperDone
+
+}
+
+func TestReapTransactionsListFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.reaperRetention = 1 * time.Hour
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByCreateTimeRange", m.ctx, (*fftypes.FFTime)(nil), mock.Anything, (*apitypes.ManagedTX)(nil), m.reaperBatchSize, persistence.SortDirectionAscending).
+		Return(nil, fmt.Errorf("pop"))
+
+	assert.Zero(t, m.reapTransactions(m.ctx))
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestReapTransactionsDeleteFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.reaperRetention = 1 * time.Hour
+
+	tx := genTestTxn("0xaaaaa", 1, apitypes.TxStatusFailed)
+	old := fftypes.FFTime(time.Now().Add(-2 * time.Hour))
+	tx.Created, tx.Updated = &old, &old
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByCreateTimeRange", m.ctx, (*fftypes.FFTime)(nil), mock.Anything, (*apitypes.ManagedTX)(nil), m.reaperBatchSize, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{tx}, nil)
+	mp.On("DeleteTransaction", m.ctx, tx.ID).Return(fmt.Errorf("pop"))
+
+	assert.Zero(t, m.reapTransactions(m.ctx))
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestReapDeletedTransactions(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.deletedRetention = 24 * time.Hour
+	m.reaperBatchSize = 1
+
+	day := 24 * time.Hour
+	deleted := func(nonce int64, ago time.Duration) *apitypes.ManagedTX {
+		tx := newReaperTestTxn(t, m, nonce, apitypes.TxStatusDeleted, 10*day, ago)
+		tx.Deleted = tx.Updated
+		err := m.persistence.WriteTransaction(m.ctx, tx, false)
+		assert.NoError(t, err)
+		return tx
+	}
+	oldDeleted := deleted(1, 2*day)
+	recentlyDeleted := deleted(2, 1*time.Hour)
+	oldSucceeded := newReaperTestTxn(t, m, 3, apitypes.TxStatusSucceeded, 10*day, 10*day)
+
+	assert.Equal(t, 1, m.reapDeletedTransactions(m.ctx))
+
+	stored, err := m.persistence.GetTransactionByID(m.ctx, oldDeleted.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+	for _, tx := range []*apitypes.ManagedTX{recentlyDeleted, oldSucceeded} {
+		stored, err := m.persistence.GetTransactionByID(m.ctx, tx.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, stored, tx.Status)
+	}
+
+}
+
+func TestReaperLoopDeleted(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.deletedRetention = 1 * time.Hour
+	m.reaperInterval = 1 * time.Millisecond
+	m.leader = true
+
+	tx := newReaperTestTxn(t, m, 1, apitypes.TxStatusDeleted, 2*time.Hour, 2*time.Hour)
+	tx.Deleted = tx.Updated
+	err := m.persistence.WriteTransaction(m.ctx, tx, false)
+	assert.NoError(t, err)
+
+	m.reaperDone = make(chan struct{})
+	go m.reaperLoop()
+	for {
+		stored, err := m.persistence.GetTransactionByID(m.ctx, tx.ID)
+		assert.NoError(t, err)
+		if stored == nil {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	m.cancelCtx()
+	<-m.reaperDone
+
+}
+
+func TestReapDeletedTransactionsListFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.deletedRetention = 1 * time.Hour
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByStatus", m.ctx, apitypes.TxStatusDeleted, "", (*apitypes.ManagedTX)(nil), m.reaperBatchSize, persistence.SortDirectionAscending).
+		Return(nil, fmt.Errorf("pop"))
+
+	assert.Zero(t, m.reapDeletedTransactions(m.ctx))
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestReapDeletedTransactionsDeleteFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.deletedRetention = 1 * time.Hour
+
+	tx := genTestTxn("0xaaaaa", 1, apitypes.TxStatusDeleted)
+	old := fftypes.FFTime(time.Now().Add(-2 * time.Hour))
+	tx.Deleted = &old
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByStatus", m.ctx, apitypes.TxStatusDeleted, "", (*apitypes.ManagedTX)(nil), m.reaperBatchSize, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{tx}, nil)
+	mp.On("DeleteTransaction", m.ctx, tx.ID).Return(fmt.Errorf("pop"))
+
+	assert.Zero(t, m.reapDeletedTransactions(m.ctx))
+
+	mp.AssertExpectations(t)
+
+}
@@ -51,6 +51,10 @@ var postRootCommand = func(m *manager) *ffapi.Route {
 			if err == nil {
 				schemas = append(schemas, queryRequest)
 			}
+			typedDataRequest, err := schemaGen(&apitypes.TypedDataRequest{})
+			if err == nil {
+				schemas = append(schemas, typedDataRequest)
+			}
 			return &openapi3.SchemaRef{
 				Value: &openapi3.Schema{
 					AnyOf: schemas,
@@ -79,25 +83,66 @@ var postRootCommand = func(m *manager) *ffapi.Route {
 				if err = baseReq.UnmarshalTo(&tReq); err != nil {
 					return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgInvalidRequestErr, baseReq.Headers.Type, err)
 				}
+				if idempotencyKey := r.Req.Header.Get(apitypes.IdempotencyKeyHeader); idempotencyKey != "" {
+					tReq.Headers.IdempotencyKey = idempotencyKey
+				}
+				if requestID := r.Req.Header.Get(apitypes.RequestIDHeader); requestID != "" {
+					tReq.Headers.RequestID = requestID
+				}
+				// The key name is resolved first, so the rate limit applies to the signing address
+				if err = m.resolveSigner(r.Req.Context(), &tReq.Headers, &tReq.TransactionHeaders); err != nil {
+					return nil, err
+				}
+				if err = m.checkRateLimit(r, tReq.From); err != nil {
+					return nil, err
+				}
 				return m.sendManagedTransaction(r.Req.Context(), &tReq)
 			case apitypes.RequestTypeDeploy:
 				var tReq apitypes.ContractDeployRequest
 				if err = baseReq.UnmarshalTo(&tReq); err != nil {
 					return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgInvalidRequestErr, baseReq.Headers.Type, err)
 				}
+				if idempotencyKey := r.Req.Header.Get(apitypes.IdempotencyKeyHeader); idempotencyKey != "" {
+					tReq.Headers.IdempotencyKey = idempotencyKey
+				}
+				if requestID := r.Req.Header.Get(apitypes.RequestIDHeader); requestID != "" {
+					tReq.Headers.RequestID = requestID
+				}
+				if err = m.resolveSigner(r.Req.Context(), &tReq.Headers, &tReq.TransactionHeaders); err != nil {
+					return nil, err
+				}
+				if err = m.checkRateLimit(r, tReq.From); err != nil {
+					return nil, err
+				}
 				return m.sendManagedContractDeployment(r.Req.Context(), &tReq)
 			case apitypes.RequestTypeQuery:
 				var tReq apitypes.QueryRequest
 				if err = baseReq.UnmarshalTo(&tReq); err != nil {
 					return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgInvalidRequestErr, baseReq.Headers.Type, err)
 				}
-				res, _, err := m.connector.QueryInvoke(r.Req.Context(), &ffcapi.QueryInvokeRequest{
+				connector, err := m.getConnector(r.Req.Context(), tReq.Headers.Connector)
+				if err != nil {
+					return nil, err
+				}
+				res, _, err := connector.QueryInvoke(r.Req.Context(), &ffcapi.QueryInvokeRequest{
 					TransactionInput: tReq.TransactionInput,
 				})
 				if err != nil {
 					return nil, err
 				}
 				return res.Outputs, nil
+			case apitypes.RequestTypeSignTypedData:
+				var tReq apitypes.TypedDataRequest
+				if err = baseReq.UnmarshalTo(&tReq); err != nil {
+					return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgInvalidRequestErr, baseReq.Headers.Type, err)
+				}
+				if requestID := r.Req.Header.Get(apitypes.RequestIDHeader); requestID != "" {
+					tReq.Headers.RequestID = requestID
+				}
+				if err = m.checkRateLimit(r, tReq.From); err != nil {
+					return nil, err
+				}
+				return m.signTypedData(r.Req.Context(), &tReq)
 			default:
 				return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgUnsupportedRequestType, baseReq.Headers.Type)
 			}
@@ -0,0 +1,100 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetConnectorInfoCachedAtStartup(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	m.connectorInfoInterval = 1 * time.Hour
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Name:         "evmconnect",
+		Version:      "v1.2.0",
+		Capabilities: []ffcapi.Capability{ffcapi.CapabilityEIP1559, ffcapi.CapabilityTrace},
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		var info apitypes.ConnectorInfo
+		res, err := resty.New().R().
+			SetResult(&info).
+			Get(url + "/connector/info")
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		assert.Equal(t, "evmconnect", info.Name)
+		assert.Equal(t, "v1.2.0", info.Version)
+		assert.Equal(t, []ffcapi.Capability{ffcapi.CapabilityEIP1559, ffcapi.CapabilityTrace}, info.Capabilities)
+		assert.NotNil(t, info.LastRefresh)
+	}
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestGetConnectorInfoQueriedWhenFirstNeeded(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Name:         "fabconnect",
+		Version:      "v0.9.0",
+		Capabilities: []ffcapi.Capability{},
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	res, err := resty.New().R().
+		Get(url + "/connector/info")
+	assert.NoError(t, err)
+	assert.Equal(t, 500, res.StatusCode())
+	assert.Regexp(t, "pop", res.String())
+
+	var info apitypes.ConnectorInfo
+	res, err = resty.New().R().
+		SetResult(&info).
+		Get(url + "/connector/info")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, "fabconnect", info.Name)
+	assert.Empty(t, info.Capabilities)
+
+	mfc.AssertExpectations(t)
+
+}
@@ -0,0 +1,75 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/eventsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetEventStreamDeadLetters(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	mes := &eventsmocks.Stream{}
+	streamID := apitypes.NewULID()
+	m.eventStreams[*streamID] = mes
+	mes.On("Stop", mock.Anything).Return(nil).Maybe()
+	deadLetter := &apitypes.DeadLetter{
+		ID:       fftypes.NewUUID(),
+		StreamID: streamID,
+		Created:  fftypes.Now(),
+		Reason:   "pop",
+		Events:   []*apitypes.EventWithContext{{StandardContext: apitypes.EventContext{StreamID: streamID}}},
+	}
+	mes.On("DeadLetters", mock.Anything, 10).Return([]*apitypes.DeadLetter{deadLetter}, nil)
+
+	var deadLetters []*apitypes.DeadLetter
+	res, err := resty.New().R().
+		SetResult(&deadLetters).
+		Get(url + "/eventstreams/" + streamID.String() + "/deadletter?limit=10")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, deadLetter.ID, deadLetters[0].ID)
+	assert.Equal(t, "pop", deadLetters[0].Reason)
+	assert.Len(t, deadLetters[0].Events, 1)
+
+	// Not found
+	res, err = resty.New().R().
+		Get(url + "/eventstreams/" + fftypes.NewUUID().String() + "/deadletter")
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+
+	// Bad ID
+	res, err = resty.New().R().
+		Get(url + "/eventstreams/bad/deadletter")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+
+	mes.AssertExpectations(t)
+}
@@ -0,0 +1,81 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockStreamConnector(m *manager) *ffcapimocks.API {
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+	mfc.On("EventListenerAdd", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerAddResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+	mfc.On("EventListenerRemove", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerRemoveResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+	return mfc
+}
+
+func TestGetEventStreamsExport(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	mockStreamConnector(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var es1 apitypes.EventStream
+	res, err := resty.New().R().SetBody(&apitypes.EventStream{Name: strPtr("stream1")}).SetResult(&es1).Post(url + "/eventstreams")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	res, err = resty.New().R().SetBody(&apitypes.Listener{Name: strPtr("listener1"), FromBlock: strPtr("12345")}).Post(fmt.Sprintf("%s/eventstreams/%s/listeners", url, es1.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	res, err = resty.New().R().SetBody(&apitypes.EventStream{Name: strPtr("stream2"), Suspended: boolPtr(true)}).Post(url + "/eventstreams")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+
+	var export apitypes.EventStreamExport
+	res, err = resty.New().R().SetResult(&export).Get(url + "/eventstreams/export")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+
+	assert.Equal(t, apitypes.EventStreamExportVersion, export.Version)
+	assert.NotNil(t, export.Exported)
+	assert.Len(t, export.Streams, 2)
+	assert.Equal(t, "stream1", *export.Streams[0].Name)
+	assert.Nil(t, export.Streams[0].ID)
+	assert.Nil(t, export.Streams[0].Created)
+	assert.Len(t, export.Streams[0].Listeners, 1)
+	assert.Equal(t, "listener1", *export.Streams[0].Listeners[0].Name)
+	assert.Equal(t, "12345", *export.Streams[0].Listeners[0].FromBlock)
+	assert.Nil(t, export.Streams[0].Listeners[0].ID)
+	assert.Nil(t, export.Streams[0].Listeners[0].StreamID)
+	assert.Equal(t, "stream2", *export.Streams[1].Name)
+	assert.True(t, *export.Streams[1].Suspended)
+	assert.Empty(t, export.Streams[1].Listeners)
+
+}
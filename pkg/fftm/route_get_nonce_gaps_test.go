@@ -0,0 +1,54 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNonceGaps(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	m.mux.Lock()
+	m.nonceGaps["0xbbbbb"] = &apitypes.NonceGap{Signer: "0xbbbbb", MissingNonce: fftypes.NewFFBigInt(5), MissingCount: 2}
+	m.nonceGaps["0xaaaaa"] = &apitypes.NonceGap{Signer: "0xaaaaa", MissingNonce: fftypes.NewFFBigInt(11), MissingCount: 1}
+	m.mux.Unlock()
+
+	var gaps []*apitypes.NonceGap
+	res, err := resty.New().R().
+		SetResult(&gaps).
+		Get(url + "/nonces/gaps")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, gaps, 2)
+	assert.Equal(t, "0xaaaaa", gaps[0].Signer)
+	assert.Equal(t, int64(11), gaps[0].MissingNonce.Int64())
+	assert.Equal(t, "0xbbbbb", gaps[1].Signer)
+	assert.Equal(t, 2, gaps[1].MissingCount)
+
+}
@@ -0,0 +1,89 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetNonces(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	newTestTxn(t, m, "0xaaaaa", 20, apitypes.TxStatusPending)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(18),
+	}, ffcapi.ErrorReason(""), nil)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xbbbbb"}).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	// Lock a nonce for one signer, and leave a second lock part way through allocation
+	ln, err := m.assignAndLockNonce(m.ctx, "ns1:tx1", "", "0xaaaaa")
+	assert.NoError(t, err)
+	m.mux.Lock()
+	m.lockedNonces["0xbbbbb"] = &lockedNonce{m: m, nsOpID: "ns1:tx2", signer: "0xbbbbb", lockedAt: fftypes.Now(), unlocked: make(chan struct{})}
+	m.mux.Unlock()
+
+	var nonces []*apitypes.NonceStatus
+	res, err := resty.New().R().
+		SetResult(&nonces).
+		Get(url + "/nonces")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, nonces, 2)
+
+	assert.Equal(t, "0xaaaaa", nonces[0].Signer)
+	assert.True(t, nonces[0].Locked)
+	assert.Equal(t, "ns1:tx1", nonces[0].LockedBy)
+	assert.NotNil(t, nonces[0].LockedAt)
+	assert.Equal(t, int64(21), nonces[0].LockedNonce.Int64())
+	assert.Equal(t, int64(18), nonces[0].ChainNextNonce.Int64())
+
+	// The chain could not be queried for the second signer, but we still get the local state
+	assert.Equal(t, "0xbbbbb", nonces[1].Signer)
+	assert.Equal(t, "ns1:tx2", nonces[1].LockedBy)
+	assert.Nil(t, nonces[1].LockedNonce)
+	assert.Nil(t, nonces[1].ChainNextNonce)
+
+	// Once the locks are released, there is nothing to list
+	ln.complete(m.ctx)
+	m.mux.Lock()
+	m.lockedNonces["0xbbbbb"].release()
+	m.mux.Unlock()
+	res, err = resty.New().R().
+		SetResult(&nonces).
+		Get(url + "/nonces")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Empty(t, nonces)
+
+}
@@ -18,6 +18,7 @@ package fftm
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/hyperledger/firefly-common/pkg/ffapi"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
@@ -32,13 +33,26 @@ var getTransaction = func(m *manager) *ffapi.Route {
 		PathParams: []*ffapi.PathParam{
 			{Name: "transactionId", Description: tmmsgs.APIParamTransactionID},
 		},
-		QueryParams:     nil,
+		QueryParams: []*ffapi.QueryParam{
+			{Name: "waitConfirmed", Description: tmmsgs.APIParamWaitConfirmed, IsBool: true},
+			{Name: "timeout", Description: tmmsgs.APIParamWaitTimeout},
+		},
 		Description:     tmmsgs.APIEndpointGetSubscriptions,
 		JSONInputValue:  nil,
 		JSONOutputValue: func() interface{} { return &apitypes.ManagedTX{} },
-		JSONOutputCodes: []int{http.StatusOK},
+		JSONOutputCodes: []int{http.StatusOK, http.StatusAccepted},
 		JSONHandler: func(r *ffapi.APIRequest) (output interface{}, err error) {
-			return m.getTransactionByID(r.Req.Context(), r.PP["transactionId"])
+			var tx *apitypes.ManagedTX
+			if strings.EqualFold(r.QP["waitConfirmed"], "true") {
+				r.SuccessStatus, tx, err = m.waitTransactionConfirmed(r.Req.Context(), r.PP["transactionId"], r.QP["timeout"])
+			} else {
+				tx, err = m.getTransactionByID(r.Req.Context(), r.PP["transactionId"])
+			}
+			if err != nil {
+				return nil, err
+			}
+			m.addConfirmationProgress(tx)
+			return tx, nil
 		},
 	}
 }
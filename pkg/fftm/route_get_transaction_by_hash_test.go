@@ -0,0 +1,74 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTransactionByHash(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+	for _, hash := range []string{"0x111111", "0x222222", "0x333333"} {
+		txIn.TransactionHash = hash
+		err = m.persistence.WriteTransaction(m.ctx, txIn, false)
+		assert.NoError(t, err)
+	}
+
+	for _, hash := range []string{"0x111111", "0x222222", "0x333333"} {
+		var txOut *apitypes.ManagedTX
+		res, err := resty.New().R().
+			SetResult(&txOut).
+			Get(fmt.Sprintf("%s/transactions/byhash/%s", url, hash))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		assert.Equal(t, txIn.ID, txOut.ID)
+		assert.Equal(t, "0x333333", txOut.TransactionHash)
+	}
+
+}
+
+func TestGetTransactionByHashNotFound(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var errorOut map[string]interface{}
+	res, err := resty.New().R().
+		SetError(&errorOut).
+		Get(fmt.Sprintf("%s/transactions/byhash/0x999999", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+	assert.Regexp(t, "FF21067", errorOut["error"])
+
+}
@@ -0,0 +1,215 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTransactionConfirmationsNotMined(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	txIn.TransactionHash = "0x12345"
+	err = m.persistence.WriteTransaction(context.Background(), txIn, true)
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/confirmations", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.JSONEq(t, `{
+		"mined": false,
+		"confirmed": false,
+		"count": 0,
+		"required": 20,
+		"confirmations": []
+	}`, res.String())
+
+}
+
+func TestGetTransactionConfirmationsStrategy(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	txIn.TransactionHash = "0x12345"
+	txIn.ConfirmationStrategy = "fast"
+	err = m.persistence.WriteTransaction(context.Background(), txIn, true)
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/confirmations", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.JSONEq(t, `{
+		"mined": false,
+		"confirmed": false,
+		"count": 0,
+		"required": 1,
+		"strategy": "fast",
+		"confirmations": []
+	}`, res.String())
+
+}
+
+func TestGetTransactionConfirmationsInProgress(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	mc := &confirmationsmocks.Manager{}
+	mc.On("Start").Return().Maybe()
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+	m.confirmations = mc
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	txIn.TransactionHash = "0x12345"
+	err = m.persistence.WriteTransaction(context.Background(), txIn, true)
+	assert.NoError(t, err)
+
+	progress := &confirmations.TransactionProgress{
+		BlockNumber: 1001,
+		BlockHash:   "0xaaaa",
+	}
+	mc.On("TransactionProgress", "0x12345").Return(func(string) *confirmations.TransactionProgress {
+		return progress
+	})
+
+	var tc apitypes.TxConfirmations
+	var tx apitypes.ManagedTX
+	blocks := []confirmations.BlockInfo{
+		{BlockNumber: 1002, BlockHash: "0xbbbb", ParentHash: "0xaaaa"},
+		{BlockNumber: 1003, BlockHash: "0xcccc", ParentHash: "0xbbbb"},
+	}
+	for i := 0; i <= len(blocks); i++ {
+		progress.Confirmations = blocks[0:i]
+
+		res, err := resty.New().R().
+			SetResult(&tc).
+			Get(fmt.Sprintf("%s/transactions/%s/confirmations", url, txIn.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		assert.True(t, tc.Mined)
+		assert.False(t, tc.Confirmed)
+		assert.Equal(t, int64(1001), tc.BlockNumber.Int64())
+		assert.Equal(t, "0xaaaa", tc.BlockHash)
+		assert.Equal(t, i, tc.Count)
+		assert.Equal(t, 20, tc.Required)
+		assert.Equal(t, blocks[0:i], tc.Confirmations)
+
+		res, err = resty.New().R().
+			SetResult(&tx).
+			Get(fmt.Sprintf("%s/transactions/%s", url, txIn.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		assert.Equal(t, i, tx.ConfirmationCount)
+		assert.Equal(t, 20, tx.ConfirmationsRequired)
+	}
+
+	var txs []*apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetResult(&txs).
+		Get(fmt.Sprintf("%s/transactions", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, txs, 1)
+	assert.Equal(t, len(blocks), txs[0].ConfirmationCount)
+	assert.Equal(t, 20, txs[0].ConfirmationsRequired)
+
+}
+
+func TestGetTransactionConfirmationsComplete(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	txIn.TransactionHash = "0x12345"
+	txIn.Receipt = &ffcapi.TransactionReceiptResponse{
+		BlockNumber: fftypes.NewFFBigInt(1001),
+		BlockHash:   "0xaaaa",
+		Success:     true,
+	}
+	txIn.Confirmations = []confirmations.BlockInfo{
+		{BlockNumber: 1002, BlockHash: "0xbbbb", ParentHash: "0xaaaa"},
+	}
+	err = m.persistence.WriteTransaction(context.Background(), txIn, true)
+	assert.NoError(t, err)
+
+	var tc apitypes.TxConfirmations
+	res, err := resty.New().R().
+		SetResult(&tc).
+		Get(fmt.Sprintf("%s/transactions/%s/confirmations", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.True(t, tc.Mined)
+	assert.True(t, tc.Confirmed)
+	assert.Equal(t, int64(1001), tc.BlockNumber.Int64())
+	assert.Equal(t, 1, tc.Count)
+	assert.Equal(t, txIn.Confirmations, tc.Confirmations)
+
+}
+
+func TestGetTransactionConfirmationsNotFound(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/confirmations", url, "missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+
+}
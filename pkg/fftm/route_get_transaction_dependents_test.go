@@ -0,0 +1,108 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTransactionDependents(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	// parent <- child1 <- grandchild, and parent <- child2
+	newDependentTxn := func(nonce int64, dependsOn string) *apitypes.ManagedTX {
+		tx := genTestTxn("0xaaaaa", nonce, apitypes.TxStatusPending)
+		tx.DependsOn = dependsOn
+		err := m.persistence.WriteTransaction(context.Background(), tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+	parent := newDependentTxn(10001, "")
+	child1 := newDependentTxn(10002, parent.ID)
+	child2 := newDependentTxn(10003, parent.ID)
+	grandchild := newDependentTxn(10004, child1.ID)
+
+	getDependents := func(txID string) []*apitypes.ManagedTX {
+		var dependents []*apitypes.ManagedTX
+		res, err := resty.New().R().
+			SetResult(&dependents).
+			Get(fmt.Sprintf("%s/transactions/%s/dependents", url, txID))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		return dependents
+	}
+
+	dependents := getDependents(parent.ID)
+	assert.Len(t, dependents, 2)
+	assert.Equal(t, child2.ID, dependents[0].ID)
+	assert.Equal(t, child1.ID, dependents[1].ID)
+
+	dependents = getDependents(child1.ID)
+	assert.Len(t, dependents, 1)
+	assert.Equal(t, grandchild.ID, dependents[0].ID)
+
+	assert.Empty(t, getDependents(grandchild.ID))
+
+	// The other direction is the dependsOn of each transaction
+	var tx *apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetResult(&tx).
+		Get(fmt.Sprintf("%s/transactions/%s", url, grandchild.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, child1.ID, tx.DependsOn)
+
+	// Once the parent is deleted, its dependents can still be found by its ID
+	err = m.persistence.DeleteTransaction(context.Background(), parent.ID)
+	assert.NoError(t, err)
+	res, err = resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s", url, child2.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	dependents = getDependents(parent.ID)
+	assert.Len(t, dependents, 2)
+
+}
+
+func TestGetTransactionDependentsUnknown(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/dependents", url, "ns1:unknown"))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.JSONEq(t, `[]`, res.String())
+
+}
@@ -0,0 +1,90 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTransactionHistory(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	txIn.History = []*apitypes.TxHistoryEntry{
+		{Time: fftypes.Now(), Action: apitypes.TxActionSubmitted, Info: "0x12345"},
+		{Time: fftypes.Now(), Action: apitypes.TxActionGasPriceChanged, Info: "12345"},
+	}
+	err = m.persistence.WriteTransaction(context.Background(), txIn, true)
+	assert.NoError(t, err)
+
+	var history []*apitypes.TxHistoryEntry
+	res, err := resty.New().R().
+		SetResult(&history).
+		Get(fmt.Sprintf("%s/transactions/%s/history", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, txIn.History, history)
+
+}
+
+func TestGetTransactionHistoryEmpty(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/history", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.JSONEq(t, `[]`, res.String())
+
+}
+
+func TestGetTransactionHistoryNotFound(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/history", url, "missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+
+}
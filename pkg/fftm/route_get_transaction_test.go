@@ -17,12 +17,19 @@
 package fftm
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestGetTransaction(t *testing.T) {
@@ -42,6 +49,261 @@ func TestGetTransaction(t *testing.T) {
 		Get(fmt.Sprintf("%s/transactions/%s", url, txIn.ID))
 	assert.NoError(t, err)
 	assert.Equal(t, 200, res.StatusCode())
+	txIn.ConfirmationsRequired = 20
 	assert.Equal(t, *txIn, *txOut)
 
 }
+
+func TestGetTransactionFailure(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusFailed)
+	txIn.Failure = &apitypes.ManagedTXFailure{
+		Time:   fftypes.Now(),
+		Code:   apitypes.TxFailureInsufficientFunds,
+		Reason: ffcapi.ErrorReasonInsufficientFunds,
+		Error:  "pop",
+	}
+	err = m.persistence.WriteTransaction(m.ctx, txIn, true)
+	assert.NoError(t, err)
+
+	var txOut map[string]interface{}
+	res, err := resty.New().R().
+		SetResult(&txOut).
+		Get(fmt.Sprintf("%s/transactions/%s", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	failure := txOut["failure"].(map[string]interface{})
+	assert.Equal(t, "InsufficientFunds", failure["code"])
+	assert.Equal(t, "insufficient_funds", failure["reason"])
+
+}
+
+func TestGetTransactionWaitConfirmed(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+
+	// Confirm the transaction once the request is waiting
+	go func() {
+		for {
+			m.mux.Lock()
+			waiting := len(m.txWaiters[txIn.ID]) > 0
+			m.mux.Unlock()
+			if waiting {
+				break
+			}
+			time.Sleep(1 * time.Millisecond)
+		}
+		txIn.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+		err := m.execPolicy(m.ctx, &pendingState{mtx: txIn, confirmed: true}, false)
+		assert.NoError(t, err)
+	}()
+
+	var txOut *apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetResult(&txOut).
+		Get(fmt.Sprintf("%s/transactions/%s?waitConfirmed=true&timeout=10s", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode())
+	assert.Equal(t, apitypes.TxStatusSucceeded, txOut.Status)
+
+	m.mux.Lock()
+	assert.Empty(t, m.txWaiters)
+	m.mux.Unlock()
+
+}
+
+func TestGetTransactionWaitConfirmedTimeout(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+
+	var txOut *apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetResult(&txOut).
+		Get(fmt.Sprintf("%s/transactions/%s?waitConfirmed=true&timeout=10ms", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, res.StatusCode())
+	assert.Equal(t, apitypes.TxStatusPending, txOut.Status)
+
+	m.mux.Lock()
+	assert.Empty(t, m.txWaiters)
+	m.mux.Unlock()
+
+}
+
+func TestGetTransactionWaitConfirmedAlreadyComplete(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusFailed)
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s?waitConfirmed=true", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode())
+
+	res, err = resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s?waitConfirmed=true&timeout=bad", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode())
+	assert.Regexp(t, "FF21073", res.String())
+
+	res, err = resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s?waitConfirmed=true", url, "bad-id"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode())
+
+}
+
+func TestWaitTransactionConfirmedDeleted(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+
+	go func() {
+		for {
+			m.mux.Lock()
+			waiting := len(m.txWaiters[txIn.ID]) > 0
+			m.mux.Unlock()
+			if waiting {
+				break
+			}
+			time.Sleep(1 * time.Millisecond)
+		}
+		err := m.persistence.DeleteTransaction(m.ctx, txIn.ID)
+		assert.NoError(t, err)
+		m.notifyTransactionWaiters(txIn.ID)
+	}()
+
+	_, _, err := m.waitTransactionConfirmed(m.ctx, txIn.ID, "10s")
+	assert.Regexp(t, "FF21067", err)
+
+}
+
+func TestWaitTransactionConfirmedStillPending(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+
+	go func() {
+		for {
+			m.mux.Lock()
+			waiting := len(m.txWaiters[txIn.ID]) > 0
+			m.mux.Unlock()
+			if waiting {
+				break
+			}
+			time.Sleep(1 * time.Millisecond)
+		}
+		m.notifyTransactionWaiters(txIn.ID)
+	}()
+
+	status, _, err := m.waitTransactionConfirmed(m.ctx, txIn.ID, "10s")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, status)
+
+}
+
+func TestWaitTransactionConfirmedCancelled(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+	other := m.addTransactionWaiter(txIn.ID)
+
+	ctx, cancelCtx := context.WithCancel(m.ctx)
+	cancelCtx()
+	_, _, err := m.waitTransactionConfirmed(ctx, txIn.ID, "10s")
+	assert.Regexp(t, "FF00154", err)
+
+	// Only our waiter is removed
+	m.mux.Lock()
+	assert.Equal(t, []chan struct{}{other}, m.txWaiters[txIn.ID])
+	m.mux.Unlock()
+
+}
+
+func TestGetTransactionDecodedInput(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	sendWithMethod := func(method string) *apitypes.ManagedTX {
+		txInput := ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa"},
+			Method:             fftypes.JSONAnyPtr(method),
+		}
+		mfc := m.connector.(*ffcapimocks.API)
+		mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+			Nonce: fftypes.NewFFBigInt(10001),
+		}, ffcapi.ErrorReason(""), nil).Once()
+		mfc.On("TransactionPrepare", m.ctx, &ffcapi.TransactionPrepareRequest{
+			TransactionInput: txInput,
+		}).Return(&ffcapi.TransactionPrepareResponse{
+			Gas: fftypes.NewFFBigInt(100000),
+			TransactionData: "0xa9059cbb" +
+				"0000000000000000000000000102030405060708090a0b0c0d0e0f1011121314" +
+				"00000000000000000000000000000000000000000000000000000000000003e8",
+		}, ffcapi.ErrorReason(""), nil).Once()
+		mtx, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{TransactionInput: txInput})
+		assert.NoError(t, err)
+		return mtx
+	}
+
+	txIn := sendWithMethod(`{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}]}`)
+	var txOut *apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetResult(&txOut).
+		Get(fmt.Sprintf("%s/transactions/%s", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, "transfer", txOut.DecodedInput.Method)
+	assert.Equal(t, "0xa9059cbb", txOut.DecodedInput.Signature)
+	assert.Equal(t, "0x0102030405060708090a0b0c0d0e0f1011121314", txOut.DecodedInput.Args.JSONObject().GetString("to"))
+	assert.Equal(t, "1000", txOut.DecodedInput.Args.JSONObject().GetString("value"))
+
+	// A method ABI that does not match the calldata does not block the submission
+	txIn = sendWithMethod(`{"type":"function","name":"approve","inputs":[{"name":"spender","type":"address"},{"name":"value","type":"uint256"}]}`)
+	txOut = nil
+	res, err = resty.New().R().
+		SetResult(&txOut).
+		Get(fmt.Sprintf("%s/transactions/%s", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Nil(t, txOut.DecodedInput)
+
+}
@@ -0,0 +1,209 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type tracingConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.TraceAPI
+}
+
+func newTestTracingManager(t *testing.T) (string, *manager, *ffcapimocks.TraceAPI, func()) {
+	url, m, done := newTestManager(t)
+	mta := &ffcapimocks.TraceAPI{}
+	m.connector = &tracingConnector{API: m.connector.(*ffcapimocks.API), TraceAPI: mta}
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+	return url, m, mta, done
+}
+
+func writeTestMinedTxn(t *testing.T, m *manager, status apitypes.TxStatus) *apitypes.ManagedTX {
+	tx := genTestTxn("0xaaaaa", 10001, status)
+	tx.TransactionHash = "0x12345"
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{
+		BlockNumber: fftypes.NewFFBigInt(1001),
+		BlockHash:   "0xaaaa",
+		Success:     status == apitypes.TxStatusSucceeded,
+	}
+	err := m.persistence.WriteTransaction(context.Background(), tx, true)
+	assert.NoError(t, err)
+	return tx
+}
+
+func TestGetTransactionTraceCached(t *testing.T) {
+
+	url, m, mta, done := newTestTracingManager(t)
+	defer done()
+
+	txIn := writeTestMinedTxn(t, m, apitypes.TxStatusFailed)
+	mta.On("TransactionTrace", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionTraceRequest) bool {
+		return req.TransactionHash == "0x12345"
+	})).Return(&ffcapi.TransactionTraceResponse{
+		RevertReason: "Insufficient funds",
+		Trace:        fftypes.JSONAnyPtr(`{"calls":[]}`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	for i := 0; i < 2; i++ {
+		var trace apitypes.TxTrace
+		res, err := resty.New().R().
+			SetResult(&trace).
+			Get(fmt.Sprintf("%s/transactions/%s/trace", url, txIn.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		assert.True(t, trace.Supported)
+		assert.Equal(t, "Insufficient funds", trace.RevertReason)
+		assert.JSONEq(t, `{"calls":[]}`, trace.Trace.String())
+		assert.NotNil(t, trace.Fetched)
+	}
+
+	tx, err := m.persistence.GetTransactionByID(context.Background(), txIn.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Insufficient funds", tx.Trace.RevertReason)
+
+	mta.AssertExpectations(t)
+
+}
+
+func TestGetTransactionTracePendingNotCached(t *testing.T) {
+
+	url, m, mta, done := newTestTracingManager(t)
+	defer done()
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	txIn.TransactionHash = "0x12345"
+	err := m.persistence.WriteTransaction(context.Background(), txIn, true)
+	assert.NoError(t, err)
+	mta.On("TransactionTrace", mock.Anything, mock.Anything).Return(&ffcapi.TransactionTraceResponse{}, ffcapi.ErrorReason(""), nil).Twice()
+
+	for i := 0; i < 2; i++ {
+		res, err := resty.New().R().
+			Get(fmt.Sprintf("%s/transactions/%s/trace", url, txIn.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+	}
+
+	tx, err := m.persistence.GetTransactionByID(context.Background(), txIn.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, tx.Trace)
+
+	mta.AssertExpectations(t)
+
+}
+
+func TestGetTransactionTraceNotSupported(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := writeTestMinedTxn(t, m, apitypes.TxStatusSucceeded)
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/trace", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.JSONEq(t, `{"supported": false}`, res.String())
+
+}
+
+func TestGetTransactionTraceNotSubmitted(t *testing.T) {
+
+	url, m, _, done := newTestTracingManager(t)
+	defer done()
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	err := m.persistence.WriteTransaction(context.Background(), txIn, true)
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/trace", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, res.StatusCode())
+	assert.Regexp(t, "FF21114", res.String())
+
+}
+
+func TestGetTransactionTraceConnectorError(t *testing.T) {
+
+	url, m, mta, done := newTestTracingManager(t)
+	defer done()
+
+	txIn := writeTestMinedTxn(t, m, apitypes.TxStatusSucceeded)
+	mta.On("TransactionTrace", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/trace", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 500, res.StatusCode())
+	assert.Regexp(t, "pop", res.String())
+
+	tx, err := m.persistence.GetTransactionByID(context.Background(), txIn.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, tx.Trace)
+
+}
+
+func TestGetTransactionTraceNotFound(t *testing.T) {
+
+	url, _, _, done := newTestTracingManager(t)
+	defer done()
+
+	res, err := resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s/trace", url, "missing"))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+
+}
+
+func TestGetTransactionTraceCacheWriteFail(t *testing.T) {
+
+	_, m, done := newTestManagerMockPersistence(t)
+	defer done()
+	mta := &ffcapimocks.TraceAPI{}
+	m.connector = &tracingConnector{API: m.connector.(*ffcapimocks.API), TraceAPI: mta}
+
+	tx := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	tx.TransactionHash = "0x12345"
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{}
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, tx.ID).Return(tx, nil)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(fmt.Errorf("pop"))
+	mta.On("TransactionTrace", m.ctx, mock.Anything).Return(&ffcapi.TransactionTraceResponse{}, ffcapi.ErrorReason(""), nil)
+
+	_, err := m.getTransactionTrace(m.ctx, tx.ID)
+	assert.Regexp(t, "pop", err)
+
+}
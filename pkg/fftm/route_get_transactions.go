@@ -36,6 +36,12 @@ var getTransactions = func(m *manager) *ffapi.Route {
 			{Name: "after", Description: tmmsgs.APIParamAfter},
 			{Name: "signer", Description: tmmsgs.APIParamTXSigner},
 			{Name: "pending", Description: tmmsgs.APIParamTXPending, IsBool: true},
+			{Name: "status", Description: tmmsgs.APIParamTXStatus},
+			{Name: "fromTime", Description: tmmsgs.APIParamTXFromTime},
+			{Name: "toTime", Description: tmmsgs.APIParamTXToTime},
+			{Name: "requestId", Description: tmmsgs.APIParamTXRequestID},
+			{Name: "label", Description: tmmsgs.APIParamTXLabel},
+			{Name: "includeDeleted", Description: tmmsgs.APIParamTXIncludeDeleted, IsBool: true},
 			{Name: "direction", Description: tmmsgs.APIParamSortDirection},
 		},
 		Description:     tmmsgs.APIEndpointGetSubscriptions,
@@ -43,7 +49,12 @@ var getTransactions = func(m *manager) *ffapi.Route {
 		JSONOutputValue: func() interface{} { return []*apitypes.ManagedTX{} },
 		JSONOutputCodes: []int{http.StatusOK},
 		JSONHandler: func(r *ffapi.APIRequest) (output interface{}, err error) {
-			return m.getTransactions(r.Req.Context(), r.QP["after"], r.QP["limit"], r.QP["signer"], strings.EqualFold(r.QP["pending"], "true"), r.QP["direction"])
+			txs, err := m.getTransactions(r.Req.Context(), r.QP["after"], r.QP["limit"], r.QP["signer"], strings.EqualFold(r.QP["pending"], "true"), r.QP["status"], r.QP["fromTime"], r.QP["toTime"], r.QP["direction"], r.QP["requestId"], strings.EqualFold(r.QP["includeDeleted"], "true"), r.Req.URL.Query()["label"])
+			if err != nil {
+				return nil, err
+			}
+			m.addConfirmationProgress(txs...)
+			return txs, nil
 		},
 	}
 }
@@ -0,0 +1,186 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestConfirmedTxn(t *testing.T, m *manager, nonce int64, created time.Time, confirmationTime time.Duration) *apitypes.ManagedTX {
+	tx := genTestTxn("0xaaaaa", nonce, apitypes.TxStatusSucceeded)
+	createdTime := fftypes.FFTime(created)
+	firstSubmit := fftypes.FFTime(created.Add(time.Second))
+	confirmed := fftypes.FFTime(created.Add(time.Second + confirmationTime))
+	tx.Created = &createdTime
+	tx.FirstSubmit = &firstSubmit
+	tx.History = []*apitypes.TxHistoryEntry{
+		{Time: &firstSubmit, Action: apitypes.TxActionSubmitted},
+		{Time: &confirmed, Action: apitypes.TxActionConfirmed},
+	}
+	err := m.persistence.WriteTransaction(context.Background(), tx, true)
+	assert.NoError(t, err)
+	return tx
+}
+
+func TestGetTransactionsStats(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	// Seed transactions submitted before and within the window
+	now := time.Now()
+	newTestConfirmedTxn(t, m, 1000, now.Add(-2*time.Hour), 1*time.Minute)
+	newTestConfirmedTxn(t, m, 1001, now.Add(-5*time.Minute), 10*time.Second)
+	newTestConfirmedTxn(t, m, 1002, now.Add(-4*time.Minute), 20*time.Second)
+	newTestTxn(t, m, "0xaaaaa", 1003, apitypes.TxStatusFailed)
+	newTestTxn(t, m, "0xaaaaa", 1004, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xaaaaa", 1005, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xbbbbb", 2000, apitypes.TxStatusPending)
+	m.mux.Lock()
+	m.inflightBySigner = map[string]int{"0xaaaaa": 2}
+	m.mux.Unlock()
+
+	stats, err := m.getTransactionStats(m.ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[apitypes.TxStatus]int{
+		apitypes.TxStatusSucceeded: 3,
+		apitypes.TxStatusFailed:    1,
+		apitypes.TxStatusPending:   3,
+	}, stats.ByStatus)
+	assert.Equal(t, 2, stats.InFlight)
+	assert.Equal(t, 1, stats.Pending)
+	assert.Equal(t, 10*time.Minute, time.Duration(*stats.Window))
+	assert.Equal(t, 6, stats.Submitted)
+	assert.Equal(t, 2, stats.Confirmed)
+	assert.Equal(t, 15*time.Second, time.Duration(*stats.AvgConfirmationTime))
+
+	// A wider window includes the older transaction, with a number of minutes if there is no unit
+	stats, err = m.getTransactionStats(m.ctx, "180")
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Hour, time.Duration(*stats.Window))
+	assert.Equal(t, 7, stats.Submitted)
+	assert.Equal(t, 3, stats.Confirmed)
+	assert.Equal(t, 30*time.Second, time.Duration(*stats.AvgConfirmationTime))
+
+}
+
+func TestGetTransactionsStatsNoneConfirmed(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	// A transaction that succeeded without a recorded confirmation is not included in the average
+	tx := newTestTxn(t, m, "0xaaaaa", 1000, apitypes.TxStatusSucceeded)
+	tx.FirstSubmit = fftypes.Now()
+	err := m.persistence.WriteTransaction(m.ctx, tx, false)
+	assert.NoError(t, err)
+	m.mux.Lock()
+	m.inflightBySigner = map[string]int{"0xaaaaa": 1}
+	m.mux.Unlock()
+
+	stats, err := m.getTransactionStats(m.ctx, "1h")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Submitted)
+	assert.Equal(t, 0, stats.Confirmed)
+	assert.Nil(t, stats.AvgConfirmationTime)
+	assert.Equal(t, 1, stats.InFlight)
+	assert.Equal(t, 0, stats.Pending)
+
+}
+
+func TestGetTransactionsStatsManyPages(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	for i := 0; i < statsPageSize+1; i++ {
+		newTestTxn(t, m, "0xaaaaa", int64(i), apitypes.TxStatusPending)
+	}
+
+	stats, err := m.getTransactionStats(m.ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, statsPageSize+1, stats.Submitted)
+	assert.Equal(t, statsPageSize+1, stats.ByStatus[apitypes.TxStatusPending])
+
+}
+
+func TestGetTransactionsStatsRoute(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	newTestTxn(t, m, "0xaaaaa", 1000, apitypes.TxStatusFailed)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var stats apitypes.TransactionStats
+	res, err := resty.New().R().
+		SetResult(&stats).
+		Get(url + "/transactions/stats?window=30m")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, map[apitypes.TxStatus]int{apitypes.TxStatusFailed: 1}, stats.ByStatus)
+	assert.Equal(t, 30*time.Minute, time.Duration(*stats.Window))
+	assert.Equal(t, 1, stats.Submitted)
+
+	for _, window := range []string{"bad", "-1m", "0"} {
+		res, err := resty.New().R().
+			Get(url + "/transactions/stats?window=" + window)
+		assert.NoError(t, err)
+		assert.Equal(t, 400, res.StatusCode())
+		assert.Regexp(t, "FF21192", res.String())
+	}
+
+}
+
+func TestGetTransactionsStatsCountFail(t *testing.T) {
+
+	_, m, done := newTestManagerMockPersistence(t)
+	defer done()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("CountTransactionsByStatus", m.ctx).Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.getTransactionStats(m.ctx, "")
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestGetTransactionsStatsListFail(t *testing.T) {
+
+	_, m, done := newTestManagerMockPersistence(t)
+	defer done()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("CountTransactionsByStatus", m.ctx).Return(map[apitypes.TxStatus]int{}, nil)
+	mp.On("ListTransactionsByCreateTimeRange", m.ctx, mock.Anything, mock.Anything, mock.Anything, statsPageSize, mock.Anything).Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.getTransactionStats(m.ctx, "")
+	assert.Regexp(t, "pop", err)
+
+}
@@ -19,6 +19,7 @@ package fftm
 import (
 	"context"
 	"fmt"
+	neturl "net/url"
 	"testing"
 
 	"github.com/go-resty/resty/v2"
@@ -113,3 +114,187 @@ func TestGetTransactions(t *testing.T) {
 	assert.Equal(t, s2t1.ID, transactions[0].ID)
 
 }
+
+func TestGetTransactionsByStatus(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	s1t1 := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	s2t1 := newTestTxn(t, m, "0xbbbbb", 10001, apitypes.TxStatusPending)
+	s1t2 := newTestTxn(t, m, "0xaaaaa", 10002, apitypes.TxStatusFailed)
+	s1t3 := newTestTxn(t, m, "0xaaaaa", 10003, apitypes.TxStatusPending)
+	s2t2 := newTestTxn(t, m, "0xbbbbb", 10002, apitypes.TxStatusFailed)
+
+	getTransactions := func(query string) []*apitypes.ManagedTX {
+		var transactions []*apitypes.ManagedTX
+		res, err := resty.New().R().
+			SetResult(&transactions).
+			Get(url + "/transactions?" + query)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		return transactions
+	}
+
+	transactions := getTransactions("status=pending")
+	assert.Len(t, transactions, 2)
+	assert.Equal(t, s1t3.ID, transactions[0].ID)
+	assert.Equal(t, s2t1.ID, transactions[1].ID)
+
+	transactions = getTransactions("status=succeeded")
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, s1t1.ID, transactions[0].ID)
+
+	transactions = getTransactions("status=Failed&direction=asc")
+	assert.Len(t, transactions, 2)
+	assert.Equal(t, s1t2.ID, transactions[0].ID)
+	assert.Equal(t, s2t2.ID, transactions[1].ID)
+
+	// Combined with signer and pagination
+	transactions = getTransactions("status=failed&signer=0xbbbbb")
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, s2t2.ID, transactions[0].ID)
+
+	transactions = getTransactions("status=pending&signer=0xaaaaa&after=" + s1t3.ID)
+	assert.Empty(t, transactions)
+
+	transactions = getTransactions("status=pending&limit=1&after=" + s1t3.ID)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, s2t1.ID, transactions[0].ID)
+
+	// Invalid combinations
+	res, err := resty.New().R().
+		Get(url + "/transactions?status=unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21077", res.String())
+
+	res, err = resty.New().R().
+		Get(url + "/transactions?status=pending&pending")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21076", res.String())
+
+}
+
+func TestGetTransactionsByTimeRange(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	t1 := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	t2 := newTestTxn(t, m, "0xbbbbb", 10001, apitypes.TxStatusPending)
+	t3 := newTestTxn(t, m, "0xaaaaa", 10002, apitypes.TxStatusFailed)
+	t4 := newTestTxn(t, m, "0xaaaaa", 10003, apitypes.TxStatusPending)
+
+	getTransactions := func(query string) []*apitypes.ManagedTX {
+		var transactions []*apitypes.ManagedTX
+		res, err := resty.New().R().
+			SetResult(&transactions).
+			Get(url + "/transactions?" + query)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		return transactions
+	}
+	timeParam := func(name string, tx *apitypes.ManagedTX) string {
+		return name + "=" + neturl.QueryEscape(tx.Created.String())
+	}
+
+	// Both bounds are inclusive
+	transactions := getTransactions(timeParam("fromTime", t2) + "&" + timeParam("toTime", t3))
+	assert.Len(t, transactions, 2)
+	assert.Equal(t, t3.ID, transactions[0].ID)
+	assert.Equal(t, t2.ID, transactions[1].ID)
+
+	transactions = getTransactions(timeParam("fromTime", t4))
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, t4.ID, transactions[0].ID)
+
+	transactions = getTransactions(timeParam("toTime", t1))
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, t1.ID, transactions[0].ID)
+
+	transactions = getTransactions(timeParam("fromTime", t3) + "&" + timeParam("toTime", t3))
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, t3.ID, transactions[0].ID)
+
+	// Combined with limit and pagination
+	transactions = getTransactions(timeParam("fromTime", t1) + "&" + timeParam("toTime", t3) + "&limit=2")
+	assert.Len(t, transactions, 2)
+	assert.Equal(t, t3.ID, transactions[0].ID)
+	assert.Equal(t, t2.ID, transactions[1].ID)
+
+	transactions = getTransactions(timeParam("fromTime", t1) + "&" + timeParam("toTime", t3) + "&limit=2&after=" + t2.ID)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, t1.ID, transactions[0].ID)
+
+	transactions = getTransactions(timeParam("fromTime", t2) + "&limit=1&direction=asc&after=" + t2.ID)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, t3.ID, transactions[0].ID)
+
+	// Invalid ranges and combinations
+	res, err := resty.New().R().
+		Get(url + "/transactions?" + timeParam("fromTime", t3) + "&" + timeParam("toTime", t2))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21082", res.String())
+
+	res, err = resty.New().R().
+		Get(url + "/transactions?toTime=yesterday")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21081", res.String())
+
+	res, err = resty.New().R().
+		Get(url + "/transactions?status=pending&" + timeParam("fromTime", t1))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21083", res.String())
+
+}
+
+func TestGetTransactionsExcludesDeleted(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	t1 := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	t2 := newTestTxn(t, m, "0xaaaaa", 10002, apitypes.TxStatusDeleted)
+	t3 := newTestTxn(t, m, "0xaaaaa", 10003, apitypes.TxStatusDeleted)
+	t4 := newTestTxn(t, m, "0xaaaaa", 10004, apitypes.TxStatusFailed)
+
+	getTransactions := func(query string) []string {
+		var transactions []*apitypes.ManagedTX
+		res, err := resty.New().R().
+			SetResult(&transactions).
+			Get(url + "/transactions?" + query)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		ids := []string{}
+		for _, tx := range transactions {
+			ids = append(ids, tx.ID)
+		}
+		return ids
+	}
+
+	// Further pages are read, to fill the limit past the deleted transactions
+	assert.Equal(t, []string{t4.ID, t1.ID}, getTransactions("limit=2"))
+	assert.Equal(t, []string{t4.ID}, getTransactions("limit=1"))
+	assert.Equal(t, []string{t1.ID}, getTransactions("limit=1&after="+t4.ID))
+	assert.Equal(t, []string{t4.ID, t1.ID}, getTransactions("signer=0xaaaaa"))
+	assert.Equal(t, []string{t4.ID, t3.ID}, getTransactions("limit=2&includeDeleted"))
+	assert.Equal(t, []string{t3.ID, t2.ID}, getTransactions("status=Deleted"))
+
+}
@@ -0,0 +1,83 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/eventsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostEventStreamDeadLetterReplay(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	mes := &eventsmocks.Stream{}
+	streamID := apitypes.NewULID()
+	m.eventStreams[*streamID] = mes
+	mes.On("Stop", mock.Anything).Return(nil).Maybe()
+	mes.On("ReplayDeadLetters", mock.Anything).Return(&apitypes.DeadLetterReplayResult{
+		StreamID:  streamID,
+		Delivered: 2,
+		Remaining: 1,
+	}, nil).Once()
+	mes.On("ReplayDeadLetters", mock.Anything).Return(nil, fmt.Errorf("pop")).Once()
+
+	var result apitypes.DeadLetterReplayResult
+	res, err := resty.New().R().
+		SetBody(struct{}{}).
+		SetResult(&result).
+		Post(url + "/eventstreams/" + streamID.String() + "/deadletter/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, streamID, result.StreamID)
+	assert.Equal(t, 2, result.Delivered)
+	assert.Equal(t, 1, result.Remaining)
+
+	res, err = resty.New().R().
+		SetBody(struct{}{}).
+		Post(url + "/eventstreams/" + streamID.String() + "/deadletter/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 500, res.StatusCode())
+	assert.Regexp(t, "pop", res.String())
+
+	// Not found
+	res, err = resty.New().R().
+		SetBody(struct{}{}).
+		Post(url + "/eventstreams/" + fftypes.NewUUID().String() + "/deadletter/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+
+	// Bad ID
+	res, err = resty.New().R().
+		SetBody(struct{}{}).
+		Post(url + "/eventstreams/bad/deadletter/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+
+	mes.AssertExpectations(t)
+}
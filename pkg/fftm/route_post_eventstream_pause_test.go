@@ -0,0 +1,80 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostEventStreamPauseResume(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	// Create stream
+	var es apitypes.EventStream
+	res, err := resty.New().R().
+		SetBody(&apitypes.EventStream{
+			Name: strPtr("my event stream"),
+		}).
+		SetResult(&es).
+		Post(url + "/eventstreams")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+
+	// Then pause it
+	res, err = resty.New().R().
+		SetBody(&struct{}{}).
+		Post(url + "/eventstreams/" + es.ID.String() + "/pause")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, apitypes.EventStreamStatusStopped, m.eventStreams[(*es.ID)].Status())
+
+	// The suspended flag is persisted, so the stream stays paused over a restart
+	persisted, err := m.persistence.GetStream(m.ctx, es.ID)
+	assert.NoError(t, err)
+	assert.True(t, *persisted.Suspended)
+
+	// Then resume it
+	res, err = resty.New().R().
+		SetBody(&struct{}{}).
+		Post(url + "/eventstreams/" + es.ID.String() + "/resume")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, apitypes.EventStreamStatusStarted, m.eventStreams[(*es.ID)].Status())
+
+	persisted, err = m.persistence.GetStream(m.ctx, es.ID)
+	assert.NoError(t, err)
+	assert.False(t, *persisted.Suspended)
+
+	mfc.AssertExpectations(t)
+
+}
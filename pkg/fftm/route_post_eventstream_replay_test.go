@@ -0,0 +1,166 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/eventsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type replayConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.EventReplayAPI
+}
+
+func TestPostEventStreamReplay(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mra := &ffcapimocks.EventReplayAPI{}
+	m.connector = &replayConnector{API: mfc, EventReplayAPI: mra}
+	mfc.On("EventStreamNewCheckpointStruct").Return(func() ffcapi.EventListenerCheckpoint { return &testBlockCheckpoint{} })
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventListenerAdd", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerAddResponse{}, ffcapi.ErrorReason(""), nil)
+	mcm := m.confirmations.(*confirmationsmocks.Manager)
+	mcm.On("HighestBlockSeen").Return(uint64(2000))
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	// Create a stream and listener
+	es := newTestSSEStream(t, url, "sse")
+	var l apitypes.Listener
+	res, err := resty.New().R().SetBody(&apitypes.Listener{Name: strPtr("listener1")}).SetResult(&l).Post(url + "/eventstreams/" + es.ID.String() + "/listeners")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+
+	mra.On("EventListenerReplay", mock.Anything, mock.MatchedBy(func(req *ffcapi.EventListenerReplayRequest) bool {
+		return req.ListenerID.Equals(l.ID) && req.FromBlock == 1000 && req.ToBlock == 1980
+	})).Return(&ffcapi.EventListenerReplayResponse{
+		Events: ffcapi.ListenerEvents{
+			{
+				Checkpoint: &testBlockCheckpoint{Block: 1001},
+				Event:      &ffcapi.Event{ID: ffcapi.EventID{ListenerID: l.ID, BlockNumber: 1001}},
+			},
+		},
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	// The head is 2000, and the stream requires 20 confirmations
+	var result apitypes.EventStreamReplayResult
+	res, err = resty.New().R().
+		SetBody(map[string]interface{}{"fromBlock": 1000, "toBlock": 1980}).
+		SetResult(&result).
+		Post(url + "/eventstreams/" + es.ID.String() + "/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Equal(t, es.ID, result.StreamID)
+	assert.Equal(t, uint64(1980), result.ToBlock.Uint64())
+	assert.Equal(t, 1, result.Events)
+
+	res, err = resty.New().R().
+		SetBody(map[string]interface{}{"fromBlock": 1000, "toBlock": 1981}).
+		Post(url + "/eventstreams/" + es.ID.String() + "/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21124", res.String())
+
+	res, err = resty.New().R().
+		SetBody(map[string]interface{}{"fromBlock": 1001, "toBlock": 1000}).
+		Post(url + "/eventstreams/" + es.ID.String() + "/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21123", res.String())
+
+	res, err = resty.New().R().
+		SetBody(map[string]interface{}{"fromBlock": 1000}).
+		Post(url + "/eventstreams/" + es.ID.String() + "/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21123", res.String())
+
+	// Not found
+	res, err = resty.New().R().
+		SetBody(map[string]interface{}{"fromBlock": 1000, "toBlock": 1980}).
+		Post(url + "/eventstreams/" + fftypes.NewUUID().String() + "/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+
+	// Bad ID
+	res, err = resty.New().R().
+		SetBody(map[string]interface{}{"fromBlock": 1000, "toBlock": 1980}).
+		Post(url + "/eventstreams/bad/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+
+	mra.AssertExpectations(t)
+}
+
+func TestPostEventStreamReplayHeadUnknown(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	mes := &eventsmocks.Stream{}
+	confirmations := uint64(0)
+	mes.On("Spec").Return(&apitypes.EventStream{Confirmations: &confirmations})
+	streamID := apitypes.NewULID()
+	m.eventStreams[*streamID] = mes
+	mcm := m.confirmations.(*confirmationsmocks.Manager)
+	mcm.On("HighestBlockSeen").Return(uint64(0))
+
+	block := fftypes.FFuint64(1)
+	_, err := m.replayStream(m.ctx, streamID.String(), &apitypes.EventStreamReplay{
+		FromBlock: &block,
+		ToBlock:   &block,
+	})
+	assert.Regexp(t, "FF21124", err)
+}
+
+func TestPostEventStreamReplayNotSupported(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	err := m.Start()
+	assert.NoError(t, err)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Capabilities: []ffcapi.Capability{ffcapi.CapabilityTrace},
+	}, ffcapi.ErrorReason(""), nil)
+	_, err = m.refreshConnectorInfo(m.ctx)
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		SetBody(map[string]interface{}{"fromBlock": 1000, "toBlock": 1980}).
+		Post(url + "/eventstreams/" + fftypes.NewUUID().String() + "/replay")
+	assert.NoError(t, err)
+	assert.Equal(t, 501, res.StatusCode())
+	assert.Regexp(t, "FF21122", res.String())
+}
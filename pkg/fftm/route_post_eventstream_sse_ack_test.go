@@ -0,0 +1,70 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostEventStreamSSEAck(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	es := newTestSSEStream(t, url, "sse")
+
+	// Nothing is awaiting an ack, as no client is connected
+	res, err := resty.New().R().
+		SetBody(&struct{}{}).
+		Post(url + "/eventstreams/" + es.ID.String() + "/sse/ack?batch=1")
+	assert.NoError(t, err)
+	assert.Equal(t, 409, res.StatusCode())
+	assert.Regexp(t, "FF21103", res.String())
+
+	res, err = resty.New().R().
+		SetBody(&struct{}{}).
+		Post(url + "/eventstreams/" + es.ID.String() + "/sse/ack?batch=wrong")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21105", res.String())
+
+	res, err = resty.New().R().
+		SetBody(&struct{}{}).
+		Post(url + "/eventstreams/6cb1bd4d-c6b4-4d2d-95d5-a5ab1a6be3e1/sse/ack?batch=1")
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+
+	res, err = resty.New().R().
+		SetBody(&struct{}{}).
+		Post(url + "/eventstreams/bad/sse/ack?batch=1")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+
+}
@@ -0,0 +1,165 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostEventStreamsImportRoundTrip(t *testing.T) {
+
+	// Export from one manager
+	url1, m1, done1 := newTestManager(t)
+	defer done1()
+	mockStreamConnector(m1)
+	err := m1.Start()
+	assert.NoError(t, err)
+
+	batchSize := uint64(10)
+	var es1 apitypes.EventStream
+	res, err := resty.New().R().SetBody(&apitypes.EventStream{Name: strPtr("stream1"), BatchSize: &batchSize}).SetResult(&es1).Post(url1 + "/eventstreams")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	for _, name := range []string{"listener1", "listener2"} {
+		res, err = resty.New().R().SetBody(&apitypes.Listener{Name: strPtr(name), FromBlock: strPtr("12345")}).Post(fmt.Sprintf("%s/eventstreams/%s/listeners", url1, es1.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+	}
+	res, err = resty.New().R().SetBody(&apitypes.EventStream{Name: strPtr("stream2"), Suspended: boolPtr(true)}).Post(url1 + "/eventstreams")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+
+	var export apitypes.EventStreamExport
+	res, err = resty.New().R().SetResult(&export).Get(url1 + "/eventstreams/export")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+
+	// Import into a fresh manager
+	url2, m2, done2 := newTestManager(t)
+	defer done2()
+	mockStreamConnector(m2)
+	err = m2.Start()
+	assert.NoError(t, err)
+	m2.maxBatchLength = 2 // at the limit
+
+	var results []*apitypes.EventStreamImportResult
+	res, err = resty.New().R().
+		SetBody(&apitypes.EventStreamImport{EventStreamExport: export, FromBlock: strPtr("latest")}).
+		SetResult(&results).
+		Post(url2 + "/eventstreams/import")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, results, 2)
+	assert.Equal(t, "stream1", results[0].Name)
+	assert.Equal(t, apitypes.EventStreamImportCreated, results[0].Status)
+	assert.Equal(t, 2, results[0].Listeners)
+	assert.NotNil(t, results[0].ID)
+	assert.Equal(t, apitypes.EventStreamImportCreated, results[1].Status)
+	assert.Zero(t, results[1].Listeners)
+
+	stream1, err := m2.getStream(m2.ctx, results[0].ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "stream1", *stream1.Name)
+	assert.Equal(t, uint64(10), *stream1.BatchSize)
+	assert.Equal(t, apitypes.EventStreamStatusStarted, stream1.Status)
+	listeners, err := m2.persistence.ListStreamListeners(m2.ctx, nil, 0, persistence.SortDirectionAscending, results[0].ID)
+	assert.NoError(t, err)
+	assert.Len(t, listeners, 2)
+	assert.Equal(t, "listener1", *listeners[0].Name)
+	assert.Equal(t, "latest", *listeners[0].FromBlock)
+	stream2, err := m2.getStream(m2.ctx, results[1].ID.String())
+	assert.NoError(t, err)
+	assert.True(t, *stream2.Suspended)
+	assert.Equal(t, apitypes.EventStreamStatusStopped, stream2.Status)
+
+	// Exporting again from the fresh manager gives the same document
+	var reexport apitypes.EventStreamExport
+	res, err = resty.New().R().SetResult(&reexport).Get(url2 + "/eventstreams/export")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, reexport.Streams, 2)
+	assert.Equal(t, *export.Streams[0].BatchSize, *reexport.Streams[0].BatchSize)
+	assert.Len(t, reexport.Streams[0].Listeners, 2)
+
+	// Importing again skips the existing streams by default
+	res, err = resty.New().R().
+		SetBody(&apitypes.EventStreamImport{EventStreamExport: export}).
+		SetResult(&results).
+		Post(url2 + "/eventstreams/import")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, apitypes.EventStreamImportSkipped, results[0].Status)
+	assert.Equal(t, stream1.ID, results[0].ID)
+	assert.Equal(t, apitypes.EventStreamImportSkipped, results[1].Status)
+
+	// Or overwrites them, replacing the stream
+	res, err = resty.New().R().
+		SetBody(&apitypes.EventStreamImport{EventStreamExport: export, OnConflict: apitypes.EventStreamImportOverwrite}).
+		SetResult(&results).
+		Post(url2 + "/eventstreams/import")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, apitypes.EventStreamImportOverwritten, results[0].Status)
+	assert.NotEqual(t, stream1.ID, results[0].ID)
+	assert.Equal(t, 2, results[0].Listeners)
+	listeners, err = m2.persistence.ListStreamListeners(m2.ctx, nil, 0, persistence.SortDirectionAscending, results[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", *listeners[0].FromBlock)
+	_, err = m2.getStream(m2.ctx, stream1.ID.String())
+	assert.Regexp(t, "FF21045", err)
+
+}
+
+func TestPostEventStreamsImportInvalid(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	err := m.Start()
+	assert.NoError(t, err)
+
+	for body, errCode := range map[string]string{
+		`{"version":2,"streams":[]}`:                                  "FF21142",
+		`{"version":1,"onConflict":"merge","streams":[]}`:             "FF21145",
+		`{"version":1,"streams":[{"name":"s1"},{}]}`:                  "FF21143",
+		`{"version":1,"streams":[{"name":"s1"},{"name":"s1"}]}`:       "FF21144",
+		`{"version":1,"streams":[{"name":"s1","listeners":[]},null]}`: "FF21143",
+	} {
+		res, err := resty.New().R().SetBody(body).SetHeader("Content-Type", "application/json").Post(url + "/eventstreams/import")
+		assert.NoError(t, err)
+		assert.Equal(t, 400, res.StatusCode())
+		assert.Regexp(t, errCode, res.String())
+	}
+
+	// Too many streams in one import
+	m.maxBatchLength = 1
+	res, err := resty.New().R().SetBody(`{"version":1,"streams":[{"name":"s1"},{"name":"s2"}]}`).SetHeader("Content-Type", "application/json").Post(url + "/eventstreams/import")
+	assert.NoError(t, err)
+	assert.Equal(t, 413, res.StatusCode())
+	assert.Regexp(t, "FF21152", res.String())
+
+	// Nothing was created
+	export, err := m.exportStreams(m.ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, export.Streams)
+
+}
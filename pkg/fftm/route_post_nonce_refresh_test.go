@@ -0,0 +1,211 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func postNonceRefreshAsync(url, signer string) chan *apitypes.NonceRefresh {
+	result := make(chan *apitypes.NonceRefresh)
+	go func() {
+		var refresh apitypes.NonceRefresh
+		res, err := resty.New().R().
+			SetBody(&struct{}{}).
+			SetResult(&refresh).
+			Post(url + "/nonces/" + signer + "/refresh")
+		if err != nil || res.StatusCode() != 200 {
+			close(result)
+			return
+		}
+		result <- &refresh
+	}()
+	return result
+}
+
+func TestPostNonceRefreshExternalTransactionAdvancedChain(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	// Our local state is fresh, so would be used to allocate 21 without asking the chain.
+	// But transactions sent outside of FFTM have used nonces 21-24.
+	newTestTxn(t, m, "0xaaaaa", 20, apitypes.TxStatusPending)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(25),
+	}, ffcapi.ErrorReason(""), nil)
+
+	refresh := <-postNonceRefreshAsync(url, "0xaaaaa")
+	assert.Equal(t, "0xaaaaa", refresh.Signer)
+	assert.Equal(t, int64(21), refresh.OldNextNonce.Int64())
+	assert.Equal(t, int64(25), refresh.NewNextNonce.Int64())
+	assert.Equal(t, int64(25), refresh.ChainNextNonce.Int64())
+
+	// The next transaction is allocated the nonce after the external transactions
+	ln, err := m.assignAndLockNonce(m.ctx, "ns1:next", "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(25), ln.nonce)
+	newTestTxn(t, m, "0xaaaaa", 25, apitypes.TxStatusPending)
+	ln.complete(m.ctx)
+
+	// Subsequent transactions follow on from our local state again
+	ln, err = m.assignAndLockNonce(m.ctx, "ns1:after", "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(26), ln.nonce)
+	ln.complete(m.ctx)
+
+}
+
+func TestPostNonceRefreshWaitsForAssignment(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	newTestTxn(t, m, "0xaaaaa", 20, apitypes.TxStatusPending)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(25),
+	}, ffcapi.ErrorReason(""), nil)
+
+	// An assignment is in progress, so the refresh waits for it
+	inProgress, err := m.assignAndLockNonce(m.ctx, "ns1:inprogress", "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(21), inProgress.nonce)
+	refreshResult := postNonceRefreshAsync(url, "0xaaaaa")
+	select {
+	case <-refreshResult:
+		assert.Fail(t, "refresh did not wait for the nonce lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The in-progress transaction is written, and the refresh picks up from there
+	newTestTxn(t, m, "0xaaaaa", 21, apitypes.TxStatusPending)
+	inProgress.complete(m.ctx)
+	refresh := <-refreshResult
+	assert.Equal(t, int64(22), refresh.OldNextNonce.Int64())
+	assert.Equal(t, int64(25), refresh.NewNextNonce.Int64())
+
+	m.mux.Lock()
+	assert.Empty(t, m.lockedNonces)
+	m.mux.Unlock()
+
+}
+
+func TestPostNonceRefreshNeverMovesBackwards(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	// The chain has not yet mined our pending transactions
+	newTestTxn(t, m, "0xaaaaa", 20, apitypes.TxStatusPending)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(18),
+	}, ffcapi.ErrorReason(""), nil)
+
+	refresh := <-postNonceRefreshAsync(url, "0xaaaaa")
+	assert.Equal(t, int64(21), refresh.OldNextNonce.Int64())
+	assert.Equal(t, int64(21), refresh.NewNextNonce.Int64())
+	assert.Equal(t, int64(18), refresh.ChainNextNonce.Int64())
+	m.mux.Lock()
+	assert.Empty(t, m.nonceRealignments)
+	m.mux.Unlock()
+
+	// A pending realignment from a reset is reported as the old value
+	m.mux.Lock()
+	m.nonceRealignments["0xaaaaa"] = 30
+	m.mux.Unlock()
+	refresh = <-postNonceRefreshAsync(url, "0xaaaaa")
+	assert.Equal(t, int64(30), refresh.OldNextNonce.Int64())
+	assert.Equal(t, int64(30), refresh.NewNextNonce.Int64())
+
+}
+
+func TestPostNonceRefreshChainQueryFail(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	res, err := resty.New().R().
+		SetBody(&struct{}{}).
+		Post(url + "/nonces/0xaaaaa/refresh")
+	assert.NoError(t, err)
+	assert.Equal(t, 500, res.StatusCode())
+
+	// The lock is released, and nothing is changed
+	m.mux.Lock()
+	assert.Empty(t, m.lockedNonces)
+	assert.Empty(t, m.nonceRealignments)
+	m.mux.Unlock()
+
+}
+
+func TestRefreshNonceAllocatorFail(t *testing.T) {
+
+	_, m, done := newTestManagerMockPersistence(t)
+	defer done()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, "0xaaaaa", mock.Anything, 1, mock.Anything).Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.refreshNonce(m.ctx, "", "0xaaaaa")
+	assert.Regexp(t, "pop", err)
+	assert.Empty(t, m.lockedNonces)
+
+}
+
+func TestRefreshNonceNotLeader(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+	m.leaderElection = true
+
+	_, err := m.refreshNonce(m.ctx, "", "0xaaaaa")
+	assert.Regexp(t, "FF21098", err)
+
+}
@@ -0,0 +1,266 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func reserveTestNonce(t *testing.T, url string, body interface{}) *apitypes.NonceReservation {
+	var res apitypes.NonceReservation
+	httpRes, err := resty.New().R().
+		SetBody(body).
+		SetResult(&res).
+		Post(url + "/nonces/0xaaaaa/reserve")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, httpRes.StatusCode())
+	return &res
+}
+
+func submitTestLocalNonce(t *testing.T, m *manager, id string) uint64 {
+	mtx, err := m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: id}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, fftypes.NewFFBigInt(100000), "0x123456")
+	assert.NoError(t, err)
+	return mtx.Nonce.Uint64()
+}
+
+func TestPostNonceReserveThenSubmit(t *testing.T) {
+
+	url, m, mfc, _, done := newTestRawManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(10),
+	}, ffcapi.ErrorReason(""), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	reservation := reserveTestNonce(t, url, &apitypes.NonceReservationRequest{})
+	assert.NotNil(t, reservation.ID)
+	assert.Equal(t, "0xaaaaa", reservation.Signer)
+	assert.Equal(t, int64(10), reservation.Nonce.Int64())
+	assert.True(t, time.Time(*reservation.Expiry).After(time.Time(*reservation.Created)))
+
+	// The reserved nonce is skipped for transactions we assign nonces to
+	assert.Equal(t, uint64(11), submitTestLocalNonce(t, m, "ns1:local1"))
+
+	// The signed transaction is submitted with the reservation, which supplies the nonce
+	raw := sampleRawTX(0)
+	raw.Nonce = nil
+	raw.ReservationID = reservation.ID
+	var mtx apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetBody(raw).
+		SetResult(&mtx).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Equal(t, int64(10), mtx.Nonce.Int64())
+	assert.Equal(t, "0xf86c0a8502540be400", mtx.RawTransaction)
+
+	// The reservation is consumed
+	raw.Headers.ID = "ns1:raw-again"
+	res, err = resty.New().R().
+		SetBody(raw).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+	assert.Regexp(t, "FF21166", res.String())
+	m.mux.Lock()
+	assert.Empty(t, m.nonceReservations)
+	m.mux.Unlock()
+
+	assert.Equal(t, uint64(12), submitTestLocalNonce(t, m, "ns1:local2"))
+
+}
+
+func TestPostNonceReserveExpireReclaim(t *testing.T) {
+
+	url, m, mfc, _, done := newTestRawManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(10),
+	}, ffcapi.ErrorReason(""), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	ttl := fftypes.FFDuration(1 * time.Hour)
+	r1 := reserveTestNonce(t, url, &apitypes.NonceReservationRequest{TTL: &ttl})
+	assert.Equal(t, int64(10), r1.Nonce.Int64())
+	r2 := reserveTestNonce(t, url, &apitypes.NonceReservationRequest{TTL: &ttl})
+	assert.Equal(t, int64(11), r2.Nonce.Int64())
+	assert.Equal(t, uint64(12), submitTestLocalNonce(t, m, "ns1:local1"))
+
+	// Both reservations expire unused, leaving a gap before the transaction at nonce 12
+	m.mux.Lock()
+	past := fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	m.nonceReservations[*r1.ID].Expiry = &past
+	m.nonceReservations[*r2.ID].Expiry = &past
+	m.mux.Unlock()
+
+	// An expired reservation cannot be used
+	raw := sampleRawTX(10)
+	raw.ReservationID = r1.ID
+	res, err := resty.New().R().
+		SetBody(raw).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+	assert.Regexp(t, "FF21166", res.String())
+
+	// The gap is filled by the next transactions, lowest first, before new nonces are allocated
+	assert.Equal(t, uint64(10), submitTestLocalNonce(t, m, "ns1:local2"))
+	assert.Equal(t, uint64(11), submitTestLocalNonce(t, m, "ns1:local3"))
+	assert.Equal(t, uint64(13), submitTestLocalNonce(t, m, "ns1:local4"))
+	m.mux.Lock()
+	assert.Empty(t, m.nonceReservations)
+	assert.Empty(t, m.reclaimedNonces)
+	m.mux.Unlock()
+
+}
+
+func TestNonceReclaimDiscardsUsedNonces(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(11),
+	}, ffcapi.ErrorReason(""), nil)
+
+	// 10 was mined, having been submitted directly. 12 was recorded as a transaction.
+	newTestTxn(t, m, "0xaaaaa", 12, apitypes.TxStatusPending)
+	m.reclaimedNonces["0xaaaaa"] = []uint64{10, 12, 13}
+
+	ln, err := m.assignAndLockNonce(m.ctx, "ns1:tx1", "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(13), ln.nonce)
+	ln.complete(m.ctx)
+	assert.Empty(t, m.reclaimedNonces["0xaaaaa"])
+
+}
+
+func TestNonceReclaimErrors(t *testing.T) {
+
+	_, m, done := newTestManagerMockPersistence(t)
+	defer done()
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, "0xaaaaa", mock.Anything, 1, mock.Anything).Return(nil, fmt.Errorf("pop")).Once()
+	mp.On("ListTransactionsByNonce", mock.Anything, "0xaaaaa", mock.Anything, 1, mock.Anything).Return([]*apitypes.ManagedTX{}, nil)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("snap"))
+
+	m.reclaimedNonces["0xaaaaa"] = []uint64{10}
+
+	_, err := m.assignAndLockNonce(m.ctx, "ns1:tx1", "", "0xaaaaa")
+	assert.Regexp(t, "pop", err)
+
+	_, err = m.assignAndLockNonce(m.ctx, "ns1:tx1", "", "0xaaaaa")
+	assert.Regexp(t, "snap", err)
+
+	// The nonce remains reclaimed, and the lock is released
+	assert.Equal(t, []uint64{10}, m.reclaimedNonces["0xaaaaa"])
+	assert.Empty(t, m.lockedNonces)
+
+}
+
+func TestNonceReservationErrors(t *testing.T) {
+
+	url, m, mfc, _, done := newTestRawManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(10),
+	}, ffcapi.ErrorReason(""), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	zero := fftypes.FFDuration(0)
+	res, err := resty.New().R().
+		SetBody(&apitypes.NonceReservationRequest{TTL: &zero}).
+		Post(url + "/nonces/0xaaaaa/reserve")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21168", res.String())
+
+	m.signersDeny = signerSet([]string{"0xbbbbb"})
+	res, err = resty.New().R().
+		SetBody(&apitypes.NonceReservationRequest{}).
+		Post(url + "/nonces/0xbbbbb/reserve")
+	assert.NoError(t, err)
+	assert.Equal(t, 403, res.StatusCode())
+
+	reservation := reserveTestNonce(t, url, &apitypes.NonceReservationRequest{})
+
+	// Signed with a different nonce
+	raw := sampleRawTX(11)
+	raw.ReservationID = reservation.ID
+	_, err = m.sendRawTransaction(m.ctx, raw)
+	assert.Regexp(t, "FF21167.*10.*0xaaaaa", err)
+
+	// Signed by a different signer
+	raw = sampleRawTX(10)
+	raw.From = "0xccccc"
+	raw.ReservationID = reservation.ID
+	_, err = m.sendRawTransaction(m.ctx, raw)
+	assert.Regexp(t, "FF21167", err)
+
+	// Already being used by another submission
+	m.mux.Lock()
+	m.nonceReservations[*reservation.ID].consuming = true
+	m.mux.Unlock()
+	raw = sampleRawTX(10)
+	raw.ReservationID = reservation.ID
+	_, err = m.sendRawTransaction(m.ctx, raw)
+	assert.Regexp(t, "FF21169", err)
+	m.mux.Lock()
+	m.nonceReservations[*reservation.ID].consuming = false
+	m.mux.Unlock()
+
+	// A failed submission leaves the reservation in place, to be retried
+	raw.RawTransaction = ""
+	_, err = m.sendRawTransaction(m.ctx, raw)
+	assert.Regexp(t, "FF21", err)
+	raw.RawTransaction = "0xf86c0a8502540be400"
+	raw.Headers.SubmissionTimeout = new(fftypes.FFDuration)
+	*raw.Headers.SubmissionTimeout = -1
+	_, err = m.sendRawTransaction(m.ctx, raw)
+	assert.Error(t, err)
+	m.mux.Lock()
+	assert.False(t, m.nonceReservations[*reservation.ID].consuming)
+	m.mux.Unlock()
+
+	raw.Headers.SubmissionTimeout = nil
+	mtx, err := m.sendRawTransaction(m.ctx, raw)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), mtx.Nonce.Int64())
+
+}
@@ -0,0 +1,119 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostNonceResetRealignsWithChain(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	// Our local state is ahead of the chain
+	newTestTxn(t, m, "0xaaaaa", 20, apitypes.TxStatusPending)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: "0xaaaaa"}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(18),
+	}, ffcapi.ErrorReason(""), nil)
+
+	// A lock that is never completed blocks the next allocation for the signer
+	stuck, err := m.assignAndLockNonce(m.ctx, "ns1:stuck", "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(21), stuck.nonce)
+	waiterResult := make(chan *lockedNonce)
+	go func() {
+		ln, err := m.assignAndLockNonce(m.ctx, "ns1:waiter", "", "0xaaaaa")
+		assert.NoError(t, err)
+		waiterResult <- ln
+	}()
+
+	var status apitypes.NonceStatus
+	res, err := resty.New().R().
+		SetBody(&struct{}{}).
+		SetResult(&status).
+		Post(url + "/nonces/0xaaaaa/reset")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, "0xaaaaa", status.Signer)
+	assert.False(t, status.Locked)
+	assert.Equal(t, int64(18), status.ChainNextNonce.Int64())
+
+	// The waiter is released, and is allocated the next nonce from the chain
+	waiter := <-waiterResult
+	assert.Equal(t, uint64(18), waiter.nonce)
+
+	// The stuck routine completing late does not disturb the new lock holder
+	stuck.complete(m.ctx)
+	m.mux.Lock()
+	assert.Equal(t, waiter, m.lockedNonces["0xaaaaa"])
+	m.mux.Unlock()
+	waiter.complete(m.ctx)
+
+	// The realignment only applies once
+	next, err := m.assignAndLockNonce(m.ctx, "ns1:next", "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(21), next.nonce)
+	next.complete(m.ctx)
+
+}
+
+func TestPostNonceResetChainQueryFail(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	m.mux.Lock()
+	ln := &lockedNonce{m: m, signer: "0xaaaaa", unlocked: make(chan struct{})}
+	m.lockedNonces["0xaaaaa"] = ln
+	m.mux.Unlock()
+
+	res, err := resty.New().R().
+		SetBody(&struct{}{}).
+		Post(url + "/nonces/0xaaaaa/reset")
+	assert.NoError(t, err)
+	assert.Equal(t, 500, res.StatusCode())
+
+	// The lock is left in place
+	m.mux.Lock()
+	assert.Equal(t, ln, m.lockedNonces["0xaaaaa"])
+	assert.Empty(t, m.nonceRealignments)
+	m.mux.Unlock()
+
+}
@@ -0,0 +1,106 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostTransactionCancel(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	tx := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	tx.TransactionHash = "0x12345"
+	tx.FirstSubmit = fftypes.Now()
+	err = m.persistence.WriteTransaction(m.ctx, tx, true)
+	assert.NoError(t, err)
+
+	var txOut *apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetBody(&struct{}{}).
+		SetResult(&txOut).
+		Post(fmt.Sprintf("%s/transactions/%s/cancel", url, tx.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Equal(t, tx.ID, txOut.ID)
+	assert.NotNil(t, txOut.Cancel.Requested)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, rtx.Cancel)
+
+}
+
+func TestPostTransactionCancelComplete(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	tx := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+
+	var errRes fftypes.RESTError
+	res, err := resty.New().R().
+		SetBody(&struct{}{}).
+		SetError(&errRes).
+		Post(fmt.Sprintf("%s/transactions/%s/cancel", url, tx.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, res.StatusCode())
+	assert.Regexp(t, "FF21119", errRes.Error)
+
+}
+
+func TestPostTransactionCancelNotSubmitted(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	tx := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+
+	var errRes fftypes.RESTError
+	res, err := resty.New().R().
+		SetBody(&struct{}{}).
+		SetError(&errRes).
+		Post(fmt.Sprintf("%s/transactions/%s/cancel", url, tx.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, res.StatusCode())
+	assert.Regexp(t, "FF21114", errRes.Error)
+
+}
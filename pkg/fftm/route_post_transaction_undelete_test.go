@@ -0,0 +1,132 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostTransactionUndelete(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	m.deletedRetention = 1 * time.Hour
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateDelete, ffcapi.ErrorReason(""), nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	listIDs := func(query string) []string {
+		var txns []*apitypes.ManagedTX
+		res, err := resty.New().R().
+			SetResult(&txns).
+			Get(fmt.Sprintf("%s/transactions?%s", url, query))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+		ids := []string{}
+		for _, tx := range txns {
+			ids = append(ids, tx.ID)
+		}
+		return ids
+	}
+
+	// Deletion retains the transaction, but hides it from listings
+	var txOut *apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetResult(&txOut).
+		Delete(fmt.Sprintf("%s/transactions/%s", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, apitypes.TxStatusDeleted, txOut.Status)
+	assert.Equal(t, apitypes.TxStatusSucceeded, txOut.DeletedStatus)
+	assert.NotNil(t, txOut.Deleted)
+	assert.Empty(t, listIDs(""))
+	assert.Empty(t, listIDs("signer=0xaaaaa"))
+	assert.Equal(t, []string{txIn.ID}, listIDs("includeDeleted=true"))
+	assert.Equal(t, []string{txIn.ID}, listIDs("status=deleted"))
+
+	// Undelete restores it
+	txOut = nil
+	res, err = resty.New().R().
+		SetBody(struct{}{}).
+		SetResult(&txOut).
+		Post(fmt.Sprintf("%s/transactions/%s/undelete", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, apitypes.TxStatusSucceeded, txOut.Status)
+	assert.Nil(t, txOut.Deleted)
+	assert.Nil(t, txOut.DeleteRequested)
+	assert.Equal(t, apitypes.TxActionUndeleted, txOut.History[len(txOut.History)-1].Action)
+	assert.Equal(t, []string{txIn.ID}, listIDs(""))
+
+	// Only a deleted transaction can be restored
+	res, err = resty.New().R().
+		SetBody(struct{}{}).
+		Post(fmt.Sprintf("%s/transactions/%s/undelete", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, res.StatusCode())
+	assert.Regexp(t, "FF21148", res.String())
+
+	// Deleting a deleted transaction removes it immediately
+	for i := 0; i < 2; i++ {
+		res, err = resty.New().R().
+			Delete(fmt.Sprintf("%s/transactions/%s", url, txIn.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, res.StatusCode())
+	}
+	res, err = resty.New().R().
+		Get(fmt.Sprintf("%s/transactions/%s", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+
+}
+
+func TestPostTransactionUndeletePending(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+	m.deletedRetention = 1 * time.Hour
+	noopPolicyEngine(m)
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusDeleted)
+	txIn.DeletedStatus = apitypes.TxStatusPending
+	err := m.persistence.WriteTransaction(m.ctx, txIn, true)
+	assert.NoError(t, err)
+
+	err = m.undelete(m.ctx, &pendingState{mtx: txIn})
+	assert.NoError(t, err)
+
+	// It is back in the pending index, to be picked up by the policy loop
+	m.updateInflightSet(m.ctx)
+	assert.Len(t, m.inflight, 1)
+	assert.Equal(t, txIn.ID, m.inflight[0].mtx.ID)
+
+}
@@ -0,0 +1,46 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"net/http"
+
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+var postTransactionsEstimate = func(m *manager) *ffapi.Route {
+	return &ffapi.Route{
+		Name:            "postTransactionsEstimate",
+		Path:            "/transactions/estimate",
+		Method:          http.MethodPost,
+		PathParams:      nil,
+		QueryParams:     nil,
+		Description:     tmmsgs.APIEndpointPostTransactionsEstimate,
+		JSONInputValue:  func() interface{} { return &apitypes.TransactionRequest{} },
+		JSONOutputValue: func() interface{} { return &apitypes.TransactionEstimate{} },
+		JSONOutputCodes: []int{http.StatusOK},
+		JSONHandler: func(r *ffapi.APIRequest) (output interface{}, err error) {
+			req := r.Input.(*apitypes.TransactionRequest)
+			if err = m.resolveSigner(r.Req.Context(), &req.Headers, &req.TransactionHeaders); err != nil {
+				return nil, err
+			}
+			return m.estimateTransaction(r.Req.Context(), req)
+		},
+	}
+}
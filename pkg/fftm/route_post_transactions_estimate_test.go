@@ -0,0 +1,317 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// estimatingPolicyEngine is a policy engine that implements the optional gas price estimation interface
+type estimatingPolicyEngine struct {
+	*policyenginemocks.PolicyEngine
+	*policyenginemocks.GasPriceEstimator
+}
+
+func newTestEstimatingPolicyEngine(m *manager) *policyenginemocks.GasPriceEstimator {
+	mge := &policyenginemocks.GasPriceEstimator{}
+	m.policyEngine = &estimatingPolicyEngine{&policyenginemocks.PolicyEngine{}, mge}
+	return mge
+}
+
+func TestPostTransactionsEstimateGasLimitMultiplier(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	m.blockGasLimit = 2500000
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+
+	mpe := newTestEstimatingPolicyEngine(m)
+	mpe.On("EstimateGasPrice", mock.Anything, mFFC, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.Gas.Int64() == 2500000
+	})).Return(fftypes.JSONAnyPtr(`"100"`), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var estimate apitypes.TransactionEstimate
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{
+				GasLimitMultiplier: 1.5,
+			},
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{
+					From: "0x12345",
+				},
+			},
+		}).
+		SetResult(&estimate).
+		Post(fmt.Sprintf("%s/transactions/estimate", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, int64(2500000), estimate.Gas.Int64())
+
+	mFFC.AssertExpectations(t)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestPostTransactionsEstimate(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.MatchedBy(func(r *ffcapi.TransactionPrepareRequest) bool {
+		return r.From == "0x12345"
+	})).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+
+	mpe := newTestEstimatingPolicyEngine(m)
+	mpe.On("EstimateGasPrice", mock.Anything, mFFC, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.Gas.Int64() == 2000000 && mtx.TransactionData == "RAW_UNSIGNED_BYTES"
+	})).Return(fftypes.JSONAnyPtr(`{"maxFeePerGas":"100","maxPriorityFeePerGas":"10"}`), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var estimate apitypes.TransactionEstimate
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{
+					From: "0x12345",
+				},
+			},
+		}).
+		SetResult(&estimate).
+		Post(fmt.Sprintf("%s/transactions/estimate", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, int64(2000000), estimate.Gas.Int64())
+	assert.JSONEq(t, `{"maxFeePerGas":"100","maxPriorityFeePerGas":"10"}`, estimate.GasPrice.String())
+	assert.Empty(t, estimate.RevertReason)
+
+	mFFC.AssertExpectations(t)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestPostTransactionsEstimateReverted(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("execution reverted: not enough tokens"))
+
+	mpe := newTestEstimatingPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var estimate apitypes.TransactionEstimate
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{}).
+		SetResult(&estimate).
+		Post(fmt.Sprintf("%s/transactions/estimate", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, "execution reverted: not enough tokens", estimate.RevertReason)
+	assert.Nil(t, estimate.Gas)
+	assert.Nil(t, estimate.GasPrice)
+
+	mpe.AssertNotCalled(t, "EstimateGasPrice", mock.Anything, mock.Anything, mock.Anything)
+
+}
+
+func TestPostTransactionsEstimateRevertedEncoded(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	// The connector returned the ABI encoded Error("not enough tokens"), rather than decoding it
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("execution reverted: 0x08c379a0"+
+			"0000000000000000000000000000000000000000000000000000000000000020"+
+			"0000000000000000000000000000000000000000000000000000000000000011"+
+			"6e6f7420656e6f75676820746f6b656e73000000000000000000000000000000"))
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var estimate apitypes.TransactionEstimate
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{}).
+		SetResult(&estimate).
+		Post(fmt.Sprintf("%s/transactions/estimate", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, "not enough tokens", estimate.RevertReason)
+
+}
+
+func TestPostTransactionsEstimateConnectorGasPrice(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`"12345"`),
+	}, ffcapi.ErrorReason(""), nil)
+
+	// The policy engine does not estimate gas prices, so the estimate of the connector is returned
+	m.policyEngine = &policyenginemocks.PolicyEngine{}
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var estimate apitypes.TransactionEstimate
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{}).
+		SetResult(&estimate).
+		Post(fmt.Sprintf("%s/transactions/estimate", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, int64(2000000), estimate.Gas.Int64())
+	assert.Equal(t, `"12345"`, estimate.GasPrice.String())
+
+	mFFC.AssertExpectations(t)
+
+}
+
+func TestPostTransactionsEstimateConnectorGasPriceFail(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mFFC.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	m.policyEngine = &policyenginemocks.PolicyEngine{}
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{}).
+		Post(fmt.Sprintf("%s/transactions/estimate", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 500, res.StatusCode())
+	assert.Regexp(t, "pop", res.String())
+
+}
+
+func TestPostTransactionsEstimatePrepareFail(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonInvalidInputs, fmt.Errorf("pop"))
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{}).
+		Post(fmt.Sprintf("%s/transactions/estimate", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 500, res.StatusCode())
+	assert.Regexp(t, "pop", res.String())
+
+}
+
+func TestPostTransactionsEstimateGasPriceFail(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+
+	mpe := newTestEstimatingPolicyEngine(m)
+	mpe.On("EstimateGasPrice", mock.Anything, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("pop"))
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{}).
+		Post(fmt.Sprintf("%s/transactions/estimate", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 500, res.StatusCode())
+	assert.Regexp(t, "pop", res.String())
+
+}
+
+func TestPostTransactionsEstimatePolicyEngineNotConfigured(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		SetBody(&apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{PolicyEngine: "wrong"},
+		}).
+		Post(fmt.Sprintf("%s/transactions/estimate", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21078", res.String())
+
+}
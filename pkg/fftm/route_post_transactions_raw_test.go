@@ -0,0 +1,280 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type rawConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.RawTransactionAPI
+}
+
+func newTestRawManager(t *testing.T) (string, *manager, *ffcapimocks.API, *ffcapimocks.RawTransactionAPI, func()) {
+	url, m, done := newTestManager(t)
+	mfc := m.connector.(*ffcapimocks.API)
+	mra := &ffcapimocks.RawTransactionAPI{}
+	m.connector = &rawConnector{API: mfc, RawTransactionAPI: mra}
+	return url, m, mfc, mra, done
+}
+
+func sampleRawTX(nonce int64) *apitypes.RawTransactionRequest {
+	return &apitypes.RawTransactionRequest{
+		Headers: apitypes.RequestHeaders{
+			ID: fmt.Sprintf("ns1:raw-%d", nonce),
+		},
+		From:           "0xaaaaa",
+		Nonce:          fftypes.NewFFBigInt(nonce),
+		RawTransaction: "0xf86c0a8502540be400",
+	}
+}
+
+func sendSampleRawManagerTX(t *testing.T, m *manager, mfc *ffcapimocks.API, nonce int64) *apitypes.ManagedTX {
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(nonce),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mtx, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: "0xaaaaa",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	return mtx
+}
+
+func TestPostTransactionsRawE2E(t *testing.T) {
+
+	url, m, mfc, mra, done := newTestRawManager(t)
+	defer done()
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == "0x12345"
+	})).Run(func(args mock.Arguments) {
+		n := args[0].(*confirmations.Notification)
+		n.Transaction.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+			BlockNumber: fftypes.NewFFBigInt(12345),
+			BlockHash:   fftypes.NewRandB32().String(),
+			Success:     true,
+		})
+		n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+	}).Return(nil)
+	mra.On("TransactionSendRaw", mock.Anything, &ffcapi.TransactionSendRawRequest{
+		RawTransaction: "0xf86c0a8502540be400",
+	}).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var mtx apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetBody(sampleRawTX(1000)).
+		SetResult(&mtx).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.Equal(t, "ns1:raw-1000", mtx.ID)
+	assert.Equal(t, int64(1000), mtx.Nonce.Int64())
+	assert.Equal(t, "0xf86c0a8502540be400", mtx.RawTransaction)
+	assert.Nil(t, mtx.Gas)
+
+	status, rtx, err := m.waitTransactionConfirmed(m.ctx, mtx.ID, "5s")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, apitypes.TxStatusSucceeded, rtx.Status)
+	assert.Equal(t, "0x12345", rtx.TransactionHash)
+	assert.Nil(t, rtx.GasPrice)
+
+	// The nonce was never allocated, and the transaction was never prepared or sent as a normal transaction
+	mfc.AssertNotCalled(t, "NextNonceForSigner", mock.Anything, mock.Anything)
+	mfc.AssertNotCalled(t, "TransactionPrepare", mock.Anything, mock.Anything)
+	mfc.AssertNotCalled(t, "GasPriceEstimate", mock.Anything, mock.Anything)
+	mfc.AssertNotCalled(t, "TransactionSend", mock.Anything, mock.Anything)
+	mra.AssertExpectations(t)
+	mc.AssertExpectations(t)
+
+}
+
+func TestPostTransactionsRawKnownHash(t *testing.T) {
+
+	url, m, _, mra, done := newTestRawManager(t)
+	defer done()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == "0xabcde"
+	})).Run(func(args mock.Arguments) {
+		n := args[0].(*confirmations.Notification)
+		n.Transaction.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{Success: true})
+		n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+	}).Return(nil)
+
+	// Already broadcast by the caller, so the node reports it as known
+	mra.On("TransactionSendRaw", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("known transaction"))
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	req := sampleRawTX(1000)
+	req.TransactionHash = "0xabcde"
+	res, err := resty.New().R().
+		SetBody(req).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+
+	status, rtx, err := m.waitTransactionConfirmed(m.ctx, req.Headers.ID, "5s")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "0xabcde", rtx.TransactionHash)
+	assert.NotNil(t, rtx.FirstSubmit)
+	assert.Empty(t, rtx.ErrorHistory)
+	mc.AssertExpectations(t)
+
+}
+
+func TestPostTransactionsRawNonceCollision(t *testing.T) {
+
+	url, m, mfc, _, done := newTestRawManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	err := m.Start()
+	assert.NoError(t, err)
+
+	local := sendSampleRawManagerTX(t, m, mfc, 1000)
+
+	res, err := resty.New().R().
+		SetBody(sampleRawTX(1000)).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 409, res.StatusCode())
+	assert.Regexp(t, fmt.Sprintf("FF21118.*1000.*%s", local.ID), res.String())
+
+	// A raw transaction at a later nonce is accepted, and locally managed transactions continue after it
+	res, err = resty.New().R().
+		SetBody(sampleRawTX(1001)).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+
+	next := sendSampleRawManagerTX(t, m, mfc, 1000)
+	assert.Equal(t, int64(1002), next.Nonce.Int64())
+
+	// The lock is not left held by either submission
+	assert.Empty(t, m.lockedNonces)
+
+}
+
+func TestPostTransactionsRawNotSupported(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	err := m.Start()
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		SetBody(sampleRawTX(1000)).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 501, res.StatusCode())
+	assert.Regexp(t, "FF21116", res.String())
+
+}
+
+func TestPostTransactionsRawMissingFields(t *testing.T) {
+
+	url, m, _, _, done := newTestRawManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	err := m.Start()
+	assert.NoError(t, err)
+
+	for field, req := range map[string]*apitypes.RawTransactionRequest{
+		"from":           {Nonce: fftypes.NewFFBigInt(1), RawTransaction: "0x1234"},
+		"nonce":          {From: "0xaaaaa", RawTransaction: "0x1234"},
+		"rawTransaction": {From: "0xaaaaa", Nonce: fftypes.NewFFBigInt(1)},
+	} {
+		res, err := resty.New().R().
+			SetBody(req).
+			Post(url + "/transactions/raw")
+		assert.NoError(t, err)
+		assert.Equal(t, 400, res.StatusCode())
+		assert.Regexp(t, fmt.Sprintf("FF21117.*'%s'", field), res.String())
+	}
+
+}
+
+func TestPostTransactionsRawTooLarge(t *testing.T) {
+
+	url, m, _, _, done := newTestRawManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	err := m.Start()
+	assert.NoError(t, err)
+	m.maxDataSize = 8
+
+	res, err := resty.New().R().
+		SetBody(sampleRawTX(1000)).
+		Post(url + "/transactions/raw")
+	assert.NoError(t, err)
+	assert.Equal(t, 413, res.StatusCode())
+	assert.Regexp(t, "FF21151", res.String())
+
+	// Nothing was persisted
+	txs, err := m.persistence.ListTransactionsByCreateTime(m.ctx, nil, 0, persistence.SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Empty(t, txs)
+
+}
+
+func TestLockExternalNonceQueryFail(t *testing.T) {
+
+	_, m, done := newTestManagerMockPersistence(t)
+	defer done()
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", fftypes.NewFFBigInt(1001), 1, persistence.SortDirectionDescending).
+		Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.lockExternalNonce(m.ctx, "ns1:raw", "", "0xaaaaa", fftypes.NewFFBigInt(1000))
+	assert.Regexp(t, "pop", err)
+	assert.Empty(t, m.lockedNonces)
+
+}
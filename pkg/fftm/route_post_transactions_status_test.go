@@ -0,0 +1,116 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostTransactionsStatus(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(nil).Maybe()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	tx1 := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	tx1.TransactionHash = "0x11111"
+	tx1.Receipt = &ffcapi.TransactionReceiptResponse{
+		BlockNumber: fftypes.NewFFBigInt(1001),
+		BlockHash:   "0xaaaa",
+	}
+	tx1.Confirmations = []confirmations.BlockInfo{
+		{BlockNumber: 1002, BlockHash: "0xbbbb", ParentHash: "0xaaaa"},
+		{BlockNumber: 1003, BlockHash: "0xcccc", ParentHash: "0xbbbb"},
+	}
+	err = m.persistence.WriteTransaction(context.Background(), tx1, true)
+	assert.NoError(t, err)
+	tx2 := newTestTxn(t, m, "0xaaaaa", 10002, apitypes.TxStatusPending)
+
+	var results map[string]*apitypes.TxStatusSummary
+	res, err := resty.New().R().
+		SetBody([]string{tx1.ID, "unknown", tx2.ID}).
+		SetResult(&results).
+		Post(fmt.Sprintf("%s/transactions/status", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, map[string]*apitypes.TxStatusSummary{
+		tx1.ID: {
+			Found:                 true,
+			Status:                apitypes.TxStatusSucceeded,
+			TransactionHash:       "0x11111",
+			ConfirmationCount:     2,
+			ConfirmationsRequired: 20,
+		},
+		"unknown": {Found: false},
+		tx2.ID: {
+			Found:                 true,
+			Status:                apitypes.TxStatusPending,
+			ConfirmationsRequired: 20,
+		},
+	}, results)
+
+}
+
+func TestPostTransactionsStatusTooMany(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	m.maxBatchLength = 2
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	res, err := resty.New().R().
+		SetBody([]string{"tx1", "tx2", "tx3"}).
+		Post(fmt.Sprintf("%s/transactions/status", url))
+	assert.NoError(t, err)
+	assert.Equal(t, 413, res.StatusCode())
+	assert.Regexp(t, "FF21152", res.String())
+
+}
+
+func TestGetTransactionsStatusPersistenceFail(t *testing.T) {
+
+	_, m, done := newTestManagerMockPersistence(t)
+	defer done()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionsByIDs", mock.Anything, []string{"tx1"}).Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.getTransactionsStatus(context.Background(), []string{"tx1"})
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
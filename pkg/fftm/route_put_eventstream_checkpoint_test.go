@@ -0,0 +1,105 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPutEventStreamCheckpoint(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("EventStreamNewCheckpointStruct").Return(func() ffcapi.EventListenerCheckpoint { return &testBlockCheckpoint{} })
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventListenerAdd", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerAddResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventListenerHWM", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerHWMResponse{
+		Checkpoint: &testBlockCheckpoint{Block: 20000},
+	}, ffcapi.ErrorReason(""), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	// Create a stream and listener
+	var es apitypes.EventStream
+	res, err := resty.New().R().SetBody(&apitypes.EventStream{Name: strPtr("stream1")}).SetResult(&es).Post(url + "/eventstreams")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	var l apitypes.Listener
+	res, err = resty.New().R().SetBody(&apitypes.Listener{Name: strPtr("listener1")}).SetResult(&l).Post(url + "/eventstreams/" + es.ID.String() + "/listeners")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+
+	setTo := func(block int64) *apitypes.EventStreamCheckpoint {
+		b, _ := json.Marshal(&testBlockCheckpoint{Block: block})
+		return &apitypes.EventStreamCheckpoint{
+			Listeners: map[fftypes.UUID]json.RawMessage{*l.ID: b},
+		}
+	}
+
+	// Set forwards
+	var cp apitypes.EventStreamCheckpoint
+	res, err = resty.New().R().SetBody(setTo(15000)).SetResult(&cp).Put(url + "/eventstreams/" + es.ID.String() + "/checkpoint")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.JSONEq(t, `{"block":15000}`, string(cp.Listeners[*l.ID]))
+
+	// Cannot set ahead of the head
+	res, err = resty.New().R().SetBody(setTo(20001)).Put(url + "/eventstreams/" + es.ID.String() + "/checkpoint?confirmRewind")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21087", res.String())
+
+	// Rewind requires confirmation
+	res, err = resty.New().R().SetBody(setTo(10000)).Put(url + "/eventstreams/" + es.ID.String() + "/checkpoint")
+	assert.NoError(t, err)
+	assert.Equal(t, 409, res.StatusCode())
+	assert.Regexp(t, "FF21088", res.String())
+
+	res, err = resty.New().R().SetBody(setTo(10000)).SetResult(&cp).Put(url + "/eventstreams/" + es.ID.String() + "/checkpoint?confirmRewind=true")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.JSONEq(t, `{"block":10000}`, string(cp.Listeners[*l.ID]))
+
+	persisted, err := m.persistence.GetCheckpoint(m.ctx, es.ID)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"block":10000}`, string(persisted.Listeners[*l.ID]))
+
+	// Not found
+	res, err = resty.New().R().SetBody(setTo(10000)).Put(url + "/eventstreams/" + fftypes.NewUUID().String() + "/checkpoint")
+	assert.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode())
+
+	// Bad ID
+	res, err = resty.New().R().SetBody(setTo(10000)).Put(url + "/eventstreams/bad/checkpoint")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+
+}
@@ -24,24 +24,53 @@ func (m *manager) routes() []*ffapi.Route {
 		deleteEventStreamListener(m),
 		deleteSubscription(m),
 		deleteTransaction(m),
+		getBackpressure(m),
+		getConnectorInfo(m),
+		getEventStreamsExport(m), // before getEventStream, which would otherwise match the path
 		getEventStream(m),
+		getEventStreamCheckpoint(m),
+		getEventStreamDeadLetters(m),
 		getEventStreamListener(m),
 		getEventStreamListeners(m),
 		getEventStreams(m),
+		getNonceGaps(m),
+		getNonces(m),
 		getSubscription(m),
 		getSubscriptions(m),
+		getTransactionsStats(m), // before getTransaction, which would otherwise match the path
 		getTransaction(m),
+		getTransactionByHash(m),
+		getTransactionConfirmations(m),
+		getTransactionDependents(m),
+		getTransactionHistory(m),
+		getTransactionTrace(m),
 		getTransactions(m),
 		patchEventStream(m),
 		patchEventStreamListener(m),
 		patchSubscription(m),
+		postControlPause(m),
+		postControlResume(m),
 		postEventStream(m),
+		postEventStreamDeadLetterReplay(m),
 		postEventStreamListenerReset(m),
+		postEventStreamPause(m),
 		postEventStreamListeners(m),
+		postEventStreamReplay(m),
 		postEventStreamResume(m),
+		postEventStreamSSEAck(m),
 		postEventStreamSuspend(m),
+		postEventStreamsImport(m),
+		postNonceRefresh(m),
+		postNonceReserve(m),
+		postNonceReset(m),
 		postRootCommand(m),
 		postSubscriptionReset(m),
 		postSubscriptions(m),
+		postTransactionCancel(m),
+		postTransactionUndelete(m),
+		postTransactionsEstimate(m),
+		postTransactionsRaw(m),
+		postTransactionsStatus(m),
+		putEventStreamCheckpoint(m),
 	}
 }
@@ -0,0 +1,156 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+const (
+	backpressureModeQueue  = "queue"  // submissions wait for space in the in-flight set
+	backpressureModeReject = "reject" // submissions are rejected with a 429 once the backlog reaches the threshold
+)
+
+// backpressurePolicy decides what happens to submissions once the backlog of in-flight and pending
+// transactions reaches a threshold, either across all signers or for a single signer
+type backpressurePolicy struct {
+	mode      string
+	threshold int
+}
+
+func (m *manager) newBackpressurePolicy(ctx context.Context, mode string, threshold int) (*backpressurePolicy, error) {
+	if mode != backpressureModeQueue && mode != backpressureModeReject {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidBackpressureMode, mode)
+	}
+	if threshold <= 0 {
+		threshold = m.maxInFlight
+	}
+	return &backpressurePolicy{mode: mode, threshold: threshold}, nil
+}
+
+func (m *manager) initBackpressure(ctx context.Context) (err error) {
+	m.backpressure, err = m.newBackpressurePolicy(ctx, config.GetString(tmconfig.TransactionsBackpressureMode), config.GetInt(tmconfig.TransactionsBackpressureThreshold))
+	if err != nil {
+		return err
+	}
+	conf := tmconfig.SignerBackpressureConfig
+	m.signerBackpressure = make(map[string]*backpressurePolicy, conf.ArraySize())
+	for i := 0; i < conf.ArraySize(); i++ {
+		entry := conf.ArrayEntry(i)
+		signer := entry.GetString(tmconfig.SignerBackpressureConfigSigner)
+		if signer == "" {
+			return i18n.NewError(ctx, tmmsgs.MsgBackpressureSignerMissing, i)
+		}
+		// Defaults are applied here, as defaults on array entries interfere with reading the array
+		mode := entry.GetString(tmconfig.SignerBackpressureConfigMode)
+		if mode == "" {
+			mode = backpressureModeQueue
+		}
+		policy, err := m.newBackpressurePolicy(ctx, mode, entry.GetInt(tmconfig.SignerBackpressureConfigThreshold))
+		if err != nil {
+			return err
+		}
+		m.signerBackpressure[signer] = policy
+	}
+	return nil
+}
+
+// checkBackpressure rejects a submission with a 429, if the backpressure policy of the signer is to reject once the
+// backlog of in-flight and pending transactions reaches the threshold, and it has. A signer with its own policy is
+// only checked against its own backlog. Must be called holding the nonce lock for the signer, so concurrent
+// submissions for the signer cannot both pass the check. Submissions for different signers can, so the backlog
+// across all signers can briefly exceed the threshold.
+func (m *manager) checkBackpressure(ctx context.Context, signer string) error {
+	policy, signerPolicy := m.signerBackpressure[signer]
+	if !signerPolicy {
+		policy = m.backpressure
+	}
+	if policy == nil || policy.mode != backpressureModeReject {
+		return nil
+	}
+	countSigner := ""
+	if signerPolicy {
+		countSigner = signer
+	}
+	backlog, err := m.countPending(ctx, countSigner, policy.threshold)
+	if err != nil {
+		return err
+	}
+	if backlog < policy.threshold {
+		return nil
+	}
+	log.L(ctx).Warnf("Rejecting submission for signer %s with a backlog of %d transactions (signerPolicy=%t)", signer, backlog, signerPolicy)
+	if signerPolicy {
+		return i18n.NewError(ctx, tmmsgs.MsgSignerBacklogThresholdReached, signer, backlog, policy.threshold)
+	}
+	return i18n.NewError(ctx, tmmsgs.MsgBacklogThresholdReached, backlog, policy.threshold)
+}
+
+// getBackpressureStatus reports the backlog across all signers, and for each signer with its own policy
+func (m *manager) getBackpressureStatus(ctx context.Context) (*apitypes.BackpressureStatus, error) {
+	backlog, err := m.countPending(ctx, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	m.mux.Lock()
+	inflightBySigner := m.inflightBySigner
+	m.mux.Unlock()
+	inflight := 0
+	for _, count := range inflightBySigner {
+		inflight += count
+	}
+	status := &apitypes.BackpressureStatus{
+		Mode:        m.backpressure.mode,
+		Threshold:   m.backpressure.threshold,
+		Backlog:     backlog,
+		Utilization: utilization(backlog, m.backpressure.threshold),
+		InFlight:    inflight,
+		MaxInFlight: m.maxInFlight,
+		Signers:     make([]*apitypes.SignerBackpressureStatus, 0, len(m.signerBackpressure)),
+	}
+	for signer, policy := range m.signerBackpressure {
+		signerBacklog, err := m.countPending(ctx, signer, 0)
+		if err != nil {
+			return nil, err
+		}
+		status.Signers = append(status.Signers, &apitypes.SignerBackpressureStatus{
+			Signer:      signer,
+			Mode:        policy.mode,
+			Threshold:   policy.threshold,
+			Backlog:     signerBacklog,
+			Utilization: utilization(signerBacklog, policy.threshold),
+			InFlight:    inflightBySigner[signer],
+		})
+	}
+	sort.Slice(status.Signers, func(i, j int) bool { return status.Signers[i].Signer < status.Signers[j].Signer })
+	return status, nil
+}
+
+func utilization(backlog, threshold int) float64 {
+	if threshold <= 0 {
+		return 0
+	}
+	return float64(backlog) / float64(threshold)
+}
@@ -0,0 +1,287 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestBackpressureSend(t *testing.T, m *manager) func(signer string) error {
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(1000),
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	mfc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	return func(signer string) error {
+		_, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{From: signer},
+			},
+		})
+		return err
+	}
+}
+
+func TestSendTransactionBackpressureQueue(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.backpressure = &backpressurePolicy{mode: backpressureModeQueue, threshold: 2}
+	send := newTestBackpressureSend(t, m)
+
+	// Submissions are accepted at and beyond the threshold, to wait for space in the in-flight set
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, send("0xaaaaa"))
+	}
+
+}
+
+func TestSendTransactionBackpressureReject(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.backpressure = &backpressurePolicy{mode: backpressureModeReject, threshold: 3}
+	send := newTestBackpressureSend(t, m)
+
+	// Below the threshold we are accepted, up to the threshold
+	tx1 := sendSampleTX(t, m, "0xaaaaa", 1000)
+	assert.NoError(t, send("0xbbbbb"))
+	assert.NoError(t, send("0xaaaaa"))
+
+	// At the threshold we are rejected, whichever signer we are
+	err := send("0xaaaaa")
+	assert.Regexp(t, "FF21185.*3.*3", err)
+	assert.Regexp(t, "FF21185", send("0xccccc"))
+
+	// Beyond the threshold, such as after a switch from queue mode, we are still rejected
+	newTestTxn(t, m, "0xddddd", 1, apitypes.TxStatusPending)
+	assert.Regexp(t, "FF21185", send("0xaaaaa"))
+
+	// Once the backlog drains below the threshold we are accepted again
+	for _, tx := range []*apitypes.ManagedTX{tx1} {
+		tx.Status = apitypes.TxStatusSucceeded
+		assert.NoError(t, m.persistence.WriteTransaction(m.ctx, tx, false))
+	}
+	assert.Regexp(t, "FF21185", send("0xaaaaa"))
+	txns, err := m.persistence.ListTransactionsByStatus(m.ctx, apitypes.TxStatusPending, "0xddddd", nil, 1, 0)
+	assert.NoError(t, err)
+	txns[0].Status = apitypes.TxStatusFailed
+	assert.NoError(t, m.persistence.WriteTransaction(m.ctx, txns[0], false))
+	assert.NoError(t, send("0xccccc"))
+	assert.Regexp(t, "FF21185", send("0xccccc"))
+
+}
+
+func TestSendTransactionSignerBackpressure(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.backpressure = &backpressurePolicy{mode: backpressureModeReject, threshold: 2}
+	m.signerBackpressure = map[string]*backpressurePolicy{
+		"0xaaaaa": {mode: backpressureModeReject, threshold: 3},
+		"0xbbbbb": {mode: backpressureModeQueue, threshold: 1},
+	}
+	send := newTestBackpressureSend(t, m)
+
+	// A signer with its own policy is only checked against its own backlog
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, send("0xaaaaa"))
+	}
+	assert.Regexp(t, "FF21186.*0xaaaaa.*3.*3", send("0xaaaaa"))
+
+	// A signer with its own queue policy is never rejected, even though the global backlog is over the threshold
+	assert.NoError(t, send("0xbbbbb"))
+	assert.NoError(t, send("0xbbbbb"))
+
+	// Other signers are checked against the global backlog
+	assert.Regexp(t, "FF21185.*5.*2", send("0xccccc"))
+
+}
+
+func TestCheckBackpressureQueryFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.backpressure = &backpressurePolicy{mode: backpressureModeReject, threshold: 2}
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("CountTransactionsByStatus", mock.Anything).Return(nil, fmt.Errorf("pop"))
+
+	err := m.checkBackpressure(m.ctx, "0xaaaaa")
+	assert.Regexp(t, "pop", err)
+
+	_, err = m.getBackpressureStatus(m.ctx)
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestGetBackpressureStatus(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+	m.backpressure = &backpressurePolicy{mode: backpressureModeReject, threshold: 4}
+	m.signerBackpressure = map[string]*backpressurePolicy{
+		"0xbbbbb": {mode: backpressureModeReject, threshold: 2},
+		"0xaaaaa": {mode: backpressureModeQueue, threshold: 10},
+	}
+	m.maxInFlight = 10
+	m.inflightBySigner = map[string]int{"0xaaaaa": 1, "0xbbbbb": 1}
+
+	newTestTxn(t, m, "0xaaaaa", 1, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xbbbbb", 1, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xbbbbb", 2, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xbbbbb", 0, apitypes.TxStatusSucceeded)
+
+	status, err := m.getBackpressureStatus(m.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "reject", status.Mode)
+	assert.Equal(t, 4, status.Threshold)
+	assert.Equal(t, 3, status.Backlog)
+	assert.Equal(t, 0.75, status.Utilization)
+	assert.Equal(t, 2, status.InFlight)
+	assert.Equal(t, 10, status.MaxInFlight)
+	assert.Len(t, status.Signers, 2)
+	assert.Equal(t, apitypes.SignerBackpressureStatus{
+		Signer: "0xaaaaa", Mode: "queue", Threshold: 10, Backlog: 1, Utilization: 0.1, InFlight: 1,
+	}, *status.Signers[0])
+	assert.Equal(t, apitypes.SignerBackpressureStatus{
+		Signer: "0xbbbbb", Mode: "reject", Threshold: 2, Backlog: 2, Utilization: 1, InFlight: 1,
+	}, *status.Signers[1])
+
+}
+
+func TestGetBackpressure(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+	m.backpressure = &backpressurePolicy{mode: backpressureModeReject, threshold: 4}
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var status apitypes.BackpressureStatus
+	res, err := resty.New().R().
+		SetResult(&status).
+		Get(url + "/backpressure")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, "reject", status.Mode)
+	assert.Equal(t, 4, status.Threshold)
+	assert.Equal(t, 0, status.Backlog)
+	assert.Empty(t, status.Signers)
+
+}
+
+func TestGetBackpressureSignerQueryFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.backpressure = &backpressurePolicy{mode: backpressureModeQueue, threshold: 2}
+	m.signerBackpressure = map[string]*backpressurePolicy{
+		"0xaaaaa": {mode: backpressureModeReject, threshold: 3},
+	}
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("CountTransactionsByStatus", mock.Anything).Return(map[apitypes.TxStatus]int{}, nil)
+	mp.On("ListTransactionsByStatus", mock.Anything, apitypes.TxStatusPending, "0xaaaaa", mock.Anything, pendingCountPageSize, mock.Anything).Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.getBackpressureStatus(m.ctx)
+	assert.Regexp(t, "pop", err)
+
+}
+
+func setTestBackpressureConfig(t *testing.T, yaml string) *manager {
+	InitConfig()
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(yaml))
+	assert.NoError(t, err)
+	return newManager(context.Background(), &ffcapimocks.API{})
+}
+
+func TestInitBackpressure(t *testing.T) {
+
+	m := setTestBackpressureConfig(t, `
+transactions:
+  maxInFlight: 50
+  backpressure:
+    mode: reject
+signerbackpressure:
+- signer: "0xaaaaa"
+  threshold: 5
+- signer: "0xbbbbb"
+  mode: reject
+`)
+	err := m.initBackpressure(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &backpressurePolicy{mode: backpressureModeReject, threshold: 50}, m.backpressure)
+	assert.Equal(t, map[string]*backpressurePolicy{
+		"0xaaaaa": {mode: backpressureModeQueue, threshold: 5},
+		"0xbbbbb": {mode: backpressureModeReject, threshold: 50},
+	}, m.signerBackpressure)
+
+	// Queue is the default
+	m = setTestBackpressureConfig(t, ``)
+	err = m.initBackpressure(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &backpressurePolicy{mode: backpressureModeQueue, threshold: 100}, m.backpressure)
+	assert.Empty(t, m.signerBackpressure)
+
+}
+
+func TestInitBackpressureBadConfig(t *testing.T) {
+
+	m := setTestBackpressureConfig(t, `
+transactions:
+  backpressure:
+    mode: wrong
+`)
+	err := m.initBackpressure(context.Background())
+	assert.Regexp(t, "FF21183.*wrong", err)
+
+	m = setTestBackpressureConfig(t, `
+signerbackpressure:
+- mode: reject
+`)
+	err = m.initBackpressure(context.Background())
+	assert.Regexp(t, "FF21184.*0", err)
+
+	m = setTestBackpressureConfig(t, `
+signerbackpressure:
+- signer: "0xaaaaa"
+  mode: wrong
+`)
+	err = m.initBackpressure(context.Background())
+	assert.Regexp(t, "FF21183.*wrong", err)
+
+}
@@ -18,57 +18,379 @@ package fftm
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/abi"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// sendManagedTransaction prepares and submits a transaction. Any key name must already have been
+// resolved to the from address with resolveSigner.
 func (m *manager) sendManagedTransaction(ctx context.Context, request *apitypes.TransactionRequest) (*apitypes.ManagedTX, error) {
 
+	if err := validateSubmission(ctx, &request.Headers, &request.TransactionHeaders); err != nil {
+		return nil, err
+	}
+	connector, err := m.getConnector(ctx, request.Headers.Connector)
+	if err != nil {
+		return nil, err
+	}
+
 	// Prepare the transaction, which will mean we have a transaction that should be submittable.
 	// If we fail at this stage, we don't need to write any state as we are sure we haven't submitted
 	// anything to the blockchain itself.
-	prepared, _, err := m.connector.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+	prepared, _, err := connector.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: request.TransactionInput,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Decoding the input is best-effort, so a method ABI we cannot decode does not block the submission
+	var decodedInput *apitypes.DecodedInput
+	if request.Method != nil {
+		if decodedInput, err = abi.DecodeFunctionInput(ctx, request.Method, prepared.TransactionData); err != nil {
+			log.L(ctx).Infof("Transaction input not decoded: %s", err)
+		}
+	}
+
+	return m.submitTX(ctx, &request.Headers, &request.TransactionHeaders, prepared.Gas, prepared.TransactionData, false, nil, decodedInput)
+}
+
+func (m *manager) estimateTransaction(ctx context.Context, request *apitypes.TransactionRequest) (*apitypes.TransactionEstimate, error) {
+
+	if err := validateSubmission(ctx, &request.Headers, &request.TransactionHeaders); err != nil {
+		return nil, err
+	}
+	connector, err := m.getConnector(ctx, request.Headers.Connector)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the same preparation as a real send, so the gas estimate matches what we would submit
+	prepared, reason, err := connector.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
 		TransactionInput: request.TransactionInput,
 	})
 	if err != nil {
+		if reason == ffcapi.ErrorReasonTransactionReverted {
+			return &apitypes.TransactionEstimate{RevertReason: abi.DecodeRevertReason(err)}, nil
+		}
 		return nil, err
 	}
 
-	return m.submitPreparedTX(ctx, request.Headers.ID, &request.TransactionHeaders, prepared.Gas, prepared.TransactionData)
+	// Ask the policy engine for the gas price it would use on the initial submission
+	gas, _ := m.gasLimit(ctx, &request.Headers, &request.TransactionHeaders, prepared.Gas)
+	mtx := &apitypes.ManagedTX{
+		ID:                 request.Headers.ID,
+		PolicyEngine:       request.Headers.PolicyEngine,
+		Gas:                gas,
+		TransactionHeaders: request.TransactionHeaders,
+		TransactionData:    prepared.TransactionData,
+	}
+	pe, err := m.getPolicyEngine(ctx, request.Headers.Connector, request.Headers.PolicyEngine)
+	if err != nil {
+		return nil, err
+	}
+	var gasPrice *fftypes.JSONAny
+	if estimator, ok := pe.(policyengine.GasPriceEstimator); ok {
+		gasPrice, err = estimator.EstimateGasPrice(ctx, connector, mtx)
+	} else {
+		// Fall back to the gas price proposed by the connector, without the policy of the engine applied
+		var res *ffcapi.GasPriceEstimateResponse
+		if res, _, err = connector.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{}); err == nil {
+			gasPrice = res.GasPrice
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.TransactionEstimate{
+		Gas:      gas,
+		GasPrice: gasPrice,
+	}, nil
 }
 
 func (m *manager) sendManagedContractDeployment(ctx context.Context, request *apitypes.ContractDeployRequest) (*apitypes.ManagedTX, error) {
 
+	if err := validateSubmission(ctx, &request.Headers, &request.TransactionHeaders); err != nil {
+		return nil, err
+	}
+	// A contract creation is identified by having no target
+	if request.To != "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgDeployWithToAddress)
+	}
+	connector, err := m.getConnector(ctx, request.Headers.Connector)
+	if err != nil {
+		return nil, err
+	}
+
 	// Prepare the transaction, which will mean we have a transaction that should be submittable.
 	// If we fail at this stage, we don't need to write any state as we are sure we haven't submitted
 	// anything to the blockchain itself.
-	prepared, _, err := m.connector.DeployContractPrepare(ctx, &request.ContractDeployPrepareRequest)
+	prepared, _, err := connector.DeployContractPrepare(ctx, &request.ContractDeployPrepareRequest)
 	if err != nil {
 		return nil, err
 	}
 
-	return m.submitPreparedTX(ctx, request.Headers.ID, &request.TransactionHeaders, prepared.Gas, prepared.TransactionData)
+	return m.submitTX(ctx, &request.Headers, &request.TransactionHeaders, prepared.Gas, prepared.TransactionData, true, nil, nil)
+}
+
+// sendRawTransaction tracks a transaction that was signed outside of FFTM. There is no prepare step, and the
+// nonce the transaction was signed with is used rather than one being assigned. The policy engine submits
+// the signed transaction unchanged, so the gas limit and gas price are those it was signed with.
+func (m *manager) sendRawTransaction(ctx context.Context, request *apitypes.RawTransactionRequest) (*apitypes.ManagedTX, error) {
+
+	if _, ok := m.baseConnector().(ffcapi.RawTransactionAPI); !ok || !m.connectorSupports(ffcapi.CapabilityRawTransactions) {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgRawTransactionsNotSupported)
+	}
+	switch {
+	case request.From == "":
+		return nil, i18n.NewError(ctx, tmmsgs.MsgMissingRawTransactionField, "from")
+	case request.Nonce == nil && request.ReservationID == nil:
+		return nil, i18n.NewError(ctx, tmmsgs.MsgMissingRawTransactionField, "nonce")
+	case request.RawTransaction == "":
+		return nil, i18n.NewError(ctx, tmmsgs.MsgMissingRawTransactionField, "rawTransaction")
+	}
+
+	if request.ReservationID != nil {
+		// The reservation is consumed only if the transaction is accepted, so a failed submission can be retried
+		done, err := m.startConsumingNonceReservation(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		mtx, err := m.submitTX(ctx, &request.Headers, &ffcapi.TransactionHeaders{From: request.From}, nil, "", false, request, nil)
+		done(err == nil)
+		return mtx, err
+	}
+	return m.submitTX(ctx, &request.Headers, &ffcapi.TransactionHeaders{From: request.From}, nil, "", false, request, nil)
+}
+
+// validateSubmission checks the optional fields of a submission, before it is passed to the connector
+func validateSubmission(ctx context.Context, reqHeaders *apitypes.RequestHeaders, txHeaders *ffcapi.TransactionHeaders) error {
+	if reqHeaders.GasLimitMultiplier < 0 {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidGasLimitMultiplier, reqHeaders.GasLimitMultiplier)
+	}
+	return validateAccessList(ctx, txHeaders.AccessList)
+}
+
+// gasLimit determines the gas limit to submit a transaction with, and how it was determined.
+// An explicit gas limit is used as supplied, as the connector skips estimation when one is set.
+// Otherwise the estimate from the connector is multiplied by the gasLimitMultiplier of the
+// submission if there is one, without exceeding the configured block gas limit.
+func (m *manager) gasLimit(ctx context.Context, reqHeaders *apitypes.RequestHeaders, txHeaders *ffcapi.TransactionHeaders, estimated *fftypes.FFBigInt) (*fftypes.FFBigInt, apitypes.GasLimitSource) {
+	if txHeaders.Gas != nil {
+		if estimated == nil {
+			estimated = txHeaders.Gas
+		}
+		return estimated, apitypes.GasLimitSourceExplicit
+	}
+	if reqHeaders.GasLimitMultiplier == 0 || estimated == nil {
+		return estimated, apitypes.GasLimitSourceEstimated
+	}
+	multiplied, _ := new(big.Float).Mul(
+		new(big.Float).SetInt(estimated.Int()),
+		big.NewFloat(reqHeaders.GasLimitMultiplier),
+	).Int(nil)
+	if m.blockGasLimit > 0 && multiplied.Cmp(big.NewInt(m.blockGasLimit)) > 0 {
+		log.L(ctx).Infof("Gas limit %s (estimate %s x %f) reduced to the block gas limit %d", multiplied, estimated.Int(), reqHeaders.GasLimitMultiplier, m.blockGasLimit)
+		multiplied.SetInt64(m.blockGasLimit)
+	}
+	return (*fftypes.FFBigInt)(multiplied), apitypes.GasLimitSourceMultiplied
 }
 
-func (m *manager) submitPreparedTX(ctx context.Context, txID string, txHeaders *ffcapi.TransactionHeaders, gas *fftypes.FFBigInt, transactionData string) (*apitypes.ManagedTX, error) {
+// validateAccessList checks an optional access list is well formed, before it is passed to the connector.
+// Each entry must have a 20 byte address, and any storage keys must be 32 bytes, all hex encoded.
+func validateAccessList(ctx context.Context, accessList ffcapi.AccessList) error {
+	for i, entry := range accessList {
+		if entry == nil {
+			return i18n.NewError(ctx, tmmsgs.MsgInvalidAccessList, i, "missing entry")
+		}
+		if !isHexBytes(entry.Address, 20) {
+			return i18n.NewError(ctx, tmmsgs.MsgInvalidAccessList, i, fmt.Sprintf("invalid address '%s'", entry.Address))
+		}
+		for _, key := range entry.StorageKeys {
+			if !isHexBytes(key, 32) {
+				return i18n.NewError(ctx, tmmsgs.MsgInvalidAccessList, i, fmt.Sprintf("invalid storage key '%s'", key))
+			}
+		}
+	}
+	return nil
+}
+
+// checkDataSize rejects hex encoded transaction data larger than the configured maximum, so an oversized
+// submission is never persisted or assigned a nonce
+func (m *manager) checkDataSize(ctx context.Context, data string) error {
+	size := int64(len(strings.TrimPrefix(strings.TrimPrefix(data, "0x"), "0X")) / 2)
+	if m.maxDataSize > 0 && size > m.maxDataSize {
+		return i18n.NewError(ctx, tmmsgs.MsgTransactionDataTooLarge, size, m.maxDataSize)
+	}
+	return nil
+}
+
+// checkLabels bounds the number and size of the labels on a submission, as each is indexed.
+// Label keys cannot contain '=', as that separates the key and value when filtering on a label
+func (m *manager) checkLabels(ctx context.Context, labels map[string]string) error {
+	if len(labels) > m.maxLabels {
+		return i18n.NewError(ctx, tmmsgs.MsgTooManyLabels, len(labels), m.maxLabels)
+	}
+	for k, v := range labels {
+		if k == "" || strings.Contains(k, "=") {
+			return i18n.NewError(ctx, tmmsgs.MsgInvalidLabelKey, k)
+		}
+		if len(k) > m.maxLabelLength || len(v) > m.maxLabelLength {
+			return i18n.NewError(ctx, tmmsgs.MsgLabelTooLong, k, m.maxLabelLength)
+		}
+	}
+	return nil
+}
+
+func isHexBytes(s string, length int) bool {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return false
+	}
+	b, err := hex.DecodeString(s[2:])
+	return err == nil && len(b) == length
+}
+
+func (m *manager) submitPreparedTX(ctx context.Context, reqHeaders *apitypes.RequestHeaders, txHeaders *ffcapi.TransactionHeaders, gas *fftypes.FFBigInt, transactionData string) (*apitypes.ManagedTX, error) {
+	return m.submitTX(ctx, reqHeaders, txHeaders, gas, transactionData, false, nil, nil)
+}
+
+// submitTX records a new transaction for the policy loop to submit, which is either a transaction prepared
+// by the connector that we assign a nonce to, or a raw transaction signed outside of FFTM.
+// A prepared transaction is a contract creation when deploy is set, which is sent with no "to" address.
+// The decoded input, if any, is stored on the transaction for display only.
+// The submission is a span, which the spans of the policy loop processing the transaction are children of.
+func (m *manager) submitTX(ctx context.Context, reqHeaders *apitypes.RequestHeaders, txHeaders *ffcapi.TransactionHeaders, gas *fftypes.FFBigInt, transactionData string, deploy bool, raw *apitypes.RawTransactionRequest, decodedInput *apitypes.DecodedInput) (*apitypes.ManagedTX, error) {
+	ctx, span := m.tracer.Start(ctx, "submitTransaction", trace.WithAttributes(attribute.String("fftm.transaction.from", txHeaders.From)))
+	mtx, err := m.submitTXInSpan(ctx, reqHeaders, txHeaders, gas, transactionData, deploy, raw, decodedInput)
+	if mtx != nil {
+		span.SetAttributes(attribute.String("fftm.transaction.id", mtx.ID))
+	}
+	endSpan(span, err)
+	return mtx, err
+}
+
+// submitTXInSpan performs the submission, within the span started by submitTX
+func (m *manager) submitTXInSpan(ctx context.Context, reqHeaders *apitypes.RequestHeaders, txHeaders *ffcapi.TransactionHeaders, gas *fftypes.FFBigInt, transactionData string, deploy bool, raw *apitypes.RawTransactionRequest, decodedInput *apitypes.DecodedInput) (*apitypes.ManagedTX, error) {
+
+	// We do not accept new transactions once we have started draining for shutdown
+	m.mux.Lock()
+	draining := m.draining
+	m.mux.Unlock()
+	if draining {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgShuttingDown)
+	}
+	if err := m.checkLeader(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkSignerAllowed(ctx, txHeaders.From); err != nil {
+		return nil, err
+	}
+
+	// Check the policy engine and connector chosen for this transaction are available, before we assign a nonce
+	if _, err := m.getPolicyEngine(ctx, reqHeaders.Connector, reqHeaders.PolicyEngine); err != nil {
+		return nil, err
+	}
+	if _, err := m.getConnector(ctx, reqHeaders.Connector); err != nil {
+		return nil, err
+	}
+	// Nonces are allocated independently for each signer on each chain
+	connector := m.txConnectorName(reqHeaders.Connector)
+	nonceKey := apitypes.NonceKey(connector, txHeaders.From)
+	if err := validateCompletionCallback(ctx, reqHeaders.CompletionCallback); err != nil {
+		return nil, err
+	}
+	milestones, err := m.validateConfirmationWebhook(ctx, reqHeaders)
+	if err != nil {
+		return nil, err
+	}
+	data := transactionData
+	if raw != nil {
+		data = raw.RawTransaction
+	}
+	if err := m.checkDataSize(ctx, data); err != nil {
+		return nil, err
+	}
+	if err := m.checkLabels(ctx, reqHeaders.Labels); err != nil {
+		return nil, err
+	}
+	if reqHeaders.SubmissionTimeout != nil && *reqHeaders.SubmissionTimeout < 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidSubmissionTimeout, reqHeaders.SubmissionTimeout)
+	}
+	if reqHeaders.Expiry != nil && reqHeaders.NotBefore != nil && !time.Time(*reqHeaders.Expiry).After(time.Time(*reqHeaders.NotBefore)) {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidExpiry, reqHeaders.Expiry, reqHeaders.NotBefore)
+	}
+	if _, err := m.confirmationRequirement(ctx, reqHeaders.ConfirmationStrategy); err != nil {
+		return nil, err
+	}
+	var gasLimitSource apitypes.GasLimitSource
+	if raw == nil {
+		gas, gasLimitSource = m.gasLimit(ctx, reqHeaders, txHeaders, gas)
+	}
+
+	// A repeat of a submission we have already accepted returns the existing transaction, and must
+	// be detected before we assign a nonce, so we do not burn one on the duplicate.
+	if reqHeaders.IdempotencyKey != "" {
+		existing, release, err := m.reserveIdempotencyKey(ctx, nonceKey, reqHeaders.IdempotencyKey)
+		if err != nil || existing != nil {
+			return existing, err
+		}
+		defer release()
+	}
 
 	// The request ID is the primary ID, and should be supplied by the user for idempotence
+	txID := reqHeaders.ID
 	if txID == "" {
 		txID = fftypes.NewUUID().String()
 	}
+	// The request ID is for correlation with the originating request, and is included in all logging for the transaction
+	requestID := reqHeaders.RequestID
+	if requestID == "" {
+		requestID = fftypes.NewUUID().String()
+	}
+	ctx = log.WithLogField(ctx, "requestId", requestID)
+	if reqHeaders.DependsOn != "" {
+		if err := m.checkDependency(ctx, txID, reqHeaders.DependsOn); err != nil {
+			return nil, err
+		}
+	}
 
 	// First job is to assign the next nonce to this request.
 	// We block any further sends on this nonce until we've got this one successfully into the node, or
 	// fail deterministically in a way that allows us to return it.
-	lockedNonce, err := m.assignAndLockNonce(ctx, txID, txHeaders.From)
+	var lockedNonce *lockedNonce
+	nonceCtx, nonceSpan := m.tracer.Start(ctx, "assignNonce")
+	if raw != nil {
+		lockedNonce, err = m.lockExternalNonce(nonceCtx, txID, connector, txHeaders.From, raw.Nonce)
+	} else {
+		lockedNonce, err = m.assignAndLockNonce(nonceCtx, txID, connector, txHeaders.From)
+	}
+	endSpan(nonceSpan, err)
 	if err != nil {
 		return nil, err
 	}
 	// We will call markSpent() once we reach the point the nonce has been used
 	defer lockedNonce.complete(ctx)
+	if err := m.checkPendingLimit(ctx, nonceKey); err != nil {
+		return nil, err
+	}
+	if err := m.checkBackpressure(ctx, nonceKey); err != nil {
+		return nil, err
+	}
 
 	// Sequencing ID is always generated by us - so we have a deterministic order of transactions
 	// Note: We must allocate this within the nonce lock, to ensure that the nonce sequence and the
@@ -81,22 +403,54 @@ func (m *manager) submitPreparedTX(ctx context.Context, txID string, txHeaders *
 	// to the background worker.
 	now := fftypes.Now()
 	mtx := &apitypes.ManagedTX{
-		ID:                 txID, // on input the request ID must be the namespaced operation ID
-		Created:            now,
-		Updated:            now,
-		SequenceID:         seqID,
-		Nonce:              fftypes.NewFFBigInt(int64(lockedNonce.nonce)),
-		Gas:                gas,
-		TransactionHeaders: *txHeaders,
-		TransactionData:    transactionData,
-		Status:             apitypes.TxStatusPending,
+		ID:                   txID, // on input the request ID must be the namespaced operation ID
+		Created:              now,
+		Updated:              now,
+		SequenceID:           seqID,
+		Nonce:                fftypes.NewFFBigInt(int64(lockedNonce.nonce)),
+		Gas:                  gas,
+		GasLimitSource:       gasLimitSource,
+		TransactionHeaders:   *txHeaders,
+		TransactionData:      transactionData,
+		DeployContract:       deploy,
+		Status:               apitypes.TxStatusPending,
+		PolicyEngine:         reqHeaders.PolicyEngine,
+		Connector:            connector,
+		KeyName:              reqHeaders.KeyName,
+		CompletionCallback:   reqHeaders.CompletionCallback,
+		ConfirmationWebhook:  reqHeaders.ConfirmationWebhook,
+		Milestones:           milestones,
+		SubmissionTimeout:    reqHeaders.SubmissionTimeout,
+		IdempotencyKey:       reqHeaders.IdempotencyKey,
+		NotBefore:            reqHeaders.NotBefore,
+		DependsOn:            reqHeaders.DependsOn,
+		ConfirmationStrategy: reqHeaders.ConfirmationStrategy,
+		Expiry:               reqHeaders.Expiry,
+		RequestID:            requestID,
+		Labels:               reqHeaders.Labels,
+		Simulate:             reqHeaders.Simulate,
+		Priority:             reqHeaders.Priority,
+		DecodedInput:         decodedInput,
+		TraceParent:          traceParent(ctx),
+	}
+	if raw != nil {
+		mtx.RawTransaction = raw.RawTransaction
+		mtx.TransactionHash = raw.TransactionHash
+	}
+	if mtx.NotBefore != nil && time.Time(*mtx.NotBefore).After(time.Now()) {
+		// Held by the policy loop, outside of the in-flight set, until the scheduled time
+		mtx.Status = apitypes.TxStatusScheduled
 	}
 
 	if err = m.persistence.WriteTransaction(m.ctx, mtx, true); err != nil {
 		return nil, err
 	}
-	log.L(m.ctx).Infof("Tracking transaction %s at nonce %s / %d", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64())
-	m.markInflightStale()
+	log.L(txLogContext(m.ctx, mtx)).Infof("Tracking transaction %s at nonce %s / %d", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64())
+	if mtx.Status == apitypes.TxStatusScheduled {
+		m.markScheduled(time.Time(*mtx.NotBefore))
+	} else {
+		m.markInflightStale()
+	}
 
 	// Ok - we've spent it. The rest of the processing will be triggered off of lockedNonce
 	// completion adding this transaction to the pool (and/or the change event that comes in from
@@ -104,3 +458,88 @@ func (m *manager) submitPreparedTX(ctx context.Context, txID string, txHeaders *
 	lockedNonce.spent = mtx
 	return mtx, nil
 }
+
+// checkDependency verifies the transaction being depended on exists, and that following the chain of
+// dependencies from it does not lead back to the new transaction
+func (m *manager) checkDependency(ctx context.Context, txID, dependsOn string) error {
+	visited := map[string]bool{}
+	for id := dependsOn; id != ""; {
+		if id == txID || visited[id] {
+			return i18n.NewError(ctx, tmmsgs.MsgDependencyCycle, txID, dependsOn)
+		}
+		visited[id] = true
+		parent, err := m.persistence.GetTransactionByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if parent == nil {
+			if id == dependsOn {
+				return i18n.NewError(ctx, tmmsgs.MsgDependencyNotFound, dependsOn)
+			}
+			// An ancestor further up the chain has been deleted, which ends the chain
+			break
+		}
+		id = parent.DependsOn
+	}
+	return nil
+}
+
+// reserveIdempotencyKey returns any transaction already submitted by the signer with the idempotency key,
+// within the retention window. Otherwise the key is reserved until the returned function is called, so
+// that a concurrent duplicate is rejected rather than being submitted alongside this one.
+func (m *manager) reserveIdempotencyKey(ctx context.Context, signer, idempotencyKey string) (*apitypes.ManagedTX, func(), error) {
+	reservation := signer + "/" + idempotencyKey
+	m.mux.Lock()
+	inProgress := m.idempotencyKeys[reservation]
+	if !inProgress {
+		m.idempotencyKeys[reservation] = true
+	}
+	m.mux.Unlock()
+	if inProgress {
+		return nil, nil, i18n.NewError(ctx, tmmsgs.MsgIdempotencyKeyInFlight, idempotencyKey, signer)
+	}
+	release := func() {
+		m.mux.Lock()
+		delete(m.idempotencyKeys, reservation)
+		m.mux.Unlock()
+	}
+
+	existing, err := m.findIdempotentTX(ctx, signer, idempotencyKey)
+	if err != nil || existing != nil {
+		release()
+		return existing, nil, err
+	}
+	return nil, release, nil
+}
+
+// findIdempotentTX looks up the latest transaction of the signer with the idempotency key, which is
+// only returned if it was created within the retention window
+func (m *manager) findIdempotentTX(ctx context.Context, signer, idempotencyKey string) (*apitypes.ManagedTX, error) {
+	mtx, err := m.persistence.GetTransactionByIdempotencyKey(ctx, signer, idempotencyKey)
+	if err != nil || mtx == nil {
+		return nil, err
+	}
+	if mtx.Created != nil && time.Time(*mtx.Created).Before(time.Now().Add(-m.idempotencyWindow)) {
+		return nil, nil
+	}
+	log.L(ctx).Infof("Transaction %s already submitted with idempotency key %s for %s", mtx.ID, idempotencyKey, signer)
+	return mtx, nil
+}
+
+// signerSet builds a lookup set of signing addresses, which are matched case-insensitively
+func signerSet(signers []string) map[string]bool {
+	set := make(map[string]bool, len(signers))
+	for _, s := range signers {
+		set[strings.ToLower(s)] = true
+	}
+	return set
+}
+
+// checkSignerAllowed applies the configured allow and deny lists, with the deny list taking precedence
+func (m *manager) checkSignerAllowed(ctx context.Context, signer string) error {
+	key := strings.ToLower(signer)
+	if m.signersDeny[key] || (m.signersAllow != nil && !m.signersAllow[key]) {
+		return i18n.NewError(ctx, tmmsgs.MsgSignerNotAllowed, signer)
+	}
+	return nil
+}
@@ -17,14 +17,21 @@
 package fftm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -45,7 +52,650 @@ func TestSendTXPersistFail(t *testing.T) {
 	err := json.Unmarshal([]byte(sampleSendTX), &txReq)
 	assert.NoError(t, err)
 
-	_, err = m.submitPreparedTX(m.ctx, "id1", &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1"}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
 	assert.Regexp(t, "pop", err)
 
 }
+
+func TestSendTXPolicyEngineNotConfigured(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	var txReq *ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &txReq)
+	assert.NoError(t, err)
+
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1", PolicyEngine: "wrong"}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "FF21078.*wrong", err)
+
+}
+
+func TestSendTXSubmissionTimeout(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil)
+	mp.On("WriteTransaction", m.ctx, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.SubmissionTimeout.String() == "5m0s"
+	}), true).Return(fmt.Errorf("pop"))
+
+	var txReq *ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &txReq)
+	assert.NoError(t, err)
+
+	var reqHeaders *apitypes.RequestHeaders
+	err = json.Unmarshal([]byte(`{"id":"id1","submissionTimeout":"-1s"}`), &reqHeaders)
+	assert.NoError(t, err)
+	_, err = m.submitPreparedTX(m.ctx, reqHeaders, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "FF21084", err)
+
+	err = json.Unmarshal([]byte(`{"id":"id1","submissionTimeout":"5m"}`), &reqHeaders)
+	assert.NoError(t, err)
+	_, err = m.submitPreparedTX(m.ctx, reqHeaders, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSendTXExpiry(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil)
+	mp.On("WriteTransaction", m.ctx, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.Expiry.String() == "2030-01-01T00:05:00Z"
+	}), true).Return(fmt.Errorf("pop"))
+
+	var txReq *ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &txReq)
+	assert.NoError(t, err)
+
+	var reqHeaders *apitypes.RequestHeaders
+	err = json.Unmarshal([]byte(`{"id":"id1","notBefore":"2030-01-01T00:05:00Z","expiry":"2030-01-01T00:05:00Z"}`), &reqHeaders)
+	assert.NoError(t, err)
+	_, err = m.submitPreparedTX(m.ctx, reqHeaders, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "FF21131", err)
+
+	err = json.Unmarshal([]byte(`{"id":"id1","notBefore":"2030-01-01T00:00:00Z","expiry":"2030-01-01T00:05:00Z"}`), &reqHeaders)
+	assert.NoError(t, err)
+	_, err = m.submitPreparedTX(m.ctx, reqHeaders, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSendTXConfirmationStrategy(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil)
+	mp.On("WriteTransaction", m.ctx, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.ConfirmationStrategy == "fast"
+	}), true).Return(fmt.Errorf("pop"))
+
+	var txReq *ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &txReq)
+	assert.NoError(t, err)
+
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1", ConfirmationStrategy: "slow"}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "FF21153", err)
+
+	// The connector does not report finalized blocks
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1", ConfirmationStrategy: "finalized"}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "FF21126.*FinalityAPI", err)
+
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1", ConfirmationStrategy: "fast"}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSendTXMaxDataSize(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.maxDataSize = 4
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil).Once()
+	mp.On("WriteTransaction", m.ctx, mock.Anything, true).Return(fmt.Errorf("pop")).Once()
+
+	var txReq *ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &txReq)
+	assert.NoError(t, err)
+
+	// Over the limit is rejected before a nonce is assigned
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1"}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x1234567890")
+	assert.Regexp(t, "FF21151.*5.*4", err)
+
+	// At the limit is accepted
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1"}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x12345678")
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSendTXLabelLimits(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.maxLabels = 2
+	m.maxLabelLength = 5
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil).Once()
+	mp.On("WriteTransaction", m.ctx, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.Labels["app"] == "abcde" && mtx.Labels["env"] == ""
+	}), true).Return(fmt.Errorf("pop")).Once()
+
+	var txReq *ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &txReq)
+	assert.NoError(t, err)
+
+	submit := func(labels map[string]string) error {
+		_, err := m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1", Labels: labels}, &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+		return err
+	}
+
+	err = submit(map[string]string{"a": "1", "b": "2", "c": "3"})
+	assert.Regexp(t, "FF21161.*3.*2", err)
+
+	err = submit(map[string]string{"": "1"})
+	assert.Regexp(t, "FF21162", err)
+
+	err = submit(map[string]string{"a=b": "1"})
+	assert.Regexp(t, "FF21162.*a=b", err)
+
+	err = submit(map[string]string{"abcdef": "1"})
+	assert.Regexp(t, "FF21163.*abcdef.*5", err)
+
+	err = submit(map[string]string{"app": "abcdef"})
+	assert.Regexp(t, "FF21163.*app.*5", err)
+
+	// At the limits is accepted, and the labels are persisted
+	err = submit(map[string]string{"app": "abcde", "env": ""})
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSendTXSignerAllowDeny(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.signersAllow = signerSet([]string{"0xAAAAA", "0xbbbbb"})
+	m.signersDeny = signerSet([]string{"0xBBBBB"})
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil)
+	mp.On("WriteTransaction", m.ctx, mock.Anything, true).Return(fmt.Errorf("pop"))
+
+	// Allowed - with a case-insensitive match, so we get as far as persistence
+	_, err := m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1"}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "pop", err)
+
+	// Denied - even though it is also in the allow list
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id2"}, &ffcapi.TransactionHeaders{From: "0xbbbbb"}, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "FF21089.*0xbbbbb", err)
+
+	// Not in the allow list
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id3"}, &ffcapi.TransactionHeaders{From: "0xccccc"}, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "FF21089.*0xccccc", err)
+
+	mp.AssertNumberOfCalls(t, "WriteTransaction", 1)
+
+}
+
+func TestSignerListsFromConfig(t *testing.T) {
+
+	tmconfig.Reset()
+	m := newManager(context.Background(), &ffcapimocks.API{})
+	assert.Nil(t, m.signersAllow)
+	assert.NoError(t, m.checkSignerAllowed(context.Background(), "0xaaaaa"))
+
+	config.Set(tmconfig.TransactionsSignersAllow, []string{"0xAAAAA"})
+	config.Set(tmconfig.TransactionsSignersDeny, []string{"0xBBBBB"})
+	m = newManager(context.Background(), &ffcapimocks.API{})
+	assert.NoError(t, m.checkSignerAllowed(context.Background(), "0xAaAaA"))
+	assert.Regexp(t, "FF21089", m.checkSignerAllowed(context.Background(), "0xbbbbb"))
+	assert.Regexp(t, "FF21089", m.checkSignerAllowed(context.Background(), "0xccccc"))
+
+}
+
+func TestSendTXIdempotencyKey(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mFFC := m.connector.(*ffcapimocks.API)
+	mFFC.On("NextNonceForSigner", mock.Anything, mock.MatchedBy(func(nonceReq *ffcapi.NextNonceForSignerRequest) bool {
+		return nonceReq.Signer == "0x12345"
+	})).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(1000),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mFFC.On("NextNonceForSigner", mock.Anything, mock.MatchedBy(func(nonceReq *ffcapi.NextNonceForSignerRequest) bool {
+		return nonceReq.Signer == "0x67890"
+	})).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(2000),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	submit := func(id, signer, idempotencyKey string) (*apitypes.ManagedTX, error) {
+		return m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: id, IdempotencyKey: idempotencyKey}, &ffcapi.TransactionHeaders{From: signer}, fftypes.NewFFBigInt(12345), "0x123456")
+	}
+
+	mtx1, err := submit("ns1:tx1", "0x12345", "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "key1", mtx1.IdempotencyKey)
+	assert.Equal(t, int64(1000), mtx1.Nonce.Int64())
+
+	// Duplicate key returns the existing transaction, without spending a nonce
+	mtx, err := submit("ns1:tx2", "0x12345", "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ns1:tx1", mtx.ID)
+	assert.Equal(t, int64(1000), mtx.Nonce.Int64())
+
+	// Distinct key creates a new transaction, on the next nonce
+	mtx, err = submit("ns1:tx3", "0x12345", "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, "ns1:tx3", mtx.ID)
+	assert.Equal(t, int64(1001), mtx.Nonce.Int64())
+
+	// Keys are scoped to the signer
+	mtx, err = submit("ns1:tx4", "0x67890", "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ns1:tx4", mtx.ID)
+	assert.Equal(t, int64(2000), mtx.Nonce.Int64())
+
+	// A concurrent submission with the same key is rejected
+	m.idempotencyKeys["0x12345/key3"] = true
+	_, err = submit("ns1:tx5", "0x12345", "key3")
+	assert.Regexp(t, "FF21090", err)
+
+	// Once outside the retention window, the key can be reused
+	m.idempotencyWindow = 0
+	mtx, err = submit("ns1:tx6", "0x12345", "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ns1:tx6", mtx.ID)
+	assert.Equal(t, int64(1002), mtx.Nonce.Int64())
+
+	mFFC.AssertExpectations(t)
+
+}
+
+func TestSendTXIdempotencyKeyLookup(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	existing := &apitypes.ManagedTX{ID: "existing", Created: fftypes.Now(), Nonce: fftypes.NewFFBigInt(1000), IdempotencyKey: "key1"}
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByIdempotencyKey", mock.Anything, "0x12345", "key1").Return(existing, nil).Once()
+
+	mtx, err := m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1", IdempotencyKey: "key1"}, &ffcapi.TransactionHeaders{From: "0x12345"}, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.NoError(t, err)
+	assert.Equal(t, "existing", mtx.ID)
+	assert.Empty(t, m.idempotencyKeys)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSendTXIdempotencyKeyExpired(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	created := fftypes.FFTime(time.Now().Add(-2 * m.idempotencyWindow))
+	existing := &apitypes.ManagedTX{ID: "existing", Created: &created, Nonce: fftypes.NewFFBigInt(1000), IdempotencyKey: "key1"}
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByIdempotencyKey", mock.Anything, "0x12345", "key1").Return(existing, nil).Once()
+
+	mtx, release, err := m.reserveIdempotencyKey(m.ctx, "0x12345", "key1")
+	assert.NoError(t, err)
+	assert.Nil(t, mtx)
+	assert.True(t, m.idempotencyKeys["0x12345/key1"])
+	release()
+	assert.Empty(t, m.idempotencyKeys)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSendTXIdempotencyKeyLookupFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "id1", IdempotencyKey: "key1"}, &ffcapi.TransactionHeaders{From: "0x12345"}, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.Regexp(t, "pop", err)
+	assert.Empty(t, m.idempotencyKeys)
+
+}
+
+func TestSendTXDependsOn(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	parent := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+
+	// The transaction depended on must exist
+	_, err := m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{DependsOn: "ns1:unknown"}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, nil, "")
+	assert.Regexp(t, "FF21092", err)
+
+	// A transaction cannot depend on itself
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "ns1:self", DependsOn: "ns1:self"}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, nil, "")
+	assert.Regexp(t, "FF21093", err)
+
+	// Or on a transaction that (indirectly) depends on it
+	child := genTestTxn("0xaaaaa", 10002, apitypes.TxStatusPending)
+	child.DependsOn = parent.ID
+	err = m.persistence.WriteTransaction(m.ctx, child, true)
+	assert.NoError(t, err)
+	grandchild := genTestTxn("0xaaaaa", 10003, apitypes.TxStatusPending)
+	grandchild.DependsOn = child.ID
+	err = m.persistence.WriteTransaction(m.ctx, grandchild, true)
+	assert.NoError(t, err)
+	parent.DependsOn = "ns1:cyclic"
+	err = m.persistence.WriteTransaction(m.ctx, parent, false)
+	assert.NoError(t, err)
+	_, err = m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{ID: "ns1:cyclic", DependsOn: grandchild.ID}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, nil, "")
+	assert.Regexp(t, "FF21093.*ns1:cyclic", err)
+
+	// A deleted ancestor ends the chain
+	err = m.persistence.DeleteTransaction(m.ctx, parent.ID)
+	assert.NoError(t, err)
+	err = m.checkDependency(m.ctx, "ns1:cyclic", grandchild.ID)
+	assert.NoError(t, err)
+
+}
+
+func TestSendTXDependsOnQueryFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", mock.Anything, "ns1:parent").Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.submitPreparedTX(m.ctx, &apitypes.RequestHeaders{DependsOn: "ns1:parent"}, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, nil, "")
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestSendTXRequestIDLogging(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	logHook := logtest.NewGlobal()
+	defer logHook.Reset()
+
+	mtx := sendSampleTXWithHeaders(t, m, "0xaaaaa", 12345, apitypes.RequestHeaders{
+		RequestID: "trace12345",
+	})
+	assert.Equal(t, "trace12345", mtx.RequestID)
+
+	tracked := false
+	for _, e := range logHook.AllEntries() {
+		if strings.HasPrefix(e.Message, "Tracking transaction") {
+			tracked = true
+			assert.Equal(t, "trace12345", e.Data["requestId"])
+		}
+	}
+	assert.True(t, tracked)
+
+	// Policy engine activity is logged with the request ID too
+	logHook.Reset()
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	m.policyLoopCycle(m.ctx, true)
+	policyErrorLogged := false
+	for _, e := range logHook.AllEntries() {
+		if strings.HasPrefix(e.Message, "Policy engine returned error") {
+			policyErrorLogged = true
+			assert.Equal(t, "trace12345", e.Data["requestId"])
+		}
+	}
+	assert.True(t, policyErrorLogged)
+
+	// One is generated if not supplied
+	mtx = sendSampleTX(t, m, "0xaaaaa", 12346)
+	assert.NotEmpty(t, mtx.RequestID)
+
+	txns, err := m.getTransactions(m.ctx, "", "", "", false, "", "", "", "", "trace12345", false, nil)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, "trace12345", txns[0].RequestID)
+
+}
+
+func TestSendTXPriority(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendSampleTXWithHeaders(t, m, "0xaaaaa", 12345, apitypes.RequestHeaders{
+		Priority: 10,
+	})
+	assert.Equal(t, 10, mtx.Priority)
+
+	// Priority is included in listings
+	txns, err := m.getTransactions(m.ctx, "", "", "", true, "", "", "", "", "", false, nil)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 1)
+	assert.Equal(t, 10, txns[0].Priority)
+
+}
+
+func TestSendTXAccessList(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	accessList := ffcapi.AccessList{
+		{
+			Address: "0xe1a078b9e2b145d0a7387f09277c6ae1d9470771",
+			StorageKeys: []string{
+				"0x0000000000000000000000000000000000000000000000000000000000000003",
+			},
+		},
+	}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionPrepare", m.ctx, mock.MatchedBy(func(req *ffcapi.TransactionPrepareRequest) bool {
+		return assert.Equal(t, accessList, req.AccessList)
+	})).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	mtx, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From:       "0xaaaaa",
+				AccessList: accessList,
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	// Stored with the transaction, so it is passed to the connector on each submission
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, accessList, rtx.TransactionHeaders.AccessList)
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestSendTXAccessListInvalid(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	validAddress := "0xe1a078b9e2b145d0a7387f09277c6ae1d9470771"
+	validKey := "0x0000000000000000000000000000000000000000000000000000000000000003"
+	for _, tc := range []struct {
+		accessList ffcapi.AccessList
+		err        string
+	}{
+		{ffcapi.AccessList{nil}, "FF21111.*entry 0: missing entry"},
+		{ffcapi.AccessList{{Address: ""}}, "FF21111.*entry 0: invalid address ''"},
+		{ffcapi.AccessList{{Address: validAddress[2:]}}, "FF21111.*invalid address"},
+		{ffcapi.AccessList{{Address: validAddress + "00"}}, "FF21111.*invalid address"},
+		{ffcapi.AccessList{{Address: "0xzz" + validAddress[4:]}}, "FF21111.*invalid address"},
+		{ffcapi.AccessList{{Address: validAddress}, {Address: validAddress, StorageKeys: []string{validKey, "0x03"}}}, "FF21111.*entry 1: invalid storage key '0x03'"},
+	} {
+		txReq := &apitypes.TransactionRequest{
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{
+					From:       "0xaaaaa",
+					AccessList: tc.accessList,
+				},
+			},
+		}
+		_, err := m.sendManagedTransaction(m.ctx, txReq)
+		assert.Regexp(t, tc.err, err)
+		_, err = m.estimateTransaction(m.ctx, txReq)
+		assert.Regexp(t, tc.err, err)
+		_, err = m.sendManagedContractDeployment(m.ctx, &apitypes.ContractDeployRequest{
+			ContractDeployPrepareRequest: ffcapi.ContractDeployPrepareRequest{
+				TransactionHeaders: txReq.TransactionHeaders,
+			},
+		})
+		assert.Regexp(t, tc.err, err)
+	}
+
+}
+
+func TestSendTXGasLimitEstimated(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendSampleTXWithHeaders(t, m, "0xaaaaa", 12345, apitypes.RequestHeaders{})
+	assert.Equal(t, int64(100000), mtx.Gas.Int64())
+	assert.Equal(t, apitypes.GasLimitSourceEstimated, mtx.GasLimitSource)
+
+}
+
+func TestSendTXGasLimitExplicit(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	// The connector does not estimate when gas is supplied, so returns the same value
+	mfc.On("TransactionPrepare", m.ctx, mock.MatchedBy(func(req *ffcapi.TransactionPrepareRequest) bool {
+		return req.Gas.Int64() == 250000
+	})).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(250000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	mtx, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{
+			GasLimitMultiplier: 2, // not applied to an explicit gas limit
+		},
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From: "0xaaaaa",
+				Gas:  fftypes.NewFFBigInt(250000),
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(250000), mtx.Gas.Int64())
+	assert.Equal(t, apitypes.GasLimitSourceExplicit, mtx.GasLimitSource)
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestSendTXGasLimitMultiplier(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := sendSampleTXWithHeaders(t, m, "0xaaaaa", 12345, apitypes.RequestHeaders{
+		GasLimitMultiplier: 1.5,
+	})
+	assert.Equal(t, int64(150000), mtx.Gas.Int64())
+	assert.Equal(t, apitypes.GasLimitSourceMultiplied, mtx.GasLimitSource)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(150000), rtx.Gas.Int64())
+	assert.Equal(t, apitypes.GasLimitSourceMultiplied, rtx.GasLimitSource)
+
+}
+
+func TestSendTXGasLimitMultiplierClampedToBlockLimit(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.blockGasLimit = 120000
+
+	mtx := sendSampleTXWithHeaders(t, m, "0xaaaaa", 12345, apitypes.RequestHeaders{
+		GasLimitMultiplier: 1.5,
+	})
+	assert.Equal(t, int64(120000), mtx.Gas.Int64())
+	assert.Equal(t, apitypes.GasLimitSourceMultiplied, mtx.GasLimitSource)
+
+}
+
+func TestSendTXGasLimitMultiplierInvalid(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	_, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{
+			GasLimitMultiplier: -1,
+		},
+	})
+	assert.Regexp(t, "FF21113", err)
+
+}
@@ -0,0 +1,159 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// exportStreams returns the definitions of all the persisted streams and their listeners, with the IDs and
+// timestamps removed so they can be imported into another environment
+func (m *manager) exportStreams(ctx context.Context) (*apitypes.EventStreamExport, error) {
+	export := &apitypes.EventStreamExport{
+		Version:  apitypes.EventStreamExportVersion,
+		Exported: fftypes.Now(),
+		Streams:  []*apitypes.EventStreamDefinition{},
+	}
+	var lastInPage *fftypes.UUID
+	for {
+		streamDefs, err := m.persistence.ListStreams(ctx, lastInPage, startupPaginationLimit, persistence.SortDirectionAscending)
+		if err != nil {
+			return nil, err
+		}
+		if len(streamDefs) == 0 {
+			break
+		}
+		for _, def := range streamDefs {
+			lastInPage = def.ID
+			listenerDefs, err := m.persistence.ListStreamListeners(ctx, nil, 0, persistence.SortDirectionAscending, def.ID)
+			if err != nil {
+				return nil, err
+			}
+			stream := &apitypes.EventStreamDefinition{
+				EventStream: *def,
+				Listeners:   make([]*apitypes.Listener, len(listenerDefs)),
+			}
+			stream.ID, stream.Created, stream.Updated = nil, nil, nil
+			for i, listenerDef := range listenerDefs {
+				listener := *listenerDef
+				listener.ID, listener.Created, listener.Updated, listener.StreamID = nil, nil, nil, nil
+				listener.Signature = ""
+				stream.Listeners[i] = &listener
+			}
+			export.Streams = append(export.Streams, stream)
+		}
+	}
+	return export, nil
+}
+
+// importStreams creates the streams in an export document, returning the result for each stream.
+// The whole document is validated first, so nothing is created if it is invalid. A stream that fails
+// part way through is deleted, so a stream is only ever imported with all of its listeners.
+func (m *manager) importStreams(ctx context.Context, req *apitypes.EventStreamImport) ([]*apitypes.EventStreamImportResult, error) {
+	if req.Version != apitypes.EventStreamExportVersion {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgUnsupportedExportVersion, req.Version)
+	}
+	if m.maxBatchLength > 0 && len(req.Streams) > m.maxBatchLength {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgBatchTooLarge, len(req.Streams), m.maxBatchLength)
+	}
+	switch req.OnConflict {
+	case "":
+		req.OnConflict = apitypes.EventStreamImportSkip
+	case apitypes.EventStreamImportSkip, apitypes.EventStreamImportOverwrite:
+	default:
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidImportConflict, req.OnConflict)
+	}
+	names := make(map[string]bool, len(req.Streams))
+	for i, def := range req.Streams {
+		if def == nil || def.Name == nil || *def.Name == "" {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgImportStreamMissingName, i)
+		}
+		if names[*def.Name] {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgImportStreamDuplicateName, *def.Name)
+		}
+		names[*def.Name] = true
+	}
+
+	results := make([]*apitypes.EventStreamImportResult, len(req.Streams))
+	for i, def := range req.Streams {
+		results[i] = m.importStream(ctx, def, req)
+		if results[i].Status == apitypes.EventStreamImportFailed {
+			log.L(ctx).Warnf("Failed to import event stream '%s': %s", results[i].Name, results[i].Error)
+		}
+	}
+	return results, nil
+}
+
+func (m *manager) importStream(ctx context.Context, def *apitypes.EventStreamDefinition, req *apitypes.EventStreamImport) *apitypes.EventStreamImportResult {
+	result := &apitypes.EventStreamImportResult{
+		Name:   *def.Name,
+		Status: apitypes.EventStreamImportCreated,
+	}
+	failed := func(err error) *apitypes.EventStreamImportResult {
+		result.Status = apitypes.EventStreamImportFailed
+		result.ID = nil
+		result.Listeners = 0
+		result.Error = err.Error()
+		return result
+	}
+
+	m.mux.Lock()
+	existing := m.streamsByName[*def.Name]
+	m.mux.Unlock()
+	if existing != nil {
+		if req.OnConflict == apitypes.EventStreamImportSkip {
+			result.Status = apitypes.EventStreamImportSkipped
+			result.ID = existing
+			return result
+		}
+		if err := m.deleteStream(ctx, existing.String()); err != nil {
+			return failed(err)
+		}
+		result.Status = apitypes.EventStreamImportOverwritten
+	}
+
+	stream := def.EventStream
+	spec, err := m.createAndStoreNewStream(ctx, &stream)
+	if err != nil {
+		return failed(err)
+	}
+	result.ID = spec.ID
+	for _, listenerDef := range def.Listeners {
+		if listenerDef == nil {
+			continue
+		}
+		listener := *listenerDef
+		listener.StreamID = spec.ID
+		if req.FromBlock != nil {
+			listener.FromBlock = req.FromBlock
+		}
+		if _, err := m.createAndStoreNewListener(ctx, &listener); err != nil {
+			err1 := m.deleteStream(ctx, spec.ID.String())
+			log.L(ctx).Infof("Cleaned up partially imported stream '%s' (err?=%v)", *def.Name, err1)
+			return failed(err)
+		}
+		result.Listeners++
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExportStreamsReadFailed(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListStreams", m.ctx, (*fftypes.UUID)(nil), startupPaginationLimit, persistence.SortDirectionAscending).Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.exportStreams(m.ctx)
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+}
+
+func TestExportListenersReadFailed(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListStreams", m.ctx, (*fftypes.UUID)(nil), startupPaginationLimit, persistence.SortDirectionAscending).Return([]*apitypes.EventStream{
+		{ID: fftypes.NewUUID()},
+	}, nil)
+	mp.On("ListStreamListeners", m.ctx, (*fftypes.UUID)(nil), 0, persistence.SortDirectionAscending, mock.Anything).Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.exportStreams(m.ctx)
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+}
+
+func TestImportStreamListenerFailCleansUp(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+
+	results, err := m.importStreams(m.ctx, &apitypes.EventStreamImport{
+		EventStreamExport: apitypes.EventStreamExport{
+			Version: apitypes.EventStreamExportVersion,
+			Streams: []*apitypes.EventStreamDefinition{
+				{
+					EventStream: apitypes.EventStream{Name: strPtr("stream1")},
+					Listeners:   []*apitypes.Listener{nil, {Name: strPtr("listener1")}},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.EventStreamImportFailed, results[0].Status)
+	assert.Regexp(t, "pop", results[0].Error)
+	assert.Nil(t, results[0].ID)
+
+	// The stream is not left behind without its listeners
+	export, err := m.exportStreams(m.ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, export.Streams)
+	assert.Empty(t, m.streamsByName)
+
+}
+
+func TestImportStreamCreateFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteStream", m.ctx, mock.Anything).Return(fmt.Errorf("pop"))
+	mp.On("DeleteCheckpoint", m.ctx, mock.Anything).Return(nil)
+	mp.On("ListStreamDeadLetters", m.ctx, mock.Anything, 0).Return([]*apitypes.DeadLetter{}, nil)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil).Maybe()
+
+	results, err := m.importStreams(m.ctx, &apitypes.EventStreamImport{
+		EventStreamExport: apitypes.EventStreamExport{
+			Version: apitypes.EventStreamExportVersion,
+			Streams: []*apitypes.EventStreamDefinition{
+				{EventStream: apitypes.EventStream{Name: strPtr("stream1")}},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.EventStreamImportFailed, results[0].Status)
+	assert.Regexp(t, "pop", results[0].Error)
+
+	mp.AssertExpectations(t)
+}
+
+func TestImportStreamOverwriteDeleteFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	existing := apitypes.NewULID()
+	m.streamsByName["stream1"] = existing
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListStreamListeners", m.ctx, (*fftypes.UUID)(nil), startupPaginationLimit, persistence.SortDirectionAscending, existing).Return(nil, fmt.Errorf("pop"))
+
+	results, err := m.importStreams(m.ctx, &apitypes.EventStreamImport{
+		EventStreamExport: apitypes.EventStreamExport{
+			Version: apitypes.EventStreamExportVersion,
+			Streams: []*apitypes.EventStreamDefinition{
+				{EventStream: apitypes.EventStream{Name: strPtr("stream1")}},
+			},
+		},
+		OnConflict: apitypes.EventStreamImportOverwrite,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.EventStreamImportFailed, results[0].Status)
+	assert.Regexp(t, "pop", results[0].Error)
+
+	mp.AssertExpectations(t)
+}
@@ -21,11 +21,13 @@ import (
 	"encoding/json"
 	"strconv"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-transaction-manager/internal/events"
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
@@ -35,6 +37,11 @@ const (
 	startupPaginationLimit = 25
 )
 
+const (
+	maxLimitActionClamp  = "clamp"  // a limit above the maximum returns the maximum number of entries
+	maxLimitActionReject = "reject" // a limit above the maximum is rejected with a 400
+)
+
 func (m *manager) restoreStreams() error {
 	var lastInPage *fftypes.UUID
 	for {
@@ -92,7 +99,7 @@ func (m *manager) deleteAllStreamListeners(ctx context.Context, streamID *fftype
 }
 
 func (m *manager) addRuntimeStream(def *apitypes.EventStream, listeners []*apitypes.Listener) (events.Stream, error) {
-	s, err := events.NewEventStream(m.ctx, def, m.connector, m.persistence, m.wsServer, listeners)
+	s, err := events.NewEventStream(m.ctx, def, m.connector, m.persistence, m.wsServer, listeners, m.blockListeners)
 	if err != nil {
 		return nil, err
 	}
@@ -128,6 +135,11 @@ func (m *manager) deleteStream(ctx context.Context, idStr string) error {
 }
 
 func (m *manager) reserveStreamName(ctx context.Context, name string, id *fftypes.UUID) (func(bool), error) {
+	if name == apitypes.PolicyDecisionsTopic {
+		// WebSocket streams use their name as the topic
+		return nil, i18n.NewError(ctx, tmmsgs.MsgReservedStreamName, name)
+	}
+
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
@@ -314,11 +326,141 @@ func (m *manager) getStream(ctx context.Context, idStr string) (*apitypes.EventS
 	}, nil
 }
 
+func (m *manager) getStreamCheckpoint(ctx context.Context, idStr string) (*apitypes.EventStreamCheckpoint, error) {
+	id, err := fftypes.ParseUUID(ctx, idStr)
+	if err != nil {
+		return nil, err
+	}
+	m.mux.Lock()
+	s := m.eventStreams[*id]
+	m.mux.Unlock()
+	if s == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgStreamNotFound, idStr)
+	}
+	return s.GetCheckpoint(ctx)
+}
+
+func (m *manager) setStreamCheckpoint(ctx context.Context, idStr string, updates *apitypes.EventStreamCheckpoint, confirmRewind bool) (*apitypes.EventStreamCheckpoint, error) {
+	id, err := fftypes.ParseUUID(ctx, idStr)
+	if err != nil {
+		return nil, err
+	}
+	m.mux.Lock()
+	s := m.eventStreams[*id]
+	m.mux.Unlock()
+	if s == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgStreamNotFound, idStr)
+	}
+	return s.SetCheckpoint(ctx, updates, confirmRewind)
+}
+
+// replayStream re-delivers the events in a confirmed historical block range to a stream, checking the
+// range against the chain head and the number of confirmations required by the stream
+func (m *manager) replayStream(ctx context.Context, idStr string, req *apitypes.EventStreamReplay) (*apitypes.EventStreamReplayResult, error) {
+	id, err := fftypes.ParseUUID(ctx, idStr)
+	if err != nil {
+		return nil, err
+	}
+	if !m.connectorSupports(ffcapi.CapabilityEventReplay) {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgEventReplayNotSupported)
+	}
+	if req.FromBlock == nil || req.ToBlock == nil || *req.FromBlock > *req.ToBlock {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidReplayRange)
+	}
+	m.mux.Lock()
+	s := m.eventStreams[*id]
+	m.mux.Unlock()
+	if s == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgStreamNotFound, idStr)
+	}
+	var confirmedHead uint64
+	head, confirmations := m.confirmations.HighestBlockSeen(), *s.Spec().Confirmations
+	if head > confirmations {
+		confirmedHead = head - confirmations
+	}
+	if req.ToBlock.Uint64() > confirmedHead {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgReplayAheadOfHead, *req.ToBlock, confirmedHead)
+	}
+	return s.Replay(ctx, req)
+}
+
+func (m *manager) getStreamDeadLetters(ctx context.Context, idStr, limitStr string) ([]*apitypes.DeadLetter, error) {
+	limit, err := m.parseLimit(ctx, limitStr)
+	if err != nil {
+		return nil, err
+	}
+	id, err := fftypes.ParseUUID(ctx, idStr)
+	if err != nil {
+		return nil, err
+	}
+	m.mux.Lock()
+	s := m.eventStreams[*id]
+	m.mux.Unlock()
+	if s == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgStreamNotFound, idStr)
+	}
+	return s.DeadLetters(ctx, limit)
+}
+
+func (m *manager) replayStreamDeadLetters(ctx context.Context, idStr string) (*apitypes.DeadLetterReplayResult, error) {
+	id, err := fftypes.ParseUUID(ctx, idStr)
+	if err != nil {
+		return nil, err
+	}
+	m.mux.Lock()
+	s := m.eventStreams[*id]
+	m.mux.Unlock()
+	if s == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgStreamNotFound, idStr)
+	}
+	return s.ReplayDeadLetters(ctx)
+}
+
+func (m *manager) ackStreamSSE(ctx context.Context, idStr, batchStr string) error {
+	id, err := fftypes.ParseUUID(ctx, idStr)
+	if err != nil {
+		return err
+	}
+	batchNumber, err := strconv.Atoi(batchStr)
+	if err != nil {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidSSEBatchNumber, batchStr)
+	}
+	m.mux.Lock()
+	s := m.eventStreams[*id]
+	m.mux.Unlock()
+	if s == nil {
+		return i18n.NewError(ctx, tmmsgs.MsgStreamNotFound, idStr)
+	}
+	return s.AckSSE(ctx, batchNumber)
+}
+
+func (m *manager) initLimits(ctx context.Context) error {
+	switch action := config.GetString(tmconfig.APIMaxLimitAction); action {
+	case maxLimitActionClamp:
+	case maxLimitActionReject:
+		m.rejectOverMaxLimit = true
+	default:
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidMaxLimitAction, action)
+	}
+	m.defaultLimit = config.GetInt(tmconfig.APIDefaultLimit)
+	m.maxLimit = config.GetInt(tmconfig.APIMaxLimit)
+	return nil
+}
+
+// parseLimit returns the configured default if no limit is supplied, and applies the configured maximum
+// to a supplied limit - either clamping it to the maximum, or rejecting it
 func (m *manager) parseLimit(ctx context.Context, limitStr string) (limit int, err error) {
-	if limitStr != "" {
-		if limit, err = strconv.Atoi(limitStr); err != nil {
-			return -1, i18n.NewError(ctx, tmmsgs.MsgInvalidLimit, limitStr, err)
+	if limitStr == "" {
+		return m.defaultLimit, nil
+	}
+	if limit, err = strconv.Atoi(limitStr); err != nil {
+		return -1, i18n.NewError(ctx, tmmsgs.MsgInvalidLimit, limitStr, err)
+	}
+	if m.maxLimit > 0 && (limit > m.maxLimit || limit <= 0) {
+		if m.rejectOverMaxLimit {
+			return -1, i18n.NewError(ctx, tmmsgs.MsgLimitExceedsMax, limit, m.maxLimit)
 		}
+		limit = m.maxLimit
 	}
 	return limit, nil
 }
@@ -22,8 +22,11 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
@@ -75,6 +78,33 @@ func TestRestoreStreamsAndListenersOK(t *testing.T) {
 
 }
 
+func TestRestoreStreamsSuspended(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil)
+
+	truthy := true
+	es1 := &apitypes.EventStream{ID: apitypes.NewULID(), Name: strPtr("stream1"), Suspended: &truthy}
+	err := m.persistence.WriteStream(m.ctx, es1)
+	assert.NoError(t, err)
+
+	e1l1 := &apitypes.Listener{ID: apitypes.NewULID(), Name: strPtr("listener1"), StreamID: es1.ID}
+	err = m.persistence.WriteListener(m.ctx, e1l1)
+	assert.NoError(t, err)
+
+	err = m.Start()
+	assert.NoError(t, err)
+
+	// Restored, but not started
+	assert.Equal(t, es1.ID, m.streamsByName["stream1"])
+	assert.Equal(t, apitypes.EventStreamStatusStopped, m.eventStreams[*es1.ID].Status())
+	mfc.AssertNotCalled(t, "EventStreamStart", mock.Anything, mock.Anything)
+
+}
+
 func TestRestoreStreamsReadFailed(t *testing.T) {
 
 	_, m, close := newTestManagerMockPersistence(t)
@@ -281,6 +311,10 @@ func TestCreateRenameStreamNameReservation(t *testing.T) {
 	_, err = m.createAndStoreNewStream(m.ctx, &apitypes.EventStream{Name: strPtr("Name1")})
 	assert.Regexp(t, "FF21047", err)
 
+	// The topic for policy decision events is reserved
+	_, err = m.createAndStoreNewStream(m.ctx, &apitypes.EventStream{Name: strPtr(apitypes.PolicyDecisionsTopic)})
+	assert.Regexp(t, "FF21137", err)
+
 	// Create a second stream to test clash on rename
 	es2, err := m.createAndStoreNewStream(m.ctx, &apitypes.EventStream{Name: strPtr("Name2")})
 	assert.NoError(t, err)
@@ -307,6 +341,16 @@ func TestCreateStreamValidateFail(t *testing.T) {
 
 }
 
+func TestCreateStreamBlockListenerNotConfigured(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	_, err := m.createAndStoreNewStream(m.ctx, &apitypes.EventStream{Name: strPtr("stream1"), BlockListener: strPtr("tokens")})
+	assert.Regexp(t, "FF21127.*tokens", err)
+
+}
+
 func TestCreateAndStoreNewStreamListenerBadID(t *testing.T) {
 	_, m, close := newTestManagerMockPersistence(t)
 	defer close()
@@ -676,3 +720,89 @@ func TestGetListenerStatusFailStillReturn(t *testing.T) {
 	mp.AssertExpectations(t)
 
 }
+
+func TestParseLimitClamp(t *testing.T) {
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	assert.False(t, m.rejectOverMaxLimit)
+
+	// Unspecified uses the default
+	limit, err := m.parseLimit(m.ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 100, limit)
+
+	limit, err = m.parseLimit(m.ctx, "250")
+	assert.NoError(t, err)
+	assert.Equal(t, 250, limit)
+
+	limit, err = m.parseLimit(m.ctx, "1000")
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, limit)
+
+	// Over the maximum, or unlimited, is clamped to the maximum
+	for _, limitStr := range []string{"1001", "0", "-1"} {
+		limit, err = m.parseLimit(m.ctx, limitStr)
+		assert.NoError(t, err)
+		assert.Equal(t, 1000, limit)
+	}
+
+	_, err = m.parseLimit(m.ctx, "lots")
+	assert.Regexp(t, "FF21044", err)
+}
+
+func TestParseLimitReject(t *testing.T) {
+	url, m, close := newTestManager(t)
+	defer close()
+	noopPolicyEngine(m)
+	config.Set(tmconfig.APIDefaultLimit, 10)
+	config.Set(tmconfig.APIMaxLimit, 50)
+	config.Set(tmconfig.APIMaxLimitAction, "reject")
+	err := m.initLimits(m.ctx)
+	assert.NoError(t, err)
+	assert.True(t, m.rejectOverMaxLimit)
+
+	limit, err := m.parseLimit(m.ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, limit)
+
+	limit, err = m.parseLimit(m.ctx, "50")
+	assert.NoError(t, err)
+	assert.Equal(t, 50, limit)
+
+	for _, limitStr := range []string{"51", "0"} {
+		_, err = m.parseLimit(m.ctx, limitStr)
+		assert.Regexp(t, "FF21200", err)
+	}
+
+	// Rejected as a bad request by the API
+	err = m.Start()
+	assert.NoError(t, err)
+	res, err := resty.New().R().Get(url + "/transactions?limit=51")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21200", res.String())
+}
+
+func TestParseLimitNoMaximum(t *testing.T) {
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.defaultLimit = 0
+	m.maxLimit = 0
+
+	limit, err := m.parseLimit(m.ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, limit)
+
+	limit, err = m.parseLimit(m.ctx, "100000")
+	assert.NoError(t, err)
+	assert.Equal(t, 100000, limit)
+}
+
+func TestInitLimitsBadAction(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.APIMaxLimitAction, "wrong")
+
+	m := newManager(context.Background(), nil)
+	err := m.initLimits(context.Background())
+	assert.Regexp(t, "FF21199", err)
+}
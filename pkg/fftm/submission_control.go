@@ -0,0 +1,62 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+func (m *manager) loadSubmissionControl(ctx context.Context) error {
+	control, err := m.persistence.GetSubmissionControl(ctx)
+	if err != nil {
+		return err
+	}
+	paused := control != nil && control.Paused
+	if paused {
+		log.L(ctx).Warnf("Submissions are paused, and will not be sent until resumed")
+	}
+	m.mux.Lock()
+	m.submissionsPaused = paused
+	m.mux.Unlock()
+	return nil
+}
+
+// setSubmissionsPaused sets or clears the emergency stop. The state is persisted before it takes effect, so
+// a pause that has been acknowledged is still in force after a restart.
+func (m *manager) setSubmissionsPaused(ctx context.Context, paused bool) (*apitypes.SubmissionControl, error) {
+	control := &apitypes.SubmissionControl{
+		Paused:  paused,
+		Updated: fftypes.Now(),
+	}
+	if err := m.persistence.WriteSubmissionControl(ctx, control); err != nil {
+		return nil, err
+	}
+	m.mux.Lock()
+	m.submissionsPaused = paused
+	m.mux.Unlock()
+	if paused {
+		log.L(ctx).Warnf("Submissions paused")
+	} else {
+		log.L(ctx).Infof("Submissions resumed")
+		m.markInflightUpdate()
+	}
+	return control, nil
+}
@@ -0,0 +1,151 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSubmissionsPausedNoSendsUntilResumed(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	_, err := m.setSubmissionsPaused(m.ctx, true)
+	assert.NoError(t, err)
+
+	mtx := sendSampleTX(t, m, "0xaaaaa", 12345)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x" + fftypes.NewRandB32().String(),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction
+	})).Return(nil).Once()
+
+	// Nothing is sent while paused
+	waitInflightSignal(t, m, true) // from sending the TX
+	m.policyLoopCycle(m.ctx, true)
+	m.policyLoopCycle(m.ctx, false)
+	assert.Equal(t, mtx.ID, m.inflight[0].mtx.ID)
+	assert.Equal(t, apitypes.TxStatusPending, m.inflight[0].mtx.Status)
+	assert.Nil(t, m.inflight[0].mtx.FirstSubmit)
+	mfc.AssertNotCalled(t, "TransactionSend", mock.Anything, mock.Anything)
+
+	// Sends continue once resumed
+	_, err = m.setSubmissionsPaused(m.ctx, false)
+	assert.NoError(t, err)
+	waitInflightSignal(t, m, false) // from resuming
+	m.policyLoopCycle(m.ctx, false)
+	assert.NotNil(t, m.inflight[0].mtx.FirstSubmit)
+
+	mfc.AssertExpectations(t)
+	mc.AssertExpectations(t)
+}
+
+func TestSubmissionsPausedSurvivesRestart(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.confirmations.(*confirmationsmocks.Manager).On("HighestBlockSeen").Return(uint64(0))
+
+	// Written by a previous run, before it crashed
+	err := m.persistence.WriteSubmissionControl(m.ctx, &apitypes.SubmissionControl{Paused: true, Updated: fftypes.Now()})
+	assert.NoError(t, err)
+
+	err = m.Start()
+	assert.NoError(t, err)
+	assert.True(t, m.readiness().SubmissionsPaused)
+}
+
+func TestSubmissionsPauseResumeAPI(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+	m.confirmations.(*confirmationsmocks.Manager).On("HighestBlockSeen").Return(uint64(0))
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var control apitypes.SubmissionControl
+	res, err := resty.New().R().
+		SetBody(&struct{}{}).
+		SetResult(&control).
+		Post(url + "/control/pause")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.True(t, control.Paused)
+	assert.NotNil(t, control.Updated)
+	assert.True(t, m.readiness().SubmissionsPaused)
+
+	persisted, err := m.persistence.GetSubmissionControl(m.ctx)
+	assert.NoError(t, err)
+	assert.True(t, persisted.Paused)
+
+	res, err = resty.New().R().
+		SetBody(&struct{}{}).
+		SetResult(&control).
+		Post(url + "/control/resume")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.False(t, control.Paused)
+	assert.False(t, m.readiness().SubmissionsPaused)
+
+	persisted, err = m.persistence.GetSubmissionControl(m.ctx)
+	assert.NoError(t, err)
+	assert.False(t, persisted.Paused)
+}
+
+func TestSubmissionsPauseWriteFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteSubmissionControl", mock.Anything, mock.Anything).Return(fmt.Errorf("pop"))
+
+	_, err := m.setSubmissionsPaused(context.Background(), true)
+	assert.Regexp(t, "pop", err)
+	assert.False(t, m.submissionsPaused)
+}
+
+func TestStartLeaderServicesSubmissionControlFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetSubmissionControl", mock.Anything).Return(nil, fmt.Errorf("pop"))
+
+	err := m.startLeaderServices()
+	assert.Regexp(t, "pop", err)
+	assert.False(t, m.leader)
+}
@@ -0,0 +1,135 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/hyperledger/firefly-transaction-manager"
+
+// traceParentHeader is the W3C trace context header, which is also how the span context of the
+// submission is persisted on the transaction
+const traceParentHeader = "traceparent"
+
+// tracePropagator reads the trace context from the headers of API requests, and writes it to
+// the transaction, so the spans of the policy loop join the trace of the request that submitted it
+var tracePropagator = propagation.TraceContext{}
+
+// initTracing sets up exporting of spans to an OTLP collector, if one is configured.
+// Otherwise the tracer is a no-op, and nothing is recorded.
+// It must be called before initConnectorAudit, which records the spans of connector calls.
+func (m *manager) initTracing(ctx context.Context) error {
+	endpoint := config.GetString(tmconfig.TracingOTLPEndpoint)
+	if endpoint == "" {
+		return nil
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if config.GetBool(tmconfig.TracingOTLPInsecure) {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return i18n.NewError(ctx, tmmsgs.MsgTracingInitFailed, err)
+	}
+	m.setTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(config.GetString(tmconfig.TracingServiceName)),
+		)),
+	))
+	log.L(ctx).Infof("Exporting OpenTelemetry spans to %s", endpoint)
+	return nil
+}
+
+func (m *manager) setTracerProvider(tp *sdktrace.TracerProvider) {
+	m.tracerProvider = tp
+	m.tracer = tp.Tracer(tracerName)
+}
+
+// tracingMiddleware puts the trace context from the headers of each API request into its context,
+// so the span of any transaction submitted by the request is a child of the caller's span
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		ctx := tracePropagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		next.ServeHTTP(res, req.WithContext(ctx))
+	})
+}
+
+// endSpan records the error (if any) on the span, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceParent returns the W3C trace context of the span in the context, to persist on a transaction,
+// or an empty string if there is no span
+func traceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	tracePropagator.Inject(ctx, carrier)
+	return carrier.Get(traceParentHeader)
+}
+
+// startTransactionSpan starts the span covering the processing of a transaction by the policy loop, from
+// when it enters the in-flight set until it reaches a terminal state. It is a child of the span that
+// submitted the transaction, even if that was before a restart.
+func (m *manager) startTransactionSpan(ctx context.Context, mtx *apitypes.ManagedTX) trace.Span {
+	if mtx.TraceParent != "" {
+		ctx = tracePropagator.Extract(ctx, propagation.MapCarrier{traceParentHeader: mtx.TraceParent})
+	}
+	_, span := m.tracer.Start(ctx, "transaction", trace.WithAttributes(
+		attribute.String("fftm.transaction.id", mtx.ID),
+		attribute.String("fftm.transaction.from", mtx.TransactionHeaders.From),
+	))
+	return span
+}
+
+// endTransactionSpan ends the span of a transaction that has reached a terminal state
+func endTransactionSpan(pending *pendingState) {
+	if pending.span == nil {
+		return
+	}
+	mtx := pending.mtx
+	pending.span.SetAttributes(attribute.String("fftm.transaction.status", string(mtx.Status)))
+	if mtx.TransactionHash != "" {
+		pending.span.SetAttributes(attribute.String("fftm.transaction.hash", mtx.TransactionHash))
+	}
+	if mtx.Status == apitypes.TxStatusFailed {
+		pending.span.SetStatus(codes.Error, mtx.ErrorMessage)
+	}
+	pending.span.End()
+	pending.span = nil
+}
@@ -0,0 +1,243 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func newTestOTelManager(t *testing.T) (*manager, *ffcapimocks.API, *tracetest.SpanRecorder, func()) {
+	_, m, done := newTestManager(t)
+	mfc := m.connector.(*ffcapimocks.API)
+	recorder := tracetest.NewSpanRecorder()
+	m.setTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	m.connectorAudit = newConnectorAudit(mfc, logrus.InfoLevel, nil)
+	m.connectorAudit.log = false
+	m.connectorAudit.tracer = m.tracer
+	m.connector = m.connectorAudit
+	return m, mfc, recorder, done
+}
+
+func endedSpan(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, s := range recorder.Ended() {
+		if s.Name() == name {
+			return s
+		}
+	}
+	assert.Fail(t, "span not found", name)
+	return nil
+}
+
+func spanAttribute(s sdktrace.ReadOnlySpan, key string) string {
+	for _, a := range s.Attributes() {
+		if string(a.Key) == key {
+			return a.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracingDisabledByDefault(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	assert.Nil(t, m.tracerProvider)
+	assert.Nil(t, m.connectorAudit)
+	_, span := m.tracer.Start(context.Background(), "test")
+	assert.False(t, span.IsRecording())
+
+}
+
+func TestTracingOTLPExporter(t *testing.T) {
+
+	testManagerCommonInit(t)
+	config.Set(tmconfig.PersistenceLevelDBPath, t.TempDir())
+	config.Set(tmconfig.TracingOTLPEndpoint, "localhost:4318")
+	config.Set(tmconfig.TracingOTLPInsecure, true)
+
+	mm, err := NewManager(context.Background(), &ffcapimocks.API{})
+	assert.NoError(t, err)
+	m := mm.(*manager)
+	defer m.Close()
+
+	assert.NotNil(t, m.tracerProvider)
+	// The connector calls are wrapped to record spans, but not logged
+	assert.NotNil(t, m.connectorAudit)
+	assert.False(t, m.connectorAudit.log)
+	assert.NotNil(t, m.connectorAudit.tracer)
+	assert.Equal(t, m.connectorAudit, m.connector)
+
+}
+
+func TestTracingConnectorAuditNotLogged(t *testing.T) {
+
+	m, mfc, recorder, done := newTestOTelManager(t)
+	defer done()
+
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	mfc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, assert.AnError)
+	_, _, err := m.connector.GasPriceEstimate(context.Background(), &ffcapi.GasPriceEstimateRequest{})
+	assert.Error(t, err)
+
+	for _, e := range hook.AllEntries() {
+		assert.NotEqual(t, "Connector audit", e.Message)
+	}
+	span := endedSpan(t, recorder, "connector.GasPriceEstimate")
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+	assert.Equal(t, string(ffcapi.ErrorReasonNotFound), spanAttribute(span, "fftm.connector.reason"))
+	assert.Equal(t, assert.AnError.Error(), span.Status().Description)
+
+}
+
+func TestTracingMiddlewareExtractsTraceParent(t *testing.T) {
+
+	var spanContext trace.SpanContext
+	handler := tracingMiddleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		spanContext = trace.SpanContextFromContext(req.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", testTraceParent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.True(t, spanContext.IsRemote())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spanContext.SpanID().String())
+
+}
+
+func TestTracingSubmitToConfirmSpans(t *testing.T) {
+
+	m, mfc, recorder, done := newTestOTelManager(t)
+	defer done()
+
+	mfc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		TransactionData: "RAW_UNSIGNED_BYTES",
+		Gas:             fftypes.NewFFBigInt(2000000),
+	}, ffcapi.ErrorReason(""), nil)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction
+	})).Run(func(args mock.Arguments) {
+		n := args[0].(*confirmations.Notification)
+		n.Transaction.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+			BlockNumber: fftypes.NewFFBigInt(12345),
+			BlockHash:   fftypes.NewRandB32().String(),
+			Success:     true,
+		})
+		n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+	}).Return(nil)
+
+	// Submit through the API, as a child of the caller's span
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(sampleSendTX))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", testTraceParent)
+	res := httptest.NewRecorder()
+	m.router().ServeHTTP(res, req)
+	assert.Equal(t, 202, res.Code)
+
+	// Send, then confirm
+	waitInflightSignal(t, m, true)
+	m.policyLoopCycle(m.ctx, true)
+	m.policyLoopCycle(m.ctx, false)
+	waitInflightSignal(t, m, true)
+	m.policyLoopCycle(m.ctx, true)
+	assert.Empty(t, m.inflight)
+
+	mtx, err := m.persistence.GetTransactionByID(m.ctx, "ns1:904F177C-C790-4B01-BDF4-F2B4E52E607E")
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusSucceeded, mtx.Status)
+	assert.NotEmpty(t, mtx.TraceParent)
+
+	submit := endedSpan(t, recorder, "submitTransaction")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", submit.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", submit.Parent().SpanID().String())
+	assert.Equal(t, mtx.ID, spanAttribute(submit, "fftm.transaction.id"))
+
+	prepare := endedSpan(t, recorder, "connector.TransactionPrepare")
+	assert.Equal(t, "00f067aa0ba902b7", prepare.Parent().SpanID().String())
+
+	assignNonce := endedSpan(t, recorder, "assignNonce")
+	assert.Equal(t, submit.SpanContext().SpanID(), assignNonce.Parent().SpanID())
+	nextNonce := endedSpan(t, recorder, "connector.NextNonceForSigner")
+	assert.Equal(t, assignNonce.SpanContext().SpanID(), nextNonce.Parent().SpanID())
+
+	// The processing by the policy loop continues the trace of the submission
+	txSpan := endedSpan(t, recorder, "transaction")
+	assert.Equal(t, submit.SpanContext().TraceID(), txSpan.SpanContext().TraceID())
+	assert.Equal(t, submit.SpanContext().SpanID(), txSpan.Parent().SpanID())
+	assert.Equal(t, string(apitypes.TxStatusSucceeded), spanAttribute(txSpan, "fftm.transaction.status"))
+	assert.Equal(t, "0x12345", spanAttribute(txSpan, "fftm.transaction.hash"))
+
+	execute := endedSpan(t, recorder, "policyEngine.execute")
+	assert.Equal(t, txSpan.SpanContext().SpanID(), execute.Parent().SpanID())
+	send := endedSpan(t, recorder, "connector.TransactionSend")
+	assert.Equal(t, execute.SpanContext().SpanID(), send.Parent().SpanID())
+
+}
+
+func TestTracingTransactionSpanFailed(t *testing.T) {
+
+	m, _, recorder, done := newTestOTelManager(t)
+	defer done()
+
+	mtx := &apitypes.ManagedTX{
+		ID:           "tx1",
+		Status:       apitypes.TxStatusFailed,
+		ErrorMessage: "pop",
+	}
+	pending := &pendingState{mtx: mtx, span: m.startTransactionSpan(m.ctx, mtx)}
+	endTransactionSpan(pending)
+	assert.Nil(t, pending.span)
+	endTransactionSpan(pending) // no-op
+
+	span := endedSpan(t, recorder, "transaction")
+	assert.False(t, span.Parent().IsValid())
+	assert.Equal(t, "pop", span.Status().Description)
+
+}
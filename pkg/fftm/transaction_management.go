@@ -18,13 +18,18 @@ package fftm
 
 import (
 	"context"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
 func (m *manager) getTransactionByID(ctx context.Context, txID string) (transaction *apitypes.ManagedTX, err error) {
@@ -38,7 +43,276 @@ func (m *manager) getTransactionByID(ctx context.Context, txID string) (transact
 	return tx, nil
 }
 
-func (m *manager) getTransactions(ctx context.Context, afterStr, limitStr, signer string, pending bool, dirString string) (transactions []*apitypes.ManagedTX, err error) {
+func (m *manager) getTransactionByHash(ctx context.Context, hash string) (transaction *apitypes.ManagedTX, err error) {
+	tx, err := m.persistence.GetTransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgTransactionNotFound, hash)
+	}
+	return tx, nil
+}
+
+func (m *manager) getTransactionHistory(ctx context.Context, txID string) (history []*apitypes.TxHistoryEntry, err error) {
+	tx, err := m.getTransactionByID(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if tx.History == nil {
+		return []*apitypes.TxHistoryEntry{}, nil
+	}
+	return tx.History, nil
+}
+
+// getTransactionDependents does not require the transaction itself to exist, so that dependents
+// whose parent has been deleted can still be found
+func (m *manager) getTransactionDependents(ctx context.Context, txID string) ([]*apitypes.ManagedTX, error) {
+	return m.persistence.ListTransactionsByDependsOn(ctx, txID, nil, 0, persistence.SortDirectionDescending)
+}
+
+func (m *manager) getTransactionConfirmations(ctx context.Context, txID string) (*apitypes.TxConfirmations, error) {
+	tx, err := m.getTransactionByID(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	return m.txConfirmations(tx), nil
+}
+
+// getTransactionsStatus returns the status of each of the transactions, read from persistence in a single
+// call. IDs that are not found are reported as such, rather than failing the whole request.
+func (m *manager) getTransactionsStatus(ctx context.Context, txIDs []string) (map[string]*apitypes.TxStatusSummary, error) {
+	if m.maxBatchLength > 0 && len(txIDs) > m.maxBatchLength {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgBatchTooLarge, len(txIDs), m.maxBatchLength)
+	}
+	txs, err := m.persistence.GetTransactionsByIDs(ctx, txIDs)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]*apitypes.TxStatusSummary, len(txIDs))
+	for _, txID := range txIDs {
+		results[txID] = &apitypes.TxStatusSummary{Found: false}
+	}
+	for _, tx := range txs {
+		c := m.txConfirmations(tx)
+		results[tx.ID] = &apitypes.TxStatusSummary{
+			Found:                 true,
+			Status:                tx.Status,
+			TransactionHash:       tx.TransactionHash,
+			ConfirmationCount:     c.Count,
+			ConfirmationsRequired: c.Required,
+		}
+	}
+	return results, nil
+}
+
+// getTransactionTrace returns the revert reason and execution trace of a submitted transaction, if the
+// connector supports tracing. The result is cached on the transaction once it is complete, as the trace
+// of a mined transaction cannot change (in-flight transactions are still owned by the policy loop).
+func (m *manager) getTransactionTrace(ctx context.Context, txID string) (*apitypes.TxTrace, error) {
+	tx, err := m.getTransactionByID(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Trace != nil {
+		return tx.Trace, nil
+	}
+	if tx.TransactionHash == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgTransactionNotSubmitted, txID)
+	}
+	if _, ok := m.baseConnector().(ffcapi.TraceAPI); !ok || !m.connectorSupports(ffcapi.CapabilityTrace) {
+		return &apitypes.TxTrace{Supported: false}, nil
+	}
+	res, _, err := m.connector.(ffcapi.TraceAPI).TransactionTrace(ctx, &ffcapi.TransactionTraceRequest{
+		TransactionHash: tx.TransactionHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	trace := &apitypes.TxTrace{
+		Supported:    true,
+		RevertReason: res.RevertReason,
+		Trace:        res.Trace,
+		Fetched:      fftypes.Now(),
+	}
+	if txComplete(tx) && tx.Receipt != nil {
+		tx.Trace = trace
+		if err := m.persistence.WriteTransaction(ctx, tx, false); err != nil {
+			return nil, err
+		}
+	}
+	return trace, nil
+}
+
+// txConfirmations builds the confirmation progress of a transaction. Completed transactions report the
+// confirmations that were stored with them, while transactions still in-flight report the blocks the
+// confirmation manager has accumulated so far for the receipt.
+func (m *manager) txConfirmations(tx *apitypes.ManagedTX) *apitypes.TxConfirmations {
+	c := &apitypes.TxConfirmations{
+		Required:      m.requiredConfirmations,
+		Strategy:      tx.ConfirmationStrategy,
+		Confirmations: []confirmations.BlockInfo{},
+	}
+	if required, _ := m.confirmationRequirement(m.ctx, tx.ConfirmationStrategy); required != nil {
+		c.Required = required.Confirmations
+	}
+	switch {
+	case txComplete(tx):
+		if tx.Receipt != nil {
+			c.BlockNumber = tx.Receipt.BlockNumber
+			c.BlockHash = tx.Receipt.BlockHash
+		}
+		if tx.Confirmations != nil {
+			c.Confirmations = tx.Confirmations
+		}
+	case tx.TransactionHash != "":
+		if progress := m.confirmationsFor(tx.Connector).TransactionProgress(tx.TransactionHash); progress != nil && progress.BlockHash != "" {
+			c.BlockNumber = fftypes.NewFFBigInt(int64(progress.BlockNumber))
+			c.BlockHash = progress.BlockHash
+			c.Confirmations = progress.Confirmations
+		}
+	}
+	c.Mined = c.BlockHash != ""
+	c.Confirmed = c.Mined && txComplete(tx)
+	c.Count = len(c.Confirmations)
+	return c
+}
+
+// confirmationRequirement resolves the named confirmation strategy of a transaction, or returns nil if it has
+// none so the default of the confirmation manager applies. Waiting for finality requires the connector to
+// report the finalized block.
+func (m *manager) confirmationRequirement(ctx context.Context, strategy string) (*confirmations.Requirement, error) {
+	if strategy == "" {
+		return nil, nil
+	}
+	required, err := confirmations.ResolveStrategy(ctx, strategy)
+	if err != nil {
+		return nil, err
+	}
+	if required.Finalized {
+		if _, ok := m.baseConnector().(ffcapi.FinalityAPI); !ok || !m.connectorSupports(ffcapi.CapabilityFinality) {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgConnectorAPINotImplemented, "FinalityAPI")
+		}
+	}
+	return required, nil
+}
+
+// addConfirmationProgress sets the confirmation counts on transactions being returned on the API
+func (m *manager) addConfirmationProgress(txs ...*apitypes.ManagedTX) {
+	for _, tx := range txs {
+		c := m.txConfirmations(tx)
+		tx.ConfirmationCount = c.Count
+		tx.ConfirmationsRequired = c.Required
+	}
+}
+
+const defaultWaitConfirmedTimeout = 30 * time.Second
+
+func txComplete(tx *apitypes.ManagedTX) bool {
+	return tx.Status != apitypes.TxStatusPending && tx.Status != apitypes.TxStatusScheduled
+}
+
+// txLogContext adds the caller supplied request ID of a transaction to the log context, so that it
+// is included in every log line emitted while processing the transaction
+func txLogContext(ctx context.Context, mtx *apitypes.ManagedTX) context.Context {
+	if mtx.RequestID == "" {
+		return ctx
+	}
+	return log.WithLogField(ctx, "requestId", mtx.RequestID)
+}
+
+// waitTransactionConfirmed blocks until the transaction is no longer pending, the timeout is reached,
+// or the context is cancelled (such as by the client disconnecting).
+// Returns 200 if the transaction completed, or 202 if it is still pending.
+func (m *manager) waitTransactionConfirmed(ctx context.Context, txID, timeoutStr string) (status int, transaction *apitypes.ManagedTX, err error) {
+	timeout := defaultWaitConfirmedTimeout
+	if timeoutStr != "" {
+		ffd, err := fftypes.ParseDurationString(timeoutStr, time.Millisecond)
+		if err != nil || ffd < 0 {
+			return -1, nil, i18n.NewError(ctx, tmmsgs.MsgInvalidWaitTimeout, timeoutStr)
+		}
+		timeout = time.Duration(ffd)
+	}
+
+	// Register for updates before we read the state, so we cannot miss an update
+	waiter := m.addTransactionWaiter(txID)
+	defer m.removeTransactionWaiter(txID, waiter)
+
+	tx, err := m.getTransactionByID(ctx, txID)
+	if err != nil {
+		return -1, nil, err
+	}
+	if txComplete(tx) {
+		return http.StatusOK, tx, nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-waiter:
+		// Re-read the final state from persistence
+		if tx, err = m.getTransactionByID(ctx, txID); err != nil {
+			return -1, nil, err
+		}
+		if txComplete(tx) {
+			return http.StatusOK, tx, nil
+		}
+		return http.StatusAccepted, tx, nil
+	case <-timer.C:
+		log.L(ctx).Debugf("Timed out after %s waiting for transaction %s to complete", timeout, txID)
+		return http.StatusAccepted, tx, nil
+	case <-ctx.Done():
+		return -1, nil, i18n.NewError(ctx, i18n.MsgContextCanceled)
+	}
+}
+
+func (m *manager) addTransactionWaiter(txID string) chan struct{} {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	waiter := make(chan struct{})
+	m.txWaiters[txID] = append(m.txWaiters[txID], waiter)
+	return waiter
+}
+
+func (m *manager) removeTransactionWaiter(txID string, waiter chan struct{}) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	waiters := m.txWaiters[txID]
+	for i, w := range waiters {
+		if w == waiter {
+			waiters = append(waiters[0:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(m.txWaiters, txID)
+	} else {
+		m.txWaiters[txID] = waiters
+	}
+}
+
+// notifyTransactionWaiters wakes any API requests waiting for a transaction to complete
+func (m *manager) notifyTransactionWaiters(txID string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for _, waiter := range m.txWaiters[txID] {
+		close(waiter)
+	}
+	delete(m.txWaiters, txID)
+}
+
+func (m *manager) parseTimeParam(ctx context.Context, name, timeStr string) (*fftypes.FFTime, error) {
+	if timeStr == "" {
+		return nil, nil
+	}
+	t, err := fftypes.ParseTimeString(timeStr)
+	if err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidTimeParam, timeStr, name)
+	}
+	return t, nil
+}
+
+func (m *manager) getTransactions(ctx context.Context, afterStr, limitStr, signer string, pending bool, statusStr, fromTimeStr, toTimeStr, dirString, requestID string, includeDeleted bool, labelFilters []string) (transactions []*apitypes.ManagedTX, err error) {
 	limit, err := m.parseLimit(ctx, limitStr)
 	if err != nil {
 		return nil, err
@@ -52,6 +326,40 @@ func (m *manager) getTransactions(ctx context.Context, afterStr, limitStr, signe
 	default:
 		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidSortDirection, dirString)
 	}
+	var status apitypes.TxStatus
+	if statusStr != "" {
+		for _, s := range []apitypes.TxStatus{apitypes.TxStatusPending, apitypes.TxStatusScheduled, apitypes.TxStatusSucceeded, apitypes.TxStatusFailed, apitypes.TxStatusWouldSubmit, apitypes.TxStatusDeleted} {
+			if strings.EqualFold(statusStr, string(s)) {
+				status = s
+			}
+		}
+		if status == "" {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidTXStatus, statusStr)
+		}
+	}
+	fromTime, err := m.parseTimeParam(ctx, "fromTime", fromTimeStr)
+	if err != nil {
+		return nil, err
+	}
+	toTime, err := m.parseTimeParam(ctx, "toTime", toTimeStr)
+	if err != nil {
+		return nil, err
+	}
+	if fromTime != nil && toTime != nil && fromTime.UnixNano() > toTime.UnixNano() {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidTimeRange)
+	}
+	timeRange := fromTime != nil || toTime != nil
+	var labels map[string]string
+	if len(labelFilters) > 0 {
+		labels = make(map[string]string, len(labelFilters))
+		for _, lf := range labelFilters {
+			kv := strings.SplitN(lf, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidLabelFilter, lf)
+			}
+			labels[kv[0]] = kv[1]
+		}
+	}
 	var afterTx *apitypes.ManagedTX
 	if afterStr != "" {
 		// Get the transaction, as we need this to exist to pick the right field depending on the index that's been chosen
@@ -66,22 +374,79 @@ func (m *manager) getTransactions(ctx context.Context, afterStr, limitStr, signe
 	switch {
 	case signer != "" && pending:
 		return nil, i18n.NewError(ctx, tmmsgs.MsgTXConflictSignerPending)
-	case signer != "":
-		var afterNonce *fftypes.FFBigInt
-		if afterTx != nil {
-			afterNonce = afterTx.Nonce
-		}
-		return m.persistence.ListTransactionsByNonce(ctx, signer, afterNonce, limit, dir)
-	case pending:
-		var afterSequence *fftypes.UUID
-		if afterTx != nil {
-			afterSequence = afterTx.SequenceID
-		}
-		return m.persistence.ListTransactionsPending(ctx, afterSequence, limit, dir)
-	default:
-		return m.persistence.ListTransactionsByCreateTime(ctx, afterTx, limit, dir)
+	case status != "" && pending:
+		return nil, i18n.NewError(ctx, tmmsgs.MsgTXConflictStatusPending)
+	case timeRange && (signer != "" || pending || status != ""):
+		return nil, i18n.NewError(ctx, tmmsgs.MsgTXConflictTimeRange)
+	case requestID != "" && (signer != "" || pending || status != "" || timeRange):
+		return nil, i18n.NewError(ctx, tmmsgs.MsgTXConflictRequestID)
+	case labels != nil && (signer != "" || pending || status != "" || timeRange || requestID != ""):
+		return nil, i18n.NewError(ctx, tmmsgs.MsgTXConflictLabel)
+	}
+	list := func(afterTx *apitypes.ManagedTX) ([]*apitypes.ManagedTX, error) {
+		switch {
+		case labels != nil:
+			return m.persistence.ListTransactionsByLabels(ctx, labels, afterTx, limit, dir)
+		case requestID != "":
+			return m.persistence.ListTransactionsByRequestID(ctx, requestID, afterTx, limit, dir)
+		case timeRange:
+			return m.persistence.ListTransactionsByCreateTimeRange(ctx, fromTime, toTime, afterTx, limit, dir)
+		case status != "":
+			return m.persistence.ListTransactionsByStatus(ctx, status, signer, afterTx, limit, dir)
+		case signer != "":
+			var afterNonce *fftypes.FFBigInt
+			if afterTx != nil {
+				afterNonce = afterTx.Nonce
+			}
+			return m.persistence.ListTransactionsByNonce(ctx, signer, afterNonce, limit, dir)
+		case pending:
+			var afterSequence *fftypes.UUID
+			if afterTx != nil {
+				afterSequence = afterTx.SequenceID
+			}
+			return m.persistence.ListTransactionsPending(ctx, afterSequence, limit, dir)
+		default:
+			return m.persistence.ListTransactionsByCreateTime(ctx, afterTx, limit, dir)
+		}
+	}
+	if includeDeleted || status != "" || pending {
+		return list(afterTx)
 	}
 
+	// Deleted transactions are excluded, so we read on through the index until we fill the page
+	transactions = []*apitypes.ManagedTX{}
+	for {
+		page, err := list(afterTx)
+		if err != nil {
+			return nil, err
+		}
+		for _, mtx := range page {
+			if mtx.Status != apitypes.TxStatusDeleted && (limit <= 0 || len(transactions) < limit) {
+				transactions = append(transactions, mtx)
+			}
+		}
+		if limit <= 0 || len(page) < limit || len(transactions) == limit {
+			return transactions, nil
+		}
+		afterTx = page[len(page)-1]
+	}
+
+}
+
+func (m *manager) requestTransactionCancel(ctx context.Context, txID string) (transaction *apitypes.ManagedTX, err error) {
+	res := m.policyEngineAPIRequest(ctx, &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeCancel,
+		txID:        txID,
+	})
+	return res.tx, res.err
+}
+
+func (m *manager) requestTransactionUndelete(ctx context.Context, txID string) (transaction *apitypes.ManagedTX, err error) {
+	res := m.policyEngineAPIRequest(ctx, &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeUndelete,
+		txID:        txID,
+	})
+	return res.tx, res.err
 }
 
 func (m *manager) requestTransactionDeletion(ctx context.Context, txID string) (status int, transaction *apitypes.ManagedTX, err error) {
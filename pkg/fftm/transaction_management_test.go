@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -45,6 +47,38 @@ func TestGetTransactionErrors(t *testing.T) {
 
 }
 
+func TestGetTransactionByHashErrors(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByHash", m.ctx, mock.Anything).Return(nil, fmt.Errorf("pop")).Once()
+	mp.On("Close", mock.Anything).Return(nil).Maybe()
+
+	_, err := m.getTransactionByHash(m.ctx, "0x12345")
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestGetTransactionDependentsErrors(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByDependsOn", m.ctx, "id", (*apitypes.ManagedTX)(nil), 0, persistence.SortDirectionDescending).Return(nil, fmt.Errorf("pop")).Once()
+	mp.On("Close", mock.Anything).Return(nil).Maybe()
+
+	_, err := m.getTransactionDependents(m.ctx, "id")
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
+
 func TestGetTransactionsErrors(t *testing.T) {
 
 	_, m, close := newTestManagerMockPersistence(t)
@@ -53,23 +87,48 @@ func TestGetTransactionsErrors(t *testing.T) {
 	mp := m.persistence.(*persistencemocks.Persistence)
 	mp.On("GetTransactionByID", m.ctx, mock.Anything).Return(nil, fmt.Errorf("pop")).Once()
 	mp.On("GetTransactionByID", m.ctx, mock.Anything).Return(nil, nil).Once()
+	mp.On("ListTransactionsByCreateTime", m.ctx, (*apitypes.ManagedTX)(nil), 100, persistence.SortDirectionDescending).Return(nil, fmt.Errorf("pop")).Once()
 	mp.On("Close", mock.Anything).Return(nil).Maybe()
 
-	_, err := m.getTransactions(m.ctx, "", "bad limit", "", false, "")
+	_, err := m.getTransactions(m.ctx, "", "bad limit", "", false, "", "", "", "", "", false, nil)
 	assert.Regexp(t, "FF21044", err)
 
-	_, err = m.getTransactions(m.ctx, "", "", "", false, "wrong")
+	_, err = m.getTransactions(m.ctx, "", "", "", false, "", "", "", "wrong", "", false, nil)
 	assert.Regexp(t, "FF21064", err)
 
-	_, err = m.getTransactions(m.ctx, "", "", "cannot be specified with pending", true, "")
+	_, err = m.getTransactions(m.ctx, "", "", "cannot be specified with pending", true, "", "", "", "", "", false, nil)
 	assert.Regexp(t, "FF21063", err)
 
-	_, err = m.getTransactions(m.ctx, "after-causes-failure", "", "", false, "")
+	_, err = m.getTransactions(m.ctx, "", "", "", false, "unknown", "", "", "", "", false, nil)
+	assert.Regexp(t, "FF21077", err)
+
+	_, err = m.getTransactions(m.ctx, "", "", "", true, "pending", "", "", "", "", false, nil)
+	assert.Regexp(t, "FF21076", err)
+
+	_, err = m.getTransactions(m.ctx, "", "", "", false, "", "not a time", "", "", "", false, nil)
+	assert.Regexp(t, "FF21081.*fromTime", err)
+
+	_, err = m.getTransactions(m.ctx, "", "", "", false, "", "", "not a time", "", "", false, nil)
+	assert.Regexp(t, "FF21081.*toTime", err)
+
+	_, err = m.getTransactions(m.ctx, "", "", "", false, "", "2022-08-02T00:00:00Z", "2022-08-01T00:00:00Z", "", "", false, nil)
+	assert.Regexp(t, "FF21082", err)
+
+	_, err = m.getTransactions(m.ctx, "", "", "0xaaaaa", false, "", "2022-08-01T00:00:00Z", "", "", "", false, nil)
+	assert.Regexp(t, "FF21083", err)
+
+	_, err = m.getTransactions(m.ctx, "", "", "0xaaaaa", false, "", "", "", "", "trace1", false, nil)
+	assert.Regexp(t, "FF21097", err)
+
+	_, err = m.getTransactions(m.ctx, "after-causes-failure", "", "", false, "", "", "", "", "", false, nil)
 	assert.Regexp(t, "pop", err)
 
-	_, err = m.getTransactions(m.ctx, "after-not-found", "", "", false, "")
+	_, err = m.getTransactions(m.ctx, "after-not-found", "", "", false, "", "", "", "", "", false, nil)
 	assert.Regexp(t, "FF21062", err)
 
+	_, err = m.getTransactions(m.ctx, "", "", "", false, "", "", "", "", "", false, nil)
+	assert.Regexp(t, "pop", err)
+
 	mp.AssertExpectations(t)
 
 }
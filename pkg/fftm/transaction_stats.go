@@ -0,0 +1,109 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+const defaultStatsWindow = 10 * time.Minute
+
+const statsPageSize = 100
+
+// getTransactionStats reports totals across all transactions. The counts by status are maintained by the persistence
+// layer, and the in-flight count by the policy loop, so only the transactions submitted within the window are read.
+func (m *manager) getTransactionStats(ctx context.Context, windowStr string) (*apitypes.TransactionStats, error) {
+	window := defaultStatsWindow
+	if windowStr != "" {
+		ffd, err := fftypes.ParseDurationString(windowStr, time.Minute)
+		if err != nil || ffd <= 0 {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidStatsWindow, windowStr)
+		}
+		window = time.Duration(ffd)
+	}
+
+	byStatus, err := m.persistence.CountTransactionsByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mux.Lock()
+	inflight := 0
+	for _, count := range m.inflightBySigner {
+		inflight += count
+	}
+	m.mux.Unlock()
+	pending := byStatus[apitypes.TxStatusPending] - inflight
+	if pending < 0 {
+		// The in-flight set is as of the last policy loop cycle, so can include transactions that have since completed
+		pending = 0
+	}
+	ffWindow := fftypes.FFDuration(window)
+	stats := &apitypes.TransactionStats{
+		ByStatus: byStatus,
+		InFlight: inflight,
+		Pending:  pending,
+		Window:   &ffWindow,
+	}
+
+	from := fftypes.FFTime(time.Now().Add(-window))
+	var totalConfirmationTime time.Duration
+	var after *apitypes.ManagedTX
+	for {
+		page, err := m.persistence.ListTransactionsByCreateTimeRange(ctx, &from, nil, after, statsPageSize, persistence.SortDirectionAscending)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range page {
+			stats.Submitted++
+			if confirmationTime, ok := txConfirmationTime(tx); ok {
+				stats.Confirmed++
+				totalConfirmationTime += confirmationTime
+			}
+		}
+		if len(page) < statsPageSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if stats.Confirmed > 0 {
+		avg := fftypes.FFDuration(totalConfirmationTime / time.Duration(stats.Confirmed))
+		stats.AvgConfirmationTime = &avg
+	}
+	return stats, nil
+}
+
+// txConfirmationTime returns the time from the first submission of a transaction to the blockchain until it was
+// confirmed, for a transaction that has succeeded
+func txConfirmationTime(tx *apitypes.ManagedTX) (time.Duration, bool) {
+	if tx.Status != apitypes.TxStatusSucceeded || tx.FirstSubmit == nil {
+		return 0, false
+	}
+	// The confirmation is the most recent history entry, so is kept when older entries are summarized
+	for i := len(tx.History) - 1; i >= 0; i-- {
+		if h := tx.History[i]; h.Action == apitypes.TxActionConfirmed && h.Time != nil {
+			return h.Time.Time().Sub(*tx.FirstSubmit.Time()), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,147 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const typedDataDomainType = "EIP712Domain"
+
+var typedDataArraySuffix = regexp.MustCompile(`\[[0-9]*\]$`)
+
+// signTypedData resolves EIP-712 typed data to its hash and signs it with the key of the signer, via the
+// connector. The typed data is validated before it is passed to the connector, so a malformed request is
+// rejected without the connector (or the key material behind it) being involved.
+func (m *manager) signTypedData(ctx context.Context, request *apitypes.TypedDataRequest) (*apitypes.SignedTypedData, error) {
+
+	if request.From == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, "from is required")
+	}
+	if err := m.checkSignerAllowed(ctx, request.From); err != nil {
+		return nil, err
+	}
+	if _, ok := m.baseConnector().(ffcapi.TypedDataAPI); !ok || !m.connectorSupports(ffcapi.CapabilityTypedData) {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgTypedDataNotSupported)
+	}
+	if err := validateTypedData(ctx, request.TypedData); err != nil {
+		return nil, err
+	}
+
+	id := request.Headers.ID
+	if id == "" {
+		id = fftypes.NewUUID().String()
+	}
+	res, _, err := m.connector.(ffcapi.TypedDataAPI).SignTypedData(ctx, &request.SignTypedDataRequest)
+	if err != nil {
+		return nil, err
+	}
+	log.L(ctx).Infof("Signed typed data '%s' from=%s primaryType=%s hash=%s", id, request.From, request.TypedData.PrimaryType, res.Hash)
+	return &apitypes.SignedTypedData{
+		ID:        id,
+		RequestID: request.Headers.RequestID,
+		From:      request.From,
+		TypedData: request.TypedData,
+		Hash:      res.Hash,
+		Signature: res.Signature,
+		Signed:    fftypes.Now(),
+	}, nil
+}
+
+// validateTypedData checks the structure of EIP-712 typed data. Every type referenced by a field must be
+// either declared in the types, or one of the atomic/dynamic types of the ABI (optionally as an array),
+// the primary type must be declared, and the domain and message must be objects. The message must
+// contain every field of the primary type.
+func validateTypedData(ctx context.Context, td *ffcapi.TypedData) error {
+	if td == nil {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, "typedData is required")
+	}
+	if _, ok := td.Types[typedDataDomainType]; !ok {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, fmt.Sprintf("types must include '%s'", typedDataDomainType))
+	}
+	for typeName, fields := range td.Types {
+		if typeName == "" || isAtomicTypedDataType(typeName) {
+			return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, fmt.Sprintf("invalid type name '%s'", typeName))
+		}
+		names := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			if f == nil || f.Name == "" {
+				return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, fmt.Sprintf("field with no name in type '%s'", typeName))
+			}
+			if names[f.Name] {
+				return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, fmt.Sprintf("duplicate field '%s' in type '%s'", f.Name, typeName))
+			}
+			names[f.Name] = true
+			baseType := f.Type
+			for typedDataArraySuffix.MatchString(baseType) {
+				baseType = typedDataArraySuffix.ReplaceAllString(baseType, "")
+			}
+			if _, isStruct := td.Types[baseType]; !isStruct && !isAtomicTypedDataType(baseType) {
+				return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, fmt.Sprintf("unknown type '%s' for field '%s' in type '%s'", f.Type, f.Name, typeName))
+			}
+		}
+	}
+	primaryFields, ok := td.Types[td.PrimaryType]
+	if !ok || td.PrimaryType == typedDataDomainType {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, fmt.Sprintf("primaryType '%s' is not declared in types", td.PrimaryType))
+	}
+	if domain, ok := td.Domain.JSONObjectOk(true); !ok || domain == nil {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, "domain must be an object")
+	}
+	message, ok := td.Message.JSONObjectOk(true)
+	if !ok || message == nil {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, "message must be an object")
+	}
+	for _, f := range primaryFields {
+		if _, ok := message[f.Name]; !ok {
+			return i18n.NewError(ctx, tmmsgs.MsgInvalidTypedData, fmt.Sprintf("message is missing field '%s' of type '%s'", f.Name, td.PrimaryType))
+		}
+	}
+	return nil
+}
+
+func isAtomicTypedDataType(t string) bool {
+	switch t {
+	case "bool", "address", "string", "bytes":
+		return true
+	}
+	var bits string
+	switch {
+	case strings.HasPrefix(t, "bytes"):
+		n, err := strconv.Atoi(strings.TrimPrefix(t, "bytes"))
+		return err == nil && n >= 1 && n <= 32
+	case strings.HasPrefix(t, "uint"):
+		bits = strings.TrimPrefix(t, "uint")
+	case strings.HasPrefix(t, "int"):
+		bits = strings.TrimPrefix(t, "int")
+	default:
+		return false
+	}
+	n, err := strconv.Atoi(bits)
+	return err == nil && n >= 8 && n <= 256 && n%8 == 0
+}
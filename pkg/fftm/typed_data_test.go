@@ -0,0 +1,254 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallets", "type": "address[]"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person[2]"},
+			{"name": "contents", "type": "string"},
+			{"name": "ref", "type": "bytes32"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallets": ["0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"]},
+		"to": [{"name": "Bob", "wallets": []}, {"name": "Alice", "wallets": []}],
+		"contents": "Hello, Bob!",
+		"ref": "0x0000000000000000000000000000000000000000000000000000000000000001"
+	}
+}`
+
+type typedDataConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.TypedDataAPI
+}
+
+func newTestTypedDataManager(t *testing.T) (string, *manager, *ffcapimocks.TypedDataAPI, func()) {
+	url, m, done := newTestManager(t)
+	mtd := &ffcapimocks.TypedDataAPI{}
+	m.connector = &typedDataConnector{API: m.connector.(*ffcapimocks.API), TypedDataAPI: mtd}
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+	return url, m, mtd, done
+}
+
+func sampleTypedDataPayload(t *testing.T, typedData string) map[string]interface{} {
+	var td map[string]interface{}
+	err := json.Unmarshal([]byte(typedData), &td)
+	assert.NoError(t, err)
+	return map[string]interface{}{
+		"headers": map[string]interface{}{
+			"type": apitypes.RequestTypeSignTypedData,
+		},
+		"from":      "0xaaaaa",
+		"typedData": td,
+	}
+}
+
+func TestSignTypedDataOK(t *testing.T) {
+
+	url, _, mtd, done := newTestTypedDataManager(t)
+	defer done()
+
+	mtd.On("SignTypedData", mock.Anything, mock.MatchedBy(func(req *ffcapi.SignTypedDataRequest) bool {
+		return req.From == "0xaaaaa" && req.TypedData.PrimaryType == "Mail"
+	})).Return(&ffcapi.SignTypedDataResponse{
+		Hash:      "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2",
+		Signature: "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c",
+	}, ffcapi.ErrorReason(""), nil)
+
+	var signed apitypes.SignedTypedData
+	res, err := resty.New().R().
+		SetBody(sampleTypedDataPayload(t, sampleTypedData)).
+		SetHeader(apitypes.RequestIDHeader, "req1").
+		SetResult(&signed).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 202, res.StatusCode())
+	assert.NotEmpty(t, signed.ID)
+	assert.Equal(t, "req1", signed.RequestID)
+	assert.Equal(t, "0xaaaaa", signed.From)
+	assert.Equal(t, "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2", signed.Hash)
+	assert.Regexp(t, "^0x4355c47d", signed.Signature)
+	assert.Equal(t, "Mail", signed.TypedData.PrimaryType)
+	assert.NotNil(t, signed.Signed)
+
+	mtd.AssertExpectations(t)
+}
+
+func TestSignTypedDataMalformed(t *testing.T) {
+
+	url, _, mtd, done := newTestTypedDataManager(t)
+	defer done()
+
+	var errRes fftypes.RESTError
+	res, err := resty.New().R().
+		SetBody(sampleTypedDataPayload(t, `{
+			"types": {
+				"EIP712Domain": [{"name": "name", "type": "string"}],
+				"Mail": [{"name": "to", "type": "Person"}]
+			},
+			"primaryType": "Mail",
+			"domain": {"name": "Ether Mail"},
+			"message": {"to": {}}
+		}`)).
+		SetError(&errRes).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21136.*Person", errRes.Error)
+
+	mtd.AssertNotCalled(t, "SignTypedData", mock.Anything, mock.Anything)
+}
+
+func TestSignTypedDataBadRequest(t *testing.T) {
+
+	url, _, _, done := newTestTypedDataManager(t)
+	defer done()
+
+	var errRes fftypes.RESTError
+	res, err := resty.New().R().
+		SetBody(map[string]interface{}{
+			"headers": map[string]interface{}{
+				"type": apitypes.RequestTypeSignTypedData,
+			},
+			"typedData": "not an object",
+		}).
+		SetError(&errRes).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21022", errRes.Error)
+}
+
+func TestSignTypedDataSignerDenied(t *testing.T) {
+
+	url, m, _, done := newTestTypedDataManager(t)
+	defer done()
+	m.signersDeny = map[string]bool{"0xaaaaa": true}
+
+	res, err := resty.New().R().
+		SetBody(sampleTypedDataPayload(t, sampleTypedData)).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 403, res.StatusCode())
+}
+
+func TestSignTypedDataNotSupported(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	var req apitypes.TypedDataRequest
+	err := json.Unmarshal([]byte(fmt.Sprintf(`{"from":"0xaaaaa","typedData":%s}`, sampleTypedData)), &req)
+	assert.NoError(t, err)
+	_, err = m.signTypedData(context.Background(), &req)
+	assert.Regexp(t, "FF21135", err)
+}
+
+func TestSignTypedDataConnectorFail(t *testing.T) {
+
+	_, m, mtd, done := newTestTypedDataManager(t)
+	defer done()
+
+	mtd.On("SignTypedData", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	var req apitypes.TypedDataRequest
+	err := json.Unmarshal([]byte(fmt.Sprintf(`{"headers":{"id":"sig1"},"from":"0xaaaaa","typedData":%s}`, sampleTypedData)), &req)
+	assert.NoError(t, err)
+	_, err = m.signTypedData(context.Background(), &req)
+	assert.Regexp(t, "pop", err)
+}
+
+func TestSignTypedDataMissingFrom(t *testing.T) {
+
+	_, m, _, done := newTestTypedDataManager(t)
+	defer done()
+
+	_, err := m.signTypedData(context.Background(), &apitypes.TypedDataRequest{})
+	assert.Regexp(t, "FF21136.*from", err)
+}
+
+func TestValidateTypedDataErrors(t *testing.T) {
+
+	ctx := context.Background()
+	domain := `"EIP712Domain": [{"name": "name", "type": "string"}]`
+	for errMatch, typedData := range map[string]string{
+		"typedData is required":              ``,
+		"types must include 'EIP712Domain'":  `{"types": {}}`,
+		"invalid type name 'uint256'":        `{"types": {` + domain + `, "uint256": []}}`,
+		"field with no name in type 'Mail'":  `{"types": {` + domain + `, "Mail": [{"type": "string"}]}}`,
+		"duplicate field 'a' in type 'Mail'": `{"types": {` + domain + `, "Mail": [{"name": "a", "type": "string"}, {"name": "a", "type": "bool"}]}}`,
+		"unknown type 'uint7'":               `{"types": {` + domain + `, "Mail": [{"name": "a", "type": "uint7"}]}}`,
+		"unknown type 'bytes33\\[\\]'":       `{"types": {` + domain + `, "Mail": [{"name": "a", "type": "bytes33[]"}]}}`,
+		"unknown type 'int'":                 `{"types": {` + domain + `, "Mail": [{"name": "a", "type": "int"}]}}`,
+		"unknown type 'fixed128x18'":         `{"types": {` + domain + `, "Mail": [{"name": "a", "type": "fixed128x18"}]}}`,
+		"primaryType 'Other'":                `{"types": {` + domain + `, "Mail": []}, "primaryType": "Other"}`,
+		"primaryType 'EIP712Domain'":         `{"types": {` + domain + `}, "primaryType": "EIP712Domain"}`,
+		"domain must be an object":           `{"types": {` + domain + `, "Mail": []}, "primaryType": "Mail", "domain": []}`,
+		"message must be an object":          `{"types": {` + domain + `, "Mail": []}, "primaryType": "Mail", "domain": {}}`,
+		"message is missing field 'a'":       `{"types": {` + domain + `, "Mail": [{"name": "a", "type": "int8[][3]"}]}, "primaryType": "Mail", "domain": {}, "message": {}}`,
+	} {
+		var td *ffcapi.TypedData
+		if typedData != "" {
+			err := json.Unmarshal([]byte(typedData), &td)
+			assert.NoError(t, err)
+		}
+		err := validateTypedData(ctx, td)
+		assert.Regexp(t, "FF21136.*"+errMatch, err)
+	}
+
+	var td *ffcapi.TypedData
+	err := json.Unmarshal([]byte(sampleTypedData), &td)
+	assert.NoError(t, err)
+	assert.NoError(t, validateTypedData(ctx, td))
+}
@@ -0,0 +1,49 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasoracles
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracle"
+)
+
+// ConnectorFactory creates a gas oracle that uses the gas price estimate of the connector
+type ConnectorFactory struct{}
+
+func (f *ConnectorFactory) Name() string {
+	return "connector"
+}
+
+func (f *ConnectorFactory) InitConfig(conf config.Section) {}
+
+func (f *ConnectorFactory) NewGasOracle(ctx context.Context, conf config.Section) (gasoracle.GasOracle, error) {
+	return &connectorOracle{}, nil
+}
+
+type connectorOracle struct{}
+
+func (o *connectorOracle) GasPrice(ctx context.Context, cAPI ffcapi.API) (*fftypes.JSONAny, error) {
+	res, _, err := cAPI.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return res.GasPrice, nil
+}
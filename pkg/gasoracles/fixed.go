@@ -0,0 +1,61 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasoracles
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracle"
+)
+
+const FixedGasPrice = "fixedGasPrice" // treated as a raw JSON string, so can be numeric 123, or string "123", or object {"maxPriorityFeePerGas":123}
+
+// FixedFactory creates a gas oracle that always returns the configured gas price
+type FixedFactory struct{}
+
+func (f *FixedFactory) Name() string {
+	return "fixed"
+}
+
+func (f *FixedFactory) InitConfig(conf config.Section) {
+	conf.AddKnownKey(FixedGasPrice)
+}
+
+func (f *FixedFactory) NewGasOracle(ctx context.Context, conf config.Section) (gasoracle.GasOracle, error) {
+	return NewFixedGasOracle(ctx, fftypes.JSONAnyPtr(conf.GetString(FixedGasPrice)))
+}
+
+// NewFixedGasOracle returns a gas oracle for a fixed gas price supplied directly, rather than from config
+func NewFixedGasOracle(ctx context.Context, gasPrice *fftypes.JSONAny) (gasoracle.GasOracle, error) {
+	if gasPrice.IsNil() {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgNoGasConfigSetForPolicyEngine)
+	}
+	return &fixedOracle{gasPrice: gasPrice}, nil
+}
+
+type fixedOracle struct {
+	gasPrice *fftypes.JSONAny
+}
+
+func (o *fixedOracle) GasPrice(ctx context.Context, cAPI ffcapi.API) (*fftypes.JSONAny, error) {
+	return o.gasPrice, nil
+}
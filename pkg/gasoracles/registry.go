@@ -0,0 +1,64 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasoracles
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracle"
+)
+
+var gasOracles = make(map[string]Factory)
+
+func init() {
+	RegisterOracle(&ConnectorFactory{})
+	RegisterOracle(&RESTAPIFactory{})
+	RegisterOracle(&FixedFactory{})
+}
+
+// NewGasOracle creates the gas oracle registered with the name, using the gas oracle config section of a policy engine
+func NewGasOracle(ctx context.Context, conf config.Section, name string) (gasoracle.GasOracle, error) {
+	factory, ok := gasOracles[name]
+	if !ok {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgGasOracleNotRegistered, name)
+	}
+	return factory.NewGasOracle(ctx, conf)
+}
+
+type Factory interface {
+	Name() string
+	InitConfig(conf config.Section)
+	NewGasOracle(ctx context.Context, conf config.Section) (gasoracle.GasOracle, error)
+}
+
+// RegisterOracle adds a gas oracle that policy engines can select by name. Oracles must be registered
+// before the policy engines that use them, so their config is initialized in the section of each engine.
+func RegisterOracle(factory Factory) string {
+	name := factory.Name()
+	gasOracles[name] = factory
+	return name
+}
+
+// InitConfig initializes the config of every registered gas oracle, in the gas oracle config section of a policy engine
+func InitConfig(conf config.Section) {
+	for _, factory := range gasOracles {
+		factory.InitConfig(conf)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasoracles
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/gasoraclemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testOracleFactory struct {
+	oracle *gasoraclemocks.GasOracle
+}
+
+func (f *testOracleFactory) Name() string { return "test" }
+
+func (f *testOracleFactory) InitConfig(conf config.Section) {
+	conf.AddKnownKey("speed", "fast")
+}
+
+func (f *testOracleFactory) NewGasOracle(ctx context.Context, conf config.Section) (gasoracle.GasOracle, error) {
+	return f.oracle, nil
+}
+
+func newTestOracleConfig(t *testing.T) config.Section {
+	tmconfig.Reset()
+	conf := config.RootSection("unittest.gasoracle")
+	InitConfig(conf)
+	return conf
+}
+
+func TestRegistry(t *testing.T) {
+
+	f := &testOracleFactory{oracle: &gasoraclemocks.GasOracle{}}
+	assert.Equal(t, "test", RegisterOracle(f))
+	defer delete(gasOracles, "test")
+	conf := newTestOracleConfig(t)
+	assert.Equal(t, "fast", conf.GetString("speed"))
+
+	o, err := NewGasOracle(context.Background(), conf, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, f.oracle, o)
+
+	o, err = NewGasOracle(context.Background(), conf, "bob")
+	assert.Nil(t, o)
+	assert.Regexp(t, "FF21149", err)
+
+}
+
+func TestConnectorOracle(t *testing.T) {
+
+	conf := newTestOracleConfig(t)
+	o, err := NewGasOracle(context.Background(), conf, "connector")
+	assert.NoError(t, err)
+
+	mca := &ffcapimocks.API{}
+	mca.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`12345`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	gasPrice, err := o.GasPrice(context.Background(), mca)
+	assert.NoError(t, err)
+	assert.Equal(t, `12345`, gasPrice.String())
+
+	_, err = o.GasPrice(context.Background(), mca)
+	assert.Regexp(t, "pop", err)
+
+	mca.AssertExpectations(t)
+
+}
+
+func TestRESTAPIOracle(t *testing.T) {
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"standard": {"maxPriorityFee": 32, "maxFee": 33}}`))
+	}))
+	defer server.Close()
+
+	conf := newTestOracleConfig(t)
+	conf.Set(ffresty.HTTPConfigURL, fmt.Sprintf("http://%s", server.Listener.Addr()))
+	conf.Set(RESTAPIMethod, http.MethodPost)
+	conf.Set(RESTAPITemplate, `{"maxPriorityFeePerGas":{{ .standard.maxPriorityFee }},"maxFeePerGas":{{ .standard.maxFee }}}`)
+	o, err := NewGasOracle(context.Background(), conf, "restapi")
+	assert.NoError(t, err)
+
+	gasPrice, err := o.GasPrice(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"maxPriorityFeePerGas":32,"maxFeePerGas":33}`, gasPrice.String())
+
+	status = http.StatusInternalServerError
+	_, err = o.GasPrice(context.Background(), nil)
+	assert.Regexp(t, "FF21021.*500", err)
+
+}
+
+func TestRESTAPIOracleErrors(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	conf := newTestOracleConfig(t)
+	_, err := NewGasOracle(context.Background(), conf, "restapi")
+	assert.Regexp(t, "FF21024", err)
+
+	conf.Set(RESTAPITemplate, "{{ !!! wrong")
+	_, err = NewGasOracle(context.Background(), conf, "restapi")
+	assert.Regexp(t, "FF21025", err)
+
+	conf.Set(ffresty.HTTPConfigURL, fmt.Sprintf("http://%s", server.Listener.Addr()))
+	conf.Set(RESTAPITemplate, "{{ .wrong.thing | len }}")
+	o, err := NewGasOracle(context.Background(), conf, "restapi")
+	assert.NoError(t, err)
+	_, err = o.GasPrice(context.Background(), nil)
+	assert.Regexp(t, "FF21026", err)
+
+	server.Close()
+	_, err = o.GasPrice(context.Background(), nil)
+	assert.Regexp(t, "FF21021", err)
+
+}
+
+func TestFixedOracle(t *testing.T) {
+
+	conf := newTestOracleConfig(t)
+	_, err := NewGasOracle(context.Background(), conf, "fixed")
+	assert.Regexp(t, "FF21020", err)
+
+	conf.Set(FixedGasPrice, `{"gasPrice":"1000"}`)
+	o, err := NewGasOracle(context.Background(), conf, "fixed")
+	assert.NoError(t, err)
+	gasPrice, err := o.GasPrice(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"gasPrice":"1000"}`, gasPrice.String())
+
+}
@@ -0,0 +1,96 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasoracles
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"text/template"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracle"
+)
+
+const (
+	RESTAPIMethod   = "method"
+	RESTAPITemplate = "template"
+)
+
+const defaultRESTAPIMethod = http.MethodGet
+
+// RESTAPIFactory creates a gas oracle that calls an external gas station REST API, and uses a go template
+// to extract the gas price to pass to the connector from the JSON response
+type RESTAPIFactory struct{}
+
+func (f *RESTAPIFactory) Name() string {
+	return "restapi"
+}
+
+func (f *RESTAPIFactory) InitConfig(conf config.Section) {
+	ffresty.InitConfig(conf)
+	conf.AddKnownKey(RESTAPIMethod, defaultRESTAPIMethod)
+	conf.AddKnownKey(RESTAPITemplate)
+}
+
+func (f *RESTAPIFactory) NewGasOracle(ctx context.Context, conf config.Section) (gasoracle.GasOracle, error) {
+	templateString := conf.GetString(RESTAPITemplate)
+	if templateString == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgMissingGOTemplate)
+	}
+	t, err := template.New("").Parse(templateString)
+	if err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgBadGOTemplate, err)
+	}
+	return &restapiOracle{
+		client:   ffresty.New(ctx, conf),
+		method:   conf.GetString(RESTAPIMethod),
+		template: t,
+	}, nil
+}
+
+type restapiOracle struct {
+	client   *resty.Client
+	method   string
+	template *template.Template
+}
+
+func (o *restapiOracle) GasPrice(ctx context.Context, cAPI ffcapi.API) (*fftypes.JSONAny, error) {
+	var jsonResponse map[string]interface{}
+	res, err := o.client.R().
+		SetContext(ctx).
+		SetResult(&jsonResponse).
+		Execute(o.method, "")
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingGasOracleAPI, -1, err.Error())
+	}
+	if res.IsError() {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingGasOracleAPI, res.StatusCode(), res.RawResponse)
+	}
+	buff := new(bytes.Buffer)
+	err = o.template.Execute(buff, jsonResponse)
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgGasOracleResultError)
+	}
+	return fftypes.JSONAnyPtr(buff.String()), nil
+}
@@ -0,0 +1,38 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymanager
+
+import (
+	"context"
+)
+
+// KeyManager resolves the logical key names that a submission can reference in place of a signing address.
+// The connector signs each transaction with the resolved address, so a key manager that holds the keys
+// itself must make them available to the signer used by the connector under that address.
+type KeyManager interface {
+	// ResolveKey returns the signing address for a key name, or an error if the key name is unknown
+	ResolveKey(ctx context.Context, keyName string) (address string, err error)
+
+	// ListKeys returns the key names known to the key manager, with their signing addresses
+	ListKeys(ctx context.Context) ([]*KeyMapping, error)
+}
+
+// KeyMapping is a key name known to a key manager, and the signing address it resolves to
+type KeyMapping struct {
+	KeyName string `json:"keyName"`
+	Address string `json:"address"`
+}
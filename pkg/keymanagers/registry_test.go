@@ -0,0 +1,75 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keymanagers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/keymanager"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFactory struct{}
+
+type testKeyManager struct {
+	keys map[string]string
+}
+
+func (f *testFactory) Name() string {
+	return "test"
+}
+
+func (f *testFactory) InitConfig(conf config.Section) {
+	conf.AddKnownKey("address", "0x12345")
+}
+
+func (f *testFactory) NewKeyManager(ctx context.Context, conf config.Section) (keymanager.KeyManager, error) {
+	return &testKeyManager{keys: map[string]string{"key1": conf.GetString("address")}}, nil
+}
+
+func (km *testKeyManager) ResolveKey(ctx context.Context, keyName string) (string, error) {
+	address, ok := km.keys[keyName]
+	if !ok {
+		return "", fmt.Errorf("pop")
+	}
+	return address, nil
+}
+
+func (km *testKeyManager) ListKeys(ctx context.Context) ([]*keymanager.KeyMapping, error) {
+	return []*keymanager.KeyMapping{{KeyName: "key1", Address: km.keys["key1"]}}, nil
+}
+
+func TestRegistry(t *testing.T) {
+
+	tmconfig.Reset()
+	RegisterKeyManager(&testFactory{})
+
+	km, err := NewKeyManager(context.Background(), tmconfig.KeyManagerBaseConfig, "test")
+	assert.NoError(t, err)
+	address, err := km.ResolveKey(context.Background(), "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "0x12345", address)
+
+	km, err = NewKeyManager(context.Background(), tmconfig.KeyManagerBaseConfig, "bob")
+	assert.Nil(t, km)
+	assert.Regexp(t, "FF21193", err)
+
+}
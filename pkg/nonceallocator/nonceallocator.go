@@ -0,0 +1,29 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nonceallocator
+
+import (
+	"context"
+)
+
+// NonceAllocator determines the next nonce to assign to a newly prepared transaction for a signer.
+//
+// FFTM guarantees that only one call is in-flight for a given signer at any time, and that the
+// nonce returned is either spent (persisted on a transaction) or returned before the next call.
+type NonceAllocator interface {
+	NextNonce(ctx context.Context, signer string) (uint64, error)
+}
@@ -0,0 +1,51 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nonceallocators
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/nonceallocator"
+)
+
+var nonceAllocators = make(map[string]Factory)
+
+func NewNonceAllocator(ctx context.Context, baseConfig config.Section, name string, cAPI ffcapi.API) (nonceallocator.NonceAllocator, error) {
+	factory, ok := nonceAllocators[name]
+	if !ok {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgNonceAllocatorNotRegistered, name)
+	}
+	return factory.NewNonceAllocator(ctx, baseConfig.SubSection(name), cAPI)
+}
+
+type Factory interface {
+	Name() string
+	InitConfig(conf config.Section)
+	NewNonceAllocator(ctx context.Context, conf config.Section, cAPI ffcapi.API) (nonceallocator.NonceAllocator, error)
+}
+
+func RegisterAllocator(factory Factory) string {
+	name := factory.Name()
+	nonceAllocators[name] = factory
+	factory.InitConfig(tmconfig.NonceAllocatorBaseConfig.SubSection(name))
+	return name
+}
@@ -0,0 +1,67 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nonceallocators
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/nonceallocator"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFactory struct{}
+
+type testAllocator struct {
+	nonce uint64
+}
+
+func (f *testFactory) Name() string {
+	return "test"
+}
+
+func (f *testFactory) InitConfig(conf config.Section) {
+	conf.AddKnownKey("nonce", 12345)
+}
+
+func (f *testFactory) NewNonceAllocator(ctx context.Context, conf config.Section, cAPI ffcapi.API) (nonceallocator.NonceAllocator, error) {
+	return &testAllocator{nonce: uint64(conf.GetInt64("nonce"))}, nil
+}
+
+func (a *testAllocator) NextNonce(ctx context.Context, signer string) (uint64, error) {
+	return a.nonce, nil
+}
+
+func TestRegistry(t *testing.T) {
+
+	tmconfig.Reset()
+	RegisterAllocator(&testFactory{})
+
+	na, err := NewNonceAllocator(context.Background(), tmconfig.NonceAllocatorBaseConfig, "test", nil)
+	assert.NoError(t, err)
+	n, err := na.NextNonce(context.Background(), "0x12345")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12345), n)
+
+	na, err = NewNonceAllocator(context.Background(), tmconfig.NonceAllocatorBaseConfig, "bob", nil)
+	assert.Nil(t, na)
+	assert.Regexp(t, "FF21070", err)
+
+}
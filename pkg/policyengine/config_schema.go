@@ -0,0 +1,36 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyengine
+
+type ConfigKeyType string
+
+const (
+	ConfigTypeString   ConfigKeyType = "string"   // any scalar value, as it will be read as a string
+	ConfigTypeInteger  ConfigKeyType = "integer"  // a whole number, or a string containing one
+	ConfigTypeNumber   ConfigKeyType = "number"   // any number (of any size), or a string containing one
+	ConfigTypeBoolean  ConfigKeyType = "boolean"  // true/false, or a string containing one
+	ConfigTypeDuration ConfigKeyType = "duration" // a duration string such as "5m", or a number of nanoseconds
+	ConfigTypeAny      ConfigKeyType = "any"      // any value - keys nested below it are not validated, unless declared themselves
+)
+
+// ConfigKey declares a configuration key a policy engine expects, relative to the config section of the policy engine.
+// Keys in sub-sections are separated by ".", such as "gasOracle.mode"
+type ConfigKey struct {
+	Name     string
+	Type     ConfigKeyType
+	Required bool
+}
@@ -19,6 +19,7 @@ package policyengine
 import (
 	"context"
 
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
@@ -30,8 +31,24 @@ const (
 	UpdateNo     UpdateType = iota // Instructs that no update is necessary
 	UpdateYes                      // Instructs that the transaction should be updated in persistence
 	UpdateDelete                   // Instructs that the transaction should be removed completely from persistence - generally only returned when TX status is TxStatusDeleteRequested
+	UpdateFailed                   // Instructs that the transaction has failed without retry, with the returned error and reason - such as when a simulation before the first submission reverts
 )
 
 type PolicyEngine interface {
+	// Execute is called by FFTM to allow the policy engine to drive the transaction through to completion.
+	// A transaction with RawTransaction set was signed outside of FFTM, so must be submitted unchanged with ffcapi.RawTransactionAPI,
+	// as its nonce, gas and gas price cannot be re-derived.
+	// When Cancel is set on a submitted transaction, the policy engine should stop resubmitting it, and instead submit a no-op
+	// at the same nonce - recording the hash in Cancel.TransactionHash for FFTM to track against the original.
+	// The policy engine can also set Cancel itself, such as when it gives up on re-pricing a transaction that is not being mined.
+	// A transaction with Simulate set to true should be simulated with ffcapi.SimulationAPI before the first submission,
+	// if the connector supports it, returning UpdateFailed with the error if the simulation reverts.
 	Execute(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (updateType UpdateType, reason ffcapi.ErrorReason, err error)
 }
+
+// GasPriceEstimator is an optional interface a policy engine can implement, to return the gas price it would
+// use to submit the supplied transaction, without submitting it. If the policy engine does not implement it,
+// POST /transactions/estimate returns the gas price estimate of the connector.
+type GasPriceEstimator interface {
+	EstimateGasPrice(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (gasPrice *fftypes.JSONAny, err error)
+}
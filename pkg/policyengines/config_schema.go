@@ -0,0 +1,150 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyengines
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/spf13/viper"
+)
+
+// ConfigSchemaFactory is an optional interface a Factory can implement, to have the configuration of its
+// section checked before the policy engine is created. This reports unknown keys (such as typos), missing
+// required keys, and values of the wrong type, rather than the policy engine failing later (or ignoring them).
+// Every key in the schema (other than those nested under a policyengine.ConfigTypeAny key) must be registered in InitConfig.
+type ConfigSchemaFactory interface {
+	ConfigSchema() []*policyengine.ConfigKey
+}
+
+func validateConfig(ctx context.Context, name string, conf config.Section, schema []*policyengine.ConfigKey) error {
+	declared := make(map[string]*policyengine.ConfigKey, len(schema))
+	prefix := ""
+	for _, k := range schema {
+		declared[strings.ToLower(k.Name)] = k
+		if prefix == "" && k.Type != policyengine.ConfigTypeAny {
+			prefix = strings.ToLower(strings.TrimSuffix(conf.Resolve(k.Name), k.Name))
+		}
+	}
+	if prefix == "" {
+		return nil
+	}
+
+	var unknown, missing, invalid []string
+	for _, fullKey := range viper.AllKeys() {
+		if !strings.HasPrefix(fullKey, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(fullKey, prefix)
+		if !keyDeclared(declared, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	for _, k := range schema {
+		v := viper.Get(prefix + strings.ToLower(k.Name))
+		switch {
+		case v == nil || v == "":
+			if k.Required {
+				missing = append(missing, k.Name)
+			}
+		case !valueMatchesType(k.Type, v):
+			invalid = append(invalid, k.Name+" ("+string(k.Type)+")")
+		}
+	}
+
+	if len(unknown) > 0 || len(missing) > 0 || len(invalid) > 0 {
+		sort.Strings(unknown)
+		return i18n.NewError(ctx, tmmsgs.MsgPolicyEngineConfigInvalid, name, unknown, missing, invalid)
+	}
+	return nil
+}
+
+// keyDeclared checks whether a (lower case) key is declared in the schema, or is nested below a key of type any
+func keyDeclared(declared map[string]*policyengine.ConfigKey, key string) bool {
+	if _, ok := declared[key]; ok {
+		return true
+	}
+	for i := strings.LastIndex(key, "."); i > 0; i = strings.LastIndex(key, ".") {
+		key = key[0:i]
+		if k, ok := declared[key]; ok && k.Type == policyengine.ConfigTypeAny {
+			return true
+		}
+	}
+	return false
+}
+
+func valueMatchesType(t policyengine.ConfigKeyType, v interface{}) bool {
+	s, isString := v.(string)
+	switch t {
+	case policyengine.ConfigTypeString:
+		switch v.(type) {
+		case string, bool, int, int64, float64:
+			return true
+		}
+		return false
+	case policyengine.ConfigTypeInteger:
+		if isString {
+			_, err := strconv.ParseInt(s, 10, 64)
+			return err == nil
+		}
+		switch v.(type) {
+		case int, int64:
+			return true
+		}
+		return false
+	case policyengine.ConfigTypeNumber:
+		if isString {
+			_, ok := new(big.Float).SetString(s)
+			return ok
+		}
+		switch v.(type) {
+		case int, int64, float64:
+			return true
+		}
+		return false
+	case policyengine.ConfigTypeBoolean:
+		if isString {
+			_, err := strconv.ParseBool(s)
+			return err == nil
+		}
+		_, ok := v.(bool)
+		return ok
+	case policyengine.ConfigTypeDuration:
+		if isString {
+			if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return true
+			}
+			_, err := time.ParseDuration(s)
+			return err == nil
+		}
+		switch v.(type) {
+		case int, int64:
+			return true
+		}
+		return false
+	default:
+		return true
+	}
+}
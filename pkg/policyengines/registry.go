@@ -33,7 +33,13 @@ func NewPolicyEngine(ctx context.Context, baseConfig config.Section, name string
 	if !ok {
 		return nil, i18n.NewError(ctx, tmmsgs.MsgPolicyEngineNotRegistered, name)
 	}
-	return factory.NewPolicyEngine(ctx, baseConfig.SubSection(name))
+	conf := baseConfig.SubSection(name)
+	if sf, ok := factory.(ConfigSchemaFactory); ok {
+		if err := validateConfig(ctx, name, conf, sf.ConfigSchema()); err != nil {
+			return nil, err
+		}
+	}
+	return factory.NewPolicyEngine(ctx, conf)
 }
 
 type Factory interface {
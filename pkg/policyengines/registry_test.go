@@ -20,8 +20,12 @@ import (
 	"context"
 	"testing"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/simple"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,3 +44,128 @@ func TestRegistry(t *testing.T) {
 	assert.Regexp(t, "FF21019", err)
 
 }
+
+type testSchemaFactory struct {
+	schema []*policyengine.ConfigKey
+}
+
+func (f *testSchemaFactory) Name() string { return "test" }
+
+func (f *testSchemaFactory) InitConfig(conf config.Section) {
+	conf.AddKnownKey("endpoint")
+	conf.AddKnownKey("retries", 5)
+	conf.SubSection("tuning").AddKnownKey("enabled", false)
+}
+
+func (f *testSchemaFactory) NewPolicyEngine(ctx context.Context, conf config.Section) (policyengine.PolicyEngine, error) {
+	return &policyenginemocks.PolicyEngine{}, nil
+}
+
+func (f *testSchemaFactory) ConfigSchema() []*policyengine.ConfigKey {
+	return f.schema
+}
+
+func newTestSchemaFactory() *testSchemaFactory {
+	tmconfig.Reset()
+	f := &testSchemaFactory{
+		schema: []*policyengine.ConfigKey{
+			{Name: "endpoint", Type: policyengine.ConfigTypeString, Required: true},
+			{Name: "retries", Type: policyengine.ConfigTypeInteger},
+			{Name: "tuning.enabled", Type: policyengine.ConfigTypeBoolean},
+			{Name: "extras", Type: policyengine.ConfigTypeAny},
+		},
+	}
+	RegisterEngine(f)
+	return f
+}
+
+func TestConfigSchemaValid(t *testing.T) {
+
+	newTestSchemaFactory()
+	viper.Set("policyengine.test.endpoint", "http://localhost:12345")
+	viper.Set("policyengine.test.retries", "10")
+	viper.Set("policyengine.test.tuning.enabled", true)
+	viper.Set("policyengine.test.extras", map[string]interface{}{"anything": "goes"})
+
+	p, err := NewPolicyEngine(context.Background(), tmconfig.PolicyEngineBaseConfig, "test")
+	assert.NotNil(t, p)
+	assert.NoError(t, err)
+
+}
+
+func TestConfigSchemaUnknownKey(t *testing.T) {
+
+	newTestSchemaFactory()
+	viper.Set("policyengine.test.endpoint", "http://localhost:12345")
+	viper.Set("policyengine.test.retires", 10)
+	viper.Set("policyengine.test.tuning.enabeld", true)
+
+	_, err := NewPolicyEngine(context.Background(), tmconfig.PolicyEngineBaseConfig, "test")
+	assert.Regexp(t, `FF21115.*'test'.*unknown keys: \[retires tuning.enabeld\]`, err)
+
+}
+
+func TestConfigSchemaMissingRequiredKey(t *testing.T) {
+
+	newTestSchemaFactory()
+
+	_, err := NewPolicyEngine(context.Background(), tmconfig.PolicyEngineBaseConfig, "test")
+	assert.Regexp(t, `FF21115.*unknown keys: \[\].*missing required keys: \[endpoint\]`, err)
+
+}
+
+func TestConfigSchemaInvalidValues(t *testing.T) {
+
+	newTestSchemaFactory()
+	viper.Set("policyengine.test.endpoint", map[string]interface{}{"not": "a string"})
+	viper.Set("policyengine.test.retries", "many")
+	viper.Set("policyengine.test.tuning.enabled", "sometimes")
+
+	_, err := NewPolicyEngine(context.Background(), tmconfig.PolicyEngineBaseConfig, "test")
+	assert.Regexp(t, `FF21115.*invalid values: \[endpoint \(string\) retries \(integer\) tuning.enabled \(boolean\)\]`, err)
+
+}
+
+func TestConfigSchemaOnlyAnyKeys(t *testing.T) {
+
+	f := newTestSchemaFactory()
+	f.schema = []*policyengine.ConfigKey{{Name: "extras", Type: policyengine.ConfigTypeAny}}
+	viper.Set("policyengine.test.unknown", "ignored")
+
+	p, err := NewPolicyEngine(context.Background(), tmconfig.PolicyEngineBaseConfig, "test")
+	assert.NotNil(t, p)
+	assert.NoError(t, err)
+
+}
+
+func TestConfigSchemaSimpleTypo(t *testing.T) {
+
+	tmconfig.Reset()
+	RegisterEngine(&simple.PolicyEngineFactory{})
+	viper.Set("policyengine.simple.maxGasPrce", "1000")
+
+	_, err := NewPolicyEngine(context.Background(), tmconfig.PolicyEngineBaseConfig, "simple")
+	assert.Regexp(t, `FF21115.*unknown keys: \[maxgasprce\]`, err)
+
+}
+
+func TestConfigValueTypes(t *testing.T) {
+
+	assert.True(t, valueMatchesType(policyengine.ConfigTypeString, 12345))
+	assert.False(t, valueMatchesType(policyengine.ConfigTypeString, []string{}))
+	assert.True(t, valueMatchesType(policyengine.ConfigTypeInteger, int64(10)))
+	assert.False(t, valueMatchesType(policyengine.ConfigTypeInteger, 1.5))
+	assert.True(t, valueMatchesType(policyengine.ConfigTypeNumber, "100000000000000000000000"))
+	assert.True(t, valueMatchesType(policyengine.ConfigTypeNumber, 1.5))
+	assert.False(t, valueMatchesType(policyengine.ConfigTypeNumber, "lots"))
+	assert.False(t, valueMatchesType(policyengine.ConfigTypeNumber, true))
+	assert.True(t, valueMatchesType(policyengine.ConfigTypeBoolean, "true"))
+	assert.False(t, valueMatchesType(policyengine.ConfigTypeBoolean, 1))
+	assert.True(t, valueMatchesType(policyengine.ConfigTypeDuration, "5m"))
+	assert.True(t, valueMatchesType(policyengine.ConfigTypeDuration, "1000"))
+	assert.True(t, valueMatchesType(policyengine.ConfigTypeDuration, 1000))
+	assert.False(t, valueMatchesType(policyengine.ConfigTypeDuration, "soon"))
+	assert.False(t, valueMatchesType(policyengine.ConfigTypeDuration, 1.5))
+	assert.True(t, valueMatchesType(policyengine.ConfigTypeAny, []string{}))
+
+}
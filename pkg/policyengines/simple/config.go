@@ -17,44 +17,138 @@
 package simple
 
 import (
-	"net/http"
-
 	"github.com/hyperledger/firefly-common/pkg/config"
-	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/exchangerates"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracles"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
 )
 
 const (
-	FixedGasPrice          = "fixedGasPrice"    // when not using a gas station - will be treated as a raw JSON string, so can be numeric 123, or string "123", or object {"maxPriorityFeePerGas":123})
-	ResubmitInterval       = "resubmitInterval" // warnings will be written to the log at this interval if mining has not occurred, and the TX will be resubmitted
-	GasOracleConfig        = "gasOracle"
-	GasOracleMode          = "mode"
-	GasOracleMethod        = "method"
-	GasOracleTemplate      = "template"
-	GasOracleQueryInterval = "queryInterval"
+	FixedGasPrice           = "fixedGasPrice"    // when not using a gas station - will be treated as a raw JSON string, so can be numeric 123, or string "123", or object {"maxPriorityFeePerGas":123})
+	ResubmitInterval        = "resubmitInterval" // warnings will be written to the log at this interval if mining has not occurred, and the TX will be resubmitted
+	GasOracleConfig         = "gasOracle"
+	GasOracleMode           = "mode"
+	GasOracleMethod         = gasoracles.RESTAPIMethod
+	GasOracleTemplate       = gasoracles.RESTAPITemplate
+	GasOracleFixedGasPrice  = gasoracles.FixedGasPrice
+	GasOracleQueryInterval  = "queryInterval"
+	MaxGasPrice             = "maxGasPrice"          // a cap applied to a numeric gas price, or the gasPrice field of a gas price structure
+	MaxFeePerGas            = "maxFeePerGas"         // a cap applied to the maxFeePerGas field of an EIP-1559 gas price structure
+	MaxPriorityFeePerGas    = "maxPriorityFeePerGas" // a cap applied to the maxPriorityFeePerGas field of an EIP-1559 gas price structure
+	MinGasPrice             = "minGasPrice"          // a floor applied to a numeric gas price, or the gasPrice field of a gas price structure
+	MinReplacementBump      = "minReplacementBump"   // the minimum percentage each field must rise by when a resubmission changes the gas price
+	FeeType                 = "feeType"              // whether to send legacy or EIP-1559 fields, when the gas price has EIP-1559 fields
+	Simulate                = "simulate"             // whether to simulate transactions before the first submission, unless overridden on the transaction
+	EscalationConfig        = "escalation"
+	EscalationInterval      = "interval"       // the gas price of a transaction is bumped each time it has been pending this long without a receipt
+	EscalationPercentage    = "percentage"     // the percentage to bump the gas price by each interval
+	EscalationMaxBumps      = "maxBumps"       // the number of times the gas price of a transaction can be changed on resubmission
+	TerminalAction          = "terminalAction" // what to do once a transaction has reached the maximum number of bumps
+	FiatGasCapConfig        = "fiatGasCap"
+	FiatGasCapMaxCost       = "maxCost"      // the maximum fiat cost of the gas of a transaction, as a decimal string
+	FiatGasCapRateProvider  = "rateProvider" // the name of the registered exchange-rate provider for the native currency
+	FiatGasCapDecimals      = "decimals"     // the number of decimals of the native currency, that the gas price is denominated in
+	FiatGasCapQueryInterval = "queryInterval"
+	FiatGasCapFixedRate     = exchangerates.FixedRate
+	FiatGasCapMethod        = exchangerates.RESTAPIMethod
+	FiatGasCapTemplate      = exchangerates.RESTAPITemplate
 )
 
+// The gas oracle mode is the name of a registered gas oracle, or disabled to use the fixed gas price of the policy engine
 const (
 	GasOracleModeDisabled  = "disabled"
 	GasOracleModeRESTAPI   = "restapi"
 	GasOracleModeConnector = "connector"
+	GasOracleModeFixed     = "fixed"
+)
+
+// The terminal action is taken once a transaction has been bumped the maximum number of times
+const (
+	TerminalActionHold   = "hold"   // resubmit at the last gas price
+	TerminalActionCancel = "cancel" // cancel the transaction, by replacing it with a no-op at the same nonce
 )
 
 const (
-	defaultResubmitInterval       = "5m"
-	defaultGasOracleQueryInterval = "5m"
-	defaultGasOracleMethod        = http.MethodGet
-	defaultGasOracleMode          = GasOracleModeConnector
+	FeeTypeAuto    = "auto"    // EIP-1559 fields if the connector reports the eip1559 capability, otherwise legacy
+	FeeTypeLegacy  = "legacy"  // always a gasPrice field
+	FeeTypeEIP1559 = "eip1559" // always the maxFeePerGas and maxPriorityFeePerGas fields
+)
+
+const (
+	defaultResubmitInterval        = "5m"
+	defaultGasOracleQueryInterval  = "5m"
+	defaultGasOracleMode           = GasOracleModeConnector
+	defaultEscalationInterval      = "0" // disabled
+	defaultEscalationPercentage    = 10
+	defaultEscalationMaxBumps      = 0 // unlimited
+	defaultTerminalAction          = TerminalActionHold
+	defaultMinReplacementBump      = 10
+	defaultFiatGasCapRateProvider  = "fixed"
+	defaultFiatGasCapDecimals      = 18
+	defaultFiatGasCapQueryInterval = "5m"
 )
 
 func (f *PolicyEngineFactory) InitConfig(conf config.Section) {
 	conf.AddKnownKey(FixedGasPrice)
 	conf.AddKnownKey(ResubmitInterval, defaultResubmitInterval)
+	conf.AddKnownKey(MaxGasPrice)
+	conf.AddKnownKey(MaxFeePerGas)
+	conf.AddKnownKey(MaxPriorityFeePerGas)
+	conf.AddKnownKey(MinGasPrice)
+	conf.AddKnownKey(MinReplacementBump, defaultMinReplacementBump)
+	conf.AddKnownKey(FeeType)
+	conf.AddKnownKey(Simulate, false)
 
 	gasOracleConfig := conf.SubSection(GasOracleConfig)
-	ffresty.InitConfig(gasOracleConfig)
-	gasOracleConfig.AddKnownKey(GasOracleMethod, defaultGasOracleMethod)
+	gasoracles.InitConfig(gasOracleConfig)
 	gasOracleConfig.AddKnownKey(GasOracleMode, defaultGasOracleMode)
 	gasOracleConfig.AddKnownKey(GasOracleQueryInterval, defaultGasOracleQueryInterval)
-	gasOracleConfig.AddKnownKey(GasOracleTemplate)
 
+	escalationConfig := conf.SubSection(EscalationConfig)
+	escalationConfig.AddKnownKey(EscalationInterval, defaultEscalationInterval)
+	escalationConfig.AddKnownKey(EscalationPercentage, defaultEscalationPercentage)
+	escalationConfig.AddKnownKey(EscalationMaxBumps, defaultEscalationMaxBumps)
+	escalationConfig.AddKnownKey(TerminalAction, defaultTerminalAction)
+
+	fiatGasCapConfig := conf.SubSection(FiatGasCapConfig)
+	exchangerates.InitConfig(fiatGasCapConfig)
+	fiatGasCapConfig.AddKnownKey(FiatGasCapMaxCost)
+	fiatGasCapConfig.AddKnownKey(FiatGasCapRateProvider, defaultFiatGasCapRateProvider)
+	fiatGasCapConfig.AddKnownKey(FiatGasCapDecimals, defaultFiatGasCapDecimals)
+	fiatGasCapConfig.AddKnownKey(FiatGasCapQueryInterval, defaultFiatGasCapQueryInterval)
+
+}
+
+func (f *PolicyEngineFactory) ConfigSchema() []*policyengine.ConfigKey {
+	return []*policyengine.ConfigKey{
+		{Name: FixedGasPrice, Type: policyengine.ConfigTypeAny},
+		{Name: ResubmitInterval, Type: policyengine.ConfigTypeDuration},
+		{Name: MaxGasPrice, Type: policyengine.ConfigTypeNumber},
+		{Name: MaxFeePerGas, Type: policyengine.ConfigTypeNumber},
+		{Name: MaxPriorityFeePerGas, Type: policyengine.ConfigTypeNumber},
+		{Name: MinGasPrice, Type: policyengine.ConfigTypeNumber},
+		{Name: MinReplacementBump, Type: policyengine.ConfigTypeInteger},
+		{Name: FeeType, Type: policyengine.ConfigTypeString},
+		{Name: Simulate, Type: policyengine.ConfigTypeBoolean},
+		// The config of the gas oracle is not validated, other than the keys of the built-in oracles declared below
+		{Name: GasOracleConfig, Type: policyengine.ConfigTypeAny},
+		{Name: GasOracleConfig + "." + GasOracleFixedGasPrice, Type: policyengine.ConfigTypeAny},
+		{Name: GasOracleConfig + "." + GasOracleMethod, Type: policyengine.ConfigTypeString},
+		{Name: GasOracleConfig + "." + GasOracleMode, Type: policyengine.ConfigTypeString},
+		{Name: GasOracleConfig + "." + GasOracleQueryInterval, Type: policyengine.ConfigTypeDuration},
+		{Name: GasOracleConfig + "." + GasOracleTemplate, Type: policyengine.ConfigTypeString},
+		{Name: EscalationConfig + "." + EscalationInterval, Type: policyengine.ConfigTypeDuration},
+		{Name: EscalationConfig + "." + EscalationPercentage, Type: policyengine.ConfigTypeInteger},
+		{Name: EscalationConfig + "." + EscalationMaxBumps, Type: policyengine.ConfigTypeInteger},
+		{Name: EscalationConfig + "." + TerminalAction, Type: policyengine.ConfigTypeString},
+		// The config of the exchange-rate provider is not validated, other than the keys of the built-in providers declared below
+		{Name: FiatGasCapConfig, Type: policyengine.ConfigTypeAny},
+		{Name: FiatGasCapConfig + "." + FiatGasCapMaxCost, Type: policyengine.ConfigTypeNumber},
+		{Name: FiatGasCapConfig + "." + FiatGasCapRateProvider, Type: policyengine.ConfigTypeString},
+		{Name: FiatGasCapConfig + "." + FiatGasCapDecimals, Type: policyengine.ConfigTypeInteger},
+		{Name: FiatGasCapConfig + "." + FiatGasCapQueryInterval, Type: policyengine.ConfigTypeDuration},
+		{Name: FiatGasCapConfig + "." + FiatGasCapFixedRate, Type: policyengine.ConfigTypeNumber},
+		{Name: FiatGasCapConfig + "." + FiatGasCapMethod, Type: policyengine.ConfigTypeString},
+		{Name: FiatGasCapConfig + "." + FiatGasCapTemplate, Type: policyengine.ConfigTypeString},
+	}
 }
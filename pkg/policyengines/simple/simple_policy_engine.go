@@ -17,24 +17,31 @@
 package simple
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"text/template"
+	"math/big"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/hyperledger/firefly-common/pkg/config"
-	"github.com/hyperledger/firefly-common/pkg/ffresty"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/exchangerate"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/exchangerates"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracle"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracles"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
 )
 
+// defaultCancelBump is the percentage a cancellation is priced above the previous submission at the same nonce,
+// when neither a minimum replacement bump or an escalation percentage is configured
+const defaultCancelBump = 10
+
 type PolicyEngineFactory struct{}
 
 func (f *PolicyEngineFactory) Name() string {
@@ -47,32 +54,76 @@ func (f *PolicyEngineFactory) Name() string {
 // - It logs errors transactions breach certain configured thresholds of staleness
 func (f *PolicyEngineFactory) NewPolicyEngine(ctx context.Context, conf config.Section) (pe policyengine.PolicyEngine, err error) {
 	gasOracleConfig := conf.SubSection(GasOracleConfig)
+	escalationConfig := conf.SubSection(EscalationConfig)
+	fiatGasCapConfig := conf.SubSection(FiatGasCapConfig)
 	p := &simplePolicyEngine{
 		resubmitInterval: conf.GetDuration(ResubmitInterval),
 		fixedGasPrice:    fftypes.JSONAnyPtr(conf.GetString(FixedGasPrice)),
 
-		gasOracleMethod:        gasOracleConfig.GetString(GasOracleMethod),
 		gasOracleQueryInterval: gasOracleConfig.GetDuration(GasOracleQueryInterval),
-		gasOracleMode:          gasOracleConfig.GetString(GasOracleMode),
-	}
-	switch p.gasOracleMode {
-	case GasOracleModeConnector:
-		// No initialization required
-	case GasOracleModeRESTAPI:
-		p.gasOracleClient = ffresty.New(ctx, gasOracleConfig)
-		templateString := gasOracleConfig.GetString(GasOracleTemplate)
-		if templateString == "" {
-			return nil, i18n.NewError(ctx, tmmsgs.MsgMissingGOTemplate)
-		}
-		p.gasOracleTemplate, err = template.New("").Parse(templateString)
-		if err != nil {
-			return nil, i18n.NewError(ctx, tmmsgs.MsgBadGOTemplate, err)
-		}
+		gasPriceCaps:           make(map[string]*big.Int),
+		escalationInterval:     escalationConfig.GetDuration(EscalationInterval),
+		escalationPercentage:   escalationConfig.GetInt(EscalationPercentage),
+		maxGasBumps:            escalationConfig.GetInt(EscalationMaxBumps),
+		terminalAction:         escalationConfig.GetString(TerminalAction),
+		minReplacementBump:     conf.GetInt(MinReplacementBump),
+		feeType:                conf.GetString(FeeType),
+		simulate:               conf.GetBool(Simulate),
+	}
+	if p.escalationInterval > 0 && p.escalationPercentage <= 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidEscalationPercentage, p.escalationPercentage)
+	}
+	if p.maxGasBumps < 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidMaxGasBumps, p.maxGasBumps)
+	}
+	if p.terminalAction != TerminalActionHold && p.terminalAction != TerminalActionCancel {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidTerminalAction, p.terminalAction)
+	}
+	if p.minReplacementBump < 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidReplacementBump, p.minReplacementBump)
+	}
+	switch p.feeType {
+	case "", FeeTypeAuto, FeeTypeLegacy, FeeTypeEIP1559:
 	default:
-		if p.fixedGasPrice.IsNil() {
-			return nil, i18n.NewError(ctx, tmmsgs.MsgNoGasConfigSetForPolicyEngine)
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidFeeType, p.feeType)
+	}
+	if floorStr := conf.GetString(MinGasPrice); floorStr != "" {
+		gasPriceFloor, ok := new(big.Int).SetString(floorStr, 0)
+		if !ok {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidGasPriceFloor, floorStr, MinGasPrice)
+		}
+		p.gasPriceFloor = gasPriceFloor
+	}
+	for field, key := range map[string]string{
+		"":                     MaxGasPrice,
+		"gasPrice":             MaxGasPrice,
+		"maxFeePerGas":         MaxFeePerGas,
+		"maxPriorityFeePerGas": MaxPriorityFeePerGas,
+	} {
+		capStr := conf.GetString(key)
+		if capStr != "" {
+			gasPriceCap, ok := new(big.Int).SetString(capStr, 0)
+			if !ok {
+				return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidGasPriceCap, capStr, key)
+			}
+			p.gasPriceCaps[field] = gasPriceCap
 		}
 	}
+	if maxCostStr := fiatGasCapConfig.GetString(FiatGasCapMaxCost); maxCostStr != "" {
+		if err := p.initFiatGasCap(ctx, fiatGasCapConfig, maxCostStr); err != nil {
+			return nil, err
+		}
+	}
+	// Any registered gas oracle can be selected by name. The disabled mode predates the oracle registry,
+	// and uses the fixed gas price configured on the policy engine itself.
+	if mode := gasOracleConfig.GetString(GasOracleMode); mode == GasOracleModeDisabled {
+		p.gasOracle, err = gasoracles.NewFixedGasOracle(ctx, p.fixedGasPrice)
+	} else {
+		p.gasOracle, err = gasoracles.NewGasOracle(ctx, gasOracleConfig, mode)
+	}
+	if err != nil {
+		return nil, err
+	}
 	return p, nil
 }
 
@@ -80,17 +131,44 @@ type simplePolicyEngine struct {
 	fixedGasPrice    *fftypes.JSONAny
 	resubmitInterval time.Duration
 
-	gasOracleMode          string
-	gasOracleClient        *resty.Client
-	gasOracleMethod        string
-	gasOracleTemplate      *template.Template
+	gasOracle              gasoracle.GasOracle
 	gasOracleQueryInterval time.Duration
+	gasOracleMux           sync.Mutex // the policy loop can execute transactions for different signers concurrently
 	gasOracleQueryValue    *fftypes.JSONAny
 	gasOracleLastQueryTime *fftypes.FFTime
+	gasPriceCaps           map[string]*big.Int // keyed by gas price field, with "" for a single numeric value
+	escalationInterval     time.Duration
+	escalationPercentage   int
+	maxGasBumps            int      // 0 for no limit
+	terminalAction         string   // what to do once a transaction has reached the maximum gas bumps
+	gasPriceFloor          *big.Int // applied to a single numeric value, or the gasPrice field
+	minReplacementBump     int
+	feeType                string     // empty if the gas price is passed to the connector with the fields the oracle returned
+	feeTypeMux             sync.Mutex // protects the fee type resolved from the connector capabilities
+	resolvedFeeType        string     // cached once the connector has been queried, as FFTM creates separate engines for each connector
+	simulate               bool
+	simulationMux          sync.Mutex // protects the simulation support resolved from the connector capabilities
+	simulationSupported    *bool      // cached once the connector has been queried
+	fiatGasCap             *big.Rat   // the maximum fiat cost of the gas of a transaction, or nil if not configured
+	nativeUnit             *big.Int   // the number of base units the gas price is denominated in, per whole unit of the native currency
+	rateProvider           exchangerate.RateProvider
+	rateQueryInterval      time.Duration
+	rateMux                sync.Mutex
+	rateQueryValue         *big.Rat
+	rateLastQueryTime      *fftypes.FFTime
+}
+
+// fiatGasPriceCap is the gas price ceiling for a transaction, derived from the fiat gas cap at an exchange rate
+type fiatGasPriceCap struct {
+	ceiling *big.Int
+	rate    *big.Rat
 }
 
 type simplePolicyInfo struct {
-	LastWarnTime *fftypes.FFTime `json:"lastWarnTime"`
+	LastWarnTime       *fftypes.FFTime   `json:"lastWarnTime"`
+	LastEscalationTime *fftypes.FFTime   `json:"lastEscalationTime,omitempty"`
+	GasBumps           int               `json:"gasBumps,omitempty"`     // the number of resubmissions that changed the gas price
+	GasPriceCaps       map[string]string `json:"gasPriceCaps,omitempty"` // the cap last recorded in the error history for each gas price field that was limited
 }
 
 // withPolicyInfo is a convenience helper to run some logic that accesses/updates our policy section
@@ -111,16 +189,91 @@ func (p *simplePolicyEngine) withPolicyInfo(ctx context.Context, mtx *apitypes.M
 	return update, reason, err
 }
 
-func (p *simplePolicyEngine) submitTX(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (reason ffcapi.ErrorReason, err error) {
+// sendTX sends the transaction to the connector. A raw transaction that was signed outside of FFTM is sent
+// unchanged, as the nonce, gas and gas price cannot be changed without invalidating the signature.
+func (p *simplePolicyEngine) sendTX(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	if mtx.RawTransaction != "" {
+		rawAPI, ok := cAPI.(ffcapi.RawTransactionAPI)
+		if !ok {
+			return nil, "", i18n.NewError(ctx, tmmsgs.MsgRawTransactionsNotSupported)
+		}
+		log.L(ctx).Debugf("Sending raw transaction %s at nonce %s / %d (lastSubmit=%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.LastSubmit)
+		return rawAPI.TransactionSendRaw(ctx, &ffcapi.TransactionSendRawRequest{
+			RawTransaction: mtx.RawTransaction,
+		})
+	}
 	sendTX := &ffcapi.TransactionSendRequest{
 		TransactionHeaders: mtx.TransactionHeaders,
 		GasPrice:           mtx.GasPrice,
 		TransactionData:    mtx.TransactionData,
+		DeployContract:     mtx.DeployContract,
 	}
 	sendTX.TransactionHeaders.Nonce = (*fftypes.FFBigInt)(mtx.Nonce.Int())
 	sendTX.TransactionHeaders.Gas = (*fftypes.FFBigInt)(mtx.Gas.Int())
 	log.L(ctx).Debugf("Sending transaction %s at nonce %s / %d (lastSubmit=%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.LastSubmit)
-	res, reason, err := cAPI.TransactionSend(ctx, sendTX)
+	return cAPI.TransactionSend(ctx, sendTX)
+}
+
+// shouldSimulate returns whether the transaction should be simulated before its first submission. A raw transaction
+// is never simulated, as it is submitted unchanged whatever the outcome.
+func (p *simplePolicyEngine) shouldSimulate(mtx *apitypes.ManagedTX) bool {
+	switch {
+	case mtx.RawTransaction != "":
+		return false
+	case mtx.Simulate != nil:
+		return *mtx.Simulate
+	default:
+		return p.simulate
+	}
+}
+
+// simulateTX executes the transaction against the current state of the chain, if the connector supports it.
+// A simulation that reverts is returned as an error with the ErrorReasonTransactionReverted reason.
+func (p *simplePolicyEngine) simulateTX(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (ffcapi.ErrorReason, error) {
+	simulationAPI, ok := cAPI.(ffcapi.SimulationAPI)
+	if !ok || !p.resolveSimulationSupported(ctx, cAPI) {
+		log.L(ctx).Debugf("Skipping simulation of transaction %s, as the connector does not support it", mtx.ID)
+		return "", nil
+	}
+	simulateTX := &ffcapi.TransactionSimulateRequest{
+		TransactionHeaders: mtx.TransactionHeaders,
+		GasPrice:           mtx.GasPrice,
+		TransactionData:    mtx.TransactionData,
+		DeployContract:     mtx.DeployContract,
+	}
+	simulateTX.TransactionHeaders.Nonce = (*fftypes.FFBigInt)(mtx.Nonce.Int())
+	simulateTX.TransactionHeaders.Gas = (*fftypes.FFBigInt)(mtx.Gas.Int())
+	log.L(ctx).Debugf("Simulating transaction %s at nonce %s / %d", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64())
+	_, reason, err := simulationAPI.TransactionSimulate(ctx, simulateTX)
+	if err != nil {
+		log.L(ctx).Warnf("Simulation of transaction %s at nonce %s / %d failed reason=%s: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), reason, err)
+	}
+	return reason, err
+}
+
+// resolveSimulationSupported determines from the capabilities reported by the connector whether it can simulate
+// transactions. Simulation is skipped if the connector cannot be queried, which is retried on the next call.
+func (p *simplePolicyEngine) resolveSimulationSupported(ctx context.Context, cAPI ffcapi.API) bool {
+	p.simulationMux.Lock()
+	defer p.simulationMux.Unlock()
+	if p.simulationSupported != nil {
+		return *p.simulationSupported
+	}
+	info, _, err := cAPI.ConnectorInfo(ctx, &ffcapi.ConnectorInfoRequest{})
+	if err != nil {
+		log.L(ctx).Warnf("Failed to query connector capabilities to determine simulation support, skipping simulation: %s", err)
+		return false
+	}
+	supported := info.Supports(ffcapi.CapabilitySimulation)
+	p.simulationSupported = &supported
+	if !supported {
+		log.L(ctx).Infof("Transactions will not be simulated, as connector '%s' does not support simulation", info.Name)
+	}
+	return supported
+}
+
+func (p *simplePolicyEngine) submitTX(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (reason ffcapi.ErrorReason, err error) {
+	res, reason, err := p.sendTX(ctx, cAPI, mtx)
 	if err == nil {
 		mtx.TransactionHash = res.TransactionHash
 		mtx.LastSubmit = fftypes.Now()
@@ -131,6 +284,10 @@ func (p *simplePolicyEngine) submitTX(ctx context.Context, cAPI ffcapi.API, mtx
 			// If we already have a transaction hash, this is fine - we just return as if we submitted it
 			if mtx.TransactionHash != "" {
 				log.L(ctx).Debugf("Transaction %s at nonce %s / %d known with hash: %s (%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.TransactionHash, err)
+				// A raw transaction might have been broadcast before it was handed to us, in which case this is our first submission
+				if mtx.LastSubmit == nil {
+					mtx.LastSubmit = fftypes.Now()
+				}
 				return "", nil
 			}
 			// Note: to cover the edge case where we had a timeout or other failure during the initial TransactionSend,
@@ -155,17 +312,41 @@ func (p *simplePolicyEngine) Execute(ctx context.Context, cAPI ffcapi.API, mtx *
 
 	// Simple policy engine only submits once.
 	if mtx.FirstSubmit == nil {
-		// Only calculate gas price here in the simple policy engine
-		mtx.GasPrice, err = p.getGasPrice(ctx, cAPI)
-		if err != nil {
-			return policyengine.UpdateNo, "", err
-		}
-		// Submit the first time
-		if reason, err := p.submitTX(ctx, cAPI, mtx); err != nil {
-			return policyengine.UpdateYes, reason, err
+		return p.withPolicyInfo(ctx, mtx, func(info *simplePolicyInfo) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+			// Calculate the initial gas price, unless the transaction was signed with its own
+			if mtx.RawTransaction == "" {
+				gasPrice, err := p.initialGasPrice(ctx, cAPI, mtx, info)
+				if err != nil {
+					return policyengine.UpdateNo, "", err
+				}
+				mtx.GasPrice = gasPrice
+			}
+			// Check the transaction will not revert, before we spend gas discovering it does
+			if p.shouldSimulate(mtx) {
+				if reason, err := p.simulateTX(ctx, cAPI, mtx); err != nil {
+					if reason == ffcapi.ErrorReasonTransactionReverted {
+						return policyengine.UpdateFailed, reason, i18n.NewError(ctx, tmmsgs.MsgSimulationReverted, err)
+					}
+					return policyengine.UpdateNo, reason, err
+				}
+			}
+			// Submit the first time
+			if reason, err := p.submitTX(ctx, cAPI, mtx); err != nil {
+				return policyengine.UpdateYes, reason, err
+			}
+			mtx.FirstSubmit = mtx.LastSubmit
+			return policyengine.UpdateYes, "", nil
+		})
+
+	} else if mtx.Receipt == nil && mtx.Cancel != nil {
+
+		// Once cancellation is requested we stop resubmitting the original, and drive the no-op replacement instead
+		if mtx.Cancel.Receipt != nil {
+			return policyengine.UpdateNo, "", nil
 		}
-		mtx.FirstSubmit = mtx.LastSubmit
-		return policyengine.UpdateYes, "", nil
+		return p.withPolicyInfo(ctx, mtx, func(info *simplePolicyInfo) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+			return p.submitCancel(ctx, cAPI, mtx, info)
+		})
 
 	} else if mtx.Receipt == nil {
 
@@ -178,10 +359,23 @@ func (p *simplePolicyEngine) Execute(ctx context.Context, cAPI ffcapi.API, mtx *
 				lastWarnTime = mtx.FirstSubmit
 			}
 			now := fftypes.Now()
-			if now.Time().Sub(*lastWarnTime.Time()) > p.resubmitInterval {
+			bumpsExhausted := p.maxGasBumps > 0 && info.GasBumps >= p.maxGasBumps
+			escalate := mtx.RawTransaction == "" && !bumpsExhausted && p.escalationDue(mtx, info, now)
+			if escalate || now.Time().Sub(*lastWarnTime.Time()) > p.resubmitInterval {
 				secsSinceSubmit := float64(now.Time().Sub(*mtx.FirstSubmit.Time())) / float64(time.Second)
 				log.L(ctx).Infof("Transaction %s at nonce %s / %d has not been mined after %.2fs", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), secsSinceSubmit)
 				info.LastWarnTime = now
+				if bumpsExhausted && p.terminalAction == TerminalActionCancel {
+					// We drive the cancellation just as if it had been requested, replacing the transaction with a no-op
+					log.L(ctx).Warnf("Transaction %s at nonce %s / %d has not been mined after %d gas price bumps - cancelling", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), info.GasBumps)
+					mtx.Cancel = &apitypes.ManagedTXCancel{Requested: now}
+					return p.submitCancel(ctx, cAPI, mtx, info)
+				}
+				// A transaction signed outside of FFTM can only be resubmitted unchanged, and once the maximum
+				// number of bumps is reached we hold the transaction at the last gas price
+				if mtx.RawTransaction == "" && !bumpsExhausted {
+					p.refreshGasPrice(ctx, cAPI, mtx, info, now, escalate)
+				}
 				// We do a resubmit at this point - as it might no longer be in the TX pool
 				if reason, err := p.submitTX(ctx, cAPI, mtx); err != nil {
 					if reason != ffcapi.ErrorKnownTransaction {
@@ -198,52 +392,482 @@ func (p *simplePolicyEngine) Execute(ctx context.Context, cAPI ffcapi.API, mtx *
 	return policyengine.UpdateNo, "", nil
 }
 
-// getGasPrice either uses a fixed gas price, or invokes a gas station API
-func (p *simplePolicyEngine) getGasPrice(ctx context.Context, cAPI ffcapi.API) (gasPrice *fftypes.JSONAny, err error) {
-	if p.gasOracleQueryValue != nil && p.gasOracleLastQueryTime != nil &&
-		time.Since(*p.gasOracleLastQueryTime.Time()) < p.gasOracleQueryInterval {
-		return p.gasOracleQueryValue, nil
+// submitCancel sends a no-op transaction from the signer to itself at the nonce of the transaction being cancelled,
+// priced above the last submission so the node accepts it as a replacement. Each resubmission bumps the price
+// again, up to any configured caps.
+func (p *simplePolicyEngine) submitCancel(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX, info *simplePolicyInfo) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+	cancel := mtx.Cancel
+	if cancel.LastSubmit != nil && time.Since(*cancel.LastSubmit.Time()) <= p.resubmitInterval {
+		return policyengine.UpdateNo, "", nil
 	}
-	switch p.gasOracleMode {
-	case GasOracleModeRESTAPI:
-		// Make a REST call against an endpoint, and extract a value/structure to pass to the connector
-		gasPrice, err := p.getGasPriceAPI(ctx)
-		if err != nil {
-			return nil, err
+	previous := cancel.GasPrice
+	if previous == nil {
+		previous = mtx.GasPrice
+	}
+	bump := p.minReplacementBump
+	if bump <= 0 {
+		bump = p.escalationPercentage
+	}
+	if bump <= 0 {
+		bump = defaultCancelBump
+	}
+	gasPrice := bumpGasPrice(previous, bump)
+	if oracle, err := p.getGasPrice(ctx, cAPI); err != nil {
+		log.L(ctx).Warnf("Failed to refresh gas price for cancellation of transaction %s, bumping previous gas price: %s", mtx.ID, err)
+	} else if gasPrice == nil {
+		gasPrice = oracle
+	} else {
+		gasPrice = higherGasPrice(gasPrice, oracle)
+	}
+	fiatCap, err := p.fiatGasPriceCap(ctx, mtx)
+	if err != nil {
+		return policyengine.UpdateNo, "", err
+	}
+	gasPrice = p.applyGasPriceCaps(ctx, mtx, info, p.applyGasPriceFloor(gasPrice), fiatCap)
+
+	log.L(ctx).Debugf("Sending cancellation of transaction %s at nonce %s / %d (lastSubmit=%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), cancel.LastSubmit)
+	res, reason, err := cAPI.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  mtx.TransactionHeaders.From,
+			To:    mtx.TransactionHeaders.From,
+			Nonce: (*fftypes.FFBigInt)(mtx.Nonce.Int()),
+			Gas:   (*fftypes.FFBigInt)(mtx.Gas.Int()),
+		},
+		GasPrice: gasPrice,
+	})
+	if err != nil {
+		if reason != ffcapi.ErrorKnownTransaction || cancel.TransactionHash == "" {
+			return policyengine.UpdateYes, reason, err
 		}
-		p.gasOracleQueryValue = gasPrice
-		p.gasOracleLastQueryTime = fftypes.Now()
-		return p.gasOracleQueryValue, nil
-	case GasOracleModeConnector:
-		// Call the connector
-		res, _, err := cAPI.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+		log.L(ctx).Debugf("Cancellation of transaction %s at nonce %s / %d known with hash: %s (%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), cancel.TransactionHash, err)
+	} else {
+		cancel.TransactionHash = res.TransactionHash
+		cancel.GasPrice = gasPrice
+	}
+	cancel.LastSubmit = fftypes.Now()
+	if cancel.FirstSubmit == nil {
+		cancel.FirstSubmit = cancel.LastSubmit
+	}
+	log.L(ctx).Infof("Cancellation of transaction %s at nonce %s / %d submitted. Hash: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), cancel.TransactionHash)
+	return policyengine.UpdateYes, "", nil
+}
+
+// refreshGasPrice updates the gas price of a transaction that is about to be resubmitted.
+// The gas price is only bumped when escalation is due. Otherwise the transaction is resubmitted at its
+// previous gas price, limited to any configured caps - as the fiat ceiling can move with the exchange rate.
+func (p *simplePolicyEngine) refreshGasPrice(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX, info *simplePolicyInfo, now *fftypes.FFTime, escalate bool) {
+	if !escalate {
+		if fiatCap, err := p.fiatGasPriceCap(ctx, mtx); err != nil {
+			log.L(ctx).Warnf("Failed to calculate the fiat gas cap for transaction %s, resubmitting with previous gas price: %s", mtx.ID, err)
+		} else {
+			mtx.GasPrice = p.applyGasPriceCaps(ctx, mtx, info, mtx.GasPrice, fiatCap)
+		}
+		return
+	}
+	// Escalation does not depend on the oracle, so a congested network where the oracle price
+	// is flat still results in a bump. We use whichever is higher of the two.
+	// Once the cap is reached we stop bumping, and resubmit at the capped price.
+	gasPrice, err := p.getGasPrice(ctx, cAPI)
+	info.LastEscalationTime = now
+	if bumped := bumpGasPrice(mtx.GasPrice, p.escalationPercentage); bumped != nil {
+		log.L(ctx).Infof("Escalating gas price of transaction %s by %d%%", mtx.ID, p.escalationPercentage)
 		if err != nil {
-			return nil, err
+			log.L(ctx).Warnf("Failed to refresh gas price for transaction %s, escalating previous gas price: %s", mtx.ID, err)
+			gasPrice, err = bumped, nil
+		} else {
+			gasPrice = higherGasPrice(bumped, gasPrice)
 		}
-		p.gasOracleQueryValue = res.GasPrice
-		p.gasOracleLastQueryTime = fftypes.Now()
-		return p.gasOracleQueryValue, nil
-	default:
-		// Disabled - just a fixed value
-		return p.fixedGasPrice, nil
 	}
+	if err != nil {
+		log.L(ctx).Warnf("Failed to refresh gas price for transaction %s, resubmitting with previous gas price: %s", mtx.ID, err)
+	} else if fiatCap, err := p.fiatGasPriceCap(ctx, mtx); err != nil {
+		log.L(ctx).Warnf("Failed to calculate the fiat gas cap for transaction %s, resubmitting with previous gas price: %s", mtx.ID, err)
+	} else {
+		previous := mtx.GasPrice.String()
+		gasPrice = p.replacementGasPrice(ctx, mtx, p.applyGasPriceFloor(gasPrice))
+		mtx.GasPrice = p.applyGasPriceCaps(ctx, mtx, info, gasPrice, fiatCap)
+		if mtx.GasPrice.String() != previous {
+			info.GasBumps++
+			if p.maxGasBumps > 0 && info.GasBumps >= p.maxGasBumps {
+				log.L(ctx).Infof("Transaction %s has reached the maximum of %d gas price bumps", mtx.ID, p.maxGasBumps)
+			}
+		}
+	}
+}
+
+// escalationDue returns true if the transaction has been pending for the escalation interval,
+// since it was first submitted or last escalated
+func (p *simplePolicyEngine) escalationDue(mtx *apitypes.ManagedTX, info *simplePolicyInfo, now *fftypes.FFTime) bool {
+	if p.escalationInterval <= 0 {
+		return false
+	}
+	since := info.LastEscalationTime
+	if since == nil {
+		since = mtx.FirstSubmit
+	}
+	return now.Time().Sub(*since.Time()) > p.escalationInterval
+}
+
+// mapGasPrice calls fn for each numeric value in a gas price, which can be a single value or a structure of fields,
+// replacing the value with the one returned if it is non-nil. Returns nil if the gas price cannot be parsed.
+func mapGasPrice(gasPrice *fftypes.JSONAny, fn func(field string, value *big.Int) *big.Int) *fftypes.JSONAny {
+	if gasPrice == nil {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(gasPrice.Bytes(), &fields); err != nil {
+		var value fftypes.FFBigInt
+		if err := json.Unmarshal(gasPrice.Bytes(), &value); err != nil {
+			return nil
+		}
+		if newValue := fn("", value.Int()); newValue != nil {
+			return fftypes.JSONAnyPtr(newValue.String())
+		}
+		return gasPrice
+	}
+	changed := false
+	for field, valueBytes := range fields {
+		var value fftypes.FFBigInt
+		if field == "" || json.Unmarshal(valueBytes, &value) != nil {
+			continue
+		}
+		if newValue := fn(field, value.Int()); newValue != nil {
+			fields[field], _ = json.Marshal(newValue.String())
+			changed = true
+		}
+	}
+	if !changed {
+		return gasPrice
+	}
+	newBytes, _ := json.Marshal(fields)
+	return fftypes.JSONAnyPtrBytes(newBytes)
+}
+
+// bumpGasPrice increases every numeric field of a gas price by the percentage, rounding up so that
+// even small values are increased. This covers both legacy and EIP-1559 gas price structures.
+func bumpGasPrice(gasPrice *fftypes.JSONAny, percentage int) *fftypes.JSONAny {
+	return mapGasPrice(gasPrice, func(_ string, value *big.Int) *big.Int {
+		bumped := new(big.Int).Mul(value, big.NewInt(int64(100+percentage)))
+		bumped.Add(bumped, big.NewInt(99))
+		return bumped.Div(bumped, big.NewInt(100))
+	})
+}
+
+// higherGasPrice returns the bumped gas price, with any field where the oracle price is higher replaced
+// by the oracle price
+func higherGasPrice(bumped, oracle *fftypes.JSONAny) *fftypes.JSONAny {
+	oracleValues := make(map[string]*big.Int)
+	mapGasPrice(oracle, func(field string, value *big.Int) *big.Int {
+		oracleValues[field] = value
+		return nil
+	})
+	return mapGasPrice(bumped, func(field string, value *big.Int) *big.Int {
+		if oracleValue := oracleValues[field]; oracleValue != nil && oracleValue.Cmp(value) > 0 {
+			return oracleValue
+		}
+		return nil
+	})
+}
+
+// replacementGasPrice ensures a resubmission that changes the gas price will be accepted by the node as a
+// replacement of the previous submission. Nodes reject a replacement unless every field of the gas price has
+// risen by a minimum percentage, so any rise below that is increased to the minimum. A gas price that has not
+// risen at all is ignored, and the transaction is resubmitted with the previous gas price.
+func (p *simplePolicyEngine) replacementGasPrice(ctx context.Context, mtx *apitypes.ManagedTX, gasPrice *fftypes.JSONAny) *fftypes.JSONAny {
+	if p.minReplacementBump <= 0 || mtx.GasPrice == nil || gasPrice == nil {
+		return gasPrice
+	}
+	previousValues := make(map[string]*big.Int)
+	if mapGasPrice(mtx.GasPrice, func(field string, value *big.Int) *big.Int {
+		previousValues[field] = value
+		return nil
+	}) == nil {
+		return gasPrice
+	}
+	raised, sameFields := false, true
+	if mapGasPrice(gasPrice, func(field string, value *big.Int) *big.Int {
+		previousValue := previousValues[field]
+		if previousValue == nil {
+			sameFields = false
+		} else if value.Cmp(previousValue) > 0 {
+			raised = true
+		}
+		return nil
+	}) == nil || !sameFields {
+		// We cannot compare a gas price that has changed structure with the previous one
+		return gasPrice
+	}
+	if !raised {
+		return mtx.GasPrice
+	}
+	minimum := bumpGasPrice(mtx.GasPrice, p.minReplacementBump)
+	replacement := higherGasPrice(minimum, gasPrice)
+	if replacement.String() != gasPrice.String() {
+		log.L(ctx).Infof("Raising gas price of transaction %s from %s to %s, to meet the minimum replacement bump of %d%%", mtx.ID, gasPrice, replacement, p.minReplacementBump)
+	}
+	return replacement
 }
 
-func (p *simplePolicyEngine) getGasPriceAPI(ctx context.Context) (gasPrice *fftypes.JSONAny, err error) {
-	var jsonResponse map[string]interface{}
-	res, err := p.gasOracleClient.R().
-		SetResult(&jsonResponse).
-		Execute(p.gasOracleMethod, "")
+// applyGasPriceFloor raises a single numeric value, or the gasPrice field of a structure, to the configured floor
+func (p *simplePolicyEngine) applyGasPriceFloor(gasPrice *fftypes.JSONAny) *fftypes.JSONAny {
+	if p.gasPriceFloor == nil {
+		return gasPrice
+	}
+	floored := mapGasPrice(gasPrice, func(field string, value *big.Int) *big.Int {
+		if (field == "" || field == "gasPrice") && value.Cmp(p.gasPriceFloor) < 0 {
+			return p.gasPriceFloor
+		}
+		return nil
+	})
+	if floored == nil {
+		return gasPrice
+	}
+	return floored
+}
+
+// EstimateGasPrice returns the capped gas price that would be used for the initial submission of the transaction.
+// Nothing is recorded in the error history of the transaction if the gas price is capped.
+func (p *simplePolicyEngine) EstimateGasPrice(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (*fftypes.JSONAny, error) {
+	return p.initialGasPrice(ctx, cAPI, mtx, nil)
+}
+
+// initialGasPrice calculates the capped gas price for the first submission of the transaction
+func (p *simplePolicyEngine) initialGasPrice(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX, info *simplePolicyInfo) (*fftypes.JSONAny, error) {
+	gasPrice, err := p.getGasPrice(ctx, cAPI)
+	if err != nil {
+		return nil, err
+	}
+	fiatCap, err := p.fiatGasPriceCap(ctx, mtx)
 	if err != nil {
-		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingGasOracleAPI, -1, err.Error())
+		return nil, err
+	}
+	return p.applyGasPriceCaps(ctx, mtx, info, p.applyGasPriceFloor(gasPrice), fiatCap), nil
+}
+
+// applyGasPriceCaps limits a gas price, which can be a single value or a structure of fields, to the configured caps,
+// and to the ceiling derived from the fiat gas cap if one is supplied.
+// A warning is recorded in the error history of the transaction when a field is first limited, or the cap it is
+// limited to changes - such as when the fiat ceiling moves with the exchange rate. Nothing is recorded if info is nil.
+func (p *simplePolicyEngine) applyGasPriceCaps(ctx context.Context, mtx *apitypes.ManagedTX, info *simplePolicyInfo, gasPrice *fftypes.JSONAny, fiatCap *fiatGasPriceCap) *fftypes.JSONAny {
+	if (len(p.gasPriceCaps) == 0 && fiatCap == nil) || gasPrice == nil {
+		return gasPrice
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(gasPrice.Bytes(), &fields); err != nil {
+		// Not a structure, so check it as a single value
+		var value fftypes.FFBigInt
+		if err := json.Unmarshal(gasPrice.Bytes(), &value); err != nil {
+			log.L(ctx).Warnf("Unable to check gas price '%s' against configured caps: %s", gasPrice, err)
+			return gasPrice
+		}
+		if capped := p.checkGasPriceCap(ctx, mtx, info, "", value.Int(), fiatCap); capped != nil {
+			return fftypes.JSONAnyPtr(capped.String())
+		}
+		return gasPrice
+	}
+	changed := false
+	for field, valueBytes := range fields {
+		var value fftypes.FFBigInt
+		if field == "" || json.Unmarshal(valueBytes, &value) != nil {
+			continue
+		}
+		if capped := p.checkGasPriceCap(ctx, mtx, info, field, value.Int(), fiatCap); capped != nil {
+			fields[field], _ = json.Marshal(capped.String())
+			changed = true
+		}
+	}
+	if !changed {
+		return gasPrice
+	}
+	cappedBytes, _ := json.Marshal(fields)
+	return fftypes.JSONAnyPtrBytes(cappedBytes)
+}
+
+// checkGasPriceCap returns the lowest cap the value exceeds, or nil if the value can be used as-is
+func (p *simplePolicyEngine) checkGasPriceCap(ctx context.Context, mtx *apitypes.ManagedTX, info *simplePolicyInfo, field string, value *big.Int, fiatCap *fiatGasPriceCap) *big.Int {
+	fieldName := field
+	if fieldName == "" {
+		fieldName = "gasPrice"
+	}
+	var warning error
+	gasPriceCap := p.gasPriceCaps[field]
+	if gasPriceCap != nil && value.Cmp(gasPriceCap) > 0 {
+		warning = i18n.NewError(ctx, tmmsgs.MsgGasPriceCapReached, fieldName, value.String(), gasPriceCap.String())
+	} else {
+		gasPriceCap = nil
 	}
-	if res.IsError() {
-		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgErrorQueryingGasOracleAPI, res.StatusCode(), res.RawResponse)
+	// The fiat ceiling applies to every field that is a price per unit of gas, as none can usefully exceed the maximum fee
+	if fiatCap != nil && fiatCappedFields[field] && value.Cmp(fiatCap.ceiling) > 0 &&
+		(gasPriceCap == nil || fiatCap.ceiling.Cmp(gasPriceCap) < 0) {
+		gasPriceCap = fiatCap.ceiling
+		warning = i18n.NewError(ctx, tmmsgs.MsgFiatGasCapReached, fieldName, value.String(), gasPriceCap.String(), formatDecimal(p.fiatGasCap), formatDecimal(fiatCap.rate))
+	}
+	if gasPriceCap == nil {
+		return nil
+	}
+	if info == nil || info.GasPriceCaps[fieldName] == gasPriceCap.String() {
+		log.L(ctx).Debugf("Transaction %s: %s", mtx.ID, warning)
+		return gasPriceCap
+	}
+	log.L(ctx).Warnf("Transaction %s: %s", mtx.ID, warning)
+	mtx.ErrorHistory = append([]*apitypes.ManagedTXError{{
+		Time:  fftypes.Now(),
+		Error: warning.Error(),
+	}}, mtx.ErrorHistory...)
+	if info.GasPriceCaps == nil {
+		info.GasPriceCaps = make(map[string]string)
+	}
+	info.GasPriceCaps[fieldName] = gasPriceCap.String()
+	return gasPriceCap
+}
+
+var fiatCappedFields = map[string]bool{
+	"":                     true,
+	"gasPrice":             true,
+	"maxFeePerGas":         true,
+	"maxPriorityFeePerGas": true,
+}
+
+// initFiatGasCap configures a cap on the gas price expressed as a fiat amount, which is converted into a gas price
+// ceiling for each transaction using the exchange rate of the native currency and the gas of the transaction
+func (p *simplePolicyEngine) initFiatGasCap(ctx context.Context, conf config.Section, maxCostStr string) (err error) {
+	maxCost, ok := new(big.Rat).SetString(maxCostStr)
+	if !ok || maxCost.Sign() <= 0 {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidFiatGasCap, maxCostStr, FiatGasCapMaxCost)
+	}
+	decimals := conf.GetInt(FiatGasCapDecimals)
+	if decimals < 0 {
+		return i18n.NewError(ctx, tmmsgs.MsgInvalidFiatGasCap, conf.GetString(FiatGasCapDecimals), FiatGasCapDecimals)
+	}
+	p.fiatGasCap = maxCost
+	p.nativeUnit = new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	p.rateQueryInterval = conf.GetDuration(FiatGasCapQueryInterval)
+	p.rateProvider, err = exchangerates.NewRateProvider(ctx, conf, conf.GetString(FiatGasCapRateProvider))
+	return err
+}
+
+// fiatGasPriceCap returns the highest gas price at which the gas of the transaction costs no more than the
+// fiat gas cap, at the current exchange rate. This is calculated on each cycle, so the ceiling tracks the rate.
+// Returns nil if no fiat gas cap is configured.
+func (p *simplePolicyEngine) fiatGasPriceCap(ctx context.Context, mtx *apitypes.ManagedTX) (*fiatGasPriceCap, error) {
+	if p.fiatGasCap == nil {
+		return nil, nil
+	}
+	if mtx.Gas == nil || mtx.Gas.Int().Sign() <= 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgFiatGasCapNoGas, mtx.ID)
+	}
+	rate, err := p.queryExchangeRate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// ceiling = maxCost * nativeUnit / (rate * gas)
+	ceiling := new(big.Rat).Mul(p.fiatGasCap, new(big.Rat).SetInt(p.nativeUnit))
+	ceiling.Quo(ceiling, new(big.Rat).Mul(rate, new(big.Rat).SetInt(mtx.Gas.Int())))
+	return &fiatGasPriceCap{
+		ceiling: new(big.Int).Quo(ceiling.Num(), ceiling.Denom()),
+		rate:    rate,
+	}, nil
+}
+
+func (p *simplePolicyEngine) queryExchangeRate(ctx context.Context) (*big.Rat, error) {
+	p.rateMux.Lock()
+	defer p.rateMux.Unlock()
+	if p.rateQueryValue != nil && p.rateLastQueryTime != nil &&
+		time.Since(*p.rateLastQueryTime.Time()) < p.rateQueryInterval {
+		return p.rateQueryValue, nil
+	}
+	rate, err := p.rateProvider.Rate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.rateQueryValue = rate
+	p.rateLastQueryTime = fftypes.Now()
+	return p.rateQueryValue, nil
+}
+
+// formatDecimal formats a fiat amount or exchange rate for display, without trailing zeros
+func formatDecimal(r *big.Rat) string {
+	s := r.FloatString(18)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// getGasPrice either uses a fixed gas price, or invokes a gas station API
+func (p *simplePolicyEngine) getGasPrice(ctx context.Context, cAPI ffcapi.API) (*fftypes.JSONAny, error) {
+	gasPrice, err := p.queryGasPrice(ctx, cAPI)
+	if err != nil {
+		return nil, err
+	}
+	return p.applyFeeType(ctx, cAPI, gasPrice), nil
+}
+
+// applyFeeType chooses between the legacy and EIP-1559 fields of a gas price that has EIP-1559 fields, so the
+// same oracle can be used across chains that do and do not support EIP-1559. If only EIP-1559 fields are
+// available for a legacy chain, the maxFeePerGas is used as the gasPrice. A legacy gas price is always sent
+// as-is, as it is explicitly a legacy gas price, and legacy transactions are accepted on EIP-1559 chains.
+func (p *simplePolicyEngine) applyFeeType(ctx context.Context, cAPI ffcapi.API, gasPrice *fftypes.JSONAny) *fftypes.JSONAny {
+	if p.feeType == "" || gasPrice == nil {
+		return gasPrice
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(gasPrice.Bytes(), &fields); err != nil {
+		return gasPrice
+	}
+	maxFeePerGas, hasEIP1559 := fields["maxFeePerGas"]
+	legacyGasPrice, hasLegacy := fields["gasPrice"]
+	if !hasEIP1559 {
+		return gasPrice
+	}
+	if p.resolveFeeType(ctx, cAPI) == FeeTypeEIP1559 {
+		if !hasLegacy {
+			return gasPrice
+		}
+		delete(fields, "gasPrice")
+	} else {
+		if !hasLegacy {
+			legacyGasPrice = maxFeePerGas
+		}
+		fields = map[string]json.RawMessage{"gasPrice": legacyGasPrice}
+	}
+	newBytes, _ := json.Marshal(fields)
+	return fftypes.JSONAnyPtrBytes(newBytes)
+}
+
+// resolveFeeType returns the configured fee type, or in auto mode determines it from the capabilities reported
+// by the connector. Legacy is used if the connector cannot be queried, which is retried on the next call.
+func (p *simplePolicyEngine) resolveFeeType(ctx context.Context, cAPI ffcapi.API) string {
+	if p.feeType != FeeTypeAuto {
+		return p.feeType
+	}
+	p.feeTypeMux.Lock()
+	defer p.feeTypeMux.Unlock()
+	if p.resolvedFeeType != "" {
+		return p.resolvedFeeType
+	}
+	info, _, err := cAPI.ConnectorInfo(ctx, &ffcapi.ConnectorInfoRequest{})
+	if err != nil {
+		log.L(ctx).Warnf("Failed to query connector capabilities to determine the fee type, using legacy: %s", err)
+		return FeeTypeLegacy
+	}
+	p.resolvedFeeType = FeeTypeLegacy
+	if info.Supports(ffcapi.CapabilityEIP1559) {
+		p.resolvedFeeType = FeeTypeEIP1559
+	}
+	log.L(ctx).Infof("Using fee type '%s' based on the capabilities of connector '%s'", p.resolvedFeeType, info.Name)
+	return p.resolvedFeeType
+}
+
+func (p *simplePolicyEngine) queryGasPrice(ctx context.Context, cAPI ffcapi.API) (gasPrice *fftypes.JSONAny, err error) {
+	p.gasOracleMux.Lock()
+	defer p.gasOracleMux.Unlock()
+	if p.gasOracleQueryValue != nil && p.gasOracleLastQueryTime != nil &&
+		time.Since(*p.gasOracleLastQueryTime.Time()) < p.gasOracleQueryInterval {
+		return p.gasOracleQueryValue, nil
 	}
-	buff := new(bytes.Buffer)
-	err = p.gasOracleTemplate.Execute(buff, jsonResponse)
+	gasPrice, err = p.gasOracle.GasPrice(ctx, cAPI)
 	if err != nil {
-		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgGasOracleResultError)
+		return nil, err
 	}
-	return fftypes.JSONAnyPtr(buff.String()), nil
+	p.gasOracleQueryValue = gasPrice
+	p.gasOracleLastQueryTime = fftypes.Now()
+	return p.gasOracleQueryValue, nil
 }
@@ -18,7 +18,9 @@ package simple
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -28,9 +30,15 @@ import (
 	"github.com/hyperledger/firefly-common/pkg/ffresty"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/exchangeratemocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/gasoraclemocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/exchangerate"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/exchangerates"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracle"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/gasoracles"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -95,6 +103,36 @@ func TestFixedGasPriceOK(t *testing.T) {
 	mockFFCAPI.AssertExpectations(t)
 }
 
+func TestSendDeployContract(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, "12345")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_BYTECODE",
+		DeployContract:  true,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.DeployContract && req.To == "" && req.TransactionData == "SOME_BYTECODE"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	updated, _, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
 func TestGasOracleSendOK(t *testing.T) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -378,6 +416,7 @@ func TestTXSendFail(t *testing.T) {
 func TestWarnStaleWarningCannotParse(t *testing.T) {
 	f, conf := newTestPolicyEngineFactory(t)
 	conf.Set(FixedGasPrice, `12345`)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
 	p, err := f.NewPolicyEngine(context.Background(), conf)
 	assert.NoError(t, err)
 
@@ -435,6 +474,7 @@ func TestKnownTransactionHashKnown(t *testing.T) {
 func TestWarnStaleAdditionalWarningResubmitFail(t *testing.T) {
 	f, conf := newTestPolicyEngineFactory(t)
 	conf.Set(FixedGasPrice, `12345`)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
 	p, err := f.NewPolicyEngine(context.Background(), conf)
 	assert.NoError(t, err)
 
@@ -543,3 +583,1526 @@ func TestAllowsDeleteRequest(t *testing.T) {
 
 	mockFFCAPI.AssertExpectations(t)
 }
+
+func TestBadGasPriceCap(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(MaxFeePerGas, "lots")
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21071.*maxFeePerGas", err)
+}
+
+func TestConnectorGasOracleCappedAndStopsBumping(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.SubSection(GasOracleConfig).Set(GasOracleQueryInterval, "0s")
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 10)
+	conf.Set(MaxGasPrice, "20000")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`"15000"`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`"0x4e21"`), // 20001
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`50000`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	var submitted []string
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		submitted = append(submitted, args[1].(*ffcapi.TransactionSendRequest).GasPrice.String())
+	}).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	// First submission is below the cap
+	ctx := context.Background()
+	updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Empty(t, mtx.ErrorHistory)
+
+	// Escalations bump the gas price up to the cap, but never beyond it.
+	// The warning is only recorded the first time the cap is reached.
+	for i := 0; i < 2; i++ {
+		advanceEscalationTime(mtx, 100*time.Hour)
+		updated, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+		assert.NoError(t, err)
+		assert.Equal(t, policyengine.UpdateYes, updated)
+	}
+	assert.Equal(t, []string{`"15000"`, `20000`, `20000`}, submitted)
+	assert.Len(t, mtx.ErrorHistory, 1)
+	assert.Regexp(t, "FF21072.*gasPrice.*20001.*20000", mtx.ErrorHistory[0].Error)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestGasOracleEIP1559Capped(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"fast": {
+			  "maxPriorityFee":"3000000000",
+			  "maxFee":"90000000000"
+			}
+		  }`))
+	}))
+	defer server.Close()
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeRESTAPI)
+	conf.SubSection(GasOracleConfig).Set(ffresty.HTTPConfigURL, fmt.Sprintf("http://%s", server.Listener.Addr()))
+	conf.SubSection(GasOracleConfig).Set(GasOracleTemplate, `{"maxPriorityFeePerGas":"{{ .fast.maxPriorityFee }}","maxFeePerGas":"{{ .fast.maxFee }}","other":true}`)
+	conf.Set(MaxFeePerGas, "50000000000")
+	conf.Set(MaxPriorityFeePerGas, "0x77359400") // 2000000000
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.JSONObject().GetString("maxFeePerGas") == "50000000000" &&
+			req.GasPrice.JSONObject().GetString("maxPriorityFeePerGas") == "2000000000" &&
+			req.GasPrice.JSONObject().GetBool("other")
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Len(t, mtx.ErrorHistory, 2)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestGasPriceCapNotApplicable(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(MaxGasPrice, "100")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+	pe := p.(*simplePolicyEngine)
+
+	mtx := &apitypes.ManagedTX{}
+	ctx := context.Background()
+	assert.Nil(t, pe.applyGasPriceCaps(ctx, mtx, &simplePolicyInfo{}, nil, nil))
+	assert.Equal(t, `true`, pe.applyGasPriceCaps(ctx, mtx, &simplePolicyInfo{}, fftypes.JSONAnyPtr(`true`), nil).String())
+	assert.Equal(t, `{"unit":"gwei","value":99}`, pe.applyGasPriceCaps(ctx, mtx, &simplePolicyInfo{}, fftypes.JSONAnyPtr(`{"unit":"gwei","value":99}`), nil).String())
+	assert.Equal(t, `"99"`, pe.applyGasPriceCaps(ctx, mtx, &simplePolicyInfo{}, fftypes.JSONAnyPtr(`"99"`), nil).String())
+	assert.Empty(t, mtx.ErrorHistory)
+}
+
+func TestResubmitWithoutEscalationKeepsGasPrice(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.SubSection(GasOracleConfig).Set(GasOracleQueryInterval, "0s")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		FirstSubmit:     &submitTime,
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+	}
+
+	// With no escalation and no caps, the resubmission uses the previous gas price without querying the oracle
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == `12345`
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, `12345`, mtx.GasPrice.String())
+	assert.Equal(t, 0, getGasBumps(t, mtx))
+
+	mockFFCAPI.AssertExpectations(t)
+	mockFFCAPI.AssertNotCalled(t, "GasPriceEstimate", mock.Anything, mock.Anything)
+}
+
+func TestEstimateGasPriceCapped(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.Set(MaxGasPrice, "20000")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`50000`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	// Estimation does not submit anything
+	mtx := &apitypes.ManagedTX{}
+	gasPrice, err := p.(policyengine.GasPriceEstimator).EstimateGasPrice(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, `20000`, gasPrice.String())
+	assert.Nil(t, mtx.FirstSubmit)
+	assert.Empty(t, mtx.ErrorHistory)
+	assert.Nil(t, mtx.PolicyInfo)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestEstimateGasPriceFail(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	_, err = p.(policyengine.GasPriceEstimator).EstimateGasPrice(context.Background(), mockFFCAPI, &apitypes.ManagedTX{})
+	assert.Regexp(t, "pop", err)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func newEscalationTestTX(gasPrice string, lastEscalation time.Duration) *apitypes.ManagedTX {
+	firstSubmit := fftypes.FFTime(time.Now().Add(-1 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		FirstSubmit:     &firstSubmit,
+		GasPrice:        fftypes.JSONAnyPtr(gasPrice),
+	}
+	advanceEscalationTime(mtx, lastEscalation)
+	return mtx
+}
+
+// advanceEscalationTime simulates time passing since the last warning and escalation
+func advanceEscalationTime(mtx *apitypes.ManagedTX, elapsed time.Duration) {
+	last := fftypes.FFTime(time.Now().Add(-elapsed))
+	var info simplePolicyInfo
+	_ = json.Unmarshal(mtx.PolicyInfo.Bytes(), &info)
+	info.LastWarnTime, info.LastEscalationTime = &last, &last
+	infoBytes, _ := json.Marshal(&info)
+	mtx.PolicyInfo = fftypes.JSONAnyPtrBytes(infoBytes)
+}
+
+func getGasBumps(t *testing.T, mtx *apitypes.ManagedTX) int {
+	var info simplePolicyInfo
+	err := json.Unmarshal(mtx.PolicyInfo.Bytes(), &info)
+	assert.NoError(t, err)
+	return info.GasBumps
+}
+
+func TestEscalationBumpsGasPriceOverTime(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `100`)
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 10)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newEscalationTestTX(`100`, 2*time.Minute)
+
+	var submitted []string
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		submitted = append(submitted, args[1].(*ffcapi.TransactionSendRequest).GasPrice.String())
+	}).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+		assert.NoError(t, err)
+		assert.Equal(t, policyengine.UpdateYes, updated)
+
+		// No further bump until the interval passes again
+		updated, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+		assert.NoError(t, err)
+		assert.Equal(t, policyengine.UpdateNo, updated)
+
+		advanceEscalationTime(mtx, 2*time.Minute)
+	}
+	// Each bump is 10% of the previous price, rounded up, despite the fixed price not changing
+	assert.Equal(t, []string{`110`, `121`, `134`}, submitted)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestEscalationEIP1559RespectsCaps(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `{"maxFeePerGas":"1000","maxPriorityFeePerGas":"100"}`)
+	conf.Set(MaxFeePerGas, "1150")
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 10)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newEscalationTestTX(`{"maxFeePerGas":"1000","maxPriorityFeePerGas":"100"}`, 2*time.Minute)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	_, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, "1100", mtx.GasPrice.JSONObject().GetString("maxFeePerGas"))
+	assert.Equal(t, "110", mtx.GasPrice.JSONObject().GetString("maxPriorityFeePerGas"))
+	assert.Empty(t, mtx.ErrorHistory)
+
+	advanceEscalationTime(mtx, 2*time.Minute)
+	_, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, "1150", mtx.GasPrice.JSONObject().GetString("maxFeePerGas"))
+	assert.Equal(t, "121", mtx.GasPrice.JSONObject().GetString("maxPriorityFeePerGas"))
+	assert.Len(t, mtx.ErrorHistory, 1)
+	assert.Regexp(t, "FF21072.*maxFeePerGas.*1210.*1150", mtx.ErrorHistory[0].Error)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestEscalationUsesHigherOfOracleAndBump(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.SubSection(GasOracleConfig).Set(GasOracleQueryInterval, "0s")
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 10)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newEscalationTestTX(`100`, 2*time.Minute)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`150`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`100`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	var submitted []string
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		submitted = append(submitted, args[1].(*ffcapi.TransactionSendRequest).GasPrice.String())
+	}).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+		assert.NoError(t, err)
+		advanceEscalationTime(mtx, 2*time.Minute)
+	}
+	// The oracle wins when it has risen faster, then the bump wins when the oracle is flat,
+	// and the bump continues if the oracle is unavailable
+	assert.Equal(t, []string{`150`, `165`, `182`}, submitted)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestEscalationGasPriceRefreshFail(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	// The previous gas price cannot be bumped, and the oracle fails, so it is resubmitted unchanged
+	mtx := newEscalationTestTX(`true`, 2*time.Minute)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == `true`
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	updated, _, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, 0, getGasBumps(t, mtx))
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestEscalationMaxBumpsHold(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `100`)
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 10)
+	conf.SubSection(EscalationConfig).Set(EscalationMaxBumps, 2)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newEscalationTestTX(`100`, 2*time.Minute)
+
+	var submitted []string
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		submitted = append(submitted, args[1].(*ffcapi.TransactionSendRequest).GasPrice.String())
+	}).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+		assert.NoError(t, err)
+		assert.Equal(t, policyengine.UpdateYes, updated)
+		assert.Equal(t, i+1, getGasBumps(t, mtx))
+		advanceEscalationTime(mtx, 2*time.Minute)
+	}
+
+	// Once the maximum is reached there is no escalation, even though it is due
+	updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	// The transaction is still resubmitted at the last gas price
+	advanceEscalationTime(mtx, 10*time.Minute)
+	updated, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, []string{`110`, `121`, `121`}, submitted)
+	assert.Equal(t, 2, getGasBumps(t, mtx))
+	assert.Nil(t, mtx.Cancel)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestEscalationMaxBumpsCancel(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `100`)
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 10)
+	conf.SubSection(EscalationConfig).Set(EscalationMaxBumps, 1)
+	conf.SubSection(EscalationConfig).Set(TerminalAction, TerminalActionCancel)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newEscalationTestTX(`100`, 2*time.Minute)
+	mtx.Nonce = fftypes.NewFFBigInt(12)
+	mtx.Gas = fftypes.NewFFBigInt(50000)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.To == "" && req.GasPrice.String() == `110`
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.To == "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712" && req.Nonce.Int64() == 12 && req.GasPrice.String() == `121`
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0xcancel",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, 1, getGasBumps(t, mtx))
+	assert.Nil(t, mtx.Cancel)
+
+	// The next time the transaction is due to be resubmitted, it is cancelled instead
+	advanceEscalationTime(mtx, 10*time.Minute)
+	updated, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.NotNil(t, mtx.Cancel)
+	assert.NotNil(t, mtx.Cancel.Requested)
+	assert.Equal(t, "0xcancel", mtx.Cancel.TransactionHash)
+	assert.Equal(t, `110`, mtx.GasPrice.String())
+	assert.Equal(t, 1, getGasBumps(t, mtx))
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestEscalationMaxBumpsBadConfig(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(EscalationConfig).Set(EscalationMaxBumps, -1)
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21188", err)
+
+	f, conf = newTestPolicyEngineFactory(t)
+	conf.SubSection(EscalationConfig).Set(TerminalAction, "wrong")
+	_, err = f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21189.*wrong", err)
+}
+
+func TestEscalationBadPercentage(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 0)
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21095", err)
+}
+
+func TestBumpGasPriceUnparsable(t *testing.T) {
+	assert.Nil(t, bumpGasPrice(nil, 10))
+	assert.Nil(t, bumpGasPrice(fftypes.JSONAnyPtr(`true`), 10))
+	assert.Equal(t, `{"unit":"gwei"}`, bumpGasPrice(fftypes.JSONAnyPtr(`{"unit":"gwei"}`), 10).String())
+	assert.Equal(t, `2`, bumpGasPrice(fftypes.JSONAnyPtr(`"1"`), 10).String())
+	assert.Equal(t, `110`, higherGasPrice(fftypes.JSONAnyPtr(`110`), fftypes.JSONAnyPtr(`{"gasPrice":"200"}`)).String())
+}
+
+func resubmitWithOraclePrice(t *testing.T, p policyengine.PolicyEngine, previous, oracle string) *apitypes.ManagedTX {
+	mtx := newEscalationTestTX(previous, 100*time.Hour)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(oracle),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	updated, _, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	mockFFCAPI.AssertExpectations(t)
+	return mtx
+}
+
+func newReplacementTestPolicyEngine(t *testing.T, minBump int) policyengine.PolicyEngine {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.SubSection(GasOracleConfig).Set(GasOracleQueryInterval, "0s")
+	conf.Set(MinReplacementBump, minBump)
+	// Escalate by a small percentage, so the oracle price is the higher of the two when it rises
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 1)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+	return p
+}
+
+func TestReplacementTinyOracleRiseMeetsMinimumBump(t *testing.T) {
+	p := newReplacementTestPolicyEngine(t, 10)
+
+	// A rise of 1% from escalation, or 0.1% in the oracle price, would be rejected by the node as an underpriced replacement
+	mtx := resubmitWithOraclePrice(t, p, `1000`, `1001`)
+	assert.Equal(t, `1100`, mtx.GasPrice.String())
+
+	// A rise beyond the minimum is used as-is
+	mtx = resubmitWithOraclePrice(t, p, `1000`, `1500`)
+	assert.Equal(t, `1500`, mtx.GasPrice.String())
+}
+
+func TestReplacementEIP1559BumpsEveryField(t *testing.T) {
+	p := newReplacementTestPolicyEngine(t, 10)
+
+	mtx := resubmitWithOraclePrice(t, p,
+		`{"maxFeePerGas":"1000","maxPriorityFeePerGas":"100"}`,
+		`{"maxFeePerGas":"1005","maxPriorityFeePerGas":"100"}`)
+	assert.Equal(t, "1100", mtx.GasPrice.JSONObject().GetString("maxFeePerGas"))
+	assert.Equal(t, "110", mtx.GasPrice.JSONObject().GetString("maxPriorityFeePerGas"))
+}
+
+func TestReplacementFallKeepsPreviousPrice(t *testing.T) {
+	p := newReplacementTestPolicyEngine(t, 10).(*simplePolicyEngine)
+
+	mtx := &apitypes.ManagedTX{GasPrice: fftypes.JSONAnyPtr(`1000`)}
+	assert.Equal(t, `1000`, p.replacementGasPrice(context.Background(), mtx, fftypes.JSONAnyPtr(`900`)).String())
+}
+
+func TestReplacementChangedStructureNotCompared(t *testing.T) {
+	p := newReplacementTestPolicyEngine(t, 10).(*simplePolicyEngine)
+
+	mtx := &apitypes.ManagedTX{GasPrice: fftypes.JSONAnyPtr(`1000`)}
+	gasPrice := p.replacementGasPrice(context.Background(), mtx, fftypes.JSONAnyPtr(`{"maxFeePerGas":"1001","maxPriorityFeePerGas":"100"}`))
+	assert.Equal(t, `{"maxFeePerGas":"1001","maxPriorityFeePerGas":"100"}`, gasPrice.String())
+
+	mtx = &apitypes.ManagedTX{GasPrice: fftypes.JSONAnyPtr(`{"unit":"gwei"}`)}
+	assert.Equal(t, `1001`, p.replacementGasPrice(context.Background(), mtx, fftypes.JSONAnyPtr(`1001`)).String())
+}
+
+func TestReplacementMinimumBumpDisabled(t *testing.T) {
+	p := newReplacementTestPolicyEngine(t, 0)
+
+	mtx := resubmitWithOraclePrice(t, p, `1000`, `1011`)
+	assert.Equal(t, `1011`, mtx.GasPrice.String())
+}
+
+func TestReplacementBadMinimumBump(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(MinReplacementBump, -1)
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21100", err)
+}
+
+func TestGasPriceFloor(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.SubSection(GasOracleConfig).Set(GasOracleQueryInterval, "0s")
+	conf.Set(MinGasPrice, "5000")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`1000`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`{"gasPrice":"1000","unit":"wei"}`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`{"maxFeePerGas":"1000"}`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`true`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	gasPrice, err := p.(*simplePolicyEngine).EstimateGasPrice(ctx, mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.Equal(t, `5000`, gasPrice.String())
+
+	gasPrice, err = p.(*simplePolicyEngine).EstimateGasPrice(ctx, mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.Equal(t, "5000", gasPrice.JSONObject().GetString("gasPrice"))
+
+	// The floor only applies to legacy gas prices
+	gasPrice, err = p.(*simplePolicyEngine).EstimateGasPrice(ctx, mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"maxFeePerGas":"1000"}`, gasPrice.String())
+
+	gasPrice, err = p.(*simplePolicyEngine).EstimateGasPrice(ctx, mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.Equal(t, `true`, gasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestBadGasPriceFloor(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(MinGasPrice, "lots")
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21099.*minGasPrice", err)
+}
+
+type rawTestConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.RawTransactionAPI
+}
+
+func newRawTestTX() *apitypes.ManagedTX {
+	return &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		Nonce:          fftypes.NewFFBigInt(12),
+		RawTransaction: "0xf86c0a8502540be400",
+	}
+}
+
+func TestRawTransactionSendOK(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newRawTestTX()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockRawAPI := &ffcapimocks.RawTransactionAPI{}
+	mockRawAPI.On("TransactionSendRaw", mock.Anything, &ffcapi.TransactionSendRawRequest{
+		RawTransaction: "0xf86c0a8502540be400",
+	}).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, &rawTestConnector{mockFFCAPI, mockRawAPI}, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+	assert.NotNil(t, mtx.FirstSubmit)
+	assert.Nil(t, mtx.GasPrice)
+
+	mockFFCAPI.AssertExpectations(t)
+	mockRawAPI.AssertExpectations(t)
+}
+
+func TestRawTransactionResubmitUnchanged(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.Set(ResubmitInterval, "1s")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 10)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := newRawTestTX()
+	mtx.FirstSubmit = &submitTime
+	mtx.LastSubmit = &submitTime
+	mtx.TransactionHash = "0x12345"
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockRawAPI := &ffcapimocks.RawTransactionAPI{}
+	mockRawAPI.On("TransactionSendRaw", mock.Anything, &ffcapi.TransactionSendRawRequest{
+		RawTransaction: "0xf86c0a8502540be400",
+	}).Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("known transaction"))
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, &rawTestConnector{mockFFCAPI, mockRawAPI}, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+	assert.Nil(t, mtx.GasPrice)
+
+	mockFFCAPI.AssertExpectations(t)
+	mockRawAPI.AssertExpectations(t)
+}
+
+func TestRawTransactionNotSupported(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mockFFCAPI := &ffcapimocks.API{}
+
+	ctx := context.Background()
+	updated, _, err := p.Execute(ctx, mockFFCAPI, newRawTestTX())
+	assert.Regexp(t, "FF21116", err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func newCancelTestTX(gasPrice string) *apitypes.ManagedTX {
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	return &apitypes.ManagedTX{
+		ID:    "ns1:" + fftypes.NewUUID().String(),
+		Nonce: fftypes.NewFFBigInt(10),
+		Gas:   fftypes.NewFFBigInt(21000),
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+			To:   "0x1ec3b9fa2c2d2ab0e5c5a3b7e7b8fb1e8e4f7f13",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x12345",
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+		GasPrice:        fftypes.JSONAnyPtr(gasPrice),
+		Cancel:          &apitypes.ManagedTXCancel{Requested: fftypes.Now()},
+	}
+}
+
+func TestCancelSubmitsNoOpAtSameNonce(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(MinReplacementBump, 0)
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 0)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `100`)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newCancelTestTX(`100`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.From == mtx.TransactionHeaders.From &&
+			req.To == mtx.TransactionHeaders.From &&
+			req.Nonce.Int64() == 10 &&
+			req.Gas.Int64() == 21000 &&
+			req.TransactionData == "" &&
+			req.GasPrice.String() == `110` // default bump, as no replacement bump or escalation is configured
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0xcancel1",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0xcancel1", mtx.Cancel.TransactionHash)
+	assert.Equal(t, `110`, mtx.Cancel.GasPrice.String())
+	assert.NotNil(t, mtx.Cancel.FirstSubmit)
+	assert.Equal(t, mtx.Cancel.FirstSubmit, mtx.Cancel.LastSubmit)
+	// The original is left unchanged
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+	assert.Equal(t, `100`, mtx.GasPrice.String())
+
+	// Not resubmitted until the resubmit interval has passed
+	updated, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	// No action once the cancellation has a receipt
+	mtx.Cancel.LastSubmit = mtx.FirstSubmit
+	mtx.Cancel.Receipt = &ffcapi.TransactionReceiptResponse{}
+	updated, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelResubmitBumpsUpToCap(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.Set(MinReplacementBump, 25)
+	conf.Set(MaxGasPrice, "150")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newCancelTestTX(`100`)
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx.Cancel.TransactionHash = "0xcancel1"
+	mtx.Cancel.GasPrice = fftypes.JSONAnyPtr(`125`)
+	mtx.Cancel.FirstSubmit = &submitTime
+	mtx.Cancel.LastSubmit = &submitTime
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`90`),
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == `150`
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0xcancel2",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0xcancel2", mtx.Cancel.TransactionHash)
+	assert.Equal(t, `150`, mtx.Cancel.GasPrice.String())
+	assert.Equal(t, &submitTime, mtx.Cancel.FirstSubmit)
+	assert.True(t, mtx.Cancel.LastSubmit.Time().After(*submitTime.Time()))
+	assert.Regexp(t, "FF21072", mtx.ErrorHistory[0].Error)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelGasPriceRefreshFailKnownTransaction(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.Set(MinReplacementBump, 0)
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 20)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newCancelTestTX(`100`)
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx.Cancel.TransactionHash = "0xcancel1"
+	mtx.Cancel.GasPrice = fftypes.JSONAnyPtr(`120`)
+	mtx.Cancel.LastSubmit = &submitTime
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == `144`
+	})).Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("known transaction")).Once()
+
+	ctx := context.Background()
+	updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0xcancel1", mtx.Cancel.TransactionHash)
+	assert.Equal(t, `120`, mtx.Cancel.GasPrice.String())
+	assert.NotNil(t, mtx.Cancel.FirstSubmit)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelSendFail(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newCancelTestTX(`not a number`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`90`),
+	}, ffcapi.ErrorReason(""), nil)
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		// The previous price cannot be bumped, so the oracle price is used
+		return req.GasPrice.String() == `90`
+	})).Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("pop")).Once()
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, ffcapi.ErrorKnownTransaction, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Empty(t, mtx.Cancel.TransactionHash)
+	assert.Nil(t, mtx.Cancel.LastSubmit)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func newTestFeeTypePolicyEngine(t *testing.T, feeType, gasPrice string) *simplePolicyEngine {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, gasPrice)
+	conf.Set(FeeType, feeType)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+	return p.(*simplePolicyEngine)
+}
+
+func TestFeeTypeInvalid(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(FeeType, "wrong")
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21138", err)
+}
+
+func TestFeeTypeAutoEIP1559Supported(t *testing.T) {
+	p := newTestFeeTypePolicyEngine(t, FeeTypeAuto, `{"gasPrice":"100","maxFeePerGas":"200","maxPriorityFeePerGas":"10"}`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Name:         "evmconnect",
+		Capabilities: []ffcapi.Capability{ffcapi.CapabilityEIP1559},
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		gasPrice, err := p.EstimateGasPrice(ctx, mockFFCAPI, &apitypes.ManagedTX{})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"maxFeePerGas":"200","maxPriorityFeePerGas":"10"}`, gasPrice.String())
+	}
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFeeTypeAutoEIP1559Unsupported(t *testing.T) {
+	p := newTestFeeTypePolicyEngine(t, FeeTypeAuto, `{"gasPrice":"100","maxFeePerGas":"200","maxPriorityFeePerGas":"10"}`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Name: "fabconnect",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		gasPrice, err := p.EstimateGasPrice(ctx, mockFFCAPI, &apitypes.ManagedTX{})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"gasPrice":"100"}`, gasPrice.String())
+	}
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFeeTypeAutoEIP1559OnlyUnsupported(t *testing.T) {
+	p := newTestFeeTypePolicyEngine(t, FeeTypeAuto, `{"maxFeePerGas":"200","maxPriorityFeePerGas":"10"}`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{}, ffcapi.ErrorReason(""), nil).Once()
+
+	gasPrice, err := p.EstimateGasPrice(context.Background(), mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"gasPrice":"200"}`, gasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFeeTypeAutoConnectorInfoFailRetried(t *testing.T) {
+	p := newTestFeeTypePolicyEngine(t, FeeTypeAuto, `{"gasPrice":"100","maxFeePerGas":"200","maxPriorityFeePerGas":"10"}`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("ConnectorInfo", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	mockFFCAPI.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Capabilities: []ffcapi.Capability{ffcapi.CapabilityEIP1559},
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	gasPrice, err := p.EstimateGasPrice(ctx, mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"gasPrice":"100"}`, gasPrice.String())
+
+	gasPrice, err = p.EstimateGasPrice(ctx, mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":"200","maxPriorityFeePerGas":"10"}`, gasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFeeTypeExplicitLegacyUnchanged(t *testing.T) {
+	p := newTestFeeTypePolicyEngine(t, FeeTypeEIP1559, `{"gasPrice":"100"}`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	gasPrice, err := p.EstimateGasPrice(context.Background(), mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"gasPrice":"100"}`, gasPrice.String())
+
+	p = newTestFeeTypePolicyEngine(t, FeeTypeAuto, `12345`)
+	gasPrice, err = p.EstimateGasPrice(context.Background(), mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.Equal(t, `12345`, gasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFeeTypeConfiguredLegacy(t *testing.T) {
+	p := newTestFeeTypePolicyEngine(t, FeeTypeLegacy, `{"gasPrice":"100","maxFeePerGas":"200","maxPriorityFeePerGas":"10"}`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	gasPrice, err := p.EstimateGasPrice(context.Background(), mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"gasPrice":"100"}`, gasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFeeTypeNotSetPassthrough(t *testing.T) {
+	p := newTestFeeTypePolicyEngine(t, "", `{"gasPrice":"100","maxFeePerGas":"200","maxPriorityFeePerGas":"10"}`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	gasPrice, err := p.EstimateGasPrice(context.Background(), mockFFCAPI, &apitypes.ManagedTX{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"gasPrice":"100","maxFeePerGas":"200","maxPriorityFeePerGas":"10"}`, gasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+type testGasOracleFactory struct {
+	oracle *gasoraclemocks.GasOracle
+}
+
+func (f *testGasOracleFactory) Name() string { return "unittest" }
+
+func (f *testGasOracleFactory) InitConfig(conf config.Section) {}
+
+func (f *testGasOracleFactory) NewGasOracle(ctx context.Context, conf config.Section) (gasoracle.GasOracle, error) {
+	return f.oracle, nil
+}
+
+func TestGasOracleSelectedByName(t *testing.T) {
+
+	mgo := &gasoraclemocks.GasOracle{}
+	gasoracles.RegisterOracle(&testGasOracleFactory{oracle: mgo})
+	mockFFCAPI := &ffcapimocks.API{}
+	mgo.On("GasPrice", mock.Anything, mockFFCAPI).Return(fftypes.JSONAnyPtr(`{"gasPrice":"222"}`), nil)
+
+	sendWithOracle := func(mode, expectedGasPrice string, setup func(conf config.Section)) {
+		f, conf := newTestPolicyEngineFactory(t)
+		conf.SubSection(GasOracleConfig).Set(GasOracleMode, mode)
+		conf.Set(FixedGasPrice, `{"gasPrice":"111"}`) // only used when the gas oracle is disabled
+		setup(conf.SubSection(GasOracleConfig))
+		p, err := f.NewPolicyEngine(context.Background(), conf)
+		assert.NoError(t, err)
+
+		mtx := &apitypes.ManagedTX{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712"},
+		}
+		mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+			return req.GasPrice.JSONObject().GetString("gasPrice") == expectedGasPrice
+		})).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x12345"}, ffcapi.ErrorReason(""), nil).Once()
+		_, _, err = p.Execute(context.Background(), mockFFCAPI, mtx)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedGasPrice, mtx.GasPrice.JSONObject().GetString("gasPrice"))
+	}
+
+	sendWithOracle("unittest", "222", func(conf config.Section) {})
+	sendWithOracle(GasOracleModeFixed, "333", func(conf config.Section) {
+		conf.Set(GasOracleFixedGasPrice, `{"gasPrice":"333"}`)
+	})
+	sendWithOracle(GasOracleModeDisabled, "111", func(conf config.Section) {})
+
+	mgo.AssertExpectations(t)
+	mockFFCAPI.AssertExpectations(t)
+
+}
+
+func TestGasOracleNotRegistered(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, "wrong")
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21149", err)
+}
+
+type testRateProviderFactory struct {
+	provider *exchangeratemocks.RateProvider
+}
+
+func (f *testRateProviderFactory) Name() string { return "unittest" }
+
+func (f *testRateProviderFactory) InitConfig(conf config.Section) {}
+
+func (f *testRateProviderFactory) NewRateProvider(ctx context.Context, conf config.Section) (exchangerate.RateProvider, error) {
+	return f.provider, nil
+}
+
+func newFiatGasCapTestPolicyEngine(t *testing.T, maxCost string, mrp *exchangeratemocks.RateProvider) *simplePolicyEngine {
+	exchangerates.RegisterProvider(&testRateProviderFactory{provider: mrp})
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `{"maxFeePerGas":"50000000000","maxPriorityFeePerGas":"2000000000"}`)
+	fiatGasCapConfig := conf.SubSection(FiatGasCapConfig)
+	fiatGasCapConfig.Set(FiatGasCapMaxCost, maxCost)
+	fiatGasCapConfig.Set(FiatGasCapRateProvider, "unittest")
+	fiatGasCapConfig.Set(FiatGasCapQueryInterval, "0s")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+	return p.(*simplePolicyEngine)
+}
+
+func TestFiatGasCapTracksLimitAndRate(t *testing.T) {
+
+	for _, tc := range []struct {
+		maxCost  string
+		rate     *big.Rat
+		gas      int64
+		expected string
+	}{
+		{maxCost: "5", rate: big.NewRat(2500, 1), gas: 100000, expected: "20000000000"},
+		{maxCost: "5", rate: big.NewRat(5000, 1), gas: 100000, expected: "10000000000"},
+		{maxCost: "2.50", rate: big.NewRat(2500, 1), gas: 100000, expected: "10000000000"},
+		{maxCost: "10", rate: big.NewRat(2500, 1), gas: 100000, expected: "40000000000"},
+		{maxCost: "5", rate: big.NewRat(2500, 1), gas: 200000, expected: "10000000000"},
+		{maxCost: "1", rate: big.NewRat(3, 1), gas: 1, expected: "333333333333333333"}, // rounded down
+	} {
+		mrp := &exchangeratemocks.RateProvider{}
+		mrp.On("Rate", mock.Anything).Return(tc.rate, nil)
+		p := newFiatGasCapTestPolicyEngine(t, tc.maxCost, mrp)
+
+		fiatCap, err := p.fiatGasPriceCap(context.Background(), &apitypes.ManagedTX{Gas: fftypes.NewFFBigInt(tc.gas)})
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, fiatCap.ceiling.String(), "maxCost=%s rate=%s gas=%d", tc.maxCost, tc.rate, tc.gas)
+	}
+
+}
+
+func TestFiatGasCapAppliedEachCycle(t *testing.T) {
+
+	mrp := &exchangeratemocks.RateProvider{}
+	mrp.On("Rate", mock.Anything).Return(big.NewRat(2500, 1), nil).Once()
+	mrp.On("Rate", mock.Anything).Return(big.NewRat(5000, 1), nil).Once()
+	mrp.On("Rate", mock.Anything).Return(big.NewRat(100000, 1), nil).Twice()
+	p := newFiatGasCapTestPolicyEngine(t, "5", mrp)
+
+	// The ceiling falls as the rate rises: 20 gwei, then 10 gwei, then 0.5 gwei where the priority fee is also capped.
+	// A warning is recorded each time the ceiling of a field changes, but not when it stays the same.
+	mtx := &apitypes.ManagedTX{ID: "ns1:tx1", Gas: fftypes.NewFFBigInt(100000)}
+	info := &simplePolicyInfo{}
+	for _, expected := range []string{
+		`{"maxFeePerGas":"20000000000","maxPriorityFeePerGas":"2000000000"}`,
+		`{"maxFeePerGas":"10000000000","maxPriorityFeePerGas":"2000000000"}`,
+		`{"maxFeePerGas":"500000000","maxPriorityFeePerGas":"500000000"}`,
+		`{"maxFeePerGas":"500000000","maxPriorityFeePerGas":"500000000"}`,
+	} {
+		gasPrice, err := p.initialGasPrice(context.Background(), &ffcapimocks.API{}, mtx, info)
+		assert.NoError(t, err)
+		assert.JSONEq(t, expected, gasPrice.String())
+	}
+	assert.Equal(t, map[string]string{"maxFeePerGas": "500000000", "maxPriorityFeePerGas": "500000000"}, info.GasPriceCaps)
+	assert.Len(t, mtx.ErrorHistory, 4)
+	assert.Regexp(t, "FF21181.*max(Priority)?FeePerGas.*500000000.*5.*100000", mtx.ErrorHistory[0].Error)
+	assert.Regexp(t, "FF21181.*max(Priority)?FeePerGas.*500000000.*5.*100000", mtx.ErrorHistory[1].Error)
+	assert.Regexp(t, "FF21181.*maxFeePerGas.*50000000000.*10000000000.*5.*5000", mtx.ErrorHistory[2].Error)
+	assert.Regexp(t, "FF21181.*maxFeePerGas.*50000000000.*20000000000.*5.*2500", mtx.ErrorHistory[3].Error)
+
+	mrp.AssertExpectations(t)
+}
+
+func TestFiatGasCapStopsBumping(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+	conf.SubSection(GasOracleConfig).Set(GasOracleQueryInterval, "0s")
+	conf.Set(MaxGasPrice, "30000000000") // the static cap is above the fiat ceiling, so is not reached
+	conf.SubSection(EscalationConfig).Set(EscalationInterval, "1m")
+	conf.SubSection(EscalationConfig).Set(EscalationPercentage, 10)
+	fiatGasCapConfig := conf.SubSection(FiatGasCapConfig)
+	fiatGasCapConfig.Set(FiatGasCapMaxCost, "5.00")
+	fiatGasCapConfig.Set(FiatGasCapFixedRate, "2500")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		ID:  "ns1:tx1",
+		Gas: fftypes.NewFFBigInt(100000),
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	for _, oracleGasPrice := range []string{`15000000000`, `25000000000`, `40000000000`} {
+		mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+			GasPrice: fftypes.JSONAnyPtr(oracleGasPrice),
+		}, ffcapi.ErrorReason(""), nil).Once()
+	}
+	var submitted []string
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		submitted = append(submitted, args[1].(*ffcapi.TransactionSendRequest).GasPrice.String())
+	}).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, _, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Empty(t, mtx.ErrorHistory)
+
+	// Escalations bump the gas price up to the 20 gwei ceiling derived from the fiat cap, but never beyond it
+	for i := 0; i < 2; i++ {
+		advanceEscalationTime(mtx, 100*time.Hour)
+		updated, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+		assert.NoError(t, err)
+		assert.Equal(t, policyengine.UpdateYes, updated)
+	}
+	assert.Equal(t, []string{`15000000000`, `20000000000`, `20000000000`}, submitted)
+	assert.Len(t, mtx.ErrorHistory, 1)
+	assert.Regexp(t, "FF21181.*gasPrice.*25000000000.*20000000000.*5.*2500", mtx.ErrorHistory[0].Error)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFiatGasCapLowerStaticCapApplies(t *testing.T) {
+
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `50000000000`)
+	conf.Set(MaxGasPrice, "15000000000")
+	fiatGasCapConfig := conf.SubSection(FiatGasCapConfig)
+	fiatGasCapConfig.Set(FiatGasCapMaxCost, "5")
+	fiatGasCapConfig.Set(FiatGasCapFixedRate, "2500")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{Gas: fftypes.NewFFBigInt(100000)}
+	gasPrice, err := p.(*simplePolicyEngine).initialGasPrice(context.Background(), &ffcapimocks.API{}, mtx, &simplePolicyInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, `15000000000`, gasPrice.String())
+	assert.Len(t, mtx.ErrorHistory, 1)
+	assert.Regexp(t, "FF21072", mtx.ErrorHistory[0].Error)
+}
+
+func TestFiatGasCapRateCached(t *testing.T) {
+
+	mrp := &exchangeratemocks.RateProvider{}
+	mrp.On("Rate", mock.Anything).Return(big.NewRat(2500, 1), nil).Once()
+	p := newFiatGasCapTestPolicyEngine(t, "5", mrp)
+	p.rateQueryInterval = 1 * time.Hour
+
+	for i := 0; i < 2; i++ {
+		fiatCap, err := p.fiatGasPriceCap(context.Background(), &apitypes.ManagedTX{Gas: fftypes.NewFFBigInt(100000)})
+		assert.NoError(t, err)
+		assert.Equal(t, "20000000000", fiatCap.ceiling.String())
+	}
+
+	mrp.AssertExpectations(t)
+}
+
+func TestFiatGasCapRateFail(t *testing.T) {
+
+	mrp := &exchangeratemocks.RateProvider{}
+	mrp.On("Rate", mock.Anything).Return(nil, fmt.Errorf("pop"))
+	p := newFiatGasCapTestPolicyEngine(t, "5", mrp)
+
+	// The initial gas price cannot be calculated
+	_, err := p.EstimateGasPrice(context.Background(), &ffcapimocks.API{}, &apitypes.ManagedTX{Gas: fftypes.NewFFBigInt(100000)})
+	assert.Regexp(t, "pop", err)
+
+	// A resubmission uses the previous gas price
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		ID:  "ns1:tx1",
+		Gas: fftypes.NewFFBigInt(100000),
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		FirstSubmit:     &submitTime,
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+	}
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == `12345`
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+	updated, _, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+
+	// As does an escalation
+	p.escalationInterval = time.Minute
+	p.escalationPercentage = 10
+	advanceEscalationTime(mtx, 100*time.Hour)
+	updated, _, err = p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, `12345`, mtx.GasPrice.String())
+
+	// A cancellation is not sent
+	updated, _, err = p.Execute(context.Background(), mockFFCAPI, newCancelTestTX(`100`))
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFiatGasCapNoGas(t *testing.T) {
+	p := newFiatGasCapTestPolicyEngine(t, "5", &exchangeratemocks.RateProvider{})
+	_, err := p.EstimateGasPrice(context.Background(), &ffcapimocks.API{}, &apitypes.ManagedTX{ID: "ns1:tx1"})
+	assert.Regexp(t, "FF21182.*ns1:tx1", err)
+}
+
+func TestFiatGasCapBadConfig(t *testing.T) {
+
+	newWithFiatGasCap := func(setup func(conf config.Section)) error {
+		f, conf := newTestPolicyEngineFactory(t)
+		conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeConnector)
+		fiatGasCapConfig := conf.SubSection(FiatGasCapConfig)
+		fiatGasCapConfig.Set(FiatGasCapMaxCost, "5")
+		fiatGasCapConfig.Set(FiatGasCapFixedRate, "2500")
+		setup(fiatGasCapConfig)
+		_, err := f.NewPolicyEngine(context.Background(), conf)
+		return err
+	}
+
+	assert.NoError(t, newWithFiatGasCap(func(conf config.Section) {}))
+	assert.Regexp(t, "FF21180.*maxCost", newWithFiatGasCap(func(conf config.Section) { conf.Set(FiatGasCapMaxCost, "lots") }))
+	assert.Regexp(t, "FF21180.*maxCost", newWithFiatGasCap(func(conf config.Section) { conf.Set(FiatGasCapMaxCost, "0") }))
+	assert.Regexp(t, "FF21180.*decimals", newWithFiatGasCap(func(conf config.Section) { conf.Set(FiatGasCapDecimals, -1) }))
+	assert.Regexp(t, "FF21175", newWithFiatGasCap(func(conf config.Section) { conf.Set(FiatGasCapRateProvider, "wrong") }))
+	assert.Regexp(t, "FF21176", newWithFiatGasCap(func(conf config.Section) { conf.Set(FiatGasCapFixedRate, "") }))
+
+}
+
+type simulationTestConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.SimulationAPI
+}
+
+func newTestSimulationPolicyEngine(t *testing.T, simulate bool) *simplePolicyEngine {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, "12345")
+	conf.Set(Simulate, simulate)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+	return p.(*simplePolicyEngine)
+}
+
+func newSimulationTestConnector(capabilities ...ffcapi.Capability) *simulationTestConnector {
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Name:         "test",
+		Capabilities: capabilities,
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	return &simulationTestConnector{mockFFCAPI, &ffcapimocks.SimulationAPI{}}
+}
+
+func newSimulationTestTX() *apitypes.ManagedTX {
+	return &apitypes.ManagedTX{
+		ID: "ns1:" + fftypes.NewUUID().String(),
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+			To:   "0xb1c0b6cd6e1bdb2c1fd2ce4bd1b4b6df7b5a9a4c",
+		},
+		Nonce:           fftypes.NewFFBigInt(12),
+		Gas:             fftypes.NewFFBigInt(50000),
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+}
+
+func TestSimulateRevertsFailsFast(t *testing.T) {
+	p := newTestSimulationPolicyEngine(t, true)
+	mtx := newSimulationTestTX()
+
+	tc := newSimulationTestConnector(ffcapi.CapabilitySimulation)
+	tc.SimulationAPI.On("TransactionSimulate", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSimulateRequest) bool {
+		return req.From == "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712" &&
+			req.Nonce.Int64() == 12 &&
+			req.Gas.Int64() == 50000 &&
+			req.GasPrice.String() == "12345" &&
+			req.TransactionData == "SOME_RAW_TX_BYTES"
+	})).Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("execution reverted: Not allowed"))
+
+	updated, reason, err := p.Execute(context.Background(), tc, mtx)
+	assert.Regexp(t, "FF21187.*Not allowed", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, reason)
+	assert.Equal(t, policyengine.UpdateFailed, updated)
+	assert.Nil(t, mtx.FirstSubmit)
+	assert.Empty(t, mtx.TransactionHash)
+
+	tc.API.AssertExpectations(t)
+	tc.SimulationAPI.AssertExpectations(t)
+}
+
+func TestSimulateSucceedsThenSubmits(t *testing.T) {
+	// Simulation is disabled by default, but requested for the transaction
+	p := newTestSimulationPolicyEngine(t, false)
+	mtx := newSimulationTestTX()
+	simulate := true
+	mtx.Simulate = &simulate
+
+	tc := newSimulationTestConnector(ffcapi.CapabilitySimulation)
+	simulated := false
+	tc.SimulationAPI.On("TransactionSimulate", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSimulateResponse{}, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		simulated = true
+	})
+	tc.API.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return simulated && req.TransactionData == "SOME_RAW_TX_BYTES"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	updated, reason, err := p.Execute(context.Background(), tc, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+	assert.NotNil(t, mtx.FirstSubmit)
+
+	tc.API.AssertExpectations(t)
+	tc.SimulationAPI.AssertExpectations(t)
+}
+
+func TestSimulateFailureRetried(t *testing.T) {
+	p := newTestSimulationPolicyEngine(t, true)
+	mtx := newSimulationTestTX()
+
+	tc := newSimulationTestConnector(ffcapi.CapabilitySimulation)
+	tc.SimulationAPI.On("TransactionSimulate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	// An error other than a revert does not fail the transaction, so the simulation is retried
+	updated, reason, err := p.Execute(context.Background(), tc, mtx)
+	assert.Regexp(t, "pop", err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+	assert.Nil(t, mtx.FirstSubmit)
+
+	tc.API.AssertExpectations(t)
+	tc.SimulationAPI.AssertExpectations(t)
+}
+
+func TestSimulateSkipped(t *testing.T) {
+	p := newTestSimulationPolicyEngine(t, true)
+	notSimulated := false
+
+	for _, tc := range []struct {
+		name      string
+		connector func() ffcapi.API
+		mtx       func() *apitypes.ManagedTX
+	}{
+		{
+			name:      "capability not reported",
+			connector: func() ffcapi.API { return newSimulationTestConnector() },
+			mtx:       newSimulationTestTX,
+		},
+		{
+			name: "API not implemented",
+			connector: func() ffcapi.API {
+				return newSimulationTestConnector(ffcapi.CapabilitySimulation).API
+			},
+			mtx: newSimulationTestTX,
+		},
+		{
+			name:      "disabled on the transaction",
+			connector: func() ffcapi.API { return newSimulationTestConnector(ffcapi.CapabilitySimulation) },
+			mtx: func() *apitypes.ManagedTX {
+				mtx := newSimulationTestTX()
+				mtx.Simulate = &notSimulated
+				return mtx
+			},
+		},
+	} {
+		// Support is cached once the connector has been queried
+		p.simulationSupported = nil
+		cAPI := tc.connector()
+		var mockFFCAPI *ffcapimocks.API
+		switch c := cAPI.(type) {
+		case *simulationTestConnector:
+			mockFFCAPI = c.API
+		default:
+			mockFFCAPI = c.(*ffcapimocks.API)
+		}
+		mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+			TransactionHash: "0x12345",
+		}, ffcapi.ErrorReason(""), nil)
+
+		mtx := tc.mtx()
+		updated, _, err := p.Execute(context.Background(), cAPI, mtx)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, policyengine.UpdateYes, updated, tc.name)
+		assert.Equal(t, "0x12345", mtx.TransactionHash, tc.name)
+		mockFFCAPI.AssertExpectations(t)
+	}
+}
+
+func TestSimulateSupportCached(t *testing.T) {
+	p := newTestSimulationPolicyEngine(t, true)
+
+	tc := &simulationTestConnector{&ffcapimocks.API{}, &ffcapimocks.SimulationAPI{}}
+	tc.API.On("ConnectorInfo", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	tc.API.On("ConnectorInfo", mock.Anything, mock.Anything).Return(&ffcapi.ConnectorInfoResponse{
+		Capabilities: []ffcapi.Capability{ffcapi.CapabilitySimulation},
+	}, ffcapi.ErrorReason(""), nil).Once()
+	tc.SimulationAPI.On("TransactionSimulate", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSimulateResponse{}, ffcapi.ErrorReason(""), nil).Twice()
+
+	// Simulation is skipped while the connector cannot be queried
+	ctx := context.Background()
+	assert.False(t, p.resolveSimulationSupported(ctx, tc))
+	for i := 0; i < 2; i++ {
+		reason, err := p.simulateTX(ctx, tc, newSimulationTestTX())
+		assert.NoError(t, err)
+		assert.Empty(t, reason)
+	}
+
+	tc.API.AssertExpectations(t)
+	tc.SimulationAPI.AssertExpectations(t)
+}
+
+func TestSimulateRawTransactionSkipped(t *testing.T) {
+	p := newTestSimulationPolicyEngine(t, true)
+	mtx := newRawTestTX()
+	assert.False(t, p.shouldSimulate(mtx))
+	assert.True(t, p.shouldSimulate(newSimulationTestTX()))
+}